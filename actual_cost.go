@@ -0,0 +1,77 @@
+package apifu
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+type actualCostContextKeyType int
+
+var actualCostContextKey actualCostContextKeyType
+
+// Tracks the cost of a single resolved field.
+type actualCostField struct {
+	estimated int
+
+	// Accessed atomically since resolvers may report costs from other goroutines.
+	reported    int64
+	hasReported int32
+}
+
+// ReportCost reports the cost actually incurred by the resolver of the field being resolved,
+// replacing the field's estimated resolver cost when the operation's actual cost is calculated.
+// For example, a resolver that finds its result in a cache might report a cost of 0. The context
+// must be the one given to the resolver via graphql.FieldContext. If it's called more than once for
+// the same field, the last cost wins.
+//
+// This has no effect unless Config.TrackActualCost is true. See RequestInfo.ActualCost.
+func ReportCost(ctx context.Context, cost int) {
+	if field, ok := ctx.Value(actualCostContextKey).(*actualCostField); ok {
+		atomic.StoreInt64(&field.reported, int64(cost))
+		atomic.StoreInt32(&field.hasReported, 1)
+	}
+}
+
+// withActualCost wraps execute so that RequestInfo.ActualCost is calculated if
+// Config.TrackActualCost is true.
+func withActualCost(cfg *Config, execute func(*graphql.Request, *RequestInfo) *graphql.Response) func(*graphql.Request, *RequestInfo) *graphql.Response {
+	if !cfg.TrackActualCost {
+		return execute
+	}
+	return func(r *graphql.Request, info *RequestInfo) *graphql.Response {
+		// The executor invokes the observers' callbacks from its own goroutine, so the cost can be
+		// adjusted directly. This allows Config.Execute to see the actual cost as soon as
+		// graphql.Execute returns.
+		info.ActualCost = info.Cost
+
+		req := *r
+		observeField := req.ObserveField
+		req.ObserveField = func(ctx context.Context, observed *graphql.ObservedField) (context.Context, func(error)) {
+			var done func(error)
+			if observeField != nil {
+				ctx, done = observeField(ctx, observed)
+			}
+			field := &actualCostField{
+				estimated: cfg.DefaultFieldCost.Resolver,
+			}
+			if observed.Definition.Cost != nil {
+				field.estimated = observed.Definition.Cost(graphql.FieldCostContext{
+					Context:   ctx,
+					Arguments: observed.Arguments,
+				}).Resolver
+			}
+			return context.WithValue(ctx, actualCostContextKey, field), func(err error) {
+				if done != nil {
+					done(err)
+				}
+				if atomic.LoadInt32(&field.hasReported) != 0 {
+					info.ActualCost += int(atomic.LoadInt64(&field.reported)) - field.estimated
+				}
+			}
+		}
+
+		return execute(&req, info)
+	}
+}
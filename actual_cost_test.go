@@ -0,0 +1,86 @@
+package apifu
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestReportCost(t *testing.T) {
+	testCfg := Config{
+		DefaultFieldCost: graphql.FieldCost{
+			Resolver: 1,
+		},
+		ReportCost:      true,
+		TrackActualCost: true,
+	}
+	var actualCost int
+	testCfg.Execute = func(r *graphql.Request, info *RequestInfo) *graphql.Response {
+		resp := graphql.Execute(r)
+		actualCost = info.ActualCost
+		return resp
+	}
+	testCfg.AddQueryField("cached", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Cost: graphql.FieldResolverCost(10),
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			ReportCost(ctx.Context, 0)
+			return 1, nil
+		},
+	})
+	testCfg.AddQueryField("async", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Cost: graphql.FieldResolverCost(10),
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return Go(ctx.Context, func() (interface{}, error) {
+				ReportCost(ctx.Context, 3)
+				return 1, nil
+			}), nil
+		},
+	})
+	testCfg.AddQueryField("unreported", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Query    string
+		Expected int
+	}{
+		"Cached": {
+			Query:    `{cached}`,
+			Expected: 0,
+		},
+		"Async": {
+			Query:    `{async}`,
+			Expected: 3,
+		},
+		"Mixed": {
+			Query:    `{a: cached b: cached async unreported}`,
+			Expected: 4,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := executeGraphQL(t, api, tc.Query)
+			var body struct {
+				Extensions struct {
+					Cost struct {
+						Actual int `json:"actual"`
+					} `json:"cost"`
+				} `json:"extensions"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+			assert.Equal(t, tc.Expected, body.Extensions.Cost.Actual)
+			assert.Equal(t, tc.Expected, actualCost)
+		})
+	}
+}
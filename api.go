@@ -4,14 +4,21 @@ import (
 	"context"
 	"net/http"
 	"reflect"
+	"runtime/debug"
 	"strconv"
 	"sync"
+	"sync/atomic"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/parser"
+	"github.com/ccbrown/api-fu/graphql/schema"
+	"github.com/ccbrown/api-fu/graphql/transport"
+	"github.com/ccbrown/api-fu/graphql/validator"
 )
 
 // API is responsible for serving your API traffic. Construct an API by creating a Config, then
@@ -23,7 +30,32 @@ type API struct {
 	execute func(*graphql.Request, *RequestInfo) *graphql.Response
 
 	graphqlWSConnectionsMutex sync.Mutex
-	graphqlWSConnections      map[graphqlWSConnection]struct{}
+	graphqlWSConnections      map[transport.Connection]struct{}
+
+	webSocketSubprotocolsMutex sync.RWMutex
+	webSocketSubprotocols      []*webSocketSubprotocol
+
+	webhookSubscriptionsMutex sync.Mutex
+	webhookSubscriptions      map[string]*webhookSubscription
+
+	activeSubscriptionsMutex sync.Mutex
+	activeSubscriptions      map[string]*activeSubscription
+
+	documentCache *documentCache
+
+	// If Config.DocumentCacheSize isn't positive, persisted queries are still cached here.
+	persistedQueryDocumentCache *documentCache
+
+	// The operations registered via Config.RegisteredOperations, keyed by name, along with the cache
+	// of their validated documents.
+	registeredOperations             map[string]*registeredOperation
+	registeredOperationDocumentCache *documentCache
+
+	// Updated atomically. See API.PersistedQueryStats.
+	persistedQueryStats PersistedQueryStats
+
+	servicesMutex sync.RWMutex
+	services      map[reflect.Type]interface{}
 }
 
 func (api *API) Schema() *graphql.Schema {
@@ -32,6 +64,19 @@ func (api *API) Schema() *graphql.Schema {
 
 type RequestInfo struct {
 	Cost int
+
+	// The operation's costs for each dimension used by its fields. See graphql.FieldCost.Dimensions.
+	CostDimensions map[string]int
+
+	// If Config.TrackActualCost is true, this is the operation's estimated cost, adjusted by the
+	// costs that resolvers reported via ReportCost. It's final once graphql.Execute returns, so
+	// rate limiters in Config.Execute can use it to charge for actual usage, e.g. so that cache hits
+	// are cheaper.
+	ActualCost int
+
+	// The store shared by the request's resolvers. This is also available to resolvers via
+	// CtxRequestStore.
+	Store *RequestStore
 }
 
 func normalizeModelType(t reflect.Type) reflect.Type {
@@ -57,13 +102,106 @@ func NewAPI(cfg *Config) (*API, error) {
 			return graphql.Execute(r)
 		}
 	}
-	return &API{
+	api := &API{
 		config:               cfg,
 		schema:               schema,
 		logger:               logger,
-		execute:              execute,
-		graphqlWSConnections: map[graphqlWSConnection]struct{}{},
-	}, nil
+		execute:              withRequestScope(withClientTimeout(cfg, withExplain(cfg, withFieldUsage(cfg, withCostReport(cfg, withActualCost(cfg, execute)))))),
+		graphqlWSConnections: map[transport.Connection]struct{}{},
+	}
+	if cfg.DocumentCacheSize > 0 {
+		api.documentCache = newDocumentCache(cfg.DocumentCacheSize)
+		api.documentCache.onEvict = api.documentEvicted
+	} else if cfg.PersistedQueryStorage != nil {
+		api.persistedQueryDocumentCache = newDocumentCache(defaultPersistedQueryDocumentCacheSize)
+		api.persistedQueryDocumentCache.onEvict = api.documentEvicted
+	}
+	if err := api.registerOperations(); err != nil {
+		return nil, err
+	}
+	return api, nil
+}
+
+// withRequestScope wraps execute so that each execution gets a new RequestStore, which is finalized
+// once execution is complete. The context passed to resolvers is canceled once execution is
+// complete so that any goroutines still working on the request know to stop.
+func withRequestScope(execute func(*graphql.Request, *RequestInfo) *graphql.Response) func(*graphql.Request, *RequestInfo) *graphql.Response {
+	return func(r *graphql.Request, info *RequestInfo) *graphql.Response {
+		store := &RequestStore{}
+		defer store.finalize()
+		info.Store = store
+		ctx, cancel := context.WithCancel(context.WithValue(r.Context, requestStoreContextKey, store))
+		defer cancel()
+		req := *r
+		req.Context = ctx
+		return execute(&req, info)
+	}
+}
+
+// withCostReport wraps execute so that responses include a "cost" extension if
+// Config.ReportCost is true.
+func withCostReport(cfg *Config, execute func(*graphql.Request, *RequestInfo) *graphql.Response) func(*graphql.Request, *RequestInfo) *graphql.Response {
+	if !cfg.ReportCost {
+		return execute
+	}
+	return func(r *graphql.Request, info *RequestInfo) *graphql.Response {
+		resp := execute(r, info)
+		if resp.Extensions == nil {
+			resp.Extensions = map[string]interface{}{}
+		}
+		cost := map[string]interface{}{
+			"estimated": info.Cost,
+		}
+		if len(info.CostDimensions) > 0 {
+			cost["dimensions"] = info.CostDimensions
+		}
+		if cfg.TrackActualCost {
+			cost["actual"] = info.ActualCost
+		}
+		resp.Extensions["cost"] = cost
+		return resp
+	}
+}
+
+// validatorRules returns the rules that operations must pass in addition to the standard
+// validation rules.
+func (api *API) validatorRules(req *graphql.Request, info *RequestInfo) []graphql.ValidatorRule {
+	return append([]graphql.ValidatorRule{api.validateOperationCost(req, info, nil)}, api.nonCostValidatorRules(req)...)
+}
+
+// nonCostValidatorRules returns the rules returned by validatorRules other than the cost rule.
+func (api *API) nonCostValidatorRules(req *graphql.Request) []graphql.ValidatorRule {
+	var rules []graphql.ValidatorRule
+	if api.config.EnforceSunsets {
+		rules = append(rules, graphql.ValidateSunsets(clockNow(api.config.Clock)))
+	}
+	if disallowed := disallowedOperationTypes(req.Context); len(disallowed) > 0 {
+		rules = append(rules, graphql.ValidateOperationTypes(req.OperationName, disallowed...))
+	}
+	return rules
+}
+
+// Validates the operation's cost and records it in info. If actual is non-nil, it's set to the
+// operation's cost, including the variables that the cost depends on.
+func (api *API) validateOperationCost(req *graphql.Request, info *RequestInfo, actual *graphql.OperationCost) graphql.ValidatorRule {
+	var maxCost *graphql.OperationCost
+	if len(api.config.MaxCostDimensions) > 0 {
+		maxCost = &graphql.OperationCost{
+			Resolver:   -1,
+			Dimensions: api.config.MaxCostDimensions,
+		}
+	}
+	var cost graphql.OperationCost
+	rule := req.ValidateOperationCost(maxCost, &cost, api.config.DefaultFieldCost)
+	return func(doc *ast.Document, s *schema.Schema, features schema.FeatureSet, typeInfo *validator.TypeInfo) []*validator.Error {
+		ret := rule(doc, s, features, typeInfo)
+		info.Cost = cost.Resolver
+		info.CostDimensions = cost.Dimensions
+		if actual != nil {
+			*actual = cost
+		}
+		return ret
+	}
 }
 
 type apiContextKeyType int
@@ -79,10 +217,83 @@ type asyncResolution struct {
 	Dest   graphql.ResolvePromise
 }
 
+// deferredResolution can be used as the value of an asynchronous resolution to continue resolving
+// on the executor's goroutine, where resolvers may be invoked. Its result is used in place of the
+// asynchronous resolution's result. If it returns a promise, the promise's result is used instead.
+// Such promises must be ones returned by Go, GoCancelable, or the internal chaining functions.
+type deferredResolution func() (interface{}, error)
+
+// If the idle handler returns this many consecutive times without making progress, the request
+// fails with an error instead of waiting forever.
+const idleHandlerStallLimit = 100
+
 type apiRequest struct {
 	asyncResolutions        chan asyncResolution
 	chainedAsyncResolutions map[graphql.ResolvePromise]struct{}
 	batches                 map[*int]*batch
+
+	// Maps promises returned by deferred resolutions to the promises their results should be sent
+	// to instead.
+	forwardedAsyncResolutions map[graphql.ResolvePromise]graphql.ResolvePromise
+
+	// The number of goroutines started by Go whose results haven't been received yet. This is
+	// accessed atomically since Go may be invoked from within chained resolvers.
+	pendingAsyncResolutions int64
+
+	// Holds the cancelation state of promises returned by Go whose results haven't been received
+	// yet. Entries are removed once the result is received or the promise is canceled.
+	cancelationsMutex sync.Mutex
+	cancelations      map[graphql.ResolvePromise]*promiseCancelation
+
+	// Tracks the goroutines started by Go and GoCancelable. If Config.MaxConcurrentGoroutines is
+	// reached, work is queued until a goroutine becomes available.
+	workersMutex   sync.Mutex
+	runningWorkers int
+	queuedWork     []func(queued bool)
+}
+
+type promiseCancelation struct {
+	cancel    context.CancelFunc
+	abandoned chan struct{}
+
+	// Promises that the promise's goroutine is waiting on. These are canceled along with it.
+	dependencies []graphql.ResolvePromise
+}
+
+// Invoked by the executor when a promise's result is no longer needed.
+func (r *apiRequest) CancelPromise(p graphql.ResolvePromise) {
+	r.cancelationsMutex.Lock()
+	defer r.cancelationsMutex.Unlock()
+	r.cancelPromise(p)
+}
+
+func (r *apiRequest) cancelPromise(p graphql.ResolvePromise) {
+	c, ok := r.cancelations[p]
+	if !ok {
+		return
+	}
+	delete(r.cancelations, p)
+	// The goroutine will no longer send its result, so it's no longer pending.
+	atomic.AddInt64(&r.pendingAsyncResolutions, -1)
+	close(c.abandoned)
+	c.cancel()
+	for _, dependency := range c.dependencies {
+		r.cancelPromise(dependency)
+	}
+}
+
+// Removes the cancelation state for a promise whose result has been received. If the promise was
+// already canceled, false is returned and the result should be discarded.
+func (r *apiRequest) completePromise(p graphql.ResolvePromise) bool {
+	r.cancelationsMutex.Lock()
+	c, ok := r.cancelations[p]
+	delete(r.cancelations, p)
+	r.cancelationsMutex.Unlock()
+	if ok {
+		atomic.AddInt64(&r.pendingAsyncResolutions, -1)
+		c.cancel()
+	}
+	return ok
 }
 
 func (r *apiRequest) IdleHandler() {
@@ -93,6 +304,11 @@ func (r *apiRequest) IdleHandler() {
 			for _, b := range r.batches {
 				wg.Add(1)
 				b := b
+				for _, item := range b.items {
+					updateExplainedField(item.Context, func(field *ExplainedField) {
+						field.BatchSize = len(b.items)
+					})
+				}
 				go func() {
 					defer wg.Done()
 					for i, result := range b.resolver(b.items) {
@@ -102,12 +318,22 @@ func (r *apiRequest) IdleHandler() {
 			}
 			wg.Wait()
 			r.batches = map[*int]*batch{}
+		} else if atomic.LoadInt64(&r.pendingAsyncResolutions) == 0 {
+			// Nothing we know about can make progress. Return and let the executor diagnose it
+			// rather than blocking forever.
+			return
 		} else {
 			// Block until we've fully resolved something.
 			resolution := <-r.asyncResolutions
-			resolution.Dest <- resolution.Result
-			if _, ok := r.chainedAsyncResolutions[resolution.Dest]; ok {
-				delete(r.chainedAsyncResolutions, resolution.Dest)
+			if !r.completePromise(resolution.Dest) {
+				continue
+			}
+			dest := resolution.Dest
+			if !r.deliver(resolution) {
+				continue
+			}
+			if _, ok := r.chainedAsyncResolutions[dest]; ok {
+				delete(r.chainedAsyncResolutions, dest)
 				continue
 			}
 		}
@@ -116,7 +342,9 @@ func (r *apiRequest) IdleHandler() {
 		for {
 			select {
 			case resolution := <-r.asyncResolutions:
-				resolution.Dest <- resolution.Result
+				if r.completePromise(resolution.Dest) {
+					r.deliver(resolution)
+				}
 			default:
 				return
 			}
@@ -124,6 +352,32 @@ func (r *apiRequest) IdleHandler() {
 	}
 }
 
+// Sends a resolution's result to its destination, invoking it first if it's a deferredResolution.
+// If the deferred resolution returns a promise, nothing is sent yet and false is returned. Instead,
+// the promise's result is sent to the destination once it's available.
+func (r *apiRequest) deliver(resolution asyncResolution) bool {
+	if dest, ok := r.forwardedAsyncResolutions[resolution.Dest]; ok {
+		delete(r.forwardedAsyncResolutions, resolution.Dest)
+		resolution.Dest = dest
+	}
+	if f, ok := resolution.Result.Value.(deferredResolution); ok && isNil(resolution.Result.Error) {
+		v, err := f()
+		if p, ok := v.(graphql.ResolvePromise); ok && isNil(err) {
+			if r.forwardedAsyncResolutions == nil {
+				r.forwardedAsyncResolutions = map[graphql.ResolvePromise]graphql.ResolvePromise{}
+			}
+			r.forwardedAsyncResolutions[p] = resolution.Dest
+			return false
+		}
+		resolution.Result = graphql.ResolveResult{
+			Value: v,
+			Error: err,
+		}
+	}
+	resolution.Dest <- resolution.Result
+	return true
+}
+
 type apiRequestContextKeyType int
 
 var apiRequestContextKey apiRequestContextKeyType
@@ -133,17 +387,31 @@ func ctxAPIRequest(ctx context.Context) *apiRequest {
 }
 
 func chain(ctx context.Context, p graphql.ResolvePromise, f func(interface{}) (interface{}, error)) graphql.ResolvePromise {
+	return chainResult(ctx, p, func(result graphql.ResolveResult, ctxErr error) (interface{}, error) {
+		if ctxErr != nil {
+			return nil, ctxErr
+		} else if !isNil(result.Error) {
+			return nil, result.Error
+		}
+		return f(result.Value)
+	})
+}
+
+// chainResult is like chain, but f is always invoked, even if p fails or the context is canceled
+// before p completes. In the latter case, ctxErr is the context's error.
+func chainResult(ctx context.Context, p graphql.ResolvePromise, f func(result graphql.ResolveResult, ctxErr error) (interface{}, error)) graphql.ResolvePromise {
 	apiRequest := ctxAPIRequest(ctx)
 	if apiRequest.chainedAsyncResolutions == nil {
 		apiRequest.chainedAsyncResolutions = map[graphql.ResolvePromise]struct{}{}
 	}
 	apiRequest.chainedAsyncResolutions[p] = struct{}{}
-	return Go(ctx, func() (interface{}, error) {
-		result := <-p
-		if !isNil(result.Error) {
-			return nil, result.Error
+	return goPromise(ctx, []graphql.ResolvePromise{p}, func(ctx context.Context) (interface{}, error) {
+		select {
+		case result := <-p:
+			return f(result, nil)
+		case <-ctx.Done():
+			return f(graphql.ResolveResult{}, ctx.Err())
 		}
-		return f(result.Value)
 	})
 }
 
@@ -155,39 +423,140 @@ func join(ctx context.Context, p []graphql.ResolvePromise, f func([]interface{})
 	for _, p := range p {
 		apiRequest.chainedAsyncResolutions[p] = struct{}{}
 	}
-	return Go(ctx, func() (interface{}, error) {
+	return goPromise(ctx, p, func(ctx context.Context) (interface{}, error) {
 		values := make([]interface{}, len(p))
 		for i, p := range p {
-			result := <-p
-			if !isNil(result.Error) {
-				return nil, result.Error
+			select {
+			case result := <-p:
+				if !isNil(result.Error) {
+					return nil, result.Error
+				}
+				values[i] = result.Value
+			case <-ctx.Done():
+				return nil, ctx.Err()
 			}
-			values[i] = result.Value
 		}
 		return f(values)
 	})
 }
 
 // Go completes resolution asynchronously and concurrently with any other asynchronous resolutions.
+//
+// The context given to resolvers is canceled once the request's execution is complete, so f can
+// use it to avoid doing unnecessary work after the response has been sent. To also stop work when
+// only the promise's result is no longer needed, use GoCancelable.
+//
+// If Config.MaxConcurrentGoroutines is set, f may be queued until fewer goroutines are running
+// for the request. If the context is canceled while f is queued, f isn't invoked and the context's
+// error is used as the result. If f panics, the panic is logged and resolution fails with an
+// error.
 func Go(ctx context.Context, f func() (interface{}, error)) graphql.ResolvePromise {
+	return goPromise(ctx, nil, func(context.Context) (interface{}, error) {
+		return f()
+	})
+}
+
+// GoCancelable is like Go, but f is given a context that is canceled if the promise's result is no
+// longer needed. For example, if a sibling non-null field fails and the parent is nulled, the
+// contexts of any of the parent's pending descendants are canceled.
+func GoCancelable(ctx context.Context, f func(ctx context.Context) (interface{}, error)) graphql.ResolvePromise {
+	return goPromise(ctx, nil, f)
+}
+
+func goPromise(ctx context.Context, dependencies []graphql.ResolvePromise, f func(ctx context.Context) (interface{}, error)) graphql.ResolvePromise {
 	apiRequest := ctxAPIRequest(ctx)
 	if apiRequest.asyncResolutions == nil {
 		apiRequest.asyncResolutions = make(chan asyncResolution)
 	}
 	ch := make(graphql.ResolvePromise, 1)
-	go func() {
-		v, err := f()
-		apiRequest.asyncResolutions <- asyncResolution{
+	ctx, cancel := context.WithCancel(ctx)
+	cancelation := &promiseCancelation{
+		cancel:       cancel,
+		abandoned:    make(chan struct{}),
+		dependencies: dependencies,
+	}
+	apiRequest.cancelationsMutex.Lock()
+	if apiRequest.cancelations == nil {
+		apiRequest.cancelations = map[graphql.ResolvePromise]*promiseCancelation{}
+	}
+	apiRequest.cancelations[ch] = cancelation
+	apiRequest.cancelationsMutex.Unlock()
+	atomic.AddInt64(&apiRequest.pendingAsyncResolutions, 1)
+	work := func(queued bool) {
+		var v interface{}
+		var err error
+		if queued && ctx.Err() != nil {
+			// The promise was canceled while waiting for a worker, so there's no need to start.
+			err = ctx.Err()
+		} else {
+			v, err = invokeRecoveringPanics(ctx, f)
+		}
+		select {
+		case apiRequest.asyncResolutions <- asyncResolution{
 			Result: graphql.ResolveResult{
 				Value: v,
 				Error: err,
 			},
 			Dest: ch,
+		}:
+		case <-cancelation.abandoned:
 		}
-	}()
+	}
+	if dependencies == nil {
+		apiRequest.startWorker(ctxAPI(ctx).config.MaxConcurrentGoroutines, work)
+	} else {
+		// Chained promises spend their time waiting on their dependencies, so they aren't limited.
+		// Limiting them could also deadlock if they occupied every worker.
+		go work(false)
+	}
 	return ch
 }
 
+// Runs work in a new goroutine, or queues it if limit goroutines are already running. When a
+// goroutine finishes its work, it picks up the next queued work.
+func (r *apiRequest) startWorker(limit int, work func(queued bool)) {
+	if limit <= 0 {
+		go work(false)
+		return
+	}
+	r.workersMutex.Lock()
+	if r.runningWorkers >= limit {
+		r.queuedWork = append(r.queuedWork, work)
+		r.workersMutex.Unlock()
+		return
+	}
+	r.runningWorkers++
+	r.workersMutex.Unlock()
+	go func() {
+		queued := false
+		for {
+			work(queued)
+			queued = true
+			r.workersMutex.Lock()
+			if len(r.queuedWork) == 0 {
+				r.runningWorkers--
+				r.workersMutex.Unlock()
+				return
+			}
+			work = r.queuedWork[0]
+			r.queuedWork[0] = nil
+			r.queuedWork = r.queuedWork[1:]
+			r.workersMutex.Unlock()
+		}
+	}()
+}
+
+// Invokes f, converting panics into errors so that a misbehaving resolver can't crash the process.
+func invokeRecoveringPanics(ctx context.Context, f func(ctx context.Context) (interface{}, error)) (v interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ctxAPI(ctx).logger.WithField("stack", string(debug.Stack())).Errorf("panic in asynchronous resolver: %v", r)
+			v, err = nil, errors.Errorf("panic in asynchronous resolver: %v", r)
+		}
+	}()
+	return f(ctx)
+}
+
 type batch struct {
 	resolver func([]graphql.FieldContext) []graphql.ResolveResult
 	items    []graphql.FieldContext
@@ -227,43 +596,232 @@ func (api *API) ServeGraphQL(w http.ResponseWriter, r *http.Request) {
 	ctx = context.WithValue(ctx, apiRequestContextKey, apiRequest)
 	r = r.WithContext(ctx)
 
-	req, code, err := graphql.NewRequestFromHTTP(r)
+	if code, message := api.checkGraphQLOverHTTPRequest(r); code != 0 {
+		http.Error(w, message, code)
+		return
+	} else if code, message := api.checkCacheableGETRequest(w, r); code != 0 {
+		http.Error(w, message, code)
+		return
+	}
+
+	codec := api.jsonCodec()
+	req, code, err := graphql.NewRequestFromHTTPWithJSONCodec(r, codec)
 	if err != nil {
 		http.Error(w, err.Error(), code)
 		return
 	}
+	api.serveGraphQLRequest(w, r, codec, req, apiRequest, nil)
+}
+
+// Executes a request that was received via HTTP and writes the response. If registered is non-nil,
+// the request invokes the registered operation.
+func (api *API) serveGraphQLRequest(w http.ResponseWriter, r *http.Request, codec graphql.JSONCodec, req *graphql.Request, apiRequest *apiRequest, registered *registeredOperation) {
+	ctx := r.Context()
+	applyClientTimeoutHeader(req, r)
+
+	var resp *graphql.Response
+	if ctx, resp = api.handleExtensions(ctx, req.Extensions); resp != nil {
+		api.writeGraphQLResponse(w, r, codec, resp)
+		return
+	}
+	api.prepareHTTPRequest(ctx, req, apiRequest)
+
+	// If an operation can't be executed via the request's method, the response is written with
+	// 405 Method Not Allowed.
+	methodNotAllowed := false
+
+	// A response can only be cached if every operation is a query that succeeds.
+	cacheable := true
+
+	executeOperation := func(req *graphql.Request) *graphql.Response {
+		resp, notAllowed := api.executeHTTPOperation(r, req, registered)
+		if notAllowed {
+			methodNotAllowed = true
+		}
+		if resp == nil || len(resp.Errors) > 0 || req.Document == nil || !isQuery(req.Document, req.OperationName) {
+			cacheable = false
+		}
+		return resp
+	}
+
+	if registered != nil {
+		api.writeGraphQLResponse(w, r, codec, executeOperation(req))
+		return
+	}
+
+	// If every operation is executed, the results are written instead of a single response.
+	var results *graphql.OrderedMap
+	execute := func(req *graphql.Request) *graphql.Response {
+		if api.config.ExecuteAllOperations && req.OperationName == "" {
+			if names := operationNames(req.Query); len(names) > 1 {
+				results = graphql.NewOrderedMap()
+				for _, name := range names {
+					operationReq := *req
+					operationReq.OperationName = name
+					results.Append(name, executeOperation(&operationReq))
+				}
+				return nil
+			}
+		}
+		return executeOperation(req)
+	}
+	execute = PersistedQueryExtension(api.persistedQueryStorage(), execute)
+
+	var ret interface{} = execute(req)
+	if results != nil {
+		ret = results
+	}
+	api.setCacheControl(w, r, cacheable, ret)
+	if methodNotAllowed {
+		w.Header().Set("Allow", http.MethodPost)
+		api.writeGraphQLResponseWithStatus(w, r, codec, ret, http.StatusMethodNotAllowed)
+	} else {
+		api.writeGraphQLResponse(w, r, codec, ret)
+	}
+}
+
+// Populates the fields of a request received via HTTP that are determined by the API's
+// configuration rather than the request itself.
+func (api *API) prepareHTTPRequest(ctx context.Context, req *graphql.Request, apiRequest *apiRequest) {
+	req.Context = ctx
 	req.Schema = api.schema
 	req.IdleHandler = apiRequest.IdleHandler
-	if api.config.Features != nil {
-		req.Features = api.config.Features(ctx)
+	req.IdleHandlerStallLimit = idleHandlerStallLimit
+	req.CancelPromise = apiRequest.CancelPromise
+	req.ReportNulledFields = api.config.ReportNulledFields
+	req.ReportDeprecations = api.config.ReportDeprecations
+	req.ReportSchemaWarnings = api.config.ReportSchemaWarnings
+	req.MaxErrors = api.config.MaxErrors
+	req.MaxErrorMessageLength = api.config.MaxErrorMessageLength
+	req.Features = api.requestFeatures(ctx)
+}
+
+// Validates and executes a single operation of a request received via HTTP. If registered is
+// non-nil, the request invokes the registered operation. If the operation can't be executed via
+// the request's method, methodNotAllowed is true. The request's document is only set if the
+// operation is executed.
+func (api *API) executeHTTPOperation(r *http.Request, req *graphql.Request, registered *registeredOperation) (resp *graphql.Response, methodNotAllowed bool) {
+	var info RequestInfo
+	var doc *ast.Document
+	var errs []*graphql.Error
+	if registered != nil {
+		doc, errs = api.parseAndValidateRegisteredOperation(req, &info)
+	} else {
+		doc, errs = api.parseAndValidate(req, &info)
+	}
+	if len(errs) == 0 && api.isGraphQLOverHTTPMethodNotAllowed(r, doc, req.OperationName) {
+		methodNotAllowed = true
+		errs = []*graphql.Error{{
+			Message: "Only queries can be executed via GET requests.",
+		}}
+	}
+	if len(errs) == 0 {
+		errs = api.applyRequestPolicies(r, req, doc)
+	}
+	if len(errs) == 0 && registered != nil {
+		errs = registered.authorize(r, doc)
 	}
+	if len(errs) > 0 {
+		return &graphql.Response{
+			Errors: errs,
+		}, methodNotAllowed
+	}
+	req.Document = doc
+	return api.execute(req, &info), false
+}
 
-	execute := func(req *graphql.Request) *graphql.Response {
-		var info RequestInfo
-		if doc, errs := graphql.ParseAndValidate(req.Query, req.Schema, req.Features, req.ValidateCost(-1, &info.Cost, api.config.DefaultFieldCost)); len(errs) > 0 {
-			return &graphql.Response{
-				Errors: errs,
+// Returns the names of the operations in the query in the order they're defined. If the query
+// can't be parsed or any of its operations are anonymous or have duplicate names, nil is returned
+// so that the error can be reported by validation.
+func operationNames(query string) []string {
+	doc, errs := parser.ParseDocument([]byte(query))
+	if len(errs) > 0 {
+		return nil
+	}
+	var ret []string
+	seen := map[string]struct{}{}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.OperationDefinition); ok {
+			if def.Name == nil {
+				return nil
+			} else if _, ok := seen[def.Name.Name]; ok {
+				return nil
 			}
-		} else {
-			req.Document = doc
-			return api.execute(req, &info)
+			seen[def.Name.Name] = struct{}{}
+			ret = append(ret, def.Name.Name)
 		}
 	}
-	if storage := api.config.PersistedQueryStorage; storage != nil {
-		execute = PersistedQueryExtension(storage, execute)
+	return ret
+}
+
+// Returns the function used to marshal responses for the given Accept header values along with the
+// content type of the result. If the client doesn't prefer a supported binary encoding or
+// Config.BinaryResponseEncodings is false, the function is nil and JSON should be used.
+func (api *API) binaryResponseEncoding(accept []string) (func(v interface{}) ([]byte, error), string) {
+	if !api.config.BinaryResponseEncodings {
+		return nil, ""
+	}
+	switch contentType := graphql.NegotiateResponseContentType(accept); contentType {
+	case graphql.MsgpackContentType:
+		return graphql.MarshalMsgpack, contentType
+	case graphql.CBORContentType:
+		return graphql.MarshalCBOR, contentType
+	}
+	return nil, ""
+}
+
+func (api *API) writeGraphQLResponse(w http.ResponseWriter, r *http.Request, codec graphql.JSONCodec, resp interface{}) {
+	api.writeGraphQLResponseWithStatus(w, r, codec, resp, 0)
+}
+
+// Writes the response with the given status code. If the status is zero, it's determined by
+// Config.HTTPStatus or Config.StrictGraphQLOverHTTP.
+func (api *API) writeGraphQLResponseWithStatus(w http.ResponseWriter, r *http.Request, codec graphql.JSONCodec, resp interface{}, status int) {
+	marshal, contentType := codec.Marshal, graphql.NegotiateJSONContentType(r.Header.Values("Accept"))
+	if binaryMarshal, binaryContentType := api.binaryResponseEncoding(r.Header.Values("Accept")); binaryMarshal != nil {
+		marshal, contentType = binaryMarshal, binaryContentType
 	}
 
-	body, err := jsoniter.Marshal(execute(req))
+	body, err := marshal(resp)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	if status == 0 {
+		status = api.graphQLResponseHTTPStatus(r, contentType, resp)
+	}
+	if api.config.StrictGraphQLOverHTTP && (contentType == graphql.JSONContentType || contentType == graphql.GraphQLResponseContentType) {
+		contentType += "; charset=utf-8"
+	}
+
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if status != 0 {
+		w.WriteHeader(status)
+	}
 	w.Write(body)
 }
 
+// The default codec preserves the historical behavior of decoding requests with encoding/json and
+// encoding responses with jsoniter.
+type defaultJSONCodec struct{}
+
+func (defaultJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return jsoniter.Marshal(v)
+}
+
+func (defaultJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return graphql.StandardJSONCodec.Unmarshal(data, v)
+}
+
+func (api *API) jsonCodec() graphql.JSONCodec {
+	if api.config.JSONCodec != nil {
+		return api.config.JSONCodec
+	}
+	return defaultJSONCodec{}
+}
+
 func isNil(v interface{}) bool {
 	if v == nil {
 		return true
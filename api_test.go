@@ -3,12 +3,16 @@ package apifu
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -78,6 +82,164 @@ func TestGo(t *testing.T) {
 	assert.JSONEq(t, `{"data":{"s":true,"r":true}}`, string(body))
 }
 
+func TestGoCancelable(t *testing.T) {
+	canceled := make(chan struct{})
+	var requestCtx context.Context
+
+	objectType := &graphql.ObjectType{
+		Name: "Object",
+		Fields: map[string]*graphql.FieldDefinition{
+			"slow": {
+				Type: graphql.BooleanType,
+				Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+					requestCtx = ctx.Context
+					return GoCancelable(ctx.Context, func(ctx context.Context) (interface{}, error) {
+						<-ctx.Done()
+						close(canceled)
+						return nil, ctx.Err()
+					}), nil
+				},
+			},
+			"error": {
+				Type: graphql.NewNonNullType(graphql.BooleanType),
+				Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+					return Go(ctx.Context, func() (interface{}, error) {
+						return nil, fmt.Errorf("error")
+					}), nil
+				},
+			},
+		},
+	}
+
+	var testCfg Config
+	testCfg.AddQueryField("object", &graphql.FieldDefinition{
+		Type: objectType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return struct{}{}, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	resp := executeGraphQL(t, api, `{object{slow error}}`)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"object":null},"errors":[{"message":"error","locations":[{"line":1,"column":14}],"path":["object","error"]}]}`, string(body))
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the goroutine's context should be canceled")
+	}
+
+	// The request context should also be canceled now that the request is complete.
+	assert.Error(t, requestCtx.Err())
+}
+
+func TestGo_Panic(t *testing.T) {
+	var testCfg Config
+	testCfg.Logger = logrus.New()
+	testCfg.Logger.(*logrus.Logger).SetOutput(ioutil.Discard)
+	testCfg.AddQueryField("panic", &graphql.FieldDefinition{
+		Type: graphql.BooleanType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return Go(ctx.Context, func() (interface{}, error) {
+				panic("oops")
+			}), nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	resp := executeGraphQL(t, api, `{panic}`)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"panic":null},"errors":[{"message":"panic in asynchronous resolver: oops","locations":[{"line":1,"column":2}],"path":["panic"]}]}`, string(body))
+}
+
+func TestGo_MaxConcurrentGoroutines(t *testing.T) {
+	var running, maxRunning int64
+
+	testCfg := Config{
+		MaxConcurrentGoroutines: 3,
+	}
+	objectType := &graphql.ObjectType{
+		Name: "Object",
+		Fields: map[string]*graphql.FieldDefinition{
+			"value": {
+				Type: graphql.IntType,
+				Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+					return Go(ctx.Context, func() (interface{}, error) {
+						n := atomic.AddInt64(&running, 1)
+						for {
+							prev := atomic.LoadInt64(&maxRunning)
+							if n <= prev || atomic.CompareAndSwapInt64(&maxRunning, prev, n) {
+								break
+							}
+						}
+						time.Sleep(time.Millisecond)
+						atomic.AddInt64(&running, -1)
+						return ctx.Object, nil
+					}), nil
+				},
+			},
+		},
+	}
+	testCfg.AddQueryField("items", &graphql.FieldDefinition{
+		Type: graphql.NewListType(objectType),
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			ret := make([]interface{}, 10)
+			for i := range ret {
+				ret[i] = i
+			}
+			return ret, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	resp := executeGraphQL(t, api, `{items{value}}`)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"items":[{"value":0},{"value":1},{"value":2},{"value":3},{"value":4},{"value":5},{"value":6},{"value":7},{"value":8},{"value":9}]}}`, string(body))
+	assert.LessOrEqual(t, atomic.LoadInt64(&maxRunning), int64(3))
+}
+
+func TestAsyncResolverDeadlock(t *testing.T) {
+	var testCfg Config
+
+	testCfg.AddQueryField("neverResolved", &graphql.FieldDefinition{
+		Type: graphql.BooleanType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return make(graphql.ResolvePromise, 1), nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	resp := executeGraphQL(t, api, `{
+		n: neverResolved
+	}`)
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var result struct {
+		Errors []struct {
+			Message string
+		}
+	}
+	require.NoError(t, json.Unmarshal(body, &result))
+	require.Len(t, result.Errors, 1)
+	assert.Contains(t, result.Errors[0].Message, "Async resolver deadlock")
+	assert.Contains(t, result.Errors[0].Message, "Pending fields: n.")
+}
+
 func TestBatch(t *testing.T) {
 	var testCfg Config
 
@@ -289,3 +451,346 @@ func TestFeatures(t *testing.T) {
 		assert.JSONEq(t, `{"data":{"foo":true,"bar":true}}`, string(body))
 	})
 }
+
+func TestEnforceSunsets(t *testing.T) {
+	var testCfg Config
+	testCfg.EnforceSunsets = true
+	testCfg.AddQueryField("retired", &graphql.FieldDefinition{
+		Type:              graphql.IntType,
+		DeprecationReason: "Use active instead.",
+		SunsetTime:        time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+	testCfg.AddQueryField("active", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	t.Run("Retired", func(t *testing.T) {
+		resp := executeGraphQL(t, api, `{retired}`)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"errors":[{"message":"Validation error: field retired was retired on 2020-01-01","locations":[{"line":1,"column":2}],"extensions":{"code":"FIELD_SUNSET","retryable":false,"sunsetTime":"2020-01-01T00:00:00Z"}}]}`, string(body))
+	})
+
+	t.Run("Introspection", func(t *testing.T) {
+		resp := executeGraphQL(t, api, `{__type(name: "Query") {fields(includeDeprecated: true) {name sunsetTime}}}`)
+		var result struct {
+			Data struct {
+				Type struct {
+					Fields []struct {
+						Name       string
+						SunsetTime *string
+					}
+				} `json:"__type"`
+			}
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		sunsetTimes := map[string]*string{}
+		for _, field := range result.Data.Type.Fields {
+			sunsetTimes[field.Name] = field.SunsetTime
+		}
+		assert.Nil(t, sunsetTimes["active"])
+		require.NotNil(t, sunsetTimes["retired"])
+		assert.Equal(t, "2020-01-01T00:00:00Z", *sunsetTimes["retired"])
+	})
+
+	t.Run("Deprecations", func(t *testing.T) {
+		// Until the sunset time is enforced, it's reported to clients that use the field.
+		testCfg := Config{
+			ReportDeprecations: true,
+		}
+		testCfg.AddQueryField("retiring", &graphql.FieldDefinition{
+			Type:              graphql.IntType,
+			DeprecationReason: "Use active instead.",
+			SunsetTime:        time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+				return 1, nil
+			},
+		})
+		api, err := NewAPI(&testCfg)
+		require.NoError(t, err)
+
+		resp := executeGraphQL(t, api, `{retiring}`)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data":{"retiring":1},"extensions":{"deprecations":[{"coordinate":"Query.retiring","path":["retiring"],"reason":"Use active instead.","sunset":"2020-01-01T00:00:00Z","locations":[{"line":1,"column":2}]}]}}`, string(body))
+	})
+}
+
+type countingJSONCodec struct {
+	marshals   int
+	unmarshals int
+}
+
+func (c *countingJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	c.marshals++
+	return graphql.StandardJSONCodec.Marshal(v)
+}
+
+func (c *countingJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	c.unmarshals++
+	return graphql.StandardJSONCodec.Unmarshal(data, v)
+}
+
+func TestJSONCodec(t *testing.T) {
+	codec := &countingJSONCodec{}
+
+	var cfg Config
+	cfg.JSONCodec = codec
+	cfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "bar", nil
+		},
+	})
+	api, err := NewAPI(&cfg)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"query":"{foo}"}`))
+	r.Header.Set("Content-Type", "application/json")
+	api.ServeGraphQL(w, r)
+
+	body, err := ioutil.ReadAll(w.Result().Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"foo":"bar"}}`, string(body))
+	assert.Equal(t, 1, codec.marshals)
+	assert.Equal(t, 1, codec.unmarshals)
+}
+
+func TestCostDimensions(t *testing.T) {
+	testCfg := Config{
+		DefaultFieldCost: graphql.FieldCost{
+			Resolver: 1,
+		},
+		MaxCostDimensions: map[string]int{
+			"external": 1,
+		},
+		ReportCost: true,
+	}
+	testCfg.AddQueryField("search", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Cost: func(graphql.FieldCostContext) graphql.FieldCost {
+			return graphql.FieldCost{
+				Resolver: 1,
+				Dimensions: map[string]int{
+					"external": 1,
+				},
+			}
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "result", nil
+		},
+	})
+	testCfg.AddQueryField("user", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Cost: func(graphql.FieldCostContext) graphql.FieldCost {
+			return graphql.FieldCost{
+				Resolver: 1,
+				Dimensions: map[string]int{
+					"dbReads": 2,
+				},
+			}
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "alice", nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Query    string
+		Expected string
+	}{
+		"OK": {
+			Query:    `{search user}`,
+			Expected: `{"data":{"search":"result","user":"alice"},"extensions":{"cost":{"estimated":2,"dimensions":{"dbReads":2,"external":1}}}}`,
+		},
+		"Exceeded": {
+			Query:    `{a: search b: search}`,
+			Expected: `{"errors":[{"message":"Validation error: operation external cost of 2 exceeds allowed cost of 1","locations":[{"line":1,"column":1}]}]}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := executeGraphQL(t, api, tc.Query)
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.Expected, string(body))
+		})
+	}
+}
+
+func TestServeGraphQL_BinaryResponseEncodings(t *testing.T) {
+	var testCfg Config
+	testCfg.BinaryResponseEncodings = true
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Accept              string
+		ExpectedContentType string
+		ExpectedBody        []byte
+	}{
+		"JSON": {
+			Accept:              "application/json",
+			ExpectedContentType: "application/json",
+			ExpectedBody:        []byte(`{"data":{"foo":1}}`),
+		},
+		"Msgpack": {
+			Accept:              "application/msgpack",
+			ExpectedContentType: "application/msgpack",
+			ExpectedBody:        []byte{0x81, 0xa4, 'd', 'a', 't', 'a', 0x81, 0xa3, 'f', 'o', 'o', 0x01},
+		},
+		"CBOR": {
+			Accept:              "application/cbor",
+			ExpectedContentType: "application/cbor",
+			ExpectedBody:        []byte{0xbf, 0x64, 'd', 'a', 't', 'a', 0xbf, 0x63, 'f', 'o', 'o', 0x01, 0xff, 0xff},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest("POST", "", strings.NewReader(`{foo}`))
+			require.NoError(t, err)
+			r.Header.Set("Content-Type", "application/graphql")
+			r.Header.Set("Accept", tc.Accept)
+			api.ServeGraphQL(w, r)
+			resp := w.Result()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, tc.ExpectedContentType, resp.Header.Get("Content-Type"))
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.ExpectedBody, body)
+		})
+	}
+}
+
+func TestServeGraphQL_HTTPStatus(t *testing.T) {
+	testCfg := Config{
+		HTTPStatus: func(r *http.Request, resp *graphql.Response) int {
+			if graphql.NegotiateJSONContentType(r.Header.Values("Accept")) != graphql.GraphQLResponseContentType {
+				return http.StatusOK
+			}
+			return graphql.ResponseHTTPStatus(resp)
+		},
+	}
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+	testCfg.AddQueryField("unauthenticated", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return nil, graphql.NewCodedError(graphql.ErrorCodeUnauthenticated, "not authenticated")
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Query               string
+		Accept              string
+		ExpectedStatus      int
+		ExpectedContentType string
+	}{
+		"Success": {
+			Query:               `{foo}`,
+			Accept:              "application/graphql-response+json",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/graphql-response+json",
+		},
+		"PartialData": {
+			Query:               `{foo unauthenticated}`,
+			Accept:              "application/graphql-response+json",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/graphql-response+json",
+		},
+		"ValidationError": {
+			Query:               `{bar}`,
+			Accept:              "application/graphql-response+json",
+			ExpectedStatus:      http.StatusBadRequest,
+			ExpectedContentType: "application/graphql-response+json",
+		},
+		"LegacyClient": {
+			Query:               `{bar}`,
+			Accept:              "application/json",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/json",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest("POST", "", strings.NewReader(tc.Query))
+			require.NoError(t, err)
+			r.Header.Set("Content-Type", "application/graphql")
+			r.Header.Set("Accept", tc.Accept)
+			api.ServeGraphQL(w, r)
+			resp := w.Result()
+			assert.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+			assert.Equal(t, tc.ExpectedContentType, resp.Header.Get("Content-Type"))
+		})
+	}
+}
+
+func TestExecuteAllOperations(t *testing.T) {
+	testCfg := Config{
+		ExecuteAllOperations: true,
+	}
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Query    string
+		Expected string
+	}{
+		"Multiple": {
+			Query:    `query B {foo} query A {bar: foo}`,
+			Expected: `{"B":{"data":{"foo":1}},"A":{"data":{"bar":1}}}`,
+		},
+		"Invalid": {
+			Query:    `query A {foo} query B {baz}`,
+			Expected: `{"A":{"errors":[{"message":"Validation error: field baz does not exist on Query","locations":[{"line":1,"column":24}]}]},"B":{"errors":[{"message":"Validation error: field baz does not exist on Query","locations":[{"line":1,"column":24}]}]}}`,
+		},
+		"Single": {
+			Query:    `query A {foo}`,
+			Expected: `{"data":{"foo":1}}`,
+		},
+		"Anonymous": {
+			Query:    `query A {foo} {foo}`,
+			Expected: `{"errors":[{"message":"Validation error: only one operation is allowed when an anonymous operation is present","locations":[{"line":1,"column":15}]}]}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := executeGraphQL(t, api, tc.Query)
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, string(body))
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package apifu
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// CacheStore is used by CachedResolver to store resolved values. Implementations must be safe for
+// concurrent use. For example, an implementation might use an in-memory LRU cache (see
+// MemoryCacheStore) or Redis.
+type CacheStore interface {
+	// Get returns the value for the given key. If the value doesn't exist or has expired, false
+	// should be returned.
+	Get(ctx context.Context, key string) (value interface{}, ok bool, err error)
+
+	// Set stores a value for the given key. The value should expire after the given duration.
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+}
+
+// CachedResolver wraps a resolver so that its results are cached in the given store. This is
+// useful for fields that are expensive to resolve, but rarely change.
+//
+// The key function determines the cache key for each invocation. Keys are shared by all fields
+// using the store, so they should incorporate anything that affects the result, such as the object
+// and arguments. If the key function returns an empty string, the result isn't cached.
+//
+// Only successful results are cached. The resolver may return a graphql.ResolvePromise, in which
+// case its result is cached once it's available.
+//
+// Concurrent invocations with the same key are coalesced: while a value is being resolved, other
+// invocations wait for it instead of invoking the resolver again. If the resolution fails, the
+// waiting invocations receive the same error. However, if it fails because the invoking request's
+// context was canceled, the waiting invocations try again using their own contexts. If the resolver
+// panics, the waiting invocations receive an error.
+//
+// Errors returned by the store are logged, and the resolver is invoked as if the value wasn't
+// cached.
+func CachedResolver(resolve func(graphql.FieldContext) (interface{}, error), key func(graphql.FieldContext) string, ttl time.Duration, store CacheStore) func(graphql.FieldContext) (interface{}, error) {
+	var inFlightMutex sync.Mutex
+	inFlight := map[string]*cachedResolution{}
+
+	var resolver func(ctx graphql.FieldContext) (interface{}, error)
+	resolver = func(ctx graphql.FieldContext) (interface{}, error) {
+		k := key(ctx)
+		if k == "" {
+			return resolve(ctx)
+		}
+
+		if v, ok, err := store.Get(ctx.Context, k); err != nil {
+			ctxAPI(ctx.Context).logger.Warn(errors.Wrap(err, "error getting cached value"))
+		} else if ok {
+			explainCacheHit(ctx.Context, true)
+			return v, nil
+		}
+		explainCacheHit(ctx.Context, false)
+
+		inFlightMutex.Lock()
+		if r, ok := inFlight[k]; ok {
+			inFlightMutex.Unlock()
+			return Go(ctx.Context, func() (interface{}, error) {
+				select {
+				case <-r.done:
+					if r.canceled {
+						// The other invocation's context was canceled, so its error doesn't apply
+						// to us. Try again on the executor's goroutine.
+						return deferredResolution(func() (interface{}, error) {
+							return resolver(ctx)
+						}), nil
+					}
+					return r.value, r.err
+				case <-ctx.Context.Done():
+					return nil, ctx.Context.Err()
+				}
+			}), nil
+		}
+		r := &cachedResolution{
+			done: make(chan struct{}),
+		}
+		inFlight[k] = r
+		inFlightMutex.Unlock()
+
+		finish := func(value interface{}, err error, canceled bool) {
+			r.once.Do(func() {
+				if isNil(err) {
+					if err := store.Set(ctx.Context, k, value, ttl); err != nil {
+						ctxAPI(ctx.Context).logger.Warn(errors.Wrap(err, "error setting cached value"))
+					}
+				}
+				inFlightMutex.Lock()
+				delete(inFlight, k)
+				inFlightMutex.Unlock()
+				r.value, r.err, r.canceled = value, err, canceled
+				close(r.done)
+			})
+		}
+
+		// If the resolver panics, the waiting invocations fail and later invocations try again.
+		returned := false
+		defer func() {
+			if !returned {
+				finish(nil, errCachedResolverPanicked, false)
+			}
+		}()
+		v, err := resolve(ctx)
+		returned = true
+		if promise, ok := v.(graphql.ResolvePromise); ok && isNil(err) {
+			return chainResult(ctx.Context, promise, func(result graphql.ResolveResult, ctxErr error) (interface{}, error) {
+				if ctxErr != nil {
+					finish(nil, ctxErr, true)
+					return nil, ctxErr
+				}
+				finish(result.Value, result.Error, !isNil(result.Error) && ctx.Context.Err() != nil)
+				return result.Value, result.Error
+			}), nil
+		}
+		finish(v, err, !isNil(err) && ctx.Context.Err() != nil)
+		return v, err
+	}
+	return resolver
+}
+
+var errCachedResolverPanicked = errors.New("The resolver panicked.")
+
+func explainCacheHit(ctx context.Context, hit bool) {
+	updateExplainedField(ctx, func(field *ExplainedField) {
+		field.CacheHit = &hit
+	})
+}
+
+type cachedResolution struct {
+	once  sync.Once
+	done  chan struct{}
+	value interface{}
+	err   error
+
+	// If true, the resolution failed because the invoking context was canceled.
+	canceled bool
+}
+
+// MemoryCacheStore is an in-memory CacheStore. Once it reaches its maximum size, the least recently
+// used entries are evicted.
+type MemoryCacheStore struct {
+	maxEntries int
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+var _ CacheStore = (*MemoryCacheStore)(nil)
+
+type memoryCacheEntry struct {
+	key        string
+	value      interface{}
+	expiration time.Time
+}
+
+// NewMemoryCacheStore creates a new MemoryCacheStore which holds up to maxEntries values.
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+	}
+}
+
+func (s *MemoryCacheStore) Get(ctx context.Context, key string) (interface{}, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := e.Value.(*memoryCacheEntry)
+	if !time.Now().Before(entry.expiration) {
+		s.lru.Remove(e)
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(e)
+	return entry.value, true, nil
+}
+
+func (s *MemoryCacheStore) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry := &memoryCacheEntry{
+		key:        key,
+		value:      value,
+		expiration: time.Now().Add(ttl),
+	}
+	if e, ok := s.entries[key]; ok {
+		e.Value = entry
+		s.lru.MoveToFront(e)
+		return nil
+	}
+	s.entries[key] = s.lru.PushFront(entry)
+	for s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+	return nil
+}
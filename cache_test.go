@@ -0,0 +1,206 @@
+package apifu
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestCachedResolver(t *testing.T) {
+	var resolutions int64
+	store := NewMemoryCacheStore(100)
+
+	var testCfg Config
+	testCfg.AddQueryField("expensive", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Arguments: map[string]*graphql.InputValueDefinition{
+			"n": {
+				Type: graphql.NewNonNullType(graphql.IntType),
+			},
+		},
+		Resolve: CachedResolver(func(ctx graphql.FieldContext) (interface{}, error) {
+			n := ctx.Arguments["n"].(int)
+			return Go(ctx.Context, func() (interface{}, error) {
+				atomic.AddInt64(&resolutions, 1)
+				if n < 0 {
+					return nil, fmt.Errorf("negative")
+				}
+				// Give concurrent resolutions a chance to pile up.
+				time.Sleep(10 * time.Millisecond)
+				return n * 2, nil
+			}), nil
+		}, func(ctx graphql.FieldContext) string {
+			if n := ctx.Arguments["n"].(int); n != 0 {
+				return fmt.Sprintf("expensive:%v", n)
+			}
+			return ""
+		}, time.Minute, store),
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	// The cases depend on each other, so they must be run in order.
+	for _, tc := range []struct {
+		Name                string
+		Query               string
+		Expected            string
+		ExpectedResolutions int64
+	}{
+		{
+			Name:                "Coalesced",
+			Query:               `{a: expensive(n: 1) b: expensive(n: 1) c: expensive(n: 2)}`,
+			Expected:            `{"data":{"a":2,"b":2,"c":4}}`,
+			ExpectedResolutions: 2,
+		},
+		{
+			Name:                "Cached",
+			Query:               `{a: expensive(n: 1) c: expensive(n: 2)}`,
+			Expected:            `{"data":{"a":2,"c":4}}`,
+			ExpectedResolutions: 0,
+		},
+		{
+			Name:                "NoKey",
+			Query:               `{a: expensive(n: 0) b: expensive(n: 0)}`,
+			Expected:            `{"data":{"a":0,"b":0}}`,
+			ExpectedResolutions: 2,
+		},
+		{
+			Name:                "Error",
+			Query:               `{a: expensive(n: -1)}`,
+			Expected:            `{"data":{"a":null},"errors":[{"message":"negative","locations":[{"line":1,"column":2}],"path":["a"]}]}`,
+			ExpectedResolutions: 1,
+		},
+		{
+			Name:                "ErrorNotCached",
+			Query:               `{a: expensive(n: -1)}`,
+			Expected:            `{"data":{"a":null},"errors":[{"message":"negative","locations":[{"line":1,"column":2}],"path":["a"]}]}`,
+			ExpectedResolutions: 1,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			before := atomic.LoadInt64(&resolutions)
+			resp := executeGraphQL(t, api, tc.Query)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.Expected, string(body))
+			assert.Equal(t, tc.ExpectedResolutions, atomic.LoadInt64(&resolutions)-before)
+		})
+	}
+}
+
+func TestCachedResolver_Canceled(t *testing.T) {
+	var resolutions int64
+	started := make(chan struct{})
+
+	var testCfg Config
+	testCfg.AddQueryField("expensive", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: CachedResolver(func(ctx graphql.FieldContext) (interface{}, error) {
+			n := atomic.AddInt64(&resolutions, 1)
+			return GoCancelable(ctx.Context, func(ctx context.Context) (interface{}, error) {
+				if n == 1 {
+					// Block the first resolution until its request is canceled.
+					close(started)
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+				return 1, nil
+			}), nil
+		}, func(ctx graphql.FieldContext) string {
+			return "expensive"
+		}, time.Minute, NewMemoryCacheStore(100)),
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan struct{})
+	go func() {
+		defer close(canceled)
+		r, err := http.NewRequestWithContext(ctx, "POST", "", strings.NewReader(`{expensive}`))
+		require.NoError(t, err)
+		r.Header.Set("Content-Type", "application/graphql")
+		api.ServeGraphQL(httptest.NewRecorder(), r)
+	}()
+	<-started
+
+	waiter := make(chan *http.Response)
+	go func() {
+		waiter <- executeGraphQL(t, api, `{expensive}`)
+	}()
+	// Give the second request a chance to start waiting on the first.
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	<-canceled
+
+	resp := <-waiter
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"expensive":1}}`, string(body))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&resolutions))
+}
+
+func TestCachedResolver_Panic(t *testing.T) {
+	resolutions := 0
+	resolver := CachedResolver(func(ctx graphql.FieldContext) (interface{}, error) {
+		resolutions++
+		if resolutions == 1 {
+			panic("oops")
+		}
+		return resolutions, nil
+	}, func(ctx graphql.FieldContext) string {
+		return "key"
+	}, time.Minute, NewMemoryCacheStore(100))
+
+	ctx := graphql.FieldContext{
+		Context: context.Background(),
+	}
+	assert.Panics(t, func() {
+		resolver(ctx)
+	})
+
+	// The panicked resolution must not be left in flight, or this would wait for it forever.
+	v, err := resolver(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, v)
+}
+
+func TestMemoryCacheStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryCacheStore(2)
+
+	require.NoError(t, store.Set(ctx, "a", 1, time.Minute))
+	require.NoError(t, store.Set(ctx, "b", 2, time.Minute))
+
+	// Using a makes b the least recently used entry.
+	v, ok, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	require.NoError(t, store.Set(ctx, "c", 3, time.Minute))
+	_, ok, err = store.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = store.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, store.Set(ctx, "expired", 4, -time.Second))
+	_, ok, err = store.Get(ctx, "expired")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
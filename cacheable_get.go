@@ -0,0 +1,67 @@
+package apifu
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// CacheableGETConfig enables HTTP caching of queries executed via GET requests, e.g. by a CDN.
+// Clients typically construct the requests' URLs with graphql.CacheableGETURL so that equivalent
+// requests share cache entries.
+type CacheableGETConfig struct {
+	// If true, GET requests whose query strings aren't canonical are rejected with 400 Bad Request.
+	// This prevents clients from fragmenting caches with equivalent requests. See
+	// graphql.CanonicalGETQuery.
+	RequireCanonical bool
+
+	// The names of request headers other than Accept that affect responses, e.g. "Authorization".
+	// They're listed in the Vary header of every response to a GET request so that caches don't
+	// serve responses to the wrong clients.
+	Vary []string
+
+	// If given, successful responses to GET requests for queries have a Cache-Control header with
+	// this value, e.g. "public, max-age=60". Other responses to GET requests, such as those with
+	// errors, have a Cache-Control header of "no-store".
+	CacheControl string
+}
+
+// If Config.CacheableGET is given and the request is a GET request, this sets the response's Vary
+// header and checks that the request is canonical if required. If the request must be rejected, a
+// non-zero status code and a message are returned.
+func (api *API) checkCacheableGETRequest(w http.ResponseWriter, r *http.Request) (int, string) {
+	cfg := api.config.CacheableGET
+	if cfg == nil || r.Method != http.MethodGet {
+		return 0, ""
+	}
+
+	w.Header().Set("Vary", strings.Join(append([]string{"Accept"}, cfg.Vary...), ", "))
+
+	if cfg.RequireCanonical {
+		canonical, err := graphql.CanonicalGETQuery(r.URL.Query())
+		if err != nil {
+			return http.StatusBadRequest, err.Error()
+		} else if canonical != r.URL.RawQuery {
+			return http.StatusBadRequest, "the query string is not canonical"
+		}
+	}
+	return 0, ""
+}
+
+// Sets the Cache-Control header for the response to a GET request if Config.CacheableGET specifies
+// one. cacheable indicates whether every operation the request executed was a query that succeeded.
+func (api *API) setCacheControl(w http.ResponseWriter, r *http.Request, cacheable bool, resp interface{}) {
+	cfg := api.config.CacheableGET
+	if cfg == nil || cfg.CacheControl == "" || r.Method != http.MethodGet {
+		return
+	}
+	if resp, ok := resp.(*graphql.Response); ok && len(resp.Errors) > 0 {
+		cacheable = false
+	}
+	if cacheable {
+		w.Header().Set("Cache-Control", cfg.CacheControl)
+	} else {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+}
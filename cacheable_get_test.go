@@ -0,0 +1,118 @@
+package apifu
+
+import (
+	"context"
+	"crypto/sha256"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestCacheableGET(t *testing.T) {
+	testCfg := Config{
+		CacheableGET: &CacheableGETConfig{
+			RequireCanonical: true,
+			Vary:             []string{"Authorization"},
+			CacheControl:     "public, max-age=60",
+		},
+	}
+	storage := persistedQueryMap{}
+	testCfg.PersistedQueryStorage = storage
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Arguments: map[string]*graphql.InputValueDefinition{
+			"n": {
+				Type: graphql.IntType,
+			},
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return ctx.Arguments["n"], nil
+		},
+	})
+	testCfg.AddMutation("bar", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	get := func(t *testing.T, u string) *http.Response {
+		w := httptest.NewRecorder()
+		api.ServeGraphQL(w, httptest.NewRequest("GET", u, nil))
+		return w.Result()
+	}
+
+	cacheableURL := func(t *testing.T, req *graphql.CacheableGETRequest) string {
+		u, err := graphql.CacheableGETURL("/graphql", req)
+		require.NoError(t, err)
+		return u
+	}
+
+	t.Run("Query", func(t *testing.T) {
+		resp := get(t, cacheableURL(t, &graphql.CacheableGETRequest{
+			Query:     `query($n: Int) {foo(n: $n)}`,
+			Variables: map[string]interface{}{"n": 2},
+		}))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "public, max-age=60", resp.Header.Get("Cache-Control"))
+		assert.Equal(t, "Accept, Authorization", resp.Header.Get("Vary"))
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data":{"foo":2}}`, string(body))
+	})
+
+	t.Run("HashedQuery", func(t *testing.T) {
+		req := &graphql.CacheableGETRequest{
+			Query:     `{foo(n: 3)}`,
+			HashQuery: true,
+		}
+
+		// The query isn't persisted yet, so the error response must not be cached.
+		resp := get(t, cacheableURL(t, req))
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+		hash := sha256.Sum256([]byte(req.Query))
+		storage.PersistQuery(context.Background(), req.Query, hash[:])
+		resp = get(t, cacheableURL(t, req))
+		assert.Equal(t, "public, max-age=60", resp.Header.Get("Cache-Control"))
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data":{"foo":3}}`, string(body))
+	})
+
+	t.Run("ValidationError", func(t *testing.T) {
+		resp := get(t, cacheableURL(t, &graphql.CacheableGETRequest{
+			Query: `{baz}`,
+		}))
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	})
+
+	t.Run("Mutation", func(t *testing.T) {
+		resp := get(t, cacheableURL(t, &graphql.CacheableGETRequest{
+			Query: `mutation {bar}`,
+		}))
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	})
+
+	t.Run("NonCanonical", func(t *testing.T) {
+		resp := get(t, "/graphql?variables=%7B%7D&query=%7Bfoo%7D")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "Accept, Authorization", resp.Header.Get("Vary"))
+	})
+
+	t.Run("Post", func(t *testing.T) {
+		resp := executeGraphQL(t, api, `{foo(n: 1)}`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Cache-Control"))
+		assert.Empty(t, resp.Header.Get("Vary"))
+	})
+}
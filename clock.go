@@ -0,0 +1,129 @@
+package apifu
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// Clock is a source of the current time. See Config.Clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type clockFunc func() time.Time
+
+func (f clockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is a Clock that returns the system's local time.
+var SystemClock Clock = clockFunc(time.Now)
+
+// UTCClock is a Clock that returns the system's time in UTC. If it's used as Config.Clock, all
+// DateTime results are serialized in UTC.
+var UTCClock Clock = clockFunc(func() time.Time {
+	return time.Now().UTC()
+})
+
+// FakeClock is a Clock whose time only changes when it's explicitly set or advanced. It's intended
+// for tests. It's safe for concurrent use.
+type FakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewFakeClock creates a FakeClock set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now: now,
+	}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Set sets the clock's time.
+func (c *FakeClock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock's time forward by the given duration.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Returns the current time according to the clock, or the system's time if clock is nil.
+func clockNow(clock Clock) time.Time {
+	if clock == nil {
+		return time.Now()
+	}
+	return clock.Now()
+}
+
+// Returns the clock of the API serving the request, or nil if there isn't one.
+func ctxClock(ctx context.Context) Clock {
+	if api, _ := ctx.Value(apiContextKey).(*API); api != nil {
+		return api.config.Clock
+	}
+	return nil
+}
+
+// Returns the time converted to the location of the clock's times. If clock is nil, the time is
+// returned as is.
+func normalizeTime(clock Clock, t time.Time) time.Time {
+	if clock == nil {
+		return t
+	}
+	return t.In(clock.Now().Location())
+}
+
+type dateTimeTypeKeyType int
+
+// The metadata key that marks the scalars created by NewDateTimeType.
+var dateTimeTypeKey dateTimeTypeKeyType
+
+// normalizeDateTimes replaces the DateTime scalars in the definition with ones that convert results
+// to the location of the clock's times before they're serialized. The definition is cloned if
+// necessary.
+func normalizeDateTimes(def *graphql.SchemaDefinition, clock Clock) *graphql.SchemaDefinition {
+	// The scalars' pointers change when the definition is cloned, so they're identified by name.
+	names := map[string]struct{}{}
+	inspectNamedTypesOnce(def, func(node any) bool {
+		if t, ok := node.(*graphql.ScalarType); ok {
+			if _, ok := t.Metadata[dateTimeTypeKey]; ok {
+				names[t.Name] = struct{}{}
+			}
+		}
+		return true
+	})
+	if len(names) == 0 {
+		return def
+	}
+
+	def = def.Clone()
+	inspectNamedTypesOnce(def, func(node any) bool {
+		if t, ok := node.(*graphql.ScalarType); ok {
+			if _, ok := names[t.Name]; ok {
+				coerce := t.ResultCoercion
+				t.ResultCoercion = func(v any) any {
+					if tv, ok := v.(time.Time); ok {
+						v = normalizeTime(clock, tv)
+					}
+					return coerce(v)
+				}
+			}
+		}
+		return true
+	})
+	return def
+}
@@ -0,0 +1,101 @@
+package apifu
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	assert.Equal(t, start, clock.Now())
+	clock.Advance(time.Hour)
+	assert.Equal(t, start.Add(time.Hour), clock.Now())
+	clock.Set(start)
+	assert.Equal(t, start, clock.Now())
+}
+
+func TestClock(t *testing.T) {
+	est := time.FixedZone("EST", -5*60*60)
+	clock := NewFakeClock(time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC))
+
+	var minTimes []time.Time
+	var testCfg Config
+	testCfg.Clock = clock
+	testCfg.EnforceSunsets = true
+	testCfg.AddQueryField("time", &graphql.FieldDefinition{
+		Type: DateTimeType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return time.Date(2020, 1, 1, 0, 0, 0, 0, est), nil
+		},
+	})
+	testCfg.AddQueryField("retired", &graphql.FieldDefinition{
+		Type:              graphql.IntType,
+		DeprecationReason: "Use time instead.",
+		SunsetTime:        time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+	testCfg.AddQueryField("connection", TimeBasedConnection(&TimeBasedConnectionConfig{
+		NamePrefix: "Test",
+		EdgeGetter: func(ctx graphql.FieldContext, minTime time.Time, maxTime time.Time, limit int) (any, error) {
+			minTimes = append(minTimes, minTime)
+			return nil, nil
+		},
+		EdgeCursor: func(edge any) TimeBasedCursor {
+			return NewTimeBasedCursor(edge.(time.Time), "")
+		},
+		EdgeFields: map[string]*graphql.FieldDefinition{
+			"node": {
+				Type: DateTimeType,
+				Resolve: func(ctx graphql.FieldContext) (any, error) {
+					return ctx.Object, nil
+				},
+			},
+		},
+	}))
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	t.Run("DateTime", func(t *testing.T) {
+		resp := executeGraphQL(t, api, `{time}`)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data":{"time":"2020-01-01T05:00:00Z"}}`, string(body))
+
+		// The global scalar must not be modified.
+		assert.Equal(t, "2020-01-01T00:00:00-05:00", DateTimeType.ResultCoercion(time.Date(2020, 1, 1, 0, 0, 0, 0, est)))
+	})
+
+	t.Run("TimeBasedConnection", func(t *testing.T) {
+		minTimes = nil
+		resp := executeGraphQL(t, api, `{connection(first: 1, atOrAfterTime: "2020-01-01T00:00:00-05:00") {edges {node}}}`)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data":{"connection":{"edges":[]}}}`, string(body))
+		require.Len(t, minTimes, 1)
+		assert.Equal(t, time.UTC, minTimes[0].Location())
+		assert.True(t, minTimes[0].Equal(time.Date(2020, 1, 1, 5, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("Sunsets", func(t *testing.T) {
+		resp := executeGraphQL(t, api, `{retired}`)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data":{"retired":1}}`, string(body))
+
+		clock.Advance(48 * time.Hour)
+		resp = executeGraphQL(t, api, `{retired}`)
+		body, err = ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "FIELD_SUNSET")
+	})
+}
@@ -1,22 +1,21 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	goast "go/ast"
 	"go/format"
-	"go/parser"
-	"go/token"
 	"io"
 	"io/ioutil"
 	"os"
-	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/spf13/pflag"
 
+	"github.com/ccbrown/api-fu/gqlscan"
 	"github.com/ccbrown/api-fu/graphql"
 	"github.com/ccbrown/api-fu/graphql/ast"
 	"github.com/ccbrown/api-fu/graphql/schema"
@@ -30,6 +29,15 @@ type generateState struct {
 	outputStructCount  int
 	outputEnums        map[string]struct{}
 	requiresJSONImport bool
+
+	// Maps the hex-encoded SHA-256 hashes of normalized documents to the documents themselves.
+	operations map[string]string
+
+	// Maps the coordinates of selected fields to their requirements.
+	requirements map[string]*schemaRequirement
+
+	// Maps the names of subscription operations to their normalized documents.
+	subscriptions map[string]string
 }
 
 func fieldName(name string) string {
@@ -108,6 +116,9 @@ func (s *generateState) generateType(t schema.Type, selections []ast.Selection,
 		// type => field names
 		typeConditions := map[string][]string{}
 
+		// field name => field type
+		typeConditionFieldTypes := map[string]string{}
+
 		for _, sel := range selections {
 			switch sel := sel.(type) {
 			case *ast.FragmentSpread:
@@ -118,6 +129,7 @@ func (s *generateState) generateType(t schema.Type, selections []ast.Selection,
 				}
 				name := sel.FragmentName.Name
 				fields[name] = "*" + name + "Fragment `json:\"-\"`"
+				typeConditionFieldTypes[name] = "*" + name + "Fragment"
 				typeConditions[fragTypes[name]] = append(typeConditions[fragTypes[name]], name)
 			case *ast.InlineFragment:
 				if !hasTypename {
@@ -131,6 +143,7 @@ func (s *generateState) generateType(t schema.Type, selections []ast.Selection,
 					return "", err
 				}
 				fields[cond.TypeName()] = gen + " `json:\"-\"`"
+				typeConditionFieldTypes[cond.TypeName()] = gen
 				typeConditions[cond.TypeName()] = append(typeConditions[cond.TypeName()], cond.TypeName())
 			case *ast.Field:
 				var selections []ast.Selection
@@ -147,8 +160,10 @@ func (s *generateState) generateType(t schema.Type, selections []ast.Selection,
 					var err error
 					switch t := t.(type) {
 					case *schema.ObjectType:
+						s.addRequirement(t, sel, t.Fields[sel.Name.Name])
 						fields[k], err = s.generateType(t.Fields[sel.Name.Name].Type, selections, false, fragTypes)
 					case *schema.InterfaceType:
+						s.addRequirement(t, sel, t.Fields[sel.Name.Name])
 						fields[k], err = s.generateType(t.Fields[sel.Name.Name].Type, selections, false, fragTypes)
 					}
 					if err != nil {
@@ -158,6 +173,11 @@ func (s *generateState) generateType(t schema.Type, selections []ast.Selection,
 			}
 		}
 
+		if len(typeConditions) > 0 {
+			s.requiresJSONImport = true
+			fields["Unrecognized__"] = "json.RawMessage `json:\"-\"`"
+		}
+
 		parts := make([]string, 0, len(fields))
 		for k, v := range fields {
 			name := fieldName(k)
@@ -171,9 +191,16 @@ func (s *generateState) generateType(t schema.Type, selections []ast.Selection,
 		ret = "struct {\n" + strings.Join(parts, "") + "}"
 
 		if len(typeConditions) > 0 {
-			s.requiresJSONImport = true
 			tName := t.(schema.NamedType).TypeName()
 			name := "sel" + tName + strconv.Itoa(s.outputStructCount)
+			s.outputStructCount++
+
+			typeConds := make([]string, 0, len(typeConditions))
+			for typeCond := range typeConditions {
+				typeConds = append(typeConds, typeCond)
+			}
+			sort.Strings(typeConds)
+
 			s.output += `
 				type ` + name + ` ` + ret + `
 
@@ -184,7 +211,8 @@ func (s *generateState) generateType(t schema.Type, selections []ast.Selection,
 					}
 					*s = base
 			`
-			for typeCond, fields := range typeConditions {
+			for _, typeCond := range typeConds {
+				fields := typeConditions[typeCond]
 				isKnown := typeCond == tName
 				if obj, ok := t.(*schema.ObjectType); ok && !isKnown {
 					for _, iface := range obj.ImplementedInterfaces {
@@ -214,6 +242,7 @@ func (s *generateState) generateType(t schema.Type, selections []ast.Selection,
 				case *schema.ObjectType:
 					okTypes = []string{t.Name}
 				}
+				sort.Strings(okTypes)
 
 				for _, field := range fields {
 					s.output += `switch base.Typename__ {
@@ -225,9 +254,43 @@ func (s *generateState) generateType(t schema.Type, selections []ast.Selection,
 					`
 				}
 			}
-			s.output += "return nil\n}\n\n"
+
+			// Types that didn't exist when the code was generated are tolerated, but their raw JSON
+			// is retained so that callers can detect and handle them.
+			var possibleTypes []string
+			switch t := t.(type) {
+			case *schema.ObjectType:
+				possibleTypes = []string{t.Name}
+			case *schema.InterfaceType:
+				for _, t := range s.schema.InterfaceImplementations(t.Name) {
+					possibleTypes = append(possibleTypes, t.Name)
+				}
+			case *schema.UnionType:
+				for _, t := range t.MemberTypes {
+					possibleTypes = append(possibleTypes, t.Name)
+				}
+			}
+			sort.Strings(possibleTypes)
+			s.output += `switch base.Typename__ {
+				case "` + strings.Join(possibleTypes, `", "`) + `":
+				default:
+					s.Unrecognized__ = append(json.RawMessage(nil), b...)
+				}
+				return nil
+			}
+
+			`
+
+			for _, typeCond := range typeConds {
+				for _, field := range typeConditions[typeCond] {
+					s.output += `
+						func (s *` + name + `) As` + fieldName(field) + `() (` + typeConditionFieldTypes[field] + `, bool) {
+							return s.` + fieldName(field) + `, s.` + fieldName(field) + ` != nil
+						}
+					`
+				}
+			}
 			ret = name
-			s.outputStructCount++
 		}
 
 		if !nonNull {
@@ -262,6 +325,12 @@ func (s *generateState) processQuery(q string) []error {
 		return ret
 	}
 
+	// Clients send the documents as they're written, so that's what the manifest contains.
+	if s.operations != nil {
+		hash := sha256.Sum256([]byte(q))
+		s.operations[hex.EncodeToString(hash[:])] = q
+	}
+
 	fragTypes := map[string]string{}
 	for _, op := range doc.Definitions {
 		if def, ok := op.(*ast.FragmentDefinition); ok {
@@ -282,6 +351,9 @@ func (s *generateState) processQuery(q string) []error {
 				}
 			}
 			if op.Name != nil {
+				if s.subscriptions != nil && op.OperationType != nil && op.OperationType.Value == "subscription" {
+					s.subscriptions[op.Name.Name] = q
+				}
 				gen, err := s.generateType(t, op.SelectionSet.Selections, true, fragTypes)
 				if err != nil {
 					ret = append(ret, err)
@@ -304,41 +376,16 @@ func (s *generateState) processQuery(q string) []error {
 	return ret
 }
 
-func (s *generateState) processFile(path string) []error {
-	source, err := ioutil.ReadFile(path)
-	if err != nil {
-		return []error{err}
+func (s *generateState) processInputs(inputGlobs []string) []error {
+	scanner := &gqlscan.Scanner{
+		Wrapper: s.wrapper,
 	}
-
-	fset := token.NewFileSet()
-
-	f, err := parser.ParseFile(fset, "", source, 0)
-	if err != nil {
-		return []error{fmt.Errorf("parse error: %w", err)}
-	}
-
-	var errs []error
-
-	goast.Inspect(f, func(node goast.Node) bool {
-		switch node := node.(type) {
-		case *goast.CallExpr:
-			if ident, ok := node.Fun.(*goast.Ident); !ok || ident.Name != s.wrapper {
-				return true
-			} else if len(node.Args) != 1 {
-				errs = append(errs, fmt.Errorf("%v: expected 1 argument to %v", fset.Position(node.Lparen), s.wrapper))
-			} else if lit, ok := node.Args[0].(*goast.BasicLit); !ok || lit.Kind != token.STRING {
-				errs = append(errs, fmt.Errorf("%v: %v argument must be a string literal", fset.Position(node.Args[0].Pos()), s.wrapper))
-			} else if q, err := strconv.Unquote(lit.Value); err != nil {
-				errs = append(errs, fmt.Errorf("%v: error parsing argument: %w", fset.Position(node.Args[0].Pos()), err))
-			} else {
-				for _, err := range s.processQuery(q) {
-					errs = append(errs, fmt.Errorf("%v: %w", fset.Position(node.Args[0].Pos()), err))
-				}
-			}
+	docs, errs := scanner.ScanGlobs(inputGlobs)
+	for _, doc := range docs {
+		for _, err := range s.processQuery(doc.Source) {
+			errs = append(errs, fmt.Errorf("%v:%v:%v: %w", doc.Position.Filename, doc.Position.Line, doc.Position.Column, err))
 		}
-		return true
-	})
-
+	}
 	return errs
 }
 
@@ -349,21 +396,7 @@ func Generate(schema *schema.Schema, pkg string, inputGlobs []string, wrapper, j
 		outputEnums: map[string]struct{}{},
 	}
 
-	var errs []error
-	for _, glob := range inputGlobs {
-		matches, err := filepath.Glob(glob)
-		if err != nil {
-			errs = append(errs, err)
-			continue
-		}
-		for _, match := range matches {
-			for _, err := range state.processFile(match) {
-				errs = append(errs, fmt.Errorf("%v: %w", match, err))
-			}
-		}
-	}
-
-	if len(errs) > 0 {
+	if errs := state.processInputs(inputGlobs); len(errs) > 0 {
 		return "", errs
 	}
 
@@ -385,6 +418,28 @@ func Generate(schema *schema.Schema, pkg string, inputGlobs []string, wrapper, j
 	return string(out), nil
 }
 
+// GenerateManifest generates a persisted operations manifest for all of the documents found in the
+// inputs. The manifest is a JSON object mapping hex-encoded SHA-256 hashes to the documents exactly
+// as they appear in the inputs, which is how clients send them.
+func GenerateManifest(schema *schema.Schema, inputGlobs []string, wrapper string) ([]byte, []error) {
+	state := &generateState{
+		schema:      schema,
+		wrapper:     wrapper,
+		outputEnums: map[string]struct{}{},
+		operations:  map[string]string{},
+	}
+
+	if errs := state.processInputs(inputGlobs); len(errs) > 0 {
+		return nil, errs
+	}
+
+	ret, err := json.MarshalIndent(state.operations, "", "  ")
+	if err != nil {
+		return nil, []error{fmt.Errorf("error encoding manifest: %w", err)}
+	}
+	return append(ret, '\n'), nil
+}
+
 func LoadSchema(path string) (*schema.Schema, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -425,6 +480,13 @@ func Run(w io.Writer, args ...string) []error {
 	schemaPath := flags.String("schema", "", "the path to the schema json file")
 	wrapper := flags.String("wrapper", "gql", "the wrapper name to look for")
 	json := flags.String("json", "encoding/json", "the json encoding package to import")
+	manifest := flags.String("manifest", "", "if given, a persisted operations manifest is written to this path")
+	schemaCheck := flags.String("schema-check", "", "if given, schema compatibility checks are written to this path")
+	schemaCheckTag := flags.String("schema-check-tag", "schemacheck", "the build tag required to build the schema compatibility checks")
+	subscriptions := flags.String("subscriptions", "", "if given, graphql-transport-ws subscription functions are written to this path")
+	typeScript := flags.String("typescript", "", "if given, typescript definitions for the operations are written to this path")
+	typeScriptSchema := flags.String("typescript-schema", "", "if given, typescript definitions for the entire schema are written to this path")
+	typeScriptScalars := flags.StringToString("typescript-scalar", nil, "maps custom scalars to typescript types, e.g. DateTime=string")
 	flags.Parse(args)
 
 	if *pkg == "" {
@@ -445,6 +507,53 @@ func Run(w io.Writer, args ...string) []error {
 		return errs
 	}
 
+	if *manifest != "" {
+		b, errs := GenerateManifest(schema, *input, *wrapper)
+		if len(errs) > 0 {
+			return errs
+		}
+		if err := ioutil.WriteFile(*manifest, b, 0644); err != nil {
+			return []error{fmt.Errorf("error writing manifest: %w", err)}
+		}
+	}
+
+	if *schemaCheck != "" {
+		out, errs := GenerateSchemaCheck(schema, *pkg, *input, *wrapper, *schemaCheckTag)
+		if len(errs) > 0 {
+			return errs
+		}
+		if err := ioutil.WriteFile(*schemaCheck, []byte(out), 0644); err != nil {
+			return []error{fmt.Errorf("error writing schema check: %w", err)}
+		}
+	}
+
+	if *subscriptions != "" {
+		out, errs := GenerateSubscriptions(schema, *pkg, *input, *wrapper, *json)
+		if len(errs) > 0 {
+			return errs
+		}
+		if err := ioutil.WriteFile(*subscriptions, []byte(out), 0644); err != nil {
+			return []error{fmt.Errorf("error writing subscriptions: %w", err)}
+		}
+	}
+
+	if *typeScript != "" {
+		out, errs := GenerateTypeScript(schema, *input, *wrapper, *typeScriptScalars)
+		if len(errs) > 0 {
+			return errs
+		}
+		if err := ioutil.WriteFile(*typeScript, []byte(out), 0644); err != nil {
+			return []error{fmt.Errorf("error writing typescript definitions: %w", err)}
+		}
+	}
+
+	if *typeScriptSchema != "" {
+		out := GenerateTypeScriptSchema(schema, *typeScriptScalars)
+		if err := ioutil.WriteFile(*typeScriptSchema, []byte(out), 0644); err != nil {
+			return []error{fmt.Errorf("error writing typescript schema definitions: %w", err)}
+		}
+	}
+
 	fmt.Fprint(w, output)
 	return nil
 }
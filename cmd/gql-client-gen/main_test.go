@@ -1,7 +1,14 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,6 +23,58 @@ func TestGenerate(t *testing.T) {
 	require.Empty(t, errs)
 }
 
+func TestGenerate_TypeConditions(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	schema, err := LoadSchema("testdata/github-schema.json")
+	require.NoError(t, err)
+
+	out, errs := Generate(schema, "main", []string{"testdata/github.go"}, "gql", "encoding/json")
+	require.Empty(t, errs)
+
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module typeconditions\n\ngo 1.18\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "generated.go"), []byte(out), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func main() {
+	for _, data := range []string{
+		`+"`"+`{"node":{"__typename":"User","login":"octocat"}}`+"`"+`,
+		`+"`"+`{"node":{"__typename":"Bot","login":"dependabot"}}`+"`"+`,
+		`+"`"+`{"node":{"__typename":"Robot","serial":1}}`+"`"+`,
+	} {
+		var result UserData
+		if err := json.Unmarshal([]byte(data), &result); err != nil {
+			panic(err)
+		}
+		user, ok := result.Node.AsUser()
+		if ok {
+			fmt.Println(result.Node.Typename__, user.Login, string(result.Node.Unrecognized__))
+		} else {
+			fmt.Println(result.Node.Typename__, "-", string(result.Node.Unrecognized__))
+		}
+	}
+}
+`), 0644))
+
+	cmd := exec.Command(goBin, "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOWORK=off")
+	result, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(result))
+	assert.Equal(t, "User octocat \n"+
+		"Bot - \n"+
+		`Robot - {"__typename":"Robot","serial":1}`+"\n", string(result))
+}
+
 func TestRun(t *testing.T) {
 	assert.Empty(t, Run(ioutil.Discard, "--pkg", "test", "-i", "testdata/github.go", "--schema", "testdata/github-schema.json"))
 	assert.NotEmpty(t, Run(ioutil.Discard, "-i", "testdata/github.go", "--schema", "testdata/github-schema.json"))
@@ -23,3 +82,297 @@ func TestRun(t *testing.T) {
 	assert.NotEmpty(t, Run(ioutil.Discard, "--pkg", "test", "-i", "testdata/github.go", "--schema", "testdata/not-the-github-schema.json"))
 	assert.NotEmpty(t, Run(ioutil.Discard, "--pkg", "test", "-i", "testdata/github-schema.json", "--schema", "testdata/github-schema.json"))
 }
+
+func TestGenerateManifest(t *testing.T) {
+	schema, err := LoadSchema("testdata/github-schema.json")
+	require.NoError(t, err)
+
+	b, errs := GenerateManifest(schema, []string{"testdata/github.go"}, "gql")
+	require.Empty(t, errs)
+
+	source, err := ioutil.ReadFile("testdata/github.go")
+	require.NoError(t, err)
+
+	var manifest map[string]string
+	require.NoError(t, json.Unmarshal(b, &manifest))
+	assert.NotEmpty(t, manifest)
+	for k, v := range manifest {
+		hash := sha256.Sum256([]byte(v))
+		assert.Equal(t, hex.EncodeToString(hash[:]), k)
+
+		// The documents must be exactly what clients send.
+		assert.Contains(t, string(source), "gql(`"+v+"`)")
+	}
+}
+
+func TestRun_Manifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	require.Empty(t, Run(ioutil.Discard, "--pkg", "test", "-i", "testdata/github.go", "--schema", "testdata/github-schema.json", "--manifest", path))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotEmpty(t, b)
+}
+
+func TestGenerateSchemaCheck(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	schema, err := LoadSchema("testdata/github-schema.json")
+	require.NoError(t, err)
+
+	out, errs := GenerateSchemaCheck(schema, "main", []string{"testdata/github.go"}, "gql", "schemacheck")
+	require.Empty(t, errs)
+	assert.True(t, strings.HasPrefix(out, "//go:build schemacheck\n"))
+
+	original, err := ioutil.ReadFile("testdata/github-schema.json")
+	require.NoError(t, err)
+
+	// Remove User.login and make User.name non-null, which is a compatible change.
+	var introspection map[string]interface{}
+	require.NoError(t, json.Unmarshal(original, &introspection))
+	for _, typ := range introspection["data"].(map[string]interface{})["__schema"].(map[string]interface{})["types"].([]interface{}) {
+		typ := typ.(map[string]interface{})
+		if typ["name"] != "User" {
+			continue
+		}
+		var fields []interface{}
+		for _, f := range typ["fields"].([]interface{}) {
+			f := f.(map[string]interface{})
+			switch f["name"] {
+			case "login":
+				continue
+			case "name":
+				f["type"] = map[string]interface{}{"kind": "NON_NULL", "ofType": f["type"]}
+			}
+			fields = append(fields, f)
+		}
+		typ["fields"] = fields
+	}
+	modified, err := json.Marshal(introspection)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module schemacheck\n\ngo 1.18\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "schema_check.go"), []byte(out), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "original.json"), original, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "modified.json"), modified, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(`//go:build schemacheck
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	for _, path := range []string{"original.json", "modified.json"} {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("%v: %v\n", path, CheckSchema(b))
+	}
+	fmt.Println(CheckSchemaFingerprint(SchemaFingerprint))
+}
+`), 0644))
+
+	cmd := exec.Command(goBin, "run", "-tags", "schemacheck", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOWORK=off")
+	result, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(result))
+	assert.Equal(t, "original.json: <nil>\n"+
+		"modified.json: schema is incompatible with the generated operations:\n"+
+		"  - User.login: String!\n"+
+		"<nil>\n", string(result))
+}
+
+func TestRun_SchemaCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema_check.go")
+	require.Empty(t, Run(ioutil.Discard, "--pkg", "test", "-i", "testdata/github.go", "--schema", "testdata/github-schema.json", "--schema-check", path, "--schema-check-tag", "foo"))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(b), "//go:build foo\n"))
+}
+
+func TestGenerateTypeScript(t *testing.T) {
+	schema, err := LoadSchema("testdata/github-schema.json")
+	require.NoError(t, err)
+
+	out, errs := GenerateTypeScript(schema, []string{"testdata/github.go"}, "gql", nil)
+	require.Empty(t, errs)
+
+	assert.Contains(t, out, `export type ReactionContent = "CONFUSED" | "EYES" | "HEART" | "HOORAY" | "LAUGH" | "ROCKET" | "THUMBS_DOWN" | "THUMBS_UP";`)
+	assert.Contains(t, out, `export type FindIssueIDData = {
+  repository: {
+    issue: {
+      id: string;
+    } | null;
+  } | null;
+};`)
+	assert.Contains(t, out, `  } | {
+    __typename: "User";
+    name: string | null;
+    login: string;
+  } | null;
+};`)
+	assert.Contains(t, out, `export interface UserVariables {
+}`)
+}
+
+func TestGenerateTypeScriptSchema(t *testing.T) {
+	schema, err := LoadSchema("testdata/github-schema.json")
+	require.NoError(t, err)
+
+	out := GenerateTypeScriptSchema(schema, map[string]string{
+		"DateTime": "string",
+	})
+
+	assert.Contains(t, out, "export type DateTime = string;")
+	assert.Contains(t, out, `export interface AcceptTopicSuggestionInput {
+  /**
+   * A unique identifier for the client performing the mutation.
+   */
+  clientMutationId?: string | null;`)
+	assert.Contains(t, out, `export interface User extends `)
+	assert.NotContains(t, out, "__Type")
+}
+
+func TestGenerateSubscriptions(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	schema, err := LoadSchema("testdata/chat-schema.json")
+	require.NoError(t, err)
+
+	types, errs := Generate(schema, "main", []string{"testdata/chat.go"}, "gql", "encoding/json")
+	require.Empty(t, errs)
+	out, errs := GenerateSubscriptions(schema, "main", []string{"testdata/chat.go"}, "gql", "encoding/json")
+	require.Empty(t, errs)
+	assert.Contains(t, out, "func SubscribeToMessageAdded(")
+	assert.NotContains(t, out, "Node")
+
+	// The program needs to be within this module to import api-fu.
+	dir, err := ioutil.TempDir("testdata", "subscriptions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "generated.go"), []byte(types), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "subscriptions.go"), []byte(out), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	apifu "github.com/ccbrown/api-fu"
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/transport/graphqltransportws"
+)
+
+func main() {
+	messageType := &graphql.ObjectType{
+		Name: "Message",
+		Fields: map[string]*graphql.FieldDefinition{
+			"text": {
+				Type: graphql.NewNonNullType(graphql.StringType),
+				Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+					return ctx.Object, nil
+				},
+			},
+		},
+	}
+	var cfg apifu.Config
+	cfg.AddSubscription("messageAdded", &graphql.FieldDefinition{
+		Type: graphql.NewNonNullType(messageType),
+		Arguments: map[string]*graphql.InputValueDefinition{
+			"channel": {Type: graphql.NewNonNullType(graphql.StringType)},
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			if ctx.IsSubscribe {
+				ch := make(chan string, 2)
+				ch <- "hello from " + ctx.Arguments["channel"].(string)
+				ch <- "bye from " + ctx.Arguments["channel"].(string)
+				close(ch)
+				return &apifu.SubscriptionSourceStream{
+					EventChannel: ch,
+					Stop:         func() {},
+				}, nil
+			}
+			return ctx.Object, nil
+		},
+	})
+	api, err := apifu.NewAPI(&cfg)
+	if err != nil {
+		panic(err)
+	}
+	defer api.CloseHijackedConnections()
+
+	ts := httptest.NewServer(http.HandlerFunc(api.ServeGraphQLWS))
+	defer ts.Close()
+
+	client := &graphqltransportws.Client{
+		URL: "ws" + strings.TrimPrefix(ts.URL, "http"),
+	}
+	defer client.Close()
+
+	for _, variables := range []map[string]interface{}{
+		{"channel": "general"},
+		{"channel": 1},
+	} {
+		data, errs, stop := SubscribeToMessageAdded(context.Background(), client, variables)
+		for data != nil || errs != nil {
+			select {
+			case d, ok := <-data:
+				if !ok {
+					data = nil
+					continue
+				}
+				fields, _ := d.MessageAdded.AsMessageFields()
+				fmt.Println(fields.Text)
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				fmt.Println("error:", err)
+			}
+		}
+		stop()
+	}
+}
+`), 0644))
+
+	cmd := exec.Command(goBin, "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOWORK=off")
+	result, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(result))
+	assert.Equal(t, "hello from general\n"+
+		"bye from general\n"+
+		"error: Validation error: Invalid $channel value: invalid scalar value\n", string(result))
+}
+
+func TestRun_Subscriptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscriptions.go")
+	require.Empty(t, Run(ioutil.Discard, "--pkg", "test", "-i", "testdata/chat.go", "--schema", "testdata/chat-schema.json", "--subscriptions", path))
+
+	b, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "func SubscribeToMessageAdded(")
+
+	// Without subscriptions, the output must still be valid.
+	require.Empty(t, Run(ioutil.Discard, "--pkg", "test", "-i", "testdata/github.go", "--schema", "testdata/github-schema.json", "--subscriptions", path))
+	b, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "// Code generated by gql-client-gen. DO NOT EDIT.\n\npackage test\n", string(b))
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/schema"
+	"github.com/ccbrown/api-fu/graphql/sdl"
+)
+
+// A field that the generated operations depend on.
+type schemaRequirement struct {
+	Type      string
+	Arguments map[string]struct{}
+}
+
+func (s *generateState) addRequirement(parent schema.NamedType, field *ast.Field, def *schema.FieldDefinition) {
+	if s.requirements == nil || def == nil || strings.HasPrefix(field.Name.Name, "__") {
+		return
+	}
+	coordinate := parent.TypeName() + "." + field.Name.Name
+	req, ok := s.requirements[coordinate]
+	if !ok {
+		req = &schemaRequirement{
+			Type:      def.Type.String(),
+			Arguments: map[string]struct{}{},
+		}
+		s.requirements[coordinate] = req
+	}
+	for _, arg := range field.Arguments {
+		req.Arguments[arg.Name.Name] = struct{}{}
+	}
+}
+
+// Computes the same fingerprint as apifu.SchemaFingerprint. The introspection meta-fields added by
+// LoadSchema aren't part of the server's schema, so they're excluded.
+func schemaFingerprint(s *schema.Schema) (string, error) {
+	fields := s.QueryType().Fields
+	withoutMetaFields := make(map[string]*schema.FieldDefinition, len(fields))
+	for k, v := range fields {
+		if !strings.HasPrefix(k, "__") {
+			withoutMetaFields[k] = v
+		}
+	}
+	s.QueryType().Fields = withoutMetaFields
+	defer func() {
+		s.QueryType().Fields = fields
+	}()
+
+	printed, err := sdl.Print(s)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(printed))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// GenerateSchemaCheck generates a file containing functions that verify at runtime that a server's
+// schema is still compatible with the operations found in the inputs. The file is only built if the
+// given build tag is present.
+func GenerateSchemaCheck(schema *schema.Schema, pkg string, inputGlobs []string, wrapper, buildTag string) (string, []error) {
+	state := &generateState{
+		schema:       schema,
+		wrapper:      wrapper,
+		outputEnums:  map[string]struct{}{},
+		requirements: map[string]*schemaRequirement{},
+	}
+
+	if errs := state.processInputs(inputGlobs); len(errs) > 0 {
+		return "", errs
+	}
+
+	fingerprint, err := schemaFingerprint(schema)
+	if err != nil {
+		return "", []error{fmt.Errorf("error computing schema fingerprint: %w", err)}
+	}
+
+	coordinates := make([]string, 0, len(state.requirements))
+	for k := range state.requirements {
+		coordinates = append(coordinates, k)
+	}
+	sort.Strings(coordinates)
+
+	var fields strings.Builder
+	for _, coordinate := range coordinates {
+		req := state.requirements[coordinate]
+		args := make([]string, 0, len(req.Arguments))
+		for arg := range req.Arguments {
+			args = append(args, strconv.Quote(arg))
+		}
+		sort.Strings(args)
+		fields.WriteString(strconv.Quote(coordinate) + ": {Type: " + strconv.Quote(req.Type))
+		if len(args) > 0 {
+			fields.WriteString(", Arguments: []string{" + strings.Join(args, ", ") + "}")
+		}
+		fields.WriteString("},\n")
+	}
+
+	output := strings.NewReplacer(
+		"$BUILD_TAG", buildTag,
+		"$PACKAGE", pkg,
+		"$FINGERPRINT", fingerprint,
+		"$FIELDS", fields.String(),
+	).Replace(schemaCheckTemplate)
+
+	out, err := format.Source([]byte(output))
+	if err != nil {
+		return "", []error{fmt.Errorf("error formatting result: %w", err)}
+	}
+	return string(out), nil
+}
+
+const schemaCheckTemplate = `//go:build $BUILD_TAG
+
+// Code generated by gql-client-gen. DO NOT EDIT.
+
+package $PACKAGE
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// SchemaFingerprint is the fingerprint of the schema that this package was generated from. It
+// matches the fingerprint computed by apifu.SchemaFingerprint.
+const SchemaFingerprint = "$FINGERPRINT"
+
+type schemaCheckField struct {
+	Type      string
+	Arguments []string
+}
+
+// Maps the coordinates of the fields selected by the generated operations to their types and the
+// arguments given to them.
+var schemaCheckFields = map[string]schemaCheckField{
+$FIELDS}
+
+// SchemaIncompatibleError is returned when a schema isn't compatible with the generated operations.
+type SchemaIncompatibleError struct {
+	// Human readable descriptions of each incompatibility, sorted by field coordinate.
+	Differences []string
+}
+
+func (err *SchemaIncompatibleError) Error() string {
+	return "schema is incompatible with the generated operations:\n  " + strings.Join(err.Differences, "\n  ")
+}
+
+// CheckSchemaFingerprint returns an error if the given fingerprint doesn't match the schema this
+// package was generated from. This is cheap, but fails on any schema change, including compatible
+// ones.
+func CheckSchemaFingerprint(fingerprint string) error {
+	if fingerprint != SchemaFingerprint {
+		return fmt.Errorf("schema fingerprint %v doesn't match the generated fingerprint %v", fingerprint, SchemaFingerprint)
+	}
+	return nil
+}
+
+// SchemaCheckQuery is the introspection query whose result CheckSchema expects.
+const SchemaCheckQuery = ` + "`" + `{__schema{types{name fields(includeDeprecated:true){name args{name} type{...T}}}}} fragment T on __Type{kind name ofType{kind name ofType{kind name ofType{kind name ofType{kind name ofType{kind name}}}}}}` + "`" + `
+
+type schemaCheckTypeRef struct {
+	Kind   string
+	Name   string
+	OfType *schemaCheckTypeRef
+}
+
+func (t *schemaCheckTypeRef) String() string {
+	if t == nil {
+		return ""
+	}
+	switch t.Kind {
+	case "NON_NULL":
+		return t.OfType.String() + "!"
+	case "LIST":
+		return "[" + t.OfType.String() + "]"
+	}
+	return t.Name
+}
+
+// Output types are covariant, so a server may make a nullable field non-null.
+func schemaCheckTypeIsCompatible(expected, actual string) bool {
+	if expected == actual {
+		return true
+	} else if strings.HasSuffix(actual, "!") && !strings.HasSuffix(expected, "!") {
+		return schemaCheckTypeIsCompatible(expected, strings.TrimSuffix(actual, "!"))
+	} else if strings.HasSuffix(expected, "!") && strings.HasSuffix(actual, "!") {
+		return schemaCheckTypeIsCompatible(strings.TrimSuffix(expected, "!"), strings.TrimSuffix(actual, "!"))
+	} else if strings.HasPrefix(expected, "[") && strings.HasSuffix(expected, "]") && strings.HasPrefix(actual, "[") && strings.HasSuffix(actual, "]") {
+		return schemaCheckTypeIsCompatible(expected[1:len(expected)-1], actual[1:len(actual)-1])
+	}
+	return false
+}
+
+// CheckSchema verifies that a schema is compatible with the generated operations. The given JSON
+// should be the response to SchemaCheckQuery or any introspection query that selects at least the
+// same fields. If the schema is incompatible, a *SchemaIncompatibleError is returned.
+func CheckSchema(introspectionResponse []byte) error {
+	var response struct {
+		Data *struct {
+			Schema struct {
+				Types []struct {
+					Name   string
+					Fields []struct {
+						Name string
+						Args []struct {
+							Name string
+						}
+						Type *schemaCheckTypeRef
+					}
+				}
+			} ` + "`" + `json:"__schema"` + "`" + `
+		}
+		Errors []struct {
+			Message string
+		}
+	}
+	if err := json.Unmarshal(introspectionResponse, &response); err != nil {
+		return fmt.Errorf("error decoding introspection response: %w", err)
+	} else if len(response.Errors) > 0 {
+		return fmt.Errorf("introspection error: %v", response.Errors[0].Message)
+	} else if response.Data == nil {
+		return fmt.Errorf("introspection response has no data")
+	}
+
+	type field struct {
+		Type      string
+		Arguments map[string]struct{}
+	}
+	fields := map[string]field{}
+	for _, t := range response.Data.Schema.Types {
+		for _, f := range t.Fields {
+			args := map[string]struct{}{}
+			for _, arg := range f.Args {
+				args[arg.Name] = struct{}{}
+			}
+			fields[t.Name+"."+f.Name] = field{
+				Type:      f.Type.String(),
+				Arguments: args,
+			}
+		}
+	}
+
+	var differences []string
+	for coordinate, expected := range schemaCheckFields {
+		actual, ok := fields[coordinate]
+		if !ok {
+			differences = append(differences, fmt.Sprintf("- %v: %v", coordinate, expected.Type))
+			continue
+		}
+		if !schemaCheckTypeIsCompatible(expected.Type, actual.Type) {
+			differences = append(differences, fmt.Sprintf("~ %v: %v => %v", coordinate, expected.Type, actual.Type))
+		}
+		for _, arg := range expected.Arguments {
+			if _, ok := actual.Arguments[arg]; !ok {
+				differences = append(differences, fmt.Sprintf("- %v(%v:)", coordinate, arg))
+			}
+		}
+	}
+	if len(differences) > 0 {
+		sort.Slice(differences, func(i, j int) bool {
+			return differences[i][2:] < differences[j][2:]
+		})
+		return &SchemaIncompatibleError{
+			Differences: differences,
+		}
+	}
+	return nil
+}
+
+// CheckSchemaEndpoint sends SchemaCheckQuery to the given GraphQL endpoint and verifies that its
+// schema is compatible with the generated operations. If client is nil, http.DefaultClient is used.
+func CheckSchemaEndpoint(ctx context.Context, client *http.Client, url string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"query": SchemaCheckQuery,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	} else if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected introspection response status: %v", resp.Status)
+	}
+	return CheckSchema(b)
+}
+`
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+// GenerateSubscriptions generates a file containing a function for each named subscription found
+// in the inputs. The functions subscribe via a graphqltransportws.Client and decode the results
+// into the types generated by Generate, so the file must be in the same package as Generate's
+// output.
+func GenerateSubscriptions(schema *schema.Schema, pkg string, inputGlobs []string, wrapper, jsonPackage string) (string, []error) {
+	state := &generateState{
+		schema:        schema,
+		wrapper:       wrapper,
+		outputEnums:   map[string]struct{}{},
+		subscriptions: map[string]string{},
+	}
+
+	if errs := state.processInputs(inputGlobs); len(errs) > 0 {
+		return "", errs
+	}
+
+	names := make([]string, 0, len(state.subscriptions))
+	for name := range state.subscriptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Without subscriptions, the imports would be unused.
+	imports := ""
+	if len(names) > 0 {
+		jsonImport := strconv.Quote(jsonPackage)
+		if !strings.HasSuffix(jsonPackage, "/json") {
+			jsonImport = "json " + jsonImport
+		}
+		imports = "import (\n\"context\"\n" + jsonImport + "\n\n\"github.com/ccbrown/api-fu/graphql/transport/graphqltransportws\"\n)\n"
+	}
+
+	var functions strings.Builder
+	for _, name := range names {
+		functions.WriteString(strings.NewReplacer(
+			"$NAME_QUOTED", strconv.Quote(name),
+			"$NAME", name,
+			"$DOCUMENT", strconv.Quote(state.subscriptions[name]),
+		).Replace(subscriptionFunctionTemplate))
+	}
+
+	output := strings.NewReplacer(
+		"$PACKAGE", pkg,
+		"$IMPORTS", imports,
+		"$FUNCTIONS", functions.String(),
+	).Replace(subscriptionsTemplate)
+
+	out, err := format.Source([]byte(output))
+	if err != nil {
+		return "", []error{fmt.Errorf("error formatting result: %w", err)}
+	}
+	return string(out), nil
+}
+
+const subscriptionsTemplate = `// Code generated by gql-client-gen. DO NOT EDIT.
+
+package $PACKAGE
+
+$IMPORTS$FUNCTIONS`
+
+const subscriptionFunctionTemplate = `
+const subscribeTo$NAMEDocument = $DOCUMENT
+
+// SubscribeTo$NAME starts a $NAME subscription.
+//
+// Results are sent to the first returned channel. Errors, including those within results, are sent
+// to the second. Both channels are closed once the subscription ends, which happens when the server
+// completes it, when the client is closed, or when the context is canceled. The client reconnects
+// and resubscribes if its connection is lost.
+//
+// The returned function stops the subscription. It must be invoked to release the subscription's
+// resources, even if the subscription has already ended.
+func SubscribeTo$NAME(ctx context.Context, client *graphqltransportws.Client, variables map[string]interface{}) (<-chan $NAMEData, <-chan error, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	sub := client.Subscribe(ctx, &graphqltransportws.SubscribePayload{
+		Query:         subscribeTo$NAMEDocument,
+		OperationName: $NAME_QUOTED,
+		Variables:     variables,
+	})
+	data := make(chan $NAMEData)
+	errs := make(chan error)
+	go func() {
+		defer close(errs)
+		defer close(data)
+		for result := range sub.Results() {
+			if len(result.Errors) > 0 {
+				select {
+				case errs <- &graphqltransportws.ResultError{Errors: result.Errors}:
+				case <-ctx.Done():
+				}
+			}
+			if len(result.Data) == 0 || string(result.Data) == "null" {
+				continue
+			}
+			var d $NAMEData
+			if err := json.Unmarshal(result.Data, &d); err != nil {
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+				}
+				continue
+			}
+			select {
+			case data <- d:
+			case <-ctx.Done():
+			}
+		}
+		if err := sub.Err(); err != nil && ctx.Err() == nil {
+			select {
+			case errs <- err:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return data, errs, cancel
+}
+`
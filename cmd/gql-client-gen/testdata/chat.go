@@ -0,0 +1,23 @@
+package main
+
+// Wrapper to mark queries for gql-client-gen.
+func gql(s string) string {
+	return s
+}
+
+const messageFields = `fragment MessageFields on Message {
+	text
+}`
+
+var messageAdded = gql(`subscription MessageAdded($channel: String!) {
+	messageAdded(channel: $channel) {
+		__typename
+		...MessageFields
+	}
+}` + messageFields)
+
+var node = gql(`query Node {
+	node(id: "foo") {
+		id
+	}
+}`)
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ccbrown/api-fu/gqlscan"
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+type typeScriptState struct {
+	schema *schema.Schema
+
+	// Maps custom scalar names to TypeScript types. Unmapped scalars are "unknown".
+	scalars map[string]string
+
+	// The fragments of the document currently being processed.
+	fragments map[string]*ast.FragmentDefinition
+
+	// Named input types and enums that need declarations.
+	referencedTypes map[string]struct{}
+
+	output strings.Builder
+}
+
+// GenerateTypeScriptSchema generates TypeScript declarations for every type in the schema. Object
+// and interface types include all of their fields, so these declarations describe what a server
+// may return rather than what a particular operation selects.
+func GenerateTypeScriptSchema(s *schema.Schema, scalars map[string]string) string {
+	state := &typeScriptState{
+		schema:  s,
+		scalars: scalars,
+	}
+	state.output.WriteString("// Code generated by gql-client-gen. DO NOT EDIT.\n")
+
+	names := make([]string, 0, len(s.NamedTypes()))
+	for name := range s.NamedTypes() {
+		if !strings.HasPrefix(name, "__") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t := s.NamedTypes()[name]
+		switch t := t.(type) {
+		case *schema.ScalarType:
+			if _, ok := builtinTypeScriptScalars[name]; !ok {
+				state.output.WriteString("\n")
+				state.writeDescription("", t.Description)
+				fmt.Fprintf(&state.output, "export type %v = %v;\n", name, state.scalarType(name))
+			}
+		case *schema.EnumType:
+			state.writeEnum(t)
+		case *schema.InputObjectType:
+			state.writeInputObject(t)
+		case *schema.ObjectType:
+			state.output.WriteString("\n")
+			state.writeDescription("", t.Description)
+			fmt.Fprintf(&state.output, "export interface %v", name)
+			if len(t.ImplementedInterfaces) > 0 {
+				ifaces := make([]string, len(t.ImplementedInterfaces))
+				for i, iface := range t.ImplementedInterfaces {
+					ifaces[i] = iface.Name
+				}
+				state.output.WriteString(" extends " + strings.Join(ifaces, ", "))
+			}
+			state.output.WriteString(" {\n")
+			fmt.Fprintf(&state.output, "  __typename: %v;\n", strconv.Quote(name))
+			state.writeSchemaFields(t.Fields)
+			state.output.WriteString("}\n")
+		case *schema.InterfaceType:
+			state.output.WriteString("\n")
+			state.writeDescription("", t.Description)
+			fmt.Fprintf(&state.output, "export interface %v {\n", name)
+			var typenames []string
+			for _, obj := range s.InterfaceImplementations(name) {
+				typenames = append(typenames, strconv.Quote(obj.Name))
+			}
+			sort.Strings(typenames)
+			if len(typenames) == 0 {
+				typenames = []string{"string"}
+			}
+			fmt.Fprintf(&state.output, "  __typename: %v;\n", strings.Join(typenames, " | "))
+			state.writeSchemaFields(t.Fields)
+			state.output.WriteString("}\n")
+		case *schema.UnionType:
+			members := make([]string, len(t.MemberTypes))
+			for i, member := range t.MemberTypes {
+				members[i] = member.Name
+			}
+			sort.Strings(members)
+			state.output.WriteString("\n")
+			state.writeDescription("", t.Description)
+			fmt.Fprintf(&state.output, "export type %v = %v;\n", name, strings.Join(members, " | "))
+		}
+	}
+	return state.output.String()
+}
+
+// GenerateTypeScript generates TypeScript declarations for the operations and fragments found in
+// the inputs. For each named operation, "Data" and "Variables" types are generated, and for each
+// named fragment, a "Fragment" type is generated. Enums and input objects used by the operations
+// are declared as well.
+func GenerateTypeScript(s *schema.Schema, inputGlobs []string, wrapper string, scalars map[string]string) (string, []error) {
+	state := &typeScriptState{
+		schema:          s,
+		scalars:         scalars,
+		referencedTypes: map[string]struct{}{},
+	}
+
+	scanner := &gqlscan.Scanner{
+		Wrapper: wrapper,
+	}
+	docs, errs := scanner.ScanGlobs(inputGlobs)
+	var operations strings.Builder
+	for _, doc := range docs {
+		parsed, validationErrs := graphql.ParseAndValidate(doc.Source, s, nil)
+		for _, err := range validationErrs {
+			errs = append(errs, fmt.Errorf("%v:%v:%v: %w", doc.Position.Filename, doc.Position.Line, doc.Position.Column, err))
+		}
+		if len(validationErrs) == 0 {
+			state.writeDocument(parsed)
+			operations.WriteString(state.output.String())
+			state.output.Reset()
+		}
+	}
+	if len(errs) > 0 {
+		return "", errs
+	}
+
+	state.output.WriteString("// Code generated by gql-client-gen. DO NOT EDIT.\n")
+
+	// Declare the referenced types. Input objects may reference further types, so this repeats
+	// until no new types are referenced.
+	declared := map[string]struct{}{}
+	for len(declared) < len(state.referencedTypes) {
+		var names []string
+		for name := range state.referencedTypes {
+			if _, ok := declared[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			declared[name] = struct{}{}
+			switch t := s.NamedTypes()[name].(type) {
+			case *schema.EnumType:
+				state.writeEnum(t)
+			case *schema.InputObjectType:
+				state.writeInputObject(t)
+			case *schema.ScalarType:
+				fmt.Fprintf(&state.output, "\nexport type %v = %v;\n", name, state.scalarType(name))
+			}
+		}
+	}
+
+	state.output.WriteString(operations.String())
+	return state.output.String(), nil
+}
+
+var builtinTypeScriptScalars = map[string]string{
+	"Boolean": "boolean",
+	"Int":     "number",
+	"Float":   "number",
+	"String":  "string",
+	"ID":      "string",
+}
+
+func (s *typeScriptState) scalarType(name string) string {
+	if t, ok := builtinTypeScriptScalars[name]; ok {
+		return t
+	} else if t, ok := s.scalars[name]; ok {
+		return t
+	}
+	return "unknown"
+}
+
+func (s *typeScriptState) writeDescription(indent, description string) {
+	if description == "" {
+		return
+	}
+	s.output.WriteString(indent + "/**\n")
+	for _, line := range strings.Split(description, "\n") {
+		s.output.WriteString(strings.TrimRight(indent+" * "+strings.ReplaceAll(line, "*/", "*\\/"), " ") + "\n")
+	}
+	s.output.WriteString(indent + " */\n")
+}
+
+func (s *typeScriptState) writeEnum(t *schema.EnumType) {
+	values := make([]string, 0, len(t.Values))
+	for value := range t.Values {
+		values = append(values, strconv.Quote(value))
+	}
+	sort.Strings(values)
+	s.output.WriteString("\n")
+	s.writeDescription("", t.Description)
+	fmt.Fprintf(&s.output, "export type %v = %v;\n", t.Name, strings.Join(values, " | "))
+}
+
+func (s *typeScriptState) writeInputObject(t *schema.InputObjectType) {
+	s.output.WriteString("\n")
+	s.writeDescription("", t.Description)
+	fmt.Fprintf(&s.output, "export interface %v {\n", t.Name)
+	names := make([]string, 0, len(t.Fields))
+	for name := range t.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field := t.Fields[name]
+		s.writeDescription("  ", field.Description)
+		s.writeInputField(name, field.Type, field.DefaultValue != nil)
+	}
+	s.output.WriteString("}\n")
+}
+
+// Writes a field of an input object or variables type. Nullable fields and fields with defaults
+// may be omitted.
+func (s *typeScriptState) writeInputField(name string, t schema.Type, hasDefault bool) {
+	optional := ""
+	if _, ok := t.(*schema.NonNullType); !ok || hasDefault {
+		optional = "?"
+	}
+	fmt.Fprintf(&s.output, "  %v%v: %v;\n", name, optional, s.inputType(t))
+}
+
+func (s *typeScriptState) inputType(t schema.Type) string {
+	nullable := true
+	if nonNull, ok := t.(*schema.NonNullType); ok {
+		t = nonNull.Type
+		nullable = false
+	}
+
+	var ret string
+	switch t := t.(type) {
+	case *schema.ListType:
+		ret = "Array<" + s.inputType(t.Type) + ">"
+	case *schema.ScalarType:
+		ret = s.scalarType(t.Name)
+		if _, ok := builtinTypeScriptScalars[t.Name]; !ok {
+			if s.referencedTypes != nil {
+				s.referencedTypes[t.Name] = struct{}{}
+			}
+			ret = t.Name
+		}
+	case schema.NamedType:
+		if s.referencedTypes != nil {
+			s.referencedTypes[t.TypeName()] = struct{}{}
+		}
+		ret = t.TypeName()
+	}
+
+	if nullable {
+		ret += " | null"
+	}
+	return ret
+}
+
+func (s *typeScriptState) writeSchemaFields(fields map[string]*schema.FieldDefinition) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		if !strings.HasPrefix(name, "__") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field := fields[name]
+		description := field.Description
+		if field.DeprecationReason != "" {
+			description = strings.TrimSpace(description + "\n\n@deprecated " + field.DeprecationReason)
+		}
+		s.writeDescription("  ", description)
+		fmt.Fprintf(&s.output, "  %v: %v;\n", name, s.schemaFieldType(field.Type))
+	}
+}
+
+func (s *typeScriptState) schemaFieldType(t schema.Type) string {
+	nullable := true
+	if nonNull, ok := t.(*schema.NonNullType); ok {
+		t = nonNull.Type
+		nullable = false
+	}
+
+	var ret string
+	switch t := t.(type) {
+	case *schema.ListType:
+		ret = "Array<" + s.schemaFieldType(t.Type) + ">"
+	case *schema.ScalarType:
+		ret = s.scalarType(t.Name)
+		if _, ok := builtinTypeScriptScalars[t.Name]; !ok {
+			ret = t.Name
+		}
+	case schema.NamedType:
+		ret = t.TypeName()
+	}
+
+	if nullable {
+		ret += " | null"
+	}
+	return ret
+}
+
+func (s *typeScriptState) writeDocument(doc *ast.Document) {
+	s.fragments = map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok {
+			s.fragments[def.Name.Name] = def
+		}
+	}
+
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.OperationDefinition:
+			if def.Name == nil {
+				continue
+			}
+			t := s.schema.QueryType()
+			if def.OperationType != nil {
+				switch def.OperationType.Value {
+				case "mutation":
+					t = s.schema.MutationType()
+				case "subscription":
+					t = s.schema.SubscriptionType()
+				}
+			}
+			fmt.Fprintf(&s.output, "\nexport type %vData = %v;\n", def.Name.Name, s.selectionSetType(t, def.SelectionSet.Selections, ""))
+
+			fmt.Fprintf(&s.output, "\nexport interface %vVariables {\n", def.Name.Name)
+			for _, v := range def.VariableDefinitions {
+				s.writeInputField(v.Variable.Name.Name, s.schemaType(v.Type), v.DefaultValue != nil)
+			}
+			s.output.WriteString("}\n")
+		case *ast.FragmentDefinition:
+			t := s.schema.NamedTypes()[def.TypeCondition.Name.Name]
+			fmt.Fprintf(&s.output, "\nexport type %vFragment = %v;\n", def.Name.Name, s.selectionSetType(t, def.SelectionSet.Selections, ""))
+		}
+	}
+}
+
+// Converts a type from a document to the corresponding schema type.
+func (s *typeScriptState) schemaType(t ast.Type) schema.Type {
+	switch t := t.(type) {
+	case *ast.NonNullType:
+		return schema.NewNonNullType(s.schemaType(t.Type))
+	case *ast.ListType:
+		return schema.NewListType(s.schemaType(t.Type))
+	case *ast.NamedType:
+		return s.schema.NamedTypes()[t.Name.Name]
+	}
+	return nil
+}
+
+// Returns the type of a selection set on a composite type. For abstract types, this is a union of
+// the selection's type for each possible object type.
+func (s *typeScriptState) selectionSetType(t schema.NamedType, selections []ast.Selection, indent string) string {
+	var possibleTypes []*schema.ObjectType
+	switch t := t.(type) {
+	case *schema.ObjectType:
+		possibleTypes = []*schema.ObjectType{t}
+	case *schema.InterfaceType:
+		possibleTypes = s.schema.InterfaceImplementations(t.Name)
+	case *schema.UnionType:
+		possibleTypes = t.MemberTypes
+	}
+
+	// Object types that have the same selections are grouped together so that their only
+	// difference is the value of __typename.
+	var shapes []string
+	typenames := map[string][]string{}
+	for _, obj := range possibleTypes {
+		shape := s.objectSelectionType(obj, selections, indent)
+		if _, ok := typenames[shape]; !ok {
+			shapes = append(shapes, shape)
+		}
+		typenames[shape] = append(typenames[shape], strconv.Quote(obj.Name))
+	}
+	if len(shapes) == 0 {
+		return "never"
+	}
+
+	ret := make([]string, len(shapes))
+	for i, shape := range shapes {
+		sort.Strings(typenames[shape])
+		ret[i] = strings.ReplaceAll(shape, typeScriptTypenamePlaceholder, strings.Join(typenames[shape], " | "))
+	}
+	sort.Strings(ret)
+	return strings.Join(ret, " | ")
+}
+
+// Used in place of an object's __typename value so that objects with the same selections can be
+// grouped.
+const typeScriptTypenamePlaceholder = "\x00__typename\x00"
+
+type typeScriptField struct {
+	Name       string
+	Optional   bool
+	Selections []ast.Selection
+}
+
+func (s *typeScriptState) objectSelectionType(obj *schema.ObjectType, selections []ast.Selection, indent string) string {
+	var keys []string
+	fields := map[string]*typeScriptField{}
+	s.collectFields(obj, selections, false, func(key string, field *ast.Field, optional bool) {
+		f, ok := fields[key]
+		if !ok {
+			f = &typeScriptField{
+				Name:     field.Name.Name,
+				Optional: optional,
+			}
+			fields[key] = f
+			keys = append(keys, key)
+		}
+		f.Optional = f.Optional && optional
+		if field.SelectionSet != nil {
+			f.Selections = append(f.Selections, field.SelectionSet.Selections...)
+		}
+	})
+
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, key := range keys {
+		field := fields[key]
+		var fieldType string
+		if field.Name == "__typename" {
+			fieldType = typeScriptTypenamePlaceholder
+		} else {
+			fieldType = s.outputType(obj.Fields[field.Name].Type, field.Selections, indent+"  ")
+		}
+		optional := ""
+		if field.Optional {
+			optional = "?"
+		}
+		fmt.Fprintf(&b, "%v  %v%v: %v;\n", indent, key, optional, fieldType)
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// Invokes f for each field that would be selected for the given object type. Fields are optional
+// if they may be omitted due to @skip or @include directives.
+func (s *typeScriptState) collectFields(obj *schema.ObjectType, selections []ast.Selection, optional bool, f func(key string, field *ast.Field, optional bool)) {
+	for _, sel := range selections {
+		selOptional := optional || hasConditionalDirective(sel.SelectionDirectives())
+		switch sel := sel.(type) {
+		case *ast.Field:
+			key := sel.Name.Name
+			if sel.Alias != nil {
+				key = sel.Alias.Name
+			}
+			f(key, sel, selOptional)
+		case *ast.InlineFragment:
+			if sel.TypeCondition == nil || s.typeConditionApplies(obj, sel.TypeCondition.Name.Name) {
+				s.collectFields(obj, sel.SelectionSet.Selections, selOptional, f)
+			}
+		case *ast.FragmentSpread:
+			if def, ok := s.fragments[sel.FragmentName.Name]; ok && s.typeConditionApplies(obj, def.TypeCondition.Name.Name) {
+				s.collectFields(obj, def.SelectionSet.Selections, selOptional, f)
+			}
+		}
+	}
+}
+
+func hasConditionalDirective(directives []*ast.Directive) bool {
+	for _, directive := range directives {
+		if directive.Name.Name == "skip" || directive.Name.Name == "include" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *typeScriptState) typeConditionApplies(obj *schema.ObjectType, condition string) bool {
+	switch t := s.schema.NamedTypes()[condition].(type) {
+	case *schema.ObjectType:
+		return t == obj
+	case *schema.InterfaceType:
+		for _, iface := range obj.ImplementedInterfaces {
+			if iface == t {
+				return true
+			}
+		}
+	case *schema.UnionType:
+		for _, member := range t.MemberTypes {
+			if member == obj {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *typeScriptState) outputType(t schema.Type, selections []ast.Selection, indent string) string {
+	nullable := true
+	if nonNull, ok := t.(*schema.NonNullType); ok {
+		t = nonNull.Type
+		nullable = false
+	}
+
+	var ret string
+	switch t := t.(type) {
+	case *schema.ListType:
+		ret = "Array<" + s.outputType(t.Type, selections, indent) + ">"
+	case *schema.ScalarType:
+		ret = s.scalarType(t.Name)
+		if _, ok := builtinTypeScriptScalars[t.Name]; !ok {
+			s.referencedTypes[t.Name] = struct{}{}
+			ret = t.Name
+		}
+	case *schema.EnumType:
+		s.referencedTypes[t.Name] = struct{}{}
+		ret = t.Name
+	case schema.NamedType:
+		ret = s.selectionSetType(t, selections, indent)
+	}
+
+	if nullable {
+		ret += " | null"
+	}
+	return ret
+}
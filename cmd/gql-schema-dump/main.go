@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"plugin"
+
+	"github.com/spf13/pflag"
+
+	apifu "github.com/ccbrown/api-fu"
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/sdl"
+)
+
+// LoadSDLSchema loads a schema from an SDL file.
+func LoadSDLSchema(path string) (*graphql.Schema, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	def, err := sdl.ParseSchemaDefinition(src)
+	if err != nil {
+		return nil, err
+	}
+	return graphql.NewSchema(def)
+}
+
+// LoadPluginSchema loads a schema from a Go plugin. The symbol may be a *graphql.Schema variable,
+// an apifu.Config or *apifu.Config variable, or a function with the signature
+// func() (*graphql.Schema, error).
+func LoadPluginSchema(path, symbol string) (*graphql.Schema, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return schemaFromSymbol(sym)
+}
+
+func schemaFromSymbol(sym interface{}) (*graphql.Schema, error) {
+	switch sym := sym.(type) {
+	case **graphql.Schema:
+		return *sym, nil
+	case *graphql.Schema:
+		return sym, nil
+	case **apifu.Config:
+		return schemaFromSymbol(*sym)
+	case *apifu.Config:
+		api, err := apifu.NewAPI(sym)
+		if err != nil {
+			return nil, err
+		}
+		return api.Schema(), nil
+	case func() (*graphql.Schema, error):
+		return sym()
+	}
+	return nil, fmt.Errorf("unsupported symbol type: %T", sym)
+}
+
+func Run(w io.Writer, args ...string) []error {
+	flags := pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
+
+	sdlPath := flags.String("sdl", "", "the path to an sdl file to load the schema from")
+	pluginPath := flags.String("plugin", "", "the path to a go plugin to load the schema from")
+	symbol := flags.String("symbol", "Schema", "the name of the plugin symbol that provides the schema")
+	indent := flags.Bool("indent", true, "whether to indent the output")
+	flags.Parse(args)
+
+	var schema *graphql.Schema
+	var err error
+	switch {
+	case *sdlPath != "" && *pluginPath != "":
+		return []error{fmt.Errorf("only one of the --sdl or --plugin flags may be given")}
+	case *sdlPath != "":
+		schema, err = LoadSDLSchema(*sdlPath)
+	case *pluginPath != "":
+		schema, err = LoadPluginSchema(*pluginPath, *symbol)
+	default:
+		return []error{fmt.Errorf("either the --sdl or --plugin flag is required")}
+	}
+	if err != nil {
+		return []error{fmt.Errorf("error loading schema: %w", err)}
+	}
+
+	output, err := graphql.IntrospectionJSON(schema)
+	if err != nil {
+		return []error{fmt.Errorf("error introspecting schema: %w", err)}
+	}
+	if *indent {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, output, "", "  "); err != nil {
+			return []error{fmt.Errorf("error formatting result: %w", err)}
+		}
+		output = buf.Bytes()
+	}
+
+	fmt.Fprintln(w, string(output))
+	return nil
+}
+
+func main() {
+	if errs := Run(os.Stdout, os.Args[1:]...); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+		os.Exit(1)
+	}
+}
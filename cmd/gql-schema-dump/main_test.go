@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apifu "github.com/ccbrown/api-fu"
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/schema/introspection"
+)
+
+func TestRun(t *testing.T) {
+	var buf bytes.Buffer
+	require.Empty(t, Run(&buf, "--sdl", "testdata/schema.graphql"))
+
+	var result struct {
+		Data struct {
+			Schema introspection.SchemaData `json:"__schema"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &result))
+	assert.Equal(t, "Query", result.Data.Schema.QueryType.Name)
+	require.NotNil(t, result.Data.Schema.MutationType)
+	assert.Equal(t, "Mutation", result.Data.Schema.MutationType.Name)
+
+	var typeNames []string
+	for _, t := range result.Data.Schema.Types {
+		typeNames = append(typeNames, t.Name)
+	}
+	assert.Subset(t, typeNames, []string{"Query", "Mutation", "Node", "Named", "User", "Post", "SearchResult", "Status", "Filter", "DateTime"})
+
+	assert.NotEmpty(t, Run(ioutil.Discard))
+	assert.NotEmpty(t, Run(ioutil.Discard, "--sdl", "testdata/schema.graphql", "--plugin", "schema.so"))
+	assert.NotEmpty(t, Run(ioutil.Discard, "--sdl", "testdata/not-the-schema.graphql"))
+	assert.NotEmpty(t, Run(ioutil.Discard, "--plugin", "testdata/not-the-plugin.so"))
+}
+
+func TestSchemaFromSymbol(t *testing.T) {
+	var cfg apifu.Config
+	cfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+	})
+
+	s, err := schemaFromSymbol(&cfg)
+	require.NoError(t, err)
+	assert.NotNil(t, s.QueryType().Fields["foo"])
+
+	s2, err := schemaFromSymbol(func() (*graphql.Schema, error) {
+		return s, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, s, s2)
+
+	s3, err := schemaFromSymbol(&s)
+	require.NoError(t, err)
+	assert.Equal(t, s, s3)
+
+	_, err = schemaFromSymbol(1)
+	assert.Error(t, err)
+}
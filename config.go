@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -22,10 +23,33 @@ type Config struct {
 	// Invoked to get nodes by their global ids.
 	ResolveNodesByGlobalIds func(ctx context.Context, ids []string) ([]interface{}, error)
 
+	// If true, the node and nodes fields only accept ids encoded via EncodeGlobalID or EncodeID for
+	// object types. Other ids are treated as nonexistent and aren't passed to
+	// ResolveNodesByGlobalIds. The remaining ids are passed to ResolveNodesByGlobalIds in separate
+	// invocations for each type, and resolved nodes whose types don't match their invocation's type
+	// are also treated as nonexistent, so an id of one type can't be used to fetch an object of
+	// another.
+	StrictNodeIDs bool
+
 	// If given, Apollo persisted queries are supported by the API:
 	// https://www.apollographql.com/docs/react/api/link/persisted-queries/
+	//
+	// Otherwise, requests that only contain a persisted query hash receive an error with a "code"
+	// of "PERSISTED_QUERY_NOT_SUPPORTED".
+	//
+	// Validation results for persisted queries are cached even if DocumentCacheSize isn't
+	// positive. See DocumentCacheSize.
 	PersistedQueryStorage PersistedQueryStorage
 
+	// If given, persisted query hits, misses, and document cache evictions are reported here. This
+	// can be used to log hashes that miss, which may indicate stale clients. Counts of these events
+	// are also available via API.PersistedQueryStats.
+	PersistedQueryMetrics PersistedQueryMetrics
+
+	// Operations that clients can invoke by name via API.ServeRegisteredOperation, keyed by name.
+	// See RegisteredOperation.
+	RegisteredOperations map[string]*RegisteredOperation
+
 	// When calculating field costs, this is used as the default. This is typically either
 	// `graphql.FieldCost{Resolver: 1}` or left as zero.
 	DefaultFieldCost graphql.FieldCost
@@ -39,9 +63,36 @@ type Config struct {
 	// payload. If an error is returned, it will be sent to the client and the connection will be
 	// closed. Otherwise the returned context will become associated with the connection.
 	//
-	// This is commonly used for authentication.
+	// This is commonly used for authentication. To send the client a payload with the
+	// connection_ack message, such as session data, return a context from WithGraphQLWSAckPayload.
 	HandleGraphQLWSInit func(ctx context.Context, parameters json.RawMessage) (context.Context, error)
 
+	// The interval at which WebSocket keep-alive messages are sent to clients. If zero, the default
+	// of 15 seconds is used. If negative, keep-alive messages aren't sent.
+	GraphQLWSKeepAliveInterval time.Duration
+
+	// If positive, WebSocket results whose encoded payloads exceed this many bytes are replaced with
+	// a result containing a single error whose "code" extension is "PAYLOAD_TOO_LARGE".
+	GraphQLWSMaxPayloadSize int
+
+	// If positive, WebSocket results whose encoded payloads exceed this many bytes are split into
+	// multiple "chunk" messages so that they don't stall other operations on the connection. This is
+	// an extension to the protocols, so it's only done for connections whose clients opt in via
+	// their init payloads. See transport.PayloadChunk.
+	GraphQLWSPayloadChunkSize int
+
+	// If given, this function is invoked before a WebSocket connection is upgraded. It can
+	// authenticate the connection via cookies or headers such as Authorization, which is useful for
+	// clients that can't put credentials in the init payload. Browsers can't set headers on
+	// WebSocket requests, but they can pass credentials as additional subprotocols, which are
+	// available via websocket.Subprotocols(r).
+	//
+	// If an error is returned, the connection is rejected before the upgrade. If the error is an
+	// *HTTPError, its status code is used. Otherwise the status code is 403 Forbidden. If no error
+	// is returned, the returned context becomes associated with the connection and is passed to
+	// HandleGraphQLWSInit. If the returned context is nil, the request's context is used.
+	HandleGraphQLWSUpgrade func(r *http.Request) (context.Context, error)
+
 	// Explicitly adds named types to the schema. This is generally only required for interface
 	// implementations that aren't explicitly referenced elsewhere in the schema.
 	AdditionalTypes map[string]graphql.NamedType
@@ -54,6 +105,193 @@ type Config struct {
 	// If given, this function will be invoked to get the feature set for a request.
 	Features func(ctx context.Context) graphql.FeatureSet
 
+	// If non-empty, types and fields that require this feature are internal. Requests served by
+	// InternalGraphQLHandler and InternalGraphQLWSHandler always have the feature, and all other
+	// requests never do, regardless of Features. This provides a public view of the schema and an
+	// internal superset of it, both backed by the same resolvers.
+	//
+	// Internal types and fields are tagged via RequiredFeatures, e.g.
+	// `RequiredFeatures: graphql.NewFeatureSet(cfg.InternalFeature)`.
+	InternalFeature string
+
+	// If true, responses will include a "nulledFields" extension describing the nullable fields
+	// that were set to null due to errors. See graphql.NulledField.
+	ReportNulledFields bool
+
+	// If true, responses will include a "deprecations" extension describing the deprecated fields
+	// and enum values used by the operation. See graphql.DeprecationWarning.
+	ReportDeprecations bool
+
+	// If true and the schema has warnings, such as unreachable types, responses will include a
+	// "schemaWarnings" extension describing them. This is useful during development. See
+	// graphql.Request.ReportSchemaWarnings.
+	ReportSchemaWarnings bool
+
+	// If positive, up to this many parsed and validated documents are cached, keyed by query text
+	// and feature set, so that repeated queries skip parsing and most of validation. Validation
+	// rules that depend on the request, such as cost limits, are still applied to cached
+	// documents. Documents that fail validation aren't cached.
+	//
+	// Operation costs that don't depend on variables are also cached, so the per-request overhead
+	// for such documents is little more than execution.
+	DocumentCacheSize int
+
+	// If true, __typename is selected for every field with a selection set, as if clients had
+	// requested it. This is useful when the API is an internal composition layer feeding
+	// normalized caches that require typenames. See graphql.InjectTypename.
+	InjectTypename bool
+
+	// If true, redundant selections, such as fields selected both directly and via fragments, are
+	// removed from documents before they're executed. This reduces execution overhead for heavily
+	// fragment-composed documents, such as those generated by Relay. The transformation is cached
+	// along with the document if DocumentCacheSize is positive or the query is persisted. See
+	// graphql.DeduplicateSelections.
+	DeduplicateSelections bool
+
+	// If given, operations whose costs exceed these limits are rejected. The keys are dimension
+	// names as used by graphql.FieldCost.Dimensions. Dimensions without limits are unlimited.
+	MaxCostDimensions map[string]int
+
+	// If true, responses will include a "cost" extension with the operation's calculated cost and
+	// the costs for each dimension.
+	ReportCost bool
+
+	// If true, resolvers can report the costs they actually incur via ReportCost, and the
+	// operation's adjusted cost is made available via RequestInfo.ActualCost. If ReportCost is also
+	// true, the "cost" extension includes it as "actual". This adds a small amount of overhead to
+	// every field.
+	TrackActualCost bool
+
+	// If true, operations that use fields whose sunset time has passed are rejected. See
+	// graphql.FieldDefinition.SunsetTime.
+	EnforceSunsets bool
+
+	// If positive, at most this many errors are included in each response. Responses with omitted
+	// errors will include a "suppressedErrors" extension with the number of errors that were
+	// omitted. This prevents pathological queries, such as ones for large lists where every item
+	// produces an error, from generating enormous responses.
+	MaxErrors int
+
+	// If positive, error messages longer than this many characters are truncated.
+	MaxErrorMessageLength int
+
+	// Authorization policies that can be referenced by fields. See Policy.
+	Policies map[string]*Policy
+
+	// Policies that every operation served via HTTP or WebSockets must satisfy, applied in order
+	// after validation and before execution. See RequestPolicy.
+	RequestPolicies []RequestPolicy
+
+	// If given, clients may subscribe via HTTP callbacks. See API.ServeWebhookSubscriptions.
+	Webhooks *WebhookConfig
+
+	// If positive, clients may request a deadline for their operations via a "timeoutMs" extension
+	// or, for HTTP requests, the X-GraphQL-Timeout-Ms header. Requested timeouts are capped at this
+	// value. The deadline is applied to the context passed to resolvers, and if it's exceeded, the
+	// response includes an error with a "code" of "TIMEOUT".
+	//
+	// This allows interactive clients to fail faster than batch clients.
+	MaxClientTimeout time.Duration
+
+	// If positive, this limits the number of goroutines started by Go and GoCancelable that may run
+	// concurrently for a single request. Additional work is queued until a goroutine becomes
+	// available. Without a limit, an operation that selects thousands of asynchronous fields
+	// spawns thousands of goroutines.
+	MaxConcurrentGoroutines int
+
+	// If true, PageInfo's startCursor and endCursor fields are nullable and are null for empty
+	// pages, as required by the latest Relay specification. Otherwise they're non-null and are empty
+	// strings for empty pages. Changing this for an existing API is a breaking change for clients.
+	NullablePageInfoCursors bool
+
+	// Handlers for top-level request extensions, keyed by extension name. Handlers are invoked before
+	// execution for both HTTP and WebSocket requests, and may modify the request's context or reject
+	// it. See ExtensionHandler.
+	ExtensionHandlers map[string]ExtensionHandler
+
+	// If given, this is invoked with the context of each subscription as it starts. The returned
+	// attributes, such as the id of the authenticated user, are included in the snapshots returned
+	// by API.Subscriptions.
+	SubscriptionAttributes func(ctx context.Context) map[string]string
+
+	// If true, WebSocket clients may opt in to receiving subscription payloads as deltas by
+	// including a "subscriptionDeltas" extension with a value of "json-patch" when they subscribe.
+	// The first payload is delivered in full. Each subsequent payload omits its data and instead has
+	// a "patch" extension containing RFC 6902 JSON Patch operations that transform the previous
+	// payload's data into the new data. Payloads with errors are always delivered in full, and the
+	// payload after one is delivered in full as well.
+	//
+	// This can dramatically reduce bandwidth for large subscriptions whose results rarely change.
+	SubscriptionDeltas bool
+
+	// If given, operations can be executed in explain mode. See ExplainConfig.
+	Explain *ExplainConfig
+
+	// If given, this is used to decode HTTP requests and encode responses for ServeGraphQL and
+	// webhooks. By default, requests are decoded using encoding/json and responses are encoded
+	// using jsoniter.
+	JSONCodec graphql.JSONCodec
+
+	// If true, ServeGraphQL and ServeGraphQLWS use MessagePack or CBOR instead of JSON for clients
+	// whose Accept headers prefer application/msgpack or application/cbor. For WebSocket connections,
+	// the header of the upgrade request is used, and outgoing messages are sent as binary frames.
+	// See graphql.MarshalMsgpack and graphql.MarshalCBOR.
+	//
+	// This is intended for high-throughput internal consumers. Browsers generally can't set the
+	// headers needed to opt in.
+	BinaryResponseEncodings bool
+
+	// If given, this determines the HTTP status code of each response that ServeGraphQL writes for
+	// a single operation. Otherwise, the status is always 200. graphql.ResponseHTTPStatus implements
+	// the conventional mapping, e.g. 400 for validation errors and 200 for partial data.
+	//
+	// JSON responses use application/graphql-response+json instead of application/json for clients
+	// that accept it. Clients that only accept application/json may not expect non-2xx statuses,
+	// so the function typically checks the request's Accept header. See
+	// graphql.NegotiateJSONContentType.
+	HTTPStatus func(r *http.Request, resp *graphql.Response) int
+
+	// If true, ServeGraphQL follows the GraphQL over HTTP specification more strictly:
+	//
+	//   - Requests whose Accept headers don't accept any supported media type are rejected with 406
+	//     Not Acceptable.
+	//   - POST requests with unsupported content types or charsets other than UTF-8 are rejected
+	//     with 415 Unsupported Media Type.
+	//   - GET requests for operations other than queries are rejected with 405 Method Not Allowed.
+	//   - Unless HTTPStatus is given, application/graphql-response+json responses use the status
+	//     given by graphql.ResponseHTTPStatus. So requests that fail before execution, e.g. due to
+	//     validation errors, get 4xx statuses, while responses with data get 200.
+	//   - JSON responses specify the UTF-8 charset in their Content-Type headers.
+	StrictGraphQLOverHTTP bool
+
+	// If given, responses to queries executed via GET requests can be cached by HTTP caches such
+	// as CDNs. See CacheableGETConfig.
+	CacheableGET *CacheableGETConfig
+
+	// If given, the fields selected by each executed operation are recorded here. This enables
+	// API.DeadFieldReport.
+	FieldUsageMetrics FieldUsageMetrics
+
+	// If true, ServeGraphQL executes every operation in a document that contains multiple named
+	// operations when no operation name is given. Operations are executed in the order they're
+	// defined, and the response is a JSON object mapping each operation's name to its result
+	// instead of a single result. Each result is the same as the response that would be returned if
+	// the operation were requested by name, so validation errors in the document are reported for
+	// every operation. This is intended for batch-oriented tooling.
+	ExecuteAllOperations bool
+
+	// If given, this is used as the source of the current time instead of the system clock, e.g. when
+	// enforcing sunsets or recording field usage. Additionally, results of scalars created by
+	// NewDateTimeType are converted to the location of the clock's times before they're
+	// serialized, and the time ranges given to TimeBasedConnectionConfig.EdgeGetter are in that
+	// location as well. UTCClock can be used to normalize all times to UTC, and FakeClock can be
+	// used in tests.
+	Clock Clock
+
+	// If given, large cursors of connections are compressed. Compressed cursors are only accepted
+	// while this is configured.
+	CursorCompression *CursorCompressionConfig
+
 	initOnce      sync.Once
 	nodeInterface *graphql.InterfaceType
 	query         *graphql.ObjectType
@@ -96,10 +334,20 @@ func (cfg *Config) init() {
 					Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
 						// TODO: batching?
 						if id, ok := ctx.Arguments["id"].(string); ok {
-							nodes, err := ctxAPI(ctx.Context).config.ResolveNodesByGlobalIds(ctx.Context, []string{id})
-							if err != nil || len(nodes) == 0 {
+							api := ctxAPI(ctx.Context)
+							var nodes []interface{}
+							var err error
+							if api.config.StrictNodeIDs {
+								nodes, err = api.resolveStrictNodes(ctx.Context, []string{id})
+							} else {
+								nodes, err = api.config.ResolveNodesByGlobalIds(ctx.Context, []string{id})
+							}
+							if err != nil {
 								return nil, err
 							}
+							if len(nodes) == 0 {
+								return nil, nil
+							}
 							return nodes[0], nil
 						} else {
 							return nil, nil
@@ -129,7 +377,11 @@ func (cfg *Config) init() {
 								ids = append(ids, id)
 							}
 						}
-						return ctxAPI(ctx.Context).config.ResolveNodesByGlobalIds(ctx.Context, ids)
+						api := ctxAPI(ctx.Context)
+						if !api.config.StrictNodeIDs {
+							return api.config.ResolveNodesByGlobalIds(ctx.Context, ids)
+						}
+						return api.resolveStrictNodes(ctx.Context, ids)
 					},
 				},
 			},
@@ -148,8 +400,9 @@ func (cfg *Config) graphqlSchemaDefinition() (*graphql.SchemaDefinition, error)
 		Subscription:    cfg.subscription,
 		AdditionalTypes: additionalTypes,
 		Directives: map[string]*graphql.DirectiveDefinition{
-			"include": graphql.IncludeDirective,
-			"skip":    graphql.SkipDirective,
+			"include":     graphql.IncludeDirective,
+			"skip":        graphql.SkipDirective,
+			"specifiedBy": graphql.SpecifiedByDirective,
 		},
 	}
 	if cfg.PreprocessGraphQLSchemaDefinition != nil {
@@ -166,6 +419,22 @@ func (cfg *Config) graphqlSchema() (*graphql.Schema, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := validateConnectionFields(def); err != nil {
+		return nil, err
+	}
+	if cfg.NullablePageInfoCursors {
+		def = def.Clone()
+		makePageInfoCursorsNullable(def)
+	}
+	if cfg.Clock != nil {
+		def = normalizeDateTimes(def, cfg.Clock)
+	}
+	if hasPolicies(def) {
+		def = def.Clone()
+		if err := applyPolicies(def, cfg.Policies); err != nil {
+			return nil, err
+		}
+	}
 	return graphql.NewSchema(def)
 }
 
@@ -226,6 +495,9 @@ func (cfg *Config) AddMutation(name string, def *graphql.FieldDefinition) {
 //	        return nil, fmt.Errorf("Subscriptions are not supported using this protocol.")
 //	    }
 //	},
+//
+// If clients should receive the current state as soon as they subscribe, set the source stream's
+// InitialEvent function.
 func (cfg *Config) AddSubscription(name string, def *graphql.FieldDefinition) {
 	cfg.init()
 
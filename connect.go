@@ -0,0 +1,191 @@
+package apifu
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/transport/connect"
+)
+
+// ServeConnect serves GraphQL requests via the Connect protocol, as implemented by the
+// graphql/transport/connect package. Queries and mutations are executed via the unary Execute
+// procedure, and subscriptions via the server streaming Subscribe procedure. Requests are routed
+// by the suffix of their path, so the service can be mounted under any prefix.
+//
+// This is intended for service-to-service callers that standardize on gRPC-style infrastructure.
+// Only Connect's JSON codec is supported.
+func (api *API) ServeConnect(w http.ResponseWriter, r *http.Request) {
+	var streaming bool
+	switch {
+	case strings.HasSuffix(r.URL.Path, connect.ExecuteProcedure):
+	case strings.HasSuffix(r.URL.Path, connect.SubscribeProcedure):
+		streaming = true
+	default:
+		connect.WriteUnaryError(w, &connect.Error{
+			Code:    connect.CodeUnimplemented,
+			Message: "Unknown procedure.",
+		})
+		return
+	}
+
+	codec := api.jsonCodec()
+	message, connectErr := connect.ReadRequest(r, streaming, 0, codec.Unmarshal)
+	if connectErr != nil {
+		connect.WriteUnaryError(w, connectErr)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), apiContextKey, api)
+	apiRequest := &apiRequest{}
+	ctx = context.WithValue(ctx, apiRequestContextKey, apiRequest)
+
+	var resp *graphql.Response
+	if ctx, resp = api.handleExtensions(ctx, message.Extensions); resp == nil {
+		req := &graphql.Request{
+			Context:        ctx,
+			Query:          message.Query,
+			Schema:         api.schema,
+			IdleHandler:    apiRequest.IdleHandler,
+			OperationName:  message.OperationName,
+			VariableValues: message.Variables,
+			Extensions:     message.Extensions,
+
+			IdleHandlerStallLimit: idleHandlerStallLimit,
+			CancelPromise:         apiRequest.CancelPromise,
+			ReportNulledFields:    api.config.ReportNulledFields,
+			ReportDeprecations:    api.config.ReportDeprecations,
+			ReportSchemaWarnings:  api.config.ReportSchemaWarnings,
+			MaxErrors:             api.config.MaxErrors,
+			MaxErrorMessageLength: api.config.MaxErrorMessageLength,
+		}
+		req.Features = api.requestFeatures(ctx)
+		applyClientTimeoutHeader(req, r)
+
+		// Subscriptions write their own responses.
+		handled := false
+		execute := func(req *graphql.Request) *graphql.Response {
+			var info RequestInfo
+			doc, errs := api.parseAndValidate(req, &info)
+			if len(errs) == 0 {
+				errs = api.applyRequestPolicies(r, req, doc)
+			}
+			if len(errs) > 0 {
+				return &graphql.Response{
+					Errors: errs,
+				}
+			}
+			req.Document = doc
+			if !graphql.IsSubscription(doc, req.OperationName) {
+				return api.execute(req, &info)
+			} else if !streaming {
+				return &graphql.Response{
+					Errors: []*graphql.Error{{Message: "Subscriptions must be executed via the Subscribe procedure."}},
+				}
+			}
+			handled = true
+			api.serveConnectSubscription(w, req, &info)
+			return nil
+		}
+		resp = PersistedQueryExtension(api.persistedQueryStorage(), execute)(req)
+		if handled {
+			return
+		}
+	}
+
+	body, err := codec.Marshal(resp)
+	if err != nil {
+		connect.WriteUnaryError(w, &connect.Error{
+			Code:    connect.CodeInternal,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if !streaming {
+		connect.WriteUnaryResponse(w, body)
+		return
+	}
+	stream := connect.NewStreamWriter(w)
+	if err := stream.WriteMessage(body); err != nil {
+		return
+	}
+	stream.Close(nil)
+}
+
+// Runs a validated subscription request, streaming a response for each event until the client
+// disconnects, the source stream ends, or the subscription is terminated.
+func (api *API) serveConnectSubscription(w http.ResponseWriter, req *graphql.Request, info *RequestInfo) {
+	codec := api.jsonCodec()
+	stream := connect.NewStreamWriter(w)
+
+	id, err := newRandomId()
+	if err != nil {
+		stream.Close(&connect.Error{
+			Code:    connect.CodeInternal,
+			Message: "Unable to generate a subscription id.",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(req.Context)
+	defer cancel()
+	subscriptionInfo := newSubscriptionInfo(id, "", req.Query, req.Document, req.OperationName)
+	req.Context = context.WithValue(ctx, subscriptionInfoContextKey, subscriptionInfo)
+
+	sourceStream, errs := graphql.Subscribe(req)
+	if len(errs) > 0 {
+		if body, err := codec.Marshal(&graphql.Response{Errors: errs}); err == nil {
+			stream.WriteMessage(body)
+		}
+		stream.Close(nil)
+		return
+	}
+	sourceStreamIn := sourceStream.(*SubscriptionSourceStream)
+	defer sourceStreamIn.stop(subscriptionInfo)
+
+	unregister := api.registerSubscription(req.Context, id, subscriptionInfo, cancel)
+	defer unregister()
+
+	if err := sourceStreamIn.Run(ctx, func(event any) {
+		req := *req
+		req.InitialValue = event
+		body, err := codec.Marshal(api.execute(&req, info))
+		if err != nil {
+			api.logger.Error(errors.Wrap(err, "error marshaling connect response"))
+			return
+		}
+		if err := stream.WriteMessage(body); err != nil {
+			// The client is gone.
+			cancel()
+		}
+	}); err != nil && err != context.Canceled {
+		api.logger.Error(errors.Wrap(err, "error running source stream"))
+		stream.Close(&connect.Error{
+			Code: connect.CodeInternal,
+		})
+		return
+	}
+	stream.Close(nil)
+}
+
+// ConnectHandler returns an http.Handler that serves Connect requests via ServeConnect. Only POST
+// requests are supported, so AllowedMethods is ignored.
+func (api *API) ConnectHandler(opts *HandlerOptions) http.Handler {
+	connectOpts := &HandlerOptions{
+		AllowedMethods: []string{http.MethodPost},
+	}
+	if opts != nil {
+		connectOpts.CORS = opts.CORS
+		connectOpts.MaxBodySize = opts.MaxBodySize
+		connectOpts.ReadOnly = opts.ReadOnly
+		connectOpts.DisallowSubscriptions = opts.DisallowSubscriptions
+	}
+	return &apiHandler{
+		options: connectOpts,
+		serve:   api.ServeConnect,
+	}
+}
@@ -0,0 +1,96 @@
+package apifu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/transport/connect"
+)
+
+func connectEnvelope(flags byte, message string) []byte {
+	buf := make([]byte, 5+len(message))
+	buf[0] = flags
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(message)))
+	copy(buf[5:], message)
+	return buf
+}
+
+func TestServeConnect(t *testing.T) {
+	var testCfg Config
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.BooleanType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return true, nil
+		},
+	})
+	testCfg.AddSubscription("oneEvent", oneEventSubscription)
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+	handler := api.ConnectHandler(nil)
+
+	do := func(procedure, contentType string, body []byte) *http.Response {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("POST", "/rpc"+procedure, bytes.NewReader(body))
+		require.NoError(t, err)
+		r.Header.Set("Content-Type", contentType)
+		handler.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	t.Run("Execute", func(t *testing.T) {
+		resp := do(connect.ExecuteProcedure, connect.UnaryContentType, []byte(`{"query":"{foo}"}`))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.JSONEq(t, `{"data":{"foo":true}}`, string(body))
+	})
+
+	t.Run("ExecuteSubscription", func(t *testing.T) {
+		resp := do(connect.ExecuteProcedure, connect.UnaryContentType, []byte(`{"query":"subscription {oneEvent}"}`))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), "Subscribe procedure")
+	})
+
+	t.Run("Subscribe", func(t *testing.T) {
+		resp := do(connect.SubscribeProcedure, connect.StreamingContentType, connectEnvelope(0, `{"query":"subscription {oneEvent}"}`))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, connect.StreamingContentType, resp.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, append(
+			connectEnvelope(0, `{"data":{"oneEvent":1}}`),
+			connectEnvelope(0x02, `{}`)...,
+		), body)
+	})
+
+	t.Run("SubscribeQuery", func(t *testing.T) {
+		resp := do(connect.SubscribeProcedure, connect.StreamingContentType, connectEnvelope(0, `{"query":"{foo}"}`))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, append(
+			connectEnvelope(0, `{"data":{"foo":true}}`),
+			connectEnvelope(0x02, `{}`)...,
+		), body)
+	})
+
+	t.Run("UnknownProcedure", func(t *testing.T) {
+		resp := do("/foo.Bar/Baz", connect.UnaryContentType, []byte(`{"query":"{foo}"}`))
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.True(t, strings.Contains(string(body), `"code":"unimplemented"`))
+	})
+
+	t.Run("WrongContentType", func(t *testing.T) {
+		resp := do(connect.ExecuteProcedure, "application/proto", []byte(`{"query":"{foo}"}`))
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	})
+}
@@ -0,0 +1,207 @@
+package apifu
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack"
+)
+
+// CursorCompressor compresses serialized cursors. See CursorCompressionConfig.
+//
+// Decompress is given data from clients, so it must limit the size of its output.
+type CursorCompressor interface {
+	Compress(b []byte) ([]byte, error)
+	Decompress(b []byte) ([]byte, error)
+}
+
+type deflateCursorCompressor struct{}
+
+func (deflateCursorCompressor) Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// The maximum size of a cursor decompressed by DeflateCursorCompressor.
+const maxDecompressedCursorSize = 64 * 1024
+
+func (deflateCursorCompressor) Decompress(b []byte) ([]byte, error) {
+	ret, err := ioutil.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(b)), maxDecompressedCursorSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(ret) > maxDecompressedCursorSize {
+		return nil, fmt.Errorf("decompressed cursor exceeds %v bytes", maxDecompressedCursorSize)
+	}
+	return ret, nil
+}
+
+// DeflateCursorCompressor compresses cursors using DEFLATE. Decompressed cursors are limited to
+// 64 KiB.
+var DeflateCursorCompressor CursorCompressor = deflateCursorCompressor{}
+
+// CursorCompressionConfig configures the compression of large cursors. See
+// Config.CursorCompression.
+//
+// Compressed cursors are distinguishable from uncompressed ones, so both are accepted while
+// compression is configured. Compression can be enabled without invalidating cursors that clients
+// already hold, but the compressor must not be changed once compressed cursors have been handed
+// out. If compression is disabled, compressed cursors are rejected.
+type CursorCompressionConfig struct {
+	// If positive, cursors whose binary encoding is at least this many bytes are compressed.
+	// Cursors are only compressed if doing so makes them smaller.
+	Threshold int
+
+	// The compressor to use. If nil, DeflateCursorCompressor is used.
+	Compressor CursorCompressor
+}
+
+func (cfg *CursorCompressionConfig) compressor() CursorCompressor {
+	if cfg.Compressor != nil {
+		return cfg.Compressor
+	}
+	return DeflateCursorCompressor
+}
+
+// SerializeCursor is like the package-level SerializeCursor, but large cursors are compressed. If
+// cfg is nil, cursors aren't compressed.
+func (cfg *CursorCompressionConfig) SerializeCursor(cursor any) (string, error) {
+	b, err := msgpack.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	if cfg != nil && cfg.Threshold > 0 && len(b) >= cfg.Threshold {
+		compressed, err := cfg.compressor().Compress(b)
+		if err != nil {
+			return "", errors.Wrap(err, "error compressing cursor")
+		}
+		if len(compressed) < len(b) {
+			return compressedCursorPrefix + base64.RawURLEncoding.EncodeToString(compressed), nil
+		}
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DeserializeCursor is like the package-level DeserializeCursor, but compressed cursors are
+// accepted as well. If cfg is nil, compressed cursors are rejected.
+func (cfg *CursorCompressionConfig) DeserializeCursor(t reflect.Type, s string) any {
+	compressed := strings.HasPrefix(s, compressedCursorPrefix)
+	if compressed && cfg == nil {
+		return nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, compressedCursorPrefix))
+	if err == nil && compressed {
+		b, err = cfg.compressor().Decompress(b)
+	}
+	if err == nil {
+		ret := reflect.New(t)
+		if err := msgpack.Unmarshal(b, ret.Interface()); err == nil {
+			return ret.Elem().Interface()
+		}
+	}
+	return nil
+}
+
+// Returns the cursor compression configuration of the API serving the request, or nil if there
+// isn't one.
+func ctxCursorCompression(ctx context.Context) *CursorCompressionConfig {
+	if api, _ := ctx.Value(apiContextKey).(*API); api != nil {
+		return api.config.CursorCompression
+	}
+	return nil
+}
+
+// Compressed cursors begin with this prefix, which can't appear in base64url-encoded data.
+const compressedCursorPrefix = "~"
+
+// Returns an upper bound for the size of a value of the given type when encoded with msgpack. If
+// the size can't be bounded, e.g. because the type contains strings or slices, false is returned.
+func maxMsgpackSize(t reflect.Type) (int, bool) {
+	if t == reflect.TypeOf(time.Time{}) {
+		// timestamp 96 extension
+		return 15, true
+	} else if t.Implements(reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()) {
+		return 0, false
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return 1, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return 9, true
+	case reflect.Float32:
+		return 5, true
+	case reflect.Float64:
+		return 9, true
+	case reflect.Ptr:
+		return maxMsgpackSize(t.Elem())
+	case reflect.Array:
+		size, ok := maxMsgpackSize(t.Elem())
+		return 5 + t.Len()*size, ok
+	case reflect.Struct:
+		// Structs are encoded as maps keyed by field name.
+		total := 5
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := field.Name
+			if tag := field.Tag.Get("msgpack"); tag != "" {
+				if tag == "-" {
+					continue
+				} else if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+					name = tagName
+				}
+			}
+			size, ok := maxMsgpackSize(field.Type)
+			if !ok {
+				return 0, false
+			}
+			total += 5 + len(name) + size
+		}
+		return total, true
+	}
+	return 0, false
+}
+
+// Invoked when a connection is defined to check its cursors against MaxCursorLength. The warning,
+// if any, is passed to CursorLengthWarning.
+func checkCursorLength(config *ConnectionConfig) {
+	if config.MaxCursorLength <= 0 || config.CursorType == nil {
+		return
+	}
+
+	var warning string
+	if size, ok := maxMsgpackSize(config.CursorType); !ok {
+		warning = fmt.Sprintf("%vConnection cursors of type %v have no maximum length, so they may exceed the maximum of %v", config.NamePrefix, config.CursorType, config.MaxCursorLength)
+	} else if length := (size*8 + 5) / 6; length > config.MaxCursorLength {
+		warning = fmt.Sprintf("%vConnection cursors of type %v may be up to %v characters long, which exceeds the maximum of %v", config.NamePrefix, config.CursorType, length, config.MaxCursorLength)
+	} else {
+		return
+	}
+
+	if config.CursorLengthWarning != nil {
+		config.CursorLengthWarning(warning)
+	}
+}
@@ -0,0 +1,181 @@
+package apifu
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestCursorCompression(t *testing.T) {
+	type Cursor struct {
+		SortKey string
+		Id      int
+	}
+	cursor := Cursor{
+		SortKey: strings.Repeat("foo", 100),
+		Id:      1,
+	}
+
+	uncompressed, err := SerializeCursor(cursor)
+	require.NoError(t, err)
+
+	cfg := &CursorCompressionConfig{
+		Threshold: 100,
+	}
+
+	compressed, err := cfg.SerializeCursor(cursor)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(compressed, "~"))
+	assert.Less(t, len(compressed), len(uncompressed))
+
+	// Small cursors aren't compressed.
+	small, err := cfg.SerializeCursor(Cursor{Id: 1})
+	require.NoError(t, err)
+	assert.False(t, strings.HasPrefix(small, "~"))
+
+	// Both forms can be deserialized while compression is configured.
+	for _, s := range []string{uncompressed, compressed} {
+		assert.Equal(t, cursor, cfg.DeserializeCursor(reflect.TypeOf(Cursor{}), s))
+	}
+	assert.Nil(t, cfg.DeserializeCursor(reflect.TypeOf(Cursor{}), "~"+uncompressed))
+
+	// Otherwise compressed cursors are rejected.
+	assert.Equal(t, cursor, DeserializeCursor(reflect.TypeOf(Cursor{}), uncompressed))
+	assert.Nil(t, DeserializeCursor(reflect.TypeOf(Cursor{}), compressed))
+}
+
+func TestCursorCompression_DecompressionLimit(t *testing.T) {
+	cfg := &CursorCompressionConfig{
+		Threshold: 1,
+	}
+
+	// A cursor that inflates beyond the limit must be rejected.
+	bomb, err := DeflateCursorCompressor.Compress(make([]byte, maxDecompressedCursorSize+1))
+	require.NoError(t, err)
+	assert.Nil(t, cfg.DeserializeCursor(reflect.TypeOf([]byte{}), "~"+base64.RawURLEncoding.EncodeToString(bomb)))
+	_, err = DeflateCursorCompressor.Decompress(bomb)
+	assert.Error(t, err)
+
+	// Large cursors within the limit are fine.
+	large := strings.Repeat("x", maxDecompressedCursorSize/2)
+	s, err := cfg.SerializeCursor(large)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(s, "~"))
+	assert.Equal(t, large, cfg.DeserializeCursor(reflect.TypeOf(""), s))
+}
+
+func TestConnection_CursorCompression(t *testing.T) {
+	type Cursor struct {
+		SortKey string
+	}
+
+	var testCfg Config
+	testCfg.CursorCompression = &CursorCompressionConfig{
+		Threshold: 1,
+	}
+	var afterCursors []any
+	testCfg.AddQueryField("connection", Connection(&ConnectionConfig{
+		NamePrefix: "Test",
+		EdgeCursor: func(edge any) any {
+			return Cursor{SortKey: strings.Repeat(edge.(string), 50)}
+		},
+		EdgeFields: map[string]*graphql.FieldDefinition{
+			"node": {
+				Type: graphql.NewNonNullType(graphql.StringType),
+				Resolve: func(ctx graphql.FieldContext) (any, error) {
+					return ctx.Object, nil
+				},
+			},
+		},
+		CursorType: reflect.TypeOf(Cursor{}),
+		ResolveEdges: func(ctx graphql.FieldContext, after, before any, limit int) (any, func(a, b any) bool, error) {
+			afterCursors = append(afterCursors, after)
+			return []string{"a"}, func(a, b any) bool {
+				return a.(Cursor).SortKey < b.(Cursor).SortKey
+			}, nil
+		},
+	}))
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	resp := executeGraphQL(t, api, `{connection(first: 1) {edges {cursor}}}`)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	var result struct {
+		Data struct {
+			Connection struct {
+				Edges []struct {
+					Cursor string
+				}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(body, &result))
+	require.Len(t, result.Data.Connection.Edges, 1)
+	cursor := result.Data.Connection.Edges[0].Cursor
+	assert.True(t, strings.HasPrefix(cursor, "~"))
+
+	resp = executeGraphQL(t, api, `{connection(first: 1, after: "`+cursor+`") {edges {cursor}}}`)
+	body, err = ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "errors")
+	assert.Equal(t, []any{nil, Cursor{SortKey: strings.Repeat("a", 50)}}, afterCursors)
+}
+
+func TestConnection_MaxCursorLength(t *testing.T) {
+	type BoundedCursor struct {
+		Time time.Time
+		Id   int64 `msgpack:"id"`
+	}
+	type UnboundedCursor struct {
+		Name string
+		Id   int64
+	}
+
+	for name, tc := range map[string]struct {
+		CursorType      reflect.Type
+		MaxCursorLength int
+		ExpectedWarning string
+	}{
+		"Bounded": {
+			CursorType:      reflect.TypeOf(BoundedCursor{}),
+			MaxCursorLength: 100,
+		},
+		"TooLong": {
+			CursorType:      reflect.TypeOf(BoundedCursor{}),
+			MaxCursorLength: 10,
+			ExpectedWarning: "FooConnection cursors of type apifu.BoundedCursor may be up to 60 characters long, which exceeds the maximum of 10",
+		},
+		"Unbounded": {
+			CursorType:      reflect.TypeOf(UnboundedCursor{}),
+			MaxCursorLength: 100,
+			ExpectedWarning: "FooConnection cursors of type apifu.UnboundedCursor have no maximum length, so they may exceed the maximum of 100",
+		},
+		"Disabled": {
+			CursorType: reflect.TypeOf(UnboundedCursor{}),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var warning string
+			Connection(&ConnectionConfig{
+				NamePrefix:      "Foo",
+				CursorType:      tc.CursorType,
+				MaxCursorLength: tc.MaxCursorLength,
+				CursorLengthWarning: func(w string) {
+					warning = w
+				},
+			})
+			assert.Equal(t, tc.ExpectedWarning, warning)
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package apifu
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// SubscriptionOverflowPolicy determines what happens when a subscription produces results faster
+// than they can be sent to its client.
+type SubscriptionOverflowPolicy int
+
+const (
+	// The subscription waits for room in its buffer, which in turn slows down consumption of the
+	// source stream's events. This is the default.
+	SubscriptionOverflowBlock SubscriptionOverflowPolicy = iota
+
+	// Results that don't fit in the buffer are discarded, giving at-most-once delivery. This is
+	// appropriate for streams in which each event supersedes the previous ones.
+	SubscriptionOverflowDrop
+
+	// The client's connection is closed. Clients are expected to reconnect and resubscribe, which
+	// prevents a slow client from silently falling behind.
+	SubscriptionOverflowDisconnect
+)
+
+// The default values of SubscriptionDelivery.AcknowledgementTimeout and MaxAttempts.
+const (
+	defaultSubscriptionAcknowledgementTimeout = 10 * time.Second
+	defaultSubscriptionMaxDeliveryAttempts    = 5
+)
+
+var errSubscriptionDeliveryNotAcknowledged = errors.New("subscription result was not acknowledged")
+
+// SubscriptionDelivery configures how a subscription's results are delivered to WebSocket clients.
+// The zero value sends each result as soon as it's produced, waiting whenever the connection's send
+// buffer is full.
+type SubscriptionDelivery struct {
+	// The number of results that can be queued for the client.
+	BufferSize int
+
+	// What to do when a result doesn't fit in the buffer.
+	OverflowPolicy SubscriptionOverflowPolicy
+
+	// If true, results are delivered at least once. Each result is sent with a "delivery" response
+	// extension containing its sequence number, e.g. `{"sequence": 1}`, and the client must
+	// acknowledge it with a message like the following before the next result is sent:
+	//
+	//	{"id": "1", "type": "delivery_ack", "payload": {"sequence": 1}}
+	//
+	// Results that aren't acknowledged within AcknowledgementTimeout are sent again with the same
+	// sequence number, so clients should ignore sequence numbers they've already processed.
+	RequireAcknowledgement bool
+
+	// How long to wait for an acknowledgement before sending a result again. If zero, the default
+	// of 10 seconds is used.
+	AcknowledgementTimeout time.Duration
+
+	// The maximum number of times a result is sent while waiting for its acknowledgement. If it
+	// still isn't acknowledged, the client's connection is closed. If zero, the default of 5 is
+	// used.
+	MaxAttempts int
+}
+
+// subscriptionDeliverer sends a subscription's results to its client according to its
+// SubscriptionDelivery.
+type subscriptionDeliverer struct {
+	config     SubscriptionDelivery
+	send       func(resp *graphql.Response) error
+	disconnect func()
+
+	// If given, this is applied to each result once it's dequeued, e.g. to encode deltas, which
+	// must only be computed for results that are actually sent.
+	prepare func(resp *graphql.Response) *graphql.Response
+
+	queue        chan *graphql.Response
+	acks         chan int64
+	done         chan struct{}
+	disconnected sync.Once
+}
+
+// Returns a deliverer for the given configuration, or nil if the default behavior is configured.
+func newSubscriptionDeliverer(config SubscriptionDelivery, send func(*graphql.Response) error, disconnect func()) *subscriptionDeliverer {
+	if config == (SubscriptionDelivery{}) {
+		return nil
+	}
+	if config.AcknowledgementTimeout <= 0 {
+		config.AcknowledgementTimeout = defaultSubscriptionAcknowledgementTimeout
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultSubscriptionMaxDeliveryAttempts
+	}
+	return &subscriptionDeliverer{
+		config:     config,
+		send:       send,
+		disconnect: disconnect,
+		queue:      make(chan *graphql.Response, config.BufferSize),
+		acks:       make(chan int64, 1),
+		done:       make(chan struct{}),
+	}
+}
+
+// Queues a result for delivery. This must not be invoked after close.
+func (d *subscriptionDeliverer) deliver(ctx context.Context, resp *graphql.Response) {
+	switch d.config.OverflowPolicy {
+	case SubscriptionOverflowDrop:
+		select {
+		case d.queue <- resp:
+		default:
+		}
+	case SubscriptionOverflowDisconnect:
+		select {
+		case d.queue <- resp:
+		default:
+			d.disconnectOnce()
+		}
+	default:
+		select {
+		case d.queue <- resp:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// Closes the client's connection. Closing the connection stops the subscription, so this is done
+// on its own goroutine.
+func (d *subscriptionDeliverer) disconnectOnce() {
+	d.disconnected.Do(func() {
+		go d.disconnect()
+	})
+}
+
+// Records the client's acknowledgement of a result.
+func (d *subscriptionDeliverer) ack(sequence int64) {
+	// Only the newest acknowledgement matters, so replace any that hasn't been seen yet.
+	for {
+		select {
+		case d.acks <- sequence:
+			return
+		default:
+		}
+		select {
+		case <-d.acks:
+		default:
+		}
+	}
+}
+
+// Sends queued results until the queue is closed and drained or the context is canceled.
+func (d *subscriptionDeliverer) run(ctx context.Context) error {
+	defer close(d.done)
+
+	var sequence int64
+	for {
+		var resp *graphql.Response
+		select {
+		case r, ok := <-d.queue:
+			if !ok {
+				return nil
+			}
+			resp = r
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if d.prepare != nil {
+			resp = d.prepare(resp)
+		}
+
+		if !d.config.RequireAcknowledgement {
+			if err := d.send(resp); err != nil {
+				return err
+			}
+			continue
+		}
+
+		sequence++
+		withDelivery := *resp
+		withDelivery.Extensions = make(map[string]interface{}, len(resp.Extensions)+1)
+		for k, v := range resp.Extensions {
+			withDelivery.Extensions[k] = v
+		}
+		withDelivery.Extensions["delivery"] = map[string]interface{}{
+			"sequence": sequence,
+		}
+		if err := d.sendUntilAcknowledged(ctx, &withDelivery, sequence); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *subscriptionDeliverer) sendUntilAcknowledged(ctx context.Context, resp *graphql.Response, sequence int64) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for attempts := 0; ; {
+		select {
+		case acked := <-d.acks:
+			if acked >= sequence {
+				return nil
+			}
+		case <-timer.C:
+			if attempts == d.config.MaxAttempts {
+				d.disconnectOnce()
+				return errSubscriptionDeliveryNotAcknowledged
+			}
+			attempts++
+			if err := d.send(resp); err != nil {
+				return err
+			}
+			timer.Reset(d.config.AcknowledgementTimeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Closes the queue and waits for the remaining results to be sent.
+func (d *subscriptionDeliverer) close() {
+	close(d.queue)
+	<-d.done
+}
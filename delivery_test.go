@@ -0,0 +1,232 @@
+package apifu
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/transport/graphqltransportws"
+)
+
+func TestSubscriptionDeliverer(t *testing.T) {
+	responses := []*graphql.Response{
+		{Extensions: map[string]interface{}{"n": 1}},
+		{Extensions: map[string]interface{}{"n": 2}},
+		{Extensions: map[string]interface{}{"n": 3}},
+	}
+
+	// Returns a send function which blocks until each of its invocations is received from the
+	// returned channel and the release channel is closed.
+	blockingSend := func(release chan struct{}) (func(*graphql.Response) error, chan *graphql.Response) {
+		sent := make(chan *graphql.Response)
+		return func(resp *graphql.Response) error {
+			sent <- resp
+			<-release
+			return nil
+		}, sent
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		assert.Nil(t, newSubscriptionDeliverer(SubscriptionDelivery{}, nil, nil))
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		release := make(chan struct{})
+		send, sent := blockingSend(release)
+		d := newSubscriptionDeliverer(SubscriptionDelivery{
+			BufferSize:     1,
+			OverflowPolicy: SubscriptionOverflowDrop,
+		}, send, nil)
+		go d.run(context.Background())
+
+		d.deliver(context.Background(), responses[0])
+		// Wait for the first response to be dequeued, then fill the buffer and overflow it.
+		assert.Equal(t, responses[0], <-sent)
+		d.deliver(context.Background(), responses[1])
+		d.deliver(context.Background(), responses[2])
+		close(release)
+		assert.Equal(t, responses[1], <-sent)
+
+		d.close()
+	})
+
+	t.Run("Disconnect", func(t *testing.T) {
+		release := make(chan struct{})
+		send, sent := blockingSend(release)
+		disconnected := make(chan struct{})
+		d := newSubscriptionDeliverer(SubscriptionDelivery{
+			BufferSize:     1,
+			OverflowPolicy: SubscriptionOverflowDisconnect,
+		}, send, func() {
+			close(disconnected)
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		go d.run(ctx)
+
+		d.deliver(ctx, responses[0])
+		assert.Equal(t, responses[0], <-sent)
+		d.deliver(ctx, responses[1])
+		d.deliver(ctx, responses[2])
+		select {
+		case <-disconnected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the client was not disconnected")
+		}
+
+		// Disconnecting cancels the subscription.
+		cancel()
+		go func() {
+			for range sent {
+			}
+		}()
+		close(release)
+		d.close()
+	})
+
+	t.Run("RequireAcknowledgement", func(t *testing.T) {
+		release := make(chan struct{})
+		close(release)
+		send, sent := blockingSend(release)
+		d := newSubscriptionDeliverer(SubscriptionDelivery{
+			BufferSize:             1,
+			RequireAcknowledgement: true,
+			AcknowledgementTimeout: 10 * time.Millisecond,
+		}, send, nil)
+		go d.run(context.Background())
+
+		d.deliver(context.Background(), responses[0])
+		d.deliver(context.Background(), responses[1])
+
+		// The first response is sent until it's acknowledged.
+		for i := 0; i < 2; i++ {
+			resp := <-sent
+			assert.Equal(t, 1, resp.Extensions["n"])
+			assert.Equal(t, map[string]interface{}{"sequence": int64(1)}, resp.Extensions["delivery"])
+		}
+		d.ack(1)
+
+		resp := <-sent
+		for resp.Extensions["n"] == 1 {
+			// The first response may have been resent before the acknowledgement was processed.
+			resp = <-sent
+		}
+		assert.Equal(t, 2, resp.Extensions["n"])
+		assert.Equal(t, map[string]interface{}{"sequence": int64(2)}, resp.Extensions["delivery"])
+		d.ack(2)
+
+		d.close()
+	})
+
+	t.Run("MaxAttempts", func(t *testing.T) {
+		release := make(chan struct{})
+		close(release)
+		send, sent := blockingSend(release)
+		disconnected := make(chan struct{})
+		d := newSubscriptionDeliverer(SubscriptionDelivery{
+			RequireAcknowledgement: true,
+			AcknowledgementTimeout: time.Millisecond,
+			MaxAttempts:            2,
+		}, send, func() {
+			close(disconnected)
+		})
+		errs := make(chan error, 1)
+		go func() {
+			errs <- d.run(context.Background())
+		}()
+
+		d.deliver(context.Background(), responses[0])
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, 1, (<-sent).Extensions["n"])
+		}
+		assert.Equal(t, errSubscriptionDeliveryNotAcknowledged, <-errs)
+		select {
+		case <-disconnected:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the client was not disconnected")
+		}
+	})
+}
+
+func TestGraphQLWS_SubscriptionDelivery(t *testing.T) {
+	var testCfg Config
+	testCfg.AddSubscription("counter", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			if ctx.IsSubscribe {
+				ch := make(chan int, 2)
+				ch <- 1
+				ch <- 2
+				close(ch)
+				return &SubscriptionSourceStream{
+					EventChannel: ch,
+					Stop:         func() {},
+					Delivery: SubscriptionDelivery{
+						RequireAcknowledgement: true,
+					},
+				}, nil
+			}
+			return ctx.Object, nil
+		},
+	})
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+	defer api.CloseHijackedConnections()
+
+	ts := httptest.NewServer(http.HandlerFunc(api.ServeGraphQLWS))
+	defer ts.Close()
+
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: time.Second,
+		Subprotocols:     []string{graphqltransportws.WebSocketSubprotocol},
+	}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(map[string]string{
+		"type": "connection_init",
+	}))
+	var ack graphqltransportws.Message
+	require.NoError(t, conn.ReadJSON(&ack))
+	assert.Equal(t, graphqltransportws.MessageTypeConnectionAck, ack.Type)
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"id":   "sub",
+		"type": "subscribe",
+		"payload": map[string]interface{}{
+			"query": `subscription { counter }`,
+		},
+	}))
+
+	for i := 1; i <= 2; i++ {
+		var msg graphqltransportws.Message
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, graphqltransportws.MessageTypeNext, msg.Type)
+		assert.JSONEq(t, fmt.Sprintf(`{"data":{"counter":%v},"extensions":{"delivery":{"sequence":%v}}}`, i, i), string(msg.Payload))
+
+		// Malformed acknowledgements are ignored.
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"id":      "sub",
+			"type":    "delivery_ack",
+			"payload": "bad",
+		}))
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"id":      "sub",
+			"type":    "delivery_ack",
+			"payload": map[string]interface{}{"sequence": i},
+		}))
+	}
+
+	var msg graphqltransportws.Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, graphqltransportws.MessageTypeComplete, msg.Type)
+}
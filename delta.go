@@ -0,0 +1,127 @@
+package apifu
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// The request extension that WebSocket clients use to opt in to subscription deltas. See
+// Config.SubscriptionDeltas.
+const subscriptionDeltasExtension = "subscriptionDeltas"
+
+// The only delta format that is currently supported.
+const jsonPatchDeltaFormat = "json-patch"
+
+// Returns true if the request's extensions ask for subscription payloads to be delivered as deltas.
+func wantsSubscriptionDeltas(extensions map[string]interface{}) bool {
+	format, _ := extensions[subscriptionDeltasExtension].(string)
+	return format == jsonPatchDeltaFormat
+}
+
+// subscriptionDeltaEncoder converts a subscription's successive responses into deltas relative to
+// the previously delivered response. It's not safe for concurrent use.
+type subscriptionDeltaEncoder struct {
+	// The JSON representation of the previous response's data, or nil if the next response must be
+	// delivered in full.
+	previous interface{}
+}
+
+// Returns the payload to deliver for the given response. The first response, and any response with
+// errors or without data, is returned as-is. Subsequent responses have their data replaced by a
+// "patch" extension containing RFC 6902 JSON Patch operations relative to the previous data.
+func (e *subscriptionDeltaEncoder) encode(resp *graphql.Response) *graphql.Response {
+	if resp.Data == nil || len(resp.Errors) > 0 {
+		e.previous = nil
+		return resp
+	}
+
+	current, err := normalizeJSON(*resp.Data)
+	if err != nil {
+		e.previous = nil
+		return resp
+	}
+
+	previous := e.previous
+	e.previous = current
+	if previous == nil {
+		return resp
+	}
+
+	extensions := make(map[string]interface{}, len(resp.Extensions)+1)
+	for k, v := range resp.Extensions {
+		extensions[k] = v
+	}
+	extensions["patch"] = appendJSONPatch([]map[string]interface{}{}, "", previous, current)
+	return &graphql.Response{
+		Extensions: extensions,
+	}
+}
+
+// Round-trips the value through JSON so that it can be compared structurally. Objects become
+// map[string]interface{}, and numbers become json.Number so that they aren't subject to rounding.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	var ret interface{}
+	if err := decoder.Decode(&ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+var jsonPointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// Appends the JSON Patch operations that transform a into b to ops. Object members are compared
+// recursively, as are the elements of arrays with equal lengths. Arrays whose lengths differ are
+// replaced entirely.
+func appendJSONPatch(ops []map[string]interface{}, path string, a, b interface{}) []map[string]interface{} {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		if b, ok := b.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(a)+len(b))
+			for k := range a {
+				keys = append(keys, k)
+			}
+			for k := range b {
+				if _, ok := a[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				memberPath := path + "/" + jsonPointerEscaper.Replace(k)
+				av, inA := a[k]
+				bv, inB := b[k]
+				if !inB {
+					ops = append(ops, map[string]interface{}{"op": "remove", "path": memberPath})
+				} else if !inA {
+					ops = append(ops, map[string]interface{}{"op": "add", "path": memberPath, "value": bv})
+				} else {
+					ops = appendJSONPatch(ops, memberPath, av, bv)
+				}
+			}
+			return ops
+		}
+	case []interface{}:
+		if b, ok := b.([]interface{}); ok && len(a) == len(b) {
+			for i := range a {
+				ops = appendJSONPatch(ops, path+"/"+strconv.Itoa(i), a[i], b[i])
+			}
+			return ops
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		ops = append(ops, map[string]interface{}{"op": "replace", "path": path, "value": b})
+	}
+	return ops
+}
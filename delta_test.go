@@ -0,0 +1,167 @@
+package apifu
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/transport/graphqltransportws"
+)
+
+func TestSubscriptionDeltaEncoder(t *testing.T) {
+	response := func(data string) *graphql.Response {
+		var v interface{}
+		require.NoError(t, json.Unmarshal([]byte(data), &v))
+		return &graphql.Response{
+			Data: &v,
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		Previous string
+		Next     string
+		Patch    string
+	}{
+		"Unchanged": {
+			Previous: `{"a":{"b":1}}`,
+			Next:     `{"a":{"b":1}}`,
+			Patch:    `[]`,
+		},
+		"Replace": {
+			Previous: `{"a":{"b":1,"c":"x"}}`,
+			Next:     `{"a":{"b":2,"c":"x"}}`,
+			Patch:    `[{"op":"replace","path":"/a/b","value":2}]`,
+		},
+		"ReplaceWithNull": {
+			Previous: `{"a":{"b":1}}`,
+			Next:     `{"a":null}`,
+			Patch:    `[{"op":"replace","path":"/a","value":null}]`,
+		},
+		"AddAndRemove": {
+			Previous: `{"a":{"b":1}}`,
+			Next:     `{"a":{"c/~":2}}`,
+			Patch:    `[{"op":"remove","path":"/a/b"},{"op":"add","path":"/a/c~1~0","value":2}]`,
+		},
+		"ListElement": {
+			Previous: `{"a":[{"id":1},{"id":2}]}`,
+			Next:     `{"a":[{"id":1},{"id":3}]}`,
+			Patch:    `[{"op":"replace","path":"/a/1/id","value":3}]`,
+		},
+		"ListLength": {
+			Previous: `{"a":[1,2]}`,
+			Next:     `{"a":[1,2,3]}`,
+			Patch:    `[{"op":"replace","path":"/a","value":[1,2,3]}]`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var encoder subscriptionDeltaEncoder
+			assert.Equal(t, response(tc.Previous), encoder.encode(response(tc.Previous)))
+
+			delta := encoder.encode(response(tc.Next))
+			assert.Nil(t, delta.Data)
+			patch, err := json.Marshal(delta.Extensions["patch"])
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.Patch, string(patch))
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		var encoder subscriptionDeltaEncoder
+		encoder.encode(response(`{"a":1}`))
+
+		withErrors := response(`{"a":null}`)
+		withErrors.Errors = []*graphql.Error{{Message: "error"}}
+		assert.Same(t, withErrors, encoder.encode(withErrors))
+
+		// The payload after an error must be delivered in full too.
+		full := response(`{"a":2}`)
+		assert.Same(t, full, encoder.encode(full))
+	})
+}
+
+func TestGraphQLWS_SubscriptionDeltas(t *testing.T) {
+	var testCfg Config
+	testCfg.SubscriptionDeltas = true
+
+	testCfg.AddSubscription("counter", &graphql.FieldDefinition{
+		Type: &graphql.ObjectType{
+			Name: "Counter",
+			Fields: map[string]*graphql.FieldDefinition{
+				"name": {
+					Type: graphql.StringType,
+					Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+						return "counter", nil
+					},
+				},
+				"value": {
+					Type: graphql.IntType,
+					Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+						return ctx.Object, nil
+					},
+				},
+			},
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			if ctx.IsSubscribe {
+				ch := make(chan int, 2)
+				ch <- 1
+				ch <- 2
+				return &SubscriptionSourceStream{
+					EventChannel: ch,
+					Stop:         func() {},
+				}, nil
+			}
+			return ctx.Object, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+	defer api.CloseHijackedConnections()
+
+	ts := httptest.NewServer(http.HandlerFunc(api.ServeGraphQLWS))
+	defer ts.Close()
+
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: time.Second,
+		Subprotocols:     []string{graphqltransportws.WebSocketSubprotocol},
+	}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(map[string]string{
+		"type": "connection_init",
+	}))
+
+	var msg graphqltransportws.Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, graphqltransportws.MessageTypeConnectionAck, msg.Type)
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"id":   "sub",
+		"type": "subscribe",
+		"payload": map[string]interface{}{
+			"query": `subscription { counter { name value } }`,
+			"extensions": map[string]interface{}{
+				"subscriptionDeltas": "json-patch",
+			},
+		},
+	}))
+
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, graphqltransportws.MessageTypeNext, msg.Type)
+	assert.JSONEq(t, `{"data":{"counter":{"name":"counter","value":1}}}`, string(msg.Payload))
+
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, graphqltransportws.MessageTypeNext, msg.Type)
+	assert.JSONEq(t, `{"extensions":{"patch":[{"op":"replace","path":"/counter/value","value":2}]}}`, string(msg.Payload))
+}
@@ -0,0 +1,255 @@
+package apifu
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/executor"
+	"github.com/ccbrown/api-fu/graphql/validator"
+)
+
+type documentCacheKey struct {
+	schema   *graphql.Schema
+	features string
+	query    string
+}
+
+func newDocumentCacheKey(schema *graphql.Schema, features graphql.FeatureSet, query string) documentCacheKey {
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return documentCacheKey{
+		schema:   schema,
+		features: strings.Join(names, "\x00"),
+		query:    query,
+	}
+}
+
+type cachedDocument struct {
+	key      documentCacheKey
+	doc      *ast.Document
+	typeInfo *validator.TypeInfo
+
+	// The document prepared for execution by prepareDocument. Validation rules must use doc, which
+	// typeInfo describes.
+	prepared *ast.Document
+
+	// True if the document was cached for a persisted query.
+	persisted bool
+
+	// The costs of the document's operations that don't depend on variables, keyed by operation
+	// name.
+	costsMutex sync.Mutex
+	costs      map[string]*cachedOperationCost
+}
+
+type cachedOperationCost struct {
+	cost graphql.OperationCost
+	errs []*graphql.Error
+}
+
+// The number of persisted query documents cached if Config.DocumentCacheSize isn't positive.
+const defaultPersistedQueryDocumentCacheSize = 1000
+
+// documentCache is a size-bounded LRU cache of documents that have passed standard validation.
+// Validation rules that depend on the request, such as cost limits, must still be applied to
+// cached documents.
+type documentCache struct {
+	capacity int
+
+	// If given, this is invoked for each document evicted to make room for another.
+	onEvict func(*cachedDocument)
+
+	mutex   sync.Mutex
+	entries map[documentCacheKey]*list.Element
+	order   *list.List
+}
+
+func newDocumentCache(capacity int) *documentCache {
+	return &documentCache{
+		capacity: capacity,
+		entries:  map[documentCacheKey]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+func (c *documentCache) get(key documentCacheKey) *cachedDocument {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*cachedDocument)
+	}
+	return nil
+}
+
+func (c *documentCache) add(doc *cachedDocument) {
+	var evicted []*cachedDocument
+	c.mutex.Lock()
+	if element, ok := c.entries[doc.key]; ok {
+		element.Value = doc
+		c.order.MoveToFront(element)
+	} else {
+		c.entries[doc.key] = c.order.PushFront(doc)
+		for c.order.Len() > c.capacity {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*cachedDocument).key)
+			evicted = append(evicted, oldest.Value.(*cachedDocument))
+		}
+	}
+	c.mutex.Unlock()
+
+	if c.onEvict != nil {
+		for _, doc := range evicted {
+			c.onEvict(doc)
+		}
+	}
+}
+
+// Returns the cache to use for the request's document, or nil if it shouldn't be cached.
+func (api *API) documentCacheForRequest(req *graphql.Request) *documentCache {
+	if api.documentCache != nil {
+		return api.documentCache
+	} else if _, ok := req.Extensions["persistedQuery"]; ok {
+		return api.persistedQueryDocumentCache
+	}
+	return nil
+}
+
+// Parses and validates the request's query, including the API's additional validation rules. If
+// Config.DocumentCacheSize is positive or the query is persisted, documents that pass standard
+// validation are cached so that repeated queries only need to be checked against the additional
+// rules.
+//
+// The returned document is prepared for execution by prepareDocument.
+func (api *API) parseAndValidate(req *graphql.Request, info *RequestInfo) (*ast.Document, []*graphql.Error) {
+	cache := api.documentCacheForRequest(req)
+	if cache == nil {
+		doc, errs := graphql.ParseAndValidate(req.Query, req.Schema, req.Features, api.validatorRules(req, info)...)
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		return api.prepareDocument(doc, req.Schema, req.Features), nil
+	}
+
+	_, persisted := req.Extensions["persistedQuery"]
+	return api.parseAndValidateWithCache(cache, req, info, persisted)
+}
+
+// Like parseAndValidate, but always uses the given cache.
+func (api *API) parseAndValidateWithCache(cache *documentCache, req *graphql.Request, info *RequestInfo, persisted bool) (*ast.Document, []*graphql.Error) {
+	cached, errs := api.cachedParseAndValidate(cache, req.Schema, req.Features, req.Query, persisted)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	errs = api.validateCachedDocumentCost(cached, req, info)
+	errs = append(errs, graphql.ApplyValidatorRules(cached.doc, req.Schema, req.Features, cached.typeInfo, api.nonCostValidatorRules(req)...)...)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return cached.prepared, nil
+}
+
+// Transforms a validated document into the one that's executed. Constant directives are folded,
+// if Config.DeduplicateSelections is true, redundant selections are removed, and if
+// Config.InjectTypename is true, __typename selections are injected. Cached documents are only
+// prepared once.
+func (api *API) prepareDocument(doc *ast.Document, schema *graphql.Schema, features graphql.FeatureSet) *ast.Document {
+	doc = graphql.FoldConstantDirectives(doc, schema)
+	if api.config.DeduplicateSelections {
+		doc = graphql.DeduplicateSelections(doc, schema, features)
+	}
+	if api.config.InjectTypename {
+		doc = graphql.InjectTypename(doc)
+	}
+	return doc
+}
+
+// Returns the cached document for the query, parsing, validating, and caching it if necessary.
+func (api *API) cachedParseAndValidate(cache *documentCache, schema *graphql.Schema, features graphql.FeatureSet, query string, persisted bool) (*cachedDocument, []*graphql.Error) {
+	key := newDocumentCacheKey(schema, features, query)
+	if cached := cache.get(key); cached != nil {
+		return cached, nil
+	}
+	doc, errs := graphql.ParseAndValidate(query, schema, features)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	cached := &cachedDocument{
+		key:       key,
+		doc:       doc,
+		typeInfo:  validator.NewTypeInfo(doc, schema, features),
+		prepared:  api.prepareDocument(doc, schema, features),
+		persisted: persisted,
+	}
+	cache.add(cached)
+	return cached, nil
+}
+
+// Validates the cost of the requested operation and records it in info. If the operation doesn't
+// declare any variables, the result is cached so that it doesn't need to be computed again.
+func (api *API) validateCachedDocumentCost(cached *cachedDocument, req *graphql.Request, info *RequestInfo) []*graphql.Error {
+	cached.costsMutex.Lock()
+	result, ok := cached.costs[req.OperationName]
+	cached.costsMutex.Unlock()
+	if ok {
+		// Callers may modify the results, e.g. to truncate error messages, so they get copies.
+		info.Cost = result.cost.Resolver
+		info.CostDimensions = copyCostDimensions(result.cost.Dimensions)
+		return copyErrors(result.errs)
+	}
+
+	var cost graphql.OperationCost
+	errs := graphql.ApplyValidatorRules(cached.doc, req.Schema, req.Features, cached.typeInfo, api.validateOperationCost(req, info, &cost))
+	// Besides determining the cost, the rule checks that the request's variables can be coerced, so
+	// results for operations with variables are never cached. Otherwise a missing variable would
+	// only be reported as a validation error until the cost was cached. Without variables, the
+	// result only depends on the document and configuration, so errors are cached too.
+	if op, err := executor.GetOperation(cached.doc, req.OperationName); err == nil && len(op.VariableDefinitions) == 0 {
+		cached.costsMutex.Lock()
+		if cached.costs == nil {
+			cached.costs = map[string]*cachedOperationCost{}
+		}
+		cached.costs[req.OperationName] = &cachedOperationCost{
+			cost: graphql.OperationCost{
+				Resolver:   cost.Resolver,
+				Dimensions: copyCostDimensions(cost.Dimensions),
+				Variables:  cost.Variables,
+			},
+			errs: copyErrors(errs),
+		}
+		cached.costsMutex.Unlock()
+	}
+	return errs
+}
+
+func copyCostDimensions(dimensions map[string]int) map[string]int {
+	if dimensions == nil {
+		return nil
+	}
+	ret := make(map[string]int, len(dimensions))
+	for name, cost := range dimensions {
+		ret[name] = cost
+	}
+	return ret
+}
+
+func copyErrors(errs []*graphql.Error) []*graphql.Error {
+	if errs == nil {
+		return nil
+	}
+	ret := make([]*graphql.Error, len(errs))
+	for i, err := range errs {
+		e := *err
+		ret[i] = &e
+	}
+	return ret
+}
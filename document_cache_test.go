@@ -0,0 +1,301 @@
+package apifu
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/ast"
+)
+
+func TestDocumentCache(t *testing.T) {
+	cache := newDocumentCache(2)
+	key := func(query string) documentCacheKey {
+		return newDocumentCacheKey(nil, nil, query)
+	}
+
+	cache.add(&cachedDocument{key: key("a")})
+	cache.add(&cachedDocument{key: key("b")})
+	assert.NotNil(t, cache.get(key("a")))
+
+	// b is now the least recently used document.
+	cache.add(&cachedDocument{key: key("c")})
+	assert.NotNil(t, cache.get(key("a")))
+	assert.Nil(t, cache.get(key("b")))
+	assert.NotNil(t, cache.get(key("c")))
+
+	assert.Equal(t, newDocumentCacheKey(nil, graphql.NewFeatureSet("x", "y"), "a"), newDocumentCacheKey(nil, graphql.NewFeatureSet("y", "x"), "a"))
+	assert.NotEqual(t, newDocumentCacheKey(nil, graphql.NewFeatureSet("x"), "a"), key("a"))
+}
+
+func TestAPI_DocumentCache(t *testing.T) {
+	testCfg := Config{
+		DocumentCacheSize: 10,
+		Features:          featuresFromContext,
+		ReportCost:        true,
+	}
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type:             graphql.StringType,
+		RequiredFeatures: graphql.NewFeatureSet("foo"),
+		Cost:             graphql.FieldResolverCost(3),
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "foo", nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		Query    string
+		Features []string
+		Expected string
+	}{
+		{
+			Features: []string{"foo"},
+			Expected: `{"data":{"foo":"foo"},"extensions":{"cost":{"estimated":3}}}`,
+		},
+		{
+			// The cached document must be used, and its cost must still be reported.
+			Features: []string{"foo"},
+			Expected: `{"data":{"foo":"foo"},"extensions":{"cost":{"estimated":3}}}`,
+		},
+		{
+			// Statically skipped fields are excluded from the cost.
+			Query:    `{foo a: foo @skip(if: true) b: foo @include(if: false)}`,
+			Features: []string{"foo"},
+			Expected: `{"data":{"foo":"foo"},"extensions":{"cost":{"estimated":3}}}`,
+		},
+		{
+			// The document must be revalidated for a different feature set.
+			Features: nil,
+			Expected: `{"errors":[{"message":"Validation error: field foo does not exist on Query","locations":[{"line":1,"column":2}]}]}`,
+		},
+	} {
+		query := tc.Query
+		if query == "" {
+			query = `{foo}`
+		}
+		resp := executeGraphQLWithFeatures(t, api, query, tc.Features)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, tc.Expected, string(body))
+	}
+
+	assert.Equal(t, 2, api.documentCache.order.Len())
+}
+
+func TestAPI_DeduplicateSelections(t *testing.T) {
+	testCfg := Config{
+		DocumentCacheSize:     10,
+		DeduplicateSelections: true,
+	}
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "foo", nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	const query = `{foo ...F ... on Query {foo}} fragment F on Query {foo}`
+	for i := 0; i < 2; i++ {
+		resp := executeGraphQL(t, api, query)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data":{"foo":"foo"}}`, string(body))
+	}
+
+	cached := api.documentCache.get(newDocumentCacheKey(api.schema, nil, query))
+	require.NotNil(t, cached)
+	assert.Equal(t, `{foo ...F} fragment F on Query {foo}`, ast.Print(cached.prepared))
+	assert.Equal(t, query, ast.Print(cached.doc))
+}
+
+func TestAPI_DocumentCache_InjectTypename(t *testing.T) {
+	testCfg := Config{
+		DocumentCacheSize: 10,
+		InjectTypename:    true,
+	}
+	testCfg.AddQueryField("thing", &graphql.FieldDefinition{
+		Type: &graphql.ObjectType{
+			Name: "Thing",
+			Fields: map[string]*graphql.FieldDefinition{
+				"id": {
+					Type: graphql.IDType,
+					Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+						return "1", nil
+					},
+				},
+			},
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return struct{}{}, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	const query = `{thing {id}}`
+	for i := 0; i < 2; i++ {
+		resp := executeGraphQL(t, api, query)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data":{"thing":{"id":"1","__typename":"Thing"}}}`, string(body))
+	}
+
+	// The typename is injected once, when the document is cached.
+	cached := api.documentCache.get(newDocumentCacheKey(api.schema, nil, query))
+	require.NotNil(t, cached)
+	assert.Equal(t, `{thing {id __typename}}`, ast.Print(cached.prepared))
+	assert.Equal(t, query, ast.Print(cached.doc))
+}
+
+func TestAPI_DocumentCache_CostResults(t *testing.T) {
+	testCfg := Config{
+		DocumentCacheSize: 10,
+		MaxCostDimensions: map[string]int{
+			"x": 3,
+		},
+	}
+	costs := 0
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Arguments: map[string]*graphql.InputValueDefinition{
+			"n": {
+				Type: graphql.NewNonNullType(graphql.IntType),
+			},
+		},
+		Cost: func(ctx graphql.FieldCostContext) graphql.FieldCost {
+			costs++
+			return graphql.FieldCost{
+				Dimensions: map[string]int{
+					"x": ctx.Arguments["n"].(int),
+				},
+			}
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return ctx.Arguments["n"], nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	// Errors are cached for operations without variables.
+	for i := 0; i < 3; i++ {
+		resp := executeGraphQL(t, api, `{foo(n: 4)}`)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"errors":[{"message":"Validation error: operation x cost of 4 exceeds allowed cost of 3","locations":[{"line":1,"column":1}]}]}`, string(body))
+	}
+	assert.Equal(t, 1, costs)
+
+	// Modifying the returned results doesn't modify the cached ones.
+	cached, errs := api.cachedParseAndValidate(api.documentCache, api.schema, nil, `{foo(n: 2)}`, false)
+	require.Empty(t, errs)
+	req := &graphql.Request{
+		Context: context.Background(),
+		Schema:  api.schema,
+	}
+	for i := 0; i < 2; i++ {
+		var info RequestInfo
+		assert.Empty(t, api.validateCachedDocumentCost(cached, req, &info))
+		assert.Equal(t, map[string]int{"x": 2}, info.CostDimensions)
+		info.CostDimensions["x"] = 100
+	}
+
+	cached, errs = api.cachedParseAndValidate(api.documentCache, api.schema, nil, `{foo(n: 4)}`, false)
+	require.Empty(t, errs)
+	for i := 0; i < 2; i++ {
+		var info RequestInfo
+		errs := api.validateCachedDocumentCost(cached, req, &info)
+		require.Len(t, errs, 1)
+		assert.NotEqual(t, "modified", errs[0].Message)
+		errs[0].Message = "modified"
+	}
+}
+
+func TestAPI_PersistedQueryDocumentCache(t *testing.T) {
+	testCfg := Config{
+		PersistedQueryStorage: persistedQueryMap{},
+		ReportCost:            true,
+	}
+	costs := 0
+	testCfg.AddQueryField("count", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Arguments: map[string]*graphql.InputValueDefinition{
+			"n": {
+				Type: graphql.NewNonNullType(graphql.IntType),
+			},
+		},
+		Cost: func(ctx graphql.FieldCostContext) graphql.FieldCost {
+			costs++
+			return graphql.FieldCost{
+				Resolver: ctx.Arguments["n"].(int),
+			}
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return ctx.Arguments["n"], nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	get := func(query, variables string) string {
+		hash := sha256.Sum256([]byte(query))
+		params := url.Values{
+			"query":      []string{query},
+			"extensions": []string{`{"persistedQuery":{"version":1,"sha256Hash":"` + hex.EncodeToString(hash[:]) + `"}}`},
+		}
+		if variables != "" {
+			params["variables"] = []string{variables}
+		}
+		w := httptest.NewRecorder()
+		api.ServeGraphQL(w, httptest.NewRequest("GET", "/?"+params.Encode(), nil))
+		body, err := ioutil.ReadAll(w.Result().Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	t.Run("Constant", func(t *testing.T) {
+		costs = 0
+		for i := 0; i < 3; i++ {
+			assert.JSONEq(t, `{"data":{"count":2},"extensions":{"cost":{"estimated":2}}}`, get(`{count(n: 2)}`, ""))
+		}
+		assert.Equal(t, 1, costs)
+	})
+
+	t.Run("Variables", func(t *testing.T) {
+		costs = 0
+		query := `query($n: Int!) {count(n: $n)}`
+		assert.JSONEq(t, `{"data":{"count":3},"extensions":{"cost":{"estimated":3}}}`, get(query, `{"n":3}`))
+		assert.JSONEq(t, `{"data":{"count":4},"extensions":{"cost":{"estimated":4}}}`, get(query, `{"n":4}`))
+		assert.Equal(t, 2, costs)
+
+		// Variables are still validated once the document is cached.
+		assert.JSONEq(t, `{"errors":[{"message":"Validation error: The n variable is required.","locations":[{"line":1,"column":1}]}]}`, get(query, ""))
+	})
+
+	assert.Nil(t, api.documentCache)
+	assert.Equal(t, 2, api.persistedQueryDocumentCache.order.Len())
+
+	// Queries that aren't persisted aren't cached.
+	resp := executeGraphQL(t, api, `{count(n: 5)}`)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"count":5},"extensions":{"cost":{"estimated":5}}}`, string(body))
+	assert.Equal(t, 2, api.persistedQueryDocumentCache.order.Len())
+}
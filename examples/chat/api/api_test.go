@@ -18,7 +18,7 @@ import (
 func NewTestAPI() *API {
 	return &API{
 		App: &app.App{
-			Store: &store.Store{
+			Store: &store.KeyValueStore{
 				Backend: memorystore.NewBackend(),
 			},
 		},
@@ -26,24 +26,52 @@ func init() {
 		"time": apifu.NonNull(apifu.DateTimeType, "Time"),
 		"user": {
 			Type: userType,
-			Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
-				users, err := ctxSession(ctx.Context).GetUsersByIds(ctx.Object.(*model.Message).UserId)
-				if err != nil || len(users) == 0 {
-					return nil, err
+			// Pages of messages typically have many authors. Batching lets us load them all with a
+			// single store query.
+			Resolve: apifu.Batch(func(ctxs []graphql.FieldContext) []graphql.ResolveResult {
+				ids := make([]model.Id, len(ctxs))
+				for i, ctx := range ctxs {
+					ids[i] = ctx.Object.(*model.Message).UserId
 				}
-				return users[0], nil
-			},
+				users, err := ctxSession(ctxs[0].Context).GetUsersByIds(ids...)
+				usersById := map[string]*model.User{}
+				for _, user := range users {
+					usersById[string(user.Id)] = user
+				}
+				ret := make([]graphql.ResolveResult, len(ctxs))
+				for i, id := range ids {
+					if err != nil {
+						ret[i].Error = err
+					} else if user, ok := usersById[string(id)]; ok {
+						ret[i].Value = user
+					}
+				}
+				return ret
+			}),
 		},
 		"body": apifu.NonNull(graphql.StringType, "Body"),
 		"channel": {
 			Type: channelType,
-			Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
-				users, err := ctxSession(ctx.Context).GetChannelsByIds(ctx.Object.(*model.Message).ChannelId)
-				if err != nil || len(users) == 0 {
-					return nil, err
+			Resolve: apifu.Batch(func(ctxs []graphql.FieldContext) []graphql.ResolveResult {
+				ids := make([]model.Id, len(ctxs))
+				for i, ctx := range ctxs {
+					ids[i] = ctx.Object.(*model.Message).ChannelId
 				}
-				return users[0], nil
-			},
+				channels, err := ctxSession(ctxs[0].Context).GetChannelsByIds(ids...)
+				channelsById := map[string]*model.Channel{}
+				for _, channel := range channels {
+					channelsById[string(channel.Id)] = channel
+				}
+				ret := make([]graphql.ResolveResult, len(ctxs))
+				for i, id := range ids {
+					if err != nil {
+						ret[i].Error = err
+					} else if channel, ok := channelsById[string(id)]; ok {
+						ret[i].Value = channel
+					}
+				}
+				return ret
+			}),
 		},
 	}
 }
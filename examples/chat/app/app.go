@@ -3,5 +3,5 @@ package app
 import "github.com/ccbrown/api-fu/examples/chat/store"
 
 type App struct {
-	Store *store.Store
+	Store store.Store
 }
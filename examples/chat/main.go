@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"flag"
 	"net/http"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"github.com/go-redis/redis"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	_ "github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 
 	"github.com/ccbrown/api-fu/examples/chat/api"
@@ -24,25 +26,44 @@ import (
 
 func main() {
 	redisAddress := flag.String("redis-address", "", "can be used to run with a redis database")
+	postgresURL := flag.String("postgres-url", "", "can be used to run with a postgres database")
 	flag.Parse()
 
-	var backend keyvaluestore.Backend
-	if *redisAddress == "" {
-		logrus.Info("using a temporary database. if you would like data to be persistent, provide --redis-address")
-		backend = memorystore.NewBackend()
+	var s store.Store
+	if *postgresURL != "" {
+		db, err := sql.Open("postgres", *postgresURL)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		defer db.Close()
+		sqlStore := &store.SQLStore{
+			DB:      db,
+			Dialect: store.SQLDialectPostgres,
+		}
+		if err := sqlStore.Migrate(); err != nil {
+			logrus.Fatal(err)
+		}
+		s = sqlStore
 	} else {
-		backend = &redisstore.Backend{
-			Client: redis.NewClient(&redis.Options{
-				Addr: *redisAddress,
-			}),
+		var backend keyvaluestore.Backend
+		if *redisAddress == "" {
+			logrus.Info("using a temporary database. if you would like data to be persistent, provide --redis-address or --postgres-url")
+			backend = memorystore.NewBackend()
+		} else {
+			backend = &redisstore.Backend{
+				Client: redis.NewClient(&redis.Options{
+					Addr: *redisAddress,
+				}),
+			}
+		}
+		s = &store.KeyValueStore{
+			Backend: backend,
 		}
 	}
 
 	api := &api.API{
 		App: &app.App{
-			Store: &store.Store{
-				Backend: backend,
-			},
+			Store: s,
 		},
 	}
 
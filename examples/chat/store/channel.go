@@ -4,7 +4,7 @@ import (
 	"github.com/ccbrown/api-fu/examples/chat/model"
 )
 
-func (s *Store) AddChannel(channel *model.Channel) error {
+func (s *KeyValueStore) AddChannel(channel *model.Channel) error {
 	serialized, err := serialize(channel)
 	if err != nil {
 		return err
@@ -17,12 +17,12 @@ func (s *Store) AddChannel(channel *model.Channel) error {
 	return err
 }
 
-func (s *Store) GetChannelsByIds(ids ...model.Id) ([]*model.Channel, error) {
+func (s *KeyValueStore) GetChannelsByIds(ids ...model.Id) ([]*model.Channel, error) {
 	var ret []*model.Channel
 	return ret, s.getByIds("channel", &ret, ids...)
 }
 
-func (s *Store) GetChannels() ([]*model.Channel, error) {
+func (s *KeyValueStore) GetChannels() ([]*model.Channel, error) {
 	ids, err := s.Backend.SMembers("channels")
 	if ids == nil {
 		return nil, err
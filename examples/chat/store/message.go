@@ -6,7 +6,7 @@ import (
 	"github.com/ccbrown/api-fu/examples/chat/model"
 )
 
-func (s *Store) AddMessage(message *model.Message) error {
+func (s *KeyValueStore) AddMessage(message *model.Message) error {
 	serialized, err := serialize(message)
 	if err != nil {
 		return err
@@ -19,15 +19,12 @@ func (s *Store) AddMessage(message *model.Message) error {
 	return err
 }
 
-func (s *Store) GetMessagesByIds(ids ...model.Id) ([]*model.Message, error) {
+func (s *KeyValueStore) GetMessagesByIds(ids ...model.Id) ([]*model.Message, error) {
 	var ret []*model.Message
 	return ret, s.getByIds("message", &ret, ids...)
 }
 
-// GetMessagesByChannelIdAndTimeRange gets messages for a particular channel within an inclusive
-// time range. If limit is non-zero, the returned messages will be limited to that number. If limit
-// is negative, the returned messages will be the last messages in the range.
-func (s *Store) GetMessagesByChannelIdAndTimeRange(channelId model.Id, begin, end time.Time, limit int) ([]*model.Message, error) {
+func (s *KeyValueStore) GetMessagesByChannelIdAndTimeRange(channelId model.Id, begin, end time.Time, limit int) ([]*model.Message, error) {
 	zrange := s.Backend.ZRangeByScore
 	if limit < 0 {
 		zrange = s.Backend.ZRevRangeByScore
@@ -0,0 +1,308 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccbrown/api-fu/examples/chat/model"
+)
+
+// SQLDialect identifies the flavor of SQL spoken by a database.
+type SQLDialect int
+
+const (
+	SQLDialectSQLite SQLDialect = iota
+	SQLDialectPostgres
+)
+
+// SQLStore implements Store using a SQL database. Before it's used, Migrate should be invoked to
+// create or update the database's tables.
+//
+// Messages are paginated using keyset queries on the (channel_id, time, id) index, so the cost of
+// fetching a page doesn't grow with the number of messages before or after it.
+type SQLStore struct {
+	DB      *sql.DB
+	Dialect SQLDialect
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// Each migration is applied exactly once, in order. Once a migration has been released, it must
+// never be modified. Changes should be made by appending new migrations instead.
+var sqlMigrations = []struct {
+	SQLite   string
+	Postgres string
+}{
+	{
+		SQLite: `
+			CREATE TABLE users (
+				id BLOB PRIMARY KEY,
+				revision_number INTEGER NOT NULL,
+				handle TEXT NOT NULL UNIQUE,
+				password_hash BLOB NOT NULL
+			);
+			CREATE TABLE channels (
+				id BLOB PRIMARY KEY,
+				revision_number INTEGER NOT NULL,
+				creator_user_id BLOB NOT NULL,
+				creation_time INTEGER NOT NULL,
+				name TEXT NOT NULL
+			);
+			CREATE TABLE messages (
+				id BLOB PRIMARY KEY,
+				revision_number INTEGER NOT NULL,
+				user_id BLOB NOT NULL,
+				channel_id BLOB NOT NULL,
+				time INTEGER NOT NULL,
+				body TEXT NOT NULL
+			);
+			CREATE INDEX messages_by_channel ON messages (channel_id, time, id);
+		`,
+		Postgres: `
+			CREATE TABLE users (
+				id BYTEA PRIMARY KEY,
+				revision_number INTEGER NOT NULL,
+				handle TEXT NOT NULL UNIQUE,
+				password_hash BYTEA NOT NULL
+			);
+			CREATE TABLE channels (
+				id BYTEA PRIMARY KEY,
+				revision_number INTEGER NOT NULL,
+				creator_user_id BYTEA NOT NULL,
+				creation_time BIGINT NOT NULL,
+				name TEXT NOT NULL
+			);
+			CREATE TABLE messages (
+				id BYTEA PRIMARY KEY,
+				revision_number INTEGER NOT NULL,
+				user_id BYTEA NOT NULL,
+				channel_id BYTEA NOT NULL,
+				time BIGINT NOT NULL,
+				body TEXT NOT NULL
+			);
+			CREATE INDEX messages_by_channel ON messages (channel_id, time, id);
+		`,
+	},
+}
+
+// Migrate brings the database's schema up to date.
+func (s *SQLStore) Migrate() error {
+	if _, err := s.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("error creating migrations table: %w", err)
+	}
+
+	var version int
+	if err := s.DB.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("error getting schema version: %w", err)
+	}
+
+	for i := version; i < len(sqlMigrations); i++ {
+		migration := sqlMigrations[i].SQLite
+		if s.Dialect == SQLDialectPostgres {
+			migration = sqlMigrations[i].Postgres
+		}
+
+		tx, err := s.DB.Begin()
+		if err != nil {
+			return err
+		}
+		for _, stmt := range strings.Split(migration, ";") {
+			if strings.TrimSpace(stmt) == "" {
+				continue
+			}
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("error applying migration %v: %w", i+1, err)
+			}
+		}
+		if _, err := tx.Exec(s.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Queries are written with "?" placeholders. For Postgres, they need to be rewritten to "$1", "$2",
+// etc.
+func (s *SQLStore) rebind(query string) string {
+	if s.Dialect != SQLDialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// Returns a list of placeholders and arguments for an "IN" clause. Duplicate ids are removed.
+func inClause(ids []model.Id) (string, []interface{}) {
+	args := make([]interface{}, 0, len(ids))
+	seen := map[string]struct{}{}
+	for _, id := range ids {
+		if _, ok := seen[string(id)]; !ok {
+			seen[string(id)] = struct{}{}
+			args = append(args, []byte(id))
+		}
+	}
+	return "(" + strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ") + ")", args
+}
+
+func (s *SQLStore) AddChannel(channel *model.Channel) error {
+	_, err := s.DB.Exec(s.rebind(`INSERT INTO channels (id, revision_number, creator_user_id, creation_time, name) VALUES (?, ?, ?, ?, ?)`),
+		[]byte(channel.Id), channel.RevisionNumber, []byte(channel.CreatorUserId), channel.CreationTime.UnixNano(), channel.Name)
+	return err
+}
+
+const channelColumns = `id, revision_number, creator_user_id, creation_time, name`
+
+func scanChannels(rows *sql.Rows, err error) ([]*model.Channel, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []*model.Channel
+	for rows.Next() {
+		var channel model.Channel
+		var creationTime int64
+		if err := rows.Scan((*[]byte)(&channel.Id), &channel.RevisionNumber, (*[]byte)(&channel.CreatorUserId), &creationTime, &channel.Name); err != nil {
+			return nil, err
+		}
+		channel.CreationTime = time.Unix(0, creationTime)
+		ret = append(ret, &channel)
+	}
+	return ret, rows.Err()
+}
+
+func (s *SQLStore) GetChannelsByIds(ids ...model.Id) ([]*model.Channel, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	in, args := inClause(ids)
+	return scanChannels(s.DB.Query(s.rebind(`SELECT `+channelColumns+` FROM channels WHERE id IN `+in), args...))
+}
+
+func (s *SQLStore) GetChannels() ([]*model.Channel, error) {
+	return scanChannels(s.DB.Query(`SELECT ` + channelColumns + ` FROM channels`))
+}
+
+func (s *SQLStore) AddMessage(message *model.Message) error {
+	_, err := s.DB.Exec(s.rebind(`INSERT INTO messages (id, revision_number, user_id, channel_id, time, body) VALUES (?, ?, ?, ?, ?, ?)`),
+		[]byte(message.Id), message.RevisionNumber, []byte(message.UserId), []byte(message.ChannelId), message.Time.UnixNano(), message.Body)
+	return err
+}
+
+const messageColumns = `id, revision_number, user_id, channel_id, time, body`
+
+func scanMessages(rows *sql.Rows, err error) ([]*model.Message, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []*model.Message
+	for rows.Next() {
+		var message model.Message
+		var t int64
+		if err := rows.Scan((*[]byte)(&message.Id), &message.RevisionNumber, (*[]byte)(&message.UserId), (*[]byte)(&message.ChannelId), &t, &message.Body); err != nil {
+			return nil, err
+		}
+		message.Time = time.Unix(0, t)
+		ret = append(ret, &message)
+	}
+	return ret, rows.Err()
+}
+
+func (s *SQLStore) GetMessagesByIds(ids ...model.Id) ([]*model.Message, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	in, args := inClause(ids)
+	return scanMessages(s.DB.Query(s.rebind(`SELECT `+messageColumns+` FROM messages WHERE id IN `+in), args...))
+}
+
+func (s *SQLStore) GetMessagesByChannelIdAndTimeRange(channelId model.Id, begin, end time.Time, limit int) ([]*model.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE channel_id = ? AND time >= ? AND time <= ?`
+	args := []interface{}{[]byte(channelId), begin.UnixNano(), end.UnixNano()}
+	if limit < 0 {
+		query += ` ORDER BY time DESC, id DESC LIMIT ?`
+		args = append(args, -limit)
+	} else if limit > 0 {
+		query += ` ORDER BY time, id LIMIT ?`
+		args = append(args, limit)
+	} else {
+		query += ` ORDER BY time, id`
+	}
+	return scanMessages(s.DB.Query(s.rebind(query), args...))
+}
+
+func (s *SQLStore) AddUser(user *model.User) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(s.rebind(`SELECT COUNT(*) FROM users WHERE handle = ?`), user.Handle).Scan(&exists); err != nil {
+		return err
+	} else if exists > 0 {
+		return ErrUserHandleExists
+	}
+
+	// If another user takes the handle concurrently, the unique constraint will cause this to fail.
+	if _, err := tx.Exec(s.rebind(`INSERT INTO users (id, revision_number, handle, password_hash) VALUES (?, ?, ?, ?)`),
+		[]byte(user.Id), user.RevisionNumber, user.Handle, user.PasswordHash); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const userColumns = `id, revision_number, handle, password_hash`
+
+func scanUsers(rows *sql.Rows, err error) ([]*model.User, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []*model.User
+	for rows.Next() {
+		var user model.User
+		if err := rows.Scan((*[]byte)(&user.Id), &user.RevisionNumber, &user.Handle, &user.PasswordHash); err != nil {
+			return nil, err
+		}
+		ret = append(ret, &user)
+	}
+	return ret, rows.Err()
+}
+
+func (s *SQLStore) GetUsersByIds(ids ...model.Id) ([]*model.User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	in, args := inClause(ids)
+	return scanUsers(s.DB.Query(s.rebind(`SELECT `+userColumns+` FROM users WHERE id IN `+in), args...))
+}
+
+func (s *SQLStore) GetUserByHandle(handle string) (*model.User, error) {
+	users, err := scanUsers(s.DB.Query(s.rebind(`SELECT `+userColumns+` FROM users WHERE handle = ?`), handle))
+	if len(users) < 1 {
+		return nil, err
+	}
+	return users[0], nil
+}
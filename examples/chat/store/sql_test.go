@@ -0,0 +1,178 @@
+package store
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/examples/chat/model"
+)
+
+func TestSQLStore_Rebind(t *testing.T) {
+	query := `SELECT id FROM users WHERE handle = ? AND id IN (?, ?)`
+	assert.Equal(t, query, (&SQLStore{Dialect: SQLDialectSQLite}).rebind(query))
+	assert.Equal(t, `SELECT id FROM users WHERE handle = $1 AND id IN ($2, $3)`, (&SQLStore{Dialect: SQLDialectPostgres}).rebind(query))
+}
+
+func TestInClause(t *testing.T) {
+	in, args := inClause([]model.Id{model.Id("a"), model.Id("b"), model.Id("a")})
+	assert.Equal(t, "(?, ?)", in)
+	assert.Equal(t, []interface{}{[]byte("a"), []byte("b")}, args)
+}
+
+// The store is tested against Postgres if CHAT_TEST_POSTGRES_URL is set. The chat tables in the
+// database are dropped before the test runs, so it should only be used for testing.
+func newTestSQLStore(t *testing.T) *SQLStore {
+	url := os.Getenv("CHAT_TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("CHAT_TEST_POSTGRES_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`DROP TABLE IF EXISTS schema_migrations, users, channels, messages`)
+	require.NoError(t, err)
+
+	s := &SQLStore{
+		DB:      db,
+		Dialect: SQLDialectPostgres,
+	}
+	require.NoError(t, s.Migrate())
+
+	// Migrations that have already been applied must be skipped.
+	require.NoError(t, s.Migrate())
+	return s
+}
+
+func TestSQLStore_Users(t *testing.T) {
+	s := newTestSQLStore(t)
+
+	alice := &model.User{
+		Id:           model.GenerateId(),
+		Handle:       "alice",
+		PasswordHash: []byte("hash"),
+	}
+	require.NoError(t, s.AddUser(alice))
+	assert.Equal(t, ErrUserHandleExists, s.AddUser(&model.User{
+		Id:           model.GenerateId(),
+		Handle:       "alice",
+		PasswordHash: []byte("hash"),
+	}))
+
+	user, err := s.GetUserByHandle("alice")
+	require.NoError(t, err)
+	assert.Equal(t, alice, user)
+
+	user, err = s.GetUserByHandle("bob")
+	require.NoError(t, err)
+	assert.Nil(t, user)
+
+	users, err := s.GetUsersByIds(alice.Id, model.GenerateId(), alice.Id)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.User{alice}, users)
+
+	users, err = s.GetUsersByIds()
+	require.NoError(t, err)
+	assert.Empty(t, users)
+}
+
+func TestSQLStore_Channels(t *testing.T) {
+	s := newTestSQLStore(t)
+
+	channel := &model.Channel{
+		Id:            model.GenerateId(),
+		CreatorUserId: model.GenerateId(),
+		CreationTime:  time.Unix(1000, 1),
+		Name:          "general",
+	}
+	require.NoError(t, s.AddChannel(channel))
+
+	channels, err := s.GetChannelsByIds(channel.Id, model.GenerateId())
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+	assert.Equal(t, channel.Id, channels[0].Id)
+	assert.Equal(t, channel.CreatorUserId, channels[0].CreatorUserId)
+	assert.True(t, channel.CreationTime.Equal(channels[0].CreationTime))
+	assert.Equal(t, "general", channels[0].Name)
+
+	channels, err = s.GetChannels()
+	require.NoError(t, err)
+	assert.Len(t, channels, 1)
+}
+
+func TestSQLStore_Messages(t *testing.T) {
+	s := newTestSQLStore(t)
+
+	channelId := model.GenerateId()
+	ids := []model.Id{
+		model.Id("a"),
+		model.Id("b"),
+		model.Id("c"),
+		model.Id("d"),
+	}
+	// b and c have the same time, so they're ordered by id.
+	times := []time.Time{
+		time.Unix(1, 0),
+		time.Unix(2, 0),
+		time.Unix(2, 0),
+		time.Unix(3, 0),
+	}
+	for i, id := range ids {
+		require.NoError(t, s.AddMessage(&model.Message{
+			Id:        id,
+			UserId:    model.GenerateId(),
+			ChannelId: channelId,
+			Time:      times[i],
+			Body:      "message " + string(id),
+		}))
+	}
+	require.NoError(t, s.AddMessage(&model.Message{
+		Id:        model.Id("other"),
+		UserId:    model.GenerateId(),
+		ChannelId: model.GenerateId(),
+		Time:      time.Unix(2, 0),
+		Body:      "other channel",
+	}))
+
+	messageIds := func(messages []*model.Message) []string {
+		ret := make([]string, len(messages))
+		for i, message := range messages {
+			ret[i] = string(message.Id)
+		}
+		return ret
+	}
+
+	for name, tc := range map[string]struct {
+		Begin    time.Time
+		End      time.Time
+		Limit    int
+		Expected []string
+	}{
+		"All":        {time.Unix(0, 0), time.Unix(10, 0), 0, []string{"a", "b", "c", "d"}},
+		"Inclusive":  {time.Unix(2, 0), time.Unix(3, 0), 0, []string{"b", "c", "d"}},
+		"First":      {time.Unix(0, 0), time.Unix(10, 0), 2, []string{"a", "b"}},
+		"Last":       {time.Unix(0, 0), time.Unix(10, 0), -2, []string{"d", "c"}},
+		"LastInTies": {time.Unix(0, 0), time.Unix(2, 0), -1, []string{"c"}},
+		"Empty":      {time.Unix(4, 0), time.Unix(10, 0), 0, []string{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			messages, err := s.GetMessagesByChannelIdAndTimeRange(channelId, tc.Begin, tc.End, tc.Limit)
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, messageIds(messages))
+		})
+	}
+
+	messages, err := s.GetMessagesByIds(model.Id("b"), model.Id("missing"))
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "message b", messages[0].Body)
+	assert.Equal(t, channelId, messages[0].ChannelId)
+	assert.True(t, times[1].Equal(messages[0].Time))
+}
@@ -1,7 +1,9 @@
 package store
 
 import (
+	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/ccbrown/keyvaluestore"
 	"github.com/vmihailenco/msgpack"
@@ -9,11 +11,35 @@ import (
 	"github.com/ccbrown/api-fu/examples/chat/model"
 )
 
+var ErrUserHandleExists = fmt.Errorf("user handle exists")
+
 // Store implements the persistence layer of our application.
-type Store struct {
+type Store interface {
+	AddChannel(channel *model.Channel) error
+	GetChannelsByIds(ids ...model.Id) ([]*model.Channel, error)
+	GetChannels() ([]*model.Channel, error)
+
+	AddMessage(message *model.Message) error
+	GetMessagesByIds(ids ...model.Id) ([]*model.Message, error)
+
+	// GetMessagesByChannelIdAndTimeRange gets messages for a particular channel within an inclusive
+	// time range. If limit is non-zero, the returned messages will be limited to that number. If
+	// limit is negative, the returned messages will be the last messages in the range.
+	GetMessagesByChannelIdAndTimeRange(channelId model.Id, begin, end time.Time, limit int) ([]*model.Message, error)
+
+	// Adds a user to the database. Returns ErrUserHandleExists if the handle is taken.
+	AddUser(user *model.User) error
+	GetUsersByIds(ids ...model.Id) ([]*model.User, error)
+	GetUserByHandle(handle string) (*model.User, error)
+}
+
+// KeyValueStore implements Store using a key-value backend such as Redis.
+type KeyValueStore struct {
 	Backend keyvaluestore.Backend
 }
 
+var _ Store = (*KeyValueStore)(nil)
+
 func serialize(v interface{}) (string, error) {
 	b, err := msgpack.Marshal(v)
 	if err != nil {
@@ -26,7 +52,7 @@ func deserialize(s string, dest interface{}) error {
 	return msgpack.Unmarshal([]byte(s), dest)
 }
 
-func (s *Store) getByIds(key string, dest interface{}, ids ...model.Id) error {
+func (s *KeyValueStore) getByIds(key string, dest interface{}, ids ...model.Id) error {
 	batch := s.Backend.Batch()
 	gets := make([]keyvaluestore.GetResult, 0, len(ids))
 	keys := map[string]struct{}{}
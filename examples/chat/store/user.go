@@ -1,15 +1,10 @@
 package store
 
 import (
-	"fmt"
-
 	"github.com/ccbrown/api-fu/examples/chat/model"
 )
 
-var ErrUserHandleExists = fmt.Errorf("user handle exists")
-
-// Adds a user to the database. Returns ErrUserHandleExists if the handle is taken.
-func (s *Store) AddUser(user *model.User) error {
+func (s *KeyValueStore) AddUser(user *model.User) error {
 	serialized, err := serialize(user)
 	if err != nil {
 		return err
@@ -26,12 +21,12 @@ func (s *Store) AddUser(user *model.User) error {
 	return nil
 }
 
-func (s *Store) GetUsersByIds(ids ...model.Id) ([]*model.User, error) {
+func (s *KeyValueStore) GetUsersByIds(ids ...model.Id) ([]*model.User, error) {
 	var ret []*model.User
 	return ret, s.getByIds("user", &ret, ids...)
 }
 
-func (s *Store) GetUserByHandle(handle string) (*model.User, error) {
+func (s *KeyValueStore) GetUserByHandle(handle string) (*model.User, error) {
 	id, err := s.Backend.Get("user_by_handle:" + handle)
 	if id == nil {
 		return nil, err
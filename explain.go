@@ -0,0 +1,244 @@
+package apifu
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// ExplainConfig configures explain mode. In explain mode, the execution of an operation is
+// recorded as a tree of resolved fields, which is useful for debugging slow operations. See
+// Explanation.
+//
+// Recording an explanation adds overhead to every field, so explain mode is typically only enabled
+// in development and staging environments.
+type ExplainConfig struct {
+	// This determines whether a request is explained. If nil, no requests are explained.
+	//
+	// Explanations reveal details about the server's implementation, so this should only allow
+	// trusted clients. For example, it might check the request's context for an authenticated
+	// developer, then use ExplainRequested to see whether the developer asked for an explanation.
+	Enabled func(r *graphql.Request) bool
+
+	// If true, explanations are included in the response's "explain" extension.
+	IncludeInResponse bool
+
+	// If given, this is invoked with the explanation of each explained request.
+	Handler func(ctx context.Context, explanation *Explanation)
+}
+
+func (cfg *ExplainConfig) enabled(r *graphql.Request) bool {
+	return cfg.Enabled != nil && cfg.Enabled(r)
+}
+
+// ExplainRequested returns true if the request has an "explain" extension that is true.
+func ExplainRequested(r *graphql.Request) bool {
+	explain, _ := r.Extensions["explain"].(bool)
+	return explain
+}
+
+// Explanation describes the execution of an operation.
+type Explanation struct {
+	// The total duration of the operation's execution.
+	Duration time.Duration `json:"durationNanoseconds"`
+
+	// The root fields of the operation in the order in which they began resolving.
+	Fields []*ExplainedField `json:"fields"`
+}
+
+// ExplainedField describes the resolution of a single field.
+type ExplainedField struct {
+	// The path of the field within the response data.
+	Path graphql.Path `json:"path"`
+
+	// The field's coordinate, e.g. "Query.node".
+	Field string `json:"field"`
+
+	// The time between the invocation of the field's resolver and the availability of its result.
+	// For asynchronous resolvers, this includes the time spent waiting for the result. It doesn't
+	// include the time spent resolving the field's children.
+	Duration time.Duration `json:"durationNanoseconds"`
+
+	// The cost of resolving the field, as defined by its Cost function.
+	Cost int `json:"cost"`
+
+	// If the field was resolved via Batch, this is the number of fields in the batch.
+	BatchSize int `json:"batchSize,omitempty"`
+
+	// If the field was resolved via CachedResolver, this indicates whether the value was cached.
+	CacheHit *bool `json:"cacheHit,omitempty"`
+
+	// If resolution failed, this is the error's message.
+	Error string `json:"error,omitempty"`
+
+	// Additional information added by resolvers via AnnotateExplanation.
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+
+	// The children of the field in the order in which they began resolving.
+	Children []*ExplainedField `json:"children,omitempty"`
+}
+
+type explainer struct {
+	config *Config
+
+	mutex       sync.Mutex
+	explanation Explanation
+	fields      map[string]*ExplainedField
+
+	// Once execution is complete, the explanation is no longer modified.
+	done bool
+}
+
+type explainedFieldContextKeyType int
+
+var explainedFieldContextKey explainedFieldContextKeyType
+
+type explainedFieldContext struct {
+	explainer *explainer
+	field     *ExplainedField
+}
+
+// Returns a key that uniquely identifies a path.
+func pathKey(path graphql.Path) string {
+	var b strings.Builder
+	for _, segment := range path {
+		if segment.IsIndex() {
+			b.WriteString("[" + strconv.Itoa(segment.Index) + "]")
+		} else {
+			b.WriteString("." + segment.Key)
+		}
+	}
+	return b.String()
+}
+
+func (e *explainer) observeField(ctx context.Context, observed *graphql.ObservedField) (context.Context, func(error)) {
+	field := &ExplainedField{
+		Path:  observed.Path,
+		Field: observed.ObjectType.Name + "." + observed.Name,
+		Cost:  e.config.DefaultFieldCost.Resolver,
+	}
+	if observed.Definition.Cost != nil {
+		field.Cost = observed.Definition.Cost(graphql.FieldCostContext{
+			Context:   ctx,
+			Arguments: observed.Arguments,
+		}).Resolver
+	}
+
+	// The field's parent is the nearest ancestor that isn't a list index.
+	parentPath := observed.Path[:len(observed.Path)-1]
+	for len(parentPath) > 0 {
+		if !parentPath[len(parentPath)-1].IsIndex() {
+			break
+		}
+		parentPath = parentPath[:len(parentPath)-1]
+	}
+
+	e.mutex.Lock()
+	if parent, ok := e.fields[pathKey(parentPath)]; ok && len(parentPath) > 0 {
+		parent.Children = append(parent.Children, field)
+	} else {
+		e.explanation.Fields = append(e.explanation.Fields, field)
+	}
+	e.fields[pathKey(observed.Path)] = field
+	e.mutex.Unlock()
+
+	ctx = context.WithValue(ctx, explainedFieldContextKey, &explainedFieldContext{
+		explainer: e,
+		field:     field,
+	})
+	start := time.Now()
+	return ctx, func(err error) {
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		if e.done {
+			return
+		}
+		field.Duration = time.Since(start)
+		if err != nil {
+			field.Error = err.Error()
+		}
+	}
+}
+
+func ctxExplainedField(ctx context.Context) *explainedFieldContext {
+	v, _ := ctx.Value(explainedFieldContextKey).(*explainedFieldContext)
+	return v
+}
+
+// Invokes f with the explained field for the given resolver context, if there is one.
+func updateExplainedField(ctx context.Context, f func(field *ExplainedField)) {
+	if v := ctxExplainedField(ctx); v != nil {
+		v.explainer.mutex.Lock()
+		defer v.explainer.mutex.Unlock()
+		if !v.explainer.done {
+			f(v.field)
+		}
+	}
+}
+
+// AnnotateExplanation adds information to the explanation of the field being resolved with the
+// given context. If the request isn't being explained, this does nothing. It's safe to invoke from
+// any goroutine.
+func AnnotateExplanation(ctx context.Context, key string, value interface{}) {
+	updateExplainedField(ctx, func(field *ExplainedField) {
+		if field.Annotations == nil {
+			field.Annotations = map[string]interface{}{}
+		}
+		field.Annotations[key] = value
+	})
+}
+
+// withExplain wraps execute so that requests are explained if explain mode is enabled for them.
+func withExplain(cfg *Config, execute func(*graphql.Request, *RequestInfo) *graphql.Response) func(*graphql.Request, *RequestInfo) *graphql.Response {
+	if cfg.Explain == nil {
+		return execute
+	}
+	return func(r *graphql.Request, info *RequestInfo) *graphql.Response {
+		if !cfg.Explain.enabled(r) {
+			return execute(r, info)
+		}
+
+		e := &explainer{
+			config: cfg,
+			fields: map[string]*ExplainedField{},
+		}
+		req := *r
+		observeField := req.ObserveField
+		req.ObserveField = func(ctx context.Context, field *graphql.ObservedField) (context.Context, func(error)) {
+			if observeField == nil {
+				return e.observeField(ctx, field)
+			}
+			ctx, f := observeField(ctx, field)
+			ctx, g := e.observeField(ctx, field)
+			return ctx, func(err error) {
+				if f != nil {
+					f(err)
+				}
+				g(err)
+			}
+		}
+
+		start := time.Now()
+		resp := execute(&req, info)
+
+		e.mutex.Lock()
+		e.done = true
+		e.explanation.Duration = time.Since(start)
+		e.mutex.Unlock()
+
+		if cfg.Explain.IncludeInResponse {
+			if resp.Extensions == nil {
+				resp.Extensions = map[string]interface{}{}
+			}
+			resp.Extensions["explain"] = &e.explanation
+		}
+		if cfg.Explain.Handler != nil {
+			cfg.Explain.Handler(r.Context, &e.explanation)
+		}
+		return resp
+	}
+}
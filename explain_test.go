@@ -0,0 +1,135 @@
+package apifu
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestExplain(t *testing.T) {
+	itemType := &graphql.ObjectType{
+		Name: "Item",
+		Fields: map[string]*graphql.FieldDefinition{
+			"batched": {
+				Type: graphql.IntType,
+				Resolve: Batch(func(ctxs []graphql.FieldContext) []graphql.ResolveResult {
+					ret := make([]graphql.ResolveResult, len(ctxs))
+					for i, ctx := range ctxs {
+						ret[i].Value = ctx.Object
+					}
+					return ret
+				}),
+			},
+		},
+	}
+
+	var explanation *Explanation
+
+	var cfg Config
+	cfg.DefaultFieldCost = graphql.FieldCost{Resolver: 1}
+	cfg.Explain = &ExplainConfig{
+		Enabled:           ExplainRequested,
+		IncludeInResponse: true,
+		Handler: func(ctx context.Context, e *Explanation) {
+			explanation = e
+		},
+	}
+	cfg.AddQueryField("items", &graphql.FieldDefinition{
+		Type: graphql.NewListType(itemType),
+		Cost: graphql.FieldResolverCost(5),
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			AnnotateExplanation(ctx.Context, "source", "test")
+			return []int{1, 2}, nil
+		},
+	})
+	store := NewMemoryCacheStore(0)
+	cfg.AddQueryField("cached", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: CachedResolver(func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		}, func(graphql.FieldContext) string {
+			return "cached"
+		}, time.Minute, store),
+	})
+
+	api, err := NewAPI(&cfg)
+	require.NoError(t, err)
+
+	t.Run("Disabled", func(t *testing.T) {
+		explanation = nil
+		resp := executeGraphQL(t, api, `{cached}`)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data":{"cached":1}}`, string(body))
+		assert.Nil(t, explanation)
+	})
+
+	t.Run("Enabled", func(t *testing.T) {
+		explanation = nil
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"query":"{items{b: batched} cached}","extensions":{"explain":true}}`))
+		r.Header.Set("Content-Type", "application/json")
+		api.ServeGraphQL(w, r)
+
+		var resp struct {
+			Data       json.RawMessage
+			Extensions struct {
+				Explain *Explanation
+			}
+		}
+		require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&resp))
+		assert.JSONEq(t, `{"items":[{"b":1},{"b":2}],"cached":1}`, string(resp.Data))
+		require.NotNil(t, resp.Extensions.Explain)
+
+		require.NotNil(t, explanation)
+		require.Len(t, explanation.Fields, 2)
+
+		items := explanation.Fields[0]
+		assert.Equal(t, "Query.items", items.Field)
+		assert.Equal(t, 5, items.Cost)
+		assert.Equal(t, map[string]interface{}{"source": "test"}, items.Annotations)
+		require.Len(t, items.Children, 2)
+		for i, child := range items.Children {
+			assert.Equal(t, "Item.batched", child.Field)
+			assert.Equal(t, graphql.NewPath("items", i, "b"), child.Path)
+			assert.Equal(t, 1, child.Cost)
+			assert.Equal(t, 2, child.BatchSize)
+		}
+
+		cached := explanation.Fields[1]
+		assert.Equal(t, "Query.cached", cached.Field)
+		require.NotNil(t, cached.CacheHit)
+		assert.True(t, *cached.CacheHit)
+	})
+}
+
+func TestExplain_NoEnabledHook(t *testing.T) {
+	var cfg Config
+	cfg.Explain = &ExplainConfig{
+		IncludeInResponse: true,
+	}
+	cfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+	api, err := NewAPI(&cfg)
+	require.NoError(t, err)
+
+	// Clients can't enable explain mode on their own.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"query":"{foo}","extensions":{"explain":true}}`))
+	r.Header.Set("Content-Type", "application/json")
+	api.ServeGraphQL(w, r)
+	assert.JSONEq(t, `{"data":{"foo":1}}`, w.Body.String())
+}
@@ -0,0 +1,365 @@
+package apifu
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// ExportFormat is a format supported by export handlers. See API.ExportHandler.
+type ExportFormat string
+
+const (
+	// Newline-delimited JSON, with one JSON object per edge.
+	ExportFormatNDJSON ExportFormat = "ndjson"
+
+	// Comma-separated values, with a header row containing the column names.
+	ExportFormatCSV ExportFormat = "csv"
+)
+
+// ExportColumn defines a column of an export.
+type ExportColumn struct {
+	// The name of the column. For CSV exports, this is used in the header row. For NDJSON exports,
+	// it's used as the key.
+	Name string
+
+	// The response keys leading from the edge to the value, e.g. []string{"node", "name"}.
+	Path []string
+}
+
+// ExportConfig defines an export endpoint that streams every edge of a connection. See
+// API.ExportHandler.
+type ExportConfig struct {
+	// The operation that selects a page of the connection. It must accept an "after" variable of
+	// type String, which is passed to the connection's after argument, and it must select the
+	// connection's edges along with pageInfo's hasNextPage and endCursor fields:
+	//
+	//	query ($after: String) {
+	//	  users(first: 500, after: $after) {
+	//	    edges { node { id name } }
+	//	    pageInfo { hasNextPage endCursor }
+	//	  }
+	//	}
+	Query string
+
+	// The response keys leading from the operation's data to the connection, e.g.
+	// []string{"users"}.
+	ConnectionPath []string
+
+	// If given, this is invoked to get the operation's variables other than "after". If an error
+	// is returned, the request is rejected with 400 Bad Request.
+	Variables func(r *http.Request) (map[string]interface{}, error)
+
+	// The columns to export. If empty, NDJSON exports contain each edge's node as is and CSV
+	// exports aren't supported.
+	Columns []ExportColumn
+
+	// The maximum number of pages to export. If the connection has more pages, the export fails
+	// once this many have been written. If zero, the default of 10,000 is used.
+	MaxPages int
+}
+
+const defaultExportMaxPages = 10000
+
+func (cfg *ExportConfig) maxPages() int {
+	if cfg.MaxPages > 0 {
+		return cfg.MaxPages
+	}
+	return defaultExportMaxPages
+}
+
+// ExportHandler returns an http.Handler that streams every edge of a connection as NDJSON or CSV.
+// This makes it possible to implement features such as "download all" without clients paginating
+// through thousands of pages.
+//
+// The handler executes the configured operation repeatedly, passing each page's end cursor as the
+// "after" variable of the next, until a page indicates that there are no more edges. Each page is
+// validated and executed just as it would be by ServeGraphQL, so the same resolvers, cost limits,
+// and authorization apply.
+//
+// The format is selected by the "format" query string parameter, which may be "ndjson" or "csv".
+// If it's absent, CSV is used if the Accept header prefers text/csv, and NDJSON is used
+// otherwise.
+//
+// If the first page produces errors, they're written as a normal GraphQL response. Once streaming
+// has started, NDJSON exports end with a line containing an "errors" property if a page fails, and
+// CSV exports are aborted so that the client sees an incomplete response. The export also fails if
+// a page's end cursor is the same as the previous one's or if ExportConfig.MaxPages is exceeded,
+// since either would otherwise allow a misbehaving connection to stream forever.
+func (api *API) ExportHandler(config *ExportConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		format := exportFormat(r)
+		if format == "" || (format == ExportFormatCSV && len(config.Columns) == 0) {
+			http.Error(w, "unsupported format", http.StatusBadRequest)
+			return
+		}
+
+		variables := map[string]interface{}{}
+		if config.Variables != nil {
+			v, err := config.Variables(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for name, value := range v {
+				variables[name] = value
+			}
+		}
+
+		codec := api.jsonCodec()
+		var writer exportWriter
+		started := false
+		var after interface{}
+		for page := 0; ; page++ {
+			var errs []*graphql.Error
+			var connection *graphql.OrderedMap
+			if page >= config.maxPages() {
+				errs = []*graphql.Error{{
+					Message: fmt.Sprintf("The export exceeded its maximum of %v pages.", config.maxPages()),
+				}}
+			} else {
+				variables["after"] = after
+				resp := api.executeExportPage(r, config.Query, variables)
+				connection, errs = exportConnection(resp, config.ConnectionPath)
+			}
+			if len(errs) > 0 {
+				if !started {
+					api.writeGraphQLResponse(w, r, codec, &graphql.Response{
+						Errors: errs,
+					})
+				} else if writer.abort(errs) != nil {
+					panic(http.ErrAbortHandler)
+				}
+				return
+			}
+
+			if !started {
+				started = true
+				writer = newExportWriter(w, codec, format, config.Columns)
+				if err := writer.start(); err != nil {
+					return
+				}
+			}
+
+			edges, _ := connection.Get("edges")
+			edgeList, _ := edges.([]interface{})
+			for _, edge := range edgeList {
+				if err := writer.write(edge); err != nil {
+					return
+				}
+			}
+			if err := writer.flush(); err != nil {
+				return
+			}
+
+			pageInfo, _ := connection.Get("pageInfo")
+			pageInfoMap, _ := pageInfo.(*graphql.OrderedMap)
+			if pageInfoMap == nil {
+				return
+			}
+			hasNextPage, _ := pageInfoMap.Get("hasNextPage")
+			endCursor, _ := pageInfoMap.Get("endCursor")
+			cursor, _ := endCursor.(string)
+			if hasNextPage != true || cursor == "" || len(edgeList) == 0 {
+				return
+			} else if after == cursor {
+				if writer.abort([]*graphql.Error{{
+					Message: "The connection returned the same end cursor for consecutive pages.",
+				}}) != nil {
+					panic(http.ErrAbortHandler)
+				}
+				return
+			}
+			after = cursor
+		}
+	})
+}
+
+// Returns the format requested by the request, or an empty string if the requested format is
+// unsupported.
+func exportFormat(r *http.Request) ExportFormat {
+	if format := r.URL.Query().Get("format"); format != "" {
+		switch ExportFormat(format) {
+		case ExportFormatNDJSON, ExportFormatCSV:
+			return ExportFormat(format)
+		}
+		return ""
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && mediaType == "text/csv" {
+				return ExportFormatCSV
+			}
+		}
+	}
+	return ExportFormatNDJSON
+}
+
+// Executes a single page of an export.
+func (api *API) executeExportPage(r *http.Request, query string, variables map[string]interface{}) *graphql.Response {
+	ctx := context.WithValue(r.Context(), apiContextKey, api)
+	apiRequest := &apiRequest{}
+	ctx = context.WithValue(ctx, apiRequestContextKey, apiRequest)
+	r = r.WithContext(ctx)
+
+	req := &graphql.Request{
+		Query:          query,
+		VariableValues: variables,
+	}
+	api.prepareHTTPRequest(ctx, req, apiRequest)
+	resp, _ := api.executeHTTPOperation(r, req, nil)
+	return resp
+}
+
+// Returns the connection at the given path within the response's data. Any errors in the response
+// are returned, even if the connection is present, so that partial exports aren't mistaken for
+// complete ones.
+func exportConnection(resp *graphql.Response, path []string) (*graphql.OrderedMap, []*graphql.Error) {
+	if len(resp.Errors) > 0 {
+		return nil, resp.Errors
+	}
+	var v interface{}
+	if resp.Data != nil {
+		v = *resp.Data
+	}
+	for _, key := range path {
+		m, _ := v.(*graphql.OrderedMap)
+		if m == nil {
+			v = nil
+			break
+		}
+		v, _ = m.Get(key)
+	}
+	connection, _ := v.(*graphql.OrderedMap)
+	if connection == nil {
+		return nil, []*graphql.Error{{Message: "The export's connection was not found in the response."}}
+	}
+	return connection, nil
+}
+
+// Returns the value at the given path within an edge.
+func exportValue(edge interface{}, path []string) interface{} {
+	for _, key := range path {
+		m, _ := edge.(*graphql.OrderedMap)
+		if m == nil {
+			return nil
+		}
+		edge, _ = m.Get(key)
+	}
+	return edge
+}
+
+type exportWriter struct {
+	w       http.ResponseWriter
+	codec   graphql.JSONCodec
+	format  ExportFormat
+	columns []ExportColumn
+	csv     *csv.Writer
+}
+
+func newExportWriter(w http.ResponseWriter, codec graphql.JSONCodec, format ExportFormat, columns []ExportColumn) exportWriter {
+	ret := exportWriter{
+		w:       w,
+		codec:   codec,
+		format:  format,
+		columns: columns,
+	}
+	if format == ExportFormatCSV {
+		ret.csv = csv.NewWriter(w)
+	}
+	return ret
+}
+
+func (w *exportWriter) start() error {
+	if w.format == ExportFormatCSV {
+		w.w.Header().Set("Content-Type", "text/csv")
+		w.w.WriteHeader(http.StatusOK)
+		names := make([]string, len(w.columns))
+		for i, column := range w.columns {
+			names[i] = column.Name
+		}
+		return w.csv.Write(names)
+	}
+	w.w.Header().Set("Content-Type", "application/x-ndjson")
+	w.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (w *exportWriter) write(edge interface{}) error {
+	if w.format == ExportFormatCSV {
+		record := make([]string, len(w.columns))
+		for i, column := range w.columns {
+			switch v := exportValue(edge, column.Path).(type) {
+			case nil:
+			case string:
+				record[i] = v
+			default:
+				b, err := w.codec.Marshal(v)
+				if err != nil {
+					return err
+				}
+				record[i] = string(b)
+			}
+		}
+		return w.csv.Write(record)
+	}
+
+	var v interface{}
+	if len(w.columns) == 0 {
+		v = exportValue(edge, []string{"node"})
+	} else {
+		m := graphql.NewOrderedMap()
+		for _, column := range w.columns {
+			m.Append(column.Name, exportValue(edge, column.Path))
+		}
+		v = m
+	}
+	b, err := w.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(append(b, '\n'))
+	return err
+}
+
+func (w *exportWriter) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if flusher, ok := w.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// Ends the export due to the given errors. For NDJSON, the errors are written as the final line.
+// Otherwise an error is returned.
+func (w *exportWriter) abort(errs []*graphql.Error) error {
+	if w.format != ExportFormatNDJSON {
+		return fmt.Errorf("export failed: %w", errs[0])
+	}
+	b, err := w.codec.Marshal(map[string]interface{}{
+		"errors": errs,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := w.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return w.flush()
+}
@@ -0,0 +1,272 @@
+package apifu
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestExportHandler(t *testing.T) {
+	type user struct {
+		Id   int
+		Name string
+	}
+	var users []user
+	for i := 0; i < 5; i++ {
+		users = append(users, user{Id: i, Name: fmt.Sprintf("User %v", i)})
+	}
+
+	var pages int
+	var testCfg Config
+	testCfg.AddQueryField("users", Connection(&ConnectionConfig{
+		NamePrefix: "User",
+		ResolveEdges: func(ctx graphql.FieldContext, after, before any, limit int) (any, func(a, b any) bool, error) {
+			pages++
+			var ret []user
+			for _, u := range users {
+				if after == nil || u.Id > after.(int) {
+					ret = append(ret, u)
+				}
+			}
+			return ret, func(a, b any) bool {
+				return a.(int) < b.(int)
+			}, nil
+		},
+		CursorType: reflect.TypeOf(0),
+		EdgeCursor: func(edge any) any {
+			return edge.(user).Id
+		},
+		EdgeFields: map[string]*graphql.FieldDefinition{
+			"node": {
+				Type: &graphql.ObjectType{
+					Name: "User",
+					Fields: map[string]*graphql.FieldDefinition{
+						"id": {
+							Type: graphql.IntType,
+							Resolve: func(ctx graphql.FieldContext) (any, error) {
+								return ctx.Object.(user).Id, nil
+							},
+						},
+						"name": {
+							Type: graphql.StringType,
+							Resolve: func(ctx graphql.FieldContext) (any, error) {
+								return ctx.Object.(user).Name, nil
+							},
+						},
+					},
+				},
+				Resolve: func(ctx graphql.FieldContext) (any, error) {
+					return ctx.Object, nil
+				},
+			},
+		},
+	}))
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	const query = `query ($after: String, $first: Int!) {
+		users(first: $first, after: $after) {
+			edges { node { id name } }
+			pageInfo { hasNextPage endCursor }
+		}
+	}`
+	variables := func(r *http.Request) (map[string]interface{}, error) {
+		if r.URL.Query().Get("invalid") != "" {
+			return map[string]interface{}{"first": "x"}, nil
+		}
+		return map[string]interface{}{"first": 2}, nil
+	}
+
+	handler := api.ExportHandler(&ExportConfig{
+		Query:          query,
+		ConnectionPath: []string{"users"},
+		Variables:      variables,
+	})
+	columnsHandler := api.ExportHandler(&ExportConfig{
+		Query:          query,
+		ConnectionPath: []string{"users"},
+		Variables:      variables,
+		Columns: []ExportColumn{
+			{Name: "ID", Path: []string{"node", "id"}},
+			{Name: "Name", Path: []string{"node", "name"}},
+		},
+	})
+	maxPagesHandler := api.ExportHandler(&ExportConfig{
+		Query:          query,
+		ConnectionPath: []string{"users"},
+		Variables:      variables,
+		MaxPages:       2,
+	})
+
+	for name, tc := range map[string]struct {
+		Handler             http.Handler
+		URL                 string
+		Accept              string
+		ExpectedStatus      int
+		ExpectedContentType string
+		ExpectedBody        string
+		ExpectedPages       int
+	}{
+		"NDJSON": {
+			Handler:             handler,
+			URL:                 "/",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/x-ndjson",
+			ExpectedBody: `{"id":0,"name":"User 0"}
+{"id":1,"name":"User 1"}
+{"id":2,"name":"User 2"}
+{"id":3,"name":"User 3"}
+{"id":4,"name":"User 4"}
+`,
+			ExpectedPages: 3,
+		},
+		"NDJSONColumns": {
+			Handler:             columnsHandler,
+			URL:                 "/?format=ndjson",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/x-ndjson",
+			ExpectedBody: `{"ID":0,"Name":"User 0"}
+{"ID":1,"Name":"User 1"}
+{"ID":2,"Name":"User 2"}
+{"ID":3,"Name":"User 3"}
+{"ID":4,"Name":"User 4"}
+`,
+			ExpectedPages: 3,
+		},
+		"CSV": {
+			Handler:             columnsHandler,
+			URL:                 "/",
+			Accept:              "text/csv",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "text/csv",
+			ExpectedBody:        "ID,Name\n0,User 0\n1,User 1\n2,User 2\n3,User 3\n4,User 4\n",
+			ExpectedPages:       3,
+		},
+		"MaxPages": {
+			Handler:             maxPagesHandler,
+			URL:                 "/",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/x-ndjson",
+			ExpectedBody: `{"id":0,"name":"User 0"}
+{"id":1,"name":"User 1"}
+{"id":2,"name":"User 2"}
+{"id":3,"name":"User 3"}
+{"errors":[{"message":"The export exceeded its maximum of 2 pages."}]}
+`,
+			ExpectedPages: 2,
+		},
+		"CSVWithoutColumns": {
+			Handler:        handler,
+			URL:            "/?format=csv",
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		"UnknownFormat": {
+			Handler:        handler,
+			URL:            "/?format=xml",
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		"Error": {
+			Handler:             handler,
+			URL:                 "/?invalid=1",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/json",
+			ExpectedBody:        `{"errors":[{"message":"Validation error: Invalid $first value: invalid scalar value","locations":[{"line":1,"column":1}]}]}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			pages = 0
+			r := httptest.NewRequest(http.MethodGet, tc.URL, nil)
+			if tc.Accept != "" {
+				r.Header.Set("Accept", tc.Accept)
+			}
+			w := httptest.NewRecorder()
+			tc.Handler.ServeHTTP(w, r)
+			resp := w.Result()
+			require.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+			if tc.ExpectedStatus != http.StatusOK {
+				return
+			}
+			assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), tc.ExpectedContentType))
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			if tc.ExpectedContentType == "application/json" {
+				assert.JSONEq(t, tc.ExpectedBody, string(body))
+			} else {
+				assert.Equal(t, tc.ExpectedBody, string(body))
+			}
+			assert.Equal(t, tc.ExpectedPages, pages)
+		})
+	}
+}
+
+func TestExportHandler_RepeatedCursor(t *testing.T) {
+	var pages int
+	var testCfg Config
+	testCfg.AddQueryField("items", &graphql.FieldDefinition{
+		Type: &graphql.ObjectType{
+			Name: "ItemConnection",
+			Fields: map[string]*graphql.FieldDefinition{
+				"edges": {
+					Type: graphql.NewListType(graphql.IntType),
+					Resolve: func(ctx graphql.FieldContext) (any, error) {
+						return []int{1}, nil
+					},
+				},
+				"pageInfo": {
+					Type: &graphql.ObjectType{
+						Name: "ItemPageInfo",
+						Fields: map[string]*graphql.FieldDefinition{
+							"hasNextPage": {
+								Type: graphql.BooleanType,
+								Resolve: func(ctx graphql.FieldContext) (any, error) {
+									return true, nil
+								},
+							},
+							"endCursor": {
+								Type: graphql.StringType,
+								Resolve: func(ctx graphql.FieldContext) (any, error) {
+									return "stuck", nil
+								},
+							},
+						},
+					},
+					Resolve: func(ctx graphql.FieldContext) (any, error) {
+						return ctx.Object, nil
+					},
+				},
+			},
+		},
+		Arguments: map[string]*graphql.InputValueDefinition{
+			"after": {
+				Type: graphql.StringType,
+			},
+		},
+		Resolve: func(ctx graphql.FieldContext) (any, error) {
+			pages++
+			return struct{}{}, nil
+		},
+	})
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	handler := api.ExportHandler(&ExportConfig{
+		Query:          `query ($after: String) { items(after: $after) { edges pageInfo { hasNextPage endCursor } } }`,
+		ConnectionPath: []string{"items"},
+		Columns:        []ExportColumn{{Name: "n"}},
+	})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "{\"n\":1}\n{\"n\":1}\n{\"errors\":[{\"message\":\"The connection returned the same end cursor for consecutive pages.\"}]}\n", w.Body.String())
+	assert.Equal(t, 2, pages)
+}
@@ -0,0 +1,61 @@
+package apifu
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// ExtensionHandler handles a top-level request extension such as a tracing flag, client metadata,
+// or an A/B test variant. It's given the extension's value as decoded from JSON and returns the
+// context to use for the rest of the request, which allows it to make information available to
+// resolvers.
+//
+// If an error is returned, the request is rejected without being executed. If the error is a
+// *graphql.Error or implements graphql.ExtendedError, its extensions are included in the response.
+type ExtensionHandler func(ctx context.Context, value interface{}) (context.Context, error)
+
+// Invokes the configured extension handlers for the extensions present in the request. Handlers
+// are invoked in order of extension name. If a handler rejects the request, a response containing
+// its error is returned.
+func (api *API) handleExtensions(ctx context.Context, extensions map[string]interface{}) (context.Context, *graphql.Response) {
+	if len(api.config.ExtensionHandlers) == 0 || len(extensions) == 0 {
+		return ctx, nil
+	}
+
+	names := make([]string, 0, len(extensions))
+	for name := range extensions {
+		if _, ok := api.config.ExtensionHandlers[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		newCtx, err := api.config.ExtensionHandlers[name](ctx, extensions[name])
+		if err != nil {
+			return ctx, &graphql.Response{
+				Errors: []*graphql.Error{extensionHandlerError(err)},
+			}
+		}
+		ctx = newCtx
+	}
+	return ctx, nil
+}
+
+func extensionHandlerError(err error) *graphql.Error {
+	var graphqlErr *graphql.Error
+	if errors.As(err, &graphqlErr) {
+		return graphqlErr
+	}
+	ret := &graphql.Error{
+		Message: err.Error(),
+	}
+	var ext graphql.ExtendedError
+	if errors.As(err, &ext) {
+		ret.Extensions = ext.Extensions()
+	}
+	return ret
+}
@@ -0,0 +1,131 @@
+package apifu
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/transport/graphqltransportws"
+)
+
+type variantContextKeyType int
+
+var variantContextKey variantContextKeyType
+
+func TestExtensionHandlers(t *testing.T) {
+	testCfg := Config{
+		ExtensionHandlers: map[string]ExtensionHandler{
+			"variant": func(ctx context.Context, value interface{}) (context.Context, error) {
+				variant, ok := value.(string)
+				if !ok {
+					return nil, graphql.NewCodedError(graphql.ErrorCodeBadUserInput, "The variant extension must be a string.")
+				}
+				return context.WithValue(ctx, variantContextKey, variant), nil
+			},
+		},
+	}
+	testCfg.AddQueryField("variant", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return ctx.Context.Value(variantContextKey), nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+	defer api.CloseHijackedConnections()
+
+	t.Run("HTTP", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			Body     string
+			Expected string
+		}{
+			"None": {
+				Body:     `{"query":"{variant}"}`,
+				Expected: `{"data":{"variant":null}}`,
+			},
+			"Valid": {
+				Body:     `{"query":"{variant}","extensions":{"variant":"b"}}`,
+				Expected: `{"data":{"variant":"b"}}`,
+			},
+			"Invalid": {
+				Body:     `{"query":"{variant}","extensions":{"variant":1}}`,
+				Expected: `{"errors":[{"message":"The variant extension must be a string.","extensions":{"code":"BAD_USER_INPUT","retryable":false}}]}`,
+			},
+			"Unhandled": {
+				Body:     `{"query":"{variant}","extensions":{"foo":1}}`,
+				Expected: `{"data":{"variant":null}}`,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				r, err := http.NewRequest("POST", "", strings.NewReader(tc.Body))
+				require.NoError(t, err)
+				r.Header.Set("Content-Type", "application/json")
+				api.ServeGraphQL(w, r)
+
+				body, err := ioutil.ReadAll(w.Result().Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, tc.Expected, string(body))
+			})
+		}
+	})
+
+	t.Run("WebSocket", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(api.ServeGraphQLWS))
+		defer ts.Close()
+
+		dialer := &websocket.Dialer{
+			HandshakeTimeout: time.Second,
+			Subprotocols:     []string{graphqltransportws.WebSocketSubprotocol},
+		}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.NoError(t, conn.WriteJSON(map[string]string{
+			"type": "connection_init",
+		}))
+		var msg graphqltransportws.Message
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, graphqltransportws.MessageTypeConnectionAck, msg.Type)
+
+		for i, tc := range []struct {
+			Variant  interface{}
+			Expected string
+		}{
+			{"b", `{"data":{"variant":"b"}}`},
+			{1, `{"errors":[{"message":"The variant extension must be a string.","extensions":{"code":"BAD_USER_INPUT","retryable":false}}]}`},
+		} {
+			id := fmt.Sprint(i)
+			require.NoError(t, conn.WriteJSON(map[string]interface{}{
+				"id":   id,
+				"type": "subscribe",
+				"payload": map[string]interface{}{
+					"query": `{variant}`,
+					"extensions": map[string]interface{}{
+						"variant": tc.Variant,
+					},
+				},
+			}))
+
+			require.NoError(t, conn.ReadJSON(&msg))
+			assert.Equal(t, id, msg.Id)
+			assert.Equal(t, graphqltransportws.MessageTypeNext, msg.Type)
+			assert.JSONEq(t, tc.Expected, string(msg.Payload))
+
+			require.NoError(t, conn.ReadJSON(&msg))
+			assert.Equal(t, graphqltransportws.MessageTypeComplete, msg.Type)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package apifu
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// FieldUsageMetrics records which fields are selected by executed operations so that fields which
+// are no longer used can be found. See API.DeadFieldReport. Implementations must be safe for
+// concurrent use. For example, an implementation might keep timestamps in memory (see
+// MemoryFieldUsageMetrics) or in a database shared by all of an API's servers.
+type FieldUsageMetrics interface {
+	// RecordFieldUsage is invoked for each executed operation with the schema coordinates of the
+	// fields it selects, e.g. "User.name". Recording is done on a best effort basis, so any errors
+	// should be handled internally.
+	RecordFieldUsage(ctx context.Context, coordinates []string, t time.Time)
+
+	// FieldLastSeen returns the last time that each field was recorded, keyed by coordinate.
+	// Fields that have never been recorded should be omitted.
+	FieldLastSeen(ctx context.Context) (map[string]time.Time, error)
+}
+
+// withFieldUsage wraps execute so that the fields selected by each operation are recorded if
+// Config.FieldUsageMetrics is given.
+func withFieldUsage(cfg *Config, execute func(*graphql.Request, *RequestInfo) *graphql.Response) func(*graphql.Request, *RequestInfo) *graphql.Response {
+	if cfg.FieldUsageMetrics == nil {
+		return execute
+	}
+	return func(r *graphql.Request, info *RequestInfo) *graphql.Response {
+		if coordinates := graphql.SelectedFields(r.Document, r.Schema, r.Features, r.OperationName); len(coordinates) > 0 {
+			cfg.FieldUsageMetrics.RecordFieldUsage(r.Context, coordinates, clockNow(cfg.Clock))
+		}
+		return execute(r, info)
+	}
+}
+
+// MemoryFieldUsageMetrics is an in-memory FieldUsageMetrics. Its timestamps are lost when the
+// process exits, so it's best suited to long-running processes or tests.
+type MemoryFieldUsageMetrics struct {
+	mutex    sync.Mutex
+	lastSeen map[string]time.Time
+}
+
+var _ FieldUsageMetrics = (*MemoryFieldUsageMetrics)(nil)
+
+// NewMemoryFieldUsageMetrics creates a new, empty MemoryFieldUsageMetrics.
+func NewMemoryFieldUsageMetrics() *MemoryFieldUsageMetrics {
+	return &MemoryFieldUsageMetrics{
+		lastSeen: map[string]time.Time{},
+	}
+}
+
+func (m *MemoryFieldUsageMetrics) RecordFieldUsage(ctx context.Context, coordinates []string, t time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, coordinate := range coordinates {
+		if t.After(m.lastSeen[coordinate]) {
+			m.lastSeen[coordinate] = t
+		}
+	}
+}
+
+func (m *MemoryFieldUsageMetrics) FieldLastSeen(ctx context.Context) (map[string]time.Time, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	ret := make(map[string]time.Time, len(m.lastSeen))
+	for coordinate, t := range m.lastSeen {
+		ret[coordinate] = t
+	}
+	return ret, nil
+}
+
+// DeadFieldReport lists the fields of an API's schema that haven't been selected by any operation
+// within a time window. It can be marshaled to JSON for export.
+type DeadFieldReport struct {
+	// The beginning of the time window.
+	Since time.Time `json:"since"`
+
+	// The time at which the report was generated, which is the end of the time window.
+	GeneratedAt time.Time `json:"generatedAt"`
+
+	// The fields that weren't selected within the time window, sorted by coordinate.
+	Fields []*DeadField `json:"fields"`
+}
+
+// DeadField describes a field that hasn't been selected within a DeadFieldReport's time window.
+type DeadField struct {
+	// The field's schema coordinate, e.g. "User.name".
+	Coordinate string `json:"coordinate"`
+
+	// The last time the field was selected, if it has ever been recorded.
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
+
+	// True if the field is already deprecated.
+	Deprecated bool `json:"deprecated,omitempty"`
+}
+
+// DeadFieldReport generates a report of the object and interface fields which haven't been selected
+// by any operation within the given window, according to Config.FieldUsageMetrics. This can be
+// used to guide schema pruning. Note that the accuracy of the report depends on how long usage has
+// been recorded for: fields which have never been recorded may simply predate the metrics.
+//
+// If Config.FieldUsageMetrics isn't given, an error is returned.
+func (api *API) DeadFieldReport(ctx context.Context, window time.Duration) (*DeadFieldReport, error) {
+	if api.config.FieldUsageMetrics == nil {
+		return nil, errors.New("field usage metrics are not configured")
+	}
+	lastSeen, err := api.config.FieldUsageMetrics.FieldLastSeen(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting field usage")
+	}
+
+	now := clockNow(api.config.Clock)
+	report := &DeadFieldReport{
+		Since:       now.Add(-window),
+		GeneratedAt: now,
+		Fields:      []*DeadField{},
+	}
+	addFields := func(typeName string, fields map[string]*graphql.FieldDefinition) {
+		for name, def := range fields {
+			coordinate := typeName + "." + name
+			t, ok := lastSeen[coordinate]
+			if ok && !t.Before(report.Since) {
+				continue
+			}
+			field := &DeadField{
+				Coordinate: coordinate,
+				Deprecated: def.DeprecationReason != "",
+			}
+			if ok {
+				field.LastSeen = &t
+			}
+			report.Fields = append(report.Fields, field)
+		}
+	}
+	for _, t := range api.schema.NamedTypes() {
+		switch t := t.(type) {
+		case *graphql.ObjectType:
+			addFields(t.Name, t.Fields)
+		case *graphql.InterfaceType:
+			addFields(t.Name, t.Fields)
+		}
+	}
+	sort.Slice(report.Fields, func(i, j int) bool {
+		return report.Fields[i].Coordinate < report.Fields[j].Coordinate
+	})
+	return report, nil
+}
@@ -0,0 +1,82 @@
+package apifu
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestDeadFieldReport(t *testing.T) {
+	metrics := NewMemoryFieldUsageMetrics()
+	testCfg := Config{
+		FieldUsageMetrics: metrics,
+	}
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+	testCfg.AddQueryField("bar", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+	testCfg.AddQueryField("baz", &graphql.FieldDefinition{
+		Type:              graphql.IntType,
+		DeprecationReason: "Use foo.",
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	lastYear := time.Now().Add(-365 * 24 * time.Hour).UTC()
+	metrics.RecordFieldUsage(context.Background(), []string{"Query.bar"}, lastYear)
+
+	executeGraphQL(t, api, `{foo __typename}`)
+
+	report, err := api.DeadFieldReport(context.Background(), 30*24*time.Hour)
+	require.NoError(t, err)
+
+	fields := map[string]*DeadField{}
+	for _, field := range report.Fields {
+		fields[field.Coordinate] = field
+	}
+	assert.NotContains(t, fields, "Query.foo")
+	require.Contains(t, fields, "Query.bar")
+	require.NotNil(t, fields["Query.bar"].LastSeen)
+	assert.True(t, lastYear.Equal(*fields["Query.bar"].LastSeen))
+	require.Contains(t, fields, "Query.baz")
+	assert.Nil(t, fields["Query.baz"].LastSeen)
+	assert.True(t, fields["Query.baz"].Deprecated)
+
+	// The node field is added to every API's query type.
+	assert.Contains(t, fields, "Query.node")
+
+	buf, err := json.Marshal(fields["Query.baz"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"coordinate":"Query.baz","deprecated":true}`, string(buf))
+}
+
+func TestDeadFieldReport_NoMetrics(t *testing.T) {
+	var testCfg Config
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	_, err = api.DeadFieldReport(context.Background(), time.Hour)
+	assert.Error(t, err)
+}
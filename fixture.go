@@ -0,0 +1,173 @@
+package apifu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// Fixture records the outputs of resolvers so that they can be replayed later. This enables
+// deterministic integration tests of complete operations without the underlying datastore.
+//
+// Only resolvers wrapped with FixtureResolver participate, and only when executed via the
+// fixture's Execute function. Outputs are keyed by the field's path and arguments, so a replayed
+// operation must select the same fields as the recorded one.
+//
+// A typical test records a fixture once against a real datastore, commits it, and replays it
+// thereafter:
+//
+//	cfg.Execute = fixture.Execute(nil)
+type Fixture struct {
+	replay bool
+
+	mutex   sync.Mutex
+	entries map[string]*fixtureEntry
+}
+
+type fixtureEntry struct {
+	Value json.RawMessage `json:"value,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// NewFixtureRecorder creates a fixture that records resolver outputs. Once the operations have been
+// executed, use Save to write the fixture.
+func NewFixtureRecorder() *Fixture {
+	return &Fixture{
+		entries: map[string]*fixtureEntry{},
+	}
+}
+
+// LoadFixture reads a fixture written by Save. The returned fixture replays the recorded outputs
+// instead of invoking resolvers.
+func LoadFixture(r io.Reader) (*Fixture, error) {
+	entries := map[string]*fixtureEntry{}
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("error decoding fixture: %w", err)
+	}
+	return &Fixture{
+		replay:  true,
+		entries: entries,
+	}, nil
+}
+
+// Save writes the fixture's outputs as JSON. The output is deterministic, so it's suitable for
+// committing alongside tests.
+func (f *Fixture) Save(w io.Writer) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	b, err := json.MarshalIndent(f.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding fixture: %w", err)
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+type fixtureContextKeyType int
+
+var fixtureContextKey fixtureContextKeyType
+
+type fixtureContext struct {
+	fixture *Fixture
+	key     string
+}
+
+// Execute wraps an execute function such as Config.Execute so that the resolvers it invokes record
+// or replay their outputs. If execute is nil, graphql.Execute is used.
+func (f *Fixture) Execute(execute func(*graphql.Request, *RequestInfo) *graphql.Response) func(*graphql.Request, *RequestInfo) *graphql.Response {
+	if execute == nil {
+		execute = func(r *graphql.Request, info *RequestInfo) *graphql.Response {
+			return graphql.Execute(r)
+		}
+	}
+	return func(r *graphql.Request, info *RequestInfo) *graphql.Response {
+		req := *r
+		observeField := req.ObserveField
+		req.ObserveField = func(ctx context.Context, field *graphql.ObservedField) (context.Context, func(error)) {
+			var done func(error)
+			if observeField != nil {
+				ctx, done = observeField(ctx, field)
+			}
+			return context.WithValue(ctx, fixtureContextKey, &fixtureContext{
+				fixture: f,
+				key:     fixtureKey(field),
+			}), done
+		}
+		return execute(&req, info)
+	}
+}
+
+// Returns the key for a field's output, e.g. `.users[0].posts({"first":10})`.
+func fixtureKey(field *graphql.ObservedField) string {
+	key := pathKey(field.Path)
+	if len(field.Arguments) > 0 {
+		if b, err := json.Marshal(field.Arguments); err == nil {
+			key += "(" + string(b) + ")"
+		}
+	}
+	return key
+}
+
+func (f *Fixture) record(key string, value interface{}, err error) {
+	entry := &fixtureEntry{}
+	if !isNil(err) {
+		entry.Error = err.Error()
+	} else if b, err := json.Marshal(value); err != nil {
+		entry.Error = fmt.Sprintf("unable to record %T: %v", value, err)
+	} else {
+		entry.Value = b
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.entries[key] = entry
+}
+
+// FixtureResolver wraps a resolver so that its outputs can be recorded and replayed by a Fixture.
+// The resolver's values must be of type T (or nil) and must survive a round-trip through JSON. When
+// the request isn't being executed by a fixture, the resolver is invoked normally.
+//
+// Errors are recorded too, but are replayed as plain errors with the same message.
+func FixtureResolver[T any](resolve func(graphql.FieldContext) (interface{}, error)) func(graphql.FieldContext) (interface{}, error) {
+	return func(ctx graphql.FieldContext) (interface{}, error) {
+		fctx, _ := ctx.Context.Value(fixtureContextKey).(*fixtureContext)
+		if fctx == nil {
+			return resolve(ctx)
+		}
+		f := fctx.fixture
+
+		if f.replay {
+			f.mutex.Lock()
+			entry, ok := f.entries[fctx.key]
+			f.mutex.Unlock()
+			if !ok {
+				return nil, fmt.Errorf("no fixture output recorded for %v", fctx.key)
+			} else if entry.Error != "" {
+				return nil, fmt.Errorf("%v", entry.Error)
+			} else if len(entry.Value) == 0 || string(entry.Value) == "null" {
+				return nil, nil
+			}
+			var value T
+			if err := json.Unmarshal(entry.Value, &value); err != nil {
+				return nil, fmt.Errorf("unable to replay fixture output for %v: %w", fctx.key, err)
+			}
+			return value, nil
+		}
+
+		v, err := resolve(ctx)
+		if promise, ok := v.(graphql.ResolvePromise); ok && isNil(err) {
+			return chainResult(ctx.Context, promise, func(result graphql.ResolveResult, ctxErr error) (interface{}, error) {
+				if ctxErr != nil {
+					return nil, ctxErr
+				}
+				f.record(fctx.key, result.Value, result.Error)
+				return result.Value, result.Error
+			}), nil
+		}
+		f.record(fctx.key, v, err)
+		return v, err
+	}
+}
@@ -0,0 +1,97 @@
+package apifu
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestFixture(t *testing.T) {
+	type User struct {
+		Name string
+	}
+
+	userType := &graphql.ObjectType{
+		Name: "User",
+		Fields: map[string]*graphql.FieldDefinition{
+			"name": NonNull(graphql.StringType, "Name"),
+		},
+	}
+
+	newAPI := func(fixture *Fixture, datastore map[string]*User) *API {
+		var cfg Config
+		cfg.Execute = fixture.Execute(nil)
+		cfg.AddQueryField("user", &graphql.FieldDefinition{
+			Type: userType,
+			Arguments: map[string]*graphql.InputValueDefinition{
+				"id": {
+					Type: graphql.NewNonNullType(graphql.IDType),
+				},
+			},
+			Resolve: FixtureResolver[*User](func(ctx graphql.FieldContext) (interface{}, error) {
+				id := ctx.Arguments["id"].(string)
+				if user, ok := datastore[id]; ok {
+					return user, nil
+				}
+				return nil, fmt.Errorf("user %v not found", id)
+			}),
+		})
+		cfg.AddQueryField("asyncUser", &graphql.FieldDefinition{
+			Type: userType,
+			Resolve: FixtureResolver[*User](func(ctx graphql.FieldContext) (interface{}, error) {
+				return Go(ctx.Context, func() (interface{}, error) {
+					return datastore["async"], nil
+				}), nil
+			}),
+		})
+		api, err := NewAPI(&cfg)
+		require.NoError(t, err)
+		return api
+	}
+
+	const query = `{a: user(id: "a") {name} b: user(id: "b") {name} asyncUser {name}}`
+	const expected = `{"data":{"a":{"name":"Alice"},"b":null,"asyncUser":{"name":"Async"}},"errors":[{"message":"user b not found","locations":[{"line":1,"column":26}],"path":["b"]}]}`
+
+	recorder := NewFixtureRecorder()
+	resp := executeGraphQL(t, newAPI(recorder, map[string]*User{
+		"a":     {Name: "Alice"},
+		"async": {Name: "Async"},
+	}), query)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, expected, string(body))
+
+	var buf bytes.Buffer
+	require.NoError(t, recorder.Save(&buf))
+	assert.JSONEq(t, `{
+		".a({\"id\":\"a\"})": {"value": {"Name": "Alice"}},
+		".b({\"id\":\"b\"})": {"error": "user b not found"},
+		".asyncUser": {"value": {"Name": "Async"}}
+	}`, buf.String())
+
+	t.Run("Replay", func(t *testing.T) {
+		fixture, err := LoadFixture(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+
+		resp := executeGraphQL(t, newAPI(fixture, nil), query)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, expected, string(body))
+	})
+
+	t.Run("ReplayMissing", func(t *testing.T) {
+		fixture, err := LoadFixture(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+
+		resp := executeGraphQL(t, newAPI(fixture, nil), `{user(id: "c") {name}}`)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `no fixture output recorded for .user({\"id\":\"c\"})`)
+	})
+}
@@ -0,0 +1,317 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/executor"
+)
+
+type planExecution struct {
+	context   context.Context
+	variables map[string]interface{}
+
+	// mutex guards data and errors, which are written to as fetches complete.
+	mutex  sync.Mutex
+	data   map[string]interface{}
+	errors []*graphql.Error
+}
+
+func (g *Gateway) executePlan(ctx context.Context, plan *QueryPlan, variables map[string]interface{}) (map[string]interface{}, []*graphql.Error) {
+	e := &planExecution{
+		context:   ctx,
+		variables: variables,
+		data:      map[string]interface{}{},
+	}
+	if plan.Serial {
+		for _, fetch := range plan.Fetches {
+			e.executeFetch(fetch)
+		}
+	} else {
+		e.executeFetches(plan.Fetches)
+	}
+	return e.data, e.errors
+}
+
+func (e *planExecution) executeFetches(fetches []*Fetch) {
+	var wg sync.WaitGroup
+	for _, fetch := range fetches {
+		wg.Add(1)
+		fetch := fetch
+		go func() {
+			defer wg.Done()
+			e.executeFetch(fetch)
+		}()
+	}
+	wg.Wait()
+}
+
+func (e *planExecution) addError(err *graphql.Error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.errors = append(e.errors, err)
+}
+
+func (e *planExecution) executeFetch(fetch *Fetch) {
+	req := &ServiceRequest{
+		Query: fetch.Query,
+	}
+	for _, name := range fetch.Variables {
+		if v, ok := e.variables[name]; ok {
+			if req.Variables == nil {
+				req.Variables = map[string]interface{}{}
+			}
+			req.Variables[name] = v
+		}
+	}
+
+	var targets []entityTarget
+	if fetch.TypeName != "" {
+		// Sibling fetches may be merging their results into the targets concurrently, so the
+		// representations must be built while holding the lock.
+		e.mutex.Lock()
+		targets = collectEntityTargets(e.data, fetch.Path, fetch.TypeName, nil)
+		representations := make([]interface{}, len(targets))
+		for i, target := range targets {
+			representation := map[string]interface{}{
+				"__typename": fetch.TypeName,
+			}
+			for _, key := range fetch.Service.Keys[fetch.TypeName] {
+				representation[key] = target.Object[keyAliasPrefix+key]
+			}
+			representations[i] = representation
+		}
+		e.mutex.Unlock()
+		if len(targets) == 0 {
+			return
+		}
+		if req.Variables == nil {
+			req.Variables = map[string]interface{}{}
+		}
+		req.Variables[representationsVariable] = representations
+	}
+
+	resp, err := fetch.Service.Execute(e.context, req)
+	if err != nil {
+		e.addError(&graphql.Error{
+			Message: fmt.Sprintf("Error fetching from %v: %v", fetch.Service.Name, err.Error()),
+		})
+		return
+	}
+
+	e.mutex.Lock()
+	if fetch.TypeName == "" {
+		mergeValues(e.data, resp.Data)
+		e.errors = append(e.errors, resp.Errors...)
+	} else {
+		entities, _ := resp.Data["_entities"].([]interface{})
+		for i, target := range targets {
+			if i < len(entities) {
+				if entity, ok := entities[i].(map[string]interface{}); ok {
+					mergeValues(target.Object, entity)
+				}
+			}
+		}
+		for _, err := range resp.Errors {
+			// Map errors for entities back to the entities' paths in the gateway's response.
+			if len(err.Path) >= 2 && err.Path[0] == graphql.KeySegment("_entities") {
+				if i := err.Path[1]; i.IsIndex() && i.Index < len(targets) {
+					err.Path = targets[i.Index].Path.Append(err.Path[2:]...)
+				}
+			}
+			err.Locations = nil
+			e.errors = append(e.errors, err)
+		}
+	}
+	e.mutex.Unlock()
+
+	e.executeFetches(fetch.Children)
+}
+
+type entityTarget struct {
+	Object map[string]interface{}
+	Path   graphql.Path
+}
+
+// Finds the objects at the given path which are of the given type. Lists are traversed implicitly.
+func collectEntityTargets(v interface{}, path []string, typeName string, responsePath graphql.Path) []entityTarget {
+	switch v := v.(type) {
+	case []interface{}:
+		var ret []entityTarget
+		for i, item := range v {
+			ret = append(ret, collectEntityTargets(item, path, typeName, responsePath.Append(graphql.IndexSegment(i)))...)
+		}
+		return ret
+	case map[string]interface{}:
+		if len(path) == 0 {
+			if v[typenameAlias] == typeName {
+				return []entityTarget{{
+					Object: v,
+					Path:   responsePath,
+				}}
+			}
+			return nil
+		}
+		return collectEntityTargets(v[path[0]], path[1:], typeName, responsePath.Append(graphql.KeySegment(path[0])))
+	}
+	return nil
+}
+
+// Recursively merges the fields of src into dest.
+func mergeValues(dest, src map[string]interface{}) {
+	for k, v := range src {
+		if existing, ok := dest[k]; ok {
+			dest[k] = mergeValue(existing, v)
+		} else {
+			dest[k] = v
+		}
+	}
+}
+
+func mergeValue(dest, src interface{}) interface{} {
+	switch dest := dest.(type) {
+	case map[string]interface{}:
+		if src, ok := src.(map[string]interface{}); ok {
+			mergeValues(dest, src)
+			return dest
+		}
+	case []interface{}:
+		if src, ok := src.([]interface{}); ok && len(src) == len(dest) {
+			for i := range dest {
+				dest[i] = mergeValue(dest[i], src[i])
+			}
+			return dest
+		}
+	}
+	if src == nil {
+		return dest
+	}
+	return src
+}
+
+type responseField struct {
+	key        string
+	selections []ast.Selection
+
+	// The type conditions that each occurrence of the field is nested within. An occurrence with
+	// no type conditions applies to any object.
+	conditions [][]string
+}
+
+// Collects the response keys for the selections in order, along with their merged sub-selections
+// and the type conditions they were selected within.
+func collectResponseFields(fragments map[string]*ast.FragmentDefinition, selections []ast.Selection, conditions []string, fields []*responseField, index map[string]*responseField) []*responseField {
+	for _, selection := range selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			key := selection.Name.Name
+			if selection.Alias != nil {
+				key = selection.Alias.Name
+			}
+			field, ok := index[key]
+			if !ok {
+				field = &responseField{
+					key: key,
+				}
+				index[key] = field
+				fields = append(fields, field)
+			}
+			field.conditions = append(field.conditions, conditions)
+			if selection.SelectionSet != nil {
+				field.selections = append(field.selections, selection.SelectionSet.Selections...)
+			}
+		case *ast.InlineFragment:
+			fragmentConditions := conditions
+			if selection.TypeCondition != nil {
+				fragmentConditions = appendCondition(conditions, selection.TypeCondition.Name.Name)
+			}
+			fields = collectResponseFields(fragments, selection.SelectionSet.Selections, fragmentConditions, fields, index)
+		case *ast.FragmentSpread:
+			if def, ok := fragments[selection.FragmentName.Name]; ok {
+				fields = collectResponseFields(fragments, def.SelectionSet.Selections, appendCondition(conditions, def.TypeCondition.Name.Name), fields, index)
+			}
+		}
+	}
+	return fields
+}
+
+// Appends without modifying the backing array of conditions, which may be shared by siblings.
+func appendCondition(conditions []string, typeName string) []string {
+	ret := make([]string, len(conditions), len(conditions)+1)
+	copy(ret, conditions)
+	return append(ret, typeName)
+}
+
+// Returns true if the field is selected for objects of the given type. If the type is unknown,
+// only unconditional occurrences are considered.
+func (f *responseField) appliesTo(s *graphql.Schema, typeName string) bool {
+	for _, conditions := range f.conditions {
+		applies := true
+		for _, condition := range conditions {
+			if typeName == "" || !typeConditionApplies(s, condition, typeName) {
+				applies = false
+				break
+			}
+		}
+		if applies {
+			return true
+		}
+	}
+	return false
+}
+
+func typeConditionApplies(s *graphql.Schema, condition, typeName string) bool {
+	if condition == typeName {
+		return true
+	}
+	switch t := s.NamedTypes()[condition].(type) {
+	case *graphql.InterfaceType:
+		for _, impl := range s.InterfaceImplementations(t.Name) {
+			if impl.Name == typeName {
+				return true
+			}
+		}
+	case *graphql.UnionType:
+		for _, member := range t.MemberTypes {
+			if member.Name == typeName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Converts merged fetch results into the final response data, putting fields in the order they
+// were requested and removing any fields that were added by the gateway. Fields that apply to the
+// object but are missing from it, such as those whose fetch failed, are completed as null.
+func completeObject(s *graphql.Schema, fragments map[string]*ast.FragmentDefinition, selections []ast.Selection, typeName string, object map[string]interface{}) *executor.OrderedMap {
+	if typeName == "" {
+		typeName, _ = object[typenameAlias].(string)
+	}
+	ret := executor.NewOrderedMap()
+	for _, field := range collectResponseFields(fragments, selections, nil, nil, map[string]*responseField{}) {
+		if v, ok := object[field.key]; ok {
+			ret.Append(field.key, completeValue(s, fragments, field.selections, v))
+		} else if field.appliesTo(s, typeName) {
+			ret.Append(field.key, nil)
+		}
+	}
+	return ret
+}
+
+func completeValue(s *graphql.Schema, fragments map[string]*ast.FragmentDefinition, selections []ast.Selection, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return completeObject(s, fragments, selections, "", v)
+	case []interface{}:
+		ret := make([]interface{}, len(v))
+		for i, item := range v {
+			ret[i] = completeValue(s, fragments, selections, item)
+		}
+		return ret
+	}
+	return v
+}
@@ -0,0 +1,318 @@
+// Package gateway is an experimental GraphQL gateway. It composes the schemas of several remote
+// GraphQL services into a single schema and executes queries against it by planning and issuing
+// requests to the services that own the selected fields.
+//
+// Entities can be shared between services in the style of Apollo Federation: if multiple services
+// define fields for the same object type, the type must have keys declared for each of those
+// services, and each service must expose an `_entities(representations: [_Any!]!): [_Entity]!`
+// field on its query type which resolves representations of the type. A representation is an
+// object containing the "__typename" and key fields of the entity.
+//
+// This package is experimental. Its API may change, and it does not yet support subscriptions or
+// entity fetches for fields of abstract types.
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/schema"
+	"github.com/ccbrown/api-fu/graphql/schema/introspection"
+)
+
+// ServiceRequest is a request sent by the gateway to a service.
+type ServiceRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// ServiceResponse is a service's response to a ServiceRequest.
+type ServiceResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []*graphql.Error       `json:"errors"`
+}
+
+// Service describes a remote GraphQL service.
+type Service struct {
+	// Name is used to identify the service in query plans and errors.
+	Name string
+
+	// Schema is the service's schema as returned by an introspection query.
+	Schema *introspection.SchemaData
+
+	// Keys maps entity type names to the names of the fields that uniquely identify them. Key
+	// fields must be scalars or enums.
+	Keys map[string][]string
+
+	// Execute sends a request to the service. See NewHTTPExecutor for a typical implementation.
+	Execute func(ctx context.Context, r *ServiceRequest) (*ServiceResponse, error)
+}
+
+// NewHTTPExecutor returns a function suitable for Service.Execute which sends requests to the given
+// URL as JSON POST requests. If client is nil, http.DefaultClient is used.
+func NewHTTPExecutor(url string, client *http.Client) func(ctx context.Context, r *ServiceRequest) (*ServiceResponse, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context, r *ServiceRequest) (*ServiceResponse, error) {
+		body, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status code: %v", resp.StatusCode)
+		}
+		var ret ServiceResponse
+		if err := json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+			return nil, err
+		}
+		return &ret, nil
+	}
+}
+
+// Config defines the services that make up the gateway.
+type Config struct {
+	Services []*Service
+}
+
+// Gateway executes queries across multiple services.
+type Gateway struct {
+	schema   *graphql.Schema
+	services []*Service
+
+	// owners maps type names to field names to the services that define them, in the order they
+	// were given in the config.
+	owners map[string]map[string][]*Service
+}
+
+// These types and fields are used for entity resolution and aren't exposed by the gateway.
+var federationTypes = map[string]struct{}{
+	"_Any":     {},
+	"_Entity":  {},
+	"_Service": {},
+}
+
+var federationQueryFields = map[string]struct{}{
+	"_entities": {},
+	"_service":  {},
+}
+
+// New composes the services' schemas and builds a Gateway.
+func New(cfg *Config) (*Gateway, error) {
+	if len(cfg.Services) == 0 {
+		return nil, fmt.Errorf("at least one service is required")
+	}
+
+	g := &Gateway{
+		services: cfg.Services,
+		owners:   map[string]map[string][]*Service{},
+	}
+
+	merged := &introspection.SchemaData{}
+	types := map[string]*introspection.TypeData{}
+	var typeNames []string
+	directives := map[string]struct{}{}
+
+	for _, service := range cfg.Services {
+		if service.Schema == nil {
+			return nil, fmt.Errorf("service %v has no schema", service.Name)
+		} else if service.Execute == nil {
+			return nil, fmt.Errorf("service %v has no executor", service.Name)
+		}
+
+		if merged.QueryType.Name == "" {
+			merged.QueryType = service.Schema.QueryType
+		} else if service.Schema.QueryType.Name != merged.QueryType.Name {
+			return nil, fmt.Errorf("service %v has a different query type name: %v", service.Name, service.Schema.QueryType.Name)
+		}
+		if t := service.Schema.MutationType; t != nil {
+			if merged.MutationType == nil {
+				merged.MutationType = t
+			} else if t.Name != merged.MutationType.Name {
+				return nil, fmt.Errorf("service %v has a different mutation type name: %v", service.Name, t.Name)
+			}
+		}
+
+		for _, t := range service.Schema.Types {
+			if _, ok := federationTypes[t.Name]; ok || strings.HasPrefix(t.Name, "__") {
+				continue
+			}
+
+			if t.Kind == "OBJECT" || t.Kind == "INTERFACE" {
+				fieldOwners, ok := g.owners[t.Name]
+				if !ok {
+					fieldOwners = map[string][]*Service{}
+					g.owners[t.Name] = fieldOwners
+				}
+				for _, field := range t.Fields {
+					if _, ok := federationQueryFields[field.Name]; ok && t.Name == service.Schema.QueryType.Name {
+						continue
+					}
+					fieldOwners[field.Name] = append(fieldOwners[field.Name], service)
+				}
+			}
+
+			existing, ok := types[t.Name]
+			if !ok {
+				t := t
+				t.Fields = nil
+				types[t.Name] = &t
+				typeNames = append(typeNames, t.Name)
+				existing = &t
+			} else if existing.Kind != t.Kind {
+				return nil, fmt.Errorf("service %v defines %v as a different kind of type", service.Name, t.Name)
+			}
+			mergeTypeData(existing, t, t.Name == service.Schema.QueryType.Name)
+		}
+
+		for _, d := range service.Schema.Directives {
+			if _, ok := directives[d.Name]; !ok {
+				directives[d.Name] = struct{}{}
+				merged.Directives = append(merged.Directives, d)
+			}
+		}
+	}
+
+	for _, name := range typeNames {
+		merged.Types = append(merged.Types, *types[name])
+	}
+
+	def, err := merged.GetSchemaDefinition()
+	if err != nil {
+		return nil, fmt.Errorf("error building schema definition: %w", err)
+	}
+	s, err := schema.New(def)
+	if err != nil {
+		return nil, fmt.Errorf("error building schema: %w", err)
+	}
+	for k, v := range introspection.MetaFields {
+		s.QueryType().Fields[k] = v
+	}
+	g.schema = s
+	return g, nil
+}
+
+// Merges the fields, interfaces, and possible types of src into dest.
+func mergeTypeData(dest *introspection.TypeData, src introspection.TypeData, isQueryType bool) {
+	for _, field := range src.Fields {
+		if _, ok := federationQueryFields[field.Name]; ok && isQueryType {
+			continue
+		}
+		exists := false
+		for _, existing := range dest.Fields {
+			if existing.Name == field.Name {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			dest.Fields = append(dest.Fields, field)
+		}
+	}
+	dest.Interfaces = mergeTypeDataNames(dest.Interfaces, src.Interfaces)
+	dest.PossibleTypes = mergeTypeDataNames(dest.PossibleTypes, src.PossibleTypes)
+}
+
+func mergeTypeDataNames(dest, src []introspection.TypeData) []introspection.TypeData {
+	for _, t := range src {
+		if _, ok := federationTypes[t.Name]; ok {
+			continue
+		}
+		exists := false
+		for _, existing := range dest {
+			if existing.Name == t.Name {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			dest = append(dest, t)
+		}
+	}
+	return dest
+}
+
+// Schema returns the composed schema.
+func (g *Gateway) Schema() *graphql.Schema {
+	return g.schema
+}
+
+// Execute executes a request against the gateway's services. The request's Schema and IdleHandler
+// fields are ignored.
+func (g *Gateway) Execute(r *graphql.Request) *graphql.Response {
+	ctx := r.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	doc := r.Document
+	if doc == nil {
+		var errs []*graphql.Error
+		doc, errs = graphql.ParseAndValidate(r.Query, g.schema, r.Features)
+		if len(errs) > 0 {
+			return &graphql.Response{
+				Errors: errs,
+			}
+		}
+	}
+
+	plan, err := g.Plan(doc, r.OperationName)
+	if err != nil {
+		return &graphql.Response{
+			Errors: []*graphql.Error{{Message: err.Error()}},
+		}
+	}
+
+	if plan.Introspection {
+		req := *r
+		req.Context = ctx
+		req.Document = doc
+		req.Schema = g.schema
+		req.IdleHandler = nil
+		return graphql.Execute(&req)
+	}
+
+	data, errs := g.executePlan(ctx, plan, r.VariableValues)
+	var dataInterface interface{} = completeObject(g.schema, plan.fragments, plan.operation.SelectionSet.Selections, plan.rootType.Name, data)
+	return &graphql.Response{
+		Data:   &dataInterface,
+		Errors: errs,
+	}
+}
+
+// ServeGraphQL serves GraphQL HTTP requests in the same manner as apifu.API.ServeGraphQL.
+func (g *Gateway) ServeGraphQL(w http.ResponseWriter, r *http.Request) {
+	req, code, err := graphql.NewRequestFromHTTP(r)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+
+	body, err := jsoniter.Marshal(g.Execute(req))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
@@ -0,0 +1,400 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/schema/introspection"
+)
+
+var anyType = &graphql.ScalarType{
+	Name: "_Any",
+	LiteralCoercion: func(v ast.Value) interface{} {
+		return nil
+	},
+	VariableValueCoercion: func(v interface{}) interface{} {
+		return v
+	},
+	ResultCoercion: func(v interface{}) interface{} {
+		return v
+	},
+}
+
+type user struct {
+	Id   string
+	Name string
+}
+
+type review struct {
+	Body     string
+	AuthorId string
+}
+
+var users = map[string]*user{
+	"1": {Id: "1", Name: "Alice"},
+	"2": {Id: "2", Name: "Bob"},
+}
+
+var reviews = []*review{
+	{Body: "Great!", AuthorId: "1"},
+	{Body: "Meh.", AuthorId: "2"},
+	{Body: "Awful.", AuthorId: "1"},
+}
+
+// Builds a query type with an _entities field that resolves representations using the given
+// function.
+func entitiesQueryType(fields map[string]*graphql.FieldDefinition, entityTypes []*graphql.ObjectType, resolve func(typename, id string) interface{}) *graphql.ObjectType {
+	fields["_entities"] = &graphql.FieldDefinition{
+		Type: graphql.NewNonNullType(graphql.NewListType(&graphql.UnionType{
+			Name:        "_Entity",
+			MemberTypes: entityTypes,
+		})),
+		Arguments: map[string]*graphql.InputValueDefinition{
+			"representations": {
+				Type: graphql.NewNonNullType(graphql.NewListType(graphql.NewNonNullType(anyType))),
+			},
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			var ret []interface{}
+			for _, representation := range ctx.Arguments["representations"].([]interface{}) {
+				representation := representation.(map[string]interface{})
+				ret = append(ret, resolve(representation["__typename"].(string), representation["id"].(string)))
+			}
+			return ret, nil
+		},
+	}
+	return &graphql.ObjectType{
+		Name:   "Query",
+		Fields: fields,
+	}
+}
+
+func newAccountsSchema(t *testing.T) *graphql.Schema {
+	userType := &graphql.ObjectType{
+		Name: "User",
+		Fields: map[string]*graphql.FieldDefinition{
+			"id": {
+				Type: graphql.NewNonNullType(graphql.IDType),
+				Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+					return ctx.Object.(*user).Id, nil
+				},
+			},
+			"name": {
+				Type: graphql.StringType,
+				Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+					return ctx.Object.(*user).Name, nil
+				},
+			},
+		},
+		IsTypeOf: func(v interface{}) bool {
+			_, ok := v.(*user)
+			return ok
+		},
+	}
+	s, err := graphql.NewSchema(&graphql.SchemaDefinition{
+		Query: entitiesQueryType(map[string]*graphql.FieldDefinition{
+			"me": {
+				Type: userType,
+				Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+					return users["1"], nil
+				},
+			},
+		}, []*graphql.ObjectType{userType}, func(typename, id string) interface{} {
+			return users[id]
+		}),
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func newReviewsSchema(t *testing.T) *graphql.Schema {
+	userType := &graphql.ObjectType{
+		Name: "User",
+		Fields: map[string]*graphql.FieldDefinition{
+			"id": {
+				Type: graphql.NewNonNullType(graphql.IDType),
+				Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+					return ctx.Object.(*user).Id, nil
+				},
+			},
+		},
+		IsTypeOf: func(v interface{}) bool {
+			_, ok := v.(*user)
+			return ok
+		},
+	}
+	reviewType := &graphql.ObjectType{
+		Name: "Review",
+		Fields: map[string]*graphql.FieldDefinition{
+			"body": {
+				Type: graphql.StringType,
+				Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+					return ctx.Object.(*review).Body, nil
+				},
+			},
+			"author": {
+				Type: userType,
+				Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+					return &user{Id: ctx.Object.(*review).AuthorId}, nil
+				},
+			},
+		},
+	}
+	userType.Fields["reviews"] = &graphql.FieldDefinition{
+		Type: graphql.NewListType(reviewType),
+		Arguments: map[string]*graphql.InputValueDefinition{
+			"first": {
+				Type: graphql.IntType,
+			},
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			var ret []*review
+			for _, r := range reviews {
+				if r.AuthorId == ctx.Object.(*user).Id {
+					ret = append(ret, r)
+				}
+			}
+			if first, ok := ctx.Arguments["first"].(int); ok && first < len(ret) {
+				ret = ret[:first]
+			}
+			return ret, nil
+		},
+	}
+	s, err := graphql.NewSchema(&graphql.SchemaDefinition{
+		Query: entitiesQueryType(map[string]*graphql.FieldDefinition{
+			"topReviews": {
+				Type: graphql.NewListType(reviewType),
+				Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+					return reviews[:2], nil
+				},
+			},
+		}, []*graphql.ObjectType{userType}, func(typename, id string) interface{} {
+			return &user{Id: id}
+		}),
+	})
+	require.NoError(t, err)
+	return s
+}
+
+// Builds a service that executes requests in-process, but round-trips everything through JSON as
+// a remote service would.
+func newTestService(t *testing.T, name string, s *graphql.Schema, requests *testRequestLog) *Service {
+	resp := graphql.Execute(&graphql.Request{
+		Context: context.Background(),
+		Query:   string(introspection.Query),
+		Schema:  s,
+	})
+	require.Empty(t, resp.Errors)
+	buf, err := json.Marshal(resp.Data)
+	require.NoError(t, err)
+	var data struct {
+		Schema introspection.SchemaData `json:"__schema"`
+	}
+	require.NoError(t, json.Unmarshal(buf, &data))
+
+	return &Service{
+		Name:   name,
+		Schema: &data.Schema,
+		Keys: map[string][]string{
+			"User": {"id"},
+		},
+		Execute: func(ctx context.Context, r *ServiceRequest) (*ServiceResponse, error) {
+			requests.Add(name + ": " + r.Query)
+			buf, err := json.Marshal(r.Variables)
+			if err != nil {
+				return nil, err
+			}
+			var variables map[string]interface{}
+			if err := json.Unmarshal(buf, &variables); err != nil {
+				return nil, err
+			}
+			resp := graphql.Execute(&graphql.Request{
+				Context:        ctx,
+				Query:          r.Query,
+				Schema:         s,
+				VariableValues: variables,
+			})
+			if buf, err = json.Marshal(resp); err != nil {
+				return nil, err
+			}
+			var ret ServiceResponse
+			if err := json.Unmarshal(buf, &ret); err != nil {
+				return nil, err
+			}
+			return &ret, nil
+		},
+	}
+}
+
+type testRequestLog struct {
+	mutex    sync.Mutex
+	requests []string
+}
+
+func (l *testRequestLog) Add(request string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.requests = append(l.requests, request)
+}
+
+func newTestGateway(t *testing.T) (*Gateway, *testRequestLog) {
+	var requests testRequestLog
+	g, err := New(&Config{
+		Services: []*Service{
+			newTestService(t, "accounts", newAccountsSchema(t), &requests),
+			newTestService(t, "reviews", newReviewsSchema(t), &requests),
+		},
+	})
+	require.NoError(t, err)
+	return g, &requests
+}
+
+func TestGateway(t *testing.T) {
+	g, requests := newTestGateway(t)
+
+	for name, tc := range map[string]struct {
+		Query            string
+		Variables        map[string]interface{}
+		ExpectedResponse string
+		ExpectedRequests []string
+	}{
+		"SingleService": {
+			Query:            `{me {name}}`,
+			ExpectedResponse: `{"data":{"me":{"name":"Alice"}}}`,
+			ExpectedRequests: []string{
+				`accounts: query {me {name}}`,
+			},
+		},
+		"EntityFetch": {
+			Query:            `query ($n: Int) {me {name reviews(first: $n) {body}}}`,
+			Variables:        map[string]interface{}{"n": 1},
+			ExpectedResponse: `{"data":{"me":{"name":"Alice","reviews":[{"body":"Great!"}]}}}`,
+			ExpectedRequests: []string{
+				`accounts: query {me {name _gateway_typename: __typename _gateway_key_id: id}}`,
+				`reviews: query ($_gateway_representations: [_Any!]!, $n: Int) {_entities(representations: $_gateway_representations) {... on User {reviews(first: $n) {body}}}}`,
+			},
+		},
+		"NestedEntityFetch": {
+			Query:            `{topReviews {body author {...F}}} fragment F on User {name reviews {body}}`,
+			ExpectedResponse: `{"data":{"topReviews":[{"body":"Great!","author":{"name":"Alice","reviews":[{"body":"Great!"},{"body":"Awful."}]}},{"body":"Meh.","author":{"name":"Bob","reviews":[{"body":"Meh."}]}}]}}`,
+			ExpectedRequests: []string{
+				`reviews: query {topReviews {body author {... on User {reviews {body} _gateway_typename: __typename _gateway_key_id: id}}}}`,
+				`accounts: query ($_gateway_representations: [_Any!]!) {_entities(representations: $_gateway_representations) {... on User {name}}}`,
+			},
+		},
+		"MultipleRootServices": {
+			Query:            `{__typename me {id} topReviews {body}}`,
+			ExpectedResponse: `{"data":{"__typename":"Query","me":{"id":"1"},"topReviews":[{"body":"Great!"},{"body":"Meh."}]}}`,
+		},
+		"Introspection": {
+			Query:            `{__type(name: "User") {name kind}}`,
+			ExpectedResponse: `{"data":{"__type":{"name":"User","kind":"OBJECT"}}}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			requests.requests = nil
+			resp := g.Execute(&graphql.Request{
+				Context:        context.Background(),
+				Query:          tc.Query,
+				VariableValues: tc.Variables,
+			})
+			buf, err := json.Marshal(resp)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.ExpectedResponse, string(buf))
+			if tc.ExpectedRequests != nil {
+				assert.ElementsMatch(t, tc.ExpectedRequests, requests.requests)
+			}
+		})
+	}
+}
+
+func TestGateway_FailedFetch(t *testing.T) {
+	var requests testRequestLog
+	reviews := newTestService(t, "reviews", newReviewsSchema(t), &requests)
+	reviews.Execute = func(ctx context.Context, r *ServiceRequest) (*ServiceResponse, error) {
+		return nil, fmt.Errorf("service unavailable")
+	}
+	g, err := New(&Config{
+		Services: []*Service{
+			newTestService(t, "accounts", newAccountsSchema(t), &requests),
+			reviews,
+		},
+	})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Query            string
+		ExpectedResponse string
+	}{
+		"RootFetch": {
+			Query:            `{me {name} topReviews {body}}`,
+			ExpectedResponse: `{"data":{"me":{"name":"Alice"},"topReviews":null},"errors":[{"message":"Error fetching from reviews: service unavailable"}]}`,
+		},
+		"EntityFetch": {
+			Query:            `{me {name ... on User {reviews {body}}}}`,
+			ExpectedResponse: `{"data":{"me":{"name":"Alice","reviews":null}},"errors":[{"message":"Error fetching from reviews: service unavailable"}]}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := g.Execute(&graphql.Request{
+				Context: context.Background(),
+				Query:   tc.Query,
+			})
+			buf, err := json.Marshal(resp)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.ExpectedResponse, string(buf))
+		})
+	}
+}
+
+func TestGateway_ServeGraphQL(t *testing.T) {
+	g, _ := newTestGateway(t)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("POST", "", strings.NewReader(`{me {name reviews {body}}}`))
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", "application/graphql")
+	g.ServeGraphQL(w, r)
+
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, map[string]interface{}{
+		"data": map[string]interface{}{
+			"me": map[string]interface{}{
+				"name": "Alice",
+				"reviews": []interface{}{
+					map[string]interface{}{"body": "Great!"},
+					map[string]interface{}{"body": "Awful."},
+				},
+			},
+		},
+	}, body)
+}
+
+func TestNewHTTPExecutor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ServiceRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		fmt.Fprintf(w, `{"data":{"query":%q}}`, req.Query)
+	}))
+	defer server.Close()
+
+	resp, err := NewHTTPExecutor(server.URL, nil)(context.Background(), &ServiceRequest{
+		Query: `{x}`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"query": "{x}"}, resp.Data)
+}
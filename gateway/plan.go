@@ -0,0 +1,491 @@
+package gateway
+
+import (
+	"fmt"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/executor"
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+// QueryPlan describes how the gateway executes an operation.
+type QueryPlan struct {
+	// If true, the operation only selects introspection fields and is executed by the gateway
+	// itself without any fetches.
+	Introspection bool
+
+	// If true, the root fetches must be executed one after another, as is the case for mutations.
+	// Otherwise they may be executed concurrently.
+	Serial bool
+
+	// Fetches are the requests made to services to resolve the operation's root fields.
+	Fetches []*Fetch
+
+	operation *ast.OperationDefinition
+	fragments map[string]*ast.FragmentDefinition
+	rootType  *schema.ObjectType
+}
+
+// Fetch is a request that the gateway makes to a service.
+type Fetch struct {
+	Service *Service
+
+	// For entity fetches, this is the response path of the entities being fetched. Lists are
+	// traversed implicitly, so it never contains indices. For root fetches, it is empty.
+	Path []string
+
+	// For entity fetches, this is the name of the entities' type.
+	TypeName string
+
+	// Query is the GraphQL document sent to the service.
+	Query string
+
+	// Variables are the names of the operation's variables that are forwarded to the service.
+	Variables []string
+
+	// Children are entity fetches that depend on the result of this one. They're executed after
+	// this fetch completes.
+	Children []*Fetch
+}
+
+// The gateway adds these aliased fields to fetches when it needs to identify entities.
+const (
+	typenameAlias           = "_gateway_typename"
+	keyAliasPrefix          = "_gateway_key_"
+	representationsVariable = "_gateway_representations"
+)
+
+type planner struct {
+	gateway   *Gateway
+	fragments map[string]*ast.FragmentDefinition
+	operation *ast.OperationDefinition
+}
+
+// Plan builds a query plan for an operation. The document must be valid for the gateway's schema.
+func (g *Gateway) Plan(doc *ast.Document, operationName string) (*QueryPlan, error) {
+	operation, opErr := executor.GetOperation(doc, operationName)
+	if opErr != nil {
+		return nil, opErr
+	}
+
+	plan := &QueryPlan{
+		operation: operation,
+		fragments: map[string]*ast.FragmentDefinition{},
+	}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok {
+			plan.fragments[def.Name.Name] = def
+		}
+	}
+
+	p := &planner{
+		gateway:   g,
+		fragments: plan.fragments,
+		operation: operation,
+	}
+
+	var rootType *schema.ObjectType
+	if opType := operation.OperationType; opType == nil || opType.Value == "query" {
+		rootType = g.schema.QueryType()
+	} else if opType.Value == "mutation" {
+		rootType = g.schema.MutationType()
+		plan.Serial = true
+	} else {
+		return nil, fmt.Errorf("%v operations are not supported by the gateway", opType.Value)
+	}
+	if rootType == nil {
+		return nil, fmt.Errorf("the schema does not support this operation")
+	}
+	plan.rootType = rootType
+
+	selections := operation.SelectionSet.Selections
+
+	var services []*Service
+	hasIntrospection := false
+	p.visitFields(selections, func(field *ast.Field) {
+		switch field.Name.Name {
+		case "__typename":
+		case "__schema", "__type":
+			hasIntrospection = true
+		default:
+			if owner := p.rootFieldOwner(rootType, field); !containsService(services, owner) {
+				services = append(services, owner)
+			}
+		}
+	})
+	if len(services) == 0 {
+		plan.Introspection = true
+		return plan, nil
+	} else if hasIntrospection {
+		return nil, fmt.Errorf("introspection fields cannot be combined with other fields")
+	}
+
+	if plan.Serial {
+		// Each root mutation field must be resolved in order, so we can only combine consecutive
+		// fields that belong to the same service.
+		var current *Service
+		var group []ast.Selection
+		flush := func() error {
+			if current == nil {
+				return nil
+			}
+			fetch, err := p.planRootFetch(current, rootType, group)
+			if err != nil {
+				return err
+			}
+			plan.Fetches = append(plan.Fetches, fetch)
+			return nil
+		}
+		for _, selection := range selections {
+			var owners []*Service
+			p.visitFields([]ast.Selection{selection}, func(field *ast.Field) {
+				if field.Name.Name != "__typename" {
+					if owner := p.rootFieldOwner(rootType, field); !containsService(owners, owner) {
+						owners = append(owners, owner)
+					}
+				}
+			})
+			if len(owners) > 1 {
+				return nil, fmt.Errorf("mutation fragments cannot span multiple services")
+			} else if len(owners) == 1 && owners[0] != current {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				current = owners[0]
+				group = nil
+			} else if current == nil {
+				// This is a __typename selection before any service's fields. Just give it to the
+				// first service.
+				current = services[0]
+			}
+			group = append(group, selection)
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	} else {
+		for i, service := range services {
+			service := service
+			includeTypename := i == 0
+			serviceSelections := p.filterSelections(selections, func(field *ast.Field) bool {
+				if field.Name.Name == "__typename" {
+					return includeTypename
+				}
+				return p.rootFieldOwner(rootType, field) == service
+			})
+			fetch, err := p.planRootFetch(service, rootType, serviceSelections)
+			if err != nil {
+				return nil, err
+			}
+			plan.Fetches = append(plan.Fetches, fetch)
+		}
+	}
+
+	return plan, nil
+}
+
+func containsService(services []*Service, service *Service) bool {
+	for _, s := range services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Root fields are always resolved by the first service that defines them.
+func (p *planner) rootFieldOwner(rootType *schema.ObjectType, field *ast.Field) *Service {
+	if owners := p.gateway.owners[rootType.Name][field.Name.Name]; len(owners) > 0 {
+		return owners[0]
+	}
+	return nil
+}
+
+// Invokes f for every field in the selections, descending into fragments but not sub-selections.
+func (p *planner) visitFields(selections []ast.Selection, f func(*ast.Field)) {
+	for _, selection := range selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			f(selection)
+		case *ast.InlineFragment:
+			p.visitFields(selection.SelectionSet.Selections, f)
+		case *ast.FragmentSpread:
+			if def, ok := p.fragments[selection.FragmentName.Name]; ok {
+				p.visitFields(def.SelectionSet.Selections, f)
+			}
+		}
+	}
+}
+
+// Returns the selections with only the fields that satisfy keep. Fragment spreads are converted to
+// inline fragments, and fragments that end up empty are removed.
+func (p *planner) filterSelections(selections []ast.Selection, keep func(*ast.Field) bool) []ast.Selection {
+	var ret []ast.Selection
+	for _, selection := range selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if keep(selection) {
+				ret = append(ret, selection)
+			}
+		case *ast.InlineFragment:
+			if filtered := p.filterSelections(selection.SelectionSet.Selections, keep); len(filtered) > 0 {
+				fragment := *selection
+				fragment.SelectionSet = &ast.SelectionSet{
+					Selections: filtered,
+				}
+				ret = append(ret, &fragment)
+			}
+		case *ast.FragmentSpread:
+			if fragment := p.inlineFragment(selection); fragment != nil {
+				ret = append(ret, p.filterSelections([]ast.Selection{fragment}, keep)...)
+			}
+		}
+	}
+	return ret
+}
+
+func (p *planner) inlineFragment(spread *ast.FragmentSpread) *ast.InlineFragment {
+	def, ok := p.fragments[spread.FragmentName.Name]
+	if !ok {
+		return nil
+	}
+	return &ast.InlineFragment{
+		TypeCondition: def.TypeCondition,
+		Directives:    spread.Directives,
+		SelectionSet:  def.SelectionSet,
+		Ellipsis:      spread.Ellipsis,
+	}
+}
+
+func (p *planner) planRootFetch(service *Service, rootType *schema.ObjectType, selections []ast.Selection) (*Fetch, error) {
+	fetch := &Fetch{
+		Service: service,
+	}
+	planned, err := p.planSelections(fetch, service, rootType, selections, nil)
+	if err != nil {
+		return nil, err
+	}
+	operationType := "query"
+	if opType := p.operation.OperationType; opType != nil {
+		operationType = opType.Value
+	}
+	p.finalizeFetch(fetch, &ast.OperationDefinition{
+		OperationType: &ast.OperationType{
+			Value: operationType,
+		},
+		SelectionSet: &ast.SelectionSet{
+			Selections: planned,
+		},
+	})
+	return fetch, nil
+}
+
+// Splits the selections on an object of type t into the ones that service can resolve, which are
+// returned, and the ones that must be fetched from other services, which are added to fetch as
+// child entity fetches.
+func (p *planner) planSelections(fetch *Fetch, service *Service, t schema.NamedType, selections []ast.Selection, path []string) ([]ast.Selection, error) {
+	var ret []ast.Selection
+
+	var remoteServices []*Service
+	remoteSelections := map[*Service][]ast.Selection{}
+
+	for _, selection := range selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			fieldName := selection.Name.Name
+			if fieldName == "__typename" {
+				ret = append(ret, selection)
+				continue
+			}
+
+			owners := p.gateway.owners[t.TypeName()][fieldName]
+			if len(owners) == 0 {
+				return nil, fmt.Errorf("no service defines %v.%v", t.TypeName(), fieldName)
+			} else if !containsService(owners, service) {
+				owner := owners[0]
+				if !containsService(remoteServices, owner) {
+					remoteServices = append(remoteServices, owner)
+				}
+				remoteSelections[owner] = append(remoteSelections[owner], selection)
+				continue
+			}
+
+			field := *selection
+			if selection.SelectionSet != nil {
+				var fieldDef *schema.FieldDefinition
+				switch t := t.(type) {
+				case *schema.ObjectType:
+					fieldDef = t.Fields[fieldName]
+				case *schema.InterfaceType:
+					fieldDef = t.Fields[fieldName]
+				}
+				if fieldDef == nil {
+					return nil, fmt.Errorf("no service defines %v.%v", t.TypeName(), fieldName)
+				}
+				responseKey := fieldName
+				if selection.Alias != nil {
+					responseKey = selection.Alias.Name
+				}
+				fieldPath := append(append([]string(nil), path...), responseKey)
+				planned, err := p.planSelections(fetch, service, schema.UnwrappedType(fieldDef.Type), selection.SelectionSet.Selections, fieldPath)
+				if err != nil {
+					return nil, err
+				}
+				field.SelectionSet = &ast.SelectionSet{
+					Selections: planned,
+				}
+			}
+			ret = append(ret, &field)
+		case *ast.InlineFragment:
+			fragmentType := t
+			if selection.TypeCondition != nil {
+				fragmentType = p.gateway.schema.NamedTypes()[selection.TypeCondition.Name.Name]
+			}
+			planned, err := p.planSelections(fetch, service, fragmentType, selection.SelectionSet.Selections, path)
+			if err != nil {
+				return nil, err
+			}
+			if len(planned) > 0 {
+				fragment := *selection
+				fragment.SelectionSet = &ast.SelectionSet{
+					Selections: planned,
+				}
+				ret = append(ret, &fragment)
+			}
+		case *ast.FragmentSpread:
+			if fragment := p.inlineFragment(selection); fragment != nil {
+				planned, err := p.planSelections(fetch, service, t, []ast.Selection{fragment}, path)
+				if err != nil {
+					return nil, err
+				}
+				ret = append(ret, planned...)
+			}
+		}
+	}
+
+	if len(remoteServices) == 0 {
+		return ret, nil
+	}
+
+	if _, ok := t.(*schema.ObjectType); !ok {
+		return nil, fmt.Errorf("fields of abstract type %v cannot be fetched from other services", t.TypeName())
+	}
+	keys, ok := service.Keys[t.TypeName()]
+	if !ok {
+		return nil, fmt.Errorf("service %v does not declare keys for %v", service.Name, t.TypeName())
+	}
+	ret = append(ret, aliasedField(typenameAlias, "__typename"))
+	for _, key := range keys {
+		ret = append(ret, aliasedField(keyAliasPrefix+key, key))
+	}
+
+	for _, remote := range remoteServices {
+		if _, ok := remote.Keys[t.TypeName()]; !ok {
+			return nil, fmt.Errorf("service %v does not declare keys for %v", remote.Name, t.TypeName())
+		}
+		child := &Fetch{
+			Service:  remote,
+			Path:     path,
+			TypeName: t.TypeName(),
+		}
+		planned, err := p.planSelections(child, remote, t, remoteSelections[remote], path)
+		if err != nil {
+			return nil, err
+		}
+		p.finalizeFetch(child, entitiesOperation(t.TypeName(), planned))
+		fetch.Children = append(fetch.Children, child)
+	}
+
+	return ret, nil
+}
+
+func aliasedField(alias, name string) *ast.Field {
+	return &ast.Field{
+		Alias: &ast.Name{
+			Name: alias,
+		},
+		Name: &ast.Name{
+			Name: name,
+		},
+	}
+}
+
+func namedType(name string) *ast.NamedType {
+	return &ast.NamedType{
+		Name: &ast.Name{
+			Name: name,
+		},
+	}
+}
+
+// Builds an operation that resolves the given selections on a list of entity representations.
+func entitiesOperation(typeName string, selections []ast.Selection) *ast.OperationDefinition {
+	representations := &ast.Variable{
+		Name: &ast.Name{
+			Name: representationsVariable,
+		},
+	}
+	return &ast.OperationDefinition{
+		OperationType: &ast.OperationType{
+			Value: "query",
+		},
+		VariableDefinitions: []*ast.VariableDefinition{
+			{
+				Variable: representations,
+				Type: &ast.NonNullType{
+					Type: &ast.ListType{
+						Type: &ast.NonNullType{
+							Type: namedType("_Any"),
+						},
+					},
+				},
+			},
+		},
+		SelectionSet: &ast.SelectionSet{
+			Selections: []ast.Selection{
+				&ast.Field{
+					Name: &ast.Name{
+						Name: "_entities",
+					},
+					Arguments: []*ast.Argument{
+						{
+							Name: &ast.Name{
+								Name: "representations",
+							},
+							Value: representations,
+						},
+					},
+					SelectionSet: &ast.SelectionSet{
+						Selections: []ast.Selection{
+							&ast.InlineFragment{
+								TypeCondition: namedType(typeName),
+								SelectionSet: &ast.SelectionSet{
+									Selections: selections,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Adds the definitions for any variables used by the operation, then renders it as the fetch's
+// query.
+func (p *planner) finalizeFetch(fetch *Fetch, op *ast.OperationDefinition) {
+	used := map[string]struct{}{}
+	ast.Inspect(op.SelectionSet, func(node ast.Node) bool {
+		if v, ok := node.(*ast.Variable); ok {
+			used[v.Name.Name] = struct{}{}
+		}
+		return true
+	})
+	for _, def := range p.operation.VariableDefinitions {
+		if _, ok := used[def.Variable.Name.Name]; ok {
+			op.VariableDefinitions = append(op.VariableDefinitions, def)
+			fetch.Variables = append(fetch.Variables, def.Variable.Name.Name)
+		}
+	}
+	fetch.Query = ast.Print(op)
+}
@@ -0,0 +1,245 @@
+// Package gqlscan finds GraphQL documents embedded in Go source code. Documents are identified by
+// calls to a wrapper function, e.g. `gql("query { viewer { id } }")`. This is the discovery logic
+// used by gql-client-gen, and it's useful for other tools such as manifest generators and linters.
+package gqlscan
+
+import (
+	"fmt"
+	goast "go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Document is a GraphQL document found in Go source code.
+type Document struct {
+	// The document's source.
+	Source string
+
+	// The position of the wrapper's argument.
+	Position token.Position
+}
+
+// Scanner finds GraphQL documents in Go source code.
+type Scanner struct {
+	// The name of the wrapper function. This is typically an identifier such as "gql", but it may
+	// also be qualified, e.g. "client.GQL".
+	Wrapper string
+
+	// Maps directories to the package-level constants declared in them.
+	packageConstants map[string]map[string]goast.Expr
+}
+
+// The wrapper's argument may refer to constants, which may refer to other constants. This limits
+// the depth of that chain so that cycles don't cause infinite recursion.
+const maxConstantDepth = 100
+
+// ScanGlobs scans all of the Go files matching the given globs. Errors for files are prefixed with
+// the file's path.
+func (s *Scanner) ScanGlobs(globs []string) ([]*Document, []error) {
+	var docs []*Document
+	var errs []error
+	for _, glob := range globs {
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, match := range matches {
+			fileDocs, fileErrs := s.ScanFile(match)
+			docs = append(docs, fileDocs...)
+			for _, err := range fileErrs {
+				errs = append(errs, fmt.Errorf("%v: %w", match, err))
+			}
+		}
+	}
+	return docs, errs
+}
+
+// ScanFile scans a Go file. The wrapper's argument may be a string literal, a concatenation of
+// string literals, or a constant declared in the file's package.
+func (s *Scanner) ScanFile(path string) ([]*Document, []error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, source, 0)
+	if err != nil {
+		return nil, []error{fmt.Errorf("parse error: %w", err)}
+	}
+	return s.scan(fset, f, s.constantsForFile(path, f))
+}
+
+// ScanSource scans Go source code. The filename is only used for positions. Unlike ScanFile,
+// constants declared in other files of the package can't be resolved.
+func (s *Scanner) ScanSource(filename string, source []byte) ([]*Document, []error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, source, 0)
+	if err != nil {
+		return nil, []error{fmt.Errorf("parse error: %w", err)}
+	}
+	return s.scan(fset, f, packageConstants(f))
+}
+
+func (s *Scanner) scan(fset *token.FileSet, f *goast.File, constants map[string]goast.Expr) ([]*Document, []error) {
+	var docs []*Document
+	var errs []error
+
+	goast.Inspect(f, func(node goast.Node) bool {
+		call, ok := node.(*goast.CallExpr)
+		if !ok || !s.isWrapper(call.Fun) {
+			return true
+		}
+		position := fset.Position(call.Lparen)
+		if len(call.Args) != 1 {
+			errs = append(errs, fmt.Errorf("%v: expected 1 argument to %v", positionString(position), s.Wrapper))
+			return true
+		}
+		position = fset.Position(call.Args[0].Pos())
+		if source, err := evalString(call.Args[0], constants, 0); err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v argument %w", positionString(position), s.Wrapper, err))
+		} else {
+			docs = append(docs, &Document{
+				Source:   source,
+				Position: position,
+			})
+		}
+		return true
+	})
+
+	return docs, errs
+}
+
+// Formats a position without the filename, which callers typically already include.
+func positionString(p token.Position) string {
+	return fmt.Sprintf("%v:%v", p.Line, p.Column)
+}
+
+func (s *Scanner) isWrapper(expr goast.Expr) bool {
+	switch expr := expr.(type) {
+	case *goast.Ident:
+		return expr.Name == s.Wrapper
+	case *goast.SelectorExpr:
+		if x, ok := expr.X.(*goast.Ident); ok {
+			return x.Name+"."+expr.Sel.Name == s.Wrapper
+		}
+	}
+	return false
+}
+
+// Evaluates an expression that must be a constant string.
+func evalString(expr goast.Expr, constants map[string]goast.Expr, depth int) (string, error) {
+	if depth > maxConstantDepth {
+		return "", fmt.Errorf("has too many levels of constants")
+	}
+
+	switch expr := expr.(type) {
+	case *goast.BasicLit:
+		if expr.Kind != token.STRING {
+			break
+		}
+		s, err := strconv.Unquote(expr.Value)
+		if err != nil {
+			return "", fmt.Errorf("could not be parsed: %w", err)
+		}
+		return s, nil
+	case *goast.ParenExpr:
+		return evalString(expr.X, constants, depth+1)
+	case *goast.BinaryExpr:
+		if expr.Op != token.ADD {
+			break
+		}
+		x, err := evalString(expr.X, constants, depth+1)
+		if err != nil {
+			return "", err
+		}
+		y, err := evalString(expr.Y, constants, depth+1)
+		if err != nil {
+			return "", err
+		}
+		return x + y, nil
+	case *goast.Ident:
+		if value := localConstant(expr); value != nil {
+			return evalString(value, constants, depth+1)
+		} else if value, ok := constants[expr.Name]; ok && expr.Obj == nil {
+			return evalString(value, constants, depth+1)
+		}
+		return "", fmt.Errorf("must be a string literal or constant, but %v is not a known constant", expr.Name)
+	}
+	return "", fmt.Errorf("must be a string literal or constant")
+}
+
+// Returns the value of the constant that the identifier refers to if it was declared in the same
+// file.
+func localConstant(ident *goast.Ident) goast.Expr {
+	if ident.Obj == nil || ident.Obj.Kind != goast.Con {
+		return nil
+	}
+	spec, ok := ident.Obj.Decl.(*goast.ValueSpec)
+	if !ok {
+		return nil
+	}
+	for i, name := range spec.Names {
+		if name.Name == ident.Name && i < len(spec.Values) {
+			return spec.Values[i]
+		}
+	}
+	return nil
+}
+
+// Returns the package-level constants declared in the file.
+func packageConstants(f *goast.File) map[string]goast.Expr {
+	ret := map[string]goast.Expr{}
+	for _, decl := range f.Decls {
+		decl, ok := decl.(*goast.GenDecl)
+		if !ok || decl.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range decl.Specs {
+			spec := spec.(*goast.ValueSpec)
+			for i, name := range spec.Names {
+				if i < len(spec.Values) {
+					ret[name.Name] = spec.Values[i]
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// Returns the package-level constants declared in the file's package. Other files in the same
+// directory are parsed as needed. Files that can't be parsed are ignored.
+func (s *Scanner) constantsForFile(path string, f *goast.File) map[string]goast.Expr {
+	dir := filepath.Dir(path)
+	key := dir + "\x00" + f.Name.Name
+	if constants, ok := s.packageConstants[key]; ok {
+		return constants
+	}
+
+	constants := map[string]goast.Expr{}
+	if matches, err := filepath.Glob(filepath.Join(dir, "*.go")); err == nil {
+		for _, match := range matches {
+			if strings.HasSuffix(match, "_test.go") && !strings.HasSuffix(path, "_test.go") {
+				continue
+			}
+			other, err := parser.ParseFile(token.NewFileSet(), match, nil, 0)
+			if err != nil || other.Name.Name != f.Name.Name {
+				continue
+			}
+			for name, value := range packageConstants(other) {
+				constants[name] = value
+			}
+		}
+	}
+
+	if s.packageConstants == nil {
+		s.packageConstants = map[string]map[string]goast.Expr{}
+	}
+	s.packageConstants[key] = constants
+	return constants
+}
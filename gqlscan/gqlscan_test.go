@@ -0,0 +1,64 @@
+package gqlscan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanner_ScanFile(t *testing.T) {
+	scanner := &Scanner{
+		Wrapper: "gql",
+	}
+	docs, errs := scanner.ScanFile("testdata/pkg/queries.go")
+	require.Empty(t, errs)
+
+	var sources []string
+	for _, doc := range docs {
+		sources = append(sources, doc.Source)
+	}
+	assert.Equal(t, []string{
+		`query Literal { a }`,
+		`query Concatenated { b }`,
+		`query Viewer { viewer { id name } }`,
+		`query Node { node(id: "x") { id name } }`,
+		`query Local { d }`,
+	}, sources)
+
+	assert.Equal(t, "testdata/pkg/queries.go", docs[0].Position.Filename)
+	assert.Equal(t, 8, docs[0].Position.Line)
+	assert.Equal(t, 6, docs[0].Position.Column)
+}
+
+func TestScanner_QualifiedWrapper(t *testing.T) {
+	scanner := &Scanner{
+		Wrapper: "client.GQL",
+	}
+	docs, errs := scanner.ScanFile("testdata/pkg/queries.go")
+	require.Empty(t, errs)
+	require.Len(t, docs, 1)
+	assert.Equal(t, `query Qualified { c }`, docs[0].Source)
+}
+
+func TestScanner_Errors(t *testing.T) {
+	scanner := &Scanner{
+		Wrapper: "gql",
+	}
+	_, errs := scanner.ScanGlobs([]string{"testdata/pkg/errors.go"})
+	require.Len(t, errs, 3)
+	assert.EqualError(t, errs[0], "testdata/pkg/errors.go: 4:6: gql argument must be a string literal or constant, but s is not a known constant")
+	assert.EqualError(t, errs[1], "testdata/pkg/errors.go: 5:5: expected 1 argument to gql")
+	assert.EqualError(t, errs[2], "testdata/pkg/errors.go: 6:6: gql argument must be a string literal or constant, but undefined is not a known constant")
+}
+
+func TestScanner_ScanSource(t *testing.T) {
+	scanner := &Scanner{
+		Wrapper: "gql",
+	}
+	docs, errs := scanner.ScanSource("x.go", []byte("package x\n\nvar q = gql(`{ a }`)\n"))
+	require.Empty(t, errs)
+	require.Len(t, docs, 1)
+	assert.Equal(t, "{ a }", docs[0].Source)
+	assert.Equal(t, "x.go", docs[0].Position.Filename)
+}
@@ -0,0 +1,3 @@
+package pkg
+
+const nodeQuery = "query Node { node(id: \"x\") { " + userFields + " } }"
@@ -0,0 +1,7 @@
+package pkg
+
+func errors(s string) {
+	gql(s)
+	gql()
+	gql(undefined)
+}
@@ -0,0 +1,16 @@
+package pkg
+
+const userFields = `id name`
+
+const viewerQuery = `query Viewer { viewer { ` + userFields + ` } }`
+
+func queries() {
+	gql(`query Literal { a }`)
+	gql("query Concatenated { " + "b" + " }")
+	gql(viewerQuery)
+	gql(nodeQuery)
+	client.GQL(`query Qualified { c }`)
+
+	const local = `query Local { d }`
+	gql(local)
+}
@@ -0,0 +1,190 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Print converts a node back into GraphQL source text. The output is compact and does not preserve
+// formatting or comments from the original source, but parsing it will produce an equivalent AST.
+func Print(node Node) string {
+	var sb strings.Builder
+	printNode(&sb, node)
+	return sb.String()
+}
+
+func printNode(sb *strings.Builder, node Node) {
+	switch node := node.(type) {
+	case *Document:
+		for i, def := range node.Definitions {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			printNode(sb, def)
+		}
+	case *OperationDefinition:
+		if node.OperationType == nil && node.Name == nil && len(node.VariableDefinitions) == 0 && len(node.Directives) == 0 {
+			printNode(sb, node.SelectionSet)
+			return
+		}
+		if node.OperationType != nil {
+			sb.WriteString(node.OperationType.Value)
+		} else {
+			sb.WriteString("query")
+		}
+		if node.Name != nil {
+			sb.WriteByte(' ')
+			sb.WriteString(node.Name.Name)
+		}
+		if len(node.VariableDefinitions) > 0 {
+			if node.Name == nil {
+				sb.WriteByte(' ')
+			}
+			sb.WriteByte('(')
+			for i, def := range node.VariableDefinitions {
+				if i > 0 {
+					sb.WriteString(", ")
+				}
+				printNode(sb, def)
+			}
+			sb.WriteByte(')')
+		}
+		printDirectives(sb, node.Directives)
+		sb.WriteByte(' ')
+		printNode(sb, node.SelectionSet)
+	case *FragmentDefinition:
+		sb.WriteString("fragment ")
+		sb.WriteString(node.Name.Name)
+		sb.WriteString(" on ")
+		sb.WriteString(node.TypeCondition.Name.Name)
+		printDirectives(sb, node.Directives)
+		sb.WriteByte(' ')
+		printNode(sb, node.SelectionSet)
+	case *VariableDefinition:
+		printNode(sb, node.Variable)
+		sb.WriteString(": ")
+		printNode(sb, node.Type)
+		if node.DefaultValue != nil {
+			sb.WriteString(" = ")
+			printNode(sb, node.DefaultValue)
+		}
+	case *NamedType:
+		sb.WriteString(node.Name.Name)
+	case *ListType:
+		sb.WriteByte('[')
+		printNode(sb, node.Type)
+		sb.WriteByte(']')
+	case *NonNullType:
+		printNode(sb, node.Type)
+		sb.WriteByte('!')
+	case *SelectionSet:
+		sb.WriteByte('{')
+		for i, selection := range node.Selections {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			printNode(sb, selection)
+		}
+		sb.WriteByte('}')
+	case *Field:
+		if node.Alias != nil {
+			sb.WriteString(node.Alias.Name)
+			sb.WriteString(": ")
+		}
+		sb.WriteString(node.Name.Name)
+		printArguments(sb, node.Arguments)
+		printDirectives(sb, node.Directives)
+		if node.SelectionSet != nil {
+			sb.WriteByte(' ')
+			printNode(sb, node.SelectionSet)
+		}
+	case *FragmentSpread:
+		sb.WriteString("...")
+		sb.WriteString(node.FragmentName.Name)
+		printDirectives(sb, node.Directives)
+	case *InlineFragment:
+		sb.WriteString("...")
+		if node.TypeCondition != nil {
+			sb.WriteString(" on ")
+			sb.WriteString(node.TypeCondition.Name.Name)
+		}
+		printDirectives(sb, node.Directives)
+		sb.WriteByte(' ')
+		printNode(sb, node.SelectionSet)
+	case *Directive:
+		sb.WriteByte('@')
+		sb.WriteString(node.Name.Name)
+		printArguments(sb, node.Arguments)
+	case *Argument:
+		sb.WriteString(node.Name.Name)
+		sb.WriteString(": ")
+		printNode(sb, node.Value)
+	case *Variable:
+		sb.WriteByte('$')
+		sb.WriteString(node.Name.Name)
+	case *BooleanValue:
+		if node.Value {
+			sb.WriteString("true")
+		} else {
+			sb.WriteString("false")
+		}
+	case *FloatValue:
+		sb.WriteString(node.Value)
+	case *IntValue:
+		sb.WriteString(node.Value)
+	case *StringValue:
+		// JSON string escapes are a subset of GraphQL's.
+		b, _ := json.Marshal(node.Value)
+		sb.Write(b)
+	case *EnumValue:
+		sb.WriteString(node.Value)
+	case *NullValue:
+		sb.WriteString("null")
+	case *ListValue:
+		sb.WriteByte('[')
+		for i, value := range node.Values {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			printNode(sb, value)
+		}
+		sb.WriteByte(']')
+	case *ObjectValue:
+		sb.WriteByte('{')
+		for i, field := range node.Fields {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			printNode(sb, field)
+		}
+		sb.WriteByte('}')
+	case *ObjectField:
+		sb.WriteString(node.Name.Name)
+		sb.WriteString(": ")
+		printNode(sb, node.Value)
+	default:
+		panic(fmt.Errorf("unexpected node type %T", node))
+	}
+}
+
+func printArguments(sb *strings.Builder, arguments []*Argument) {
+	if len(arguments) == 0 {
+		return
+	}
+	sb.WriteByte('(')
+	for i, arg := range arguments {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		printNode(sb, arg)
+	}
+	sb.WriteByte(')')
+}
+
+func printDirectives(sb *strings.Builder, directives []*Directive) {
+	for _, directive := range directives {
+		sb.WriteByte(' ')
+		printNode(sb, directive)
+	}
+}
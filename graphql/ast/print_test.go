@@ -0,0 +1,34 @@
+package ast_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/parser"
+)
+
+func TestPrint(t *testing.T) {
+	doc, errs := parser.ParseDocument([]byte(`query Q($a: [Int!]! = [1, 2], $b: In = {x: "\"y\"", z: null}) @d(x: ENUM) {
+		alias: field(a: $a, b: 1.5, c: true) { ...F ... on T { x } ... @skip(if: false) { y } }
+	}
+	fragment F on T { z }
+	{ x }`))
+	require.Empty(t, errs)
+	assert.Equal(t, `query Q($a: [Int!]! = [1, 2], $b: In = {x: "\"y\"", z: null}) @d(x: ENUM) {alias: field(a: $a, b: 1.5, c: true) {...F ... on T {x} ... @skip(if: false) {y}}} fragment F on T {z} {x}`, ast.Print(doc))
+}
+
+func TestPrint_KitchenSink(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/kitchen-sink.graphql")
+	require.NoError(t, err)
+	doc, errs := parser.ParseDocument(src)
+	require.Empty(t, errs)
+
+	printed := ast.Print(doc)
+	reparsed, errs := parser.ParseDocument([]byte(printed))
+	require.Empty(t, errs)
+	assert.Equal(t, printed, ast.Print(reparsed))
+}
@@ -0,0 +1,203 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Rewrite traverses the given AST, replacing each node with the result of f. Nodes are visited in
+// depth-first order, and f is invoked for a node after its children have been rewritten, so f sees
+// the rewritten children.
+//
+// If f returns its argument, the node is kept. If f returns nil, the node is removed from its
+// parent. For nodes within lists, such as selections or arguments, the node is removed from the
+// list. Otherwise the parent's field is set to nil, which is only valid for optional fields such as
+// aliases and selection sets. Replacement nodes must be valid in their parent's position, e.g. a
+// selection can only be replaced with another selection.
+//
+// The given AST isn't modified. Nodes whose descendants are rewritten are copied, and everything
+// else is shared with the original. This makes it safe to rewrite documents that are cached or in
+// use by other requests.
+//
+// The rewritten root node is returned, or nil if f removed it.
+func Rewrite(node Node, f func(Node) Node) Node {
+	if isNilNode(node) {
+		return node
+	}
+
+	switch n := node.(type) {
+	case *Document:
+		if definitions, ok := rewriteList(n.Definitions, f); ok {
+			c := *n
+			c.Definitions = definitions
+			node = &c
+		}
+	case *OperationDefinition:
+		c := *n
+		changed := rewriteField(&c.OperationType, f)
+		changed = rewriteField(&c.Name, f) || changed
+		changed = rewriteListField(&c.VariableDefinitions, f) || changed
+		changed = rewriteListField(&c.Directives, f) || changed
+		changed = rewriteField(&c.SelectionSet, f) || changed
+		if changed {
+			node = &c
+		}
+	case *FragmentDefinition:
+		c := *n
+		changed := rewriteField(&c.Name, f)
+		changed = rewriteField(&c.TypeCondition, f) || changed
+		changed = rewriteListField(&c.Directives, f) || changed
+		changed = rewriteField(&c.SelectionSet, f) || changed
+		if changed {
+			node = &c
+		}
+	case *VariableDefinition:
+		c := *n
+		changed := rewriteField(&c.Variable, f)
+		changed = rewriteField(&c.Type, f) || changed
+		changed = rewriteField(&c.DefaultValue, f) || changed
+		if changed {
+			node = &c
+		}
+	case *ListType:
+		c := *n
+		if rewriteField(&c.Type, f) {
+			node = &c
+		}
+	case *NonNullType:
+		c := *n
+		if rewriteField(&c.Type, f) {
+			node = &c
+		}
+	case *Directive:
+		c := *n
+		changed := rewriteField(&c.Name, f)
+		changed = rewriteListField(&c.Arguments, f) || changed
+		if changed {
+			node = &c
+		}
+	case *SelectionSet:
+		c := *n
+		if rewriteListField(&c.Selections, f) {
+			node = &c
+		}
+	case *Field:
+		c := *n
+		changed := rewriteField(&c.Alias, f)
+		changed = rewriteField(&c.Name, f) || changed
+		changed = rewriteListField(&c.Arguments, f) || changed
+		changed = rewriteListField(&c.Directives, f) || changed
+		changed = rewriteField(&c.SelectionSet, f) || changed
+		if changed {
+			node = &c
+		}
+	case *FragmentSpread:
+		c := *n
+		changed := rewriteField(&c.FragmentName, f)
+		changed = rewriteListField(&c.Directives, f) || changed
+		if changed {
+			node = &c
+		}
+	case *InlineFragment:
+		c := *n
+		changed := rewriteField(&c.TypeCondition, f)
+		changed = rewriteListField(&c.Directives, f) || changed
+		changed = rewriteField(&c.SelectionSet, f) || changed
+		if changed {
+			node = &c
+		}
+	case *Argument:
+		c := *n
+		changed := rewriteField(&c.Name, f)
+		changed = rewriteField(&c.Value, f) || changed
+		if changed {
+			node = &c
+		}
+	case *NamedType:
+		c := *n
+		if rewriteField(&c.Name, f) {
+			node = &c
+		}
+	case *Variable:
+		c := *n
+		if rewriteField(&c.Name, f) {
+			node = &c
+		}
+	case *OperationType, *Name, *BooleanValue, *IntValue, *FloatValue, *StringValue, *EnumValue, *NullValue:
+	case *ListValue:
+		c := *n
+		if rewriteListField(&c.Values, f) {
+			node = &c
+		}
+	case *ObjectValue:
+		c := *n
+		if rewriteListField(&c.Fields, f) {
+			node = &c
+		}
+	case *ObjectField:
+		c := *n
+		changed := rewriteField(&c.Name, f)
+		changed = rewriteField(&c.Value, f) || changed
+		if changed {
+			node = &c
+		}
+	default:
+		panic(fmt.Errorf("unknown node type: %T", n))
+	}
+
+	return f(node)
+}
+
+func isNilNode(node Node) bool {
+	return node == nil || reflect.ValueOf(node).IsNil()
+}
+
+// Rewrites the node pointed to by field. Returns true if it was changed.
+func rewriteField[T Node](field *T, f func(Node) Node) bool {
+	if isNilNode(*field) {
+		return false
+	}
+	original := Node(*field)
+	rewritten := Rewrite(original, f)
+	if rewritten == original {
+		return false
+	}
+	var replacement T
+	if rewritten != nil {
+		var ok bool
+		if replacement, ok = rewritten.(T); !ok {
+			panic(fmt.Errorf("cannot replace %T with %T", original, rewritten))
+		}
+	}
+	*field = replacement
+	return true
+}
+
+// Rewrites the nodes of the list pointed to by field. Returns true if any were changed.
+func rewriteListField[T Node](field *[]T, f func(Node) Node) bool {
+	list, changed := rewriteList(*field, f)
+	if changed {
+		*field = list
+	}
+	return changed
+}
+
+// Rewrites each node in the list, removing nodes that are rewritten to nil. If nothing changes,
+// the original list is returned. Otherwise a new list is returned.
+func rewriteList[T Node](list []T, f func(Node) Node) ([]T, bool) {
+	var ret []T
+	for i, node := range list {
+		rewritten := node
+		if rewriteField(&rewritten, f) && ret == nil {
+			ret = make([]T, i, len(list))
+			copy(ret, list[:i])
+		}
+		if ret != nil && !isNilNode(rewritten) {
+			ret = append(ret, rewritten)
+		}
+	}
+	if ret == nil {
+		return list, false
+	}
+	return ret, true
+}
@@ -0,0 +1,108 @@
+package ast_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/parser"
+)
+
+func TestRewrite_KitchenSink(t *testing.T) {
+	src, err := ioutil.ReadFile("testdata/kitchen-sink.graphql")
+	require.NoError(t, err)
+	doc, errs := parser.ParseDocument(src)
+	require.Empty(t, errs)
+
+	// If nothing is rewritten, the original document should be returned.
+	assert.Same(t, doc, ast.Rewrite(doc, func(node ast.Node) ast.Node {
+		return node
+	}))
+}
+
+func TestRewrite(t *testing.T) {
+	const src = `query Q($id: ID!) { user(id: $id) { name secret posts { secret title } } ... on Query { secret } }`
+
+	for name, tc := range map[string]struct {
+		Rewrite  func(ast.Node) ast.Node
+		Expected string
+	}{
+		"InjectTypename": {
+			Rewrite: func(node ast.Node) ast.Node {
+				if selectionSet, ok := node.(*ast.SelectionSet); ok {
+					c := *selectionSet
+					c.Selections = append(c.Selections[:len(c.Selections):len(c.Selections)], &ast.Field{
+						Name: &ast.Name{Name: "__typename"},
+					})
+					return &c
+				}
+				return node
+			},
+			Expected: `query Q($id: ID!) {user(id: $id) {name secret posts {secret title __typename} __typename} ... on Query {secret __typename} __typename}`,
+		},
+		"RemoveFields": {
+			Rewrite: func(node ast.Node) ast.Node {
+				if field, ok := node.(*ast.Field); ok && field.Name.Name == "secret" {
+					return nil
+				}
+				return node
+			},
+			Expected: `query Q($id: ID!) {user(id: $id) {name posts {title}} ... on Query {}}`,
+		},
+		"AddArgument": {
+			Rewrite: func(node ast.Node) ast.Node {
+				if field, ok := node.(*ast.Field); ok && field.Name.Name == "posts" {
+					c := *field
+					c.Arguments = append(c.Arguments, &ast.Argument{
+						Name:  &ast.Name{Name: "tenant"},
+						Value: &ast.StringValue{Value: "t"},
+					})
+					return &c
+				}
+				return node
+			},
+			Expected: `query Q($id: ID!) {user(id: $id) {name secret posts(tenant: "t") {secret title}} ... on Query {secret}}`,
+		},
+		"ReplaceValue": {
+			Rewrite: func(node ast.Node) ast.Node {
+				if arg, ok := node.(*ast.Argument); ok {
+					if _, ok := arg.Value.(*ast.Variable); ok {
+						c := *arg
+						c.Value = &ast.StringValue{Value: "x"}
+						return &c
+					}
+				}
+				return node
+			},
+			Expected: `query Q($id: ID!) {user(id: "x") {name secret posts {secret title}} ... on Query {secret}}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			doc, errs := parser.ParseDocument([]byte(src))
+			require.Empty(t, errs)
+			original := ast.Print(doc)
+
+			rewritten := ast.Rewrite(doc, tc.Rewrite)
+			assert.Equal(t, tc.Expected, ast.Print(rewritten))
+
+			// The original document must not be modified.
+			assert.Equal(t, original, ast.Print(doc))
+		})
+	}
+
+	t.Run("InvalidReplacement", func(t *testing.T) {
+		doc, errs := parser.ParseDocument([]byte(src))
+		require.Empty(t, errs)
+		assert.Panics(t, func() {
+			ast.Rewrite(doc, func(node ast.Node) ast.Node {
+				if _, ok := node.(*ast.Field); ok {
+					return &ast.IntValue{Value: "1"}
+				}
+				return node
+			})
+		})
+	})
+}
@@ -0,0 +1,179 @@
+package graphql
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack"
+
+	"github.com/ccbrown/api-fu/graphql/executor"
+)
+
+// The media types of the binary encodings supported by MarshalMsgpack and MarshalCBOR.
+const (
+	MsgpackContentType = "application/msgpack"
+	CBORContentType    = "application/cbor"
+)
+
+// MarshalMsgpack encodes v as MessagePack. It's typically used to encode a Response for clients
+// that prefer a binary encoding to JSON.
+//
+// Structs are encoded using their json tags, so the result is equivalent to the JSON encoding.
+// OrderedMaps are encoded with their keys in order. Values that only implement json.Marshaler, such
+// as json.RawMessage, aren't converted via JSON, so they should be decoded before being encoded.
+func MarshalMsgpack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf).UseJSONTag(true).UseCompactEncoding(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalMsgpack decodes MessagePack produced by MarshalMsgpack into v. Like MarshalMsgpack, it
+// uses json tags for structs, so it can be used to decode a Response.
+func UnmarshalMsgpack(data []byte, v interface{}) error {
+	return msgpack.NewDecoder(bytes.NewReader(data)).UseJSONTag(true).Decode(v)
+}
+
+// MarshalCBOR is like MarshalMsgpack, but encodes v as CBOR (RFC 8949). Maps, including those
+// encoded from structs, are encoded with indefinite lengths.
+func MarshalCBOR(v interface{}) ([]byte, error) {
+	// The CBOR encoder can't be customized for types that don't implement its interfaces, such as
+	// Path, so v is first converted to plain values via MessagePack. Maps are decoded as OrderedMaps
+	// to preserve their key order.
+	var buf bytes.Buffer
+	if err := msgpack.NewEncoder(&buf).UseJSONTag(true).Encode(v); err != nil {
+		return nil, err
+	}
+	dec := msgpack.NewDecoder(&buf)
+	dec.SetDecodeMapFunc(decodeOrderedMapMsgpack)
+	plain, err := dec.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(plain)
+}
+
+// UnmarshalCBOR is like UnmarshalMsgpack, but decodes CBOR produced by MarshalCBOR.
+func UnmarshalCBOR(data []byte, v interface{}) error {
+	var plain interface{}
+	if err := cborDecMode.Unmarshal(data, &plain); err != nil {
+		return err
+	}
+	buf, err := MarshalMsgpack(plain)
+	if err != nil {
+		return err
+	}
+	return UnmarshalMsgpack(buf, v)
+}
+
+var cborDecMode, _ = cbor.DecOptions{
+	DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)),
+}.DecMode()
+
+func decodeOrderedMapMsgpack(dec *msgpack.Decoder) (interface{}, error) {
+	n, err := dec.DecodeMapLen()
+	if err != nil || n < 0 {
+		return nil, err
+	}
+	m := NewOrderedMap()
+	for i := 0; i < n; i++ {
+		key, err := dec.DecodeString()
+		if err != nil {
+			return nil, err
+		}
+		value, err := dec.DecodeInterface()
+		if err != nil {
+			return nil, err
+		}
+		m.Append(key, value)
+	}
+	return m, nil
+}
+
+func init() {
+	// Paths are encoded as arrays of strings and integers, just like they are in JSON.
+	msgpack.Register(Path(nil), encodePathMsgpack, decodePathMsgpack)
+	msgpack.Register(PathSegment{}, encodePathSegmentMsgpack, decodePathSegmentMsgpack)
+}
+
+func encodePathMsgpack(enc *msgpack.Encoder, v reflect.Value) error {
+	return enc.Encode(v.Interface().(Path).Components())
+}
+
+func decodePathMsgpack(dec *msgpack.Decoder, v reflect.Value) error {
+	components, err := dec.DecodeSlice()
+	if err != nil {
+		return err
+	} else if components == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	path, err := ParsePath(components)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(path))
+	return nil
+}
+
+func encodePathSegmentMsgpack(enc *msgpack.Encoder, v reflect.Value) error {
+	return enc.Encode(v.Interface().(PathSegment).Component())
+}
+
+func decodePathSegmentMsgpack(dec *msgpack.Decoder, v reflect.Value) error {
+	component, err := dec.DecodeInterface()
+	if err != nil {
+		return err
+	}
+	segment, err := executor.NewPathSegment(component)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(segment))
+	return nil
+}
+
+// NegotiateResponseContentType returns the content type that should be used for a response given
+// the request's Accept header values. If the client prefers MessagePack or CBOR to JSON, the
+// corresponding content type is returned. Otherwise, an empty string is returned, indicating that
+// JSON should be used.
+func NegotiateResponseContentType(accept []string) string {
+	best, bestQuality := "", 0.0
+	forEachMediaRange(accept, func(mediaType string, quality float64) {
+		if quality <= bestQuality {
+			return
+		}
+		switch mediaType {
+		case JSONContentType, GraphQLResponseContentType:
+			best, bestQuality = "", quality
+		case MsgpackContentType, CBORContentType:
+			best, bestQuality = mediaType, quality
+		}
+	})
+	return best
+}
+
+// Invokes f with the lowercased media type and quality of each media range in the given Accept
+// header values.
+func forEachMediaRange(accept []string, f func(mediaType string, quality float64)) {
+	for _, header := range accept {
+		for _, mediaRange := range strings.Split(header, ",") {
+			parts := strings.Split(mediaRange, ";")
+			mediaType := strings.ToLower(strings.TrimSpace(parts[0]))
+			quality := 1.0
+			for _, param := range parts[1:] {
+				if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.ToLower(k) == "q" {
+					if q, err := strconv.ParseFloat(v, 64); err == nil {
+						quality = q
+					}
+				}
+			}
+			f(mediaType, quality)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package graphql
+
+import (
+	"encoding/json"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack"
+)
+
+func TestMarshalMsgpack(t *testing.T) {
+	data := NewOrderedMap()
+	data.Append("b", 1)
+	data.Append("a", true)
+	buf, err := MarshalMsgpack(data)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x82, 0xa1, 'b', 0x01, 0xa1, 'a', 0xc3}, buf)
+
+	for name, v := range map[string]interface{}{
+		"NegativeInts": []interface{}{-1, -33, -200, -40000, -3000000000},
+		"LargeInts":    []interface{}{200, 40000, 3000000000, uint64(1) << 63},
+		"Floats":       []interface{}{1.5, float32(2.5)},
+		"LongString":   string(make([]byte, 300)),
+		"Response": &Response{
+			Data: func() *interface{} {
+				var v interface{} = data
+				return &v
+			}(),
+			Errors: []*Error{
+				{
+					Message:   "foo",
+					Locations: []Location{{Line: 1, Column: 2}},
+					Path:      NewPath("a", 1),
+				},
+			},
+			Extensions: map[string]interface{}{
+				"x": []interface{}{1, 2.5, nil},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf, err := MarshalMsgpack(v)
+			require.NoError(t, err)
+			var decoded interface{}
+			require.NoError(t, msgpack.Unmarshal(buf, &decoded))
+			expected, err := jsoniter.Marshal(v)
+			require.NoError(t, err)
+			actual, err := json.Marshal(decoded)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
+
+func TestMarshalCBOR(t *testing.T) {
+	data := NewOrderedMap()
+	data.Append("b", 1)
+	data.Append("a", true)
+
+	for name, tc := range map[string]struct {
+		Value    interface{}
+		Expected []byte
+	}{
+		"OrderedMap":  {data, []byte{0xbf, 0x61, 'b', 0x01, 0x61, 'a', 0xf5, 0xff}},
+		"Path":        {NewPath("a", 1), []byte{0x82, 0x61, 'a', 0x01}},
+		"Null":        {nil, []byte{0xf6}},
+		"NegativeInt": {-500, []byte{0x39, 0x01, 0xf3}},
+		"SmallInts":   {[]int{-1, 1}, []byte{0x82, 0x20, 0x01}},
+		"Int":         {500, []byte{0x19, 0x01, 0xf4}},
+		"Float":       {1.5, []byte{0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		"Array":       {[]string{"x"}, []byte{0x81, 0x61, 'x'}},
+		"Struct":      {Location{Line: 1, Column: 2}, []byte{0xbf, 0x64, 'l', 'i', 'n', 'e', 0x01, 0x66, 'c', 'o', 'l', 'u', 'm', 'n', 0x02, 0xff}},
+		"OmitEmpty":   {&Error{Message: "x"}, []byte{0xbf, 0x67, 'm', 'e', 's', 's', 'a', 'g', 'e', 0x61, 'x', 0xff}},
+		"NestedPath":  {&Error{Message: "x", Path: NewPath("a", 1)}, []byte{0xbf, 0x67, 'm', 'e', 's', 's', 'a', 'g', 'e', 0x61, 'x', 0x64, 'p', 'a', 't', 'h', 0x82, 0x61, 'a', 0x01, 0xff}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf, err := MarshalCBOR(tc.Value)
+			require.NoError(t, err)
+			assert.Equal(t, tc.Expected, buf)
+		})
+	}
+}
+
+func TestBinaryEncoding_RoundTrip(t *testing.T) {
+	for name, codec := range map[string]struct {
+		Marshal   func(interface{}) ([]byte, error)
+		Unmarshal func([]byte, interface{}) error
+	}{
+		"Msgpack": {MarshalMsgpack, UnmarshalMsgpack},
+		"CBOR":    {MarshalCBOR, UnmarshalCBOR},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf, err := codec.Marshal(NewPath("users", 0, "name"))
+			require.NoError(t, err)
+			var path Path
+			require.NoError(t, codec.Unmarshal(buf, &path))
+			assert.Equal(t, NewPath("users", 0, "name"), path)
+
+			data := NewOrderedMap()
+			data.Append("a", nil)
+			var dataValue interface{} = data
+			buf, err = codec.Marshal(&Response{
+				Data: &dataValue,
+				Errors: []*Error{
+					{
+						Message:   "foo",
+						Locations: []Location{{Line: 1, Column: 2}},
+						Path:      NewPath("a", 1),
+					},
+					{
+						Message: "bar",
+					},
+				},
+			})
+			require.NoError(t, err)
+			var resp Response
+			require.NoError(t, codec.Unmarshal(buf, &resp))
+			require.NotNil(t, resp.Data)
+			assert.Equal(t, map[string]interface{}{"a": nil}, *resp.Data)
+			assert.Equal(t, []*Error{
+				{
+					Message:   "foo",
+					Locations: []Location{{Line: 1, Column: 2}},
+					Path:      NewPath("a", 1),
+				},
+				{
+					Message: "bar",
+				},
+			}, resp.Errors)
+
+			for _, invalid := range [][]interface{}{{""}, {1.5}, {-1}, {nil}, {true}} {
+				buf, err := codec.Marshal(invalid)
+				require.NoError(t, err)
+				assert.Error(t, codec.Unmarshal(buf, &path), "%v", invalid)
+			}
+		})
+	}
+}
+
+func TestNegotiateResponseContentType(t *testing.T) {
+	for name, tc := range map[string]struct {
+		Accept   []string
+		Expected string
+	}{
+		"None":            {nil, ""},
+		"JSON":            {[]string{"application/json"}, ""},
+		"Wildcard":        {[]string{"*/*"}, ""},
+		"Msgpack":         {[]string{"application/msgpack"}, MsgpackContentType},
+		"CBOR":            {[]string{"application/cbor, application/json;q=0.9"}, CBORContentType},
+		"PreferJSON":      {[]string{"application/json", "application/cbor"}, ""},
+		"Quality":         {[]string{"application/json;q=0.5, application/msgpack;q=0.8"}, MsgpackContentType},
+		"LowerQuality":    {[]string{"application/msgpack;q=0.1, application/graphql-response+json"}, ""},
+		"CaseInsensitive": {[]string{"Application/CBOR"}, CBORContentType},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, NegotiateResponseContentType(tc.Accept))
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package graphql
+
+import (
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/validator"
+)
+
+// FoldConstantDirectives returns a copy of the document in which directives such as @skip and
+// @include are evaluated ahead of time if their arguments don't reference variables. Selections
+// that they exclude are removed, and directives that include their selections are dropped, so the
+// executor doesn't evaluate them again for every execution. Directives that reference variables
+// are left alone.
+//
+// The document should already be validated. The given document isn't modified.
+func FoldConstantDirectives(doc *ast.Document, schema *Schema) *ast.Document {
+	return ast.Rewrite(doc, func(node ast.Node) ast.Node {
+		selection, ok := node.(ast.Selection)
+		if !ok {
+			return node
+		}
+		if validator.IsStaticallyExcluded(schema, selection) {
+			return nil
+		}
+
+		directives := selection.SelectionDirectives()
+		var remaining []*ast.Directive
+		for i, directive := range directives {
+			if include, ok := validator.StaticFieldCollectionFilter(schema, directive); ok && include {
+				if remaining == nil {
+					remaining = append(make([]*ast.Directive, 0, len(directives)-1), directives[:i]...)
+				}
+			} else if remaining != nil {
+				remaining = append(remaining, directive)
+			}
+		}
+		if remaining == nil {
+			return node
+		} else if len(remaining) == 0 {
+			remaining = nil
+		}
+
+		switch selection := selection.(type) {
+		case *ast.Field:
+			ret := *selection
+			ret.Directives = remaining
+			return &ret
+		case *ast.FragmentSpread:
+			ret := *selection
+			ret.Directives = remaining
+			return &ret
+		case *ast.InlineFragment:
+			ret := *selection
+			ret.Directives = remaining
+			return &ret
+		}
+		return node
+	}).(*ast.Document)
+}
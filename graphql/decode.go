@@ -0,0 +1,354 @@
+package graphql
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/ccbrown/api-fu/graphql/executor"
+)
+
+// DecodeError is returned by DecodeResult when a value can't be decoded into the target.
+type DecodeError struct {
+	// The path of the value within the result.
+	Path Path
+
+	Message string
+}
+
+func (err *DecodeError) Error() string {
+	if len(err.Path) == 0 {
+		return err.Message
+	}
+	return fmt.Sprintf("%v (at %v)", err.Message, err.Path)
+}
+
+// DecodeResult decodes execution result data, such as Response.Data, into the value pointed to by
+// target. It behaves like unmarshaling the data's JSON representation with encoding/json, but
+// doesn't require a round-trip through JSON. Struct fields are matched to result keys using their
+// json tags, or case-insensitively by name if they don't have one.
+//
+// Types that implement json.Unmarshaler or encoding.TextUnmarshaler are decoded via those
+// interfaces.
+func DecodeResult(data interface{}, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return &DecodeError{Message: fmt.Sprintf("decode target must be a non-nil pointer, not %T", target)}
+	}
+	if p, ok := data.(*interface{}); ok {
+		if p == nil {
+			data = nil
+		} else {
+			data = *p
+		}
+	}
+	d := &decoder{}
+	return d.decode(data, v.Elem())
+}
+
+type decoder struct {
+	path Path
+}
+
+func (d *decoder) errorf(format string, args ...interface{}) error {
+	return &DecodeError{
+		Path:    append(Path(nil), d.path...),
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func (d *decoder) decode(src interface{}, dst reflect.Value) error {
+	if src == nil {
+		switch dst.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			dst.Set(reflect.Zero(dst.Type()))
+		}
+		return nil
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return d.decode(src, dst.Elem())
+	}
+
+	if dst.CanAddr() {
+		if ptr := dst.Addr(); ptr.Type().Implements(jsonUnmarshalerType) {
+			b, err := json.Marshal(src)
+			if err != nil {
+				return d.errorf("unable to marshal %T: %v", src, err)
+			} else if err := ptr.Interface().(json.Unmarshaler).UnmarshalJSON(b); err != nil {
+				return d.errorf("%v", err)
+			}
+			return nil
+		} else if s, ok := src.(string); ok && ptr.Type().Implements(textUnmarshalerType) {
+			if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+				return d.errorf("%v", err)
+			}
+			return nil
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return d.errorf("cannot decode into non-empty interface %v", dst.Type())
+		}
+		dst.Set(reflect.ValueOf(plainValue(src)))
+		return nil
+	case reflect.Struct:
+		m, ok := src.(*executor.OrderedMap)
+		if !ok {
+			return d.errorf("cannot decode %T into %v", src, dst.Type())
+		}
+		fields := cachedStructFields(dst.Type())
+		for _, item := range m.Items() {
+			index, ok := fields.byName[item.Key]
+			if !ok {
+				index, ok = fields.byFoldedName[strings.ToLower(item.Key)]
+			}
+			if !ok {
+				continue
+			}
+			field, err := fieldByIndex(dst, index)
+			if err != nil {
+				return d.errorf("%v", err)
+			}
+			d.path = append(d.path, KeySegment(item.Key))
+			err = d.decode(item.Value, field)
+			d.path = d.path[:len(d.path)-1]
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		m, ok := src.(*executor.OrderedMap)
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return d.errorf("cannot decode %T into %v", src, dst.Type())
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), m.Len()))
+		}
+		for _, item := range m.Items() {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			d.path = append(d.path, KeySegment(item.Key))
+			err := d.decode(item.Value, elem)
+			d.path = d.path[:len(d.path)-1]
+			if err != nil {
+				return err
+			}
+			dst.SetMapIndex(reflect.ValueOf(item.Key).Convert(dst.Type().Key()), elem)
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		list, ok := src.([]interface{})
+		if !ok {
+			return d.errorf("cannot decode %T into %v", src, dst.Type())
+		}
+		if dst.Kind() == reflect.Slice {
+			dst.Set(reflect.MakeSlice(dst.Type(), len(list), len(list)))
+		} else if dst.Len() < len(list) {
+			return d.errorf("cannot decode list of length %v into %v", len(list), dst.Type())
+		}
+		for i, item := range list {
+			d.path = append(d.path, IndexSegment(i))
+			err := d.decode(item, dst.Index(i))
+			d.path = d.path[:len(d.path)-1]
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	v := reflect.ValueOf(src)
+	switch dst.Kind() {
+	case reflect.String:
+		if v.Kind() == reflect.String {
+			dst.SetString(v.String())
+			return nil
+		}
+	case reflect.Bool:
+		if v.Kind() == reflect.Bool {
+			dst.SetBool(v.Bool())
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = v.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = int64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			if f := v.Float(); f == float64(int64(f)) {
+				n = int64(f)
+			} else {
+				return d.errorf("cannot decode non-integer %v into %v", f, dst.Type())
+			}
+		default:
+			return d.errorf("cannot decode %T into %v", src, dst.Type())
+		}
+		if dst.OverflowInt(n) {
+			return d.errorf("value %v overflows %v", n, dst.Type())
+		}
+		dst.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.Int() < 0 {
+				return d.errorf("cannot decode negative value %v into %v", v.Int(), dst.Type())
+			}
+			n = uint64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = v.Uint()
+		case reflect.Float32, reflect.Float64:
+			if f := v.Float(); f >= 0 && f == float64(uint64(f)) {
+				n = uint64(f)
+			} else {
+				return d.errorf("cannot decode %v into %v", f, dst.Type())
+			}
+		default:
+			return d.errorf("cannot decode %T into %v", src, dst.Type())
+		}
+		if dst.OverflowUint(n) {
+			return d.errorf("value %v overflows %v", n, dst.Type())
+		}
+		dst.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			dst.SetFloat(float64(v.Int()))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			dst.SetFloat(float64(v.Uint()))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			dst.SetFloat(v.Float())
+			return nil
+		}
+	}
+
+	return d.errorf("cannot decode %T into %v", src, dst.Type())
+}
+
+// Converts ordered maps into regular maps so that results can be decoded into interface{} values.
+func plainValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *executor.OrderedMap:
+		ret := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			ret[item.Key] = plainValue(item.Value)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(v))
+		for i, item := range v {
+			ret[i] = plainValue(item)
+		}
+		return ret
+	}
+	return v
+}
+
+// Gets the field with the given index, allocating embedded pointers as necessary.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct %v", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+type structFields struct {
+	byName       map[string][]int
+	byFoldedName map[string][]int
+}
+
+var structFieldsCache sync.Map
+
+func cachedStructFields(t reflect.Type) *structFields {
+	if f, ok := structFieldsCache.Load(t); ok {
+		return f.(*structFields)
+	}
+	fields := &structFields{
+		byName:       map[string][]int{},
+		byFoldedName: map[string][]int{},
+	}
+	addStructFields(fields, t, nil, map[reflect.Type]struct{}{})
+	f, _ := structFieldsCache.LoadOrStore(t, fields)
+	return f.(*structFields)
+}
+
+func addStructFields(fields *structFields, t reflect.Type, index []int, visited map[reflect.Type]struct{}) {
+	if _, ok := visited[t]; ok {
+		return
+	}
+	visited[t] = struct{}{}
+
+	// Fields at shallower depths take precedence, so embedded structs are visited last.
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, field)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldIndex := append(index[:len(index):len(index)], i)
+		if name != "" {
+			if _, ok := fields.byName[name]; !ok {
+				fields.byName[name] = fieldIndex
+			}
+		} else {
+			name = field.Name
+		}
+		if _, ok := fields.byFoldedName[strings.ToLower(name)]; !ok {
+			fields.byFoldedName[strings.ToLower(name)] = fieldIndex
+		}
+	}
+
+	for _, field := range embedded {
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		addStructFields(fields, ft, append(index[:len(index):len(index)], field.Index[0]), visited)
+	}
+}
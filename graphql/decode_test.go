@@ -0,0 +1,155 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type decodeTestBase struct {
+	Id string `json:"id"`
+}
+
+type decodeTestUser struct {
+	decodeTestBase
+	Name       *string
+	Age        uint8             `json:"years"`
+	Scores     []float64         `json:"scores"`
+	Created    time.Time         `json:"created"`
+	Friends    []*decodeTestUser `json:"friends"`
+	Attributes map[string]int    `json:"attributes"`
+	Anything   interface{}       `json:"anything"`
+	Ignored    string            `json:"-"`
+}
+
+func TestDecodeResult(t *testing.T) {
+	attributesType := &ObjectType{
+		Name: "Attributes",
+		Fields: map[string]*FieldDefinition{
+			"height": {
+				Type: IntType,
+				Resolve: func(FieldContext) (interface{}, error) {
+					return 180, nil
+				},
+			},
+		},
+	}
+	userType := &ObjectType{
+		Name: "User",
+	}
+	userType.Fields = map[string]*FieldDefinition{
+		"id": {
+			Type: NewNonNullType(IDType),
+			Resolve: func(ctx FieldContext) (interface{}, error) {
+				return ctx.Object, nil
+			},
+		},
+		"name": {
+			Type: StringType,
+			Resolve: func(ctx FieldContext) (interface{}, error) {
+				return "user " + ctx.Object.(string), nil
+			},
+		},
+		"age": {
+			Type: IntType,
+			Resolve: func(FieldContext) (interface{}, error) {
+				return 42, nil
+			},
+		},
+		"scores": {
+			Type: NewListType(FloatType),
+			Resolve: func(FieldContext) (interface{}, error) {
+				return []float64{1.5, 2}, nil
+			},
+		},
+		"created": {
+			Type: StringType,
+			Resolve: func(FieldContext) (interface{}, error) {
+				return "2020-01-02T03:04:05Z", nil
+			},
+		},
+		"friends": {
+			Type: NewListType(userType),
+			Resolve: func(FieldContext) (interface{}, error) {
+				return []string{"2", "3"}, nil
+			},
+		},
+		"attributes": {
+			Type: attributesType,
+			Resolve: func(FieldContext) (interface{}, error) {
+				return struct{}{}, nil
+			},
+		},
+	}
+	s, err := NewSchema(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"user": {
+					Type: userType,
+					Resolve: func(FieldContext) (interface{}, error) {
+						return "1", nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	resp := Execute(&Request{
+		Context: context.Background(),
+		Query: `{
+			user {
+				id
+				name
+				years: age
+				scores
+				created
+				friends { id }
+				attributes { height }
+				anything: attributes { height }
+			}
+		}`,
+		Schema: s,
+	})
+	require.Empty(t, resp.Errors)
+
+	var result struct {
+		User *decodeTestUser `json:"user"`
+	}
+	require.NoError(t, DecodeResult(resp.Data, &result))
+
+	name := "user 1"
+	assert.Equal(t, &decodeTestUser{
+		decodeTestBase: decodeTestBase{Id: "1"},
+		Name:           &name,
+		Age:            42,
+		Scores:         []float64{1.5, 2},
+		Created:        time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Friends: []*decodeTestUser{
+			{decodeTestBase: decodeTestBase{Id: "2"}},
+			{decodeTestBase: decodeTestBase{Id: "3"}},
+		},
+		Attributes: map[string]int{"height": 180},
+		Anything:   map[string]interface{}{"height": 180},
+	}, result.User)
+
+	t.Run("TypeMismatch", func(t *testing.T) {
+		var result struct {
+			User struct {
+				Name int `json:"name"`
+			} `json:"user"`
+		}
+		err := DecodeResult(resp.Data, &result)
+		require.IsType(t, &DecodeError{}, err)
+		assert.Equal(t, NewPath("user", "name"), err.(*DecodeError).Path)
+	})
+
+	t.Run("NonPointer", func(t *testing.T) {
+		var result struct{}
+		assert.Error(t, DecodeResult(resp.Data, result))
+	})
+}
@@ -0,0 +1,135 @@
+package graphql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/executor"
+	"github.com/ccbrown/api-fu/graphql/schema"
+	"github.com/ccbrown/api-fu/graphql/validator"
+)
+
+// DeprecationWarning describes an operation's usage of a deprecated field or enum value. If
+// Request.ReportDeprecations is true, these are reported in the response's "deprecations"
+// extension.
+type DeprecationWarning struct {
+	// The schema coordinate of the deprecated field or enum value, e.g. "User.name" or
+	// "Status.INACTIVE".
+	Coordinate string `json:"coordinate"`
+
+	// The path of the field within the response data. List indices are omitted. For enum values,
+	// this is the path of the field whose arguments use the value.
+	Path Path `json:"path"`
+
+	Reason string `json:"reason"`
+
+	// If the deprecated field has a sunset time, this is the time formatted as an RFC 3339 string.
+	// Otherwise, if the field or enum value has a directive with a string "sunset" argument, this
+	// is its value.
+	Sunset string `json:"sunset,omitempty"`
+
+	Locations []Location `json:"locations"`
+}
+
+// DeprecationWarnings returns warnings for all of the deprecated fields and enum values used by an
+// operation. The document must be valid.
+func DeprecationWarnings(doc *ast.Document, s *Schema, features FeatureSet, operationName string) []*DeprecationWarning {
+	operation, err := executor.GetOperation(doc, operationName)
+	if err != nil {
+		return nil
+	}
+
+	typeInfo := validator.NewTypeInfo(doc, s, features)
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[def.Name.Name] = def
+		}
+	}
+
+	var ret []*DeprecationWarning
+	warningsByKey := map[string]*DeprecationWarning{}
+	addWarning := func(node ast.Node, coordinate string, path Path, reason string, sunset string) {
+		location := Location{
+			Line:   node.Position().Line,
+			Column: node.Position().Column,
+		}
+		key := fmt.Sprintf("%v %v", coordinate, path)
+		if warning, ok := warningsByKey[key]; ok {
+			warning.Locations = append(warning.Locations, location)
+			return
+		}
+		warning := &DeprecationWarning{
+			Coordinate: coordinate,
+			Path:       path,
+			Reason:     reason,
+			Sunset:     sunset,
+			Locations:  []Location{location},
+		}
+		warningsByKey[key] = warning
+		ret = append(ret, warning)
+	}
+
+	var visitSelectionSet func(selectionSet *ast.SelectionSet, path Path)
+	visitSelectionSet = func(selectionSet *ast.SelectionSet, path Path) {
+		for _, selection := range selectionSet.Selections {
+			switch selection := selection.(type) {
+			case *ast.Field:
+				responseKey := selection.Name.Name
+				if selection.Alias != nil {
+					responseKey = selection.Alias.Name
+				}
+				fieldPath := path.Append(KeySegment(responseKey))
+
+				if def := typeInfo.FieldDefinitions[selection]; def != nil && def.DeprecationReason != "" {
+					if parent := typeInfo.SelectionSetTypes[selectionSet]; parent != nil {
+						sunset := sunsetFromDirectives(def.Directives)
+						if !def.SunsetTime.IsZero() {
+							sunset = def.SunsetTime.UTC().Format(time.RFC3339)
+						}
+						addWarning(selection, parent.TypeName()+"."+selection.Name.Name, fieldPath, def.DeprecationReason, sunset)
+					}
+				}
+
+				for _, argument := range selection.Arguments {
+					ast.Inspect(argument.Value, func(node ast.Node) bool {
+						value, ok := node.(*ast.EnumValue)
+						if !ok {
+							return true
+						}
+						if enumType, ok := schema.NullableType(typeInfo.ExpectedTypes[value]).(*schema.EnumType); ok {
+							if def, ok := enumType.Values[value.Value]; ok && def.DeprecationReason != "" {
+								addWarning(value, enumType.Name+"."+value.Value, fieldPath, def.DeprecationReason, sunsetFromDirectives(def.Directives))
+							}
+						}
+						return true
+					})
+				}
+
+				if selection.SelectionSet != nil {
+					visitSelectionSet(selection.SelectionSet, fieldPath)
+				}
+			case *ast.InlineFragment:
+				visitSelectionSet(selection.SelectionSet, path)
+			case *ast.FragmentSpread:
+				if fragment, ok := fragments[selection.FragmentName.Name]; ok {
+					visitSelectionSet(fragment.SelectionSet, path)
+				}
+			}
+		}
+	}
+	visitSelectionSet(operation.SelectionSet, Path{})
+	return ret
+}
+
+func sunsetFromDirectives(directives []*schema.Directive) string {
+	for _, directive := range directives {
+		for _, argument := range directive.Arguments {
+			if s, ok := argument.Value.(string); ok && argument.Name == "sunset" {
+				return s
+			}
+		}
+	}
+	return ""
+}
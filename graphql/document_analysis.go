@@ -0,0 +1,271 @@
+package graphql
+
+import (
+	"sort"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/executor"
+	"github.com/ccbrown/api-fu/graphql/schema"
+	"github.com/ccbrown/api-fu/graphql/validator"
+)
+
+// DocumentAnalysis describes redundancy within an operation. See AnalyzeDocument.
+type DocumentAnalysis struct {
+	// The sorted names of the document's fragments that the operation doesn't use, either
+	// directly or via other fragments.
+	UnusedFragments []string
+
+	// The response keys that the operation selects more than once on the same type, e.g. once
+	// directly and once via a fragment. The executor merges these selections, so they're harmless,
+	// but heavily composed documents may contain many of them.
+	OverlappingSelections []*OverlappingSelection
+}
+
+// OverlappingSelection describes a response key that's selected more than once on the same type.
+type OverlappingSelection struct {
+	// The path of the response key within the response data. List indices are omitted.
+	Path Path `json:"path"`
+
+	// The locations of each of the selections.
+	Locations []Location `json:"locations"`
+}
+
+// AnalyzeDocument reports unused fragments and overlapping selections for an operation. The
+// document must be valid. If the operation can't be found, nil is returned.
+func AnalyzeDocument(doc *ast.Document, s *Schema, features FeatureSet, operationName string) *DocumentAnalysis {
+	operation, err := executor.GetOperation(doc, operationName)
+	if err != nil {
+		return nil
+	}
+
+	typeInfo := validator.NewTypeInfo(doc, s, features)
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[def.Name.Name] = def
+		}
+	}
+
+	ret := &DocumentAnalysis{}
+	usedFragments := map[string]struct{}{}
+
+	var analyzeSelectionSets func(path Path, selectionSets []*ast.SelectionSet)
+	analyzeSelectionSets = func(path Path, selectionSets []*ast.SelectionSet) {
+		type selectedField struct {
+			field      *ast.Field
+			parentType string
+		}
+		var keys []string
+		fieldsByKey := map[string][]selectedField{}
+		visitedFragments := map[string]struct{}{}
+
+		var collect func(selectionSet *ast.SelectionSet)
+		collect = func(selectionSet *ast.SelectionSet) {
+			for _, selection := range selectionSet.Selections {
+				switch selection := selection.(type) {
+				case *ast.Field:
+					key := responseKey(selection)
+					if _, ok := fieldsByKey[key]; !ok {
+						keys = append(keys, key)
+					}
+					var parentType string
+					if parent := typeInfo.SelectionSetTypes[selectionSet]; parent != nil {
+						parentType = parent.TypeName()
+					}
+					fieldsByKey[key] = append(fieldsByKey[key], selectedField{
+						field:      selection,
+						parentType: parentType,
+					})
+				case *ast.InlineFragment:
+					collect(selection.SelectionSet)
+				case *ast.FragmentSpread:
+					name := selection.FragmentName.Name
+					usedFragments[name] = struct{}{}
+					if _, ok := visitedFragments[name]; ok {
+						continue
+					}
+					visitedFragments[name] = struct{}{}
+					if fragment, ok := fragments[name]; ok {
+						collect(fragment.SelectionSet)
+					}
+				}
+			}
+		}
+		for _, selectionSet := range selectionSets {
+			collect(selectionSet)
+		}
+
+		for _, key := range keys {
+			fields := fieldsByKey[key]
+			fieldPath := path.Append(KeySegment(key))
+
+			var locations []Location
+			countsByType := map[string]int{}
+			for _, field := range fields {
+				countsByType[field.parentType]++
+			}
+			for _, field := range fields {
+				if countsByType[field.parentType] > 1 {
+					locations = append(locations, Location{
+						Line:   field.field.Position().Line,
+						Column: field.field.Position().Column,
+					})
+				}
+			}
+			if len(locations) > 0 {
+				ret.OverlappingSelections = append(ret.OverlappingSelections, &OverlappingSelection{
+					Path:      fieldPath,
+					Locations: locations,
+				})
+			}
+
+			var subselections []*ast.SelectionSet
+			for _, field := range fields {
+				if field.field.SelectionSet != nil {
+					subselections = append(subselections, field.field.SelectionSet)
+				}
+			}
+			if len(subselections) > 0 {
+				analyzeSelectionSets(fieldPath, subselections)
+			}
+		}
+	}
+	analyzeSelectionSets(nil, []*ast.SelectionSet{operation.SelectionSet})
+
+	for name := range fragments {
+		if _, ok := usedFragments[name]; !ok {
+			ret.UnusedFragments = append(ret.UnusedFragments, name)
+		}
+	}
+	sort.Strings(ret.UnusedFragments)
+	return ret
+}
+
+// DeduplicateSelections returns a copy of the document in which redundant selections are removed.
+// Within each selection set:
+//
+//   - Fields with the same response key and no directives are merged into the first of them.
+//   - Repeated spreads of the same fragment without directives are removed.
+//   - Inline fragments without directives whose type conditions are absent or match the
+//     enclosing type are replaced by their selections.
+//
+// This doesn't change the operations' results, but it reduces the work the executor does to
+// collect and merge fields, especially for heavily fragment-composed documents such as those
+// generated by Relay. The document must be valid, and the given document isn't modified.
+func DeduplicateSelections(doc *ast.Document, s *Schema, features FeatureSet) *ast.Document {
+	d := &selectionDeduplicator{
+		typeInfo: validator.NewTypeInfo(doc, s, features),
+	}
+	ret := *doc
+	ret.Definitions = make([]ast.Definition, len(doc.Definitions))
+	for i, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.OperationDefinition:
+			c := *def
+			c.SelectionSet = d.selectionSet(def.SelectionSet)
+			ret.Definitions[i] = &c
+		case *ast.FragmentDefinition:
+			c := *def
+			c.SelectionSet = d.selectionSet(def.SelectionSet)
+			ret.Definitions[i] = &c
+		default:
+			ret.Definitions[i] = def
+		}
+	}
+	return &ret
+}
+
+type selectionDeduplicator struct {
+	typeInfo *validator.TypeInfo
+}
+
+func (d *selectionDeduplicator) selectionSet(selectionSet *ast.SelectionSet) *ast.SelectionSet {
+	ret := *selectionSet
+	ret.Selections = d.selections(d.typeInfo.SelectionSetTypes[selectionSet], []*ast.SelectionSet{selectionSet})
+	return &ret
+}
+
+// Returns the deduplicated combination of the given selection sets, which must all belong to the
+// given type.
+func (d *selectionDeduplicator) selections(parentType schema.NamedType, selectionSets []*ast.SelectionSet) []ast.Selection {
+	var ret []ast.Selection
+	fieldsByKey := map[string]int{}
+	subselections := map[int][]*ast.SelectionSet{}
+	spreads := map[string]struct{}{}
+
+	var add func(selections []ast.Selection)
+	add = func(selections []ast.Selection) {
+		for _, selection := range selections {
+			switch selection := selection.(type) {
+			case *ast.Field:
+				if len(selection.Directives) == 0 {
+					key := responseKey(selection)
+					if i, ok := fieldsByKey[key]; ok && isSameField(ret[i].(*ast.Field), selection) {
+						if selection.SelectionSet != nil {
+							subselections[i] = append(subselections[i], selection.SelectionSet)
+						}
+						continue
+					} else if !ok {
+						fieldsByKey[key] = len(ret)
+					}
+				}
+				if selection.SelectionSet != nil {
+					subselections[len(ret)] = []*ast.SelectionSet{selection.SelectionSet}
+				}
+				ret = append(ret, selection)
+			case *ast.FragmentSpread:
+				if len(selection.Directives) == 0 {
+					if _, ok := spreads[selection.FragmentName.Name]; ok {
+						continue
+					}
+					spreads[selection.FragmentName.Name] = struct{}{}
+				}
+				ret = append(ret, selection)
+			case *ast.InlineFragment:
+				if len(selection.Directives) == 0 && (selection.TypeCondition == nil || (parentType != nil && selection.TypeCondition.Name.Name == parentType.TypeName())) {
+					add(selection.SelectionSet.Selections)
+					continue
+				}
+				c := *selection
+				c.SelectionSet = d.selectionSet(selection.SelectionSet)
+				ret = append(ret, &c)
+			}
+		}
+	}
+	for _, selectionSet := range selectionSets {
+		add(selectionSet.Selections)
+	}
+
+	for i, selectionSets := range subselections {
+		field := *ret[i].(*ast.Field)
+		selectionSet := *selectionSets[0]
+		selectionSet.Selections = d.selections(d.typeInfo.SelectionSetTypes[selectionSets[0]], selectionSets)
+		field.SelectionSet = &selectionSet
+		ret[i] = &field
+	}
+	return ret
+}
+
+// Returns true if the fields have the same names and arguments, regardless of the arguments' order.
+func isSameField(a, b *ast.Field) bool {
+	if a.Name.Name != b.Name.Name || len(a.Arguments) != len(b.Arguments) {
+		return false
+	}
+	values := make(map[string]string, len(a.Arguments))
+	for _, arg := range a.Arguments {
+		values[arg.Name.Name] = ast.Print(arg.Value)
+	}
+	for _, arg := range b.Arguments {
+		if value, ok := values[arg.Name.Name]; !ok || value != ast.Print(arg.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func responseKey(field *ast.Field) string {
+	if field.Alias != nil {
+		return field.Alias.Name
+	}
+	return field.Name.Name
+}
@@ -0,0 +1,173 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
+)
+
+func newDocumentAnalysisTestSchema(t *testing.T) *Schema {
+	nodeType := &InterfaceType{
+		Name: "Node",
+		Fields: map[string]*FieldDefinition{
+			"id": {
+				Type: IntType,
+			},
+		},
+	}
+
+	userType := &ObjectType{
+		Name:                  "User",
+		ImplementedInterfaces: []*InterfaceType{nodeType},
+		IsTypeOf: func(v interface{}) bool {
+			return v == "user"
+		},
+	}
+	userType.Fields = map[string]*FieldDefinition{
+		"id": {
+			Type: IntType,
+			Resolve: func(FieldContext) (interface{}, error) {
+				return 1, nil
+			},
+		},
+		"name": {
+			Type: StringType,
+			Resolve: func(FieldContext) (interface{}, error) {
+				return "alice", nil
+			},
+		},
+		"friends": {
+			Type: NewListType(userType),
+			Arguments: map[string]*InputValueDefinition{
+				"first": {
+					Type: IntType,
+				},
+			},
+			Resolve: func(FieldContext) (interface{}, error) {
+				return []interface{}{"user", "user"}, nil
+			},
+		},
+	}
+
+	botType := &ObjectType{
+		Name:                  "Bot",
+		ImplementedInterfaces: []*InterfaceType{nodeType},
+		Fields: map[string]*FieldDefinition{
+			"id": {
+				Type: IntType,
+			},
+			"name": {
+				Type: StringType,
+			},
+		},
+		IsTypeOf: func(v interface{}) bool {
+			return v == "bot"
+		},
+	}
+
+	s, err := NewSchema(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"user": {
+					Type: userType,
+					Resolve: func(FieldContext) (interface{}, error) {
+						return "user", nil
+					},
+				},
+				"node": {
+					Type: nodeType,
+					Resolve: func(FieldContext) (interface{}, error) {
+						return "user", nil
+					},
+				},
+			},
+		},
+		AdditionalTypes: []NamedType{botType},
+		Directives: map[string]*DirectiveDefinition{
+			"include": IncludeDirective,
+		},
+	})
+	require.NoError(t, err)
+	return s
+}
+
+const documentAnalysisTestQuery = `
+	query Q($b: Boolean!) {
+		user {
+			id
+			...UserFields
+			... on User { name }
+			friends(first: 2) { id }
+			name @include(if: $b)
+		}
+		node { ... on User { name } ... on Bot { name } }
+	}
+	fragment UserFields on User { id friends(first: 2) { name ...Friend } ...Friend }
+	fragment Friend on User { id }
+	query Other { user { ...Other } }
+	fragment Other on User { id }
+`
+
+func TestAnalyzeDocument(t *testing.T) {
+	s := newDocumentAnalysisTestSchema(t)
+	doc, errs := ParseAndValidate(documentAnalysisTestQuery, s, nil)
+	require.Empty(t, errs)
+
+	analysis := AnalyzeDocument(doc, s, nil, "Q")
+	require.NotNil(t, analysis)
+	assert.Equal(t, []string{"Other"}, analysis.UnusedFragments)
+
+	paths := make([]string, len(analysis.OverlappingSelections))
+	for i, overlap := range analysis.OverlappingSelections {
+		paths[i] = overlap.Path.String()
+	}
+	assert.Equal(t, []string{
+		"user.id",
+		"user.friends",
+		"user.friends.id",
+		"user.name",
+	}, paths)
+	assert.Len(t, analysis.OverlappingSelections[0].Locations, 3)
+	assert.Equal(t, []Location{{Line: 12, Column: 35}, {Line: 7, Column: 4}}, analysis.OverlappingSelections[1].Locations)
+
+	assert.Nil(t, AnalyzeDocument(doc, s, nil, "Nonexistent"))
+}
+
+func TestDeduplicateSelections(t *testing.T) {
+	s := newDocumentAnalysisTestSchema(t)
+	doc, errs := ParseAndValidate(documentAnalysisTestQuery, s, nil)
+	require.Empty(t, errs)
+	original := ast.Print(doc)
+
+	deduplicated := DeduplicateSelections(doc, s, nil)
+	assert.Equal(t, original, ast.Print(doc), "the original document must not be modified")
+	assert.Equal(t, "query Q($b: Boolean!) {user {id ...UserFields name friends(first: 2) {id} name @include(if: $b)} node {... on User {name} ... on Bot {name}}} "+
+		"fragment UserFields on User {id friends(first: 2) {name ...Friend} ...Friend} "+
+		"fragment Friend on User {id} "+
+		"query Other {user {...Other}} "+
+		"fragment Other on User {id}", ast.Print(deduplicated))
+
+	for _, variables := range []map[string]interface{}{{"b": true}, {"b": false}} {
+		var results []string
+		for _, doc := range []*ast.Document{doc, deduplicated} {
+			resp := Execute(&Request{
+				Context:        context.Background(),
+				Document:       doc,
+				Schema:         s,
+				OperationName:  "Q",
+				VariableValues: variables,
+			})
+			require.Empty(t, resp.Errors)
+			body, err := json.Marshal(resp)
+			require.NoError(t, err)
+			results = append(results, string(body))
+		}
+		assert.Equal(t, results[0], results[1])
+	}
+}
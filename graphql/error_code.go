@@ -0,0 +1,125 @@
+package graphql
+
+import (
+	"fmt"
+
+	"github.com/ccbrown/api-fu/graphql/validator"
+)
+
+// ErrorCode classifies errors so that clients can handle them programmatically. Errors with codes
+// include the code in their extensions' "code" property along with a "retryable" flag.
+type ErrorCode string
+
+const (
+	// The request was invalid, e.g. an argument was malformed. Retrying won't help.
+	ErrorCodeBadUserInput ErrorCode = "BAD_USER_INPUT"
+
+	// The request requires authentication.
+	ErrorCodeUnauthenticated ErrorCode = "UNAUTHENTICATED"
+
+	// The requester isn't allowed to perform the operation.
+	ErrorCodeForbidden ErrorCode = "FORBIDDEN"
+
+	// The requested object doesn't exist.
+	ErrorCodeNotFound ErrorCode = "NOT_FOUND"
+
+	// An unexpected error occurred on the server.
+	ErrorCodeInternal ErrorCode = "INTERNAL"
+
+	// A dependency of the server is temporarily unavailable. The request may succeed if retried.
+	ErrorCodeUnavailable ErrorCode = "UNAVAILABLE"
+
+	// The operation exceeded its timeout. The request may succeed if retried.
+	ErrorCodeTimeout ErrorCode = "TIMEOUT"
+
+	// The operation uses a field that has been retired. See ValidateSunsets.
+	ErrorCodeFieldSunset ErrorCode = validator.ErrorCodeFieldSunset
+
+	// The operation's type isn't allowed by the endpoint. See ValidateOperationTypes.
+	ErrorCodeOperationTypeNotAllowed ErrorCode = validator.ErrorCodeOperationTypeNotAllowed
+
+	// The requested registered operation doesn't exist.
+	ErrorCodeOperationNotFound ErrorCode = "OPERATION_NOT_FOUND"
+
+	// A result was too large to be sent.
+	ErrorCodePayloadTooLarge ErrorCode = "PAYLOAD_TOO_LARGE"
+
+	// The request only contained the hash of a persisted query, and the query wasn't found. The
+	// client is expected to retry with the full query.
+	ErrorCodePersistedQueryNotFound ErrorCode = "PERSISTED_QUERY_NOT_FOUND"
+
+	// The request's persisted query hash doesn't match its query.
+	ErrorCodePersistedQueryHashMismatch ErrorCode = "PERSISTED_QUERY_HASH_MISMATCH"
+
+	// The request only contained the hash of a persisted query, but the server doesn't support
+	// persisted queries.
+	ErrorCodePersistedQueryNotSupported ErrorCode = "PERSISTED_QUERY_NOT_SUPPORTED"
+)
+
+// IsRetryable returns true if a failed request with this code may succeed if retried without
+// modification.
+func (c ErrorCode) IsRetryable() bool {
+	return c == ErrorCodeUnavailable || c == ErrorCodeTimeout
+}
+
+// Extensions returns the extensions of an error with this code: its "code" and "retryable"
+// properties. Errors may add additional properties to the result.
+func (c ErrorCode) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":      string(c),
+		"retryable": c.IsRetryable(),
+	}
+}
+
+// CodedError is an error classified by an ErrorCode. Resolvers can return it (or an error that wraps
+// it) to populate the GraphQL error's "code" and "retryable" extensions.
+type CodedError struct {
+	Code    ErrorCode
+	Message string
+
+	// If non-nil, this overrides the code's default retryability.
+	Retryable *bool
+
+	// The underlying error, if any. It isn't exposed to clients.
+	Err error
+}
+
+// NewCodedError creates a new CodedError with the given code and formatted message.
+func NewCodedError(code ErrorCode, format string, args ...interface{}) *CodedError {
+	return &CodedError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// WrapCodedError classifies an existing error. The message sent to clients is the given message
+// rather than the underlying error's so that internal details aren't leaked.
+func WrapCodedError(err error, code ErrorCode, message string) *CodedError {
+	return &CodedError{
+		Code:    code,
+		Message: message,
+		Err:     err,
+	}
+}
+
+func (err *CodedError) Error() string {
+	return err.Message
+}
+
+func (err *CodedError) Unwrap() error {
+	return err.Err
+}
+
+// IsRetryable returns true if a failed request may succeed if retried without modification.
+func (err *CodedError) IsRetryable() bool {
+	if err.Retryable != nil {
+		return *err.Retryable
+	}
+	return err.Code.IsRetryable()
+}
+
+func (err *CodedError) Extensions() map[string]interface{} {
+	ret := err.Code.Extensions()
+	ret["retryable"] = err.IsRetryable()
+	return ret
+}
@@ -22,7 +22,7 @@ type Error struct {
 	Locations []Location
 
 	// If the error occurred during the resolution of a particular field, a path will be present.
-	Path []interface{}
+	Path Path
 
 	originalError error
 }
@@ -51,7 +51,7 @@ func newErrorWithPath(node ast.Node, path *path, message string, args ...interfa
 		}}
 	}
 	if path != nil {
-		ret.Path = path.Slice()
+		ret.Path = path.Path()
 	}
 	return ret
 }
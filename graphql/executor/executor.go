@@ -5,6 +5,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/ccbrown/api-fu/graphql/ast"
 	"github.com/ccbrown/api-fu/graphql/executor/internal/future"
@@ -24,6 +26,10 @@ type ResolveResult struct {
 // the field's resolve function. If you do, you must define an IdleHandler for the request. Any time
 // request execution is unable to proceed, the idle handler will be invoked. Before the idle handler
 // returns, a result must be sent to at least one previously returned ResolvePromise.
+//
+// If the promise's result is no longer needed, for example because an error nulled one of the
+// field's ancestors, the request's CancelPromise function is invoked so that any work being done to
+// fulfill the promise can be stopped.
 type ResolvePromise chan ResolveResult
 
 // Request defines all of the inputs required to execute a GraphQL query.
@@ -35,13 +41,62 @@ type Request struct {
 	Features       schema.FeatureSet
 	InitialValue   any
 	IdleHandler    func()
+
+	// If positive, execution will fail with an "async resolver deadlock" error if the idle handler
+	// returns this many consecutive times without a result being sent to any pending
+	// ResolvePromise. This guards against idle handlers that don't honor their contract, which would
+	// otherwise cause execution to loop forever.
+	IdleHandlerStallLimit int
+
+	// If non-nil, an entry will be appended for every nullable field whose value was set to null
+	// due to an error. This allows clients to distinguish legitimately null fields from fields that
+	// were nulled by errors.
+	NulledFields *[]NulledField
+
+	// If given, this is invoked for each pending ResolvePromise whose result is no longer needed.
+	// This happens when an error nulls one of the field's ancestors, or when execution completes
+	// before the promise is resolved, e.g. due to an error or context cancelation. It's always
+	// invoked from the goroutine executing the request, and it's fine for the promise to receive a
+	// result afterwards.
+	CancelPromise func(ResolvePromise)
+
+	// If given, this is invoked before each field is resolved. The returned context is passed to
+	// the resolver. If the returned function is non-nil, it's invoked once the resolver's result is
+	// available, with the error if resolution failed. It isn't invoked for abandoned promises. Both
+	// functions are always invoked from the goroutine executing the request.
+	//
+	// This can be used to trace or profile execution.
+	ObserveField func(ctx context.Context, field *ObservedField) (context.Context, func(error))
+}
+
+// ObservedField describes a field that's about to be resolved. See Request.ObserveField.
+type ObservedField struct {
+	// The path of the field within the response data.
+	Path Path
+
+	ObjectType *schema.ObjectType
+	Name       string
+	Definition *schema.FieldDefinition
+	Arguments  map[string]any
+}
+
+// NulledField describes a nullable field that was set to null due to an error.
+type NulledField struct {
+	// The path of the field within the response data.
+	Path Path
+
+	// The index of the originating error within the returned errors.
+	ErrorIndex int
 }
 
 // ExecuteRequest executes a request.
 func ExecuteRequest(ctx context.Context, r *Request) (*OrderedMap, []*Error) {
-	if e, err := newExecutor(ctx, r); err != nil {
+	e, err := newExecutor(ctx, r)
+	if err != nil {
 		return nil, []*Error{err}
-	} else if opType := e.Operation.OperationType; opType == nil || opType.Value == "query" {
+	}
+	defer e.cancelPendingPromises(nil)
+	if opType := e.Operation.OperationType; opType == nil || opType.Value == "query" {
 		return e.executeQuery(r.InitialValue)
 	} else if opType.Value == "mutation" {
 		return e.executeMutation(r.InitialValue)
@@ -78,6 +133,31 @@ type executor struct {
 	Operation           *ast.OperationDefinition
 	IdleHandler         func()
 
+	// IdleHandlerStallLimit is the number of consecutive unproductive idle handler invocations
+	// allowed before execution fails. See Request.IdleHandlerStallLimit.
+	IdleHandlerStallLimit int
+
+	// PendingPromises holds the paths of fields that are waiting on a ResolvePromise. It's used to
+	// produce useful diagnostics when the idle handler fails to make progress.
+	PendingPromises map[ResolvePromise]*path
+
+	// PendingReceives holds receives from list fields' channels that couldn't complete without
+	// blocking, along with the paths of the list fields.
+	PendingReceives map[*pendingReceive]*path
+
+	// ResolvedPromiseCount is incremented every time a pending ResolvePromise or receive gets a
+	// result.
+	ResolvedPromiseCount int
+
+	// If non-nil, fields nulled due to errors are recorded here. See Request.NulledFields.
+	NulledFields *[]NulledField
+
+	// If non-nil, this is invoked for abandoned promises. See Request.CancelPromise.
+	CancelPromise func(ResolvePromise)
+
+	// If non-nil, this is invoked for each resolved field. See Request.ObserveField.
+	ObserveField func(context.Context, *ObservedField) (context.Context, func(error))
+
 	// GroupedFieldSetCache is used to cache the results of collectFields.
 	GroupedFieldSetCache map[string]*GroupedFieldSet
 
@@ -97,14 +177,20 @@ func newExecutor(ctx context.Context, r *Request) (*executor, *Error) {
 	}
 
 	e := &executor{
-		Context:              ctx,
-		Schema:               r.Schema,
-		FragmentDefinitions:  map[string]*ast.FragmentDefinition{},
-		VariableValues:       coercedVariableValues,
-		Features:             r.Features,
-		Operation:            operation,
-		IdleHandler:          r.IdleHandler,
-		GroupedFieldSetCache: map[string]*GroupedFieldSet{},
+		Context:               ctx,
+		Schema:                r.Schema,
+		FragmentDefinitions:   map[string]*ast.FragmentDefinition{},
+		VariableValues:        coercedVariableValues,
+		Features:              r.Features,
+		Operation:             operation,
+		IdleHandler:           r.IdleHandler,
+		IdleHandlerStallLimit: r.IdleHandlerStallLimit,
+		PendingPromises:       map[ResolvePromise]*path{},
+		PendingReceives:       map[*pendingReceive]*path{},
+		NulledFields:          r.NulledFields,
+		CancelPromise:         r.CancelPromise,
+		ObserveField:          r.ObserveField,
+		GroupedFieldSetCache:  map[string]*GroupedFieldSet{},
 	}
 	e.CatchError = func(r future.Result[any]) future.Result[any] {
 		if r.IsErr() {
@@ -174,13 +260,14 @@ func (e *executor) subscribe(initialValue any) (any, *Error) {
 		return nil, err
 	}
 
-	resolveValue, resolveErr := fieldDef.Resolve(schema.FieldContext{
-		Context:     e.Context,
-		Schema:      e.Schema,
-		Object:      initialValue,
-		Features:    e.Features,
-		Arguments:   argumentValues,
-		IsSubscribe: true,
+	resolveValue, resolveErr := subscriptionType.FieldResolver(fieldName)(schema.FieldContext{
+		Context:          e.Context,
+		Schema:           e.Schema,
+		Object:           initialValue,
+		Features:         e.Features,
+		Arguments:        argumentValues,
+		ArgumentPresence: argumentPresence(field.Arguments, e.VariableValues),
+		IsSubscribe:      true,
 	})
 	if !isNil(resolveErr) {
 		return nil, &Error{
@@ -189,7 +276,7 @@ func (e *executor) subscribe(initialValue any) (any, *Error) {
 				Line:   field.Position().Line,
 				Column: field.Position().Column,
 			}},
-			Path:          []any{item.Key},
+			Path:          Path{KeySegment(item.Key)},
 			originalError: resolveErr,
 		}
 	}
@@ -224,16 +311,36 @@ func wait[T any](e *executor, f future.Future[T]) (T, error) {
 		return r
 	})
 	f.Poll()
+	stalls := 0
 	for !done {
-		if e.IdleHandler == nil {
+		resolvedPromiseCount := e.ResolvedPromiseCount
+		if len(e.PendingPromises) == 0 && len(e.PendingReceives) > 0 {
+			// Only list fields' channels can make progress, so wait for one of them.
+			e.awaitPendingReceive()
+		} else if e.IdleHandler == nil {
 			return result.Value, newError(nil, "No idle handler defined.")
+		} else {
+			e.IdleHandler()
 		}
-		e.IdleHandler()
 		f.Poll()
+		if e.ResolvedPromiseCount != resolvedPromiseCount {
+			stalls = 0
+		} else if stalls++; e.IdleHandlerStallLimit > 0 && stalls >= e.IdleHandlerStallLimit {
+			return result.Value, e.newAsyncResolverDeadlockError(stalls)
+		}
 	}
 	return result.Value, result.Error
 }
 
+func (e *executor) newAsyncResolverDeadlockError(stalls int) *Error {
+	paths := make([]string, 0, len(e.PendingPromises))
+	for _, p := range e.PendingPromises {
+		paths = append(paths, p.String())
+	}
+	sort.Strings(paths)
+	return newError(nil, "Async resolver deadlock: the idle handler returned %d consecutive times without resolving a promise. Pending fields: %s.", stalls, strings.Join(paths, ", "))
+}
+
 func (e *executor) executeSelections(selections []ast.Selection, objectType *schema.ObjectType, objectValue any, pathIn *path, forceSerial bool) future.Future[*OrderedMap] {
 	groupedFieldSet := e.collectFields(objectType, selections)
 
@@ -258,21 +365,47 @@ func (e *executor) executeSelections(selections []ast.Selection, objectType *sch
 		}
 
 		if fieldDef != nil {
-			itemPath := recyclablePath
-			if itemPath == nil {
-				itemPath = pathIn.WithStringComponent(responseKey)
-			} else {
-				itemPath.StringComponent = responseKey
+			fieldPath := fieldPath{
+				parent:      pathIn,
+				responseKey: responseKey,
+			}
+			if recyclablePath != nil {
+				recyclablePath.StringComponent = responseKey
+				fieldPath.path = recyclablePath
 				recyclablePath = nil
 			}
 
-			f := e.catchErrorIfNullable(fieldDef.Type, e.executeField(objectValue, fields, fieldDef, itemPath))
+			var f future.Future[any]
+			resolvedValue, listLimit, observeResult, err := e.resolveField(objectType, objectValue, fields, fieldDef, &fieldPath)
+			if err != nil {
+				f = future.Err[any](err)
+			} else if _, isPromise := resolvedValue.(ResolvePromise); !isPromise && isLeafType(fieldDef.Type) {
+				// This is the fast path taken by most fields: synchronously resolved scalars are
+				// completed without futures and don't need their paths unless there's an error.
+				if observeResult != nil {
+					observeResult(nil)
+				}
+				value, err := e.completeLeafValue(fieldDef.Type, fields, resolvedValue, &fieldPath)
+				if err == nil {
+					resultMap.Set(i, responseKey, value)
+					recyclablePath = fieldPath.path
+					continue
+				}
+				f = future.Err[any](err)
+			} else {
+				f = e.completeResolvedValue(fieldDef, fields, resolvedValue, listLimit, observeResult, &fieldPath)
+			}
+
+			itemPath := fieldPath.get()
+			f = e.catchErrorIfNullable(fieldDef.Type, f, itemPath)
 			if forceSerial || f.IsReady() {
 				responseValue, err := wait(e, f)
 				if err != nil {
 					return future.Err[*OrderedMap](err)
 				}
 				resultMap.Set(i, responseKey, responseValue)
+				// The field's result is ready, so nothing within it is waiting on a promise that
+				// could reference the path later.
 				recyclablePath = itemPath
 			} else {
 				i := i
@@ -285,6 +418,9 @@ func (e *executor) executeSelections(selections []ast.Selection, objectType *sch
 		}
 	}
 
+	if len(futures) == 0 {
+		return future.Ok(resultMap)
+	}
 	return future.MapOkValue(future.After(futures...), resultMap)
 }
 
@@ -305,70 +441,168 @@ func newFieldResolveError(fields []*ast.Field, err error, path *path) *Error {
 	return &Error{
 		Message:       err.Error(),
 		Locations:     locations,
-		Path:          path.Slice(),
+		Path:          path.Path(),
 		originalError: err,
 	}
 }
 
-func (e *executor) executeField(objectValue any, fields []*ast.Field, fieldDef *schema.FieldDefinition, path *path) future.Future[any] {
+// Invokes the field's resolver. The field's list limit is also returned, or -1 if it doesn't have
+// one. If the field is being observed, the returned function must be invoked with the field's
+// result.
+func (e *executor) resolveField(objectType *schema.ObjectType, objectValue any, fields []*ast.Field, fieldDef *schema.FieldDefinition, fieldPath *fieldPath) (any, int, func(error), error) {
 	field := fields[0]
 	argumentValues, coercionErr := coerceArgumentValues(field, fieldDef.Arguments, field.Arguments, e.VariableValues)
 	if coercionErr != nil {
-		return future.Err[any](coercionErr)
+		return nil, -1, nil, coercionErr
 	}
 	if err := e.Context.Err(); err != nil {
-		return future.Err[any](newFieldResolveError(fields, err, path))
-	}
-	resolvedValue, err := fieldDef.Resolve(schema.FieldContext{
-		Context:   e.Context,
-		Schema:    e.Schema,
-		Object:    objectValue,
-		Features:  e.Features,
-		Arguments: argumentValues,
-	})
+		return nil, -1, nil, newFieldResolveError(fields, err, fieldPath.get())
+	}
+	ctx := e.Context
+	var observeResult func(error)
+	if e.ObserveField != nil {
+		ctx, observeResult = e.ObserveField(ctx, &ObservedField{
+			Path:       fieldPath.get().Path(),
+			ObjectType: objectType,
+			Name:       field.Name.Name,
+			Definition: fieldDef,
+			Arguments:  argumentValues,
+		})
+	}
+	resolve := fieldDef.Resolve
+	if resolve == nil {
+		resolve = objectType.FieldResolver(field.Name.Name)
+	}
+	fieldContext := schema.FieldContext{
+		Context:          ctx,
+		Schema:           e.Schema,
+		Object:           objectValue,
+		Features:         e.Features,
+		Arguments:        argumentValues,
+		ArgumentPresence: argumentPresence(field.Arguments, e.VariableValues),
+	}
+	resolvedValue, err := resolve(fieldContext)
 	if !isNil(err) {
-		return future.Err[any](newFieldResolveError(fields, err, path))
+		if observeResult != nil {
+			observeResult(err)
+		}
+		return nil, -1, nil, newFieldResolveError(fields, err, fieldPath.get())
+	}
+	listLimit := -1
+	if fieldDef.ListLimit != nil {
+		listLimit = fieldDef.ListLimit(fieldContext)
 	}
+	return resolvedValue, listLimit, observeResult, nil
+}
+
+func (e *executor) completeResolvedValue(fieldDef *schema.FieldDefinition, fields []*ast.Field, resolvedValue any, listLimit int, observeResult func(error), fieldPath *fieldPath) future.Future[any] {
 	if f, ok := resolvedValue.(ResolvePromise); ok {
+		path := fieldPath.get()
+		e.PendingPromises[f] = path
 		return future.Then(future.New(func() (future.Result[any], bool) {
 			var result future.Result[any]
 			select {
 			case r := <-f:
+				delete(e.PendingPromises, f)
+				e.ResolvedPromiseCount++
 				if !isNil(r.Error) {
 					result.Error = r.Error
 				} else {
 					result.Value = r.Value
 				}
+				if observeResult != nil {
+					observeResult(result.Error)
+				}
 				return result, true
 			default:
 				return result, false
 			}
 		}), func(r future.Result[any]) future.Future[any] {
 			if r.IsOk() {
-				return e.completeValue(fieldDef.Type, fields, r.Value, path)
+				return e.completeValue(fieldDef.Type, fields, r.Value, path, listLimit)
 			}
 			return future.Err[any](newFieldResolveError(fields, r.Error, path))
 		})
 	}
-	return e.completeValue(fieldDef.Type, fields, resolvedValue, path)
+	if observeResult != nil {
+		observeResult(nil)
+	}
+	if isLeafType(fieldDef.Type) {
+		value, err := e.completeLeafValue(fieldDef.Type, fields, resolvedValue, fieldPath)
+		if err != nil {
+			return future.Err[any](err)
+		}
+		return future.Ok(value)
+	}
+	return e.completeValue(fieldDef.Type, fields, resolvedValue, fieldPath.get(), listLimit)
 }
 
-func (e *executor) catchErrorIfNullable(t schema.Type, f future.Future[any]) future.Future[any] {
+func (e *executor) catchErrorIfNullable(t schema.Type, f future.Future[any], path *path) future.Future[any] {
 	if schema.IsNonNullType(t) {
 		return f
 	}
+	if f.IsReady() {
+		// Even if the future is ready, siblings of the field that caused the error may still be
+		// waiting on promises.
+		if f.Result().IsErr() {
+			e.nullField(path)
+		}
+		return future.Map(f, e.CatchError)
+	}
+	if e.NulledFields != nil || e.CancelPromise != nil {
+		return future.Map(f, func(r future.Result[any]) future.Result[any] {
+			if r.IsErr() {
+				e.nullField(path)
+			}
+			return e.CatchError(r)
+		})
+	}
 	return future.Map(f, e.CatchError)
 }
 
-func (e *executor) completeValue(fieldType schema.Type, fields []*ast.Field, result any, pathIn *path) future.Future[any] {
+// Records that the field at the given path is being nulled due to an error and cancels any pending
+// promises within it. This must be invoked before the error is caught.
+func (e *executor) nullField(path *path) {
+	if e.NulledFields != nil {
+		*e.NulledFields = append(*e.NulledFields, NulledField{
+			Path:       path.Path(),
+			ErrorIndex: len(e.Errors),
+		})
+	}
+	e.cancelPendingPromises(path)
+}
+
+// Cancels the pending promises of all fields within the given path. If the path is nil, all pending
+// promises are canceled. The promises are forgotten even if there's no CancelPromise function, so a
+// path never has pending descendants once its error is caught and can safely be recycled. Pending
+// receives from list fields' channels are forgotten too.
+func (e *executor) cancelPendingPromises(ancestor *path) {
+	for p, path := range e.PendingPromises {
+		if ancestor == nil || path.HasAncestor(ancestor) {
+			delete(e.PendingPromises, p)
+			if e.CancelPromise != nil {
+				e.CancelPromise(p)
+			}
+		}
+	}
+	for r, path := range e.PendingReceives {
+		if ancestor == nil || path.HasAncestor(ancestor) {
+			delete(e.PendingReceives, r)
+		}
+	}
+}
+
+// Completes a field's value. If listLimit is non-negative and the value is a list, no more than that
+// many items are completed. See FieldDefinition.ListLimit.
+func (e *executor) completeValue(fieldType schema.Type, fields []*ast.Field, result any, pathIn *path, listLimit int) future.Future[any] {
 	if nonNullType, ok := fieldType.(*schema.NonNullType); ok {
-		fut := e.completeValue(nonNullType.Type, fields, result, pathIn)
+		fut := e.completeValue(nonNullType.Type, fields, result, pathIn, listLimit)
 		if fut.IsReady() {
 			r := fut.Result()
 			if r.IsOk() && r.Value == nil {
 				return future.Err[any](newErrorWithPath(fields[0], pathIn, "Null result for non-null field."))
 			}
-			return future.Ok[any](r.Value)
+			return fut
 		}
 		return future.Map(fut, func(r future.Result[any]) future.Result[any] {
 			if r.IsOk() && r.Value == nil {
@@ -385,56 +619,59 @@ func (e *executor) completeValue(fieldType schema.Type, fields []*ast.Field, res
 	switch fieldType := fieldType.(type) {
 	case *schema.ListType:
 		result := reflect.ValueOf(result)
-		if result.Kind() != reflect.Slice {
+		if (result.Kind() == reflect.Func || result.Kind() == reflect.Chan) && result.IsNil() {
+			return future.Ok[any](nil)
+		}
+		nextItem, length, ok := e.listItems(result, pathIn)
+		if !ok {
 			return future.Err[any](newErrorWithPath(fields[0], pathIn, "Result is not a list."))
 		}
-		innerType := fieldType.Type
-		completedResult := make([]future.Future[any], result.Len())
-		var recyclablePath *path
-		for i := range completedResult {
-			itemPath := recyclablePath
-			if itemPath == nil {
-				itemPath = pathIn.WithIntComponent(i)
-			} else {
-				itemPath.IntComponent = i
-				recyclablePath = nil
-			}
-			fut := e.catchErrorIfNullable(innerType, e.completeValue(innerType, fields, result.Index(i).Interface(), itemPath))
-			if fut.IsReady() {
-				recyclablePath = itemPath
-			}
-			completedResult[i] = fut
+		if listLimit >= 0 && length > listLimit {
+			length = listLimit
 		}
-		return future.MapOkToAny(future.Join(completedResult...))
-	case *schema.ScalarType:
-		coerced, err := fieldType.CoerceResult(result)
-		if err != nil {
-			return future.Err[any](newErrorWithPath(fields[0], pathIn, "Unexpected result: %v", err))
+		l := &listCompletion{
+			executor:  e,
+			innerType: fieldType.Type,
+			fields:    fields,
+			path:      pathIn,
+			nextItem:  nextItem,
+			limit:     listLimit,
+			items:     make([]future.Future[any], 0, length),
 		}
-		return future.Ok(coerced)
-	case *schema.EnumType:
-		coerced, err := fieldType.CoerceResult(result)
+		return l.complete()
+	case *schema.ScalarType, *schema.EnumType:
+		value, err := e.completeLeafValue(fieldType, fields, result, &fieldPath{path: pathIn})
 		if err != nil {
-			return future.Err[any](newErrorWithPath(fields[0], pathIn, "Unexpected result: %v", err))
+			return future.Err[any](err)
 		}
-		return future.Ok[any](coerced)
+		return future.Ok(value)
 	case *schema.ObjectType, *schema.InterfaceType, *schema.UnionType:
 		var objectType *schema.ObjectType
 		switch fieldType := fieldType.(type) {
 		case *schema.ObjectType:
 			objectType = fieldType
 		case *schema.InterfaceType:
-			for _, t := range e.Schema.InterfaceImplementations(fieldType.Name) {
-				if t.IsTypeOf(result) {
-					objectType = t
-					break
+			if fieldType.ResolveType != nil {
+				objectType = e.resolvedType(fieldType.ResolveType(e.Context, result), fieldType)
+			}
+			if objectType == nil {
+				for _, t := range e.Schema.InterfaceImplementations(fieldType.Name) {
+					if t.IsTypeOf != nil && t.IsTypeOf(result) {
+						objectType = t
+						break
+					}
 				}
 			}
 		case *schema.UnionType:
-			for _, t := range fieldType.MemberTypes {
-				if t.IsTypeOf(result) {
-					objectType = t
-					break
+			if fieldType.ResolveType != nil {
+				objectType = e.resolvedType(fieldType.ResolveType(e.Context, result), fieldType)
+			}
+			if objectType == nil {
+				for _, t := range fieldType.MemberTypes {
+					if t.IsTypeOf != nil && t.IsTypeOf(result) {
+						objectType = t
+						break
+					}
 				}
 			}
 		}
@@ -446,6 +683,73 @@ func (e *executor) completeValue(fieldType schema.Type, fields []*ast.Field, res
 	panic(fmt.Sprintf("unexpected field type: %T", fieldType))
 }
 
+// Returns true if values of the given type are completed by completeLeafValue.
+func isLeafType(t schema.Type) bool {
+	switch schema.NullableType(t).(type) {
+	case *schema.ScalarType, *schema.EnumType:
+		return true
+	}
+	return false
+}
+
+// Completes a scalar or enum value. Unlike completeValue, this doesn't use futures and only
+// allocates the path if there's an error.
+func (e *executor) completeLeafValue(fieldType schema.Type, fields []*ast.Field, result any, fieldPath *fieldPath) (any, *Error) {
+	if nonNullType, ok := fieldType.(*schema.NonNullType); ok {
+		value, err := e.completeLeafValue(nonNullType.Type, fields, result, fieldPath)
+		if err == nil && value == nil {
+			return nil, newErrorWithPath(fields[0], fieldPath.get(), "Null result for non-null field.")
+		}
+		return value, err
+	}
+
+	if isNil(result) {
+		return nil, nil
+	}
+
+	var coerced any
+	var err error
+	switch fieldType := fieldType.(type) {
+	case *schema.ScalarType:
+		coerced, err = fieldType.CoerceResult(result)
+	case *schema.EnumType:
+		coerced, err = fieldType.CoerceResult(result)
+	default:
+		panic(fmt.Sprintf("unexpected leaf type: %T", fieldType))
+	}
+	if err != nil {
+		return nil, newErrorWithPath(fields[0], fieldPath.get(), "Unexpected result: %v", err)
+	}
+	return coerced, nil
+}
+
+// Returns the schema's object type with the same name as the one returned by a ResolveType
+// function, or nil if there is no such type or it isn't a possible type of the abstract type.
+func (e *executor) resolvedType(resolved *schema.ObjectType, abstractType schema.NamedType) *schema.ObjectType {
+	if resolved == nil {
+		return nil
+	}
+	objectType, ok := e.Schema.NamedTypes()[resolved.Name].(*schema.ObjectType)
+	if !ok {
+		return nil
+	}
+	switch abstractType := abstractType.(type) {
+	case *schema.InterfaceType:
+		for _, iface := range objectType.ImplementedInterfaces {
+			if iface == abstractType {
+				return objectType
+			}
+		}
+	case *schema.UnionType:
+		for _, member := range abstractType.MemberTypes {
+			if member == objectType {
+				return objectType
+			}
+		}
+	}
+	return nil
+}
+
 func mergeSelectionSets(fields []*ast.Field) []ast.Selection {
 	// In the common case, there's nothing to merge.
 	if len(fields) == 1 && fields[0].SelectionSet != nil {
@@ -466,23 +770,25 @@ func (e *executor) collectFields(objectType *schema.ObjectType, selections []ast
 	// collectFields can be called many times with the same inputs throughout a query's execution,
 	// so we memoize the return value.
 
-	objectNameLen := len(objectType.Name)
-	cacheKeyBytes := make([]byte, objectNameLen+8*len(selections))
-	copy(cacheKeyBytes, objectType.Name)
-	for i, sel := range selections {
+	// The key is built in a stack buffer when possible, and the lookup doesn't allocate a string,
+	// so cache hits are free of allocations.
+	var cacheKeyBuffer [128]byte
+	cacheKeyBytes := append(cacheKeyBuffer[:0], objectType.Name...)
+	for _, sel := range selections {
 		pos := sel.Position()
-		binary.LittleEndian.PutUint32(cacheKeyBytes[objectNameLen+i*8:], uint32(pos.Line))
-		binary.LittleEndian.PutUint32(cacheKeyBytes[objectNameLen+i*8+4:], uint32(pos.Column))
+		n := len(cacheKeyBytes)
+		cacheKeyBytes = append(cacheKeyBytes, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(cacheKeyBytes[n:], uint32(pos.Line))
+		binary.LittleEndian.PutUint32(cacheKeyBytes[n+4:], uint32(pos.Column))
 	}
-	cacheKey := string(cacheKeyBytes)
 
-	if hit, ok := e.GroupedFieldSetCache[cacheKey]; ok {
+	if hit, ok := e.GroupedFieldSetCache[string(cacheKeyBytes)]; ok {
 		return hit
 	}
 
 	groupedFieldSet := NewGroupedFieldSetWithCapacity(len(selections))
 	e.collectFieldsImpl(objectType, selections, nil, groupedFieldSet)
-	e.GroupedFieldSetCache[cacheKey] = groupedFieldSet
+	e.GroupedFieldSetCache[string(cacheKeyBytes)] = groupedFieldSet
 	return groupedFieldSet
 }
 
@@ -620,3 +926,21 @@ func coerceArgumentValues(node ast.Node, argumentDefinitions map[string]*schema.
 	ret, err := validator.CoerceArgumentValues(node, argumentDefinitions, arguments, variableValues)
 	return ret, newErrorWithValidatorError(err)
 }
+
+// Returns the names of the arguments that are explicitly given. Arguments given as variables are
+// only present if the variables have values.
+func argumentPresence(arguments []*ast.Argument, variableValues map[string]any) map[string]bool {
+	if len(arguments) == 0 {
+		return nil
+	}
+	ret := make(map[string]bool, len(arguments))
+	for _, arg := range arguments {
+		if variable, ok := arg.Value.(*ast.Variable); ok {
+			if _, ok := variableValues[variable.Name.Name]; !ok {
+				continue
+			}
+		}
+		ret[arg.Name.Name] = true
+	}
+	return ret
+}
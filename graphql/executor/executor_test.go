@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -295,16 +296,21 @@ func TestExecuteRequest(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	t.Run("IntrospectionQuery", func(t *testing.T) {
-		parsed, parseErrs := parser.ParseDocument(introspection.Query)
-		require.Empty(t, parseErrs)
-		require.Empty(t, validator.ValidateDocument(parsed, s, nil))
-		_, errs := ExecuteRequest(context.Background(), &Request{
-			Document: parsed,
-			Schema:   s,
+	for name, query := range map[string][]byte{
+		"IntrospectionQuery":     introspection.Query,
+		"FullIntrospectionQuery": introspection.FullQuery,
+	} {
+		t.Run(name, func(t *testing.T) {
+			parsed, parseErrs := parser.ParseDocument(query)
+			require.Empty(t, parseErrs)
+			require.Empty(t, validator.ValidateDocument(parsed, s, nil))
+			_, errs := ExecuteRequest(context.Background(), &Request{
+				Document: parsed,
+				Schema:   s,
+			})
+			require.Empty(t, errs)
 		})
-		require.Empty(t, errs)
-	})
+	}
 
 	for name, tc := range map[string]struct {
 		Document             string
@@ -339,7 +345,7 @@ func TestExecuteRequest(t *testing.T) {
 			ExpectedErrors: []*Error{
 				{
 					Locations: []Location{{1, 9}},
-					Path:      []interface{}{"badResolveValue"},
+					Path:      NewPath("badResolveValue"),
 				},
 			},
 		},
@@ -349,7 +355,7 @@ func TestExecuteRequest(t *testing.T) {
 			ExpectedErrors: []*Error{
 				{
 					Locations: []Location{{1, 9}},
-					Path:      []interface{}{"l", 1},
+					Path:      NewPath("l", 1),
 				},
 			},
 		},
@@ -372,7 +378,7 @@ func TestExecuteRequest(t *testing.T) {
 			ExpectedErrors: []*Error{
 				{
 					Locations: []Location{{1, 30}},
-					Path:      []interface{}{"objectsWithAsyncStringError", 1, "asyncString"},
+					Path:      NewPath("objectsWithAsyncStringError", 1, "asyncString"),
 				},
 			},
 			ExpectedIdlePromises: []int{3, 2},
@@ -428,7 +434,7 @@ func TestExecuteRequest(t *testing.T) {
 			ExpectedErrors: []*Error{
 				{
 					Locations: []Location{{1, 2}, {1, 8}},
-					Path:      []interface{}{"error"},
+					Path:      NewPath("error"),
 				},
 			},
 		},
@@ -438,7 +444,7 @@ func TestExecuteRequest(t *testing.T) {
 			ExpectedErrors: []*Error{
 				{
 					Locations: []Location{{1, 9}},
-					Path:      []interface{}{"object", "nonNullError"},
+					Path:      NewPath("object", "nonNullError"),
 				},
 			},
 		},
@@ -448,7 +454,7 @@ func TestExecuteRequest(t *testing.T) {
 			ExpectedErrors: []*Error{
 				{
 					Locations: []Location{{1, 52}},
-					Path:      []interface{}{"object", "object", "object", "object", "objs", 1, "n"},
+					Path:      NewPath("object", "object", "object", "object", "objs", 1, "n"),
 				},
 			},
 		},
@@ -458,7 +464,7 @@ func TestExecuteRequest(t *testing.T) {
 			ExpectedErrors: []*Error{
 				{
 					Locations: []Location{{1, 2}},
-					Path:      []interface{}{"l", 1},
+					Path:      NewPath("l", 1),
 				},
 			},
 		},
@@ -513,6 +519,855 @@ func TestExecuteRequest(t *testing.T) {
 	}
 }
 
+func TestNulledFields(t *testing.T) {
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query:           objectType,
+		AdditionalTypes: []schema.NamedType{dogType, catType},
+	})
+	require.NoError(t, err)
+
+	doc, parseErrs := parser.ParseDocument([]byte(`{intOne objs: objectsWithError{n: intOneOrError} object{nonNullError}}`))
+	require.Empty(t, parseErrs)
+	require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+	var nulledFields []NulledField
+	data, errs := ExecuteRequest(context.Background(), &Request{
+		Document:     doc,
+		Schema:       s,
+		NulledFields: &nulledFields,
+	})
+	serializedData, err := json.Marshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, `{"intOne":1,"objs":[{"n":1},{"n":null},{"n":1}],"object":null}`, string(serializedData))
+	require.Len(t, errs, 2)
+	assert.Equal(t, []NulledField{
+		{Path: NewPath("objs", 1, "n"), ErrorIndex: 0},
+		{Path: NewPath("object"), ErrorIndex: 1},
+	}, nulledFields)
+	assert.Equal(t, NewPath("object", "nonNullError"), errs[1].Path)
+}
+
+func TestIdleHandlerStallLimit(t *testing.T) {
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"neverResolved": {
+					Type: schema.IntType,
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return make(ResolvePromise, 1), nil
+					},
+				},
+				"resolved": {
+					Type: schema.IntType,
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return 1, nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	doc, parseErrs := parser.ParseDocument([]byte(`{resolved b: neverResolved a: neverResolved}`))
+	require.Empty(t, parseErrs)
+	require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+	idleHandlerCalls := 0
+	data, errs := ExecuteRequest(context.Background(), &Request{
+		Document: doc,
+		Schema:   s,
+		IdleHandler: func() {
+			idleHandlerCalls++
+		},
+		IdleHandlerStallLimit: 3,
+	})
+	assert.Nil(t, data)
+	assert.Equal(t, 3, idleHandlerCalls)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "Async resolver deadlock: the idle handler returned 3 consecutive times without resolving a promise. Pending fields: a, b.", errs[0].Message)
+}
+
+func TestCancelPromise(t *testing.T) {
+	var promises map[string]ResolvePromise
+
+	objectType := &schema.ObjectType{
+		Name: "Object",
+	}
+	objectType.Fields = map[string]*schema.FieldDefinition{
+		"async": {
+			Type: schema.IntType,
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				p := make(ResolvePromise, 1)
+				promises[ctx.Arguments["name"].(string)] = p
+				return p, nil
+			},
+			Arguments: map[string]*schema.InputValueDefinition{
+				"name": {
+					Type: schema.NewNonNullType(schema.StringType),
+				},
+			},
+		},
+		"nonNullAsyncError": {
+			Type: schema.NewNonNullType(schema.IntType),
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				p := make(ResolvePromise, 1)
+				p <- ResolveResult{
+					Error: fmt.Errorf("error"),
+				}
+				return p, nil
+			},
+		},
+		"nonNullSyncError": {
+			Type: schema.NewNonNullType(schema.IntType),
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return nil, fmt.Errorf("error")
+			},
+		},
+		"nonNullSyncErrorForSecondItem": {
+			Type: schema.NewNonNullType(schema.IntType),
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				if ctx.Object == 1 {
+					return nil, fmt.Errorf("error")
+				}
+				return 0, nil
+			},
+		},
+		"object": {
+			Type: objectType,
+			Resolve: func(schema.FieldContext) (interface{}, error) {
+				return struct{}{}, nil
+			},
+		},
+	}
+	objectType.Fields["list"] = &schema.FieldDefinition{
+		Type: schema.NewListType(schema.NewNonNullType(objectType)),
+		Resolve: func(schema.FieldContext) (interface{}, error) {
+			return []int{0, 1}, nil
+		},
+	}
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: objectType,
+	})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Document        string
+		ExpectedData    string
+		ExpectedCancels []string
+	}{
+		"SyncErrorWithAsyncSibling": {
+			Document:        `{a: async(name: "a") object{b: async(name: "b") nonNullSyncError}}`,
+			ExpectedData:    `{"a":1,"object":null}`,
+			ExpectedCancels: []string{"b"},
+		},
+		"NulledAncestor": {
+			Document:        `{a: async(name: "a") object{b: async(name: "b") nonNullAsyncError}}`,
+			ExpectedData:    `{"a":1,"object":null}`,
+			ExpectedCancels: []string{"b"},
+		},
+		"NonNullListItemError": {
+			Document:        `{a: async(name: "a") list{x: nonNullSyncErrorForSecondItem b: async(name: "b")}}`,
+			ExpectedData:    `{"a":1,"list":null}`,
+			ExpectedCancels: []string{"b"},
+		},
+		"NulledRoot": {
+			Document:        `{a: async(name: "a") nonNullAsyncError}`,
+			ExpectedData:    `null`,
+			ExpectedCancels: []string{"a"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			promises = map[string]ResolvePromise{}
+
+			doc, parseErrs := parser.ParseDocument([]byte(tc.Document))
+			require.Empty(t, parseErrs)
+			require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+			var canceled []string
+			data, errs := ExecuteRequest(context.Background(), &Request{
+				Document: doc,
+				Schema:   s,
+				IdleHandler: func() {
+					// Abandoned promises should be canceled immediately, not once execution completes.
+					assert.ElementsMatch(t, tc.ExpectedCancels, canceled)
+					if p, ok := promises["a"]; ok {
+						delete(promises, "a")
+						p <- ResolveResult{
+							Value: 1,
+						}
+					}
+				},
+				CancelPromise: func(p ResolvePromise) {
+					for name, other := range promises {
+						if p == other {
+							canceled = append(canceled, name)
+						}
+					}
+				},
+			})
+			serializedData, err := json.Marshal(data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.ExpectedData, string(serializedData))
+			assert.Len(t, errs, 1)
+			assert.ElementsMatch(t, tc.ExpectedCancels, canceled)
+		})
+	}
+}
+
+func TestObserveField(t *testing.T) {
+	type ctxKey struct{}
+
+	objectType := &schema.ObjectType{
+		Name: "Object",
+	}
+	objectType.Fields = map[string]*schema.FieldDefinition{
+		"value": {
+			Type: schema.StringType,
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return ctx.Context.Value(ctxKey{}), nil
+			},
+		},
+		"async": {
+			Type: schema.IntType,
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				p := make(ResolvePromise, 1)
+				p <- ResolveResult{
+					Error: fmt.Errorf("error"),
+				}
+				return p, nil
+			},
+		},
+		"objects": {
+			Type: schema.NewListType(objectType),
+			Resolve: func(schema.FieldContext) (interface{}, error) {
+				return []struct{}{{}}, nil
+			},
+		},
+	}
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: objectType,
+	})
+	require.NoError(t, err)
+
+	doc, parseErrs := parser.ParseDocument([]byte(`{objects{v: value} async}`))
+	require.Empty(t, parseErrs)
+	require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+	var observed []string
+	data, errs := ExecuteRequest(context.Background(), &Request{
+		Document:    doc,
+		Schema:      s,
+		IdleHandler: func() {},
+		ObserveField: func(ctx context.Context, field *ObservedField) (context.Context, func(error)) {
+			observed = append(observed, fmt.Sprintf("start %v %v.%v", field.Path, field.ObjectType.Name, field.Name))
+			return context.WithValue(ctx, ctxKey{}, "observed"), func(err error) {
+				observed = append(observed, fmt.Sprintf("end %v %v", field.Path, err))
+			}
+		},
+	})
+	require.Len(t, errs, 1)
+	serializedData, err := json.Marshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, `{"objects":[{"v":"observed"}],"async":null}`, string(serializedData))
+	assert.Equal(t, []string{
+		"start objects Object.objects",
+		"end objects <nil>",
+		"start objects.0.v Object.value",
+		"end objects.0.v <nil>",
+		"start async Object.async",
+		"end async error",
+	}, observed)
+}
+
+func TestArgumentPresence(t *testing.T) {
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"present": {
+					Type: schema.NewListType(schema.StringType),
+					Arguments: map[string]*schema.InputValueDefinition{
+						"a": {
+							Type:         schema.IntType,
+							DefaultValue: 1,
+						},
+						"b": {
+							Type: schema.IntType,
+						},
+						"c": {
+							Type: schema.IntType,
+						},
+					},
+					Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+						ret := []string{}
+						for _, name := range []string{"a", "b", "c"} {
+							if ctx.ArgumentPresence[name] {
+								ret = append(ret, name)
+							}
+						}
+						return ret, nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for src, expected := range map[string]string{
+		`{present}`:                                  `{"present":[]}`,
+		`{present(a: 1, b: null)}`:                   `{"present":["a","b"]}`,
+		`query ($c: Int) {present(c: $c)}`:           `{"present":[]}`,
+		`query ($c: Int = null) {present(c: $c)}`:    `{"present":["c"]}`,
+		`query ($a: Int) {present(a: $a, b: $a)}`:    `{"present":[]}`,
+		`query ($a: Int = 2) {present(a: $a, c: 3)}`: `{"present":["a","c"]}`,
+	} {
+		doc, parseErrs := parser.ParseDocument([]byte(src))
+		require.Empty(t, parseErrs)
+		require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+		data, errs := ExecuteRequest(context.Background(), &Request{
+			Document: doc,
+			Schema:   s,
+		})
+		require.Empty(t, errs)
+		serializedData, err := json.Marshal(data)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(serializedData), src)
+	}
+}
+
+func TestLeafFieldErrorPaths(t *testing.T) {
+	objectType := &schema.ObjectType{
+		Name: "Object",
+	}
+	objectType.Fields = map[string]*schema.FieldDefinition{
+		"ok": {
+			Type: schema.IntType,
+			Resolve: func(schema.FieldContext) (interface{}, error) {
+				return 1, nil
+			},
+		},
+		"error": {
+			Type: schema.IntType,
+			Resolve: func(schema.FieldContext) (interface{}, error) {
+				return nil, fmt.Errorf("error")
+			},
+		},
+		"invalid": {
+			Type: schema.IntType,
+			Resolve: func(schema.FieldContext) (interface{}, error) {
+				return "foo", nil
+			},
+		},
+		"null": {
+			Type: schema.NewNonNullType(schema.IntType),
+			Resolve: func(schema.FieldContext) (interface{}, error) {
+				return nil, nil
+			},
+		},
+		"objects": {
+			Type: schema.NewListType(objectType),
+			Resolve: func(schema.FieldContext) (interface{}, error) {
+				return []struct{}{{}, {}}, nil
+			},
+		},
+	}
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: objectType,
+	})
+	require.NoError(t, err)
+
+	doc, parseErrs := parser.ParseDocument([]byte(`{ok error objects{ok invalid ok2: ok} a: objects{null}}`))
+	require.Empty(t, parseErrs)
+	require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+	data, errs := ExecuteRequest(context.Background(), &Request{
+		Document: doc,
+		Schema:   s,
+	})
+	serializedData, err := json.Marshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, `{"ok":1,"error":null,"objects":[{"ok":1,"invalid":null,"ok2":1},{"ok":1,"invalid":null,"ok2":1}],"a":[null,null]}`, string(serializedData))
+
+	var paths []string
+	for _, err := range errs {
+		paths = append(paths, err.Path.String())
+	}
+	assert.Equal(t, []string{
+		"error",
+		"objects.0.invalid",
+		"objects.1.invalid",
+		"a.0.null",
+		"a.1.null",
+	}, paths)
+}
+
+func TestListEarlyTermination(t *testing.T) {
+	completions := 0
+
+	itemType := &schema.ObjectType{
+		Name: "Item",
+		Fields: map[string]*schema.FieldDefinition{
+			"n": {
+				Type: schema.NewNonNullType(schema.IntType),
+				Resolve: func(schema.FieldContext) (interface{}, error) {
+					completions++
+					return nil, fmt.Errorf("error")
+				},
+			},
+		},
+	}
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"items": {
+					Type: schema.NewListType(schema.NewNonNullType(itemType)),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return make([]struct{}, 10000), nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	doc, parseErrs := parser.ParseDocument([]byte(`{items{n}}`))
+	require.Empty(t, parseErrs)
+	require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+	data, errs := ExecuteRequest(context.Background(), &Request{
+		Document: doc,
+		Schema:   s,
+	})
+	serializedData, err := json.Marshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, `{"items":null}`, string(serializedData))
+	require.Len(t, errs, 1)
+	assert.Equal(t, NewPath("items", 0, "n"), errs[0].Path)
+	assert.Equal(t, 1, completions)
+}
+
+func TestLazyLists(t *testing.T) {
+	consumed := 0
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"iterator": {
+					Type: schema.NewListType(schema.IntType),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						i := 0
+						return func() (any, bool) {
+							i++
+							return i, i <= 3
+						}, nil
+					},
+				},
+				"typedIterator": {
+					Type: schema.NewListType(schema.StringType),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						items := []string{"a", "b"}
+						return func() (string, bool) {
+							if len(items) == 0 {
+								return "", false
+							}
+							item := items[0]
+							items = items[1:]
+							return item, true
+						}, nil
+					},
+				},
+				"channel": {
+					Type: schema.NewListType(schema.IntType),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						ch := make(chan int, 2)
+						ch <- 1
+						ch <- 2
+						close(ch)
+						return ch, nil
+					},
+				},
+				"nilIterator": {
+					Type: schema.NewListType(schema.IntType),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return (func() (int, bool))(nil), nil
+					},
+				},
+				"failingIterator": {
+					Type: schema.NewListType(schema.NewNonNullType(schema.IntType)),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return func() (any, bool) {
+							consumed++
+							if consumed == 2 {
+								return nil, true
+							}
+							return consumed, true
+						}, nil
+					},
+				},
+				"notIterator": {
+					Type: schema.NewListType(schema.IntType),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return func(int) (int, bool) {
+							return 0, false
+						}, nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	doc, parseErrs := parser.ParseDocument([]byte(`{iterator typedIterator channel nilIterator failingIterator notIterator}`))
+	require.Empty(t, parseErrs)
+	require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+	data, errs := ExecuteRequest(context.Background(), &Request{
+		Document: doc,
+		Schema:   s,
+	})
+	serializedData, err := json.Marshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, `{"iterator":[1,2,3],"typedIterator":["a","b"],"channel":[1,2],"nilIterator":null,"failingIterator":null,"notIterator":null}`, string(serializedData))
+	require.Len(t, errs, 2)
+	assert.Equal(t, NewPath("failingIterator", 1), errs[0].Path)
+	assert.Equal(t, "Result is not a list.", errs[1].Message)
+
+	// The iterator shouldn't be consumed past the item that nulled the list.
+	assert.Equal(t, 2, consumed)
+}
+
+func TestLazyLists_ContextCancelation(t *testing.T) {
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"channel": {
+					Type: schema.NewListType(schema.IntType),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return make(chan int), nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	doc, parseErrs := parser.ParseDocument([]byte(`{channel}`))
+	require.Empty(t, parseErrs)
+	require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	data, errs := ExecuteRequest(ctx, &Request{
+		Document: doc,
+		Schema:   s,
+	})
+	serializedData, err := json.Marshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, `{"channel":null}`, string(serializedData))
+	require.Len(t, errs, 1)
+	assert.Equal(t, NewPath("channel"), errs[0].Path)
+}
+
+func TestLazyLists_ListLimit(t *testing.T) {
+	consumed := 0
+	var ch chan int
+
+	first := func(ctx schema.FieldContext) int {
+		return ctx.Arguments["first"].(int)
+	}
+	arguments := map[string]*schema.InputValueDefinition{
+		"first": {
+			Type: schema.NewNonNullType(schema.IntType),
+		},
+	}
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"iterator": {
+					Type:      schema.NewListType(schema.IntType),
+					Arguments: arguments,
+					ListLimit: first,
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return func() (any, bool) {
+							consumed++
+							return consumed, true
+						}, nil
+					},
+				},
+				"channel": {
+					Type:      schema.NewNonNullType(schema.NewListType(schema.IntType)),
+					Arguments: arguments,
+					ListLimit: first,
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						ch = make(chan int, 5)
+						for i := 1; i <= 5; i++ {
+							ch <- i
+						}
+						return ch, nil
+					},
+				},
+				"slice": {
+					Type:      schema.NewListType(schema.NewListType(schema.IntType)),
+					Arguments: arguments,
+					ListLimit: first,
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return [][]int{{1, 2, 3}, {4, 5}, {6}}, nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	doc, parseErrs := parser.ParseDocument([]byte(`{iterator(first: 3) channel(first: 2) slice(first: 2) empty: slice(first: 0)}`))
+	require.Empty(t, parseErrs)
+	require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+	data, errs := ExecuteRequest(context.Background(), &Request{
+		Document: doc,
+		Schema:   s,
+	})
+	require.Empty(t, errs)
+	serializedData, err := json.Marshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, `{"iterator":[1,2,3],"channel":[1,2],"slice":[[1,2,3],[4,5]],"empty":[]}`, string(serializedData))
+
+	// Nothing should be consumed past the limit.
+	assert.Equal(t, 3, consumed)
+	assert.Len(t, ch, 3)
+}
+
+func TestLazyLists_PendingChannel(t *testing.T) {
+	var ch chan int
+	var promise ResolvePromise
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"channel": {
+					Type: schema.NewListType(schema.IntType),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						ch = make(chan int)
+						return ch, nil
+					},
+				},
+				"async": {
+					Type: schema.IntType,
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						promise = make(ResolvePromise, 1)
+						return promise, nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	doc, parseErrs := parser.ParseDocument([]byte(`{channel async}`))
+	require.Empty(t, parseErrs)
+	require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+	// Waiting on the channel must not prevent the executor from resolving the other field. The
+	// channel only receives items once the other field has been resolved.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	idleHandlerCalls := 0
+	data, errs := ExecuteRequest(ctx, &Request{
+		Document: doc,
+		Schema:   s,
+		IdleHandler: func() {
+			idleHandlerCalls++
+			promise <- ResolveResult{
+				Value: 1,
+			}
+			go func() {
+				ch <- 1
+				ch <- 2
+				close(ch)
+			}()
+		},
+	})
+	require.Empty(t, errs)
+	serializedData, err := json.Marshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, `{"channel":[1,2],"async":1}`, string(serializedData))
+	assert.Equal(t, 1, idleHandlerCalls)
+}
+
+func TestInterfaceDefaultResolvers(t *testing.T) {
+	nodeType := &schema.InterfaceType{
+		Name: "Node",
+		Fields: map[string]*schema.FieldDefinition{
+			"id": {
+				Type: schema.NewNonNullType(schema.IDType),
+				Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+					return fmt.Sprintf("%v", ctx.Object), nil
+				},
+			},
+		},
+	}
+
+	newNodeObjectType := func(name string, fields map[string]*schema.FieldDefinition) *schema.ObjectType {
+		return &schema.ObjectType{
+			Name:                  name,
+			Fields:                fields,
+			ImplementedInterfaces: []*schema.InterfaceType{nodeType},
+			IsTypeOf: func(v interface{}) bool {
+				return strings.HasPrefix(v.(string), name)
+			},
+		}
+	}
+
+	userType := newNodeObjectType("User", map[string]*schema.FieldDefinition{
+		"id": {
+			Type: schema.NewNonNullType(schema.IDType),
+		},
+	})
+	postType := newNodeObjectType("Post", map[string]*schema.FieldDefinition{
+		"id": {
+			Type: schema.NewNonNullType(schema.IDType),
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return "overridden", nil
+			},
+		},
+	})
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"nodes": {
+					Type: schema.NewListType(nodeType),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return []string{"User1", "Post1"}, nil
+					},
+				},
+				"user": {
+					Type: userType,
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return "User2", nil
+					},
+				},
+			},
+		},
+		AdditionalTypes: []schema.NamedType{userType, postType},
+	})
+	require.NoError(t, err)
+
+	doc, parseErrs := parser.ParseDocument([]byte(`{nodes{id} user{id}}`))
+	require.Empty(t, parseErrs)
+	require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+	data, errs := ExecuteRequest(context.Background(), &Request{
+		Document: doc,
+		Schema:   s,
+	})
+	require.Empty(t, errs)
+	serializedData, err := json.Marshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, `{"nodes":[{"id":"User1"},{"id":"overridden"}],"user":{"id":"User2"}}`, string(serializedData))
+}
+
+func TestResolveType(t *testing.T) {
+	type record struct {
+		Kind string
+		Id   string
+	}
+
+	nodeType := &schema.InterfaceType{
+		Name: "Node",
+		Fields: map[string]*schema.FieldDefinition{
+			"id": {
+				Type: schema.NewNonNullType(schema.IDType),
+			},
+		},
+	}
+
+	newObjectType := func(name string, interfaces ...*schema.InterfaceType) *schema.ObjectType {
+		return &schema.ObjectType{
+			Name: name,
+			Fields: map[string]*schema.FieldDefinition{
+				"id": {
+					Type: schema.NewNonNullType(schema.IDType),
+					Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+						return name + ctx.Object.(record).Id, nil
+					},
+				},
+			},
+			ImplementedInterfaces: interfaces,
+		}
+	}
+	userType := newObjectType("User", nodeType)
+	postType := newObjectType("Post", nodeType)
+	otherType := newObjectType("Other")
+
+	byKind := map[string]*schema.ObjectType{
+		"user":  userType,
+		"post":  postType,
+		"other": otherType,
+	}
+	resolveType := func(ctx context.Context, v interface{}) *schema.ObjectType {
+		return byKind[v.(record).Kind]
+	}
+	nodeType.ResolveType = resolveType
+
+	unionType := &schema.UnionType{
+		Name:        "Union",
+		MemberTypes: []*schema.ObjectType{userType, postType},
+		ResolveType: resolveType,
+	}
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"nodes": {
+					Type: schema.NewListType(nodeType),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return []record{{"user", "1"}, {"post", "2"}, {"other", "3"}}, nil
+					},
+				},
+				"union": {
+					Type: schema.NewListType(unionType),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return []record{{"post", "4"}, {"user", "5"}}, nil
+					},
+				},
+				"other": {
+					Type: otherType,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	doc, parseErrs := parser.ParseDocument([]byte(`{nodes{id} union{... on Node{id}}}`))
+	require.Empty(t, parseErrs)
+	require.Empty(t, validator.ValidateDocument(doc, s, nil))
+
+	data, errs := ExecuteRequest(context.Background(), &Request{
+		Document: doc,
+		Schema:   s,
+	})
+	require.Len(t, errs, 1)
+	assert.Equal(t, NewPath("nodes", 2), errs[0].Path)
+	serializedData, err := json.Marshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, `{"nodes":[{"id":"User1"},{"id":"Post2"},null],"union":[{"id":"Post4"},{"id":"User5"}]}`, string(serializedData))
+}
+
 func TestGetOperation(t *testing.T) {
 	doc, errs := parser.ParseDocument([]byte(`{x} {x} query q {x} mutation m {x} mutation m {x}`))
 	assert.Empty(t, errs)
@@ -600,6 +1455,84 @@ func BenchmarkExecuteRequest(b *testing.B) {
 	}
 }
 
+func BenchmarkExecuteRequest_ScalarSelections(b *testing.B) {
+	type node struct {
+		Id     string
+		Name   string
+		Status string
+	}
+
+	nodeType := &schema.ObjectType{
+		Name: "Node",
+		Fields: map[string]*schema.FieldDefinition{
+			"id": {
+				Type: schema.NewNonNullType(schema.IDType),
+				Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+					return ctx.Object.(*node).Id, nil
+				},
+			},
+			"name": {
+				Type: schema.StringType,
+				Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+					return ctx.Object.(*node).Name, nil
+				},
+			},
+			"status": {
+				Type: &schema.EnumType{
+					Name: "Status",
+					Values: map[string]*schema.EnumValueDefinition{
+						"ACTIVE": {
+							Value: "active",
+						},
+					},
+				},
+				Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+					return ctx.Object.(*node).Status, nil
+				},
+			},
+		},
+	}
+
+	nodes := make([]*node, 1000)
+	for i := range nodes {
+		nodes[i] = &node{
+			Id:     fmt.Sprintf("%v", i),
+			Name:   "foo",
+			Status: "active",
+		}
+	}
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"nodes": {
+					Type: schema.NewListType(nodeType),
+					Resolve: func(schema.FieldContext) (interface{}, error) {
+						return nodes, nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(b, err)
+	doc, parseErrs := parser.ParseDocument([]byte(`{nodes{id name status}}`))
+	require.Empty(b, parseErrs)
+	require.Empty(b, validator.ValidateDocument(doc, s, nil))
+
+	r := &Request{
+		Document: doc,
+		Schema:   s,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		sink, _ = ExecuteRequest(context.Background(), r)
+	}
+}
+
 func TestContextCancelation(t *testing.T) {
 	var objectType = &schema.ObjectType{
 		Name: "Object",
@@ -12,7 +12,12 @@ type Result[T any] struct {
 
 // IsOk returns true if the result is not an error.
 func (r Result[T]) IsOk() bool {
-	return r.Error == nil || reflect.ValueOf(r.Error).IsNil()
+	if r.Error == nil {
+		return true
+	}
+	// Errors may be non-pointer types such as context.DeadlineExceeded.
+	rv := reflect.ValueOf(r.Error)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
 }
 
 // IsErr returns true if the result is an error.
@@ -82,8 +87,12 @@ func MapOk[T any, U any](f Future[T], fn func(T) U) Future[U] {
 			poll: func() (Result[U], bool) {
 				r, ok := f.poll()
 				var r2 Result[U]
-				if ok && r.IsOk() {
-					r2.Value = fn(r.Value)
+				if ok {
+					if r.IsOk() {
+						r2.Value = fn(r.Value)
+					} else {
+						r2.Error = r.Error
+					}
 				}
 				return r2, ok
 			},
@@ -108,8 +117,12 @@ func MapOkToAny[T any](f Future[T]) Future[any] {
 			poll: func() (Result[any], bool) {
 				r, ok := f.poll()
 				var r2 Result[any]
-				if ok && r.IsOk() {
-					r2.Value = r.Value
+				if ok {
+					if r.IsOk() {
+						r2.Value = r.Value
+					} else {
+						r2.Error = r.Error
+					}
 				}
 				return r2, ok
 			},
@@ -134,8 +147,12 @@ func MapOkValue[T any, U any](f Future[T], v U) Future[U] {
 			poll: func() (Result[U], bool) {
 				r, ok := f.poll()
 				var r2 Result[U]
-				if ok && r.IsOk() {
-					r2.Value = v
+				if ok {
+					if r.IsOk() {
+						r2.Value = v
+					} else {
+						r2.Error = r.Error
+					}
 				}
 				return r2, ok
 			},
@@ -272,7 +289,8 @@ func After[T any](fs ...Future[T]) Future[struct{}] {
 	return New(func() (Result[struct{}], bool) {
 		ok := true
 
-		for _, f := range fs {
+		for i := range fs {
+			f := &fs[i]
 			f.Poll()
 			if f.IsReady() {
 				if !f.Result().IsOk() {
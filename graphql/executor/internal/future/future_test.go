@@ -24,6 +24,18 @@ func TestErr(t *testing.T) {
 	assert.Error(t, f.Result().Error)
 }
 
+type valueError struct{}
+
+func (valueError) Error() string {
+	return "value error"
+}
+
+func TestErr_NonPointer(t *testing.T) {
+	f := Err[bool](valueError{})
+	require.True(t, f.IsReady())
+	assert.True(t, f.Result().IsErr())
+}
+
 func TestMap(t *testing.T) {
 	f := Map(Ok(1), func(r Result[int]) Result[float64] {
 		return Result[float64]{
@@ -76,6 +88,32 @@ func TestPoll(t *testing.T) {
 	assert.Equal(t, 4, f.Result().Value)
 }
 
+func TestPoll_Err(t *testing.T) {
+	ready := false
+
+	f := New(func() (Result[int], bool) {
+		return Result[int]{Error: fmt.Errorf("foo")}, ready
+	})
+	for name, f := range map[string]Future[any]{
+		"MapOk": MapOk(f, func(v int) any {
+			return v
+		}),
+		"MapOkToAny": MapOkToAny(f),
+		"MapOkValue": MapOkValue(f, any(1)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ready = false
+			f.Poll()
+			require.False(t, f.IsReady())
+
+			ready = true
+			f.Poll()
+			require.True(t, f.IsReady())
+			assert.True(t, f.Result().IsErr())
+		})
+	}
+}
+
 func TestJoin(t *testing.T) {
 	t.Run("Ready", func(t *testing.T) {
 		f := Join(Ok(1), Ok(2))
@@ -0,0 +1,211 @@
+package executor
+
+import (
+	"reflect"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/executor/internal/future"
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+// listItem is the result of getting the next item of a list. If OK is false, the list has no more
+// items.
+type listItem struct {
+	Value any
+	OK    bool
+	Err   error
+}
+
+// pendingReceive is a receive from a list field's channel that couldn't complete without blocking.
+// It's completed either when the executor polls the channel again or by awaitPendingReceive.
+type pendingReceive struct {
+	Channel reflect.Value
+	Result  listItem
+	Done    bool
+}
+
+// Attempts to receive from the channel without blocking. Returns false if no item is available.
+func tryReceive(ch reflect.Value) (listItem, bool) {
+	item, ok := ch.TryRecv()
+	if ok {
+		return listItem{Value: item.Interface(), OK: true}, true
+	} else if item.IsValid() {
+		// The channel is closed.
+		return listItem{}, true
+	}
+	return listItem{}, false
+}
+
+// listCompletion holds the state of a list value that's being completed.
+type listCompletion struct {
+	executor  *executor
+	innerType schema.Type
+	fields    []*ast.Field
+	path      *path
+	nextItem  func() future.Future[listItem]
+
+	// If non-negative, no more than this many items are completed.
+	limit int
+
+	items          []future.Future[any]
+	recyclablePath *path
+}
+
+// Completes the list's remaining items. If the next item isn't available yet, the rest of the list
+// is completed once it is.
+func (l *listCompletion) complete() future.Future[any] {
+	for l.limit < 0 || len(l.items) < l.limit {
+		next := l.nextItem()
+		if !next.IsReady() {
+			return future.Then(next, func(r future.Result[listItem]) future.Future[any] {
+				if f, done := l.completeItem(r.Value); done {
+					return f
+				}
+				return l.complete()
+			})
+		}
+		if f, done := l.completeItem(next.Result().Value); done {
+			return f
+		}
+	}
+	return future.MapOkToAny(future.Join(l.items...))
+}
+
+// Adds the item to the list. If there are no more items to complete, true is returned along with
+// the list's result.
+func (l *listCompletion) completeItem(item listItem) (future.Future[any], bool) {
+	e := l.executor
+	if item.Err != nil {
+		return future.Err[any](newErrorWithPath(l.fields[0], l.path, "Unable to get list item: %v", item.Err)), true
+	} else if !item.OK {
+		return future.MapOkToAny(future.Join(l.items...)), true
+	}
+	i := len(l.items)
+	itemPath := l.recyclablePath
+	if itemPath == nil {
+		itemPath = l.path.WithIntComponent(i)
+	} else {
+		itemPath.IntComponent = i
+		l.recyclablePath = nil
+	}
+	fut := e.catchErrorIfNullable(l.innerType, e.completeValue(l.innerType, l.fields, item.Value, itemPath, -1), itemPath)
+	if fut.IsReady() {
+		if fut.Result().IsErr() {
+			// The error will null the entire list, so there's no point in completing the
+			// remaining items, and any previous items that are still pending are abandoned.
+			e.cancelPendingPromises(l.path)
+			return fut, true
+		}
+		l.recyclablePath = itemPath
+	}
+	l.items = append(l.items, fut)
+	return future.Future[any]{}, false
+}
+
+// Returns a function that yields the items of a list field's result in order, along with the
+// number of items if it's known in advance. Results may be slices, iterator functions of the form
+// func() (T, bool), or channels. Iterators and channels are consumed one item at a time, and they
+// aren't consumed any further once the list can no longer be completed successfully or the caller
+// has all the items it needs. The completed items are still collected by the caller.
+//
+// Iterators are invoked on the executor's goroutine. Channels are never blocked on while anything
+// else can make progress: if no item is ready, the returned future is pending until the channel is
+// polled again, much like a ResolvePromise. The future yields an error if the context is canceled
+// first.
+func (e *executor) listItems(result reflect.Value, path *path) (next func() future.Future[listItem], length int, ok bool) {
+	switch result.Kind() {
+	case reflect.Slice:
+		i := 0
+		return func() future.Future[listItem] {
+			if i >= result.Len() {
+				return future.Ok(listItem{})
+			}
+			i++
+			return future.Ok(listItem{Value: result.Index(i - 1).Interface(), OK: true})
+		}, result.Len(), true
+	case reflect.Func:
+		if f, ok := result.Interface().(func() (any, bool)); ok {
+			return func() future.Future[listItem] {
+				item, ok := f()
+				return future.Ok(listItem{Value: item, OK: ok})
+			}, 0, true
+		}
+		t := result.Type()
+		if t.NumIn() != 0 || t.NumOut() != 2 || t.Out(1).Kind() != reflect.Bool {
+			return nil, 0, false
+		}
+		return func() future.Future[listItem] {
+			out := result.Call(nil)
+			if !out[1].Bool() {
+				return future.Ok(listItem{})
+			}
+			return future.Ok(listItem{Value: out[0].Interface(), OK: true})
+		}, 0, true
+	case reflect.Chan:
+		if result.Type().ChanDir()&reflect.RecvDir == 0 {
+			return nil, 0, false
+		}
+		return func() future.Future[listItem] {
+			if item, ok := tryReceive(result); ok {
+				return future.Ok(item)
+			} else if err := e.Context.Err(); err != nil {
+				return future.Ok(listItem{Err: err})
+			}
+			r := &pendingReceive{
+				Channel: result,
+			}
+			e.PendingReceives[r] = path
+			return future.New(func() (future.Result[listItem], bool) {
+				if !r.Done {
+					item, ok := tryReceive(result)
+					if !ok {
+						return future.Result[listItem]{}, false
+					}
+					delete(e.PendingReceives, r)
+					e.ResolvedPromiseCount++
+					r.Result = item
+				}
+				return future.Result[listItem]{Value: r.Result}, true
+			})
+		}, 0, true
+	}
+	return nil, 0, false
+}
+
+// Blocks until one of the pending receives can be completed or the context is canceled. This is
+// only used once nothing else can make progress. If the context is canceled, all pending receives
+// are completed with the context's error.
+func (e *executor) awaitPendingReceive() {
+	receives := make([]*pendingReceive, 0, len(e.PendingReceives))
+	cases := make([]reflect.SelectCase, 1, len(e.PendingReceives)+1)
+	cases[0] = reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(e.Context.Done()),
+	}
+	for r := range e.PendingReceives {
+		receives = append(receives, r)
+		cases = append(cases, reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: r.Channel,
+		})
+	}
+
+	chosen, item, ok := reflect.Select(cases)
+	if chosen == 0 {
+		for _, r := range receives {
+			r.Result = listItem{Err: e.Context.Err()}
+			r.Done = true
+			delete(e.PendingReceives, r)
+		}
+		e.ResolvedPromiseCount++
+		return
+	}
+
+	r := receives[chosen-1]
+	if ok {
+		r.Result = listItem{Value: item.Interface(), OK: true}
+	}
+	r.Done = true
+	delete(e.PendingReceives, r)
+	e.ResolvedPromiseCount++
+}
@@ -1,9 +1,13 @@
 package executor
 
 import (
+	"bytes"
+	"io"
 	"unsafe"
 
+	"github.com/fxamacker/cbor/v2"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/vmihailenco/msgpack"
 )
 
 // OrderedMapItem is a key-value pair for an item in an OrderedMap.
@@ -58,11 +62,102 @@ func (m *OrderedMap) Items() []OrderedMapItem {
 	return m.items
 }
 
+// Get returns the value for the given key. If the key doesn't exist, false is returned.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	for _, item := range m.items {
+		if item.Key == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Put replaces the value for the given key, or appends a new key-value pair if the key doesn't
+// exist.
+func (m *OrderedMap) Put(key string, value interface{}) {
+	for i := range m.items {
+		if m.items[i].Key == key {
+			m.items[i].Value = value
+			return
+		}
+	}
+	m.Append(key, value)
+}
+
+// Delete removes the given key from the map, preserving the order of the remaining items. It returns
+// true if the key existed.
+func (m *OrderedMap) Delete(key string) bool {
+	for i, item := range m.items {
+		if item.Key == key {
+			m.items = append(m.items[:i], m.items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Range invokes f for each key-value pair in the map, in order. If f returns false, iteration
+// stops.
+func (m *OrderedMap) Range(f func(key string, value interface{}) bool) {
+	for _, item := range m.items {
+		if !f(item.Key, item.Value) {
+			return
+		}
+	}
+}
+
+// WriteJSON writes the map to w as JSON, maintaining the correct key order. Unlike MarshalJSON, the
+// output is streamed instead of being buffered in its entirety.
+func (m *OrderedMap) WriteJSON(w io.Writer) error {
+	stream := jsoniter.NewStream(jsoniter.ConfigDefault, w, 4096)
+	stream.WriteVal(m)
+	if stream.Error != nil {
+		return stream.Error
+	}
+	return stream.Flush()
+}
+
 // MarshalJSON marshals the map to JSON, maintaining the correct key order.
 func (m *OrderedMap) MarshalJSON() ([]byte, error) {
 	return jsoniter.Marshal(m)
 }
 
+// EncodeMsgpack implements msgpack.CustomEncoder, maintaining the correct key order.
+func (m *OrderedMap) EncodeMsgpack(enc *msgpack.Encoder) error {
+	if err := enc.EncodeMapLen(len(m.items)); err != nil {
+		return err
+	}
+	for _, kv := range m.items {
+		if err := enc.EncodeString(kv.Key); err != nil {
+			return err
+		} else if err := enc.Encode(kv.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalCBOR implements cbor.Marshaler, maintaining the correct key order. The map is encoded with
+// an indefinite length.
+func (m *OrderedMap) MarshalCBOR() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := cbor.NewEncoder(&buf)
+	if err := enc.StartIndefiniteMap(); err != nil {
+		return nil, err
+	}
+	for _, kv := range m.items {
+		if err := enc.Encode(kv.Key); err != nil {
+			return nil, err
+		} else if err := enc.Encode(kv.Value); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.EndIndefinite(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 type orderedMapEncoder struct{}
 
 func (e *orderedMapEncoder) IsEmpty(ptr unsafe.Pointer) bool {
@@ -1,12 +1,14 @@
 package executor
 
 import (
+	"bytes"
 	"encoding/json"
 	"strconv"
 	"testing"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestOrderedMapEncoding(t *testing.T) {
@@ -43,3 +45,32 @@ func BenchmarkOrderedMapEncoding(b *testing.B) {
 		sink, _ = jsoniter.ConfigFastest.Marshal(m)
 	}
 }
+
+func TestOrderedMap(t *testing.T) {
+	m := NewOrderedMap()
+	m.Append("a", 1)
+	m.Append("b", 2)
+	m.Append("c", 3)
+
+	v, ok := m.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	_, ok = m.Get("d")
+	assert.False(t, ok)
+
+	m.Put("b", "redacted")
+	m.Put("d", 4)
+	assert.True(t, m.Delete("a"))
+	assert.False(t, m.Delete("a"))
+
+	var keys []string
+	m.Range(func(key string, value interface{}) bool {
+		keys = append(keys, key)
+		return key != "c"
+	})
+	assert.Equal(t, []string{"b", "c"}, keys)
+
+	var buf bytes.Buffer
+	require.NoError(t, m.WriteJSON(&buf))
+	assert.Equal(t, `{"b":"redacted","c":3,"d":4}`, buf.String())
+}
@@ -1,5 +1,175 @@
 package executor
 
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PathSegment is a single component of a Path. It's either a response key or, if Key is empty, a
+// list index.
+type PathSegment struct {
+	Key   string
+	Index int
+}
+
+// KeySegment returns a path segment for the given response key.
+func KeySegment(key string) PathSegment {
+	return PathSegment{Key: key}
+}
+
+// IndexSegment returns a path segment for the given list index.
+func IndexSegment(index int) PathSegment {
+	return PathSegment{Index: index}
+}
+
+// IsIndex returns true if the segment is a list index rather than a response key.
+func (s PathSegment) IsIndex() bool {
+	return s.Key == ""
+}
+
+func (s PathSegment) String() string {
+	if s.IsIndex() {
+		return strconv.Itoa(s.Index)
+	}
+	return s.Key
+}
+
+// MarshalJSON encodes the segment as a JSON string or number.
+func (s PathSegment) MarshalJSON() ([]byte, error) {
+	if s.IsIndex() {
+		return strconv.AppendInt(nil, int64(s.Index), 10), nil
+	}
+	return json.Marshal(s.Key)
+}
+
+// Component returns the segment as a string or int. It's the inverse of NewPathSegment.
+func (s PathSegment) Component() interface{} {
+	if s.IsIndex() {
+		return s.Index
+	}
+	return s.Key
+}
+
+// NewPathSegment converts a string or number into a path segment. Strings become response keys and
+// must not be empty. Numbers of any type become list indices and must be non-negative integers.
+func NewPathSegment(component interface{}) (PathSegment, error) {
+	v := reflect.ValueOf(component)
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() == 0 {
+			return PathSegment{}, fmt.Errorf("path keys must not be empty")
+		}
+		return KeySegment(v.String()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := v.Int(); n >= 0 && n <= math.MaxInt {
+			return IndexSegment(int(n)), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := v.Uint(); n <= math.MaxInt {
+			return IndexSegment(int(n)), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if n := v.Float(); n >= 0 && n <= 1<<53 && n == math.Trunc(n) {
+			return IndexSegment(int(n)), nil
+		}
+	default:
+		return PathSegment{}, fmt.Errorf("path segments must be strings or numbers")
+	}
+	return PathSegment{}, fmt.Errorf("invalid path index: %v", component)
+}
+
+// UnmarshalJSON decodes the segment from a JSON string or number.
+func (s *PathSegment) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	segment, err := NewPathSegment(v)
+	if err != nil {
+		return err
+	}
+	*s = segment
+	return nil
+}
+
+// Path is the location of a value within response data, e.g. the field that an error occurred
+// in. When encoded as JSON, it has the form defined by the spec, such as `["users", 0, "name"]`.
+type Path []PathSegment
+
+// NewPath builds a path from strings, which become response keys, and ints, which become list
+// indices. For convenience with decoded JSON, other numeric types such as float64 are also
+// accepted as list indices. It panics if any component isn't valid according to NewPathSegment.
+func NewPath(components ...interface{}) Path {
+	ret, err := ParsePath(components)
+	if err != nil {
+		panic(err.Error())
+	}
+	return ret
+}
+
+// ParsePath is like NewPath, but returns an error instead of panicking. It's the inverse of
+// Components, and it's typically used to decode paths from encodings other than JSON.
+func ParsePath(components []interface{}) (Path, error) {
+	ret := make(Path, len(components))
+	for i, component := range components {
+		segment, err := NewPathSegment(component)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = segment
+	}
+	return ret, nil
+}
+
+// String returns the path's segments joined by dots, e.g. "users.0.name".
+func (p Path) String() string {
+	var b strings.Builder
+	for i, segment := range p {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(segment.String())
+	}
+	return b.String()
+}
+
+// MatchPrefix returns true if prefix is equal to p or to one of its ancestors.
+func (p Path) MatchPrefix(prefix Path) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	for i, segment := range prefix {
+		if p[i] != segment {
+			return false
+		}
+	}
+	return true
+}
+
+// Components returns the path's segments as strings and ints.
+func (p Path) Components() []interface{} {
+	if p == nil {
+		return nil
+	}
+	ret := make([]interface{}, len(p))
+	for i, segment := range p {
+		ret[i] = segment.Component()
+	}
+	return ret
+}
+
+// Append returns a copy of the path with the given segments appended. Unlike the built-in append,
+// it never modifies p's backing array.
+func (p Path) Append(segments ...PathSegment) Path {
+	ret := make(Path, len(p), len(p)+len(segments))
+	copy(ret, p)
+	return append(ret, segments...)
+}
+
 type path struct {
 	Prev            *path
 	StringComponent string
@@ -20,12 +190,52 @@ func (p *path) WithStringComponent(s string) *path {
 	}
 }
 
-func (p *path) Slice() []interface{} {
+// fieldPath is the path of a field which is only allocated if it's needed. Most fields are scalars
+// which resolve without errors, so their paths are never needed.
+type fieldPath struct {
+	parent      *path
+	responseKey string
+	path        *path
+}
+
+func (p *fieldPath) get() *path {
+	if p.path == nil {
+		p.path = p.parent.WithStringComponent(p.responseKey)
+	}
+	return p.path
+}
+
+// HasAncestor returns true if ancestor is p or one of its predecessors. Paths are compared by
+// identity, not by value.
+func (p *path) HasAncestor(ancestor *path) bool {
+	for ; p != nil; p = p.Prev {
+		if p == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// Path returns the exported representation of the path. Only a single allocation is made.
+func (p *path) Path() Path {
 	if p == nil {
 		return nil
 	}
-	if p.StringComponent != "" {
-		return append(p.Prev.Slice(), p.StringComponent)
+	n := 0
+	for c := p; c != nil; c = c.Prev {
+		n++
 	}
-	return append(p.Prev.Slice(), p.IntComponent)
+	ret := make(Path, n)
+	for c := p; c != nil; c = c.Prev {
+		n--
+		ret[n] = PathSegment{
+			Key:   c.StringComponent,
+			Index: c.IntComponent,
+		}
+	}
+	return ret
+}
+
+func (p *path) String() string {
+	return p.Path().String()
 }
@@ -0,0 +1,63 @@
+package executor
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPath(t *testing.T) {
+	p := NewPath("users", 0, "name")
+	assert.Equal(t, Path{KeySegment("users"), IndexSegment(0), KeySegment("name")}, p)
+	assert.Equal(t, "users.0.name", p.String())
+	assert.Equal(t, []interface{}{"users", 0, "name"}, p.Components())
+
+	assert.True(t, p.MatchPrefix(nil))
+	assert.True(t, p.MatchPrefix(NewPath("users", 0)))
+	assert.True(t, p.MatchPrefix(p))
+	assert.False(t, p.MatchPrefix(NewPath("users", 1)))
+	assert.False(t, p.MatchPrefix(NewPath("users", 0, "name", "first")))
+
+	q := p[:2].Append(KeySegment("email"))
+	assert.Equal(t, "users.0.email", q.String())
+	assert.Equal(t, "users.0.name", p.String())
+
+	assert.Equal(t, p, NewPath("users", 0.0, "name"))
+	assert.Equal(t, p, NewPath("users", int64(0), "name"))
+	for _, invalid := range []interface{}{true, nil, "", -1, 1.5, -1.0, math.NaN(), uint64(math.MaxUint64)} {
+		assert.Panics(t, func() {
+			NewPath(invalid)
+		}, "%v", invalid)
+	}
+
+	q, err := ParsePath(p.Components())
+	require.NoError(t, err)
+	assert.Equal(t, p, q)
+	_, err = ParsePath([]interface{}{"users", 0.5})
+	assert.Error(t, err)
+}
+
+func TestPath_JSON(t *testing.T) {
+	buf, err := json.Marshal(NewPath("users", 0, "name"))
+	require.NoError(t, err)
+	assert.Equal(t, `["users",0,"name"]`, string(buf))
+
+	var p Path
+	require.NoError(t, json.Unmarshal([]byte(`["_entities",1,"name"]`), &p))
+	assert.Equal(t, NewPath("_entities", 1, "name"), p)
+
+	for _, invalid := range []string{`[""]`, `[1.5]`, `[-1]`, `[null]`, `[{}]`} {
+		assert.Error(t, json.Unmarshal([]byte(invalid), &p), invalid)
+	}
+}
+
+func TestPath_Path(t *testing.T) {
+	var p *path
+	assert.Nil(t, p.Path())
+	p = p.WithStringComponent("a").WithIntComponent(2).WithStringComponent("b")
+	assert.Equal(t, NewPath("a", 2, "b"), p.Path())
+	assert.Equal(t, "a.2.b", p.String())
+}
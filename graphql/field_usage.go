@@ -0,0 +1,74 @@
+package graphql
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/executor"
+	"github.com/ccbrown/api-fu/graphql/schema"
+	"github.com/ccbrown/api-fu/graphql/validator"
+)
+
+// SelectedFields returns the sorted schema coordinates, e.g. "User.name", of all of the fields
+// selected by an operation, including those selected via fragments. Introspection fields are
+// omitted. The document must be valid.
+//
+// When a field is selected on an interface, the coordinates of the corresponding fields of the
+// interface's implementations are also returned since any of them may be resolved.
+func SelectedFields(doc *ast.Document, s *Schema, features FeatureSet, operationName string) []string {
+	operation, err := executor.GetOperation(doc, operationName)
+	if err != nil {
+		return nil
+	}
+
+	typeInfo := validator.NewTypeInfo(doc, s, features)
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[def.Name.Name] = def
+		}
+	}
+
+	selected := map[string]struct{}{}
+	visitedFragments := map[string]struct{}{}
+	var visitSelectionSet func(selectionSet *ast.SelectionSet)
+	visitSelectionSet = func(selectionSet *ast.SelectionSet) {
+		for _, selection := range selectionSet.Selections {
+			switch selection := selection.(type) {
+			case *ast.Field:
+				name := selection.Name.Name
+				if parent := typeInfo.SelectionSetTypes[selectionSet]; parent != nil && !strings.HasPrefix(name, "__") {
+					selected[parent.TypeName()+"."+name] = struct{}{}
+					if iface, ok := parent.(*schema.InterfaceType); ok {
+						for _, impl := range s.InterfaceImplementations(iface.Name) {
+							selected[impl.Name+"."+name] = struct{}{}
+						}
+					}
+				}
+				if selection.SelectionSet != nil {
+					visitSelectionSet(selection.SelectionSet)
+				}
+			case *ast.InlineFragment:
+				visitSelectionSet(selection.SelectionSet)
+			case *ast.FragmentSpread:
+				name := selection.FragmentName.Name
+				if _, ok := visitedFragments[name]; ok {
+					continue
+				}
+				visitedFragments[name] = struct{}{}
+				if fragment, ok := fragments[name]; ok {
+					visitSelectionSet(fragment.SelectionSet)
+				}
+			}
+		}
+	}
+	visitSelectionSet(operation.SelectionSet)
+
+	ret := make([]string, 0, len(selected))
+	for coordinate := range selected {
+		ret = append(ret, coordinate)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,66 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectedFields(t *testing.T) {
+	namedType := &InterfaceType{
+		Name: "Named",
+		Fields: map[string]*FieldDefinition{
+			"name": {
+				Type: StringType,
+			},
+		},
+	}
+
+	userType := &ObjectType{
+		Name: "User",
+		Fields: map[string]*FieldDefinition{
+			"name": {
+				Type: StringType,
+			},
+			"email": {
+				Type: StringType,
+			},
+		},
+		ImplementedInterfaces: []*InterfaceType{namedType},
+		IsTypeOf: func(interface{}) bool {
+			return true
+		},
+	}
+
+	s, err := NewSchema(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"user": {
+					Type: userType,
+				},
+				"named": {
+					Type: namedType,
+				},
+				"unused": {
+					Type: StringType,
+				},
+			},
+		},
+		AdditionalTypes: []NamedType{userType},
+	})
+	require.NoError(t, err)
+
+	doc, errs := ParseAndValidate(`
+		query A { user { ...F } __typename }
+		query B { named { name ... on User { email } } }
+		fragment F on User { email ...G }
+		fragment G on User { email }
+	`, s, nil)
+	require.Empty(t, errs)
+
+	assert.Equal(t, []string{"Query.user", "User.email"}, SelectedFields(doc, s, nil, "A"))
+	assert.Equal(t, []string{"Named.name", "Query.named", "User.email", "User.name"}, SelectedFields(doc, s, nil, "B"))
+	assert.Nil(t, SelectedFields(doc, s, nil, "C"))
+}
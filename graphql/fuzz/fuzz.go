@@ -0,0 +1,443 @@
+// Package fuzz generates random operations that are valid for a schema, along with values for their
+// variables. It's intended to drive fuzz tests that look for panics in the executor and in
+// resolvers before they're found in production:
+//
+//	func FuzzExecute(f *testing.F) {
+//		g := fuzz.NewGenerator(s, nil)
+//		f.Fuzz(func(t *testing.T, data []byte) {
+//			op := g.GenerateFromBytes(data)
+//			doc, errs := graphql.ParseAndValidate(op.Query, s, nil)
+//			...
+//		})
+//	}
+//
+// Operations select fields up to a bounded depth and provide every required argument along with a
+// random subset of optional ones. Argument values are given either inline or via variables so that
+// both coercion paths are exercised.
+package fuzz
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+// Config configures a Generator.
+type Config struct {
+	// The maximum depth of selection sets. If zero, 3 is used.
+	MaxDepth int
+
+	// The maximum number of fields selected by each selection set. If zero, 4 is used.
+	MaxFields int
+
+	// The maximum number of items in generated list values. If zero, 3 is used.
+	MaxListLength int
+
+	// The features that operations may use. Fields, types, and arguments that require other
+	// features are never used.
+	Features schema.FeatureSet
+
+	// If true, mutations may be generated in addition to queries. Subscriptions are never
+	// generated.
+	Mutations bool
+
+	// Generators for the values of custom scalars, keyed by scalar name. Values are always given via
+	// variables, so they should be suitable for VariableValueCoercion. Arguments with custom
+	// scalar types that don't have generators are omitted, and fields that require such arguments
+	// aren't selected.
+	ScalarValues map[string]func(r *rand.Rand) interface{}
+}
+
+// Operation is a generated operation.
+type Operation struct {
+	Query     string
+	Variables map[string]interface{}
+}
+
+// Generator generates operations for a schema.
+type Generator struct {
+	schema *schema.Schema
+	config Config
+}
+
+// NewGenerator creates a new Generator for the given schema. If config is nil, the defaults are
+// used.
+func NewGenerator(s *schema.Schema, config *Config) *Generator {
+	g := &Generator{
+		schema: s,
+	}
+	if config != nil {
+		g.config = *config
+	}
+	if g.config.MaxDepth <= 0 {
+		g.config.MaxDepth = 3
+	}
+	if g.config.MaxFields <= 0 {
+		g.config.MaxFields = 4
+	}
+	if g.config.MaxListLength <= 0 {
+		g.config.MaxListLength = 3
+	}
+	return g
+}
+
+// Generate generates an operation using the given source of randomness.
+func (g *Generator) Generate(r *rand.Rand) *Operation {
+	gen := &generation{
+		Generator: g,
+		rand:      r,
+		variables: map[string]interface{}{},
+	}
+
+	operationType, root := "query", g.schema.QueryType()
+	if g.config.Mutations && g.schema.MutationType() != nil && r.Intn(2) == 0 {
+		operationType, root = "mutation", g.schema.MutationType()
+	}
+
+	selections := gen.selectionSet(root, 1)
+	var b strings.Builder
+	b.WriteString(operationType)
+	if len(gen.variableDefinitions) > 0 {
+		b.WriteString("(" + strings.Join(gen.variableDefinitions, ", ") + ")")
+	}
+	b.WriteString(" " + selections)
+	return &Operation{
+		Query:     b.String(),
+		Variables: gen.variables,
+	}
+}
+
+// GenerateFromBytes generates an operation using the given bytes as the source of randomness. This
+// is intended for use with fuzzers: small changes to the data generally result in small changes to
+// the operation. Once the data is exhausted, the generator makes the simplest choices.
+func (g *Generator) GenerateFromBytes(data []byte) *Operation {
+	return g.Generate(rand.New(&byteSource{data: data}))
+}
+
+// byteSource is a rand.Source that produces values from a byte slice.
+type byteSource struct {
+	data []byte
+}
+
+func (s *byteSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *byteSource) Uint64() uint64 {
+	var buf [8]byte
+	n := copy(buf[:], s.data)
+	s.data = s.data[n:]
+	return binary.BigEndian.Uint64(buf[:])
+}
+
+func (s *byteSource) Seed(seed int64) {}
+
+// generation holds the state for a single generated operation.
+type generation struct {
+	*Generator
+	rand                *rand.Rand
+	aliases             int
+	variableDefinitions []string
+	variables           map[string]interface{}
+}
+
+func (g *generation) available(features schema.FeatureSet) bool {
+	return features.IsSubsetOf(g.config.Features)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isLeafType(t schema.Type) bool {
+	switch schema.UnwrappedType(t).(type) {
+	case *schema.ScalarType, *schema.EnumType:
+		return true
+	}
+	return false
+}
+
+// Returns a selection set for the given composite type.
+func (g *generation) selectionSet(t schema.NamedType, depth int) string {
+	var selections []string
+	switch t := t.(type) {
+	case *schema.ObjectType:
+		selections = g.fields(t.Fields, depth)
+	case *schema.InterfaceType:
+		selections = g.fields(t.Fields, depth)
+		selections = append(selections, g.inlineFragments(g.schema.InterfaceImplementations(t.Name), depth)...)
+	case *schema.UnionType:
+		selections = g.inlineFragments(t.MemberTypes, depth)
+	}
+	if len(selections) == 0 {
+		selections = append(selections, "__typename")
+	}
+	return "{" + strings.Join(selections, " ") + "}"
+}
+
+// Returns inline fragments for a random subset of the given object types.
+func (g *generation) inlineFragments(types []*schema.ObjectType, depth int) []string {
+	var ret []string
+	for _, t := range types {
+		if g.available(t.RequiredFeatures) && g.rand.Intn(2) == 0 {
+			ret = append(ret, "... on "+t.Name+" "+g.selectionSet(t, depth))
+		}
+	}
+	return ret
+}
+
+// Returns a random selection of the given fields. Every field is given a unique alias so that
+// selections never conflict.
+func (g *generation) fields(fields map[string]*schema.FieldDefinition, depth int) []string {
+	var candidates []string
+	for _, name := range sortedKeys(fields) {
+		field := fields[name]
+		if !g.available(field.RequiredFeatures) || (depth >= g.config.MaxDepth && !isLeafType(field.Type)) {
+			continue
+		}
+		candidates = append(candidates, name)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var ret []string
+	for n := 1 + g.rand.Intn(g.config.MaxFields); n > 0; n-- {
+		name := candidates[g.rand.Intn(len(candidates))]
+		field := fields[name]
+		arguments, ok := g.arguments(field.Arguments)
+		if !ok {
+			continue
+		}
+		g.aliases++
+		s := "f" + strconv.Itoa(g.aliases) + ": " + name + arguments
+		if !isLeafType(field.Type) {
+			s += " " + g.selectionSet(schema.UnwrappedType(field.Type), depth+1)
+		}
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+// Returns the arguments for a field, or false if a required argument can't be generated.
+func (g *generation) arguments(defs map[string]*schema.InputValueDefinition) (string, bool) {
+	var arguments []string
+	for _, name := range sortedKeys(defs) {
+		def := defs[name]
+		required := schema.IsNonNullType(def.Type) && def.DefaultValue == nil
+		if !required && g.rand.Intn(2) == 0 {
+			continue
+		}
+		value, ok := g.value(def.Type, def.Constraints, 0)
+		if !ok {
+			if required {
+				return "", false
+			}
+			continue
+		}
+		literal, ok := g.literal(def.Type, value)
+		if !ok || g.rand.Intn(2) == 0 {
+			variable := "v" + strconv.Itoa(len(g.variableDefinitions)+1)
+			g.variableDefinitions = append(g.variableDefinitions, "$"+variable+": "+def.Type.String())
+			g.variables[variable] = value
+			literal = "$" + variable
+		}
+		arguments = append(arguments, name+": "+literal)
+	}
+	if len(arguments) == 0 {
+		return "", true
+	}
+	return "(" + strings.Join(arguments, ", ") + ")", true
+}
+
+// Returns a random value of the given type suitable for use as a variable value, or false if one
+// can't be generated.
+func (g *generation) value(t schema.Type, constraints *schema.InputValueConstraints, depth int) (interface{}, bool) {
+	nonNull, isNonNull := t.(*schema.NonNullType)
+	if isNonNull {
+		t = nonNull.Type
+	} else if g.rand.Intn(8) == 0 {
+		return nil, true
+	}
+
+	switch t := t.(type) {
+	case *schema.ListType:
+		n := g.rand.Intn(g.config.MaxListLength + 1)
+		if constraints != nil {
+			n = clampLength(n, constraints)
+		}
+		ret := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			item, ok := g.value(t.Type, nil, depth)
+			if !ok {
+				return nil, false
+			}
+			ret = append(ret, item)
+		}
+		return ret, true
+	case *schema.EnumType:
+		names := sortedKeys(t.Values)
+		if len(names) == 0 {
+			return nil, false
+		}
+		return names[g.rand.Intn(len(names))], true
+	case *schema.InputObjectType:
+		if !g.available(t.RequiredFeatures) {
+			return nil, false
+		}
+		ret := map[string]interface{}{}
+		for _, name := range sortedKeys(t.Fields) {
+			field := t.Fields[name]
+			required := schema.IsNonNullType(field.Type) && field.DefaultValue == nil
+			if !required && (depth >= g.config.MaxDepth || g.rand.Intn(2) == 0) {
+				continue
+			}
+			if depth >= g.config.MaxDepth*2 {
+				// The input object is recursive and the recursion can't be broken.
+				return nil, false
+			}
+			value, ok := g.value(field.Type, field.Constraints, depth+1)
+			if !ok {
+				if required {
+					return nil, false
+				}
+				continue
+			}
+			ret[name] = value
+		}
+		return ret, true
+	case *schema.ScalarType:
+		if !g.available(t.RequiredFeatures) {
+			return nil, false
+		}
+		return g.scalarValue(t, constraints)
+	}
+	return nil, false
+}
+
+const stringRunes = "abcXYZ019 _-\"\\\n\té😀"
+
+func (g *generation) scalarValue(t *schema.ScalarType, constraints *schema.InputValueConstraints) (interface{}, bool) {
+	switch t {
+	case schema.IntType:
+		n := float64(g.rand.Intn(2001) - 1000)
+		if g.rand.Intn(16) == 0 {
+			n = float64(math.MaxInt32)
+		}
+		n = clampNumber(n, constraints)
+		if constraints != nil && constraints.Min != nil && n < math.Ceil(*constraints.Min) {
+			n = math.Ceil(*constraints.Min)
+		} else if constraints != nil && constraints.Max != nil && n > math.Floor(*constraints.Max) {
+			n = math.Floor(*constraints.Max)
+		}
+		return int(n), true
+	case schema.FloatType:
+		return clampNumber(g.rand.NormFloat64()*1000, constraints), true
+	case schema.BooleanType:
+		return g.rand.Intn(2) == 0, true
+	case schema.IDType:
+		return strconv.Itoa(g.rand.Intn(1000)), true
+	case schema.StringType:
+		runes := []rune(stringRunes)
+		n := g.rand.Intn(8)
+		if constraints != nil {
+			n = clampLength(n, constraints)
+		}
+		s := make([]rune, n)
+		for i := range s {
+			s[i] = runes[g.rand.Intn(len(runes))]
+		}
+		return string(s), true
+	}
+	if f := g.config.ScalarValues[t.Name]; f != nil {
+		return f(g.rand), true
+	}
+	return nil, false
+}
+
+func clampNumber(n float64, constraints *schema.InputValueConstraints) float64 {
+	if constraints != nil && constraints.Min != nil && n < *constraints.Min {
+		n = *constraints.Min
+	}
+	if constraints != nil && constraints.Max != nil && n > *constraints.Max {
+		n = *constraints.Max
+	}
+	return n
+}
+
+func clampLength(n int, constraints *schema.InputValueConstraints) int {
+	if n < constraints.MinLength {
+		n = constraints.MinLength
+	}
+	if constraints.MaxLength > 0 && n > constraints.MaxLength {
+		n = constraints.MaxLength
+	}
+	return n
+}
+
+// Returns the GraphQL literal for a value produced by value, or false if it can't be represented as
+// a literal, e.g. because it contains a custom scalar.
+func (g *generation) literal(t schema.Type, v interface{}) (string, bool) {
+	if v == nil {
+		return "null", true
+	}
+	if nonNull, ok := t.(*schema.NonNullType); ok {
+		t = nonNull.Type
+	}
+	switch t := t.(type) {
+	case *schema.ListType:
+		items := v.([]interface{})
+		parts := make([]string, len(items))
+		for i, item := range items {
+			s, ok := g.literal(t.Type, item)
+			if !ok {
+				return "", false
+			}
+			parts[i] = s
+		}
+		return "[" + strings.Join(parts, ", ") + "]", true
+	case *schema.EnumType:
+		return v.(string), true
+	case *schema.InputObjectType:
+		fields := v.(map[string]interface{})
+		parts := make([]string, 0, len(fields))
+		for _, name := range sortedKeys(fields) {
+			s, ok := g.literal(t.Fields[name].Type, fields[name])
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, name+": "+s)
+		}
+		return "{" + strings.Join(parts, ", ") + "}", true
+	case *schema.ScalarType:
+		switch t {
+		case schema.IntType, schema.BooleanType:
+			b, _ := json.Marshal(v)
+			return string(b), true
+		case schema.FloatType:
+			return strconv.FormatFloat(v.(float64), 'g', -1, 64), true
+		case schema.StringType, schema.IDType:
+			// The source text of a document is limited to the Basic Multilingual Plane, so any
+			// other characters must be passed via variables.
+			for _, r := range v.(string) {
+				if r > 0xffff {
+					return "", false
+				}
+			}
+			b, _ := json.Marshal(v)
+			return string(b), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,284 @@
+package fuzz
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/executor"
+	"github.com/ccbrown/api-fu/graphql/parser"
+	"github.com/ccbrown/api-fu/graphql/schema"
+	"github.com/ccbrown/api-fu/graphql/validator"
+)
+
+func newTestSchema(t testing.TB) *schema.Schema {
+	dateTimeType := &schema.ScalarType{
+		Name: "DateTime",
+		LiteralCoercion: func(v ast.Value) interface{} {
+			return nil
+		},
+		VariableValueCoercion: func(v interface{}) interface{} {
+			if s, ok := v.(string); ok {
+				if t, err := time.Parse(time.RFC3339, s); err == nil {
+					return t
+				}
+			}
+			return nil
+		},
+		ResultCoercion: func(v interface{}) interface{} {
+			if t, ok := v.(time.Time); ok {
+				return t.Format(time.RFC3339)
+			}
+			return nil
+		},
+	}
+
+	colorType := &schema.EnumType{
+		Name: "Color",
+		Values: map[string]*schema.EnumValueDefinition{
+			"RED":  {Value: "red"},
+			"BLUE": {Value: "blue"},
+		},
+	}
+
+	filterType := &schema.InputObjectType{
+		Name: "Filter",
+		Fields: map[string]*schema.InputValueDefinition{
+			"color": {
+				Type: colorType,
+			},
+			"limit": {
+				Type:        schema.NewNonNullType(schema.IntType),
+				Constraints: &schema.InputValueConstraints{Min: float64Ptr(0), Max: float64Ptr(10)},
+			},
+		},
+	}
+	filterType.Fields["and"] = &schema.InputValueDefinition{
+		Type: schema.NewListType(schema.NewNonNullType(filterType)),
+	}
+
+	resolveSelf := func(ctx schema.FieldContext) (interface{}, error) {
+		return ctx.Object, nil
+	}
+
+	nodeType := &schema.InterfaceType{
+		Name: "Node",
+		Fields: map[string]*schema.FieldDefinition{
+			"id": {
+				Type: schema.NewNonNullType(schema.IDType),
+			},
+		},
+	}
+
+	userType := &schema.ObjectType{
+		Name:                  "User",
+		ImplementedInterfaces: []*schema.InterfaceType{nodeType},
+		IsTypeOf: func(v interface{}) bool {
+			return v == "user"
+		},
+	}
+	postType := &schema.ObjectType{
+		Name:                  "Post",
+		ImplementedInterfaces: []*schema.InterfaceType{nodeType},
+		IsTypeOf: func(v interface{}) bool {
+			return v == "post"
+		},
+	}
+	userType.Fields = map[string]*schema.FieldDefinition{
+		"id": {
+			Type: schema.NewNonNullType(schema.IDType),
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return "1", nil
+			},
+		},
+		"name": {
+			Type: schema.StringType,
+			Arguments: map[string]*schema.InputValueDefinition{
+				"upper": {
+					Type:         schema.BooleanType,
+					DefaultValue: false,
+				},
+			},
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return "alice", nil
+			},
+		},
+		"posts": {
+			Type: schema.NewNonNullType(schema.NewListType(schema.NewNonNullType(postType))),
+			Arguments: map[string]*schema.InputValueDefinition{
+				"filter": {
+					Type: schema.NewNonNullType(filterType),
+				},
+				"since": {
+					Type: dateTimeType,
+				},
+			},
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return []interface{}{"post", "post"}, nil
+			},
+		},
+		"secret": {
+			Type:             schema.StringType,
+			RequiredFeatures: schema.NewFeatureSet("secret"),
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				panic("secret fields should never be selected")
+			},
+		},
+	}
+	postType.Fields = map[string]*schema.FieldDefinition{
+		"id": {
+			Type: schema.NewNonNullType(schema.IDType),
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return "2", nil
+			},
+		},
+		"author": {
+			Type: userType,
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return "user", nil
+			},
+		},
+		"createdAt": {
+			Type: dateTimeType,
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), nil
+			},
+		},
+		"color": {
+			Type: colorType,
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return "red", nil
+			},
+		},
+	}
+
+	searchResultType := &schema.UnionType{
+		Name:        "SearchResult",
+		MemberTypes: []*schema.ObjectType{userType, postType},
+	}
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"node": {
+					Type: nodeType,
+					Arguments: map[string]*schema.InputValueDefinition{
+						"id": {
+							Type: schema.NewNonNullType(schema.IDType),
+						},
+					},
+					Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+						return "user", nil
+					},
+				},
+				"search": {
+					Type: schema.NewListType(searchResultType),
+					Arguments: map[string]*schema.InputValueDefinition{
+						"text": {
+							Type:        schema.NewNonNullType(schema.StringType),
+							Constraints: &schema.InputValueConstraints{MaxLength: 4},
+						},
+						"colors": {
+							Type: schema.NewListType(colorType),
+						},
+						"weight": {
+							Type: schema.FloatType,
+						},
+					},
+					Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+						return []interface{}{"user", "post", nil}, nil
+					},
+				},
+				"viewer": {
+					Type:    schema.NewNonNullType(userType),
+					Resolve: func(ctx schema.FieldContext) (interface{}, error) { return "user", nil },
+				},
+			},
+		},
+		Mutation: &schema.ObjectType{
+			Name: "Mutation",
+			Fields: map[string]*schema.FieldDefinition{
+				"touch": {
+					Type: schema.NewNonNullType(schema.BooleanType),
+					Arguments: map[string]*schema.InputValueDefinition{
+						"at": {
+							Type: schema.NewNonNullType(dateTimeType),
+						},
+					},
+					Resolve: resolveSelf,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func newTestGenerator(s *schema.Schema) *Generator {
+	return NewGenerator(s, &Config{
+		Mutations: true,
+		ScalarValues: map[string]func(r *rand.Rand) interface{}{
+			"DateTime": func(r *rand.Rand) interface{} {
+				return time.Unix(r.Int63n(1e9), 0).UTC().Format(time.RFC3339)
+			},
+		},
+	})
+}
+
+func execute(t testing.TB, s *schema.Schema, op *Operation) {
+	doc, parseErrs := parser.ParseDocument([]byte(op.Query))
+	require.Empty(t, parseErrs, op.Query)
+	require.Empty(t, validator.ValidateDocument(doc, s, nil), op.Query)
+	_, errs := executor.ExecuteRequest(context.Background(), &executor.Request{
+		Document:       doc,
+		Schema:         s,
+		VariableValues: op.Variables,
+		InitialValue:   true,
+	})
+	assert.Empty(t, errs, op.Query)
+}
+
+func TestGenerator(t *testing.T) {
+	s := newTestSchema(t)
+	g := newTestGenerator(s)
+	r := rand.New(rand.NewSource(0))
+	seenMutation := false
+	seenVariables := false
+	for i := 0; i < 500; i++ {
+		op := g.Generate(r)
+		execute(t, s, op)
+		seenMutation = seenMutation || op.Query[0] == 'm'
+		seenVariables = seenVariables || len(op.Variables) > 0
+	}
+	assert.True(t, seenMutation)
+	assert.True(t, seenVariables)
+}
+
+func TestGenerator_Deterministic(t *testing.T) {
+	s := newTestSchema(t)
+	g := newTestGenerator(s)
+	data := []byte("some fuzzer input")
+	assert.Equal(t, g.GenerateFromBytes(data), g.GenerateFromBytes(data))
+	assert.Equal(t, g.GenerateFromBytes(nil), g.GenerateFromBytes(nil))
+}
+
+func FuzzGenerator(f *testing.F) {
+	s := newTestSchema(f)
+	g := newTestGenerator(s)
+	f.Add([]byte{})
+	f.Add([]byte("\x01\x02\x03\x04\x05\x06\x07\x08"))
+	f.Add([]byte("the quick brown fox jumps over the lazy dog"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		execute(t, s, g.GenerateFromBytes(data))
+	})
+}
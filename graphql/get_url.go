@@ -0,0 +1,139 @@
+package graphql
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// CacheableGETRequest describes an operation to be executed via a GET request. See
+// CacheableGETURL.
+type CacheableGETRequest struct {
+	Query         string
+	OperationName string
+	Variables     map[string]interface{}
+	Extensions    map[string]interface{}
+
+	// If true, the query is omitted from the URL. Instead, it's identified by its SHA-256 hash via
+	// Apollo's persistedQuery extension, which keeps URLs short. The server must already have the
+	// query persisted, e.g. via a persisted operations manifest.
+	HashQuery bool
+}
+
+// CacheableGETURL returns a URL for executing the operation via a GET request to the given
+// endpoint. The URL's query string is canonical: parameters are sorted by name, empty parameters
+// are omitted, and variables and extensions are encoded as JSON objects with sorted keys. So
+// equivalent requests always produce identical URLs, which makes them suitable as cache keys for
+// CDNs and other HTTP caches. Servers can check that requests are canonical with
+// CanonicalGETQuery.
+//
+// The endpoint must not have a query string of its own.
+func CacheableGETURL(endpoint string, req *CacheableGETRequest) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	} else if u.RawQuery != "" {
+		return "", fmt.Errorf("endpoint must not have a query string")
+	}
+
+	values := url.Values{}
+	if req.HashQuery {
+		hash := sha256.Sum256([]byte(req.Query))
+		extensions := make(map[string]interface{}, len(req.Extensions)+1)
+		for k, v := range req.Extensions {
+			extensions[k] = v
+		}
+		extensions["persistedQuery"] = map[string]interface{}{
+			"version":    1,
+			"sha256Hash": hex.EncodeToString(hash[:]),
+		}
+		buf, err := json.Marshal(extensions)
+		if err != nil {
+			return "", fmt.Errorf("unable to encode extensions: %w", err)
+		}
+		values.Set("extensions", string(buf))
+	} else {
+		values.Set("query", req.Query)
+		if len(req.Extensions) > 0 {
+			buf, err := json.Marshal(req.Extensions)
+			if err != nil {
+				return "", fmt.Errorf("unable to encode extensions: %w", err)
+			}
+			values.Set("extensions", string(buf))
+		}
+	}
+	if req.OperationName != "" {
+		values.Set("operationName", req.OperationName)
+	}
+	if len(req.Variables) > 0 {
+		buf, err := json.Marshal(req.Variables)
+		if err != nil {
+			return "", fmt.Errorf("unable to encode variables: %w", err)
+		}
+		values.Set("variables", string(buf))
+	}
+
+	// The parameters are already canonical except for the JSON encoding of numbers, which must
+	// match the server's re-encoding.
+	query, err := CanonicalGETQuery(values)
+	if err != nil {
+		return "", err
+	}
+	u.RawQuery = query
+	return u.String(), nil
+}
+
+// CanonicalGETQuery returns the canonical form of a GET request's query string parameters as
+// produced by CacheableGETURL. An error is returned if the parameters include anything other than
+// a single "query", "operationName", "variables", or "extensions" value, or if the variables or
+// extensions aren't JSON objects.
+//
+// Servers can compare the result to the request's raw query string to reject requests that
+// aren't canonical, which prevents equivalent requests from fragmenting caches.
+func CanonicalGETQuery(values url.Values) (string, error) {
+	ret := url.Values{}
+	for name, v := range values {
+		if len(v) != 1 {
+			return "", fmt.Errorf("the %v parameter must be given exactly once", name)
+		}
+		switch name {
+		case "query", "operationName":
+			if v[0] != "" {
+				ret.Set(name, v[0])
+			}
+		case "variables", "extensions":
+			canonical, err := canonicalJSONObject(v[0])
+			if err != nil {
+				return "", fmt.Errorf("malformed %v parameter", name)
+			} else if canonical != "" {
+				ret.Set(name, canonical)
+			}
+		default:
+			return "", fmt.Errorf("unexpected parameter: %v", name)
+		}
+	}
+	return ret.Encode(), nil
+}
+
+// Re-encodes a JSON object with sorted keys and no insignificant whitespace. Numbers are preserved
+// exactly. Empty objects result in an empty string.
+func canonicalJSONObject(s string) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(s)))
+	decoder.UseNumber()
+	var v map[string]interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return "", err
+	} else if decoder.More() {
+		return "", fmt.Errorf("unexpected data after object")
+	} else if len(v) == 0 {
+		return "", nil
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,107 @@
+package graphql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheableGETURL(t *testing.T) {
+	t.Run("Query", func(t *testing.T) {
+		u, err := CacheableGETURL("https://example.com/graphql", &CacheableGETRequest{
+			Query:         `query Q($b: Int, $a: String) {foo}`,
+			OperationName: "Q",
+			Variables: map[string]interface{}{
+				"b": 1,
+				"a": "x",
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/graphql?operationName=Q&query=query+Q%28%24b%3A+Int%2C+%24a%3A+String%29+%7Bfoo%7D&variables=%7B%22a%22%3A%22x%22%2C%22b%22%3A1%7D", u)
+
+		parsed, err := url.Parse(u)
+		require.NoError(t, err)
+		canonical, err := CanonicalGETQuery(parsed.Query())
+		require.NoError(t, err)
+		assert.Equal(t, parsed.RawQuery, canonical)
+	})
+
+	t.Run("HashQuery", func(t *testing.T) {
+		const query = `{foo}`
+		hash := sha256.Sum256([]byte(query))
+		u, err := CacheableGETURL("/graphql", &CacheableGETRequest{
+			Query:     query,
+			HashQuery: true,
+		})
+		require.NoError(t, err)
+		parsed, err := url.Parse(u)
+		require.NoError(t, err)
+		assert.Equal(t, url.Values{
+			"extensions": []string{`{"persistedQuery":{"sha256Hash":"` + hex.EncodeToString(hash[:]) + `","version":1}}`},
+		}, parsed.Query())
+	})
+
+	t.Run("EndpointQueryString", func(t *testing.T) {
+		_, err := CacheableGETURL("/graphql?foo=bar", &CacheableGETRequest{
+			Query: `{foo}`,
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestCanonicalGETQuery(t *testing.T) {
+	for name, tc := range map[string]struct {
+		RawQuery string
+		Expected string
+		Error    bool
+	}{
+		"Canonical": {
+			RawQuery: "query=%7Bfoo%7D&variables=%7B%22a%22%3A1%7D",
+			Expected: "query=%7Bfoo%7D&variables=%7B%22a%22%3A1%7D",
+		},
+		"Unordered": {
+			RawQuery: "variables=%7B%22a%22%3A1%7D&query=%7Bfoo%7D",
+			Expected: "query=%7Bfoo%7D&variables=%7B%22a%22%3A1%7D",
+		},
+		"UnsortedVariables": {
+			RawQuery: "query=%7Bfoo%7D&variables=%7B%20%22b%22%3A%201%2C%20%22a%22%3A%202%20%7D",
+			Expected: "query=%7Bfoo%7D&variables=%7B%22a%22%3A2%2C%22b%22%3A1%7D",
+		},
+		"LargeNumber": {
+			RawQuery: "query=%7Bfoo%7D&variables=%7B%22a%22%3A12345678901234567890%7D",
+			Expected: "query=%7Bfoo%7D&variables=%7B%22a%22%3A12345678901234567890%7D",
+		},
+		"EmptyParameters": {
+			RawQuery: "query=%7Bfoo%7D&operationName=&variables=%7B%7D",
+			Expected: "query=%7Bfoo%7D",
+		},
+		"UnexpectedParameter": {
+			RawQuery: "query=%7Bfoo%7D&cachebuster=1",
+			Error:    true,
+		},
+		"RepeatedParameter": {
+			RawQuery: "query=%7Bfoo%7D&query=%7Bbar%7D",
+			Error:    true,
+		},
+		"MalformedVariables": {
+			RawQuery: "query=%7Bfoo%7D&variables=%5B%5D",
+			Error:    true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			values, err := url.ParseQuery(tc.RawQuery)
+			require.NoError(t, err)
+			canonical, err := CanonicalGETQuery(values)
+			if tc.Error {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.Expected, canonical)
+			}
+		})
+	}
+}
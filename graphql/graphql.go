@@ -3,15 +3,19 @@ package graphql
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"mime"
 	"net/http"
+	"time"
+	"unicode/utf8"
 
 	"github.com/ccbrown/api-fu/graphql/ast"
 	"github.com/ccbrown/api-fu/graphql/executor"
 	"github.com/ccbrown/api-fu/graphql/parser"
 	"github.com/ccbrown/api-fu/graphql/schema"
+	"github.com/ccbrown/api-fu/graphql/schema/introspection"
 	"github.com/ccbrown/api-fu/graphql/validator"
 )
 
@@ -69,6 +73,9 @@ type EnumValueDefinition = schema.EnumValueDefinition
 // InputValueDefinition defines an input value such as an argument.
 type InputValueDefinition = schema.InputValueDefinition
 
+// InputValueConstraints define restrictions on input values beyond those imposed by their types.
+type InputValueConstraints = schema.InputValueConstraints
+
 // FieldDefinition defines a field on an object type.
 type FieldDefinition = schema.FieldDefinition
 
@@ -86,12 +93,39 @@ func ValidateCost(operationName string, variableValues map[string]interface{}, m
 	return validator.ValidateCost(operationName, variableValues, max, actual, defaultCost)
 }
 
+// OperationCost is the cost of an operation, calculated by summing the costs of its fields.
+type OperationCost = validator.OperationCost
+
+// ValidateOperationCost is like ValidateCost, but it also calculates costs for each of the
+// dimensions defined by FieldCost.Dimensions. If max is nil, no limits are enforced. Otherwise, if
+// max.Resolver is non-negative, it limits the resolver cost, and each entry in max.Dimensions limits
+// the corresponding dimension.
+func ValidateOperationCost(operationName string, variableValues map[string]interface{}, max *OperationCost, actual *OperationCost, defaultCost schema.FieldCost) ValidatorRule {
+	return validator.ValidateOperationCost(operationName, variableValues, max, actual, defaultCost)
+}
+
+// ValidateSunsets rejects operations that use fields whose sunset time is at or before now. The
+// errors' extensions have a "code" of "FIELD_SUNSET". See FieldDefinition.SunsetTime.
+func ValidateSunsets(now time.Time) ValidatorRule {
+	return validator.ValidateSunsets(now)
+}
+
+// ValidateOperationTypes rejects operations with any of the given types, e.g. "mutation" or
+// "subscription". If operationName is empty, every operation in the document is checked. The
+// errors' extensions have a "code" of "OPERATION_TYPE_NOT_ALLOWED".
+func ValidateOperationTypes(operationName string, disallowed ...string) ValidatorRule {
+	return validator.ValidateOperationTypes(operationName, disallowed...)
+}
+
 // IncludeDirective implements the @include directive as defined by the GraphQL spec.
 var IncludeDirective = schema.IncludeDirective
 
 // SkipDirective implements the @skip directive as defined by the GraphQL spec.
 var SkipDirective = schema.SkipDirective
 
+// SpecifiedByDirective implements the @specifiedBy directive as defined by the GraphQL spec.
+var SpecifiedByDirective = schema.SpecifiedByDirective
+
 // IDType implements the ID type as defined by the GraphQL spec. It can be deserialized from a
 // string or an integer type, but always serializes to a string.
 var IDType = schema.IDType
@@ -128,6 +162,47 @@ type ResolveResult = executor.ResolveResult
 // returns, a result must be sent to at least one previously returned ResolvePromise.
 type ResolvePromise = executor.ResolvePromise
 
+// OrderedMap is the type used for objects within execution results. It maintains the order of its
+// keys and serializes to a JSON object.
+type OrderedMap = executor.OrderedMap
+
+// OrderedMapItem is a key-value pair within an OrderedMap.
+type OrderedMapItem = executor.OrderedMapItem
+
+// NewOrderedMap creates a new, empty OrderedMap.
+func NewOrderedMap() *OrderedMap {
+	return executor.NewOrderedMap()
+}
+
+// Path is the location of a value within response data, such as the path of an error. It's
+// encoded as JSON in the form defined by the spec, e.g. `["users", 0, "name"]`.
+type Path = executor.Path
+
+// PathSegment is a single component of a Path: either a response key or a list index.
+type PathSegment = executor.PathSegment
+
+// NewPath builds a path from strings, which become response keys, and ints, which become list
+// indices. It panics if given any other type, an empty key, or a negative or non-integral index.
+func NewPath(components ...interface{}) Path {
+	return executor.NewPath(components...)
+}
+
+// ParsePath is like NewPath, but returns an error instead of panicking. It's the inverse of
+// Path.Components.
+func ParsePath(components []interface{}) (Path, error) {
+	return executor.ParsePath(components)
+}
+
+// KeySegment returns a path segment for the given response key.
+func KeySegment(key string) PathSegment {
+	return executor.KeySegment(key)
+}
+
+// IndexSegment returns a path segment for the given list index.
+func IndexSegment(index int) PathSegment {
+	return executor.IndexSegment(index)
+}
+
 // Schema represents a GraphQL schema.
 type Schema = schema.Schema
 
@@ -147,6 +222,33 @@ func NewSchema(def *SchemaDefinition) (*Schema, error) {
 	return schema.New(def)
 }
 
+// SchemaVisitor contains the functions invoked by WalkSchema.
+type SchemaVisitor = schema.Visitor
+
+// SchemaWalkInfo describes the position of an element visited by WalkSchema.
+type SchemaWalkInfo = schema.WalkInfo
+
+// WalkSchema traverses the schema's directives, named types, fields, arguments, and enum values,
+// invoking the visitor's functions for each exactly once. See schema.Walk.
+func WalkSchema(s *Schema, v *SchemaVisitor) {
+	schema.Walk(s, v)
+}
+
+// IntrospectionJSON executes the introspection query against the given schema and returns the
+// JSON-encoded response, e.g. `{"data":{"__schema":{...}}}`. This is the format expected by
+// most tools that consume introspection results such as GraphiQL and graphql-codegen.
+func IntrospectionJSON(s *Schema) ([]byte, error) {
+	resp := Execute(&Request{
+		Context: context.Background(),
+		Query:   string(introspection.FullQuery),
+		Schema:  s,
+	})
+	if len(resp.Errors) > 0 {
+		return nil, resp.Errors[0]
+	}
+	return json.Marshal(resp)
+}
+
 // Request defines all of the inputs required to execute a GraphQL query.
 type Request struct {
 	Context context.Context
@@ -164,8 +266,49 @@ type Request struct {
 	Extensions     map[string]interface{}
 	InitialValue   interface{}
 	IdleHandler    func()
+
+	// If positive, execution will fail with an "async resolver deadlock" error if the idle handler
+	// returns this many consecutive times without a result being sent to any pending
+	// ResolvePromise.
+	IdleHandlerStallLimit int
+
+	// If given, this is invoked for each pending ResolvePromise whose result is no longer needed,
+	// e.g. because an error nulled one of the field's ancestors. It can be used to stop any work
+	// being done to fulfill the promise.
+	CancelPromise func(ResolvePromise)
+
+	// If true, the response's extensions will include a "nulledFields" list describing every
+	// nullable field that was set to null due to an error. See NulledField.
+	ReportNulledFields bool
+
+	// If true, the response's extensions will include a "deprecations" list describing every
+	// deprecated field and enum value used by the operation. See DeprecationWarning.
+	ReportDeprecations bool
+
+	// If true and the schema has warnings, the response's extensions will include a
+	// "schemaWarnings" list describing them. See schema.Schema.Warnings.
+	ReportSchemaWarnings bool
+
+	// If positive, at most this many errors are included in the response. If any errors are
+	// omitted, the response's extensions will include a "suppressedErrors" count.
+	MaxErrors int
+
+	// If positive, error messages longer than this many characters are truncated.
+	MaxErrorMessageLength int
+
+	// If true, __typename is selected for every field with a selection set as if the client had
+	// requested it. See InjectTypename.
+	InjectTypename bool
+
+	// If given, this is invoked before each field is resolved. The returned context is passed to
+	// the resolver. If the returned function is non-nil, it's invoked once the resolver's result is
+	// available. This can be used to trace or profile execution.
+	ObserveField func(ctx context.Context, field *ObservedField) (context.Context, func(error))
 }
 
+// ObservedField describes a field that's about to be resolved. See Request.ObserveField.
+type ObservedField = executor.ObservedField
+
 // Calculates the cost of the requested operation and ensures it is not greater than max. If max is
 // -1, no limit is enforced. If actual is non-nil, it is set to the actual cost of the operation.
 // Queries with costs that are too high to calculate due to overflows always result in an error when
@@ -174,6 +317,12 @@ func (r *Request) ValidateCost(max int, actual *int, defaultCost schema.FieldCos
 	return validator.ValidateCost(r.OperationName, r.VariableValues, max, actual, defaultCost)
 }
 
+// Calculates the cost of the requested operation, including its cost dimensions, and ensures it
+// is within max. See ValidateOperationCost.
+func (r *Request) ValidateOperationCost(max *OperationCost, actual *OperationCost, defaultCost schema.FieldCost) ValidatorRule {
+	return validator.ValidateOperationCost(r.OperationName, r.VariableValues, max, actual, defaultCost)
+}
+
 func (r *Request) executorRequest(doc *ast.Document) *executor.Request {
 	return &executor.Request{
 		Document:       doc,
@@ -183,13 +332,26 @@ func (r *Request) executorRequest(doc *ast.Document) *executor.Request {
 		Features:       r.Features,
 		InitialValue:   r.InitialValue,
 		IdleHandler:    r.IdleHandler,
+
+		IdleHandlerStallLimit: r.IdleHandlerStallLimit,
+		CancelPromise:         r.CancelPromise,
+		ObserveField:          r.ObserveField,
 	}
 }
 
 // NewRequestFromHTTP constructs a Request from an HTTP request. Requests may be GET requests using
 // query string parameters or POST requests with either the application/json or application/graphql
 // content type. If the request is malformed, an HTTP error code and error are returned.
+//
+// For GET requests, the variables and extensions parameters must be JSON-encoded. The query may be
+// omitted if an extension such as Apollo's persistedQuery provides it.
 func NewRequestFromHTTP(r *http.Request) (req *Request, code int, err error) {
+	return NewRequestFromHTTPWithJSONCodec(r, StandardJSONCodec)
+}
+
+// NewRequestFromHTTPWithJSONCodec is like NewRequestFromHTTP, but uses the given codec to decode
+// JSON.
+func NewRequestFromHTTPWithJSONCodec(r *http.Request, codec JSONCodec) (req *Request, code int, err error) {
 	req = &Request{
 		Context: r.Context(),
 	}
@@ -199,7 +361,7 @@ func NewRequestFromHTTP(r *http.Request) (req *Request, code int, err error) {
 		req.Query = r.URL.Query().Get("query")
 
 		if variables := r.URL.Query().Get("variables"); variables != "" {
-			if err := json.Unmarshal([]byte(variables), &req.VariableValues); err != nil {
+			if err := codec.Unmarshal([]byte(variables), &req.VariableValues); err != nil {
 				return nil, http.StatusBadRequest, fmt.Errorf("malformed variables parameter")
 			}
 		}
@@ -207,7 +369,7 @@ func NewRequestFromHTTP(r *http.Request) (req *Request, code int, err error) {
 		req.OperationName = r.URL.Query().Get("operationName")
 
 		if extensions := r.URL.Query().Get("extensions"); extensions != "" {
-			if err := json.Unmarshal([]byte(extensions), &req.Extensions); err != nil {
+			if err := codec.Unmarshal([]byte(extensions), &req.Extensions); err != nil {
 				return nil, http.StatusBadRequest, fmt.Errorf("malformed extensions parameter")
 			}
 		}
@@ -223,7 +385,9 @@ func NewRequestFromHTTP(r *http.Request) (req *Request, code int, err error) {
 				Extensions    map[string]interface{} `json:"extensions"`
 			}
 
-			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			if b, err := ioutil.ReadAll(r.Body); err != nil {
+				return nil, http.StatusBadRequest, fmt.Errorf("unable to read request body")
+			} else if err := codec.Unmarshal(b, &body); err != nil {
 				return nil, http.StatusBadRequest, fmt.Errorf("malformed request body")
 			}
 
@@ -252,9 +416,9 @@ type Location struct {
 
 // Error represents a GraphQL error as defined by the spec.
 type Error struct {
-	Message   string        `json:"message"`
-	Locations []Location    `json:"locations,omitempty"`
-	Path      []interface{} `json:"path,omitempty"`
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	Path      Path       `json:"path,omitempty"`
 
 	// To populate this field, your resolvers can return errors that implement ExtendedError.
 	Extensions map[string]interface{} `json:"extensions,omitempty"`
@@ -265,7 +429,8 @@ func (err *Error) Error() string {
 }
 
 // ExtendedError can be used to add data to a GraphQL error. If a resolver returns an error that
-// implements this interface, the error's extensions property will be populated.
+// implements this interface, or that wraps one, the error's extensions property will be populated.
+// See CodedError for a standard implementation.
 type ExtendedError interface {
 	error
 	Extensions() map[string]interface{}
@@ -273,8 +438,21 @@ type ExtendedError interface {
 
 // Response represents the result of executing a GraphQL query.
 type Response struct {
-	Data   *interface{} `json:"data,omitempty"`
-	Errors []*Error     `json:"errors,omitempty"`
+	Data       *interface{}           `json:"data,omitempty"`
+	Errors     []*Error               `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// NulledField describes a nullable field that was set to null due to an error. If
+// Request.ReportNulledFields is true, these are reported in the response's "nulledFields"
+// extension.
+type NulledField struct {
+	// The path of the field within the response data.
+	Path Path `json:"path"`
+
+	// The index of the error within the response's errors that caused the field to be nulled. If
+	// the error was omitted due to Request.MaxErrors, this is -1.
+	ErrorIndex int `json:"errorIndex"`
 }
 
 // IsSubscription returns true if the operation with the given name is a subscription operation.
@@ -304,22 +482,39 @@ func ParseAndValidate(query string, schema *Schema, features schema.FeatureSet,
 		return nil, errors
 	}
 	if validationErrs := validator.ValidateDocument(parsed, schema, features, additionalRules...); len(validationErrs) > 0 {
-		for _, err := range validationErrs {
-			locations := make([]Location, len(err.Locations))
-			for i, loc := range err.Locations {
-				locations[i].Line = loc.Line
-				locations[i].Column = loc.Column
-			}
-			errors = append(errors, &Error{
-				Message:   "Validation error: " + err.Message,
-				Locations: locations,
-			})
-		}
-		return nil, errors
+		return nil, newErrorsFromValidatorErrors(validationErrs)
 	}
 	return parsed, nil
 }
 
+// ApplyValidatorRules validates a document that has already passed ParseAndValidate against
+// additional rules. The type info must have been created by validator.NewTypeInfo for the same
+// document, schema, and features. This allows validated documents to be cached and checked against
+// request-specific rules, such as cost limits, without being fully revalidated.
+func ApplyValidatorRules(doc *ast.Document, schema *Schema, features FeatureSet, typeInfo *validator.TypeInfo, rules ...ValidatorRule) []*Error {
+	if validationErrs := validator.ApplyRules(doc, schema, features, typeInfo, rules...); len(validationErrs) > 0 {
+		return newErrorsFromValidatorErrors(validationErrs)
+	}
+	return nil
+}
+
+func newErrorsFromValidatorErrors(validationErrs []*validator.Error) []*Error {
+	errors := make([]*Error, len(validationErrs))
+	for i, err := range validationErrs {
+		locations := make([]Location, len(err.Locations))
+		for i, loc := range err.Locations {
+			locations[i].Line = loc.Line
+			locations[i].Column = loc.Column
+		}
+		errors[i] = &Error{
+			Message:    "Validation error: " + err.Message,
+			Locations:  locations,
+			Extensions: err.Extensions,
+		}
+	}
+	return errors
+}
+
 func newErrorFromExecutorError(err *executor.Error) *Error {
 	locations := make([]Location, len(err.Locations))
 	for i, loc := range err.Locations {
@@ -331,7 +526,8 @@ func newErrorFromExecutorError(err *executor.Error) *Error {
 		Locations: locations,
 		Path:      err.Path,
 	}
-	if ext, ok := err.Unwrap().(ExtendedError); ok {
+	var ext ExtendedError
+	if errors.As(err.Unwrap(), &ext) {
 		retErr.Extensions = ext.Extensions()
 	}
 	return retErr
@@ -366,18 +562,81 @@ func Execute(r *Request) *Response {
 		var errors []*Error
 		doc, errors = ParseAndValidate(r.Query, r.Schema, r.Features)
 		if len(errors) > 0 {
-			return &Response{
-				Errors: errors,
-			}
+			ret.Errors = errors
+			r.limitErrors(ret)
+			return ret
 		}
 	}
 
-	data, errs := executor.ExecuteRequest(r.Context, r.executorRequest(doc))
+	if r.InjectTypename {
+		doc = InjectTypename(doc)
+	}
+
+	executorRequest := r.executorRequest(doc)
+	var nulledFields []executor.NulledField
+	if r.ReportNulledFields {
+		executorRequest.NulledFields = &nulledFields
+	}
+
+	data, errs := executor.ExecuteRequest(r.Context, executorRequest)
 	var dataInterface interface{}
 	dataInterface = data
 	ret.Data = &dataInterface
 	for _, err := range errs {
 		ret.Errors = append(ret.Errors, newErrorFromExecutorError(err))
 	}
+	if r.ReportNulledFields {
+		report := make([]NulledField, len(nulledFields))
+		for i, field := range nulledFields {
+			report[i] = NulledField{
+				Path:       field.Path,
+				ErrorIndex: field.ErrorIndex,
+			}
+			if r.MaxErrors > 0 && field.ErrorIndex >= r.MaxErrors {
+				report[i].ErrorIndex = -1
+			}
+		}
+		ret.Extensions = map[string]interface{}{
+			"nulledFields": report,
+		}
+	}
+	if r.ReportDeprecations {
+		if warnings := DeprecationWarnings(doc, r.Schema, r.Features, r.OperationName); len(warnings) > 0 {
+			if ret.Extensions == nil {
+				ret.Extensions = map[string]interface{}{}
+			}
+			ret.Extensions["deprecations"] = warnings
+		}
+	}
+	if r.ReportSchemaWarnings {
+		if warnings := r.Schema.Warnings(); len(warnings) > 0 {
+			if ret.Extensions == nil {
+				ret.Extensions = map[string]interface{}{}
+			}
+			ret.Extensions["schemaWarnings"] = warnings
+		}
+	}
+	r.limitErrors(ret)
 	return ret
 }
+
+// Applies MaxErrors and MaxErrorMessageLength to the response.
+func (r *Request) limitErrors(resp *Response) {
+	if r.MaxErrors > 0 && len(resp.Errors) > r.MaxErrors {
+		if resp.Extensions == nil {
+			resp.Extensions = map[string]interface{}{}
+		}
+		resp.Extensions["suppressedErrors"] = len(resp.Errors) - r.MaxErrors
+		resp.Errors = resp.Errors[:r.MaxErrors]
+	}
+	if r.MaxErrorMessageLength > 0 {
+		for i, err := range resp.Errors {
+			if utf8.RuneCountInString(err.Message) <= r.MaxErrorMessageLength {
+				continue
+			}
+			truncated := *err
+			truncated.Message = string([]rune(err.Message)[:r.MaxErrorMessageLength]) + "..."
+			resp.Errors[i] = &truncated
+		}
+	}
+}
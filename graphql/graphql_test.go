@@ -1,13 +1,20 @@
 package graphql
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 	"testing"
 
+	"github.com/ccbrown/api-fu/graphql/ast"
 	"github.com/ccbrown/api-fu/graphql/executor"
+	"github.com/ccbrown/api-fu/graphql/parser"
+	"github.com/ccbrown/api-fu/graphql/schema"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -133,3 +140,503 @@ func TestNewErrorFromExecutorError(t *testing.T) {
 		},
 	}))
 }
+
+func TestExecute_ReportNulledFields(t *testing.T) {
+	s, err := NewSchema(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"ok": {
+					Type: IntType,
+					Resolve: func(FieldContext) (interface{}, error) {
+						return nil, nil
+					},
+				},
+				"error": {
+					Type: IntType,
+					Resolve: func(FieldContext) (interface{}, error) {
+						return nil, fmt.Errorf("error")
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	resp := Execute(&Request{
+		Context:            context.Background(),
+		Query:              `{ok error}`,
+		Schema:             s,
+		ReportNulledFields: true,
+	})
+	body, err := json.Marshal(resp)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"data": {"ok": null, "error": null},
+		"errors": [{"message": "error", "locations": [{"line": 1, "column": 5}], "path": ["error"]}],
+		"extensions": {"nulledFields": [{"path": ["error"], "errorIndex": 0}]}
+	}`, string(body))
+}
+
+func TestExecute_InjectTypename(t *testing.T) {
+	nodeType := &ObjectType{
+		Name: "Node",
+	}
+	nodeType.Fields = map[string]*FieldDefinition{
+		"id": {
+			Type: IntType,
+			Resolve: func(FieldContext) (interface{}, error) {
+				return 1, nil
+			},
+		},
+		"child": {
+			Type: nodeType,
+			Resolve: func(FieldContext) (interface{}, error) {
+				return struct{}{}, nil
+			},
+		},
+	}
+
+	s, err := NewSchema(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"node": {
+					Type: nodeType,
+					Resolve: func(FieldContext) (interface{}, error) {
+						return struct{}{}, nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	doc, errs := ParseAndValidate(`{
+		node { id child { __typename ...F } }
+		aliased: node { t: __typename }
+	}
+	fragment F on Node { child { id } }`, s, nil)
+	require.Empty(t, errs)
+
+	resp := Execute(&Request{
+		Context:        context.Background(),
+		Document:       doc,
+		Schema:         s,
+		InjectTypename: true,
+	})
+	body, err := json.Marshal(resp)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"data": {
+			"node": {"id": 1, "child": {"__typename": "Node", "child": {"id": 1, "__typename": "Node"}}, "__typename": "Node"},
+			"aliased": {"t": "Node", "__typename": "Node"}
+		}
+	}`, string(body))
+
+	// The cached document must not be modified.
+	resp = Execute(&Request{
+		Context:  context.Background(),
+		Document: doc,
+		Schema:   s,
+	})
+	body, err = json.Marshal(resp)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"data": {
+			"node": {"id": 1, "child": {"__typename": "Node", "child": {"id": 1}}},
+			"aliased": {"t": "Node"}
+		}
+	}`, string(body))
+}
+
+func TestFoldConstantDirectives(t *testing.T) {
+	s, err := NewSchema(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"int": {
+					Type: IntType,
+					Resolve: func(FieldContext) (interface{}, error) {
+						return 1, nil
+					},
+				},
+			},
+		},
+		Directives: map[string]*DirectiveDefinition{
+			"include": IncludeDirective,
+			"skip":    SkipDirective,
+		},
+	})
+	require.NoError(t, err)
+
+	const query = `query ($skip: Boolean!) {
+		a: int @skip(if: true)
+		b: int @include(if: true)
+		c: int @skip(if: $skip)
+		d: int @include(if: true) @skip(if: $skip)
+		... @include(if: false) { e: int }
+		...F @skip(if: false)
+	}
+	fragment F on Query { f: int }`
+	doc, errs := ParseAndValidate(query, s, nil)
+	require.Empty(t, errs)
+	original := ast.Print(doc)
+
+	folded := FoldConstantDirectives(doc, s)
+	assert.Equal(t, original, ast.Print(doc), "the original document must not be modified")
+
+	expected, parseErrs := parser.ParseDocument([]byte(`query ($skip: Boolean!) {
+		b: int
+		c: int @skip(if: $skip)
+		d: int @skip(if: $skip)
+		...F
+	}
+	fragment F on Query { f: int }`))
+	require.Empty(t, parseErrs)
+	assert.Equal(t, ast.Print(expected), ast.Print(folded))
+
+	resp := Execute(&Request{
+		Context:        context.Background(),
+		Document:       folded,
+		Schema:         s,
+		VariableValues: map[string]interface{}{"skip": true},
+	})
+	body, err := json.Marshal(resp)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data": {"b": 1, "f": 1}}`, string(body))
+}
+
+func TestExecute_ErrorCodes(t *testing.T) {
+	retryable := true
+	s, err := NewSchema(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"notFound": {
+					Type: IntType,
+					Resolve: func(FieldContext) (interface{}, error) {
+						return nil, NewCodedError(ErrorCodeNotFound, "Thing %v not found.", 1)
+					},
+				},
+				"unavailable": {
+					Type: IntType,
+					Resolve: func(FieldContext) (interface{}, error) {
+						return nil, fmt.Errorf("wrapped: %w", WrapCodedError(fmt.Errorf("connection refused"), ErrorCodeUnavailable, "Try again later."))
+					},
+				},
+				"retryableInternal": {
+					Type: IntType,
+					Resolve: func(FieldContext) (interface{}, error) {
+						return nil, &CodedError{
+							Code:      ErrorCodeInternal,
+							Message:   "Something went wrong.",
+							Retryable: &retryable,
+						}
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	resp := Execute(&Request{
+		Context: context.Background(),
+		Query:   `{notFound unavailable retryableInternal}`,
+		Schema:  s,
+	})
+	body, err := json.Marshal(resp)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"data": {"notFound": null, "unavailable": null, "retryableInternal": null},
+		"errors": [
+			{"message": "Thing 1 not found.", "locations": [{"line": 1, "column": 2}], "path": ["notFound"], "extensions": {"code": "NOT_FOUND", "retryable": false}},
+			{"message": "wrapped: Try again later.", "locations": [{"line": 1, "column": 11}], "path": ["unavailable"], "extensions": {"code": "UNAVAILABLE", "retryable": true}},
+			{"message": "Something went wrong.", "locations": [{"line": 1, "column": 23}], "path": ["retryableInternal"], "extensions": {"code": "INTERNAL", "retryable": true}}
+		]
+	}`, string(body))
+}
+
+func TestExecute_ReportDeprecations(t *testing.T) {
+	sunsetDirective := &DirectiveDefinition{
+		Arguments: map[string]*InputValueDefinition{
+			"sunset": {
+				Type: NewNonNullType(StringType),
+			},
+		},
+		Locations: []schema.DirectiveLocation{schema.DirectiveLocationFieldDefinition},
+	}
+	statusType := &EnumType{
+		Name: "Status",
+		Values: map[string]*EnumValueDefinition{
+			"ACTIVE": {
+				Value: 1,
+			},
+			"INACTIVE": {
+				Value:             0,
+				DeprecationReason: "Use ACTIVE instead.",
+			},
+		},
+	}
+	objectType := &ObjectType{
+		Name: "Object",
+	}
+	objectType.Fields = map[string]*FieldDefinition{
+		"old": {
+			Type:              IntType,
+			DeprecationReason: "Use new instead.",
+			Directives: []*Directive{
+				{
+					Definition: sunsetDirective,
+					Arguments: []*schema.Argument{
+						{
+							Name:  "sunset",
+							Value: "2030-01-01",
+						},
+					},
+				},
+			},
+			Resolve: func(FieldContext) (interface{}, error) {
+				return 1, nil
+			},
+		},
+		"object": {
+			Type: objectType,
+			Arguments: map[string]*InputValueDefinition{
+				"status": {
+					Type: statusType,
+				},
+			},
+			Resolve: func(FieldContext) (interface{}, error) {
+				return struct{}{}, nil
+			},
+		},
+	}
+	s, err := NewSchema(&SchemaDefinition{
+		Query: objectType,
+		Directives: map[string]*DirectiveDefinition{
+			"sunset": sunsetDirective,
+		},
+	})
+	require.NoError(t, err)
+
+	resp := Execute(&Request{
+		Context:            context.Background(),
+		Query:              `{object(status: INACTIVE) {old ...F} o: object {... on Object {old}}} fragment F on Object {old}`,
+		Schema:             s,
+		ReportDeprecations: true,
+	})
+	body, err := json.Marshal(resp)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"data": {"object": {"old": 1}, "o": {"old": 1}},
+		"extensions": {"deprecations": [
+			{"coordinate": "Status.INACTIVE", "path": ["object"], "reason": "Use ACTIVE instead.", "locations": [{"line": 1, "column": 17}]},
+			{"coordinate": "Object.old", "path": ["object", "old"], "reason": "Use new instead.", "sunset": "2030-01-01", "locations": [{"line": 1, "column": 28}, {"line": 1, "column": 93}]},
+			{"coordinate": "Object.old", "path": ["o", "old"], "reason": "Use new instead.", "sunset": "2030-01-01", "locations": [{"line": 1, "column": 64}]}
+		]}
+	}`, string(body))
+}
+
+func TestExecute_ReportSchemaWarnings(t *testing.T) {
+	s, err := NewSchema(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"foo": {
+					Type: IntType,
+					Resolve: func(FieldContext) (interface{}, error) {
+						return 1, nil
+					},
+				},
+			},
+		},
+		AdditionalTypes: []NamedType{
+			&ObjectType{
+				Name: "Unused",
+				Fields: map[string]*FieldDefinition{
+					"foo": {
+						Type: IntType,
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for name, report := range map[string]bool{
+		"Enabled":  true,
+		"Disabled": false,
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := Execute(&Request{
+				Context:              context.Background(),
+				Query:                `{foo}`,
+				Schema:               s,
+				ReportSchemaWarnings: report,
+			})
+			body, err := json.Marshal(resp)
+			require.NoError(t, err)
+			if report {
+				assert.JSONEq(t, `{
+					"data": {"foo": 1},
+					"extensions": {"schemaWarnings": [
+						{"path": ["type Unused"], "message": "type is unreachable from the root operation types"}
+					]}
+				}`, string(body))
+			} else {
+				assert.JSONEq(t, `{"data": {"foo": 1}}`, string(body))
+			}
+		})
+	}
+}
+
+func TestExecute_ErrorLimits(t *testing.T) {
+	itemType := &ObjectType{
+		Name: "Item",
+		Fields: map[string]*FieldDefinition{
+			"value": {
+				Type: IntType,
+				Resolve: func(FieldContext) (interface{}, error) {
+					return nil, fmt.Errorf("something went terribly wrong")
+				},
+			},
+		},
+	}
+	s, err := NewSchema(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"list": {
+					Type: NewListType(itemType),
+					Resolve: func(FieldContext) (interface{}, error) {
+						return []interface{}{1, 2, 3, 4}, nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	resp := Execute(&Request{
+		Context:               context.Background(),
+		Query:                 `{list{value}}`,
+		Schema:                s,
+		ReportNulledFields:    true,
+		MaxErrors:             2,
+		MaxErrorMessageLength: 10,
+	})
+	require.Len(t, resp.Errors, 2)
+	for _, err := range resp.Errors {
+		assert.Equal(t, "something ...", err.Message)
+	}
+	assert.Equal(t, 2, resp.Extensions["suppressedErrors"])
+	nulledFields := resp.Extensions["nulledFields"].([]NulledField)
+	require.Len(t, nulledFields, 4)
+	assert.Equal(t, 1, nulledFields[1].ErrorIndex)
+	assert.Equal(t, -1, nulledFields[3].ErrorIndex)
+}
+
+func TestExecute_InputValueConstraints(t *testing.T) {
+	one := 1.0
+	s, err := NewSchema(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"echo": {
+					Type: StringType,
+					Arguments: map[string]*InputValueDefinition{
+						"s": {
+							Type: NewNonNullType(StringType),
+							Constraints: &InputValueConstraints{
+								MaxLength: 3,
+								Pattern:   regexp.MustCompile(`^[a-z]*$`),
+							},
+						},
+						"n": {
+							Type: IntType,
+							Constraints: &InputValueConstraints{
+								Min: &one,
+							},
+						},
+						"obj": {
+							Type: &InputObjectType{
+								Name: "EchoInput",
+								Fields: map[string]*InputValueDefinition{
+									"s": {
+										Type: StringType,
+										Constraints: &InputValueConstraints{
+											Validate: func(v interface{}) error {
+												if v == "bad" {
+													return fmt.Errorf("must not be bad")
+												}
+												return nil
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+					Resolve: func(ctx FieldContext) (interface{}, error) {
+						return ctx.Arguments["s"], nil
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Query     string
+		Variables map[string]interface{}
+		Expected  string
+	}{
+		"Valid": {
+			Query:    `{echo(s: "abc", n: 1, obj: {s: "good"})}`,
+			Expected: `{"data":{"echo":"abc"}}`,
+		},
+		"MaxLength": {
+			Query:    `{echo(s: "abcd")}`,
+			Expected: `{"data":{"echo":null},"errors":[{"message":"Invalid s argument: must have a length of at most 3","locations":[{"line":1,"column":10}]}]}`,
+		},
+		"Pattern": {
+			Query:    `{echo(s: "ABC")}`,
+			Expected: `{"data":{"echo":null},"errors":[{"message":"Invalid s argument: must match the pattern ^[a-z]*$","locations":[{"line":1,"column":10}]}]}`,
+		},
+		"Variable": {
+			Query:     `query ($n: Int) {echo(s: "abc", n: $n)}`,
+			Variables: map[string]interface{}{"n": 0},
+			Expected:  `{"data":{"echo":null},"errors":[{"message":"Invalid n argument: must be at least 1","locations":[{"line":1,"column":36}]}]}`,
+		},
+		"InputObjectField": {
+			Query:    `{echo(s: "abc", obj: {s: "bad"})}`,
+			Expected: `{"data":{"echo":null},"errors":[{"message":"Invalid argument value: invalid s field: must not be bad","locations":[{"line":1,"column":22}]}]}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := Execute(&Request{
+				Context:        context.Background(),
+				Query:          tc.Query,
+				Schema:         s,
+				VariableValues: tc.Variables,
+			})
+			body, err := json.Marshal(resp)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.Expected, string(body))
+		})
+	}
+
+	t.Run("CostValidation", func(t *testing.T) {
+		req := &Request{
+			Query:          `query ($n: Int) {echo(s: "abc", n: $n)}`,
+			Schema:         s,
+			VariableValues: map[string]interface{}{"n": 0},
+		}
+		var cost int
+		_, errs := ParseAndValidate(req.Query, req.Schema, nil, req.ValidateCost(-1, &cost, FieldCost{}))
+		require.Len(t, errs, 1)
+		assert.Equal(t, "Validation error: Invalid n argument: must be at least 1", errs[0].Message)
+		assert.Equal(t, []Location{{Line: 1, Column: 36}}, errs[0].Locations)
+	})
+}
@@ -0,0 +1,121 @@
+package graphql
+
+import (
+	"net/http"
+	"strings"
+)
+
+// The media types of JSON responses. GraphQLResponseContentType is defined by the GraphQL over HTTP
+// specification. Unlike application/json, it tells clients that the body is a GraphQL response even
+// if the status code isn't 2xx.
+const (
+	JSONContentType            = "application/json"
+	GraphQLResponseContentType = "application/graphql-response+json"
+)
+
+// NegotiateJSONContentType returns the media type that should be used for a JSON response given the
+// request's Accept header values. If the client accepts GraphQLResponseContentType at least as
+// readily as application/json, it's returned. Otherwise, JSONContentType is returned, which is what
+// legacy clients expect.
+func NegotiateJSONContentType(accept []string) string {
+	jsonQuality, graphqlResponseQuality := 0.0, 0.0
+	forEachMediaRange(accept, func(mediaType string, quality float64) {
+		switch mediaType {
+		case JSONContentType:
+			if quality > jsonQuality {
+				jsonQuality = quality
+			}
+		case GraphQLResponseContentType:
+			if quality > graphqlResponseQuality {
+				graphqlResponseQuality = quality
+			}
+		}
+	})
+	if graphqlResponseQuality > 0 && graphqlResponseQuality >= jsonQuality {
+		return GraphQLResponseContentType
+	}
+	return JSONContentType
+}
+
+// ResponseHTTPStatus returns the HTTP status code conventionally used for the given response:
+//
+//   - If the response has data, even if it's partial or null due to errors, the status is 200.
+//   - If the request failed before execution and all of the errors have the same ErrorCode, the
+//     code determines the status as described below.
+//   - Otherwise, if the request failed before execution, e.g. due to a validation error, the status
+//     is 400.
+//
+// The status is 401 for ErrorCodeUnauthenticated, 403 for ErrorCodeForbidden and
+// ErrorCodeOperationTypeNotAllowed, 404 for ErrorCodeNotFound and ErrorCodeOperationNotFound, 410
+// for ErrorCodeFieldSunset, 413 for ErrorCodePayloadTooLarge, 500 for ErrorCodeInternal, 503 for
+// ErrorCodeUnavailable, and 504 for ErrorCodeTimeout. Other codes, such as ErrorCodeBadUserInput
+// and the persisted query codes, use 400.
+//
+// Clients using application/json may not expect non-2xx statuses, so this is typically only used
+// for responses with GraphQLResponseContentType.
+func ResponseHTTPStatus(resp *Response) int {
+	if resp == nil || resp.Data != nil || len(resp.Errors) == 0 {
+		return http.StatusOK
+	}
+	code, _ := resp.Errors[0].Extensions["code"].(string)
+	for _, err := range resp.Errors[1:] {
+		if other, _ := err.Extensions["code"].(string); other != code {
+			code = ""
+			break
+		}
+	}
+	switch ErrorCode(code) {
+	case ErrorCodeUnauthenticated:
+		return http.StatusUnauthorized
+	case ErrorCodeForbidden, ErrorCodeOperationTypeNotAllowed:
+		return http.StatusForbidden
+	case ErrorCodeNotFound, ErrorCodeOperationNotFound:
+		return http.StatusNotFound
+	case ErrorCodeFieldSunset:
+		return http.StatusGone
+	case ErrorCodePayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case ErrorCodeInternal:
+		return http.StatusInternalServerError
+	case ErrorCodeUnavailable:
+		return http.StatusServiceUnavailable
+	case ErrorCodeTimeout:
+		return http.StatusGatewayTimeout
+	case ErrorCodeBadUserInput, ErrorCodePersistedQueryNotFound, ErrorCodePersistedQueryHashMismatch, ErrorCodePersistedQueryNotSupported:
+		return http.StatusBadRequest
+	}
+	return http.StatusBadRequest
+}
+
+// IsAcceptable returns true if the given Accept header values accept the given media type, either
+// explicitly or via a wildcard such as "*/*". The most specific matching media range determines
+// whether the type is accepted, so "application/json;q=0" rejects JSON even if "*/*" is present.
+// If there are no values, all media types are acceptable.
+func IsAcceptable(accept []string, mediaType string) bool {
+	if len(accept) == 0 {
+		return true
+	}
+	mediaType = strings.ToLower(mediaType)
+	typeWildcard := mediaType
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		typeWildcard = mediaType[:i] + "/*"
+	}
+	// The qualities of the exact, type wildcard, and full wildcard matches, or -1 if absent.
+	qualities := [3]float64{-1, -1, -1}
+	forEachMediaRange(accept, func(mediaRange string, quality float64) {
+		switch mediaRange {
+		case mediaType:
+			qualities[0] = quality
+		case typeWildcard:
+			qualities[1] = quality
+		case "*/*":
+			qualities[2] = quality
+		}
+	})
+	for _, quality := range qualities {
+		if quality >= 0 {
+			return quality > 0
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package graphql
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateJSONContentType(t *testing.T) {
+	for name, tc := range map[string]struct {
+		Accept   []string
+		Expected string
+	}{
+		"None":            {nil, JSONContentType},
+		"JSON":            {[]string{"application/json"}, JSONContentType},
+		"Wildcard":        {[]string{"*/*"}, JSONContentType},
+		"GraphQLResponse": {[]string{"application/graphql-response+json, application/json"}, GraphQLResponseContentType},
+		"PreferJSON":      {[]string{"application/graphql-response+json;q=0.9, application/json"}, JSONContentType},
+		"Quality":         {[]string{"application/json;q=0.5", "application/graphql-response+json;q=0.8"}, GraphQLResponseContentType},
+		"Rejected":        {[]string{"application/graphql-response+json;q=0"}, JSONContentType},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, NegotiateJSONContentType(tc.Accept))
+		})
+	}
+}
+
+func TestResponseHTTPStatus(t *testing.T) {
+	var null interface{}
+	coded := func(code ErrorCode) *Error {
+		return &Error{
+			Message:    "error",
+			Extensions: NewCodedError(code, "error").Extensions(),
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		Response *Response
+		Expected int
+	}{
+		"Data":             {&Response{Data: &null}, http.StatusOK},
+		"PartialData":      {&Response{Data: &null, Errors: []*Error{coded(ErrorCodeUnauthenticated)}}, http.StatusOK},
+		"ValidationError":  {&Response{Errors: []*Error{{Message: "Validation error"}}}, http.StatusBadRequest},
+		"Unauthenticated":  {&Response{Errors: []*Error{coded(ErrorCodeUnauthenticated), coded(ErrorCodeUnauthenticated)}}, http.StatusUnauthorized},
+		"Forbidden":        {&Response{Errors: []*Error{coded(ErrorCodeForbidden)}}, http.StatusForbidden},
+		"Internal":         {&Response{Errors: []*Error{coded(ErrorCodeInternal)}}, http.StatusInternalServerError},
+		"Unavailable":      {&Response{Errors: []*Error{coded(ErrorCodeUnavailable)}}, http.StatusServiceUnavailable},
+		"NotFound":         {&Response{Errors: []*Error{coded(ErrorCodeNotFound)}}, http.StatusNotFound},
+		"OperationMissing": {&Response{Errors: []*Error{coded(ErrorCodeOperationNotFound)}}, http.StatusNotFound},
+		"TypeNotAllowed":   {&Response{Errors: []*Error{coded(ErrorCodeOperationTypeNotAllowed)}}, http.StatusForbidden},
+		"FieldSunset":      {&Response{Errors: []*Error{coded(ErrorCodeFieldSunset)}}, http.StatusGone},
+		"PayloadTooLarge":  {&Response{Errors: []*Error{coded(ErrorCodePayloadTooLarge)}}, http.StatusRequestEntityTooLarge},
+		"Timeout":          {&Response{Errors: []*Error{coded(ErrorCodeTimeout)}}, http.StatusGatewayTimeout},
+		"BadUserInput":     {&Response{Errors: []*Error{coded(ErrorCodeBadUserInput)}}, http.StatusBadRequest},
+		"PersistedQuery":   {&Response{Errors: []*Error{coded(ErrorCodePersistedQueryNotFound)}}, http.StatusBadRequest},
+		"MixedCodes":       {&Response{Errors: []*Error{coded(ErrorCodeUnauthenticated), coded(ErrorCodeForbidden)}}, http.StatusBadRequest},
+		"PartiallyUncoded": {&Response{Errors: []*Error{coded(ErrorCodeUnauthenticated), {Message: "error"}}}, http.StatusBadRequest},
+		"UnrecognizedCode": {&Response{Errors: []*Error{{Message: "error", Extensions: map[string]interface{}{"code": "UNKNOWN"}}}}, http.StatusBadRequest},
+		"NoErrorsOrData":   {&Response{}, http.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, ResponseHTTPStatus(tc.Response))
+		})
+	}
+}
+
+func TestIsAcceptable(t *testing.T) {
+	for name, tc := range map[string]struct {
+		Accept   []string
+		Expected bool
+	}{
+		"None":              {nil, true},
+		"Exact":             {[]string{"application/json"}, true},
+		"CaseInsensitive":   {[]string{"Application/JSON"}, true},
+		"Wildcard":          {[]string{"*/*"}, true},
+		"TypeWildcard":      {[]string{"application/*"}, true},
+		"Other":             {[]string{"text/html"}, false},
+		"OtherTypeWildcard": {[]string{"text/*"}, false},
+		"Rejected":          {[]string{"application/json;q=0, */*"}, false},
+		"RejectedWildcard":  {[]string{"*/*;q=0, application/json;q=0.1"}, true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, IsAcceptable(tc.Accept, JSONContentType))
+		})
+	}
+}
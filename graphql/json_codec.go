@@ -0,0 +1,27 @@
+package graphql
+
+import (
+	"encoding/json"
+)
+
+// JSONCodec encodes and decodes JSON. It can be used to replace encoding/json with a faster
+// implementation such as jsoniter, go-json, or sonic.
+//
+// Implementations must honor json.Marshaler and the json struct tags used by this package's types.
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// StandardJSONCodec is a JSONCodec that uses encoding/json.
+var StandardJSONCodec JSONCodec = standardJSONCodec{}
+
+type standardJSONCodec struct{}
+
+func (standardJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (standardJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
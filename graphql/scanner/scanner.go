@@ -135,7 +135,7 @@ func (s *Scanner) Scan() bool {
 		case '\t', ' ':
 			s.consumeRune()
 			s.token = token.WHITE_SPACE
-		case '!', '$', '(', ')', ':', '=', '@', '[', ']', '{', '|', '}':
+		case '!', '$', '&', '(', ')', ':', '=', '@', '[', ']', '{', '|', '}':
 			s.consumeRune()
 			s.token = token.PUNCTUATOR
 		case ',':
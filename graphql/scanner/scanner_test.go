@@ -45,6 +45,16 @@ func TestScanner(t *testing.T) {
 	assert.Empty(t, s.Errors())
 }
 
+func TestScanner_Ampersand(t *testing.T) {
+	s := New([]byte(`A & B`), 0)
+	var literals []string
+	for s.Scan() {
+		literals = append(literals, s.Literal())
+	}
+	assert.Equal(t, []string{"A", "&", "B"}, literals)
+	assert.Empty(t, s.Errors())
+}
+
 func TestScanner_IllegalCharacter(t *testing.T) {
 	s := New([]byte(`{😃}`), 0)
 	var tokens []token.Token
@@ -253,6 +253,18 @@ var SkipDirective = &DirectiveDefinition{
 	},
 }
 
+// SpecifiedByDirective implements the @specifiedBy directive as defined by the GraphQL spec. Scalars
+// specify their URLs via ScalarType.SpecifiedByURL rather than by applying this directive.
+var SpecifiedByDirective = &DirectiveDefinition{
+	Description: "The @specifiedBy directive is used within the type system definition language to provide a URL for specifying the behavior of custom scalar types.",
+	Arguments: map[string]*InputValueDefinition{
+		"url": {
+			Type: NewNonNullType(StringType),
+		},
+	},
+	Locations: []DirectiveLocation{DirectiveLocationScalar},
+}
+
 // IncludeDirective implements the @include directive as defined by the GraphQL spec.
 var IncludeDirective = &DirectiveDefinition{
 	Description: "The @include directive may be provided for fields, fragment spreads, and inline fragments, and allows for conditional inclusion during execution as described by the if argument.",
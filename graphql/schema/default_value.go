@@ -0,0 +1,68 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Returns an error if v can't be used as a default value of the given type. Default values are
+// stored in their coerced form, so this checks that they can be converted back into results of
+// the type, which is required to serialize them for introspection.
+func checkDefaultValue(t Type, v interface{}) error {
+	if v == nil || v == Null {
+		if _, ok := t.(*NonNullType); ok {
+			return fmt.Errorf("null is not a valid %v", t)
+		}
+		return nil
+	}
+
+	switch t := t.(type) {
+	case *NonNullType:
+		return checkDefaultValue(t.Type, v)
+	case *ScalarType:
+		if t.ResultCoercion != nil && t.ResultCoercion(v) == nil {
+			return fmt.Errorf("%#v is not a valid %v", v, t)
+		}
+	case *EnumType:
+		if _, err := t.CoerceResult(v); err != nil {
+			return err
+		}
+	case *ListType:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return fmt.Errorf("%#v is not a valid %v", v, t)
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := checkDefaultValue(t.Type, rv.Index(i).Interface()); err != nil {
+				return fmt.Errorf("item %v: %w", i, err)
+			}
+		}
+	case *InputObjectType:
+		if t.ResultCoercion == nil {
+			// This is reported by InputValueDefinition.shallowValidate.
+			return nil
+		}
+		fields, err := t.ResultCoercion(v)
+		if err != nil {
+			return err
+		}
+		for name := range fields {
+			if _, ok := t.Fields[name]; !ok {
+				return fmt.Errorf("%v has no field named %v", t, name)
+			}
+		}
+		for name, field := range t.Fields {
+			value, ok := fields[name]
+			if !ok {
+				if _, isNonNull := field.Type.(*NonNullType); isNonNull && field.DefaultValue == nil {
+					return fmt.Errorf("field %v: missing required field", name)
+				}
+				continue
+			}
+			if err := checkDefaultValue(field.Type, value); err != nil {
+				return fmt.Errorf("field %v: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -1,9 +1,6 @@
 package schema
 
-import (
-	"fmt"
-	"strings"
-)
+import "strings"
 
 type DirectiveLocation string
 
@@ -34,6 +31,9 @@ type DirectiveDefinition struct {
 	Arguments   map[string]*InputValueDefinition
 	Locations   []DirectiveLocation
 
+	// If true, the directive may be used more than once at the same location.
+	IsRepeatable bool
+
 	// If non-nil, this function will be invoked during field collection for each selection with
 	// this directive present. If the function returns false, the selection will be skipped.
 	FieldCollectionFilter func(arguments map[string]interface{}) bool
@@ -57,18 +57,20 @@ func referencesDirective(node interface{}, directive *DirectiveDefinition) bool
 	return foundReference
 }
 
-func (d *DirectiveDefinition) shallowValidate() error {
+func (d *DirectiveDefinition) shallowValidate() []*ValidationError {
+	var errs []*ValidationError
 	for name, arg := range d.Arguments {
+		path := []string{"argument " + name}
 		if !isName(name) || strings.HasPrefix(name, "__") {
-			return fmt.Errorf("illegal directive argument name: %v", name)
+			errs = append(errs, newValidationError(path, "illegal directive argument name: %v", name))
 		} else if referencesDirective(arg, d) {
-			return fmt.Errorf("directive is self-referencing via %v argument", name)
+			errs = append(errs, newValidationError(path, "directive is self-referencing via %v argument", name))
 		}
 	}
 	if len(d.Locations) == 0 {
-		return fmt.Errorf("directives must have one or more locations")
+		errs = append(errs, newValidationError(nil, "directives must have one or more locations"))
 	}
-	return nil
+	return errs
 }
 
 type Directive struct {
@@ -51,17 +51,17 @@ func (t *EnumType) TypeName() string {
 	return t.Name
 }
 
-func (t *EnumType) shallowValidate() error {
+func (t *EnumType) shallowValidate() []*ValidationError {
 	if len(t.Values) == 0 {
-		return fmt.Errorf("%v must have at least one field", t.Name)
-	} else {
-		for name := range t.Values {
-			if !isName(name) || name == "true" || name == "false" || name == "null" {
-				return fmt.Errorf("illegal field name: %v", name)
-			}
+		return []*ValidationError{newValidationError(nil, "%v must have at least one field", t.Name)}
+	}
+	var errs []*ValidationError
+	for name := range t.Values {
+		if !isName(name) || name == "true" || name == "false" || name == "null" {
+			errs = append(errs, newValidationError([]string{"value " + name}, "illegal field name: %v", name))
 		}
 	}
-	return nil
+	return errs
 }
 
 func (t *EnumType) CoerceVariableValue(v interface{}) (interface{}, error) {
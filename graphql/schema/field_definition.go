@@ -2,8 +2,8 @@ package schema
 
 import (
 	"context"
-	"fmt"
 	"strings"
+	"time"
 )
 
 // FieldContext contains important context passed to resolver implementations.
@@ -14,6 +14,12 @@ type FieldContext struct {
 	Features  FeatureSet
 	Arguments map[string]interface{}
 
+	// The names of the arguments that were explicitly given, either as literals or as variables
+	// with values. Explicit nulls are present in Arguments with nil values, but omitted arguments
+	// with defaults are indistinguishable from explicit ones without this. This is useful for
+	// PATCH-like mutations.
+	ArgumentPresence map[string]bool
+
 	// IsSubscribe is true if this is a subscription field being invoked for a subscribe operation.
 	// Subselections of this field will not be executed, and the return value will be returned
 	// immediately to the caller of Subscribe.
@@ -33,6 +39,16 @@ type FieldCost struct {
 	// return arrays, this is typically the number of expected results (e.g. the "first" or "last"
 	// argument to a connection field). Defaults to 1 if not set.
 	Multiplier int
+
+	// Costs in additional dimensions, such as database reads or external calls, keyed by dimension
+	// name. Like Resolver, these are multiplied by the multipliers of the field's ancestors. This
+	// allows rate limits to be expressed separately for different backends.
+	Dimensions map[string]int
+
+	// The names of any variables read via FieldCostContext.VariableValues. Variables referenced by
+	// the field's arguments, including those nested within input objects and lists, are tracked
+	// automatically, so this is only needed for variables read directly.
+	Variables []string
 }
 
 // Returns a cost function which returns a constant resolver cost with no multiplier.
@@ -48,8 +64,13 @@ func FieldResolverCost(n int) func(FieldCostContext) FieldCost {
 type FieldCostContext struct {
 	Context context.Context
 
-	// The arguments that were provided.
+	// The arguments that were provided. Variables within the arguments, including those nested
+	// within input objects and lists, have already been substituted.
 	Arguments map[string]interface{}
+
+	// The operation's coerced variable values. Cost functions that read these directly must
+	// declare the variables they read via FieldCost.Variables.
+	VariableValues map[string]interface{}
 }
 
 // FieldDefinition defines an object's field.
@@ -60,6 +81,13 @@ type FieldDefinition struct {
 	Directives        []*Directive
 	DeprecationReason string
 
+	// If non-zero, the field is retired at this time. Fields with sunset times must be deprecated,
+	// and should be deprecated well in advance so that clients have time to migrate. The sunset
+	// time is exposed via the non-standard "sunsetTime" introspection field and reported to clients
+	// that use the field via the "sunset" property of deprecation warnings. Once it passes,
+	// operations using the field can be rejected via validator.ValidateSunsets.
+	SunsetTime time.Time
+
 	// This field is only available for introspection and use when the given features are enabled.
 	RequiredFeatures FeatureSet
 
@@ -68,20 +96,50 @@ type FieldDefinition struct {
 	// metering.
 	Cost func(FieldCostContext) FieldCost
 
+	// The name of an authorization policy which must permit access to the field. Policies aren't
+	// evaluated by this package. Rather, this is metadata for higher level libraries such as apifu
+	// to act on.
+	Policy string
+
+	// Resolves the field's value. For list fields, the result may be a slice, an iterator function
+	// of the form func() (T, bool), or a channel. Iterators and channels are consumed one item at a
+	// time as the list is completed, so resolvers don't need to build an intermediate slice, but
+	// every completed item is still held in the response. They aren't consumed any further if an
+	// error prevents the list from being completed or the ListLimit is reached.
+	//
+	// Iterators are invoked on the executor's goroutine. Channels are received from without
+	// blocking: if no item is ready, the list waits like a field with a pending ResolvePromise while
+	// other fields are resolved, and the executor only blocks on the channel once nothing else can
+	// make progress. Producers should watch the context to know when to give up.
+	//
+	// Interface fields may also define Resolve. Implementing object types inherit it for fields that
+	// they declare without a Resolve function. See ObjectType.FieldResolver.
 	Resolve func(FieldContext) (interface{}, error)
+
+	// If given, this is invoked after the field is resolved to get the maximum number of items to
+	// include in the field's list, typically based on an argument such as "first". Any further
+	// items are omitted, and iterators and channels aren't consumed past the limit. If it returns a
+	// negative number, the list isn't limited. For lists of lists, only the outer list is limited.
+	ListLimit func(FieldContext) int
 }
 
-func (d *FieldDefinition) shallowValidate() error {
+func (d *FieldDefinition) shallowValidate() []*ValidationError {
 	if d.Type == nil {
-		return fmt.Errorf("field is missing type")
+		return []*ValidationError{newValidationError(nil, "field is missing type")}
 	} else if !d.Type.IsOutputType() {
-		return fmt.Errorf("%v cannot be used as a field type", d.Type)
-	} else {
-		for name := range d.Arguments {
-			if !isName(name) || strings.HasPrefix(name, "__") {
-				return fmt.Errorf("illegal field argument name: %v", name)
-			}
+		return []*ValidationError{newValidationError(nil, "%v cannot be used as a field type", d.Type)}
+	}
+	var errs []*ValidationError
+	for name := range d.Arguments {
+		if !isName(name) || strings.HasPrefix(name, "__") {
+			errs = append(errs, newValidationError([]string{"argument " + name}, "illegal field argument name: %v", name))
 		}
 	}
-	return nil
+	if !d.SunsetTime.IsZero() && d.DeprecationReason == "" {
+		errs = append(errs, newValidationError(nil, "fields with sunset times must be deprecated"))
+	}
+	if d.ListLimit != nil && !IsListType(NullableType(d.Type)) {
+		errs = append(errs, newValidationError(nil, "only list fields can have list limits"))
+	}
+	return errs
 }
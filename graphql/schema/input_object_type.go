@@ -67,6 +67,8 @@ func (t *InputObjectType) CoerceVariableValue(v interface{}) (interface{}, error
 			if fieldValue, ok := v[name]; ok {
 				if coerced, err := CoerceVariableValue(fieldValue, field.Type); err != nil {
 					return nil, err
+				} else if err := field.CheckConstraints(coerced); err != nil {
+					return nil, fmt.Errorf("invalid %v field: %w", name, err)
 				} else {
 					result[name] = coerced
 				}
@@ -110,6 +112,8 @@ func (t *InputObjectType) CoerceLiteral(node *ast.ObjectValue, variableValues ma
 			}
 			if coerced, err := CoerceLiteral(field.Value, fieldDef.Type, variableValues); err != nil {
 				return nil, err
+			} else if err := fieldDef.CheckConstraints(coerced); err != nil {
+				return nil, fmt.Errorf("invalid %v field: %w", name, err)
 			} else {
 				result[name] = coerced
 			}
@@ -133,20 +137,23 @@ func (t *InputObjectType) CoerceLiteral(node *ast.ObjectValue, variableValues ma
 	return result, nil
 }
 
-func (t *InputObjectType) shallowValidate() error {
+func (t *InputObjectType) shallowValidate() []*ValidationError {
 	if len(t.Fields) == 0 {
-		return fmt.Errorf("%v must have at least one field", t.Name)
-	} else {
-		for name, field := range t.Fields {
-			if !isName(name) || strings.HasPrefix(name, "__") {
-				return fmt.Errorf("illegal field name: %v", name)
-			} else if !field.Type.IsInputType() {
-				return fmt.Errorf("%v field must be an input type", name)
-			} else if !field.Type.TypeRequiredFeatures().IsSubsetOf(t.RequiredFeatures) {
-				// TODO: support conditional input fields?
-				return fmt.Errorf("%v field type has additional required features, but conditional input fields are not currently supported", name)
-			}
+		return []*ValidationError{newValidationError(nil, "%v must have at least one field", t.Name)}
+	}
+	var errs []*ValidationError
+	for name, field := range t.Fields {
+		path := []string{"field " + name}
+		if !isName(name) || strings.HasPrefix(name, "__") {
+			errs = append(errs, newValidationError(path, "illegal field name: %v", name))
+		} else if field.Type == nil {
+			// reported by the field itself
+		} else if !field.Type.IsInputType() {
+			errs = append(errs, newValidationError(path, "%v field must be an input type", name))
+		} else if !field.Type.TypeRequiredFeatures().IsSubsetOf(t.RequiredFeatures) {
+			// TODO: support conditional input fields?
+			errs = append(errs, newValidationError(path, "%v field type has additional required features, but conditional input fields are not currently supported", name))
 		}
 	}
-	return nil
+	return errs
 }
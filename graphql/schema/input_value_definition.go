@@ -1,6 +1,13 @@
 package schema
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"unicode/utf8"
+)
 
 // InputValueDefinition defines an input value such as an argument.
 type InputValueDefinition struct {
@@ -11,6 +18,109 @@ type InputValueDefinition struct {
 	DefaultValue interface{}
 
 	Directives []*Directive
+
+	// If non-empty, the input value is deprecated. Required input values, i.e. non-null ones
+	// without defaults, can't be deprecated.
+	DeprecationReason string
+
+	// If given, values are checked against these constraints after they're coerced.
+	Constraints *InputValueConstraints
+}
+
+// InputValueConstraints define restrictions on input values beyond those imposed by their types.
+// Constraints are never applied to null values.
+type InputValueConstraints struct {
+	// For strings, these are the minimum and maximum number of characters. For lists, they're the
+	// minimum and maximum number of items. Zero means no limit.
+	MinLength int
+	MaxLength int
+
+	// If given, strings must match this pattern. Typically you'll want to anchor it with ^ and $.
+	Pattern *regexp.Regexp
+
+	// If given, numbers must be within these bounds (inclusive). This applies to values of any
+	// integer or floating point kind, including those produced by custom scalars.
+	Min *float64
+	Max *float64
+
+	// If given, this is invoked with the coerced value after all other constraints have been
+	// checked.
+	Validate func(value interface{}) error
+}
+
+// ConstraintViolationError is returned when an input value doesn't satisfy its constraints.
+type ConstraintViolationError struct {
+	Message string
+}
+
+func (err *ConstraintViolationError) Error() string {
+	return err.Message
+}
+
+func newConstraintViolationError(message string, args ...interface{}) *ConstraintViolationError {
+	return &ConstraintViolationError{
+		Message: fmt.Sprintf(message, args...),
+	}
+}
+
+// CheckConstraints returns a *ConstraintViolationError if the given coerced value doesn't satisfy
+// the definition's constraints.
+func (d *InputValueDefinition) CheckConstraints(value interface{}) error {
+	c := d.Constraints
+	if c == nil || value == nil {
+		return nil
+	}
+
+	length := -1
+	switch v := value.(type) {
+	case string:
+		length = utf8.RuneCountInString(v)
+		if c.Pattern != nil && !c.Pattern.MatchString(v) {
+			return newConstraintViolationError("must match the pattern %v", c.Pattern)
+		}
+	case []interface{}:
+		length = len(v)
+	}
+	if length >= 0 {
+		if c.MinLength > 0 && length < c.MinLength {
+			return newConstraintViolationError("must have a length of at least %v", c.MinLength)
+		} else if c.MaxLength > 0 && length > c.MaxLength {
+			return newConstraintViolationError("must have a length of at most %v", c.MaxLength)
+		}
+	}
+
+	if n := numericValue(value); n != nil {
+		if c.Min != nil && n.Cmp(big.NewFloat(*c.Min)) < 0 {
+			return newConstraintViolationError("must be at least %v", *c.Min)
+		} else if c.Max != nil && n.Cmp(big.NewFloat(*c.Max)) > 0 {
+			return newConstraintViolationError("must be at most %v", *c.Max)
+		}
+	}
+
+	if c.Validate != nil {
+		if err := c.Validate(value); err != nil {
+			return &ConstraintViolationError{Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// Returns the value as a big.Float if it's an integer or floating point number of any kind, such as
+// the int64s produced by custom scalars. Otherwise nil is returned. Integers are represented
+// exactly, so they're compared against bounds without losing precision.
+func numericValue(value interface{}) *big.Float {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Float).SetInt64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Float).SetUint64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); !math.IsNaN(f) {
+			return big.NewFloat(f)
+		}
+	}
+	return nil
 }
 
 type explicitNull struct{}
@@ -18,15 +128,18 @@ type explicitNull struct{}
 // Null is to specify an explicit "null" default for input values.
 var Null = (*explicitNull)(nil)
 
-func (d *InputValueDefinition) shallowValidate() error {
+func (d *InputValueDefinition) shallowValidate() []*ValidationError {
 	if d.Type == nil {
-		return fmt.Errorf("input value is missing type")
+		return []*ValidationError{newValidationError(nil, "input value is missing type")}
 	} else if !d.Type.IsInputType() {
-		return fmt.Errorf("%v cannot be used as an input value type", d.Type)
+		return []*ValidationError{newValidationError(nil, "%v cannot be used as an input value type", d.Type)}
+	}
+	if d.DeprecationReason != "" && IsNonNullType(d.Type) && d.DefaultValue == nil {
+		return []*ValidationError{newValidationError(nil, "required input values cannot be deprecated")}
 	}
 	if d.DefaultValue != nil && d.DefaultValue != Null {
 		if obj, ok := d.Type.(*InputObjectType); ok && obj.ResultCoercion == nil {
-			return fmt.Errorf("assigning a default value to a %v requires it to define a result coercion function", d.Type)
+			return []*ValidationError{newValidationError(nil, "assigning a default value to a %v requires it to define a result coercion function", d.Type)}
 		}
 	}
 	return nil
@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInputValueDefinition_CheckConstraints(t *testing.T) {
+	type count int64
+
+	min := -1.0
+	max := 10.0
+	bounded := &InputValueDefinition{
+		Type: IntType,
+		Constraints: &InputValueConstraints{
+			Min: &min,
+			Max: &max,
+		},
+	}
+
+	// 2^53 can be represented exactly as a float64, but 2^53 + 1 can't.
+	maxSafe := float64(1 << 53)
+	precise := &InputValueDefinition{
+		Type: IntType,
+		Constraints: &InputValueConstraints{
+			Max: &maxSafe,
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		Definition *InputValueDefinition
+		Value      interface{}
+		Okay       bool
+	}{
+		"Int":             {bounded, 10, true},
+		"IntTooLarge":     {bounded, 11, false},
+		"Int64":           {bounded, int64(-1), true},
+		"Int64TooSmall":   {bounded, int64(-2), false},
+		"Int32TooLarge":   {bounded, int32(11), false},
+		"Uint64TooLarge":  {bounded, uint64(math.MaxUint64), false},
+		"NamedInt64":      {bounded, count(5), true},
+		"NamedTooLarge":   {bounded, count(11), false},
+		"Float32TooLarge": {bounded, float32(10.5), false},
+		"Float64":         {bounded, 9.5, true},
+		"String":          {bounded, "100", true},
+		"MaxSafeInt64":    {precise, int64(1 << 53), true},
+		"PreciseInt64":    {precise, int64(1<<53 + 1), false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tc.Definition.CheckConstraints(tc.Value)
+			if tc.Okay {
+				assert.NoError(t, err)
+			} else {
+				assert.IsType(t, &ConstraintViolationError{}, err)
+			}
+		})
+	}
+}
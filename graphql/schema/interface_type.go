@@ -1,7 +1,7 @@
 package schema
 
 import (
-	"fmt"
+	"context"
 	"strings"
 )
 
@@ -13,6 +13,18 @@ type InterfaceType struct {
 
 	// This type is only available for introspection and use when the given features are enabled.
 	RequiredFeatures FeatureSet
+
+	// If given, this is used to determine the object type of values before falling back to the
+	// implementations' IsTypeOf functions. This lets the type be determined via a single dispatch,
+	// e.g. based on a discriminator column. If it returns nil, the IsTypeOf functions are used.
+	// Otherwise it should return an implementation of the interface, which is matched to the
+	// schema's types by name. If given, implementations don't need to define IsTypeOf.
+	ResolveType func(ctx context.Context, value interface{}) *ObjectType
+
+	// Arbitrary values that let the package that created the type recognize it later. Like context
+	// values, keys should be of unexported types to avoid collisions. They aren't exposed via
+	// introspection.
+	Metadata map[interface{}]interface{}
 }
 
 func (t *InterfaceType) GetField(name string, features FeatureSet) *FieldDefinition {
@@ -50,29 +62,36 @@ func (t *InterfaceType) TypeName() string {
 	return t.Name
 }
 
-func (t *InterfaceType) shallowValidate() error {
+func (t *InterfaceType) shallowValidate() []*ValidationError {
+	var errs []*ValidationError
 	hasAtLeastOneUnconditionalField := false
 	for name, field := range t.Fields {
+		path := []string{"field " + name}
 		if !isName(name) || strings.HasPrefix(name, "__") {
-			return fmt.Errorf("illegal field name: %v", name)
+			errs = append(errs, newValidationError(path, "illegal field name: %v", name))
+			continue
 		}
 		if field.RequiredFeatures.IsSubsetOf(t.RequiredFeatures) {
 			hasAtLeastOneUnconditionalField = true
 		}
+		if field.Type == nil {
+			// reported by the field itself
+			continue
+		}
 
 		fieldRequiredFeatures := field.RequiredFeatures.Union(t.RequiredFeatures)
 		if !field.Type.TypeRequiredFeatures().IsSubsetOf(fieldRequiredFeatures) {
-			return fmt.Errorf("field type requires features that are not required by the field")
+			errs = append(errs, newValidationError(path, "field type requires features that are not required by the field"))
 		} else {
 			for name, arg := range field.Arguments {
-				if !arg.Type.TypeRequiredFeatures().IsSubsetOf(fieldRequiredFeatures) {
-					return fmt.Errorf("field argument %v requires features that are not required by the field", name)
+				if arg.Type != nil && !arg.Type.TypeRequiredFeatures().IsSubsetOf(fieldRequiredFeatures) {
+					errs = append(errs, newValidationError(append(path, "argument "+name), "field argument %v requires features that are not required by the field", name))
 				}
 			}
 		}
 	}
 	if !hasAtLeastOneUnconditionalField {
-		return fmt.Errorf("%v must have at least one field", t.Name)
+		errs = append(errs, newValidationError(nil, "%v must have at least one field", t.Name))
 	}
-	return nil
+	return errs
 }
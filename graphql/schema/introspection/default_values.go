@@ -0,0 +1,129 @@
+package introspection
+
+import (
+	"sort"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/parser"
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+// DefaultValueDifference describes an argument or input object field whose default value differs
+// between imported schema data and a schema's definitions.
+type DefaultValueDifference struct {
+	// The schema coordinate of the argument or field, e.g. "Query.users(first:)",
+	// "UserFilter.status", or "@include(if:)".
+	Coordinate string
+
+	// The imported default value in GraphQL syntax, or nil if there is none.
+	Imported *string
+
+	// The defined default value in GraphQL syntax, or nil if there is none.
+	Defined *string
+
+	// If either default value can't be interpreted using the defined type, this describes why.
+	Error string
+}
+
+// DefaultValueDifferences compares the default values of the arguments and input object fields in
+// the data, e.g. as imported from SDL or an introspection query, to those of the corresponding
+// definitions in s. Imported values are coerced to the defined types before they're compared, so
+// formatting differences are ignored. Arguments and fields that aren't present in both are also
+// ignored. The differences are sorted by coordinate.
+func (d *SchemaData) DefaultValueDifferences(s *schema.Schema) []*DefaultValueDifference {
+	var ret []*DefaultValueDifference
+	compare := func(imported []InputValueData, defined map[string]*schema.InputValueDefinition, coordinate func(name string) string) {
+		for _, value := range imported {
+			if def, ok := defined[value.Name]; ok {
+				if diff := compareDefaultValues(coordinate(value.Name), value.DefaultValue, def); diff != nil {
+					ret = append(ret, diff)
+				}
+			}
+		}
+	}
+
+	namedTypes := s.NamedTypes()
+	for _, t := range d.Types {
+		switch def := namedTypes[t.Name].(type) {
+		case *schema.ObjectType:
+			for _, field := range t.Fields {
+				if fieldDef, ok := def.Fields[field.Name]; ok {
+					compare(field.Args, fieldDef.Arguments, argumentCoordinate(t.Name+"."+field.Name))
+				}
+			}
+		case *schema.InterfaceType:
+			for _, field := range t.Fields {
+				if fieldDef, ok := def.Fields[field.Name]; ok {
+					compare(field.Args, fieldDef.Arguments, argumentCoordinate(t.Name+"."+field.Name))
+				}
+			}
+		case *schema.InputObjectType:
+			compare(t.InputFields, def.Fields, func(name string) string {
+				return t.Name + "." + name
+			})
+		}
+	}
+	directives := s.Directives()
+	for _, directive := range d.Directives {
+		if def, ok := directives[directive.Name]; ok {
+			compare(directive.Args, def.Arguments, argumentCoordinate("@"+directive.Name))
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Coordinate < ret[j].Coordinate
+	})
+	return ret
+}
+
+// Returns a function that builds the coordinates of the arguments of the given field or directive.
+func argumentCoordinate(parent string) func(name string) string {
+	return func(name string) string {
+		return parent + "(" + name + ":)"
+	}
+}
+
+// Returns nil if the imported default value is equivalent to the definition's.
+func compareDefaultValues(coordinate string, imported *string, def *schema.InputValueDefinition) *DefaultValueDifference {
+	if imported == nil && def.DefaultValue == nil {
+		return nil
+	}
+
+	ret := &DefaultValueDifference{
+		Coordinate: coordinate,
+		Imported:   imported,
+	}
+	var defined string
+	if def.DefaultValue != nil {
+		var err error
+		if defined, err = marshalValue(def.Type, def.DefaultValue); err != nil {
+			ret.Error = "unable to serialize defined default value: " + err.Error()
+			return ret
+		}
+		ret.Defined = &defined
+	}
+	if imported == nil || def.DefaultValue == nil {
+		return ret
+	}
+
+	value, errs := parser.ParseValue([]byte(*imported))
+	if len(errs) > 0 {
+		ret.Error = "unable to parse imported default value: " + errs[0].Message
+		return ret
+	}
+	var coerced interface{} = schema.Null
+	if _, isNull := value.(*ast.NullValue); !isNull {
+		var err error
+		if coerced, err = schema.CoerceLiteral(value, def.Type, nil); err != nil {
+			ret.Error = "unable to coerce imported default value: " + err.Error()
+			return ret
+		}
+	}
+	if normalized, err := marshalValue(def.Type, coerced); err != nil {
+		ret.Error = "unable to serialize imported default value: " + err.Error()
+		return ret
+	} else if normalized == defined {
+		return nil
+	}
+	return ret
+}
@@ -0,0 +1,92 @@
+package introspection_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql/schema"
+	"github.com/ccbrown/api-fu/graphql/sdl"
+)
+
+func TestSchemaData_DefaultValueDifferences(t *testing.T) {
+	statusType := &schema.EnumType{
+		Name: "Status",
+		Values: map[string]*schema.EnumValueDefinition{
+			"ACTIVE": {
+				Value: "active",
+			},
+			"INACTIVE": {
+				Value: "inactive",
+			},
+		},
+	}
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"users": {
+					Type: schema.NewListType(schema.StringType),
+					Arguments: map[string]*schema.InputValueDefinition{
+						"first": {
+							Type:         schema.IntType,
+							DefaultValue: 10,
+						},
+						"ratio": {
+							Type:         schema.FloatType,
+							DefaultValue: 1.0,
+						},
+						"status": {
+							Type:         statusType,
+							DefaultValue: "active",
+						},
+						"names": {
+							Type:         schema.NewListType(schema.StringType),
+							DefaultValue: []interface{}{"a"},
+						},
+						"after": {
+							Type: schema.StringType,
+						},
+						"before": {
+							Type:         schema.StringType,
+							DefaultValue: schema.Null,
+						},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	data, err := sdl.Parse([]byte(`
+		type Query {
+			users(first: Int = 20, ratio: Float = 1, status: Status = INACTIVE, names: [String] = "a", after: String = "x", before: String = null, missing: Int = 1): [String]
+		}
+		enum Status { ACTIVE INACTIVE }
+	`))
+	require.NoError(t, err)
+
+	diffs := data.DefaultValueDifferences(s)
+	var coordinates []string
+	for _, diff := range diffs {
+		coordinates = append(coordinates, diff.Coordinate)
+	}
+	assert.Equal(t, []string{
+		"Query.users(after:)",
+		"Query.users(first:)",
+		"Query.users(status:)",
+	}, coordinates)
+
+	require.Len(t, diffs, 3)
+	assert.Equal(t, `"x"`, *diffs[0].Imported)
+	assert.Nil(t, diffs[0].Defined)
+	assert.Equal(t, "20", *diffs[1].Imported)
+	assert.Equal(t, "10", *diffs[1].Defined)
+	assert.Equal(t, "INACTIVE", *diffs[2].Imported)
+	assert.Equal(t, "ACTIVE", *diffs[2].Defined)
+	for _, diff := range diffs {
+		assert.Empty(t, diff.Error)
+	}
+}
@@ -2,6 +2,7 @@ package introspection
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/ccbrown/api-fu/graphql/schema"
 )
@@ -46,17 +47,26 @@ func nullableString(s string) (interface{}, error) {
 	return nil, nil
 }
 
-func inputValues(values map[string]*schema.InputValueDefinition) (interface{}, error) {
+func inputValues(values map[string]*schema.InputValueDefinition, includeDeprecated bool) (interface{}, error) {
 	ret := []inputValue{}
 	for name, def := range values {
-		ret = append(ret, inputValue{
-			Name:       name,
-			Definition: def,
-		})
+		if def.DeprecationReason == "" || includeDeprecated {
+			ret = append(ret, inputValue{
+				Name:       name,
+				Definition: def,
+			})
+		}
 	}
 	return ret, nil
 }
 
+var includeDeprecatedArguments = map[string]*schema.InputValueDefinition{
+	"includeDeprecated": {
+		Type:         schema.BooleanType,
+		DefaultValue: false,
+	},
+}
+
 type directive struct {
 	Name       string
 	Definition *schema.DirectiveDefinition
@@ -227,15 +237,20 @@ func init() {
 				return nullableString(description)
 			},
 		},
-		"fields": {
-			Type: schema.NewListType(schema.NewNonNullType(FieldType)),
+		"specifiedByURL": {
+			Type: schema.StringType,
 			Cost: schema.FieldResolverCost(0),
-			Arguments: map[string]*schema.InputValueDefinition{
-				"includeDeprecated": {
-					Type:         schema.BooleanType,
-					DefaultValue: false,
-				},
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				if t, ok := ctx.Object.(*schema.ScalarType); ok {
+					return nullableString(t.SpecifiedByURL)
+				}
+				return nil, nil
 			},
+		},
+		"fields": {
+			Type:      schema.NewListType(schema.NewNonNullType(FieldType)),
+			Cost:      schema.FieldResolverCost(0),
+			Arguments: includeDeprecatedArguments,
 			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
 				var fields map[string]*schema.FieldDefinition
 				switch t := ctx.Object.(type) {
@@ -264,7 +279,13 @@ func init() {
 			Cost: schema.FieldResolverCost(0),
 			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
 				if t, ok := ctx.Object.(*schema.ObjectType); ok {
-					return t.ImplementedInterfaces, nil
+					ret := []*schema.InterfaceType{}
+					for _, iface := range t.ImplementedInterfaces {
+						if iface.RequiredFeatures.IsSubsetOf(ctx.Features) {
+							ret = append(ret, iface)
+						}
+					}
+					return ret, nil
 				}
 				return nil, nil
 			},
@@ -273,25 +294,28 @@ func init() {
 			Type: schema.NewListType(schema.NewNonNullType(TypeType)),
 			Cost: schema.FieldResolverCost(0),
 			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				var possibleTypes []*schema.ObjectType
 				switch t := ctx.Object.(type) {
 				case *schema.InterfaceType:
-					return ctx.Schema.InterfaceImplementations(t.Name), nil
+					possibleTypes = ctx.Schema.InterfaceImplementations(t.Name)
 				case *schema.UnionType:
-					return t.MemberTypes, nil
+					possibleTypes = t.MemberTypes
 				default:
 					return nil, nil
 				}
+				ret := []*schema.ObjectType{}
+				for _, t := range possibleTypes {
+					if t.RequiredFeatures.IsSubsetOf(ctx.Features) {
+						ret = append(ret, t)
+					}
+				}
+				return ret, nil
 			},
 		},
 		"enumValues": {
-			Type: schema.NewListType(schema.NewNonNullType(EnumValueType)),
-			Cost: schema.FieldResolverCost(0),
-			Arguments: map[string]*schema.InputValueDefinition{
-				"includeDeprecated": {
-					Type:         schema.BooleanType,
-					DefaultValue: false,
-				},
-			},
+			Type:      schema.NewListType(schema.NewNonNullType(EnumValueType)),
+			Cost:      schema.FieldResolverCost(0),
+			Arguments: includeDeprecatedArguments,
 			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
 				if t, ok := ctx.Object.(*schema.EnumType); ok {
 					includeDeprecated := ctx.Arguments["includeDeprecated"].(bool)
@@ -310,11 +334,12 @@ func init() {
 			},
 		},
 		"inputFields": {
-			Type: schema.NewListType(schema.NewNonNullType(InputValueType)),
-			Cost: schema.FieldResolverCost(0),
+			Type:      schema.NewListType(schema.NewNonNullType(InputValueType)),
+			Cost:      schema.FieldResolverCost(0),
+			Arguments: includeDeprecatedArguments,
 			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
 				if t, ok := ctx.Object.(*schema.InputObjectType); ok {
-					return inputValues(t.Fields)
+					return inputValues(t.Fields, ctx.Arguments["includeDeprecated"].(bool))
 				}
 				return nil, nil
 			},
@@ -421,10 +446,18 @@ var DirectiveType = &schema.ObjectType{
 			},
 		},
 		"args": {
-			Type: schema.NewNonNullType(schema.NewListType(schema.NewNonNullType(InputValueType))),
+			Type:      schema.NewNonNullType(schema.NewListType(schema.NewNonNullType(InputValueType))),
+			Cost:      schema.FieldResolverCost(0),
+			Arguments: includeDeprecatedArguments,
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return inputValues(ctx.Object.(directive).Definition.Arguments, ctx.Arguments["includeDeprecated"].(bool))
+			},
+		},
+		"isRepeatable": {
+			Type: schema.NewNonNullType(schema.BooleanType),
 			Cost: schema.FieldResolverCost(0),
 			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
-				return inputValues(ctx.Object.(directive).Definition.Arguments)
+				return ctx.Object.(directive).Definition.IsRepeatable, nil
 			},
 		},
 	},
@@ -453,10 +486,11 @@ var FieldType = &schema.ObjectType{
 			},
 		},
 		"args": {
-			Type: schema.NewNonNullType(schema.NewListType(schema.NewNonNullType(InputValueType))),
-			Cost: schema.FieldResolverCost(0),
+			Type:      schema.NewNonNullType(schema.NewListType(schema.NewNonNullType(InputValueType))),
+			Cost:      schema.FieldResolverCost(0),
+			Arguments: includeDeprecatedArguments,
 			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
-				return inputValues(ctx.Object.(field).Definition.Arguments)
+				return inputValues(ctx.Object.(field).Definition.Arguments, ctx.Arguments["includeDeprecated"].(bool))
 			},
 		},
 		"type": {
@@ -480,6 +514,19 @@ var FieldType = &schema.ObjectType{
 				return nullableString(ctx.Object.(field).Definition.DeprecationReason)
 			},
 		},
+		// This isn't part of the spec. It exposes FieldDefinition.SunsetTime as an RFC 3339 string
+		// so that clients can plan for fields being retired. Since other servers won't support it,
+		// NewQuery only requests it if QueryOptions.FieldSunsetTime is set.
+		"sunsetTime": {
+			Type: schema.StringType,
+			Cost: schema.FieldResolverCost(0),
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				if t := ctx.Object.(field).Definition.SunsetTime; !t.IsZero() {
+					return t.UTC().Format(time.RFC3339), nil
+				}
+				return nil, nil
+			},
+		},
 	},
 }
 
@@ -562,5 +609,19 @@ var InputValueType = &schema.ObjectType{
 				return nil, nil
 			},
 		},
+		"isDeprecated": {
+			Type: schema.NewNonNullType(schema.BooleanType),
+			Cost: schema.FieldResolverCost(0),
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return ctx.Object.(inputValue).Definition.DeprecationReason != "", nil
+			},
+		},
+		"deprecationReason": {
+			Type: schema.StringType,
+			Cost: schema.FieldResolverCost(0),
+			Resolve: func(ctx schema.FieldContext) (interface{}, error) {
+				return nullableString(ctx.Object.(inputValue).Definition.DeprecationReason)
+			},
+		},
 	},
 }
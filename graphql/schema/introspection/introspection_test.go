@@ -4,10 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/ccbrown/api-fu/graphql/ast"
 	"github.com/ccbrown/api-fu/graphql/executor"
 	"github.com/ccbrown/api-fu/graphql/parser"
 	"github.com/ccbrown/api-fu/graphql/schema"
@@ -145,3 +147,136 @@ func TestIntrospection(t *testing.T) {
 		assert.NotContains(t, string(buf), `"name":"age"`)
 	})
 }
+
+func TestIntrospection_RoundTrip(t *testing.T) {
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"list": {
+					Type: schema.NewListType(schema.IntType),
+					Arguments: map[string]*schema.InputValueDefinition{
+						"first": {
+							Type:         schema.IntType,
+							DefaultValue: 10,
+						},
+						"enum": {
+							Type: &schema.EnumType{
+								Name: "Enum",
+								Values: map[string]*schema.EnumValueDefinition{
+									"FOO": {Value: "FOO"},
+									"BAR": {Value: "BAR"},
+								},
+							},
+							DefaultValue: "FOO",
+						},
+						"limit": {
+							Type:              schema.IntType,
+							DeprecationReason: "Use first instead.",
+						},
+						"after": {
+							Type: &schema.ScalarType{
+								Name:                  "DateTime",
+								SpecifiedByURL:        "https://example.com",
+								LiteralCoercion:       func(ast.Value) interface{} { return nil },
+								VariableValueCoercion: func(interface{}) interface{} { return nil },
+								ResultCoercion:        func(interface{}) interface{} { return nil },
+							},
+						},
+					},
+				},
+				"retired": {
+					Type:              schema.IntType,
+					DeprecationReason: "Use list instead.",
+					SunsetTime:        time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		Directives: map[string]*schema.DirectiveDefinition{
+			"tag": {
+				Arguments: map[string]*schema.InputValueDefinition{
+					"name": {
+						Type: schema.NewNonNullType(schema.StringType),
+					},
+				},
+				Locations:    []schema.DirectiveLocation{schema.DirectiveLocationField},
+				IsRepeatable: true,
+			},
+		},
+	})
+	require.NoError(t, err)
+	doc, parseErrs := parser.ParseDocument(introspection.FullQuery)
+	require.Empty(t, parseErrs)
+
+	data, errs := executor.ExecuteRequest(context.Background(), &executor.Request{
+		Document: doc,
+		Schema:   s,
+	})
+	require.Empty(t, errs)
+	buf, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	var result struct {
+		Schema introspection.SchemaData `json:"__schema"`
+	}
+	require.NoError(t, json.Unmarshal(buf, &result))
+
+	def, err := result.Schema.GetSchemaDefinition()
+	require.NoError(t, err)
+	_, err = schema.New(def)
+	require.NoError(t, err)
+
+	args := def.Query.Fields["list"].Arguments
+	assert.Equal(t, 10, args["first"].DefaultValue)
+	assert.Equal(t, "FOO", args["enum"].DefaultValue)
+	assert.Nil(t, args["limit"].DefaultValue)
+	assert.Equal(t, "Use first instead.", args["limit"].DeprecationReason)
+	assert.Equal(t, "https://example.com", args["after"].Type.(*schema.ScalarType).SpecifiedByURL)
+	assert.True(t, def.Directives["tag"].IsRepeatable)
+	assert.True(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Equal(def.Query.Fields["retired"].SunsetTime))
+	assert.True(t, def.Query.Fields["list"].SunsetTime.IsZero())
+}
+
+func TestNewQuery(t *testing.T) {
+	for name, tc := range map[string]struct {
+		Options  *introspection.QueryOptions
+		Included []string
+		Excluded []string
+	}{
+		"Default": {
+			Excluded: []string{"isRepeatable", "specifiedByURL", "args(includeDeprecated: true)", "inputFields(includeDeprecated: true)", "sunsetTime"},
+		},
+		"DirectiveIsRepeatable": {
+			Options:  &introspection.QueryOptions{DirectiveIsRepeatable: true},
+			Included: []string{"isRepeatable"},
+			Excluded: []string{"specifiedByURL", "args(includeDeprecated: true)"},
+		},
+		"SpecifiedByURL": {
+			Options:  &introspection.QueryOptions{SpecifiedByURL: true},
+			Included: []string{"specifiedByURL"},
+			Excluded: []string{"isRepeatable", "args(includeDeprecated: true)"},
+		},
+		"InputValueDeprecation": {
+			Options:  &introspection.QueryOptions{InputValueDeprecation: true},
+			Included: []string{"args(includeDeprecated: true)", "inputFields(includeDeprecated: true)"},
+			Excluded: []string{"isRepeatable", "specifiedByURL"},
+		},
+		"FieldSunsetTime": {
+			Options:  &introspection.QueryOptions{FieldSunsetTime: true},
+			Included: []string{"sunsetTime"},
+			Excluded: []string{"isRepeatable", "specifiedByURL", "args(includeDeprecated: true)"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			query := introspection.NewQuery(tc.Options)
+			_, parseErrs := parser.ParseDocument(query)
+			require.Empty(t, parseErrs)
+			for _, s := range tc.Included {
+				assert.Contains(t, string(query), s)
+			}
+			for _, s := range tc.Excluded {
+				assert.NotContains(t, string(query), s)
+			}
+		})
+	}
+}
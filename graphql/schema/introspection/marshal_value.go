@@ -4,12 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/ccbrown/api-fu/graphql/schema"
 )
 
-// Used to marshal input types for default value introspection.
+// MarshalValue marshals a value of the given input type into GraphQL syntax. This is used for
+// default value introspection. Input object fields are sorted by name, so the output is
+// deterministic.
+func MarshalValue(t schema.Type, v interface{}) (string, error) {
+	return marshalValue(t, v)
+}
+
 func marshalValue(t schema.Type, v interface{}) (string, error) {
 	if v == schema.Null {
 		return "null", nil
@@ -41,9 +48,14 @@ func marshalValue(t schema.Type, v interface{}) (string, error) {
 		if err != nil {
 			return "", err
 		}
+		keys := make([]string, 0, len(kv))
+		for k := range kv {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
 		parts := make([]string, 0, len(kv))
-		for k, v := range kv {
-			s, err := marshalValue(t.Fields[k].Type, v)
+		for _, k := range keys {
+			s, err := marshalValue(t.Fields[k].Type, kv[k])
 			if err != nil {
 				return "", err
 			}
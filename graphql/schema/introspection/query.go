@@ -1,8 +1,70 @@
 package introspection
 
-// IntrospectionQuery attempts to query for everything in a schema. The results may be incomplete if
-// there are excessively nested list/non-null types.
-var Query = []byte(`
+import "strings"
+
+// QueryOptions selects which introspection fields beyond those supported by most servers are
+// requested by NewQuery. Servers that don't support a requested field will reject the query, so
+// these should only be enabled for servers known to support them.
+type QueryOptions struct {
+	// If true, the query includes the isRepeatable field of directives.
+	DirectiveIsRepeatable bool
+
+	// If true, the query includes the specifiedByURL field of types.
+	SpecifiedByURL bool
+
+	// If true, the query includes deprecated arguments and input fields, along with the
+	// isDeprecated and deprecationReason fields of input values.
+	InputValueDeprecation bool
+
+	// If true, the query includes the sunsetTime field of fields. This field is specific to this
+	// package's introspection schema.
+	FieldSunsetTime bool
+}
+
+// NewQuery returns a query that attempts to query for everything in a schema. The results may be
+// incomplete if there are excessively nested list/non-null types. If options is nil, the query
+// only requests fields that are supported by most servers.
+func NewQuery(options *QueryOptions) []byte {
+	if options == nil {
+		options = &QueryOptions{}
+	}
+	directiveIsRepeatable, specifiedByURL, includeDeprecated, inputValueDeprecation, fieldSunsetTime := "", "", "", "", ""
+	if options.DirectiveIsRepeatable {
+		directiveIsRepeatable = "\n          isRepeatable"
+	}
+	if options.SpecifiedByURL {
+		specifiedByURL = "\n      specifiedByURL"
+	}
+	if options.InputValueDeprecation {
+		includeDeprecated = "(includeDeprecated: true)"
+		inputValueDeprecation = "\n      isDeprecated\n      deprecationReason"
+	}
+	if options.FieldSunsetTime {
+		fieldSunsetTime = "\n        sunsetTime"
+	}
+	return []byte(strings.NewReplacer(
+		"$DIRECTIVE_IS_REPEATABLE", directiveIsRepeatable,
+		"$SPECIFIED_BY_URL", specifiedByURL,
+		"$INCLUDE_DEPRECATED", includeDeprecated,
+		"$INPUT_VALUE_DEPRECATION", inputValueDeprecation,
+		"$FIELD_SUNSET_TIME", fieldSunsetTime,
+	).Replace(queryTemplate))
+}
+
+// Query attempts to query for everything in a schema using only fields that are supported by most
+// servers. The results may be incomplete if there are excessively nested list/non-null types.
+var Query = NewQuery(nil)
+
+// FullQuery is like Query, but requests every field supported by this package's introspection
+// schema. It should only be used with servers known to support them.
+var FullQuery = NewQuery(&QueryOptions{
+	DirectiveIsRepeatable: true,
+	SpecifiedByURL:        true,
+	InputValueDeprecation: true,
+	FieldSunsetTime:       true,
+})
+
+const queryTemplate = `
     {
       __schema {
         queryType { name }
@@ -15,29 +77,29 @@ var Query = []byte(`
           name
           description
           locations
-          args {
+          args$INCLUDE_DEPRECATED {
             ...InputValue
-          }
+          }$DIRECTIVE_IS_REPEATABLE
         }
       }
     }
     fragment FullType on __Type {
       kind
       name
-      description
+      description$SPECIFIED_BY_URL
       fields(includeDeprecated: true) {
         name
         description
-        args {
+        args$INCLUDE_DEPRECATED {
           ...InputValue
         }
         type {
           ...TypeRef
         }
         isDeprecated
-        deprecationReason
+        deprecationReason$FIELD_SUNSET_TIME
       }
-      inputFields {
+      inputFields$INCLUDE_DEPRECATED {
         ...InputValue
       }
       interfaces {
@@ -57,7 +119,7 @@ var Query = []byte(`
       name
       description
       type { ...TypeRef }
-      defaultValue
+      defaultValue$INPUT_VALUE_DEPRECATION
     }
     fragment TypeRef on __Type {
       kind
@@ -91,4 +153,4 @@ var Query = []byte(`
         }
       }
     }
-`)
+`
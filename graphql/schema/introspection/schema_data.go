@@ -2,7 +2,11 @@ package introspection
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/parser"
 	"github.com/ccbrown/api-fu/graphql/schema"
 )
 
@@ -17,6 +21,10 @@ type SchemaData struct {
 // Gets a schema definition for the given schema data. This is not a lossless transformation, and
 // the definition will not be usable for a server as-is, but it can be used for example to validate
 // a query against another server's GraphQL schema.
+//
+// Enum values are defined with their names as their values, and input objects are coerced to
+// maps. Default values are coerced accordingly, with custom scalars coerced to the equivalent JSON
+// values.
 func (d *SchemaData) GetSchemaDefinition() (*schema.SchemaDefinition, error) {
 	ret := &schema.SchemaDefinition{
 		Directives: map[string]*schema.DirectiveDefinition{},
@@ -81,11 +89,18 @@ func (d *SchemaData) GetSchemaDefinition() (*schema.SchemaDefinition, error) {
 			continue
 		}
 
+		// Include all types, even those that aren't referenced by the root operation types. The
+		// introspection types are implicitly part of every schema, so they're excluded.
+		if !strings.HasPrefix(t.Name, "__") {
+			ret.AdditionalTypes = append(ret.AdditionalTypes, types[t.Name])
+		}
+
 		switch t.Kind {
 		case "SCALAR":
 			def := types[t.Name].(*schema.ScalarType)
 			def.Name = t.Name
 			def.Description = t.Description
+			def.SpecifiedByURL = t.SpecifiedByURL
 		case "OBJECT":
 			def := types[t.Name].(*schema.ObjectType)
 			def.Name = t.Name
@@ -107,9 +122,6 @@ func (d *SchemaData) GetSchemaDefinition() (*schema.SchemaDefinition, error) {
 					def.ImplementedInterfaces = append(def.ImplementedInterfaces, iface)
 				}
 			}
-			if len(t.Interfaces) > 0 {
-				ret.AdditionalTypes = append(ret.AdditionalTypes, def)
-			}
 			def.IsTypeOf = func(v interface{}) bool {
 				return false
 			}
@@ -155,6 +167,12 @@ func (d *SchemaData) GetSchemaDefinition() (*schema.SchemaDefinition, error) {
 			def.Name = t.Name
 			def.Description = t.Description
 			def.Fields = map[string]*schema.InputValueDefinition{}
+			def.ResultCoercion = func(v interface{}) (map[string]interface{}, error) {
+				if m, ok := v.(map[string]interface{}); ok {
+					return m, nil
+				}
+				return nil, fmt.Errorf("unexpected input object value: %T", v)
+			}
 			for _, field := range t.InputFields {
 				if fieldDef, err := field.getInputValueDefinition(types); err != nil {
 					return nil, err
@@ -173,14 +191,140 @@ func (d *SchemaData) GetSchemaDefinition() (*schema.SchemaDefinition, error) {
 		}
 	}
 
+	if err := d.setDefaultValues(types, ret.Directives); err != nil {
+		return nil, err
+	}
+
 	return ret, nil
 }
 
+// Default values may reference any type, so they're coerced once all of the types are complete.
+func (d *SchemaData) setDefaultValues(types map[string]schema.NamedType, directives map[string]*schema.DirectiveDefinition) error {
+	set := func(values []InputValueData, defs map[string]*schema.InputValueDefinition, coordinate func(name string) string) error {
+		for _, value := range values {
+			if value.DefaultValue == nil {
+				continue
+			}
+			def := defs[value.Name]
+			v, err := parseDefaultValue(*value.DefaultValue, def.Type)
+			if err != nil {
+				return fmt.Errorf("invalid default value for %v: %w", coordinate(value.Name), err)
+			}
+			def.DefaultValue = v
+		}
+		return nil
+	}
+
+	for _, t := range d.Types {
+		if _, ok := schema.BuiltInTypes[t.Name]; ok {
+			continue
+		}
+		var fields map[string]*schema.FieldDefinition
+		switch def := types[t.Name].(type) {
+		case *schema.ObjectType:
+			fields = def.Fields
+		case *schema.InterfaceType:
+			fields = def.Fields
+		case *schema.InputObjectType:
+			if err := set(t.InputFields, def.Fields, func(name string) string {
+				return t.Name + "." + name
+			}); err != nil {
+				return err
+			}
+		}
+		for _, field := range t.Fields {
+			if err := set(field.Args, fields[field.Name].Arguments, argumentCoordinate(t.Name+"."+field.Name)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, dir := range d.Directives {
+		if err := set(dir.Args, directives[dir.Name].Arguments, argumentCoordinate("@"+dir.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parses a default value in GraphQL syntax and coerces it to the given type.
+func parseDefaultValue(src string, t schema.Type) (interface{}, error) {
+	value, errs := parser.ParseValue([]byte(src))
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("unable to parse: %v", errs[0].Message)
+	}
+	if ast.IsNullValue(value) {
+		if schema.IsNonNullType(t) {
+			return nil, fmt.Errorf("null is not a valid %v", t)
+		}
+		return schema.Null, nil
+	}
+	return coerceDefaultValue(value, t)
+}
+
+// Coerces a default value literal. Unlike schema.CoerceLiteral, this doesn't add the default values
+// of input object fields, so the result serializes back to the original literal.
+func coerceDefaultValue(from ast.Value, t schema.Type) (interface{}, error) {
+	if ast.IsNullValue(from) {
+		if schema.IsNonNullType(t) {
+			return nil, fmt.Errorf("null is not a valid %v", t)
+		}
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *schema.NonNullType:
+		return coerceDefaultValue(from, t.Type)
+	case *schema.ScalarType:
+		if t.LiteralCoercion == nil && t.ParseLiteral == nil {
+			return schema.LiteralValue(from, nil)
+		}
+		return t.CoerceLiteral(from, nil)
+	case *schema.EnumType:
+		return t.CoerceLiteral(from)
+	case *schema.ListType:
+		list, ok := from.(*ast.ListValue)
+		if !ok {
+			item, err := coerceDefaultValue(from, t.Type)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		ret := make([]interface{}, len(list.Values))
+		for i, item := range list.Values {
+			v, err := coerceDefaultValue(item, t.Type)
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = v
+		}
+		return ret, nil
+	case *schema.InputObjectType:
+		if obj, ok := from.(*ast.ObjectValue); ok {
+			ret := make(map[string]interface{}, len(obj.Fields))
+			for _, field := range obj.Fields {
+				def, ok := t.Fields[field.Name.Name]
+				if !ok {
+					return nil, fmt.Errorf("unknown field: %v", field.Name.Name)
+				}
+				v, err := coerceDefaultValue(field.Value, def.Type)
+				if err != nil {
+					return nil, err
+				}
+				ret[field.Name.Name] = v
+			}
+			return ret, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot coerce to %v", t)
+}
+
 type DirectiveData struct {
-	Name        string
-	Description string
-	Locations   []string
-	Args        []InputValueData
+	Name         string
+	Description  string
+	Locations    []string
+	Args         []InputValueData
+	IsRepeatable bool
 }
 
 var directiveLocations = map[string]schema.DirectiveLocation{
@@ -206,8 +350,9 @@ var directiveLocations = map[string]schema.DirectiveLocation{
 
 func (d DirectiveData) getDirectiveDefinition(types map[string]schema.NamedType) (*schema.DirectiveDefinition, error) {
 	ret := &schema.DirectiveDefinition{
-		Description: d.Description,
-		Arguments:   map[string]*schema.InputValueDefinition{},
+		Description:  d.Description,
+		Arguments:    map[string]*schema.InputValueDefinition{},
+		IsRepeatable: d.IsRepeatable,
 	}
 	for _, l := range d.Locations {
 		if def, ok := directiveLocations[l]; ok {
@@ -227,15 +372,16 @@ func (d DirectiveData) getDirectiveDefinition(types map[string]schema.NamedType)
 }
 
 type TypeData struct {
-	Kind          string
-	Name          string
-	Description   string
-	Fields        []FieldData
-	InputFields   []InputValueData
-	Interfaces    []TypeData
-	EnumValues    []EnumValueData
-	PossibleTypes []TypeData
-	OfType        *TypeData
+	Kind           string
+	Name           string
+	Description    string
+	SpecifiedByURL string
+	Fields         []FieldData
+	InputFields    []InputValueData
+	Interfaces     []TypeData
+	EnumValues     []EnumValueData
+	PossibleTypes  []TypeData
+	OfType         *TypeData
 }
 
 func (d TypeData) getType(types map[string]schema.NamedType) (schema.Type, error) {
@@ -271,6 +417,7 @@ type FieldData struct {
 	Type              TypeData
 	IsDeprecated      bool
 	DeprecationReason string
+	SunsetTime        time.Time
 }
 
 func (d FieldData) getFieldDefinition(types map[string]schema.NamedType) (*schema.FieldDefinition, error) {
@@ -281,6 +428,7 @@ func (d FieldData) getFieldDefinition(types map[string]schema.NamedType) (*schem
 	ret := &schema.FieldDefinition{
 		Description:       d.Description,
 		DeprecationReason: d.DeprecationReason,
+		SunsetTime:        d.SunsetTime,
 		Type:              t,
 		Arguments:         map[string]*schema.InputValueDefinition{},
 	}
@@ -298,6 +446,13 @@ type InputValueData struct {
 	Name        string
 	Description string
 	Type        TypeData
+
+	// The default value in GraphQL syntax, if there is one. This can also be compared to another
+	// schema's defaults via DefaultValueDifferences.
+	DefaultValue *string
+
+	IsDeprecated      bool
+	DeprecationReason string
 }
 
 func (d InputValueData) getInputValueDefinition(types map[string]schema.NamedType) (*schema.InputValueDefinition, error) {
@@ -306,8 +461,9 @@ func (d InputValueData) getInputValueDefinition(types map[string]schema.NamedTyp
 		return nil, err
 	}
 	return &schema.InputValueDefinition{
-		Description: d.Description,
-		Type:        t,
+		Description:       d.Description,
+		Type:              t,
+		DeprecationReason: d.DeprecationReason,
 	}, nil
 }
 
@@ -321,6 +477,7 @@ type EnumValueData struct {
 func (d EnumValueData) getEnumValueDefinition(types map[string]schema.NamedType) (*schema.EnumValueDefinition, error) {
 	return &schema.EnumValueDefinition{
 		Description:       d.Description,
+		Value:             d.Name,
 		DeprecationReason: d.DeprecationReason,
 	}, nil
 }
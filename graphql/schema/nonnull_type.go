@@ -1,7 +1,5 @@
 package schema
 
-import "fmt"
-
 type NonNullType struct {
 	Type Type
 }
@@ -46,9 +44,9 @@ func (t *NonNullType) Unwrap() Type {
 	return t.Type
 }
 
-func (t *NonNullType) shallowValidate() error {
+func (t *NonNullType) shallowValidate() []*ValidationError {
 	if IsNonNullType(t.Type) {
-		return fmt.Errorf("non-null types cannot wrap other non-null types")
+		return []*ValidationError{newValidationError(nil, "non-null types cannot wrap other non-null types")}
 	}
 	return nil
 }
@@ -16,8 +16,8 @@ type ObjectType struct {
 
 	ImplementedInterfaces []*InterfaceType
 
-	// Objects that implement one or more interfaces must define this. The function should return
-	// true if obj is an object of this type.
+	// Objects that implement one or more interfaces must define this unless all of the interfaces
+	// define ResolveType. The function should return true if obj is an object of this type.
 	IsTypeOf func(obj interface{}) bool
 }
 
@@ -28,6 +28,22 @@ func (t *ObjectType) GetField(name string, features FeatureSet) *FieldDefinition
 	return nil
 }
 
+// FieldResolver returns the function used to resolve the named field. This is the field's Resolve
+// function, or if it doesn't have one, the Resolve function of the corresponding field of the first
+// implemented interface that has one. This allows interfaces to provide default resolvers for
+// fields that are resolved identically by all of their implementations.
+func (t *ObjectType) FieldResolver(name string) func(FieldContext) (interface{}, error) {
+	if field, ok := t.Fields[name]; ok && field.Resolve != nil {
+		return field.Resolve
+	}
+	for _, iface := range t.ImplementedInterfaces {
+		if ifaceField, ok := iface.Fields[name]; ok && ifaceField.Resolve != nil {
+			return ifaceField.Resolve
+		}
+	}
+	return nil
+}
+
 func (t *ObjectType) String() string {
 	return t.Name
 }
@@ -76,6 +92,9 @@ func (t *ObjectType) satisfyInterface(iface *InterfaceType) error {
 		field, ok := t.Fields[name]
 		if !ok {
 			return fmt.Errorf("object is missing field named %v", name)
+		} else if field.Type == nil || ifaceField.Type == nil {
+			// reported by the fields themselves
+			continue
 		} else if !field.Type.IsSubTypeOf(ifaceField.Type) {
 			return fmt.Errorf("object's %v field is not a subtype of the corresponding interface field", name)
 		} else if !field.RequiredFeatures.IsSubsetOf(ifaceField.RequiredFeatures) {
@@ -98,41 +117,54 @@ func (t *ObjectType) satisfyInterface(iface *InterfaceType) error {
 	return nil
 }
 
-func (t *ObjectType) shallowValidate() error {
+func (t *ObjectType) shallowValidate() []*ValidationError {
+	var errs []*ValidationError
 	hasAtLeastOneUnconditionalField := false
 	for name, field := range t.Fields {
+		path := []string{"field " + name}
 		if !isName(name) || strings.HasPrefix(name, "__") {
-			return fmt.Errorf("illegal field name: %v", name)
-		} else if !field.Type.IsOutputType() {
-			return fmt.Errorf("%v field must be an output type", name)
+			errs = append(errs, newValidationError(path, "illegal field name: %v", name))
+			continue
 		}
 		if field.RequiredFeatures.IsSubsetOf(t.RequiredFeatures) {
 			hasAtLeastOneUnconditionalField = true
 		}
+		if field.Type == nil {
+			// reported by the field itself
+			continue
+		} else if !field.Type.IsOutputType() {
+			errs = append(errs, newValidationError(path, "%v field must be an output type", name))
+			continue
+		}
 
 		fieldRequiredFeatures := field.RequiredFeatures.Union(t.RequiredFeatures)
 		if !field.Type.TypeRequiredFeatures().IsSubsetOf(fieldRequiredFeatures) {
-			return fmt.Errorf("field type requires features that are not required by the field")
+			errs = append(errs, newValidationError(path, "field type requires features that are not required by the field"))
 		} else {
 			for name, arg := range field.Arguments {
-				if !arg.Type.TypeRequiredFeatures().IsSubsetOf(fieldRequiredFeatures) {
-					return fmt.Errorf("field argument %v requires features that are not required by the field", name)
+				if arg.Type != nil && !arg.Type.TypeRequiredFeatures().IsSubsetOf(fieldRequiredFeatures) {
+					errs = append(errs, newValidationError(append(path, "argument "+name), "field argument %v requires features that are not required by the field", name))
 				}
 			}
 		}
 	}
 	if !hasAtLeastOneUnconditionalField {
-		return fmt.Errorf("%v must have at least one field", t.Name)
+		errs = append(errs, newValidationError(nil, "%v must have at least one field", t.Name))
 	}
 	for _, iface := range t.ImplementedInterfaces {
 		if err := t.satisfyInterface(iface); err != nil {
-			return fmt.Errorf("%v does not satisfy %v: %v", t.Name, iface.Name, err.Error())
+			errs = append(errs, newValidationError([]string{"implements " + iface.Name}, "%v does not satisfy %v: %v", t.Name, iface.Name, err.Error()))
 		}
 	}
-	if len(t.ImplementedInterfaces) > 0 && t.IsTypeOf == nil {
-		return fmt.Errorf("%v implements an interface, but does not define IsTypeOf", t.Name)
+	if t.IsTypeOf == nil {
+		for _, iface := range t.ImplementedInterfaces {
+			if iface.ResolveType == nil {
+				errs = append(errs, newValidationError(nil, "%v implements an interface, but does not define IsTypeOf", t.Name))
+				break
+			}
+		}
 	}
-	return nil
+	return errs
 }
 
 func IsObjectType(t Type) bool {
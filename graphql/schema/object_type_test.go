@@ -33,3 +33,71 @@ func TestObjectType_IsSubTypeOf(t *testing.T) {
 	assert.True(t, obj.IsSubTypeOf(iface))
 	assert.False(t, obj.IsSubTypeOf(IntType))
 }
+
+func TestObjectType_FieldResolver(t *testing.T) {
+	resolver := func(value string) func(FieldContext) (interface{}, error) {
+		return func(FieldContext) (interface{}, error) {
+			return value, nil
+		}
+	}
+
+	node := &InterfaceType{
+		Fields: map[string]*FieldDefinition{
+			"id": {
+				Type:    StringType,
+				Resolve: resolver("node"),
+			},
+			"name": {
+				Type:    StringType,
+				Resolve: resolver("node"),
+			},
+		},
+	}
+
+	named := &InterfaceType{
+		Fields: map[string]*FieldDefinition{
+			"name": {
+				Type:    StringType,
+				Resolve: resolver("named"),
+			},
+			"description": {
+				Type: StringType,
+			},
+		},
+	}
+
+	obj := &ObjectType{
+		Fields: map[string]*FieldDefinition{
+			"id": {
+				Type: StringType,
+			},
+			"name": {
+				Type:    StringType,
+				Resolve: resolver("object"),
+			},
+			"description": {
+				Type: StringType,
+			},
+		},
+		ImplementedInterfaces: []*InterfaceType{named, node},
+	}
+
+	for name, expected := range map[string]interface{}{
+		"id":   "node",
+		"name": "object",
+	} {
+		resolve := obj.FieldResolver(name)
+		if assert.NotNil(t, resolve, name) {
+			v, err := resolve(FieldContext{})
+			assert.NoError(t, err)
+			assert.Equal(t, expected, v, name)
+		}
+	}
+	assert.Nil(t, obj.FieldResolver("description"))
+	assert.Nil(t, obj.FieldResolver("undefined"))
+
+	obj.Fields["name"].Resolve = nil
+	v, err := obj.FieldResolver("name")(FieldContext{})
+	assert.NoError(t, err)
+	assert.Equal(t, "named", v)
+}
@@ -2,6 +2,7 @@ package schema
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/ccbrown/api-fu/graphql/ast"
 )
@@ -14,15 +15,32 @@ type ScalarType struct {
 	// This type is only available for introspection and use when the given features are enabled.
 	RequiredFeatures FeatureSet
 
+	// If non-empty, this is the URL of a specification for the scalar's data format. It's exposed
+	// via introspection as specifiedByURL.
+	SpecifiedByURL string
+
 	// Should return nil if coercion is impossible.
 	LiteralCoercion func(ast.Value) interface{}
 
+	// If given, this is used instead of LiteralCoercion to coerce literals. Scalars such as JSON can
+	// use it to accept list and object literals which contain variables, as variableValues holds the
+	// coerced values of the operation's variables. LiteralValue can be used to convert such literals
+	// into plain values.
+	//
+	// During validation, the variables' values aren't known yet, so variableValues is nil.
+	ParseLiteral func(value ast.Value, variableValues map[string]interface{}) (interface{}, error)
+
 	// Should return nil if coercion is impossible.
 	VariableValueCoercion func(interface{}) interface{}
 
 	// Should return nil if coercion is impossible. In many cases, this can be the same as
 	// VariableValueCoercion.
 	ResultCoercion func(interface{}) interface{}
+
+	// Arbitrary values that let the package that created the type recognize it later. Like context
+	// values, keys should be of unexported types to avoid collisions. They aren't exposed via
+	// introspection.
+	Metadata map[interface{}]interface{}
 }
 
 func (t *ScalarType) String() string {
@@ -53,6 +71,18 @@ func (t *ScalarType) TypeName() string {
 	return t.Name
 }
 
+// CoerceLiteral coerces a literal using ParseLiteral if it's defined or LiteralCoercion otherwise.
+func (t *ScalarType) CoerceLiteral(from ast.Value, variableValues map[string]interface{}) (interface{}, error) {
+	if t.ParseLiteral != nil {
+		return t.ParseLiteral(from, variableValues)
+	} else if t.LiteralCoercion != nil {
+		if coerced := t.LiteralCoercion(from); coerced != nil {
+			return coerced, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot coerce to %v", t)
+}
+
 func (t *ScalarType) CoerceVariableValue(v interface{}) (interface{}, error) {
 	if coerced := t.VariableValueCoercion(v); coerced != nil {
 		return coerced, nil
@@ -67,6 +97,49 @@ func (t *ScalarType) CoerceResult(result interface{}) (interface{}, error) {
 	return nil, fmt.Errorf("invalid scalar result value")
 }
 
+// LiteralValue converts a literal to a plain value: a bool, int64, float64, string, nil,
+// []interface{}, or map[string]interface{}. Enum values are converted to strings. Variables are
+// replaced with their values from variableValues, or nil if they have none.
+func LiteralValue(from ast.Value, variableValues map[string]interface{}) (interface{}, error) {
+	switch from := from.(type) {
+	case *ast.Variable:
+		return variableValues[from.Name.Name], nil
+	case *ast.BooleanValue:
+		return from.Value, nil
+	case *ast.IntValue:
+		return strconv.ParseInt(from.Value, 10, 64)
+	case *ast.FloatValue:
+		return strconv.ParseFloat(from.Value, 64)
+	case *ast.StringValue:
+		return from.Value, nil
+	case *ast.EnumValue:
+		return from.Value, nil
+	case *ast.NullValue:
+		return nil, nil
+	case *ast.ListValue:
+		ret := make([]interface{}, len(from.Values))
+		for i, item := range from.Values {
+			v, err := LiteralValue(item, variableValues)
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = v
+		}
+		return ret, nil
+	case *ast.ObjectValue:
+		ret := make(map[string]interface{}, len(from.Fields))
+		for _, field := range from.Fields {
+			v, err := LiteralValue(field.Value, variableValues)
+			if err != nil {
+				return nil, err
+			}
+			ret[field.Name.Name] = v
+		}
+		return ret, nil
+	}
+	return nil, fmt.Errorf("unsupported literal: %T", from)
+}
+
 func IsScalarType(t Type) bool {
 	_, ok := t.(*ScalarType)
 	return ok
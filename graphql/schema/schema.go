@@ -3,6 +3,7 @@ package schema
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/ccbrown/api-fu/graphql/ast"
@@ -16,6 +17,8 @@ type Schema struct {
 	queryType        *ObjectType
 	mutationType     *ObjectType
 	subscriptionType *ObjectType
+
+	warnings []*ValidationError
 }
 
 func (s *Schema) QueryType() *ObjectType {
@@ -48,8 +51,90 @@ func isName(s string) bool {
 	return nameRegex.MatchString(s)
 }
 
+// ValidationError describes a problem with a schema definition.
+type ValidationError struct {
+	// Path identifies the location of the problem within the schema. For example:
+	// []string{"type User", "field friends", "argument first"}
+	Path []string `json:"path"`
+
+	Message string `json:"message"`
+}
+
+func newValidationError(path []string, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (err *ValidationError) Error() string {
+	if len(err.Path) == 0 {
+		return err.Message
+	}
+	return strings.Join(err.Path, " > ") + ": " + err.Message
+}
+
+// ValidationErrors is the error returned by New when a schema definition is invalid. It contains
+// all of the problems that were found.
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Returns the name of the given node within its parent, or an empty string if it can't be
+// determined.
+func nodePathComponent(parent, node interface{}, directiveNames map[*DirectiveDefinition]string) string {
+	switch node := node.(type) {
+	case NamedType:
+		return "type " + node.TypeName()
+	case *DirectiveDefinition:
+		return "directive @" + directiveNames[node]
+	case *FieldDefinition:
+		var fields map[string]*FieldDefinition
+		switch parent := parent.(type) {
+		case *ObjectType:
+			fields = parent.Fields
+		case *InterfaceType:
+			fields = parent.Fields
+		}
+		for name, field := range fields {
+			if field == node {
+				return "field " + name
+			}
+		}
+	case *InputValueDefinition:
+		switch parent := parent.(type) {
+		case *InputObjectType:
+			for name, field := range parent.Fields {
+				if field == node {
+					return "field " + name
+				}
+			}
+		case *FieldDefinition:
+			for name, arg := range parent.Arguments {
+				if arg == node {
+					return "argument " + name
+				}
+			}
+		case *DirectiveDefinition:
+			for name, arg := range parent.Arguments {
+				if arg == node {
+					return "argument " + name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// New validates the given schema definition and builds a schema. If the definition is invalid, the
+// returned error will be a ValidationErrors describing all of the problems that were found.
 func New(def *SchemaDefinition) (*Schema, error) {
-	var err error
 	schema := &Schema{
 		directives:               def.Directives,
 		namedTypes:               map[string]NamedType{},
@@ -60,32 +145,60 @@ func New(def *SchemaDefinition) (*Schema, error) {
 	}
 
 	if schema.queryType == nil {
-		return nil, fmt.Errorf("schemas must define the query operation")
+		return nil, ValidationErrors{newValidationError(nil, "schemas must define the query operation")}
 	}
 
-	for name := range def.Directives {
+	var errs ValidationErrors
+
+	directiveNames := map[*DirectiveDefinition]string{}
+	for name, d := range def.Directives {
+		directiveNames[d] = name
 		if !isName(name) || strings.HasPrefix(name, "__") {
-			return nil, fmt.Errorf("illegal directive name: %v", name)
+			errs = append(errs, newValidationError([]string{"directive @" + name}, "illegal directive name: %v", name))
 		}
 	}
 
+	type frame struct {
+		node interface{}
+		path []string
+	}
+	stack := []frame{{}}
+	visited := map[NamedType]struct{}{}
+
 	Inspect(def, func(node interface{}) bool {
-		if err != nil {
-			return false
+		if node == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+
+		parent := stack[len(stack)-1]
+		path := parent.path
+		switch node.(type) {
+		case NamedType, *DirectiveDefinition:
+			path = []string{nodePathComponent(parent.node, node, directiveNames)}
+		default:
+			if component := nodePathComponent(parent.node, node, directiveNames); component != "" {
+				path = append(path[:len(path):len(path)], component)
+			}
 		}
 
 		if namedType, ok := node.(NamedType); ok {
-			if name := namedType.TypeName(); !isName(name) || strings.HasPrefix(name, "__") {
-				err = fmt.Errorf("illegal type name: %v", name)
-			} else if existing, ok := schema.namedTypes[name]; ok && existing != namedType {
-				err = fmt.Errorf("multiple definitions for named type: %v", name)
-			} else if builtin, ok := BuiltInTypes[name]; ok && namedType != builtin {
-				err = fmt.Errorf("%v builtin may not be overridden", name)
-			} else if existing != nil {
+			name := namedType.TypeName()
+			if existing, ok := schema.namedTypes[name]; ok && existing != namedType {
+				errs = append(errs, newValidationError(path, "multiple definitions for named type: %v", name))
+				return false
+			} else if _, ok := visited[namedType]; ok {
 				// already visited
 				return false
-			} else {
-				schema.namedTypes[name] = namedType
+			}
+			visited[namedType] = struct{}{}
+			schema.namedTypes[name] = namedType
+
+			if !isName(name) || strings.HasPrefix(name, "__") {
+				errs = append(errs, newValidationError(path, "illegal type name: %v", name))
+			} else if builtin, ok := BuiltInTypes[name]; ok && namedType != builtin {
+				errs = append(errs, newValidationError(path, "%v builtin may not be overridden", name))
+				return false
 			}
 		}
 
@@ -95,23 +208,106 @@ func New(def *SchemaDefinition) (*Schema, error) {
 			}
 		}
 
-		if err == nil {
-			if n, ok := node.(interface {
-				shallowValidate() error
-			}); ok {
-				err = n.shallowValidate()
+		if n, ok := node.(interface {
+			shallowValidate() []*ValidationError
+		}); ok {
+			for _, err := range n.shallowValidate() {
+				err.Path = append(path[:len(path):len(path)], err.Path...)
+				errs = append(errs, err)
+			}
+		}
+
+		if input, ok := node.(*InputValueDefinition); ok && def.StrictDefaultValues && input.DefaultValue != nil && input.Type != nil {
+			if err := checkDefaultValue(input.Type, input.DefaultValue); err != nil {
+				errs = append(errs, newValidationError(path, "invalid default value: %v", err))
 			}
 		}
 
-		return err == nil
+		stack = append(stack, frame{
+			node: node,
+			path: path,
+		})
+		return true
 	})
 
-	if err != nil {
-		return nil, err
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			return errs[i].Error() < errs[j].Error()
+		})
+		return nil, errs
 	}
+
+	schema.warnings = schema.lint(def)
 	return schema, nil
 }
 
+// Warnings returns potential problems with the schema that don't prevent it from being used, such
+// as types that are unreachable from the root operation types. They can be reported to clients via
+// the "schemaWarnings" response extension. See graphql.Request.ReportSchemaWarnings.
+func (s *Schema) Warnings() []*ValidationError {
+	return s.warnings
+}
+
+func (s *Schema) lint(def *SchemaDefinition) []*ValidationError {
+	var ret []*ValidationError
+
+	// Find the types that are reachable from the root operation types or directives, including
+	// the implementations of any reachable interfaces.
+	reachable := map[NamedType]struct{}{}
+	visit := func(node interface{}) {
+		Inspect(node, func(node interface{}) bool {
+			if t, ok := node.(NamedType); ok {
+				if _, ok := reachable[t]; ok {
+					return false
+				}
+				reachable[t] = struct{}{}
+			}
+			return true
+		})
+	}
+	visit(def.Query)
+	visit(def.Mutation)
+	visit(def.Subscription)
+	for _, d := range def.Directives {
+		visit(d)
+	}
+	for {
+		before := len(reachable)
+		for _, t := range s.namedTypes {
+			if iface, ok := t.(*InterfaceType); ok {
+				if _, ok := reachable[iface]; ok {
+					for _, obj := range s.interfaceImplementations[iface.Name] {
+						visit(obj)
+					}
+				}
+			}
+		}
+		if len(reachable) == before {
+			break
+		}
+	}
+
+	names := make([]string, 0, len(s.namedTypes))
+	for name := range s.namedTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t := s.namedTypes[name]
+		path := []string{"type " + name}
+		if _, ok := reachable[t]; !ok {
+			if _, ok := BuiltInTypes[name]; !ok {
+				ret = append(ret, newValidationError(path, "type is unreachable from the root operation types"))
+			}
+		}
+		if iface, ok := t.(*InterfaceType); ok && len(s.interfaceImplementations[iface.Name]) == 0 {
+			ret = append(ret, newValidationError(path, "interface has no implementations, so fields of this type can never be resolved"))
+		}
+	}
+	return ret
+}
+
 // Creates a deep copy of the given schema definition. This allows you to safely modify descriptions
 // or other attributes of the schema without modifying the original definition.
 func (def *SchemaDefinition) Clone() *SchemaDefinition {
@@ -129,6 +325,11 @@ type SchemaDefinition struct {
 
 	// AdditionalTypes is used to add otherwise unreferenced types to the schema.
 	AdditionalTypes []NamedType
+
+	// If true, New rejects arguments and input object fields whose default values aren't valid
+	// for their types. Otherwise, such defaults are only detected when they're first used, e.g. by
+	// an introspection query.
+	StrictDefaultValues bool
 }
 
 type Argument struct {
@@ -215,10 +416,7 @@ func coerceLiteral(from ast.Value, to Type, variableValues map[string]interface{
 
 	switch to := to.(type) {
 	case *ScalarType:
-		if v := to.LiteralCoercion(from); v != nil {
-			return v, nil
-		}
-		return nil, fmt.Errorf("cannot coerce to %v", to)
+		return to.CoerceLiteral(from, variableValues)
 	case *ListType:
 		return to.coerceLiteral(from, variableValues, allowItemToListCoercion)
 	case *InputObjectType:
@@ -3,6 +3,7 @@ package schema
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -29,6 +30,115 @@ func TestSchema(t *testing.T) {
 	assert.NotNil(t, schema.NamedTypes()["Int"])
 }
 
+func TestNew_ValidationErrors(t *testing.T) {
+	userType := &ObjectType{
+		Name: "User",
+		Fields: map[string]*FieldDefinition{
+			"friends": {
+				Type: NewListType(StringType),
+				Arguments: map[string]*InputValueDefinition{
+					"first": {
+						Type: &ObjectType{
+							Name: "NotAnInput",
+							Fields: map[string]*FieldDefinition{
+								"x": {
+									Type: IntType,
+								},
+							},
+						},
+					},
+				},
+			},
+			"__bad": {
+				Type: IntType,
+			},
+		},
+	}
+	_, err := New(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"user": {
+					Type: userType,
+				},
+				"nonNull": {
+					Type: &NonNullType{
+						Type: NewNonNullType(IntType),
+					},
+				},
+				"retired": {
+					Type:       IntType,
+					SunsetTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+				"limited": {
+					Type: IntType,
+					ListLimit: func(FieldContext) int {
+						return 1
+					},
+				},
+			},
+		},
+	})
+	require.Error(t, err)
+
+	errs, ok := err.(ValidationErrors)
+	require.True(t, ok)
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	assert.Equal(t, []string{
+		"type Query > field limited: only list fields can have list limits",
+		"type Query > field nonNull: non-null types cannot wrap other non-null types",
+		"type Query > field retired: fields with sunset times must be deprecated",
+		"type User > field __bad: illegal field name: __bad",
+		"type User > field friends > argument first: NotAnInput cannot be used as an input value type",
+	}, messages)
+	assert.Equal(t, []string{"type User", "field friends", "argument first"}, errs[4].Path)
+}
+
+func TestSchema_Warnings(t *testing.T) {
+	iface := &InterfaceType{
+		Name: "Interface",
+		Fields: map[string]*FieldDefinition{
+			"foo": {
+				Type: IntType,
+			},
+		},
+	}
+
+	schema, err := New(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"foo": {
+					Type: iface,
+				},
+			},
+		},
+		AdditionalTypes: []NamedType{
+			&ObjectType{
+				Name: "Unreachable",
+				Fields: map[string]*FieldDefinition{
+					"foo": {
+						Type: IntType,
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	var warnings []string
+	for _, warning := range schema.Warnings() {
+		warnings = append(warnings, warning.Error())
+	}
+	assert.Equal(t, []string{
+		"type Interface: interface has no implementations, so fields of this type can never be resolved",
+		"type Unreachable: type is unreachable from the root operation types",
+	}, warnings)
+}
+
 func TestCoercion(t *testing.T) {
 	for name, tc := range map[string]struct {
 		JSONInput      string
@@ -120,6 +230,20 @@ func TestCoercion(t *testing.T) {
 				},
 			},
 		},
+		"ScalarParseLiteral": {
+			LiteralInput: `{a: [1, 2.5, "b", $foo], b: null}`,
+			VariableValues: map[string]interface{}{
+				"foo": true,
+			},
+			Expected: map[string]interface{}{
+				"a": []interface{}{int64(1), 2.5, "b", true},
+				"b": nil,
+			},
+			Type: &ScalarType{
+				Name:         "JSON",
+				ParseLiteral: LiteralValue,
+			},
+		},
 	} {
 		t.Run(name, func(t *testing.T) {
 			if tc.JSONInput != "" {
@@ -143,3 +267,66 @@ func TestCoercion(t *testing.T) {
 		})
 	}
 }
+
+func TestNew_StrictDefaultValues(t *testing.T) {
+	statusType := &EnumType{
+		Name: "Status",
+		Values: map[string]*EnumValueDefinition{
+			"ACTIVE": {
+				Value: 1,
+			},
+		},
+	}
+	queryType := &ObjectType{
+		Name: "Query",
+		Fields: map[string]*FieldDefinition{
+			"users": {
+				Type: NewListType(StringType),
+				Arguments: map[string]*InputValueDefinition{
+					"first": {
+						Type:         IntType,
+						DefaultValue: "ten",
+					},
+					"status": {
+						Type:         statusType,
+						DefaultValue: 2,
+					},
+					"ids": {
+						Type:         NewListType(NewNonNullType(IntType)),
+						DefaultValue: []interface{}{1, Null},
+					},
+					"good": {
+						Type:         NewListType(statusType),
+						DefaultValue: []interface{}{1},
+					},
+					"nullable": {
+						Type:         IntType,
+						DefaultValue: Null,
+					},
+				},
+			},
+		},
+	}
+
+	// Without strict mode, the default values aren't checked.
+	_, err := New(&SchemaDefinition{
+		Query: queryType,
+	})
+	require.NoError(t, err)
+
+	_, err = New(&SchemaDefinition{
+		Query:               queryType,
+		StrictDefaultValues: true,
+	})
+	require.Error(t, err)
+
+	var messages []string
+	for _, err := range err.(ValidationErrors) {
+		messages = append(messages, err.Error())
+	}
+	assert.Equal(t, []string{
+		"type Query > field users > argument first: invalid default value: \"ten\" is not a valid Int",
+		"type Query > field users > argument ids: invalid default value: item 1: null is not a valid Int!",
+		"type Query > field users > argument status: invalid default value: invalid Status enum value: 2",
+	}, messages)
+}
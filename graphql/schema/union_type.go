@@ -1,6 +1,6 @@
 package schema
 
-import "fmt"
+import "context"
 
 type UnionType struct {
 	Name        string
@@ -10,6 +10,12 @@ type UnionType struct {
 
 	// This type is only available for introspection and use when the given features are enabled.
 	RequiredFeatures FeatureSet
+
+	// If given, this is used to determine the object type of values before falling back to the
+	// members' IsTypeOf functions. If it returns nil, the IsTypeOf functions are used. Otherwise it
+	// should return a member of the union, which is matched to the schema's types by name. If
+	// given, members don't need to define IsTypeOf.
+	ResolveType func(ctx context.Context, value interface{}) *ObjectType
 }
 
 func (d *UnionType) String() string {
@@ -40,23 +46,25 @@ func (d *UnionType) TypeName() string {
 	return d.Name
 }
 
-func (d *UnionType) shallowValidate() error {
+func (d *UnionType) shallowValidate() []*ValidationError {
 	if len(d.MemberTypes) == 0 {
-		return fmt.Errorf("%v must have at least one member type", d.Name)
+		return []*ValidationError{newValidationError(nil, "%v must have at least one member type", d.Name)}
 	}
+	var errs []*ValidationError
 	objNames := map[string]struct{}{}
 	for _, member := range d.MemberTypes {
+		path := []string{"member " + member.Name}
 		if !member.RequiredFeatures.IsSubsetOf(d.RequiredFeatures) {
 			// TODO: support conditional union members?
-			return fmt.Errorf("union member has additional required features, but conditional members are not currently supported")
+			errs = append(errs, newValidationError(path, "union member has additional required features, but conditional members are not currently supported"))
 		}
 		if _, ok := objNames[member.Name]; ok {
-			return fmt.Errorf("union member types must be unique")
+			errs = append(errs, newValidationError(path, "union member types must be unique"))
 		}
-		if member.IsTypeOf == nil {
-			return fmt.Errorf("union member types must define IsTypeOf")
+		if member.IsTypeOf == nil && d.ResolveType == nil {
+			errs = append(errs, newValidationError(path, "union member types must define IsTypeOf"))
 		}
 		objNames[member.Name] = struct{}{}
 	}
-	return nil
+	return errs
 }
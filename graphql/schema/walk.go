@@ -0,0 +1,144 @@
+package schema
+
+import (
+	"sort"
+)
+
+// WalkInfo describes the position of an element visited by Walk.
+type WalkInfo struct {
+	// The element's schema coordinate, e.g. "User", "User.name", "Query.user(id:)", "@include", or
+	// "@include(if:)".
+	Coordinate string
+
+	// The named type that contains the element. This is nil for named types, directives, and
+	// directive arguments.
+	Type NamedType
+
+	// For arguments of fields, this is the field that contains the argument.
+	Field *FieldDefinition
+
+	// For directive arguments, this is the directive that contains the argument.
+	Directive *DirectiveDefinition
+}
+
+// Visitor contains the functions invoked by Walk. Any of them may be nil.
+//
+// Note that the types of fields, input fields, and arguments may be wrapped in list or non-null
+// types. Use UnwrappedType to get the named type.
+type Visitor struct {
+	// Invoked for each of the schema's named types.
+	NamedType func(t NamedType, info *WalkInfo)
+
+	// Invoked for each field of each object and interface type.
+	Field func(name string, field *FieldDefinition, info *WalkInfo)
+
+	// Invoked for each field of each input object type.
+	InputField func(name string, field *InputValueDefinition, info *WalkInfo)
+
+	// Invoked for each argument of each field and directive.
+	Argument func(name string, arg *InputValueDefinition, info *WalkInfo)
+
+	// Invoked for each value of each enum type.
+	EnumValue func(name string, value *EnumValueDefinition, info *WalkInfo)
+
+	// Invoked for each of the schema's directives.
+	Directive func(name string, directive *DirectiveDefinition, info *WalkInfo)
+}
+
+// Walk traverses the schema's directives and named types, as returned by Directives and
+// NamedTypes, invoking the visitor's functions for each element exactly once. Elements are visited
+// in a deterministic order: directives, then named types, sorted by name. Each named type is
+// visited before its fields or values, and each field or directive is visited before its
+// arguments.
+//
+// Unlike Inspect, Walk doesn't follow references between types, so there's no need to break
+// cycles.
+func Walk(s *Schema, v *Visitor) {
+	directives := s.Directives()
+	for _, name := range sortedKeys(directives) {
+		def := directives[name]
+		coordinate := "@" + name
+		if v.Directive != nil {
+			v.Directive(name, def, &WalkInfo{
+				Coordinate: coordinate,
+			})
+		}
+		walkArguments(v, def.Arguments, &WalkInfo{
+			Coordinate: coordinate,
+			Directive:  def,
+		})
+	}
+
+	namedTypes := s.NamedTypes()
+	for _, name := range sortedKeys(namedTypes) {
+		t := namedTypes[name]
+		if v.NamedType != nil {
+			v.NamedType(t, &WalkInfo{
+				Coordinate: name,
+			})
+		}
+		switch t := t.(type) {
+		case *ObjectType:
+			walkFields(v, t, t.Fields)
+		case *InterfaceType:
+			walkFields(v, t, t.Fields)
+		case *InputObjectType:
+			if v.InputField != nil {
+				for _, fieldName := range sortedKeys(t.Fields) {
+					v.InputField(fieldName, t.Fields[fieldName], &WalkInfo{
+						Coordinate: name + "." + fieldName,
+						Type:       t,
+					})
+				}
+			}
+		case *EnumType:
+			if v.EnumValue != nil {
+				for _, valueName := range sortedKeys(t.Values) {
+					v.EnumValue(valueName, t.Values[valueName], &WalkInfo{
+						Coordinate: name + "." + valueName,
+						Type:       t,
+					})
+				}
+			}
+		}
+	}
+}
+
+func walkFields(v *Visitor, t NamedType, fields map[string]*FieldDefinition) {
+	for _, name := range sortedKeys(fields) {
+		field := fields[name]
+		coordinate := t.TypeName() + "." + name
+		if v.Field != nil {
+			v.Field(name, field, &WalkInfo{
+				Coordinate: coordinate,
+				Type:       t,
+			})
+		}
+		walkArguments(v, field.Arguments, &WalkInfo{
+			Coordinate: coordinate,
+			Type:       t,
+			Field:      field,
+		})
+	}
+}
+
+// Visits the arguments of a field or directive. The given info describes the field or directive.
+func walkArguments(v *Visitor, args map[string]*InputValueDefinition, parent *WalkInfo) {
+	if v.Argument == nil {
+		return
+	}
+	for _, name := range sortedKeys(args) {
+		info := *parent
+		info.Coordinate = parent.Coordinate + "(" + name + ":)"
+		v.Argument(name, args[name], &info)
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
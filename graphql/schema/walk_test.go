@@ -0,0 +1,127 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalk(t *testing.T) {
+	node := &InterfaceType{
+		Name: "Node",
+		Fields: map[string]*FieldDefinition{
+			"id": {
+				Type: NewNonNullType(IDType),
+			},
+		},
+	}
+	color := &EnumType{
+		Name: "Color",
+		Values: map[string]*EnumValueDefinition{
+			"RED":  {},
+			"BLUE": {},
+		},
+	}
+	filter := &InputObjectType{
+		Name: "Filter",
+		Fields: map[string]*InputValueDefinition{
+			"color": {
+				Type: color,
+			},
+		},
+	}
+	user := &ObjectType{
+		Name: "User",
+		Fields: map[string]*FieldDefinition{
+			"id": {
+				Type: NewNonNullType(IDType),
+			},
+			"friends": {
+				Arguments: map[string]*InputValueDefinition{
+					"filter": {
+						Type: filter,
+					},
+				},
+			},
+		},
+		ImplementedInterfaces: []*InterfaceType{node},
+		IsTypeOf: func(interface{}) bool {
+			return true
+		},
+	}
+	user.Fields["friends"].Type = NewListType(NewNonNullType(user))
+	s, err := New(&SchemaDefinition{
+		Query: &ObjectType{
+			Name: "Query",
+			Fields: map[string]*FieldDefinition{
+				"user": {
+					Type: user,
+				},
+			},
+		},
+		Directives: map[string]*DirectiveDefinition{
+			"include": IncludeDirective,
+		},
+	})
+	require.NoError(t, err)
+
+	var visited []string
+	var argumentParents []string
+	Walk(s, &Visitor{
+		NamedType: func(t NamedType, info *WalkInfo) {
+			if !strings.HasPrefix(t.TypeName(), "__") {
+				visited = append(visited, "type "+info.Coordinate)
+			}
+		},
+		Field: func(name string, field *FieldDefinition, info *WalkInfo) {
+			if !strings.HasPrefix(info.Type.TypeName(), "__") {
+				visited = append(visited, "field "+info.Coordinate+": "+UnwrappedType(field.Type).TypeName())
+			}
+		},
+		InputField: func(name string, field *InputValueDefinition, info *WalkInfo) {
+			visited = append(visited, "input field "+info.Coordinate)
+		},
+		Argument: func(name string, arg *InputValueDefinition, info *WalkInfo) {
+			if info.Type != nil && strings.HasPrefix(info.Type.TypeName(), "__") {
+				return
+			}
+			visited = append(visited, "argument "+info.Coordinate)
+			if info.Field != nil {
+				argumentParents = append(argumentParents, "field")
+			} else if info.Directive != nil {
+				argumentParents = append(argumentParents, "directive")
+			}
+		},
+		EnumValue: func(name string, value *EnumValueDefinition, info *WalkInfo) {
+			if !strings.HasPrefix(info.Type.TypeName(), "__") {
+				visited = append(visited, "enum value "+info.Coordinate)
+			}
+		},
+		Directive: func(name string, directive *DirectiveDefinition, info *WalkInfo) {
+			visited = append(visited, "directive "+info.Coordinate)
+		},
+	})
+
+	assert.Equal(t, []string{
+		"directive @include",
+		"argument @include(if:)",
+		"type Boolean",
+		"type Color",
+		"enum value Color.BLUE",
+		"enum value Color.RED",
+		"type Filter",
+		"input field Filter.color",
+		"type ID",
+		"type Node",
+		"field Node.id: ID",
+		"type Query",
+		"field Query.user: User",
+		"type User",
+		"field User.friends: User",
+		"argument User.friends(filter:)",
+		"field User.id: ID",
+	}, visited)
+	assert.Equal(t, []string{"directive", "field"}, argumentParents)
+}
@@ -0,0 +1,287 @@
+package sdl
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ccbrown/api-fu/graphql/schema"
+	"github.com/ccbrown/api-fu/graphql/schema/introspection"
+)
+
+// Print serializes a schema as an SDL document. The output is deterministic: types, directives,
+// fields, arguments, and enum values are sorted by name, so it's suitable for snapshot testing.
+//
+// Built-in scalars and the @skip, @include, and @specifiedBy directives are omitted. Features are not
+// represented, so everything in the schema is printed regardless of its required features.
+func Print(s *schema.Schema) (string, error) {
+	p := &printer{
+		directiveNames: map[*schema.DirectiveDefinition]string{},
+	}
+	for name, def := range s.Directives() {
+		p.directiveNames[def] = name
+	}
+
+	p.printSchemaDefinition(s)
+
+	directiveNames := make([]string, 0, len(s.Directives()))
+	for name, def := range s.Directives() {
+		if def != schema.SkipDirective && def != schema.IncludeDirective && def != schema.SpecifiedByDirective {
+			directiveNames = append(directiveNames, name)
+		}
+	}
+	sort.Strings(directiveNames)
+	for _, name := range directiveNames {
+		p.printDirectiveDefinition(name, s.Directives()[name])
+	}
+
+	typeNames := make([]string, 0, len(s.NamedTypes()))
+	for name := range s.NamedTypes() {
+		if _, ok := schema.BuiltInTypes[name]; !ok {
+			typeNames = append(typeNames, name)
+		}
+	}
+	sort.Strings(typeNames)
+	for _, name := range typeNames {
+		p.printNamedType(s.NamedTypes()[name])
+	}
+
+	if p.err != nil {
+		return "", p.err
+	}
+	return strings.Join(p.blocks, "\n"), nil
+}
+
+type printer struct {
+	directiveNames map[*schema.DirectiveDefinition]string
+	blocks         []string
+	err            error
+}
+
+func (p *printer) add(block string) {
+	p.blocks = append(p.blocks, block)
+}
+
+func (p *printer) marshalValue(t schema.Type, v interface{}) string {
+	if t == nil {
+		if s, ok := v.(string); ok {
+			return quote(s)
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	s, err := introspection.MarshalValue(t, v)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return s
+}
+
+func (p *printer) printSchemaDefinition(s *schema.Schema) {
+	if s.QueryType().Name == "Query" &&
+		(s.MutationType() == nil || s.MutationType().Name == "Mutation") &&
+		(s.SubscriptionType() == nil || s.SubscriptionType().Name == "Subscription") {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("schema {\n")
+	b.WriteString("  query: " + s.QueryType().Name + "\n")
+	if s.MutationType() != nil {
+		b.WriteString("  mutation: " + s.MutationType().Name + "\n")
+	}
+	if s.SubscriptionType() != nil {
+		b.WriteString("  subscription: " + s.SubscriptionType().Name + "\n")
+	}
+	b.WriteString("}\n")
+	p.add(b.String())
+}
+
+// Returns s as a GraphQL string literal. JSON's escape sequences are a subset of GraphQL's, so
+// backslashes, quotes, and control characters are escaped the same way.
+func quote(s string) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Returns s as a description. Multi-line descriptions are printed as block strings, in which
+// backslashes don't need to be escaped.
+func description(indent, s string) string {
+	if s == "" {
+		return ""
+	}
+	if !strings.Contains(s, "\n") {
+		return indent + quote(s) + "\n"
+	}
+	lines := strings.Split(strings.ReplaceAll(s, `"""`, `\"""`), "\n")
+	var b strings.Builder
+	b.WriteString(indent + `"""` + "\n")
+	for _, line := range lines {
+		if line == "" {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(indent + line + "\n")
+		}
+	}
+	b.WriteString(indent + `"""` + "\n")
+	return b.String()
+}
+
+func (p *printer) directives(directives []*schema.Directive) string {
+	var b strings.Builder
+	for _, directive := range directives {
+		b.WriteString(" @" + p.directiveNames[directive.Definition])
+		if len(directive.Arguments) > 0 {
+			arguments := append([]*schema.Argument(nil), directive.Arguments...)
+			sort.Slice(arguments, func(i, j int) bool {
+				return arguments[i].Name < arguments[j].Name
+			})
+			parts := make([]string, len(arguments))
+			for i, arg := range arguments {
+				var t schema.Type
+				if def := directive.Definition.Arguments[arg.Name]; def != nil {
+					t = def.Type
+				}
+				parts[i] = arg.Name + ": " + p.marshalValue(t, arg.Value)
+			}
+			b.WriteString("(" + strings.Join(parts, ", ") + ")")
+		}
+	}
+	return b.String()
+}
+
+func deprecated(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return " @deprecated(reason: " + quote(reason) + ")"
+}
+
+func specifiedBy(url string) string {
+	if url == "" {
+		return ""
+	}
+	return " @specifiedBy(url: " + quote(url) + ")"
+}
+
+func (p *printer) inputValue(indent, name string, def *schema.InputValueDefinition) string {
+	s := description(indent, def.Description) + indent + name + ": " + def.Type.String()
+	if def.DefaultValue != nil {
+		s += " = " + p.marshalValue(def.Type, def.DefaultValue)
+	}
+	return s + deprecated(def.DeprecationReason) + p.directives(def.Directives)
+}
+
+func (p *printer) arguments(indent string, arguments map[string]*schema.InputValueDefinition) string {
+	if len(arguments) == 0 {
+		return ""
+	}
+	names := sortedKeys(arguments)
+	hasDescriptions := false
+	for _, name := range names {
+		if arguments[name].Description != "" {
+			hasDescriptions = true
+		}
+	}
+	parts := make([]string, len(names))
+	if !hasDescriptions {
+		for i, name := range names {
+			parts[i] = p.inputValue("", name, arguments[name])
+		}
+		return "(" + strings.Join(parts, ", ") + ")"
+	}
+	for i, name := range names {
+		parts[i] = p.inputValue(indent+"  ", name, arguments[name])
+	}
+	return "(\n" + strings.Join(parts, "\n") + "\n" + indent + ")"
+}
+
+func (p *printer) fields(fields map[string]*schema.FieldDefinition) string {
+	var b strings.Builder
+	b.WriteString(" {\n")
+	for _, name := range sortedKeys(fields) {
+		field := fields[name]
+		b.WriteString(description("  ", field.Description))
+		b.WriteString("  " + name + p.arguments("  ", field.Arguments) + ": " + field.Type.String())
+		b.WriteString(deprecated(field.DeprecationReason) + p.directives(field.Directives) + "\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func repeatable(isRepeatable bool) string {
+	if isRepeatable {
+		return " repeatable"
+	}
+	return ""
+}
+
+func (p *printer) printDirectiveDefinition(name string, def *schema.DirectiveDefinition) {
+	locations := make([]string, len(def.Locations))
+	for i, location := range def.Locations {
+		locations[i] = string(location)
+	}
+	sort.Strings(locations)
+	p.add(description("", def.Description) + "directive @" + name + p.arguments("", def.Arguments) + repeatable(def.IsRepeatable) + " on " + strings.Join(locations, " | ") + "\n")
+}
+
+func (p *printer) printNamedType(t schema.NamedType) {
+	switch t := t.(type) {
+	case *schema.ScalarType:
+		p.add(description("", t.Description) + "scalar " + t.Name + specifiedBy(t.SpecifiedByURL) + p.directives(t.Directives) + "\n")
+	case *schema.ObjectType:
+		s := description("", t.Description) + "type " + t.Name
+		if len(t.ImplementedInterfaces) > 0 {
+			names := make([]string, len(t.ImplementedInterfaces))
+			for i, iface := range t.ImplementedInterfaces {
+				names[i] = iface.Name
+			}
+			sort.Strings(names)
+			s += " implements " + strings.Join(names, " & ")
+		}
+		p.add(s + p.directives(t.Directives) + p.fields(t.Fields))
+	case *schema.InterfaceType:
+		p.add(description("", t.Description) + "interface " + t.Name + p.directives(t.Directives) + p.fields(t.Fields))
+	case *schema.UnionType:
+		names := make([]string, len(t.MemberTypes))
+		for i, member := range t.MemberTypes {
+			names[i] = member.Name
+		}
+		sort.Strings(names)
+		p.add(description("", t.Description) + "union " + t.Name + p.directives(t.Directives) + " = " + strings.Join(names, " | ") + "\n")
+	case *schema.EnumType:
+		var b strings.Builder
+		b.WriteString(description("", t.Description) + "enum " + t.Name + p.directives(t.Directives) + " {\n")
+		for _, name := range sortedKeys(t.Values) {
+			value := t.Values[name]
+			b.WriteString(description("  ", value.Description))
+			b.WriteString("  " + name + deprecated(value.DeprecationReason) + p.directives(value.Directives) + "\n")
+		}
+		b.WriteString("}\n")
+		p.add(b.String())
+	case *schema.InputObjectType:
+		var b strings.Builder
+		b.WriteString(description("", t.Description) + "input " + t.Name + p.directives(t.Directives) + " {\n")
+		for _, name := range sortedKeys(t.Fields) {
+			b.WriteString(p.inputValue("  ", name, t.Fields[name]) + "\n")
+		}
+		b.WriteString("}\n")
+		p.add(b.String())
+	default:
+		if p.err == nil {
+			p.err = fmt.Errorf("unsupported named type: %T", t)
+		}
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
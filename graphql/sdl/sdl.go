@@ -0,0 +1,521 @@
+// Package sdl parses GraphQL schema definition language (SDL) documents.
+//
+// The resulting schema definitions have no resolvers and are not usable for a server as-is, but
+// they can be used for tooling such as validating queries or generating introspection results.
+package sdl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ccbrown/api-fu/graphql/scanner"
+	"github.com/ccbrown/api-fu/graphql/schema"
+	"github.com/ccbrown/api-fu/graphql/schema/introspection"
+	"github.com/ccbrown/api-fu/graphql/token"
+)
+
+// Error describes a syntax error in an SDL document.
+type Error struct {
+	Message string
+	Line    int
+	Column  int
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%v:%v: %v", err.Line, err.Column, err.Message)
+}
+
+// Parse parses an SDL document into introspection data. Type extensions are not supported.
+// Default values are preserved in GraphQL syntax, with strings re-quoted and whitespace
+// normalized.
+//
+// If the document has no schema definition, the types named "Query", "Mutation", and
+// "Subscription" are used as the root operation types.
+func Parse(src []byte) (data *introspection.SchemaData, err error) {
+	p := &parser{
+		scanner: scanner.New(src, 0),
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(*Error); ok {
+				data, err = nil, e
+			} else {
+				panic(r)
+			}
+		}
+	}()
+	p.consumeToken()
+	return p.parseDocument(), nil
+}
+
+// ParseSchemaDefinition parses an SDL document into a schema definition. See Parse for details.
+func ParseSchemaDefinition(src []byte) (*schema.SchemaDefinition, error) {
+	data, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return data.GetSchemaDefinition()
+}
+
+type parser struct {
+	scanner   *scanner.Scanner
+	token     token.Token
+	value     string
+	position  token.Position
+	recursion int
+}
+
+const maxRecursion = 1000
+
+func (p *parser) consumeToken() {
+	if p.scanner.Scan() {
+		p.token = p.scanner.Token()
+		p.value = p.scanner.StringValue()
+	} else {
+		p.token = token.INVALID
+		p.value = "EOF"
+	}
+	p.position = p.scanner.Position()
+	if errs := p.scanner.Errors(); len(errs) > 0 {
+		panic(&Error{
+			Message: errs[0].Message,
+			Line:    errs[0].Line,
+			Column:  errs[0].Column,
+		})
+	}
+}
+
+func (p *parser) errorf(message string, args ...interface{}) *Error {
+	return &Error{
+		Message: fmt.Sprintf(message, args...),
+		Line:    p.position.Line,
+		Column:  p.position.Column,
+	}
+}
+
+func (p *parser) isPunctuator(v string) bool {
+	return p.token == token.PUNCTUATOR && p.value == v
+}
+
+func (p *parser) isName(v string) bool {
+	return p.token == token.NAME && p.value == v
+}
+
+func (p *parser) expectPunctuator(v string) {
+	if !p.isPunctuator(v) {
+		panic(p.errorf("expected %v, found %v", v, p.value))
+	}
+	p.consumeToken()
+}
+
+func (p *parser) parseName() string {
+	if p.token != token.NAME {
+		panic(p.errorf("expected name, found %v", p.value))
+	}
+	ret := p.value
+	p.consumeToken()
+	return ret
+}
+
+func (p *parser) parseOptionalDescription() string {
+	if p.token == token.STRING_VALUE {
+		ret := p.value
+		p.consumeToken()
+		return ret
+	}
+	return ""
+}
+
+type rootOperationTypes struct {
+	query        string
+	mutation     string
+	subscription string
+}
+
+func (p *parser) parseDocument() *introspection.SchemaData {
+	ret := &introspection.SchemaData{}
+	var roots *rootOperationTypes
+	typeNames := map[string]struct{}{}
+
+	for p.token != token.INVALID {
+		description := p.parseOptionalDescription()
+		if p.token != token.NAME {
+			panic(p.errorf("expected definition, found %v", p.value))
+		}
+		switch keyword := p.value; keyword {
+		case "schema":
+			if roots != nil {
+				panic(p.errorf("multiple schema definitions"))
+			}
+			roots = p.parseSchemaDefinition()
+		case "scalar", "type", "interface", "union", "enum", "input":
+			t := p.parseTypeDefinition(description)
+			if _, ok := typeNames[t.Name]; ok {
+				panic(p.errorf("multiple definitions for type %v", t.Name))
+			}
+			typeNames[t.Name] = struct{}{}
+			ret.Types = append(ret.Types, t)
+		case "directive":
+			ret.Directives = append(ret.Directives, p.parseDirectiveDefinition(description))
+		case "extend":
+			panic(p.errorf("type extensions are not supported"))
+		default:
+			panic(p.errorf("unexpected %v", keyword))
+		}
+	}
+
+	// Make the built-in scalars available to the introspection data's schema definition.
+	for name := range schema.BuiltInTypes {
+		if _, ok := typeNames[name]; !ok {
+			ret.Types = append(ret.Types, introspection.TypeData{
+				Kind: "SCALAR",
+				Name: name,
+			})
+		}
+	}
+
+	if roots == nil {
+		roots = &rootOperationTypes{
+			query: "Query",
+		}
+		if _, ok := typeNames["Mutation"]; ok {
+			roots.mutation = "Mutation"
+		}
+		if _, ok := typeNames["Subscription"]; ok {
+			roots.subscription = "Subscription"
+		}
+	}
+	if roots.query == "" {
+		panic(p.errorf("schemas must define the query operation"))
+	}
+	ret.QueryType = introspection.TypeData{Name: roots.query}
+	if roots.mutation != "" {
+		ret.MutationType = &introspection.TypeData{Name: roots.mutation}
+	}
+	if roots.subscription != "" {
+		ret.SubscriptionType = &introspection.TypeData{Name: roots.subscription}
+	}
+	return ret
+}
+
+func (p *parser) parseSchemaDefinition() *rootOperationTypes {
+	p.consumeToken()
+	p.parseOptionalDirectives()
+	p.expectPunctuator("{")
+	ret := &rootOperationTypes{}
+	for !p.isPunctuator("}") {
+		operation := p.parseName()
+		p.expectPunctuator(":")
+		name := p.parseName()
+		switch operation {
+		case "query":
+			ret.query = name
+		case "mutation":
+			ret.mutation = name
+		case "subscription":
+			ret.subscription = name
+		default:
+			panic(p.errorf("unknown operation type: %v", operation))
+		}
+	}
+	p.consumeToken()
+	return ret
+}
+
+func (p *parser) parseTypeDefinition(description string) introspection.TypeData {
+	keyword := p.parseName()
+	ret := introspection.TypeData{
+		Name:        p.parseName(),
+		Description: description,
+	}
+
+	switch keyword {
+	case "scalar":
+		ret.Kind = "SCALAR"
+		ret.SpecifiedByURL = p.parseOptionalDirectives().specifiedByURL
+	case "type", "interface":
+		ret.Kind = "OBJECT"
+		if keyword == "interface" {
+			ret.Kind = "INTERFACE"
+		}
+		if p.isName("implements") {
+			p.consumeToken()
+			if p.isPunctuator("&") {
+				p.consumeToken()
+			}
+			for {
+				ret.Interfaces = append(ret.Interfaces, introspection.TypeData{
+					Kind: "INTERFACE",
+					Name: p.parseName(),
+				})
+				// Legacy documents may separate interfaces with commas, which are ignored.
+				if p.isPunctuator("&") {
+					p.consumeToken()
+				} else if p.token != token.NAME || p.peekDefinitionKeyword() {
+					break
+				}
+			}
+		}
+		p.parseOptionalDirectives()
+		if p.isPunctuator("{") {
+			p.consumeToken()
+			for !p.isPunctuator("}") {
+				ret.Fields = append(ret.Fields, p.parseFieldDefinition())
+			}
+			p.consumeToken()
+		}
+	case "union":
+		ret.Kind = "UNION"
+		p.parseOptionalDirectives()
+		if p.isPunctuator("=") {
+			p.consumeToken()
+			if p.isPunctuator("|") {
+				p.consumeToken()
+			}
+			for {
+				ret.PossibleTypes = append(ret.PossibleTypes, introspection.TypeData{
+					Kind: "OBJECT",
+					Name: p.parseName(),
+				})
+				if !p.isPunctuator("|") {
+					break
+				}
+				p.consumeToken()
+			}
+		}
+	case "enum":
+		ret.Kind = "ENUM"
+		p.parseOptionalDirectives()
+		if p.isPunctuator("{") {
+			p.consumeToken()
+			for !p.isPunctuator("}") {
+				value := introspection.EnumValueData{
+					Description: p.parseOptionalDescription(),
+					Name:        p.parseName(),
+				}
+				directives := p.parseOptionalDirectives()
+				value.IsDeprecated, value.DeprecationReason = directives.isDeprecated, directives.deprecationReason
+				ret.EnumValues = append(ret.EnumValues, value)
+			}
+			p.consumeToken()
+		}
+	case "input":
+		ret.Kind = "INPUT_OBJECT"
+		p.parseOptionalDirectives()
+		if p.isPunctuator("{") {
+			p.consumeToken()
+			for !p.isPunctuator("}") {
+				ret.InputFields = append(ret.InputFields, p.parseInputValueDefinition())
+			}
+			p.consumeToken()
+		}
+	}
+	return ret
+}
+
+// Type definitions may omit their bodies, so a name following an "implements" list may be the
+// start of the next definition rather than another interface.
+func (p *parser) peekDefinitionKeyword() bool {
+	switch p.value {
+	case "schema", "scalar", "type", "interface", "union", "enum", "input", "directive", "extend":
+		return true
+	}
+	return false
+}
+
+func (p *parser) parseFieldDefinition() introspection.FieldData {
+	ret := introspection.FieldData{
+		Description: p.parseOptionalDescription(),
+		Name:        p.parseName(),
+	}
+	ret.Args = p.parseOptionalArgumentsDefinition()
+	p.expectPunctuator(":")
+	ret.Type = p.parseType()
+	directives := p.parseOptionalDirectives()
+	ret.IsDeprecated, ret.DeprecationReason = directives.isDeprecated, directives.deprecationReason
+	return ret
+}
+
+func (p *parser) parseOptionalArgumentsDefinition() []introspection.InputValueData {
+	if !p.isPunctuator("(") {
+		return nil
+	}
+	p.consumeToken()
+	var ret []introspection.InputValueData
+	for !p.isPunctuator(")") {
+		ret = append(ret, p.parseInputValueDefinition())
+	}
+	p.consumeToken()
+	return ret
+}
+
+func (p *parser) parseInputValueDefinition() introspection.InputValueData {
+	ret := introspection.InputValueData{
+		Description: p.parseOptionalDescription(),
+		Name:        p.parseName(),
+	}
+	p.expectPunctuator(":")
+	ret.Type = p.parseType()
+	if p.isPunctuator("=") {
+		p.consumeToken()
+		defaultValue, _, _ := p.parseValue()
+		ret.DefaultValue = &defaultValue
+	}
+	directives := p.parseOptionalDirectives()
+	ret.IsDeprecated, ret.DeprecationReason = directives.isDeprecated, directives.deprecationReason
+	return ret
+}
+
+func (p *parser) parseType() introspection.TypeData {
+	p.recursion++
+	if p.recursion > maxRecursion {
+		panic(p.errorf("maximum recursion depth exceeded"))
+	}
+	defer func() {
+		p.recursion--
+	}()
+
+	var ret introspection.TypeData
+	if p.isPunctuator("[") {
+		p.consumeToken()
+		ofType := p.parseType()
+		p.expectPunctuator("]")
+		ret = introspection.TypeData{
+			Kind:   "LIST",
+			OfType: &ofType,
+		}
+	} else {
+		ret = introspection.TypeData{
+			Name: p.parseName(),
+		}
+	}
+	if p.isPunctuator("!") {
+		p.consumeToken()
+		ofType := ret
+		ret = introspection.TypeData{
+			Kind:   "NON_NULL",
+			OfType: &ofType,
+		}
+	}
+	return ret
+}
+
+// Parses a constant value and returns its string value if it is a string.
+// Parses a constant value, returning it in GraphQL syntax. If the value is a string, its contents
+// are also returned.
+func (p *parser) parseValue() (literal string, stringValue string, isString bool) {
+	p.recursion++
+	if p.recursion > maxRecursion {
+		panic(p.errorf("maximum recursion depth exceeded"))
+	}
+	defer func() {
+		p.recursion--
+	}()
+
+	switch p.token {
+	case token.INT_VALUE, token.FLOAT_VALUE, token.NAME:
+		ret := p.value
+		p.consumeToken()
+		return ret, "", false
+	case token.STRING_VALUE:
+		ret := p.value
+		p.consumeToken()
+		b, _ := json.Marshal(ret)
+		return string(b), ret, true
+	case token.PUNCTUATOR:
+		switch p.value {
+		case "[":
+			p.consumeToken()
+			var items []string
+			for !p.isPunctuator("]") {
+				item, _, _ := p.parseValue()
+				items = append(items, item)
+			}
+			p.consumeToken()
+			return "[" + strings.Join(items, ", ") + "]", "", false
+		case "{":
+			p.consumeToken()
+			var fields []string
+			for !p.isPunctuator("}") {
+				name := p.parseName()
+				p.expectPunctuator(":")
+				value, _, _ := p.parseValue()
+				fields = append(fields, name+": "+value)
+			}
+			p.consumeToken()
+			return "{" + strings.Join(fields, ", ") + "}", "", false
+		default:
+			panic(p.errorf("expected value, found %v", p.value))
+		}
+	default:
+		panic(p.errorf("expected value, found %v", p.value))
+	}
+}
+
+const defaultDeprecationReason = "No longer supported"
+
+// The information about directives that's carried over to introspection data.
+type parsedDirectives struct {
+	isDeprecated      bool
+	deprecationReason string
+	specifiedByURL    string
+}
+
+// Parses directives, returning whether a @deprecated directive was present and its reason, and the
+// URL of a @specifiedBy directive.
+func (p *parser) parseOptionalDirectives() (ret parsedDirectives) {
+	for p.isPunctuator("@") {
+		p.consumeToken()
+		name := p.parseName()
+		if name == "deprecated" {
+			ret.isDeprecated = true
+			ret.deprecationReason = defaultDeprecationReason
+		}
+		if p.isPunctuator("(") {
+			p.consumeToken()
+			for !p.isPunctuator(")") {
+				argName := p.parseName()
+				p.expectPunctuator(":")
+				if _, s, ok := p.parseValue(); ok {
+					if name == "deprecated" && argName == "reason" {
+						ret.deprecationReason = s
+					} else if name == "specifiedBy" && argName == "url" {
+						ret.specifiedByURL = s
+					}
+				}
+			}
+			p.consumeToken()
+		}
+	}
+	return ret
+}
+
+func (p *parser) parseDirectiveDefinition(description string) introspection.DirectiveData {
+	p.consumeToken()
+	p.expectPunctuator("@")
+	ret := introspection.DirectiveData{
+		Name:        p.parseName(),
+		Description: description,
+	}
+	ret.Args = p.parseOptionalArgumentsDefinition()
+	if p.isName("repeatable") {
+		p.consumeToken()
+		ret.IsRepeatable = true
+	}
+	if !p.isName("on") {
+		panic(p.errorf("expected on, found %v", p.value))
+	}
+	p.consumeToken()
+	if p.isPunctuator("|") {
+		p.consumeToken()
+	}
+	for {
+		ret.Locations = append(ret.Locations, p.parseName())
+		if !p.isPunctuator("|") {
+			break
+		}
+		p.consumeToken()
+	}
+	return ret
+}
@@ -0,0 +1,294 @@
+package sdl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+func TestParseSchemaDefinition(t *testing.T) {
+	def, err := ParseSchemaDefinition([]byte(`
+		schema {
+			query: RootQuery
+		}
+
+		"""
+		The root query type.
+		"""
+		type RootQuery {
+			"Gets a node by its id."
+			node(id: ID!, options: [Options!] = [{limit: 1}]): Node
+			search(text: String!): [SearchResult!]! @deprecated(reason: "Use node instead.")
+		}
+
+		interface Node {
+			id: ID!
+		}
+
+		type User implements & Node & Named {
+			id: ID!
+			name: String
+		}
+
+		interface Named {
+			name: String
+		}
+
+		type Post implements Node, Named {
+			id: ID!
+			name: String
+		}
+
+		union SearchResult = | User | Post
+
+		enum Status {
+			ACTIVE
+			INACTIVE @deprecated
+		}
+
+		input Options {
+			limit: Int = 10
+			status: Status = ACTIVE
+			state: Status @deprecated(reason: "Use status instead.")
+		}
+
+		scalar DateTime @specifiedBy(url: "https://example.com")
+
+		directive @auth(requires: String = "admin") repeatable on FIELD_DEFINITION | OBJECT
+	`))
+	require.NoError(t, err)
+
+	s, err := schema.New(def)
+	require.NoError(t, err)
+
+	assert.Equal(t, "RootQuery", s.QueryType().Name)
+	assert.Equal(t, "The root query type.", s.QueryType().Description)
+	assert.Nil(t, s.MutationType())
+
+	node := s.QueryType().Fields["node"]
+	assert.Equal(t, "Gets a node by its id.", node.Description)
+	assert.Equal(t, "Node", node.Type.String())
+	assert.Equal(t, "ID!", node.Arguments["id"].Type.String())
+	assert.Equal(t, "[Options!]", node.Arguments["options"].Type.String())
+	assert.Equal(t, []interface{}{map[string]interface{}{"limit": 1}}, node.Arguments["options"].DefaultValue)
+
+	search := s.QueryType().Fields["search"]
+	assert.Equal(t, "[SearchResult!]!", search.Type.String())
+	assert.Equal(t, "Use node instead.", search.DeprecationReason)
+
+	user := s.NamedTypes()["User"].(*schema.ObjectType)
+	require.Len(t, user.ImplementedInterfaces, 2)
+	assert.Len(t, s.InterfaceImplementations("Named"), 2)
+
+	assert.Len(t, s.NamedTypes()["SearchResult"].(*schema.UnionType).MemberTypes, 2)
+
+	status := s.NamedTypes()["Status"].(*schema.EnumType)
+	assert.Empty(t, status.Values["ACTIVE"].DeprecationReason)
+	assert.Equal(t, "No longer supported", status.Values["INACTIVE"].DeprecationReason)
+
+	options := s.NamedTypes()["Options"].(*schema.InputObjectType)
+	assert.Equal(t, 10, options.Fields["limit"].DefaultValue)
+	assert.Equal(t, "ACTIVE", options.Fields["status"].DefaultValue)
+	assert.Equal(t, "Use status instead.", options.Fields["state"].DeprecationReason)
+
+	assert.Equal(t, "https://example.com", s.NamedTypes()["DateTime"].(*schema.ScalarType).SpecifiedByURL)
+	assert.Equal(t, []schema.DirectiveLocation{schema.DirectiveLocationFieldDefinition, schema.DirectiveLocationObject}, s.Directives()["auth"].Locations)
+	assert.Equal(t, "admin", s.Directives()["auth"].Arguments["requires"].DefaultValue)
+	assert.True(t, s.Directives()["auth"].IsRepeatable)
+}
+
+func TestParse_DefaultRootOperationTypes(t *testing.T) {
+	data, err := Parse([]byte(`
+		type Query { a: Int }
+		type Mutation { b: Int }
+	`))
+	require.NoError(t, err)
+	assert.Equal(t, "Query", data.QueryType.Name)
+	require.NotNil(t, data.MutationType)
+	assert.Equal(t, "Mutation", data.MutationType.Name)
+	assert.Nil(t, data.SubscriptionType)
+}
+
+func TestParse_DefaultValues(t *testing.T) {
+	data, err := Parse([]byte(`
+		type Query {
+			a(x: Int = 1, y: [String] = ["a",  """b"""], z: In = {b: [1,2] a: null}, w: Int): Int
+		}
+	`))
+	require.NoError(t, err)
+	require.Equal(t, "Query", data.Types[0].Name)
+	args := data.Types[0].Fields[0].Args
+	require.Len(t, args, 4)
+	require.NotNil(t, args[0].DefaultValue)
+	assert.Equal(t, "1", *args[0].DefaultValue)
+	require.NotNil(t, args[1].DefaultValue)
+	assert.Equal(t, `["a", "b"]`, *args[1].DefaultValue)
+	require.NotNil(t, args[2].DefaultValue)
+	assert.Equal(t, "{b: [1, 2], a: null}", *args[2].DefaultValue)
+	assert.Nil(t, args[3].DefaultValue)
+}
+
+func TestParse_Errors(t *testing.T) {
+	for name, src := range map[string]string{
+		"UnexpectedToken":  `type Query { a: }`,
+		"UnexpectedEOF":    `type Query { a: Int`,
+		"Extension":        `type Query { a: Int } extend type Query { b: Int }`,
+		"DuplicateType":    `type Query { a: Int } type Query { b: Int }`,
+		"UnknownOperation": `schema { foo: Query } type Query { a: Int }`,
+		"IllegalCharacter": `type Query { a: Int% }`,
+		"UnknownKeyword":   `foo Query { a: Int }`,
+		"MissingLocations": `directive @foo on`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse([]byte(src))
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := Parse([]byte("type Query {\n  a: \n}"))
+	assert.EqualError(t, err, "3:1: expected name, found }")
+}
+
+func TestPrint(t *testing.T) {
+	src := `schema {
+  query: RootQuery
+}
+
+"A custom directive."
+directive @auth(requires: String = "admin") repeatable on FIELD_DEFINITION | OBJECT
+
+interface Named {
+  name: String
+}
+
+"""
+The root query type.
+
+It has multiple lines.
+"""
+type RootQuery {
+  "Gets a node by its id."
+  node(id: ID!, options: [Options!] = [{limit: 1, status: ACTIVE}]): Named
+  search(text: String!): [SearchResult!]! @deprecated(reason: "Use node instead.")
+}
+
+union SearchResult = Post | User
+
+enum Status {
+  ACTIVE
+  INACTIVE @deprecated(reason: "No longer supported")
+}
+
+input Options {
+  limit: Int = 10
+  state: Status @deprecated(reason: "Use status instead.")
+  status: Status
+}
+
+type Post implements Named {
+  name: String
+}
+
+type User implements Named {
+  name: String
+}
+`
+	def, err := ParseSchemaDefinition([]byte(src))
+	require.NoError(t, err)
+	s, err := schema.New(def)
+	require.NoError(t, err)
+
+	printed, err := Print(s)
+	require.NoError(t, err)
+
+	// Everything should be sorted except the schema definition, which comes first.
+	assert.Contains(t, printed, "union SearchResult = Post | User\n")
+	assert.True(t, strings.HasPrefix(printed, "schema {\n  query: RootQuery\n}\n"))
+	assert.Less(t, strings.Index(printed, "type Post"), strings.Index(printed, "type RootQuery"))
+
+	// Printing the parsed output should produce the same output.
+	def, err = ParseSchemaDefinition([]byte(printed))
+	require.NoError(t, err)
+	s, err = schema.New(def)
+	require.NoError(t, err)
+	reprinted, err := Print(s)
+	require.NoError(t, err)
+	assert.Equal(t, printed, reprinted)
+}
+
+func TestPrint_DefaultValues(t *testing.T) {
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"list": {
+					Type: schema.IntType,
+					Arguments: map[string]*schema.InputValueDefinition{
+						"first": {
+							Type:         schema.IntType,
+							DefaultValue: 10,
+						},
+						"after": {
+							Type: schema.StringType,
+						},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	printed, err := Print(s)
+	require.NoError(t, err)
+	assert.Equal(t, "type Query {\n  list(after: String, first: Int = 10): Int\n}\n", printed)
+}
+
+func TestPrint_Escaping(t *testing.T) {
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name:        "Query",
+			Description: "Multiple lines\nwith a \\ backslash and \"\"\" quotes.",
+			Fields: map[string]*schema.FieldDefinition{
+				"path": {
+					Type:              schema.StringType,
+					Description:       `A path such as "C:\Users".`,
+					DeprecationReason: `Use \n instead.`,
+					Arguments: map[string]*schema.InputValueDefinition{
+						"separator": {
+							Type:         schema.StringType,
+							DefaultValue: `\`,
+						},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	printed, err := Print(s)
+	require.NoError(t, err)
+	assert.Equal(t, `"""
+Multiple lines
+with a \ backslash and \""" quotes.
+"""
+type Query {
+  "A path such as \"C:\\Users\"."
+  path(separator: String = "\\"): String @deprecated(reason: "Use \\n instead.")
+}
+`, printed)
+
+	// The values must survive a round trip.
+	def, err := ParseSchemaDefinition([]byte(printed))
+	require.NoError(t, err)
+	parsed, err := schema.New(def)
+	require.NoError(t, err)
+	assert.Equal(t, s.QueryType().Description, parsed.QueryType().Description)
+	field := parsed.QueryType().Fields["path"]
+	assert.Equal(t, `A path such as "C:\Users".`, field.Description)
+	assert.Equal(t, `Use \n instead.`, field.DeprecationReason)
+	assert.Equal(t, `\`, field.Arguments["separator"].DefaultValue)
+}
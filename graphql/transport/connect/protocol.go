@@ -0,0 +1,269 @@
+package connect
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// ServiceName is the fully-qualified name of the service defined in graphql.proto.
+const ServiceName = "apifu.graphql.v1.GraphQLService"
+
+// The paths of the service's procedures, relative to the service's base URL.
+const (
+	ExecuteProcedure   = "/" + ServiceName + "/Execute"
+	SubscribeProcedure = "/" + ServiceName + "/Subscribe"
+)
+
+// The content types used for the JSON codec.
+const (
+	UnaryContentType     = "application/json"
+	StreamingContentType = "application/connect+json"
+)
+
+// Request represents the service's request message.
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// Code represents a Connect error code.
+type Code string
+
+// Code represents a Connect error code.
+const (
+	CodeCanceled           Code = "canceled"
+	CodeUnknown            Code = "unknown"
+	CodeInvalidArgument    Code = "invalid_argument"
+	CodeDeadlineExceeded   Code = "deadline_exceeded"
+	CodeNotFound           Code = "not_found"
+	CodePermissionDenied   Code = "permission_denied"
+	CodeResourceExhausted  Code = "resource_exhausted"
+	CodeFailedPrecondition Code = "failed_precondition"
+	CodeUnimplemented      Code = "unimplemented"
+	CodeInternal           Code = "internal"
+	CodeUnavailable        Code = "unavailable"
+	CodeUnauthenticated    Code = "unauthenticated"
+)
+
+// HTTPStatus returns the HTTP status used for unary responses with the error code.
+func (c Code) HTTPStatus() int {
+	switch c {
+	case CodeCanceled:
+		return 499
+	case CodeInvalidArgument:
+		return http.StatusBadRequest
+	case CodeDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case CodeNotFound, CodeUnimplemented:
+		return http.StatusNotFound
+	case CodePermissionDenied:
+		return http.StatusForbidden
+	case CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	case CodeFailedPrecondition:
+		return http.StatusPreconditionFailed
+	case CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case CodeUnauthenticated:
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// Error represents a Connect error. Note that GraphQL errors are part of the response message and
+// aren't represented as Connect errors.
+type Error struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message,omitempty"`
+
+	// If non-zero, this overrides the code's HTTP status for unary responses.
+	httpStatus int
+}
+
+func (err *Error) Error() string {
+	if err.Message == "" {
+		return string(err.Code)
+	}
+	return fmt.Sprintf("%v: %v", err.Code, err.Message)
+}
+
+// The flags of enveloped messages.
+const (
+	envelopeFlagCompressed = 0x01
+	envelopeFlagEndStream  = 0x02
+)
+
+// ReadRequest reads the request message from a unary or server streaming request. The message of a
+// streaming request is expected to be enveloped. If maxMessageSize is positive, larger messages are
+// rejected.
+func ReadRequest(r *http.Request, streaming bool, maxMessageSize int64, unmarshal func(data []byte, v interface{}) error) (*Request, *Error) {
+	if r.Method != http.MethodPost {
+		return nil, &Error{
+			Code:    CodeUnimplemented,
+			Message: "Only POST requests are supported.",
+		}
+	}
+
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return nil, &Error{
+			Code:    CodeUnimplemented,
+			Message: "Compression is not supported.",
+		}
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var body []byte
+	switch {
+	case contentType == UnaryContentType && !streaming:
+		var err *Error
+		body, err = readAll(r.Body, maxMessageSize)
+		if err != nil {
+			return nil, err
+		}
+	case contentType == StreamingContentType && streaming:
+		var header [5]byte
+		if _, err := io.ReadFull(r.Body, header[:]); err != nil {
+			return nil, &Error{
+				Code:    CodeInvalidArgument,
+				Message: "Unable to read message envelope.",
+			}
+		} else if header[0]&envelopeFlagCompressed != 0 {
+			return nil, &Error{
+				Code:    CodeUnimplemented,
+				Message: "Compression is not supported.",
+			}
+		}
+		size := int64(binary.BigEndian.Uint32(header[1:]))
+		if maxMessageSize > 0 && size > maxMessageSize {
+			return nil, &Error{
+				Code:    CodeResourceExhausted,
+				Message: "The request message is too large.",
+			}
+		}
+		body = make([]byte, size)
+		if _, err := io.ReadFull(r.Body, body); err != nil {
+			return nil, &Error{
+				Code:    CodeInvalidArgument,
+				Message: "Unable to read message.",
+			}
+		}
+	default:
+		return nil, &Error{
+			Code:       CodeUnimplemented,
+			Message:    "Only the JSON codec is supported.",
+			httpStatus: http.StatusUnsupportedMediaType,
+		}
+	}
+
+	var req Request
+	if err := unmarshal(body, &req); err != nil {
+		return nil, &Error{
+			Code:    CodeInvalidArgument,
+			Message: "Malformed request message.",
+		}
+	}
+	return &req, nil
+}
+
+func readAll(r io.Reader, maxSize int64) ([]byte, *Error) {
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize+1)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &Error{
+			Code:    CodeInvalidArgument,
+			Message: "Unable to read message.",
+		}
+	} else if maxSize > 0 && int64(len(body)) > maxSize {
+		return nil, &Error{
+			Code:    CodeResourceExhausted,
+			Message: "The request message is too large.",
+		}
+	}
+	return body, nil
+}
+
+// WriteUnaryResponse writes a successful unary response with the given message.
+func WriteUnaryResponse(w http.ResponseWriter, message []byte) {
+	w.Header().Set("Content-Type", UnaryContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(message)
+}
+
+// WriteUnaryError writes an unsuccessful unary response.
+func WriteUnaryError(w http.ResponseWriter, err *Error) {
+	body, _ := json.Marshal(err)
+	status := err.httpStatus
+	if status == 0 {
+		status = err.Code.HTTPStatus()
+	}
+	w.Header().Set("Content-Type", UnaryContentType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// StreamWriter writes the messages of a server streaming response.
+type StreamWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	closed  bool
+}
+
+// NewStreamWriter writes the headers of a server streaming response and returns a StreamWriter
+// for its messages. Errors are always sent at the end of the stream, so the response status is
+// always 200 OK.
+func NewStreamWriter(w http.ResponseWriter) *StreamWriter {
+	w.Header().Set("Content-Type", StreamingContentType)
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	return &StreamWriter{
+		w:       w,
+		flusher: flusher,
+	}
+}
+
+func (s *StreamWriter) writeEnvelope(flags byte, message []byte) error {
+	buf := make([]byte, 5+len(message))
+	buf[0] = flags
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(message)))
+	copy(buf[5:], message)
+	if _, err := s.w.Write(buf); err != nil {
+		return err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return nil
+}
+
+// WriteMessage writes a response message to the stream.
+func (s *StreamWriter) WriteMessage(message []byte) error {
+	if s.closed {
+		return fmt.Errorf("stream is closed")
+	}
+	return s.writeEnvelope(0, message)
+}
+
+// Close ends the stream. If err is non-nil, the stream ends with the error.
+func (s *StreamWriter) Close(err *Error) error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	var endStream struct {
+		Error *Error `json:"error,omitempty"`
+	}
+	endStream.Error = err
+	message, _ := json.Marshal(endStream)
+	return s.writeEnvelope(envelopeFlagEndStream, message)
+}
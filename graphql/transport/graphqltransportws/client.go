@@ -0,0 +1,649 @@
+package graphqltransportws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/transport"
+)
+
+// Client is a client for the "graphql-transport-ws" protocol. All of its subscriptions share a
+// single connection, which is only open while there are active subscriptions. If the connection is
+// lost, the client reconnects and resubscribes to the subscriptions that were active.
+//
+// Clients must not be copied after first use.
+type Client struct {
+	// The URL of the server, e.g. "wss://example.com/graphql".
+	URL string
+
+	// The dialer to use. If nil, websocket.DefaultDialer is used. The dialer's subprotocols are
+	// always replaced with WebSocketSubprotocol.
+	Dialer *websocket.Dialer
+
+	// If given, these headers are sent with the WebSocket handshake.
+	Header http.Header
+
+	// If given, this is invoked for each connection and its result is sent as the payload of the
+	// "connection_init" message. This is typically used for authentication. If it returns an error,
+	// the connection attempt fails and is retried later.
+	InitPayload func(ctx context.Context) (interface{}, error)
+
+	// If true, the client opts in to receiving large results as "chunk" messages by adding the
+	// transport.PayloadChunkingInitParameter property to the init payload. The init payload must
+	// then be a JSON object or nil.
+	AcceptPayloadChunks bool
+
+	// The delay before the first reconnection attempt. If zero, the default of 500 milliseconds is
+	// used. Consecutive failures double the delay up to MaxReconnectDelay.
+	MinReconnectDelay time.Duration
+
+	// The maximum delay between reconnection attempts. If zero, the default of 30 seconds is used.
+	MaxReconnectDelay time.Duration
+
+	// The interval at which "ping" messages are sent once the connection is acknowledged. If the
+	// server doesn't send any message for twice this interval, the connection is considered lost
+	// and the client reconnects. If zero, the default of 15 seconds is used. If negative, pings
+	// aren't sent and the connection never times out.
+	PingInterval time.Duration
+
+	// The maximum number of results that each subscription queues while waiting for them to be
+	// received. If a subscription's queue is full, the subscription ends with ErrResultQueueFull.
+	// If zero, the default of 1000 is used.
+	MaxQueuedResults int
+
+	// If given, this is invoked with errors that cause the client to reconnect.
+	LogError func(err error)
+
+	initOnce      sync.Once
+	ctx           context.Context
+	cancel        context.CancelFunc
+	mutex         sync.Mutex
+	subscriptions map[string]*ClientSubscription
+	nextId        int
+	conn          *websocket.Conn
+	running       bool
+	closed        bool
+}
+
+const (
+	defaultMinReconnectDelay = 500 * time.Millisecond
+	defaultMaxReconnectDelay = 30 * time.Second
+	defaultPingInterval      = 15 * time.Second
+	defaultMaxQueuedResults  = 1000
+
+	clientAckTimeout   = 10 * time.Second
+	clientWriteTimeout = 5 * time.Second
+)
+
+// ErrClientClosed is the error that subscriptions end with when their client is closed.
+var ErrClientClosed = errors.New("client closed")
+
+// ErrResultQueueFull is the error that subscriptions end with when their results aren't received
+// quickly enough. See Client.MaxQueuedResults.
+var ErrResultQueueFull = errors.New("result queue full")
+
+// SubscribePayload is the payload of a "subscribe" message.
+type SubscribePayload struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Result is the payload of a "next" message. Data is left encoded so that it can be decoded into
+// the types generated for the operation.
+type Result struct {
+	Data       json.RawMessage        `json:"data,omitempty"`
+	Errors     []*graphql.Error       `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// ResultError is returned for results that contain errors. Subscriptions also end with a
+// ResultError when the server rejects them with an "error" message.
+type ResultError struct {
+	Errors []*graphql.Error
+}
+
+func (err *ResultError) Error() string {
+	messages := make([]string, len(err.Errors))
+	for i, e := range err.Errors {
+		messages[i] = e.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (c *Client) init() {
+	c.initOnce.Do(func() {
+		c.ctx, c.cancel = context.WithCancel(context.Background())
+		c.subscriptions = map[string]*ClientSubscription{}
+	})
+}
+
+// Subscribe starts a subscription. The subscription ends when the server completes it, when the
+// context is canceled, or when it's stopped via ClientSubscription.Stop. Until then, it survives
+// reconnections. Servers may deliver duplicate or missed events across reconnections unless the
+// subscription was designed to handle them.
+func (c *Client) Subscribe(ctx context.Context, payload *SubscribePayload) *ClientSubscription {
+	c.init()
+
+	sub := &ClientSubscription{
+		client:  c,
+		results: make(chan *Result),
+		notify:  make(chan struct{}, 1),
+		stopped: make(chan struct{}),
+	}
+	go sub.forward(ctx)
+
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		sub.stop(errors.Wrap(err, "unable to marshal subscribe payload"))
+		return sub
+	}
+	sub.payload = buf
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		sub.stop(ErrClientClosed)
+		return sub
+	}
+
+	c.nextId++
+	sub.id = strconv.Itoa(c.nextId)
+	c.subscriptions[sub.id] = sub
+
+	if c.conn != nil {
+		if err := c.writeMessage(c.conn, &Message{
+			Id:      sub.id,
+			Type:    MessageTypeSubscribe,
+			Payload: sub.payload,
+		}); err != nil {
+			// Closing the connection makes the read loop reconnect, which resubscribes.
+			c.conn.Close()
+		}
+	} else if !c.running {
+		c.running = true
+		go c.run()
+	}
+	return sub
+}
+
+// Close ends all subscriptions with ErrClientClosed and closes the connection. The client can't
+// be used afterwards.
+func (c *Client) Close() error {
+	c.init()
+
+	c.mutex.Lock()
+	c.closed = true
+	subscriptions := c.subscriptions
+	c.subscriptions = map[string]*ClientSubscription{}
+	if c.conn != nil {
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "client closed"), time.Now().Add(time.Second))
+		c.conn.Close()
+	}
+	c.mutex.Unlock()
+
+	c.cancel()
+	for _, sub := range subscriptions {
+		sub.stop(ErrClientClosed)
+	}
+	return nil
+}
+
+// Must be invoked with the mutex held.
+func (c *Client) writeMessage(conn *websocket.Conn, msg *Message) error {
+	conn.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+	return conn.WriteJSON(msg)
+}
+
+func (c *Client) unsubscribe(sub *ClientSubscription, err error) {
+	c.mutex.Lock()
+	if c.subscriptions[sub.id] == sub {
+		delete(c.subscriptions, sub.id)
+		if c.conn != nil {
+			c.writeMessage(c.conn, &Message{
+				Id:   sub.id,
+				Type: MessageTypeComplete,
+			})
+			if len(c.subscriptions) == 0 {
+				// The read loop will exit and the connection won't be re-established until
+				// there's a new subscription.
+				c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "no active subscriptions"), time.Now().Add(time.Second))
+				c.conn.Close()
+				c.conn = nil
+			}
+		}
+	}
+	c.mutex.Unlock()
+	sub.stop(err)
+}
+
+// Connects and serves connections until there are no more subscriptions.
+func (c *Client) run() {
+	minDelay := c.MinReconnectDelay
+	if minDelay <= 0 {
+		minDelay = defaultMinReconnectDelay
+	}
+	maxDelay := c.MaxReconnectDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxReconnectDelay
+	}
+
+	var delay time.Duration
+	for {
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-c.ctx.Done():
+				timer.Stop()
+			}
+		}
+
+		c.mutex.Lock()
+		if c.closed || len(c.subscriptions) == 0 {
+			c.running = false
+			c.mutex.Unlock()
+			return
+		}
+		c.mutex.Unlock()
+
+		acked, err := c.connectAndServe()
+		if closeErr, ok := err.(*websocket.CloseError); ok && isTerminalCloseCode(closeErr.Code) {
+			c.mutex.Lock()
+			subscriptions := c.subscriptions
+			c.subscriptions = map[string]*ClientSubscription{}
+			c.mutex.Unlock()
+			for _, sub := range subscriptions {
+				sub.stop(err)
+			}
+			delay = 0
+			continue
+		}
+
+		c.mutex.Lock()
+		active := !c.closed && len(c.subscriptions) > 0
+		c.mutex.Unlock()
+		if !active {
+			delay = 0
+			continue
+		}
+
+		if c.LogError != nil {
+			c.LogError(err)
+		}
+		if acked {
+			delay = minDelay
+		} else {
+			delay *= 2
+			if delay < minDelay {
+				delay = minDelay
+			} else if delay > maxDelay {
+				delay = maxDelay
+			}
+		}
+	}
+}
+
+// The server uses these codes to indicate that the client did something wrong or isn't authorized.
+// Reconnecting won't help.
+func isTerminalCloseCode(code int) bool {
+	return code == 4400 || code == 4403
+}
+
+// Establishes a connection, resubscribes, and then reads from it until it fails. The returned
+// boolean indicates whether the server acknowledged the connection.
+func (c *Client) connectAndServe() (bool, error) {
+	dialer := websocket.DefaultDialer
+	if c.Dialer != nil {
+		dialer = c.Dialer
+	}
+	d := *dialer
+	d.Subprotocols = []string{WebSocketSubprotocol}
+
+	conn, _, err := d.DialContext(c.ctx, c.URL, c.Header)
+	if err != nil {
+		return false, errors.Wrap(err, "unable to dial")
+	}
+	defer conn.Close()
+
+	// Make sure that Close interrupts reads that happen before the connection is acknowledged.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	init := &Message{
+		Type: MessageTypeConnectionInit,
+	}
+	if c.InitPayload != nil {
+		payload, err := c.InitPayload(c.ctx)
+		if err != nil {
+			return false, errors.Wrap(err, "unable to get init payload")
+		}
+		buf, err := json.Marshal(payload)
+		if err != nil {
+			return false, errors.Wrap(err, "unable to marshal init payload")
+		}
+		init.Payload = buf
+	}
+	if c.AcceptPayloadChunks {
+		params := map[string]json.RawMessage{}
+		if len(init.Payload) > 0 && string(init.Payload) != "null" {
+			if err := json.Unmarshal(init.Payload, &params); err != nil {
+				return false, errors.Wrap(err, "init payload must be an object to accept payload chunks")
+			}
+		}
+		params[transport.PayloadChunkingInitParameter] = json.RawMessage("true")
+		buf, err := json.Marshal(params)
+		if err != nil {
+			return false, errors.Wrap(err, "unable to marshal init payload")
+		}
+		init.Payload = buf
+	}
+	conn.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+	if err := conn.WriteJSON(init); err != nil {
+		return false, errors.Wrap(err, "unable to send connection init")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(clientAckTimeout))
+	for acked := false; !acked; {
+		var msg Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*websocket.CloseError); ok {
+				return false, err
+			}
+			return false, errors.Wrap(err, "unable to read connection ack")
+		}
+		switch msg.Type {
+		case MessageTypeConnectionAck:
+			acked = true
+		case MessageTypePing, MessageTypePong:
+			// pings are answered once we're acknowledged
+		default:
+			return false, errors.Errorf("unexpected message before connection ack: %v", msg.Type)
+		}
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	pingInterval := c.PingInterval
+	if pingInterval == 0 {
+		pingInterval = defaultPingInterval
+	}
+
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return true, ErrClientClosed
+	}
+	c.conn = conn
+	for id, sub := range c.subscriptions {
+		if err := c.writeMessage(conn, &Message{
+			Id:      id,
+			Type:    MessageTypeSubscribe,
+			Payload: sub.payload,
+		}); err != nil {
+			c.conn = nil
+			c.mutex.Unlock()
+			return true, errors.Wrap(err, "unable to resubscribe")
+		}
+	}
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		c.conn = nil
+		c.mutex.Unlock()
+	}()
+
+	if pingInterval > 0 {
+		go c.sendPings(conn, pingInterval, done)
+	}
+
+	// Chunks are reassembled per operation. See transport.PayloadChunk.
+	chunks := map[string][]byte{}
+
+	for {
+		if pingInterval > 0 {
+			conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+		}
+		var msg Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			if _, ok := err.(*websocket.CloseError); ok {
+				return true, err
+			}
+			return true, errors.Wrap(err, "websocket read error")
+		}
+
+		switch msg.Type {
+		case MessageTypeNext:
+			var result Result
+			if err := json.Unmarshal(msg.Payload, &result); err != nil {
+				return true, errors.Wrap(err, "unable to unmarshal result")
+			}
+			if sub := c.subscription(msg.Id); sub != nil {
+				sub.push(&result)
+			}
+		case MessageTypeChunk:
+			var chunk transport.PayloadChunk
+			if err := json.Unmarshal(msg.Payload, &chunk); err != nil {
+				return true, errors.Wrap(err, "unable to unmarshal payload chunk")
+			}
+			if chunk.Index == 0 {
+				chunks[msg.Id] = nil
+			}
+			chunks[msg.Id] = append(chunks[msg.Id], chunk.Data...)
+			if chunk.Index == chunk.Count-1 {
+				payload := chunks[msg.Id]
+				delete(chunks, msg.Id)
+				var result Result
+				if err := json.Unmarshal(payload, &result); err != nil {
+					return true, errors.Wrap(err, "unable to unmarshal chunked result")
+				}
+				if sub := c.subscription(msg.Id); sub != nil {
+					sub.push(&result)
+				}
+			}
+		case MessageTypeError:
+			var errs []*graphql.Error
+			if err := json.Unmarshal(msg.Payload, &errs); err != nil {
+				return true, errors.Wrap(err, "unable to unmarshal errors")
+			}
+			if sub := c.removeSubscription(msg.Id); sub != nil {
+				sub.stop(&ResultError{
+					Errors: errs,
+				})
+			}
+		case MessageTypeComplete:
+			delete(chunks, msg.Id)
+			if sub := c.removeSubscription(msg.Id); sub != nil {
+				sub.finish(nil)
+			}
+		case MessageTypePing:
+			c.mutex.Lock()
+			err := c.writeMessage(conn, &Message{
+				Type: MessageTypePong,
+			})
+			c.mutex.Unlock()
+			if err != nil {
+				return true, errors.Wrap(err, "unable to send pong")
+			}
+		case MessageTypePong:
+			// do nothing
+		}
+	}
+}
+
+// Sends pings until done is closed. If a ping can't be sent, the connection is closed.
+func (c *Client) sendPings(conn *websocket.Conn, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.mutex.Lock()
+			err := c.writeMessage(conn, &Message{
+				Type: MessageTypePing,
+			})
+			c.mutex.Unlock()
+			if err != nil {
+				conn.Close()
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (c *Client) subscription(id string) *ClientSubscription {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.subscriptions[id]
+}
+
+func (c *Client) removeSubscription(id string) *ClientSubscription {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	sub := c.subscriptions[id]
+	delete(c.subscriptions, id)
+	return sub
+}
+
+// ClientSubscription represents a subscription started via Client.Subscribe.
+type ClientSubscription struct {
+	client  *Client
+	id      string
+	payload json.RawMessage
+	results chan *Result
+
+	// Results are queued so that slow consumers don't block the connection's other subscriptions.
+	mutex    sync.Mutex
+	queue    []*Result
+	notify   chan struct{}
+	finished bool
+	err      error
+
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// Results returns the channel that the subscription's results are sent to. The channel is closed
+// when the subscription ends.
+func (s *ClientSubscription) Results() <-chan *Result {
+	return s.results
+}
+
+// Err returns the reason that the subscription ended. It returns nil if the subscription hasn't
+// ended, if the server completed it, or if it was stopped via Stop. It should be invoked after the
+// results channel is closed.
+func (s *ClientSubscription) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
+
+// Stop stops the subscription. Results that haven't been received are discarded.
+func (s *ClientSubscription) Stop() {
+	s.client.unsubscribe(s, nil)
+}
+
+func (s *ClientSubscription) push(result *Result) {
+	maxQueued := s.client.MaxQueuedResults
+	if maxQueued <= 0 {
+		maxQueued = defaultMaxQueuedResults
+	}
+
+	s.mutex.Lock()
+	full := len(s.queue) >= maxQueued
+	if !s.finished && !full {
+		s.queue = append(s.queue, result)
+	}
+	s.mutex.Unlock()
+
+	if full {
+		s.client.unsubscribe(s, ErrResultQueueFull)
+		return
+	}
+	s.signal()
+}
+
+// Ends the subscription after any queued results are delivered.
+func (s *ClientSubscription) finish(err error) {
+	s.mutex.Lock()
+	if !s.finished {
+		s.finished = true
+		s.err = err
+	}
+	s.mutex.Unlock()
+	s.signal()
+}
+
+// Ends the subscription immediately.
+func (s *ClientSubscription) stop(err error) {
+	s.finish(err)
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+	})
+}
+
+func (s *ClientSubscription) signal() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *ClientSubscription) forward(ctx context.Context) {
+	defer close(s.results)
+
+	for {
+		s.mutex.Lock()
+		if len(s.queue) > 0 {
+			result := s.queue[0]
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+			s.mutex.Unlock()
+
+			select {
+			case s.results <- result:
+				continue
+			case <-s.stopped:
+				return
+			case <-ctx.Done():
+				s.client.unsubscribe(s, ctx.Err())
+				return
+			}
+		}
+		finished := s.finished
+		s.mutex.Unlock()
+
+		if finished {
+			return
+		}
+
+		select {
+		case <-s.notify:
+		case <-s.stopped:
+			return
+		case <-ctx.Done():
+			s.client.unsubscribe(s, ctx.Err())
+			return
+		}
+	}
+}
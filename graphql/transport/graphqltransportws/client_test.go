@@ -0,0 +1,333 @@
+package graphqltransportws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql/transport"
+)
+
+// Starts a server that acknowledges connections and then hands them to the given function along
+// with the index of the connection.
+func newTestClientServer(t *testing.T, serve func(conn *websocket.Conn, n int)) (*httptest.Server, *Client) {
+	var mutex sync.Mutex
+	connections := 0
+	upgrader := &websocket.Upgrader{
+		Subprotocols: []string{WebSocketSubprotocol},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		mutex.Lock()
+		n := connections
+		connections++
+		mutex.Unlock()
+
+		serve(conn, n)
+	}))
+	t.Cleanup(ts.Close)
+
+	client := &Client{
+		URL:               "ws" + strings.TrimPrefix(ts.URL, "http"),
+		MinReconnectDelay: 10 * time.Millisecond,
+	}
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return ts, client
+}
+
+func readTestMessage(t *testing.T, conn *websocket.Conn, expected MessageType) *Message {
+	var msg Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	require.Equal(t, expected, msg.Type)
+	return &msg
+}
+
+func acceptTestConnection(t *testing.T, conn *websocket.Conn) {
+	readTestMessage(t, conn, MessageTypeConnectionInit)
+	require.NoError(t, conn.WriteJSON(&Message{Type: MessageTypeConnectionAck}))
+}
+
+func writeTestResult(t *testing.T, conn *websocket.Conn, id, data string) {
+	require.NoError(t, conn.WriteJSON(&Message{
+		Id:      id,
+		Type:    MessageTypeNext,
+		Payload: json.RawMessage(`{"data":` + data + `}`),
+	}))
+}
+
+func collectResults(sub *ClientSubscription) []string {
+	var ret []string
+	for result := range sub.Results() {
+		if len(result.Errors) > 0 {
+			ret = append(ret, "error: "+result.Errors[0].Message)
+		} else {
+			ret = append(ret, string(result.Data))
+		}
+	}
+	return ret
+}
+
+func TestClient(t *testing.T) {
+	_, client := newTestClientServer(t, func(conn *websocket.Conn, n int) {
+		var init Message
+		require.NoError(t, conn.ReadJSON(&init))
+		assert.JSONEq(t, `{"token":"foo"}`, string(init.Payload))
+		require.NoError(t, conn.WriteJSON(&Message{Type: MessageTypeConnectionAck}))
+
+		msg := readTestMessage(t, conn, MessageTypeSubscribe)
+		assert.JSONEq(t, `{"query":"subscription{n}","variables":{"x":1}}`, string(msg.Payload))
+		require.NoError(t, conn.WriteJSON(&Message{Type: MessageTypePing}))
+		readTestMessage(t, conn, MessageTypePong)
+		writeTestResult(t, conn, msg.Id, `{"n":1}`)
+		require.NoError(t, conn.WriteJSON(&Message{
+			Id:      msg.Id,
+			Type:    MessageTypeNext,
+			Payload: json.RawMessage(`{"data":null,"errors":[{"message":"oops","path":["n"]}]}`),
+		}))
+		writeTestResult(t, conn, msg.Id, `{"n":2}`)
+		require.NoError(t, conn.WriteJSON(&Message{Id: msg.Id, Type: MessageTypeComplete}))
+		conn.ReadMessage()
+	})
+	client.InitPayload = func(ctx context.Context) (interface{}, error) {
+		return map[string]string{"token": "foo"}, nil
+	}
+
+	sub := client.Subscribe(context.Background(), &SubscribePayload{
+		Query:     "subscription{n}",
+		Variables: map[string]interface{}{"x": 1},
+	})
+	assert.Equal(t, []string{`{"n":1}`, "error: oops", `{"n":2}`}, collectResults(sub))
+	assert.NoError(t, sub.Err())
+}
+
+func TestClient_PayloadChunks(t *testing.T) {
+	_, client := newTestClientServer(t, func(conn *websocket.Conn, n int) {
+		var init Message
+		require.NoError(t, conn.ReadJSON(&init))
+		assert.JSONEq(t, `{"token":"foo","payloadChunking":true}`, string(init.Payload))
+		require.True(t, transport.AcceptsPayloadChunks(init.Payload))
+		require.NoError(t, conn.WriteJSON(&Message{Type: MessageTypeConnectionAck}))
+
+		msg := readTestMessage(t, conn, MessageTypeSubscribe)
+		chunks := transport.SplitPayload([]byte(`{"data":{"s":"héllo wörld"}}`), 5)
+		for _, chunk := range chunks {
+			assert.LessOrEqual(t, len(chunk.Data), 5)
+			buf, err := json.Marshal(chunk)
+			require.NoError(t, err)
+			require.NoError(t, conn.WriteJSON(&Message{
+				Id:      msg.Id,
+				Type:    MessageTypeChunk,
+				Payload: buf,
+			}))
+		}
+		require.NoError(t, conn.WriteJSON(&Message{Id: msg.Id, Type: MessageTypeComplete}))
+		conn.ReadMessage()
+	})
+	client.InitPayload = func(ctx context.Context) (interface{}, error) {
+		return map[string]string{"token": "foo"}, nil
+	}
+	client.AcceptPayloadChunks = true
+
+	sub := client.Subscribe(context.Background(), &SubscribePayload{
+		Query: "subscription{s}",
+	})
+	assert.Equal(t, []string{`{"s":"héllo wörld"}`}, collectResults(sub))
+	assert.NoError(t, sub.Err())
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	ids := make(chan string, 2)
+	_, client := newTestClientServer(t, func(conn *websocket.Conn, n int) {
+		acceptTestConnection(t, conn)
+		msg := readTestMessage(t, conn, MessageTypeSubscribe)
+		ids <- msg.Id
+		if n == 0 {
+			// drop the connection without completing the subscription
+			writeTestResult(t, conn, msg.Id, `{"n":1}`)
+			return
+		}
+		writeTestResult(t, conn, msg.Id, `{"n":2}`)
+		require.NoError(t, conn.WriteJSON(&Message{Id: msg.Id, Type: MessageTypeComplete}))
+		conn.ReadMessage()
+	})
+	var loggedErrors []error
+	client.LogError = func(err error) {
+		loggedErrors = append(loggedErrors, err)
+	}
+
+	sub := client.Subscribe(context.Background(), &SubscribePayload{
+		Query: "subscription{n}",
+	})
+	assert.Equal(t, []string{`{"n":1}`, `{"n":2}`}, collectResults(sub))
+	assert.NoError(t, sub.Err())
+
+	// The subscription must have kept its id.
+	assert.Equal(t, <-ids, <-ids)
+	assert.Len(t, loggedErrors, 1)
+}
+
+func TestClient_Error(t *testing.T) {
+	_, client := newTestClientServer(t, func(conn *websocket.Conn, n int) {
+		acceptTestConnection(t, conn)
+		msg := readTestMessage(t, conn, MessageTypeSubscribe)
+		require.NoError(t, conn.WriteJSON(&Message{
+			Id:      msg.Id,
+			Type:    MessageTypeError,
+			Payload: json.RawMessage(`[{"message":"bad subscription"}]`),
+		}))
+		conn.ReadMessage()
+	})
+
+	sub := client.Subscribe(context.Background(), &SubscribePayload{
+		Query: "subscription{n}",
+	})
+	assert.Empty(t, collectResults(sub))
+	require.IsType(t, &ResultError{}, sub.Err())
+	assert.Equal(t, "bad subscription", sub.Err().Error())
+}
+
+func TestClient_Rejected(t *testing.T) {
+	_, client := newTestClientServer(t, func(conn *websocket.Conn, n int) {
+		readTestMessage(t, conn, MessageTypeConnectionInit)
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4403, "forbidden"))
+		conn.ReadMessage()
+	})
+
+	sub := client.Subscribe(context.Background(), &SubscribePayload{
+		Query: "subscription{n}",
+	})
+	assert.Empty(t, collectResults(sub))
+	require.IsType(t, &websocket.CloseError{}, sub.Err())
+	assert.Equal(t, 4403, sub.Err().(*websocket.CloseError).Code)
+}
+
+func TestClient_Cancel(t *testing.T) {
+	completed := make(chan string, 1)
+	_, client := newTestClientServer(t, func(conn *websocket.Conn, n int) {
+		acceptTestConnection(t, conn)
+		msg := readTestMessage(t, conn, MessageTypeSubscribe)
+		writeTestResult(t, conn, msg.Id, `{"n":1}`)
+		completed <- readTestMessage(t, conn, MessageTypeComplete).Id
+		conn.ReadMessage()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := client.Subscribe(ctx, &SubscribePayload{
+		Query: "subscription{n}",
+	})
+	result := <-sub.Results()
+	assert.Equal(t, `{"n":1}`, string(result.Data))
+	cancel()
+
+	_, ok := <-sub.Results()
+	assert.False(t, ok)
+	assert.Equal(t, context.Canceled, sub.Err())
+	assert.Equal(t, "1", <-completed)
+}
+
+func TestClient_Close(t *testing.T) {
+	_, client := newTestClientServer(t, func(conn *websocket.Conn, n int) {
+		acceptTestConnection(t, conn)
+		readTestMessage(t, conn, MessageTypeSubscribe)
+		conn.ReadMessage()
+	})
+
+	sub := client.Subscribe(context.Background(), &SubscribePayload{
+		Query: "subscription{n}",
+	})
+	require.NoError(t, client.Close())
+	assert.Empty(t, collectResults(sub))
+	assert.Equal(t, ErrClientClosed, sub.Err())
+
+	sub = client.Subscribe(context.Background(), &SubscribePayload{
+		Query: "subscription{n}",
+	})
+	assert.Empty(t, collectResults(sub))
+	assert.Equal(t, ErrClientClosed, sub.Err())
+}
+
+func TestClient_Ping(t *testing.T) {
+	_, client := newTestClientServer(t, func(conn *websocket.Conn, n int) {
+		acceptTestConnection(t, conn)
+		msg := readTestMessage(t, conn, MessageTypeSubscribe)
+		readTestMessage(t, conn, MessageTypePing)
+		require.NoError(t, conn.WriteJSON(&Message{Type: MessageTypePong}))
+		writeTestResult(t, conn, msg.Id, `{"n":1}`)
+		require.NoError(t, conn.WriteJSON(&Message{Id: msg.Id, Type: MessageTypeComplete}))
+		conn.ReadMessage()
+	})
+	client.PingInterval = 10 * time.Millisecond
+
+	sub := client.Subscribe(context.Background(), &SubscribePayload{
+		Query: "subscription{n}",
+	})
+	assert.Equal(t, []string{`{"n":1}`}, collectResults(sub))
+	assert.NoError(t, sub.Err())
+}
+
+func TestClient_PongTimeout(t *testing.T) {
+	_, client := newTestClientServer(t, func(conn *websocket.Conn, n int) {
+		acceptTestConnection(t, conn)
+		msg := readTestMessage(t, conn, MessageTypeSubscribe)
+		if n == 0 {
+			// never respond to pings
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}
+		writeTestResult(t, conn, msg.Id, `{"n":1}`)
+		require.NoError(t, conn.WriteJSON(&Message{Id: msg.Id, Type: MessageTypeComplete}))
+		conn.ReadMessage()
+	})
+	client.PingInterval = 10 * time.Millisecond
+	var loggedErrors []error
+	client.LogError = func(err error) {
+		loggedErrors = append(loggedErrors, err)
+	}
+
+	sub := client.Subscribe(context.Background(), &SubscribePayload{
+		Query: "subscription{n}",
+	})
+	assert.Equal(t, []string{`{"n":1}`}, collectResults(sub))
+	assert.NoError(t, sub.Err())
+	assert.Len(t, loggedErrors, 1)
+}
+
+func TestClient_ResultQueueFull(t *testing.T) {
+	completed := make(chan string, 1)
+	_, client := newTestClientServer(t, func(conn *websocket.Conn, n int) {
+		acceptTestConnection(t, conn)
+		msg := readTestMessage(t, conn, MessageTypeSubscribe)
+		for i := 0; i < 5; i++ {
+			writeTestResult(t, conn, msg.Id, `{"n":1}`)
+		}
+		completed <- readTestMessage(t, conn, MessageTypeComplete).Id
+		conn.ReadMessage()
+	})
+	client.MaxQueuedResults = 1
+
+	sub := client.Subscribe(context.Background(), &SubscribePayload{
+		Query: "subscription{n}",
+	})
+	assert.Equal(t, "1", <-completed)
+	collectResults(sub)
+	assert.Equal(t, ErrResultQueueFull, sub.Err())
+}
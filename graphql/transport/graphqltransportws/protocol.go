@@ -19,6 +19,12 @@ const (
 	MessageTypeNext           MessageType = "next"
 	MessageTypeError          MessageType = "error"
 	MessageTypeComplete       MessageType = "complete"
+
+	// This isn't part of the protocol. See transport.DeliveryAckHandler.
+	MessageTypeDeliveryAck MessageType = "delivery_ack"
+
+	// This isn't part of the protocol. See transport.PayloadChunk.
+	MessageTypeChunk MessageType = "chunk"
 )
 
 // Message represents a GraphQL-WS message. This can be used for both client and server messages.
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/transport"
 	"github.com/gorilla/websocket"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
@@ -16,45 +17,51 @@ import (
 type Connection struct {
 	Handler ConnectionHandler
 
+	// If given, outgoing messages are encoded with this function and sent as binary frames instead
+	// of JSON text frames. Message payloads are encoded as nested values rather than JSON. This is
+	// typically graphql.MarshalMsgpack or graphql.MarshalCBOR. Incoming messages must still be JSON.
+	MarshalBinary func(v interface{}) ([]byte, error)
+
+	// The interval at which keep-alive messages are sent. If zero, the default of 15 seconds is
+	// used. If negative, keep-alive messages aren't sent.
+	KeepAliveInterval time.Duration
+
+	// If positive, results whose encoded payloads exceed this many bytes aren't sent. Instead, the
+	// client receives a result with a single error whose "code" extension is
+	// transport.PayloadTooLargeErrorCode.
+	MaxPayloadSize int
+
+	// If positive, results whose encoded payloads exceed this many bytes are split into "chunk"
+	// messages of at most this size. This is an extension to the protocol, so it's only done for
+	// clients that opt in via their init payloads, and only for JSON-encoded messages. See
+	// transport.PayloadChunk.
+	PayloadChunkSize int
+
 	conn              *websocket.Conn
 	readLoopDone      chan struct{}
 	writeLoopDone     chan struct{}
-	outgoing          chan *websocket.PreparedMessage
+	outgoing          chan outgoingMessage
 	close             chan struct{}
 	closeReceived     chan struct{}
 	closeMessage      chan []byte
 	beginClosingOnce  sync.Once
 	finishClosingOnce sync.Once
 	didInit           bool
+	chunkPayloads     bool
+	terminated        bool
+	keepAliveMessage  *websocket.PreparedMessage
 }
 
-// ConnectionHandler methods may be invoked on a separate goroutine, but invocations will never be
-// made concurrently.
-type ConnectionHandler interface {
-	// Called when the server receives the init message. If an error is returned, it will be sent to
-	// the client and the connection will be closed.
-	HandleInit(parameters json.RawMessage) error
-
-	// Called when the client wants to start an operation. If the operation is a query or mutation,
-	// the handler should immediately call SendData followed by SendComplete. If the operation is a
-	// subscription, the handler should call SendData to send events and SendComplete if/when the
-	// event stream ends.
-	HandleStart(id string, query string, variables map[string]interface{}, operationName string)
+// ConnectionHandler is an alias for transport.ConnectionHandler.
+type ConnectionHandler = transport.ConnectionHandler
 
-	// Called when the client wants to stop an operation. The handler should unsubscribe them from
-	// the corresponding subscription.
-	HandleStop(id string)
+// ConnectionAckPayloadHandler is an alias for transport.ConnectionAckPayloadHandler.
+type ConnectionAckPayloadHandler = transport.ConnectionAckPayloadHandler
 
-	// Called when an unexpected error occurs. The connection will perform the appropriate response,
-	// but you may want to log it.
-	LogError(err error)
+var _ transport.Connection = (*Connection)(nil)
+var _ transport.Flusher = (*Connection)(nil)
 
-	// Called when the connection begins closing and all in-flight operations should be canceled.
-	Cancel()
-
-	// Called when the connection is closed.
-	HandleClose()
-}
+const defaultKeepAliveInterval = 15 * time.Second
 
 const connectionSendBufferSize = 100
 
@@ -63,10 +70,18 @@ func (c *Connection) Serve(conn *websocket.Conn) {
 	c.conn = conn
 	c.readLoopDone = make(chan struct{})
 	c.writeLoopDone = make(chan struct{})
-	c.outgoing = make(chan *websocket.PreparedMessage, connectionSendBufferSize)
+	c.outgoing = make(chan outgoingMessage, connectionSendBufferSize)
 	c.close = make(chan struct{})
 	c.closeReceived = make(chan struct{})
 	c.closeMessage = make(chan []byte, 1)
+	c.keepAliveMessage = keepAlivePreparedMessage
+	if c.MarshalBinary != nil {
+		if data, err := c.MarshalBinary(&binaryMessage{Type: MessageTypeConnectionKeepAlive}); err == nil {
+			if prepared, err := websocket.NewPreparedMessage(websocket.BinaryMessage, data); err == nil {
+				c.keepAliveMessage = prepared
+			}
+		}
+	}
 	conn.SetCloseHandler(func(code int, text string) error {
 		select {
 		case <-c.closeReceived:
@@ -81,10 +96,39 @@ func (c *Connection) Serve(conn *websocket.Conn) {
 
 // SendData sends the given GraphQL response to the client.
 func (c *Connection) SendData(ctx context.Context, id string, response *graphql.Response) error {
+	if c.MarshalBinary != nil {
+		if c.MaxPayloadSize > 0 {
+			buf, err := c.MarshalBinary(response)
+			if err != nil {
+				return errors.Wrap(err, "unable to marshal graphql response")
+			} else if len(buf) > c.MaxPayloadSize {
+				response = transport.PayloadTooLargeResponse(len(buf), c.MaxPayloadSize)
+			}
+		}
+		return c.sendBinaryMessage(ctx, &binaryMessage{
+			Id:      id,
+			Type:    MessageTypeData,
+			Payload: response,
+		})
+	}
+
 	buf, err := jsoniter.Marshal(response)
 	if err != nil {
 		return errors.Wrap(err, "unable to marshal graphql response")
 	}
+	if c.MaxPayloadSize > 0 && len(buf) > c.MaxPayloadSize {
+		if buf, err = jsoniter.Marshal(transport.PayloadTooLargeResponse(len(buf), c.MaxPayloadSize)); err != nil {
+			return errors.Wrap(err, "unable to marshal graphql response")
+		}
+	}
+	if c.chunkPayloads && len(buf) > c.PayloadChunkSize {
+		for _, chunk := range transport.SplitPayload(buf, c.PayloadChunkSize) {
+			if err := c.sendChunk(ctx, id, chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	return c.sendMessage(ctx, &Message{
 		Id:      id,
 		Type:    MessageTypeData,
@@ -92,6 +136,18 @@ func (c *Connection) SendData(ctx context.Context, id string, response *graphql.
 	})
 }
 
+func (c *Connection) sendChunk(ctx context.Context, id string, chunk *transport.PayloadChunk) error {
+	buf, err := jsoniter.Marshal(chunk)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal payload chunk")
+	}
+	return c.sendMessage(ctx, &Message{
+		Id:      id,
+		Type:    MessageTypeChunk,
+		Payload: json.RawMessage(buf),
+	})
+}
+
 // SendComplete sends the "complete" message to the client. This should be done after queries are
 // executed or subscriptions are stopped.
 func (c *Connection) SendComplete(ctx context.Context, id string) error {
@@ -108,7 +164,75 @@ func (c *Connection) Close() error {
 	return nil
 }
 
+// The representation of messages sent as binary frames.
+type binaryMessage struct {
+	Id      string      `json:"id,omitempty"`
+	Type    MessageType `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// Flush blocks until all previously sent messages have been written to the underlying connection.
+func (c *Connection) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case c.outgoing <- outgoingMessage{flushed: flushed}:
+	case <-c.writeLoopDone:
+		return errConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-c.writeLoopDone:
+		return errConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var errConnectionClosed = errors.New("connection closed")
+
+// outgoingMessage is either a message to be written or a marker used by Flush.
+type outgoingMessage struct {
+	prepared *websocket.PreparedMessage
+
+	// If non-nil, this is closed once every message queued before it has been written.
+	flushed chan struct{}
+}
+
+func (c *Connection) sendBinaryMessage(ctx context.Context, msg *binaryMessage) error {
+	data, err := c.MarshalBinary(msg)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling message")
+	}
+	prepared, err := websocket.NewPreparedMessage(websocket.BinaryMessage, data)
+	if err != nil {
+		return errors.Wrap(err, "error preparing message")
+	}
+	select {
+	case c.outgoing <- outgoingMessage{prepared: prepared}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
 func (c *Connection) sendMessage(ctx context.Context, msg *Message) error {
+	if c.MarshalBinary != nil {
+		binaryMsg := &binaryMessage{
+			Id:   msg.Id,
+			Type: msg.Type,
+		}
+		if msg.Payload != nil {
+			payload, err := transport.DecodeBinaryPayload(msg.Payload)
+			if err != nil {
+				return errors.Wrap(err, "error decoding message payload")
+			}
+			binaryMsg.Payload = payload
+		}
+		return c.sendBinaryMessage(ctx, binaryMsg)
+	}
 	data, err := jsoniter.Marshal(msg)
 	if err != nil {
 		return errors.Wrap(err, "error marshaling message")
@@ -118,7 +242,7 @@ func (c *Connection) sendMessage(ctx context.Context, msg *Message) error {
 		return errors.Wrap(err, "error preparing message")
 	}
 	select {
-	case c.outgoing <- prepared:
+	case c.outgoing <- outgoingMessage{prepared: prepared}:
 	case <-ctx.Done():
 		return ctx.Err()
 	}
@@ -153,8 +277,18 @@ func (c *Connection) handleMessage(ctx context.Context, data []byte) {
 		return
 	}
 
+	if c.terminated {
+		// the connection is closing, so nothing else should be started
+		return
+	}
+
 	switch msg.Type {
 	case MessageTypeConnectionInit:
+		// This is only set before any operations are started, so SendData can read it without
+		// synchronization.
+		if !c.didInit {
+			c.chunkPayloads = c.PayloadChunkSize > 0 && c.MarshalBinary == nil && transport.AcceptsPayloadChunks(msg.Payload)
+		}
 		if err := c.Handler.HandleInit(msg.Payload); err != nil {
 			payload := struct {
 				Message string `json:"message"`
@@ -176,16 +310,20 @@ func (c *Connection) handleMessage(ctx context.Context, data []byte) {
 
 		c.didInit = true
 		if err := c.sendMessage(ctx, &Message{
-			Id:   msg.Id,
-			Type: MessageTypeConnectionAck,
+			Id:      msg.Id,
+			Type:    MessageTypeConnectionAck,
+			Payload: c.ackPayload(),
 		}); err != nil {
 			c.Handler.LogError(errors.Wrap(err, "unable to send graphql-ws connection ack"))
 			c.beginClosing(websocket.CloseInternalServerErr, "ack send error")
-		} else if err := c.sendMessage(ctx, &Message{
-			Type: MessageTypeConnectionKeepAlive,
-		}); err != nil {
-			c.Handler.LogError(errors.Wrap(err, "unable to send graphql-ws initial keep-alive"))
-			c.beginClosing(websocket.CloseInternalServerErr, "keep-alive send error")
+		} else if c.KeepAliveInterval >= 0 {
+			// clients only expect keep-alive messages if they receive one right after the ack
+			if err := c.sendMessage(ctx, &Message{
+				Type: MessageTypeConnectionKeepAlive,
+			}); err != nil {
+				c.Handler.LogError(errors.Wrap(err, "unable to send graphql-ws initial keep-alive"))
+				c.beginClosing(websocket.CloseInternalServerErr, "keep-alive send error")
+			}
 		}
 	case MessageTypeStart:
 		if !c.didInit {
@@ -196,25 +334,43 @@ func (c *Connection) handleMessage(ctx context.Context, data []byte) {
 			Query         string                 `json:"query"`
 			Variables     map[string]interface{} `json:"variables"`
 			OperationName string                 `json:"operationName"`
+			Extensions    map[string]interface{} `json:"extensions"`
 		}
 		if err := jsoniter.Unmarshal(msg.Payload, &payload); err != nil {
 			// ignore malformed messages
 			return
 		}
-		c.Handler.HandleStart(msg.Id, payload.Query, payload.Variables, payload.OperationName)
+		transport.HandleStart(c.Handler, msg.Id, payload.Query, payload.Variables, payload.OperationName, payload.Extensions)
 	case MessageTypeStop:
 		if !c.didInit {
 			return
 		}
 
 		c.Handler.HandleStop(msg.Id)
+	case MessageTypeDeliveryAck:
+		if !c.didInit {
+			return
+		}
+		// Malformed acknowledgements are ignored, so the result is sent again. See
+		// transport.HandleDeliveryAck.
+		transport.HandleDeliveryAck(c.Handler, msg.Id, msg.Payload)
 	case MessageTypeConnectionTerminate:
+		// Operations are canceled via the handler's Cancel method and any messages they've already
+		// queued are flushed before the socket is closed.
+		c.terminated = true
 		c.beginClosing(websocket.CloseNormalClosure, "terminate requested by client")
 	default:
 		// ignore unknown message types
 	}
 }
 
+func (c *Connection) ackPayload() json.RawMessage {
+	if h, ok := c.Handler.(ConnectionAckPayloadHandler); ok {
+		return h.ConnectionAckPayload()
+	}
+	return nil
+}
+
 var keepAlivePreparedMessage *websocket.PreparedMessage
 
 func init() {
@@ -237,22 +393,38 @@ func (c *Connection) writeLoop() {
 
 	defer c.conn.Close()
 
-	keepAliveTicker := time.NewTicker(15 * time.Second)
-	defer keepAliveTicker.Stop()
+	var keepAlive <-chan time.Time
+	if interval := c.KeepAliveInterval; interval >= 0 {
+		if interval == 0 {
+			interval = defaultKeepAliveInterval
+		}
+		keepAliveTicker := time.NewTicker(interval)
+		defer keepAliveTicker.Stop()
+		keepAlive = keepAliveTicker.C
+	}
 
 	for {
 		var msg *websocket.PreparedMessage
 		select {
 		case outgoing := <-c.outgoing:
-			msg = outgoing
-		case <-keepAliveTicker.C:
-			msg = keepAlivePreparedMessage
+			if outgoing.flushed != nil {
+				close(outgoing.flushed)
+				continue
+			}
+			msg = outgoing.prepared
+		case <-keepAlive:
+			msg = c.keepAliveMessage
 		case msg := <-c.closeMessage:
 			// make sure we send any outgoing messages before closing (e.g. to make sure we send
 			// back the error after a bad init)
 			for done := false; !done; {
 				select {
-				case msg := <-c.outgoing:
+				case outgoing := <-c.outgoing:
+					if outgoing.flushed != nil {
+						close(outgoing.flushed)
+						continue
+					}
+					msg := outgoing.prepared
 					c.conn.SetWriteDeadline(time.Now().Add(time.Second))
 					if err := c.conn.WritePreparedMessage(msg); err != nil {
 						if !websocket.IsCloseError(err, websocket.CloseAbnormalClosure, websocket.CloseGoingAway) && err != websocket.ErrCloseSent {
@@ -21,6 +21,12 @@ const (
 	MessageTypeStart               MessageType = "start"
 	MessageTypeStop                MessageType = "stop"
 	MessageTypeError               MessageType = "error"
+
+	// This isn't part of the protocol. See transport.DeliveryAckHandler.
+	MessageTypeDeliveryAck MessageType = "delivery_ack"
+
+	// This isn't part of the protocol. See transport.PayloadChunk.
+	MessageTypeChunk MessageType = "chunk"
 )
 
 // Message represents a GraphQL-WS message. This can be used for both client and server messages.
@@ -0,0 +1,238 @@
+// Package transport contains the definitions shared by the WebSocket protocol implementations in
+// its subpackages. Each subpackage adapts its protocol's messages to these interfaces, so handlers
+// and middleware can be written once and used with any of them.
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// Connection represents a server-side connection using one of the WebSocket protocols.
+type Connection interface {
+	// SendData sends the given GraphQL response to the client.
+	SendData(ctx context.Context, id string, response *graphql.Response) error
+
+	// SendComplete tells the client that an operation is complete. This should be done after
+	// queries are executed or subscriptions are stopped.
+	SendComplete(ctx context.Context, id string) error
+
+	// Serve takes ownership of the given connection and begins reading / writing to it.
+	Serve(conn *websocket.Conn)
+
+	// Close closes the connection. This must not be called from handler functions.
+	Close() error
+}
+
+// Flusher may optionally be implemented by Connections that queue outgoing messages.
+type Flusher interface {
+	// Flush blocks until all previously sent messages have been written to the underlying
+	// connection. This allows senders to wait for slow clients instead of filling the queue.
+	Flush(ctx context.Context) error
+}
+
+// ConnectionHandler handles the events of a Connection. Its methods may be invoked on a separate
+// goroutine, but invocations will never be made concurrently.
+type ConnectionHandler interface {
+	// Called when the server receives the init message. If an error is returned, it will be sent to
+	// the client and the connection will be closed.
+	HandleInit(parameters json.RawMessage) error
+
+	// Called when the client wants to start an operation. If the operation is a query or mutation,
+	// the handler should immediately call SendData followed by SendComplete. If the operation is a
+	// subscription, the handler should call SendData to send events and SendComplete if/when the
+	// event stream ends.
+	HandleStart(id string, query string, variables map[string]interface{}, operationName string)
+
+	// Called when the client wants to stop an operation. The handler should unsubscribe them from
+	// the corresponding subscription.
+	HandleStop(id string)
+
+	// Called when an unexpected error occurs. The connection will perform the appropriate response,
+	// but you may want to log it.
+	LogError(err error)
+
+	// Called when the connection begins closing and all in-flight operations should be canceled.
+	Cancel()
+
+	// Called when the connection is closed.
+	HandleClose()
+}
+
+// ConnectionAckPayloadHandler may optionally be implemented by ConnectionHandlers to send a payload
+// with the connection_ack message.
+type ConnectionAckPayloadHandler interface {
+	// Called after HandleInit succeeds. If a non-nil payload is returned, it's sent to the client
+	// with the connection_ack message.
+	ConnectionAckPayload() json.RawMessage
+}
+
+// ExtensionsHandler may optionally be implemented by ConnectionHandlers to receive the extensions
+// that clients send with operations.
+type ExtensionsHandler interface {
+	// Like HandleStart, but also receives the operation's extensions. If implemented, this is
+	// called instead of HandleStart.
+	HandleStartWithExtensions(id string, query string, variables map[string]interface{}, operationName string, extensions map[string]interface{})
+}
+
+// HandleStart passes an operation to the handler, including its extensions if the handler
+// implements ExtensionsHandler.
+func HandleStart(handler ConnectionHandler, id string, query string, variables map[string]interface{}, operationName string, extensions map[string]interface{}) {
+	if h, ok := handler.(ExtensionsHandler); ok {
+		h.HandleStartWithExtensions(id, query, variables, operationName, extensions)
+	} else {
+		handler.HandleStart(id, query, variables, operationName)
+	}
+}
+
+// DeliveryAckHandler may optionally be implemented by ConnectionHandlers to support the
+// "delivery_ack" message. This is an extension to the protocols which clients use to acknowledge
+// subscription results that were sent with sequence numbers.
+type DeliveryAckHandler interface {
+	// Called when the client acknowledges the results of an operation up to and including the
+	// given sequence number.
+	HandleDeliveryAck(id string, sequence int64)
+}
+
+// HandleDeliveryAck decodes a "delivery_ack" message's payload and passes it to the handler if it
+// implements DeliveryAckHandler. An error is returned if the payload is malformed. Connections
+// ignore such acknowledgements rather than closing, as the handler will send the unacknowledged
+// result again.
+func HandleDeliveryAck(handler ConnectionHandler, id string, payload json.RawMessage) error {
+	var ack struct {
+		Sequence int64 `json:"sequence"`
+	}
+	if err := json.Unmarshal(payload, &ack); err != nil {
+		return err
+	}
+	if h, ok := handler.(DeliveryAckHandler); ok {
+		h.HandleDeliveryAck(id, ack.Sequence)
+	}
+	return nil
+}
+
+// DecodeBinaryPayload decodes a JSON-encoded message payload into a value that a binary encoding
+// such as graphql.MarshalMsgpack can encode. Numbers that are integers are decoded as int64s so
+// that they remain integers once encoded.
+func DecodeBinaryPayload(payload json.RawMessage) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return convertJSONNumbers(v), nil
+}
+
+func convertJSONNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		n, _ := v.Float64()
+		return n
+	case []interface{}:
+		for i, item := range v {
+			v[i] = convertJSONNumbers(item)
+		}
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = convertJSONNumbers(item)
+		}
+	}
+	return v
+}
+
+// PayloadChunk is the payload of a "chunk" message. This is an extension to the protocols which
+// servers use to split large results into multiple frames so that they don't delay other
+// operations on the connection. A client reassembles a result by concatenating the data of all of
+// an operation's chunks in order, then decoding it as JSON as it would the payload of a data
+// message.
+//
+// Servers only send chunks to clients that opt in via their init payloads. See
+// PayloadChunkingInitParameter.
+type PayloadChunk struct {
+	// The index of this chunk, starting at zero.
+	Index int `json:"index"`
+
+	// The total number of chunks that the payload was split into.
+	Count int `json:"count"`
+
+	// A portion of the JSON-encoded payload.
+	Data string `json:"data"`
+}
+
+// PayloadChunkingInitParameter is the init payload property with which clients opt in to receiving
+// "chunk" messages. If the init payload is a JSON object with this property set to true, the
+// server may split large results into chunks. See PayloadChunk.
+const PayloadChunkingInitParameter = "payloadChunking"
+
+// AcceptsPayloadChunks returns true if the given init payload opts in to receiving "chunk"
+// messages.
+func AcceptsPayloadChunks(initPayload json.RawMessage) bool {
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(initPayload, &params); err != nil {
+		return false
+	}
+	return string(params[PayloadChunkingInitParameter]) == "true"
+}
+
+// SplitPayload splits a JSON-encoded payload into chunks of at most chunkSize bytes. Chunks end on
+// UTF-8 character boundaries so that each chunk's data is a valid string.
+func SplitPayload(payload []byte, chunkSize int) []*PayloadChunk {
+	var ret []*PayloadChunk
+	for start := 0; start < len(payload); {
+		end := start + chunkSize
+		if end >= len(payload) {
+			end = len(payload)
+		} else {
+			boundary := end
+			for boundary > start && !utf8.RuneStart(payload[boundary]) {
+				boundary--
+			}
+			if boundary > start {
+				end = boundary
+			}
+		}
+		ret = append(ret, &PayloadChunk{
+			Index: len(ret),
+			Data:  string(payload[start:end]),
+		})
+		start = end
+	}
+	for _, chunk := range ret {
+		chunk.Count = len(ret)
+	}
+	return ret
+}
+
+// PayloadTooLargeErrorCode is the "code" extension of the error sent in place of results that
+// exceed a connection's maximum payload size.
+const PayloadTooLargeErrorCode = graphql.ErrorCodePayloadTooLarge
+
+func payloadTooLargeErrorExtensions(size, limit int) map[string]interface{} {
+	ret := PayloadTooLargeErrorCode.Extensions()
+	ret["size"] = size
+	ret["limit"] = limit
+	return ret
+}
+
+// PayloadTooLargeResponse returns the response that is sent in place of a result whose encoded
+// payload is size bytes, exceeding the given limit.
+func PayloadTooLargeResponse(size, limit int) *graphql.Response {
+	return &graphql.Response{
+		Errors: []*graphql.Error{
+			{
+				Message:    "result payload is too large",
+				Extensions: payloadTooLargeErrorExtensions(size, limit),
+			},
+		},
+	}
+}
@@ -0,0 +1,35 @@
+package graphql
+
+import (
+	"github.com/ccbrown/api-fu/graphql/ast"
+)
+
+// InjectTypename returns a copy of the document in which __typename is selected for every field
+// with a selection set, unless the field's selection set already selects it without an alias.
+// Operations' root selection sets are left alone, so the injected fields only appear within
+// objects. This is useful when responses feed normalized caches that require typenames.
+//
+// The given document isn't modified. See Request.InjectTypename.
+func InjectTypename(doc *ast.Document) *ast.Document {
+	return ast.Rewrite(doc, func(node ast.Node) ast.Node {
+		field, ok := node.(*ast.Field)
+		if !ok || field.SelectionSet == nil {
+			return node
+		}
+		for _, selection := range field.SelectionSet.Selections {
+			if f, ok := selection.(*ast.Field); ok && f.Alias == nil && f.Name.Name == "__typename" {
+				return node
+			}
+		}
+		selectionSet := *field.SelectionSet
+		selectionSet.Selections = append(selectionSet.Selections[:len(selectionSet.Selections):len(selectionSet.Selections)], &ast.Field{
+			Name: &ast.Name{
+				Name:         "__typename",
+				NamePosition: selectionSet.Closing,
+			},
+		})
+		ret := *field
+		ret.SelectionSet = &selectionSet
+		return &ret
+	}).(*ast.Document)
+}
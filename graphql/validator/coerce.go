@@ -1,6 +1,8 @@
 package validator
 
 import (
+	"errors"
+
 	"github.com/ccbrown/api-fu/graphql/ast"
 	"github.com/ccbrown/api-fu/graphql/schema"
 )
@@ -67,13 +69,23 @@ func CoerceArgumentValues(node ast.Node, argumentDefinitions map[string]*schema.
 			if coercedValues == nil {
 				coercedValues = map[string]interface{}{}
 			}
+			var coerced interface{}
 			if argVariable, ok := argumentValue.(*ast.Variable); ok {
-				coercedValues[argumentName] = variableValues[argVariable.Name.Name]
-			} else if coerced, err := schema.CoerceLiteral(argumentValue, argumentType, variableValues); err != nil {
-				return nil, newError(argumentValue, "Invalid argument value: %v", err.Error())
+				coerced = variableValues[argVariable.Name.Name]
+			} else if v, err := schema.CoerceLiteral(argumentValue, argumentType, variableValues); err != nil {
+				ret := newError(argumentValue, "Invalid argument value: %v", err.Error())
+				var constraintErr *schema.ConstraintViolationError
+				ret.isConstraintViolation = errors.As(err, &constraintErr)
+				return nil, ret
 			} else {
-				coercedValues[argumentName] = coerced
+				coerced = v
+			}
+			if err := argumentDefinition.CheckConstraints(coerced); err != nil {
+				ret := newError(argumentValue, "Invalid %v argument: %v", argumentName, err.Error())
+				ret.isConstraintViolation = true
+				return nil, ret
 			}
+			coercedValues[argumentName] = coerced
 		}
 	}
 
@@ -0,0 +1,46 @@
+package validator
+
+import (
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+// StaticFieldCollectionFilter evaluates the field collection filter of a directive such as @skip
+// or @include if its arguments don't reference any variables. If ok is true, include is the result
+// of the filter, which is the same for every execution of the document. If the directive doesn't
+// have a filter or its result depends on variables, ok is false.
+func StaticFieldCollectionFilter(s *schema.Schema, directive *ast.Directive) (include bool, ok bool) {
+	def := s.Directives()[directive.Name.Name]
+	if def == nil || def.FieldCollectionFilter == nil {
+		return false, false
+	}
+	for _, arg := range directive.Arguments {
+		referencesVariable := false
+		ast.Inspect(arg.Value, func(node ast.Node) bool {
+			if _, ok := node.(*ast.Variable); ok {
+				referencesVariable = true
+			}
+			return !referencesVariable
+		})
+		if referencesVariable {
+			return false, false
+		}
+	}
+	arguments, err := CoerceArgumentValues(directive, def.Arguments, directive.Arguments, nil)
+	if err != nil {
+		return false, false
+	}
+	return def.FieldCollectionFilter(arguments), true
+}
+
+// IsStaticallyExcluded returns true if one of the selection's directives excludes it from field
+// collection regardless of the request's variables, e.g. because it's annotated with
+// `@skip(if: true)`.
+func IsStaticallyExcluded(s *schema.Schema, selection ast.Selection) bool {
+	for _, directive := range selection.SelectionDirectives() {
+		if include, ok := StaticFieldCollectionFilter(s, directive); ok && !include {
+			return true
+		}
+	}
+	return false
+}
@@ -14,6 +14,10 @@ type TypeInfo struct {
 	FieldDefinitions        map[*ast.Field]*schema.FieldDefinition
 	ExpectedTypes           map[ast.Value]schema.Type
 	DefaultValues           map[ast.Value]interface{}
+
+	// Values nested within list or object literals given for scalars, mapped to the scalar. These
+	// values have no expected types of their own.
+	ScalarLiteralValues map[ast.Value]*schema.ScalarType
 }
 
 func namedType(s *schema.Schema, features schema.FeatureSet, name string) schema.NamedType {
@@ -41,6 +45,15 @@ func schemaType(t ast.Type, s *schema.Schema, features schema.FeatureSet) schema
 	return nil
 }
 
+// Returns the scalar type that the given value is a literal for or is nested within, if any.
+func (info *TypeInfo) scalarLiteralType(value ast.Value) *schema.ScalarType {
+	if scalar, ok := info.ScalarLiteralValues[value]; ok {
+		return scalar
+	}
+	scalar, _ := schema.NullableType(info.ExpectedTypes[value]).(*schema.ScalarType)
+	return scalar
+}
+
 func NewTypeInfo(doc *ast.Document, s *schema.Schema, features schema.FeatureSet) *TypeInfo {
 	ret := &TypeInfo{
 		SelectionSetTypes:       map[*ast.SelectionSet]schema.NamedType{},
@@ -48,6 +61,7 @@ func NewTypeInfo(doc *ast.Document, s *schema.Schema, features schema.FeatureSet
 		FieldDefinitions:        map[*ast.Field]*schema.FieldDefinition{},
 		ExpectedTypes:           map[ast.Value]schema.Type{},
 		DefaultValues:           map[ast.Value]interface{}{},
+		ScalarLiteralValues:     map[ast.Value]*schema.ScalarType{},
 	}
 
 	var selectionSetScopes []schema.NamedType
@@ -66,9 +80,17 @@ func NewTypeInfo(doc *ast.Document, s *schema.Schema, features schema.FeatureSet
 				for _, value := range node.Values {
 					ret.ExpectedTypes[value] = expected.Type
 				}
+			} else if scalar := ret.scalarLiteralType(node); scalar != nil {
+				for _, value := range node.Values {
+					ret.ScalarLiteralValues[value] = scalar
+				}
 			}
 		case *ast.ObjectValue:
-			if expected, ok := schema.NullableType(ret.ExpectedTypes[node]).(*schema.InputObjectType); ok {
+			if scalar := ret.scalarLiteralType(node); scalar != nil {
+				for _, field := range node.Fields {
+					ret.ScalarLiteralValues[field.Value] = scalar
+				}
+			} else if expected, ok := schema.NullableType(ret.ExpectedTypes[node]).(*schema.InputObjectType); ok {
 				for _, field := range node.Fields {
 					if expected, ok := expected.Fields[field.Name.Name]; ok {
 						ret.ExpectedTypes[field.Value] = expected.Type
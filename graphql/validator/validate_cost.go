@@ -2,6 +2,7 @@ package validator
 
 import (
 	"context"
+	"sort"
 
 	"github.com/ccbrown/api-fu/graphql/ast"
 	"github.com/ccbrown/api-fu/graphql/schema"
@@ -40,6 +41,47 @@ func checkedNonNegativeAdd(a, b int) int {
 // Queries with costs that are too high to calculate due to overflows always result in an error when
 // max is non-negative, and actual will be set to the maximum possible value.
 func ValidateCost(operationName string, variableValues map[string]interface{}, max int, actual *int, defaultCost schema.FieldCost) Rule {
+	var maxCost *OperationCost
+	if max >= 0 {
+		maxCost = &OperationCost{
+			Resolver: max,
+		}
+	}
+	var cost OperationCost
+	rule := ValidateOperationCost(operationName, variableValues, maxCost, &cost, defaultCost)
+	return func(doc *ast.Document, s *schema.Schema, features schema.FeatureSet, typeInfo *TypeInfo) []*Error {
+		cost = OperationCost{}
+		ret := rule(doc, s, features, typeInfo)
+		if cost.Dimensions != nil && actual != nil {
+			// The cost was calculated, even if it exceeds the maximum.
+			*actual = cost.Resolver
+		}
+		return ret
+	}
+}
+
+// OperationCost is the cost of an operation, calculated by summing the costs of its fields.
+type OperationCost struct {
+	// The sum of the fields' resolver costs. See schema.FieldCost.Resolver.
+	Resolver int
+
+	// The sums of the fields' costs for each dimension. See schema.FieldCost.Dimensions.
+	Dimensions map[string]int
+
+	// The sorted names of the variables that the cost depends on. These are the variables
+	// referenced by the arguments of fields with cost functions, along with any declared via
+	// schema.FieldCost.Variables. The cost is the same for any operation execution with the same
+	// values for these variables.
+	Variables []string
+}
+
+// ValidateOperationCost is like ValidateCost, but it also calculates costs for each of the
+// dimensions defined by schema.FieldCost.Dimensions.
+//
+// If max is nil, no limits are enforced. Otherwise, if max.Resolver is non-negative, it limits the
+// resolver cost, and each entry in max.Dimensions limits the corresponding dimension. Dimensions
+// without an entry are unlimited.
+func ValidateOperationCost(operationName string, variableValues map[string]interface{}, max *OperationCost, actual *OperationCost, defaultCost schema.FieldCost) Rule {
 	return func(doc *ast.Document, s *schema.Schema, features schema.FeatureSet, typeInfo *TypeInfo) []*Error {
 		var ret []*Error
 
@@ -73,6 +115,8 @@ func ValidateCost(operationName string, variableValues map[string]interface{}, m
 		}
 
 		var cost int
+		dimensions := map[string]int{}
+		variables := map[string]struct{}{}
 		multipliers := []int{1}
 		ctxs := []context.Context{context.Background()}
 		fragments := map[string]struct{}{}
@@ -86,6 +130,11 @@ func ValidateCost(operationName string, variableValues map[string]interface{}, m
 					return true
 				}
 
+				if selection, ok := node.(ast.Selection); ok && IsStaticallyExcluded(s, selection) {
+					// The selection is never executed, so it doesn't contribute to the cost.
+					return false
+				}
+
 				multiplier := multipliers[len(multipliers)-1]
 				ctx := ctxs[len(ctxs)-1]
 				newMultiplier := multiplier
@@ -95,17 +144,36 @@ func ValidateCost(operationName string, variableValues map[string]interface{}, m
 				case *ast.Field:
 					if def, ok := typeInfo.FieldDefinitions[selection]; ok && coercedVariableValues != nil {
 						if args, err := CoerceArgumentValues(selection, def.Arguments, selection.Arguments, coercedVariableValues); err != nil {
-							ret = append(ret, newSecondaryError(selection, err.Error()))
+							if err.isConstraintViolation {
+								ret = append(ret, err)
+							} else {
+								ret = append(ret, newSecondaryError(selection, err.Error()))
+							}
 						} else {
 							costContext := schema.FieldCostContext{
-								Context:   ctx,
-								Arguments: args,
+								Context:        ctx,
+								Arguments:      args,
+								VariableValues: coercedVariableValues,
 							}
 							fieldCost := defaultCost
 							if def.Cost != nil {
 								fieldCost = def.Cost(costContext)
+								for _, arg := range selection.Arguments {
+									ast.Inspect(arg.Value, func(node ast.Node) bool {
+										if variable, ok := node.(*ast.Variable); ok {
+											variables[variable.Name.Name] = struct{}{}
+										}
+										return true
+									})
+								}
+							}
+							for _, name := range fieldCost.Variables {
+								variables[name] = struct{}{}
 							}
 							cost = checkedNonNegativeAdd(cost, checkedNonNegativeMultiply(multiplier, fieldCost.Resolver))
+							for dimension, n := range fieldCost.Dimensions {
+								dimensions[dimension] = checkedNonNegativeAdd(dimensions[dimension], checkedNonNegativeMultiply(multiplier, n))
+							}
 							if fieldCost.Multiplier > 1 {
 								newMultiplier = checkedNonNegativeMultiply(multiplier, fieldCost.Multiplier)
 							}
@@ -144,18 +212,44 @@ func ValidateCost(operationName string, variableValues map[string]interface{}, m
 
 		if len(ret) == 0 {
 			if actual != nil {
+				actual.Resolver = cost
 				if cost < 0 {
-					*actual = maxInt
-				} else {
-					*actual = cost
+					actual.Resolver = maxInt
+				}
+				actual.Dimensions = make(map[string]int, len(dimensions))
+				for dimension, n := range dimensions {
+					if n < 0 {
+						n = maxInt
+					}
+					actual.Dimensions[dimension] = n
 				}
+				actual.Variables = nil
+				for name := range variables {
+					actual.Variables = append(actual.Variables, name)
+				}
+				sort.Strings(actual.Variables)
 			}
 
-			if max >= 0 {
+			if max != nil && max.Resolver >= 0 {
 				if cost < 0 {
 					ret = append(ret, newError(op, "operation cost is too high to calculate"))
-				} else if cost > max {
-					ret = append(ret, newError(op, "operation cost of %v exceeds allowed cost of %v", cost, max))
+				} else if cost > max.Resolver {
+					ret = append(ret, newError(op, "operation cost of %v exceeds allowed cost of %v", cost, max.Resolver))
+				}
+			}
+
+			if max != nil {
+				names := make([]string, 0, len(max.Dimensions))
+				for dimension := range max.Dimensions {
+					names = append(names, dimension)
+				}
+				sort.Strings(names)
+				for _, dimension := range names {
+					if n := dimensions[dimension]; n < 0 {
+						ret = append(ret, newError(op, "operation %v cost is too high to calculate", dimension))
+					} else if n > max.Dimensions[dimension] {
+						ret = append(ret, newError(op, "operation %v cost of %v exceeds allowed cost of %v", dimension, n, max.Dimensions[dimension]))
+					}
 				}
 			}
 		}
@@ -78,6 +78,19 @@ func TestValidateCost(t *testing.T) {
 			},
 			MaxCost: 100,
 		},
+		"StaticallySkipped": {
+			Source:       `{objects(first: 10) { int a: int @skip(if: true) ... @include(if: false) { b: int } ...f @skip(if: true) } c: int @include(if: true)} fragment f on Object {d: int}`,
+			ExpectedCost: 1 + 10*1 + 1,
+			MaxCost:      100,
+		},
+		"DynamicallySkipped": {
+			Source:       `query ($skip: Boolean!) {int @skip(if: $skip)}`,
+			ExpectedCost: 1,
+			VariableValues: map[string]interface{}{
+				"skip": true,
+			},
+			MaxCost: 100,
+		},
 		"MultipleMatchingOperations": {
 			Source:         `query Foo {int} query Foo {int}`,
 			ExpectedErrors: 1,
@@ -128,3 +141,114 @@ func TestValidateCost(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateOperationCost(t *testing.T) {
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: objectType,
+	})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Source         string
+		VariableValues map[string]interface{}
+		MaxCost        *OperationCost
+		ExpectedCost   OperationCost
+		ExpectedErrors []string
+	}{
+		"Unlimited": {
+			Source: `{objects(first: 10) { dbReads }}`,
+			ExpectedCost: OperationCost{
+				Resolver: 1 + 10,
+				Dimensions: map[string]int{
+					"dbReads": 20,
+				},
+			},
+		},
+		"DefaultCost": {
+			Source: `{int}`,
+			ExpectedCost: OperationCost{
+				Resolver: 1,
+				Dimensions: map[string]int{
+					"cpu": 1,
+				},
+			},
+		},
+		"WithinLimits": {
+			Source: `{objects(first: 10) { dbReads }}`,
+			MaxCost: &OperationCost{
+				Resolver: -1,
+				Dimensions: map[string]int{
+					"dbReads":  20,
+					"external": 0,
+				},
+			},
+			ExpectedCost: OperationCost{
+				Resolver: 1 + 10,
+				Dimensions: map[string]int{
+					"dbReads": 20,
+				},
+			},
+		},
+		"NestedVariables": {
+			Source: `query ($a: Int, $b: Int = 3) {filteredObjects(filters: [{limit: $a}, {limit: $b}]) { int }}`,
+			VariableValues: map[string]interface{}{
+				"a": 2,
+			},
+			ExpectedCost: OperationCost{
+				Resolver: 1 + (2+3)*1,
+				Dimensions: map[string]int{
+					"cpu": 5,
+				},
+				Variables: []string{"a", "b"},
+			},
+		},
+		"DeclaredVariables": {
+			Source: `query ($cost: Int) {costFromVariable intListArgField(intListArg: [$cost])}`,
+			VariableValues: map[string]interface{}{
+				"cost": 10,
+			},
+			ExpectedCost: OperationCost{
+				Resolver: 10 + 1,
+				Dimensions: map[string]int{
+					"cpu": 1,
+				},
+				Variables: []string{"cost"},
+			},
+		},
+		"DimensionExceeded": {
+			Source: `{objects(first: 10) { dbReads }}`,
+			MaxCost: &OperationCost{
+				Resolver: 100,
+				Dimensions: map[string]int{
+					"dbReads": 10,
+				},
+			},
+			ExpectedCost: OperationCost{
+				Resolver: 1 + 10,
+				Dimensions: map[string]int{
+					"dbReads": 20,
+				},
+			},
+			ExpectedErrors: []string{"operation dbReads cost of 20 exceeds allowed cost of 10"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			doc, parseErrs := parser.ParseDocument([]byte(tc.Source))
+			require.Empty(t, parseErrs)
+
+			var cost OperationCost
+			errs := ValidateDocument(doc, s, nil, ValidateOperationCost("", tc.VariableValues, tc.MaxCost, &cost, schema.FieldCost{
+				Resolver: 1,
+				Dimensions: map[string]int{
+					"cpu": 1,
+				},
+			}))
+			var messages []string
+			for _, err := range errs {
+				messages = append(messages, err.Message)
+			}
+			assert.Equal(t, tc.ExpectedErrors, messages)
+			assert.Equal(t, tc.ExpectedCost, cost)
+		})
+	}
+}
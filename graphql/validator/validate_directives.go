@@ -45,7 +45,8 @@ func validateDirectives(doc *ast.Document, s *schema.Schema, features schema.Fea
 		for _, directive := range directives {
 			name := directive.Name.Name
 
-			if def := s.Directives()[name]; def == nil {
+			def := s.Directives()[name]
+			if def == nil {
 				ret = append(ret, newError(directive, "undefined directive"))
 			} else {
 				allowedLocation := false
@@ -60,7 +61,7 @@ func validateDirectives(doc *ast.Document, s *schema.Schema, features schema.Fea
 				}
 			}
 
-			if _, ok := directiveNames[name]; ok {
+			if _, ok := directiveNames[name]; ok && (def == nil || !def.IsRepeatable) {
 				ret = append(ret, newError(directive, "duplicate directive"))
 			} else {
 				directiveNames[name] = struct{}{}
@@ -4,6 +4,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql/schema"
 )
 
 func TestDirectives_Defined(t *testing.T) {
@@ -20,3 +23,22 @@ func TestDirectives_UniquePerLocation(t *testing.T) {
 	assert.Empty(t, validateSource(t, `{scalar @include(if: true)}`))
 	assert.Len(t, validateSource(t, `{scalar @include(if: true) @include(if: true)}`), 1)
 }
+
+func TestDirectives_Repeatable(t *testing.T) {
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: objectType,
+		Directives: map[string]*schema.DirectiveDefinition{
+			"tag": {
+				Arguments: map[string]*schema.InputValueDefinition{
+					"name": {
+						Type: schema.NewNonNullType(schema.StringType),
+					},
+				},
+				Locations:    []schema.DirectiveLocation{schema.DirectiveLocationField},
+				IsRepeatable: true,
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, validateSourceWithSchema(t, s, `{scalar @tag(name: "a") @tag(name: "b")}`))
+}
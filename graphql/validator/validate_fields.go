@@ -288,19 +288,28 @@ func validateSameResponseShape(fieldA, fieldB *ast.Field, fragmentDefinitions ma
 }
 
 func addFieldSelections(fieldsForName map[string][]fieldAndParent, selectionSet *ast.SelectionSet, fragmentDefinitions map[string]*ast.FragmentDefinition) *Error {
-	visited := map[*ast.SelectionSet]struct{}{}
+	visited := map[*ast.SelectionSet]bool{}
 	return addFieldSelectionsWithCycleDetection(fieldsForName, selectionSet, fragmentDefinitions, visited)
 }
 
-func addFieldSelectionsWithCycleDetection(fieldsForName map[string][]fieldAndParent, selectionSet *ast.SelectionSet, fragmentDefinitions map[string]*ast.FragmentDefinition, visited map[*ast.SelectionSet]struct{}) *Error {
+func addFieldSelectionsWithCycleDetection(fieldsForName map[string][]fieldAndParent, selectionSet *ast.SelectionSet, fragmentDefinitions map[string]*ast.FragmentDefinition, visited map[*ast.SelectionSet]bool) *Error {
 	if selectionSet == nil {
 		return nil
 	}
 
-	if _, ok := visited[selectionSet]; ok {
-		return newSecondaryError(selectionSet, "cycle detected")
+	// Visited selection sets map to true while their selections are being added. Encountering one
+	// of those again means there's a cycle. The others were reached via a fragment that's spread
+	// more than once, which is valid, and their fields have already been added.
+	if inProgress, ok := visited[selectionSet]; ok {
+		if inProgress {
+			return newSecondaryError(selectionSet, "cycle detected")
+		}
+		return nil
 	}
-	visited[selectionSet] = struct{}{}
+	visited[selectionSet] = true
+	defer func() {
+		visited[selectionSet] = false
+	}()
 
 	for _, selection := range selectionSet.Selections {
 		switch selection := selection.(type) {
@@ -61,6 +61,11 @@ func TestFields_FieldSelectionMerging(t *testing.T) {
 	assert.Empty(t, validateSource(t, `{objects:object{int} objects:object{int}}`))
 	assert.Len(t, validateSource(t, `{objects{int} objects:object{int}}`), 1)
 	assert.Len(t, validateSource(t, `{objects:object{int} objects{int}}`), 1)
+
+	// Spreading a fragment more than once is valid, but cycles are still errors.
+	assert.Empty(t, validateSource(t, `{object{...F ...F} object{...F}} fragment F on Object{int}`))
+	assert.Empty(t, validateSource(t, `{object{...F ...G}} fragment F on Object{...G} fragment G on Object{int}`))
+	assert.NotEmpty(t, validateSource(t, `{object{...F}} fragment F on Object{...G} fragment G on Object{...F}`))
 }
 
 func TestFields_Features(t *testing.T) {
@@ -0,0 +1,41 @@
+package validator
+
+import (
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+// ErrorCodeOperationTypeNotAllowed is the "code" extension of the errors produced by
+// ValidateOperationTypes.
+const ErrorCodeOperationTypeNotAllowed = "OPERATION_TYPE_NOT_ALLOWED"
+
+// ValidateOperationTypes rejects documents in which the requested operation has one of the given
+// types, e.g. "mutation" or "subscription". If operationName is empty, every operation is checked.
+// The errors' extensions have a "code" of "OPERATION_TYPE_NOT_ALLOWED".
+func ValidateOperationTypes(operationName string, disallowed ...string) Rule {
+	return func(doc *ast.Document, s *schema.Schema, features schema.FeatureSet, typeInfo *TypeInfo) []*Error {
+		var ret []*Error
+		for _, def := range doc.Definitions {
+			op, ok := def.(*ast.OperationDefinition)
+			if !ok || (operationName != "" && (op.Name == nil || op.Name.Name != operationName)) {
+				continue
+			}
+			operationType := "query"
+			if op.OperationType != nil {
+				operationType = op.OperationType.Value
+			}
+			for _, t := range disallowed {
+				if t == operationType {
+					err := newError(op, "%v operations are not allowed by this endpoint", operationType)
+					err.Extensions = map[string]interface{}{
+						"code":      ErrorCodeOperationTypeNotAllowed,
+						"retryable": false,
+					}
+					ret = append(ret, err)
+					break
+				}
+			}
+		}
+		return ret
+	}
+}
@@ -0,0 +1,67 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql/parser"
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+func TestValidateOperationTypes(t *testing.T) {
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"int": {
+					Type: schema.IntType,
+				},
+			},
+		},
+		Mutation: &schema.ObjectType{
+			Name: "Mutation",
+			Fields: map[string]*schema.FieldDefinition{
+				"int": {
+					Type: schema.IntType,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Source         string
+		OperationName  string
+		ExpectedErrors int
+	}{
+		"Query": {
+			Source: `{int}`,
+		},
+		"Mutation": {
+			Source:         `mutation {int}`,
+			ExpectedErrors: 1,
+		},
+		"UnrequestedMutation": {
+			Source:        `query Q {int} mutation M {int}`,
+			OperationName: "Q",
+		},
+		"RequestedMutation": {
+			Source:         `query Q {int} mutation M {int}`,
+			OperationName:  "M",
+			ExpectedErrors: 1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			doc, parseErrs := parser.ParseDocument([]byte(tc.Source))
+			require.Empty(t, parseErrs)
+			errs := ValidateDocument(doc, s, nil, ValidateOperationTypes(tc.OperationName, "mutation", "subscription"))
+			assert.Len(t, errs, tc.ExpectedErrors)
+			for _, err := range errs {
+				assert.Equal(t, "mutation operations are not allowed by this endpoint", err.Message)
+				assert.Equal(t, "OPERATION_TYPE_NOT_ALLOWED", err.Extensions["code"])
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package validator
+
+import (
+	"time"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+// ErrorCodeFieldSunset is the "code" extension of the errors produced by ValidateSunsets.
+const ErrorCodeFieldSunset = "FIELD_SUNSET"
+
+// ValidateSunsets rejects documents that use fields whose sunset time is at or before now. The
+// errors' extensions have a "code" of "FIELD_SUNSET".
+func ValidateSunsets(now time.Time) Rule {
+	return func(doc *ast.Document, s *schema.Schema, features schema.FeatureSet, typeInfo *TypeInfo) []*Error {
+		var ret []*Error
+		ast.Inspect(doc, func(node ast.Node) bool {
+			if field, ok := node.(*ast.Field); ok {
+				if def := typeInfo.FieldDefinitions[field]; def != nil && !def.SunsetTime.IsZero() && !now.Before(def.SunsetTime) {
+					err := newError(field.Name, "field %v was retired on %v", field.Name.Name, def.SunsetTime.UTC().Format("2006-01-02"))
+					err.Extensions = map[string]interface{}{
+						"code":       ErrorCodeFieldSunset,
+						"retryable":  false,
+						"sunsetTime": def.SunsetTime.UTC().Format(time.RFC3339),
+					}
+					ret = append(ret, err)
+				}
+			}
+			return true
+		})
+		return ret
+	}
+}
@@ -0,0 +1,71 @@
+package validator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql/parser"
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+func TestValidateSunsets(t *testing.T) {
+	sunsetTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"old": {
+					Type:              schema.IntType,
+					DeprecationReason: "Use new instead.",
+					SunsetTime:        sunsetTime,
+				},
+				"new": {
+					Type: schema.IntType,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Source         string
+		Now            time.Time
+		ExpectedErrors int
+	}{
+		"BeforeSunset": {
+			Source: `{old}`,
+			Now:    sunsetTime.Add(-time.Second),
+		},
+		"AtSunset": {
+			Source:         `{old}`,
+			Now:            sunsetTime,
+			ExpectedErrors: 1,
+		},
+		"AfterSunset": {
+			Source:         `{a: old b: old}`,
+			Now:            sunsetTime.Add(time.Hour),
+			ExpectedErrors: 2,
+		},
+		"Unused": {
+			Source: `{new}`,
+			Now:    sunsetTime.Add(time.Hour),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			doc, parseErrs := parser.ParseDocument([]byte(tc.Source))
+			require.Empty(t, parseErrs)
+
+			errs := ValidateDocument(doc, s, nil, ValidateSunsets(tc.Now))
+			assert.Len(t, errs, tc.ExpectedErrors)
+			for _, err := range errs {
+				assert.Equal(t, "field old was retired on 2020-01-01", err.Message)
+				assert.Equal(t, "FIELD_SUNSET", err.Extensions["code"])
+				assert.Equal(t, "2020-01-01T00:00:00Z", err.Extensions["sunsetTime"])
+			}
+		})
+	}
+}
@@ -45,7 +45,11 @@ func validateCoercion(from ast.Value, to schema.Type, allowItemToListCoercion bo
 
 	switch to := to.(type) {
 	case *schema.ScalarType:
-		if to.LiteralCoercion != nil && to.LiteralCoercion(from) == nil {
+		if to.ParseLiteral != nil {
+			if _, err := to.ParseLiteral(from, nil); err != nil {
+				ret = append(ret, newError(from, "cannot coerce to %v: %v", to, err))
+			}
+		} else if to.LiteralCoercion != nil && to.LiteralCoercion(from) == nil {
 			ret = append(ret, newError(from, "cannot coerce to %v", to))
 		}
 	case *schema.ListType:
@@ -1,11 +1,13 @@
 package validator
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/ccbrown/api-fu/graphql/ast"
 	"github.com/ccbrown/api-fu/graphql/parser"
 	"github.com/ccbrown/api-fu/graphql/schema"
 )
@@ -56,6 +58,39 @@ func TestValues_OfCorrectType(t *testing.T) {
 	assert.Len(t, validateSource(t, `query q ($s: ComplexInput = "foo") {findDog(complex:$s){nickname}}`), 1)
 }
 
+func TestValues_ScalarParseLiteral(t *testing.T) {
+	jsonType := &schema.ScalarType{
+		Name: "JSON",
+		ParseLiteral: func(value ast.Value, variableValues map[string]interface{}) (interface{}, error) {
+			if _, ok := value.(*ast.EnumValue); ok {
+				return nil, fmt.Errorf("enum values are not allowed")
+			}
+			return schema.LiteralValue(value, variableValues)
+		},
+	}
+	s, err := schema.New(&schema.SchemaDefinition{
+		Query: &schema.ObjectType{
+			Name: "Query",
+			Fields: map[string]*schema.FieldDefinition{
+				"json": {
+					Type: schema.BooleanType,
+					Arguments: map[string]*schema.InputValueDefinition{
+						"value": {
+							Type: jsonType,
+						},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Empty(t, validateSourceWithSchema(t, s, `{json(value: {a: [1, "b", null]})}`))
+	assert.Empty(t, validateSourceWithSchema(t, s, `query q($x: Boolean) {json(value: {a: [$x]})}`))
+	assert.Len(t, validateSourceWithSchema(t, s, `{json(value: FOO)}`), 1)
+	assert.Len(t, validateSourceWithSchema(t, s, `{json(value: {a: $x})}`), 1)
+}
+
 func TestValues_ValidateCoercion(t *testing.T) {
 	inputObjectType := &schema.InputObjectType{
 		Fields: map[string]*schema.InputValueDefinition{
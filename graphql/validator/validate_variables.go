@@ -84,6 +84,9 @@ func validateVariableUsage(def *ast.VariableDefinition, usage *ast.Variable, typ
 
 	if variableType == nil {
 		return newSecondaryError(def, "no type info for variable type")
+	} else if _, ok := typeInfo.ScalarLiteralValues[usage]; ok {
+		// the scalar's ParseLiteral function is responsible for validating these
+		return nil
 	} else if locationType == nil {
 		return newSecondaryError(usage, "no type info for location type")
 	}
@@ -16,12 +16,20 @@ type Error struct {
 	Message   string
 	Locations []Location
 
+	// Extensions to include in the error, e.g. a "code" for errors that clients may want to handle
+	// specially.
+	Extensions map[string]interface{}
+
 	// If a validator is unable to perform its job due to an error unrelated to its purpose, it will
 	// emit a secondary error. Secondary errors are always errors that should be caught by other
 	// validators, so if there are any primary errors, secondary errors are discarded as they should
 	// all be duplicates. If a secondary error makes it out of validation, there's probably a
 	// mistake in one of the validators.
 	isSecondary bool
+
+	// Constraint violations can only be detected once variable values are known, so they're
+	// reported by the cost validator rather than duplicated by other validators.
+	isConstraintViolation bool
 }
 
 func (err *Error) Error() string {
@@ -65,9 +73,7 @@ func newSecondaryError(node ast.Node, message string, args ...interface{}) *Erro
 type Rule func(*ast.Document, *schema.Schema, schema.FeatureSet, *TypeInfo) []*Error
 
 func ValidateDocument(doc *ast.Document, s *schema.Schema, features schema.FeatureSet, additionalRules ...Rule) []*Error {
-	typeInfo := NewTypeInfo(doc, s, features)
-	var errs []*Error
-	for _, f := range append([]Rule{
+	return ApplyRules(doc, s, features, NewTypeInfo(doc, s, features), append([]Rule{
 		validateDocument,
 		validateOperations,
 		validateFields,
@@ -76,7 +82,16 @@ func ValidateDocument(doc *ast.Document, s *schema.Schema, features schema.Featu
 		validateValues,
 		validateDirectives,
 		validateVariables,
-	}, additionalRules...) {
+	}, additionalRules...)...)
+}
+
+// ApplyRules validates the document using only the given rules. The type info must have been
+// created by NewTypeInfo for the same document, schema, and features. This allows documents that
+// have already passed ValidateDocument to be checked against request-specific rules, such as cost
+// limits, without being fully revalidated.
+func ApplyRules(doc *ast.Document, s *schema.Schema, features schema.FeatureSet, typeInfo *TypeInfo, rules ...Rule) []*Error {
+	var errs []*Error
+	for _, f := range rules {
 		errs = append(errs, f(doc, s, features, typeInfo)...)
 	}
 	var primary []*Error
@@ -229,6 +229,55 @@ func init() {
 				}
 			},
 		},
+		"filteredObjects": {
+			Type: schema.NewListType(objectType),
+			Arguments: map[string]*schema.InputValueDefinition{
+				"filters": {
+					Type: schema.NewListType(&schema.InputObjectType{
+						Name: "ObjectFilter",
+						Fields: map[string]*schema.InputValueDefinition{
+							"limit": {
+								Type: schema.IntType,
+							},
+						},
+					}),
+				},
+			},
+			Cost: func(ctx schema.FieldCostContext) schema.FieldCost {
+				// The multiplier is the sum of the filters' limits.
+				multiplier := 0
+				filters, _ := ctx.Arguments["filters"].([]interface{})
+				for _, filter := range filters {
+					limit, _ := filter.(map[string]interface{})["limit"].(int)
+					multiplier += limit
+				}
+				return schema.FieldCost{
+					Resolver:   1,
+					Multiplier: multiplier,
+				}
+			},
+		},
+		"costFromVariable": {
+			Type: schema.IntType,
+			Cost: func(ctx schema.FieldCostContext) schema.FieldCost {
+				cost, _ := ctx.VariableValues["cost"].(int)
+				return schema.FieldCost{
+					Resolver:  cost,
+					Variables: []string{"cost"},
+				}
+			},
+		},
+		"dbReads": {
+			Type: schema.IntType,
+			Cost: func(ctx schema.FieldCostContext) schema.FieldCost {
+				return schema.FieldCost{
+					Resolver: 1,
+					Dimensions: map[string]int{
+						"dbReads": 2,
+					},
+				}
+			},
+		},
 		"objectWithCostContext": {
 			Type: objectType,
 			Arguments: map[string]*schema.InputValueDefinition{
@@ -382,4 +431,5 @@ func validateSourceWithSchema(t *testing.T, s *schema.Schema, src string, featur
 
 func TestIntrospectionQuery(t *testing.T) {
 	assert.Empty(t, validateSource(t, string(introspection.Query)))
+	assert.Empty(t, validateSource(t, string(introspection.FullQuery)))
 }
@@ -0,0 +1,84 @@
+package apifu
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/executor"
+)
+
+// If Config.StrictGraphQLOverHTTP is true, this checks the request's headers against the GraphQL
+// over HTTP specification. If the request must be rejected, a non-zero status code and a message
+// are returned.
+func (api *API) checkGraphQLOverHTTPRequest(r *http.Request) (int, string) {
+	if !api.config.StrictGraphQLOverHTTP {
+		return 0, ""
+	}
+
+	if accept := r.Header.Values("Accept"); !api.acceptsResponse(accept) {
+		return http.StatusNotAcceptable, "none of the accepted media types are supported"
+	}
+
+	if r.Method == http.MethodPost {
+		mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || (mediaType != "application/json" && mediaType != "application/graphql") {
+			return http.StatusUnsupportedMediaType, "unsupported content-type"
+		} else if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+			return http.StatusUnsupportedMediaType, "unsupported charset"
+		}
+	}
+
+	return 0, ""
+}
+
+// Returns true if a response can be written in a media type that the given Accept header values
+// accept.
+func (api *API) acceptsResponse(accept []string) bool {
+	mediaTypes := []string{graphql.JSONContentType, graphql.GraphQLResponseContentType}
+	if api.config.BinaryResponseEncodings {
+		mediaTypes = append(mediaTypes, graphql.MsgpackContentType, graphql.CBORContentType)
+	}
+	for _, mediaType := range mediaTypes {
+		if graphql.IsAcceptable(accept, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if Config.StrictGraphQLOverHTTP is true and the request uses a method that can't be
+// used to execute the operation. The spec only allows queries to be executed via GET.
+func (api *API) isGraphQLOverHTTPMethodNotAllowed(r *http.Request, doc *ast.Document, operationName string) bool {
+	if !api.config.StrictGraphQLOverHTTP || r.Method != http.MethodGet {
+		return false
+	}
+	op, err := executor.GetOperation(doc, operationName)
+	if err != nil {
+		// Execution will report the error.
+		return false
+	}
+	return op.OperationType != nil && op.OperationType.Value != "query"
+}
+
+// Returns true if the operation with the given name is a query.
+func isQuery(doc *ast.Document, operationName string) bool {
+	op, err := executor.GetOperation(doc, operationName)
+	return err == nil && (op.OperationType == nil || op.OperationType.Value == "query")
+}
+
+// Returns the HTTP status code for a response written with the given content type. Zero indicates
+// that the default of 200 should be used.
+func (api *API) graphQLResponseHTTPStatus(r *http.Request, contentType string, resp interface{}) int {
+	graphqlResp, ok := resp.(*graphql.Response)
+	if !ok {
+		return 0
+	} else if api.config.HTTPStatus != nil {
+		return api.config.HTTPStatus(r, graphqlResp)
+	} else if api.config.StrictGraphQLOverHTTP && contentType == graphql.GraphQLResponseContentType {
+		return graphql.ResponseHTTPStatus(graphqlResp)
+	}
+	return 0
+}
@@ -0,0 +1,157 @@
+package apifu
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestServeGraphQL_StrictGraphQLOverHTTP(t *testing.T) {
+	testCfg := Config{
+		StrictGraphQLOverHTTP: true,
+	}
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+	testCfg.AddQueryField("error", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return nil, graphql.NewCodedError(graphql.ErrorCodeUnauthenticated, "not authenticated")
+		},
+	})
+	mutations := 0
+	testCfg.AddMutation("bar", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			mutations++
+			return 1, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Method              string
+		Query               string
+		ContentType         string
+		Accept              string
+		ExpectedStatus      int
+		ExpectedContentType string
+		ExpectedBody        string
+	}{
+		"GraphQLResponse": {
+			Query:               `{foo}`,
+			Accept:              "application/graphql-response+json",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/graphql-response+json; charset=utf-8",
+			ExpectedBody:        `{"data":{"foo":1}}`,
+		},
+		"FieldError": {
+			Query:               `{foo error}`,
+			Accept:              "application/graphql-response+json",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/graphql-response+json; charset=utf-8",
+		},
+		"ValidationError": {
+			Query:               `{baz}`,
+			Accept:              "application/graphql-response+json",
+			ExpectedStatus:      http.StatusBadRequest,
+			ExpectedContentType: "application/graphql-response+json; charset=utf-8",
+		},
+		"LegacyValidationError": {
+			Query:               `{baz}`,
+			Accept:              "application/json",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/json; charset=utf-8",
+		},
+		"NotAcceptable": {
+			Query:          `{foo}`,
+			Accept:         "text/html",
+			ExpectedStatus: http.StatusNotAcceptable,
+		},
+		"UTF8Charset": {
+			Query:               `{foo}`,
+			ContentType:         "application/graphql; charset=UTF-8",
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/json; charset=utf-8",
+			ExpectedBody:        `{"data":{"foo":1}}`,
+		},
+		"UnsupportedCharset": {
+			Query:          `{foo}`,
+			ContentType:    "application/graphql; charset=latin1",
+			ExpectedStatus: http.StatusUnsupportedMediaType,
+		},
+		"UnsupportedContentType": {
+			Query:          `{foo}`,
+			ContentType:    "text/plain",
+			ExpectedStatus: http.StatusUnsupportedMediaType,
+		},
+		"GetQuery": {
+			Method:              "GET",
+			Query:               `{foo}`,
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/json; charset=utf-8",
+			ExpectedBody:        `{"data":{"foo":1}}`,
+		},
+		"GetMutation": {
+			Method:              "GET",
+			Query:               `mutation {bar}`,
+			ExpectedStatus:      http.StatusMethodNotAllowed,
+			ExpectedContentType: "application/json; charset=utf-8",
+			ExpectedBody:        `{"errors":[{"message":"Only queries can be executed via GET requests."}]}`,
+		},
+		"PostMutation": {
+			Query:               `mutation {bar}`,
+			ExpectedStatus:      http.StatusOK,
+			ExpectedContentType: "application/json; charset=utf-8",
+			ExpectedBody:        `{"data":{"bar":1}}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var r *http.Request
+			if tc.Method == "GET" {
+				r = httptest.NewRequest("GET", "/?"+url.Values{"query": []string{tc.Query}}.Encode(), nil)
+			} else {
+				r = httptest.NewRequest("POST", "/", strings.NewReader(tc.Query))
+				contentType := tc.ContentType
+				if contentType == "" {
+					contentType = "application/graphql"
+				}
+				r.Header.Set("Content-Type", contentType)
+			}
+			if tc.Accept != "" {
+				r.Header.Set("Accept", tc.Accept)
+			}
+			w := httptest.NewRecorder()
+			api.ServeGraphQL(w, r)
+			resp := w.Result()
+			assert.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+			if tc.ExpectedContentType != "" {
+				assert.Equal(t, tc.ExpectedContentType, resp.Header.Get("Content-Type"))
+			}
+			if tc.ExpectedBody != "" {
+				body, err := ioutil.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, tc.ExpectedBody, string(body))
+			}
+			if tc.ExpectedStatus == http.StatusMethodNotAllowed {
+				assert.Equal(t, "POST", resp.Header.Get("Allow"))
+			}
+		})
+	}
+
+	// The mutation must only have been executed via POST.
+	assert.Equal(t, 1, mutations)
+}
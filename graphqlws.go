@@ -3,38 +3,48 @@ package apifu
 import (
 	"context"
 	"encoding/json"
-	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/hashicorp/go-multierror"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/transport"
 	"github.com/ccbrown/api-fu/graphql/transport/graphqltransportws"
 	"github.com/ccbrown/api-fu/graphql/transport/graphqlws"
 )
 
-type graphqlWSConnection interface {
-	SendData(ctx context.Context, id string, response *graphql.Response) error
-	SendComplete(ctx context.Context, id string) error
-	Serve(conn *websocket.Conn)
-	io.Closer
-}
-
 type graphqlWSHandler struct {
 	API        *API
-	Connection graphqlWSConnection
+	Connection transport.Connection
 	Context    context.Context
 	Logger     logrus.FieldLogger
 
+	// Uniquely identifies the connection. See SubscriptionInfo.ConnectionId.
+	ConnectionId string
+
+	// The request that was upgraded. Config.RequestPolicies are applied to it for each operation.
+	Request *http.Request
+
 	cancelContext func()
-	subscriptions map[string]SubscriptionSourceStream
 	features      graphql.FeatureSet
+
+	// Subscriptions may be stopped by the API's TerminateSubscription method, so access must be
+	// synchronized.
+	subscriptionsMutex sync.Mutex
+	subscriptions      map[string]SubscriptionSourceStream
+	deliverers         map[string]*subscriptionDeliverer
 }
 
+var _ transport.ConnectionAckPayloadHandler = (*graphqlWSHandler)(nil)
+var _ transport.DeliveryAckHandler = (*graphqlWSHandler)(nil)
+var _ transport.ExtensionsHandler = (*graphqlWSHandler)(nil)
+
 func (h *graphqlWSHandler) HandleInit(parameters json.RawMessage) error {
 	if f := h.API.config.HandleGraphQLWSInit; f != nil {
 		if ctx, err := f(h.Context, parameters); err != nil {
@@ -43,18 +53,26 @@ func (h *graphqlWSHandler) HandleInit(parameters json.RawMessage) error {
 			h.Context = ctx
 		}
 	}
-	if h.API.config.Features != nil {
-		h.features = h.API.config.Features(h.Context)
-	}
+	h.features = h.API.requestFeatures(h.Context)
 	return nil
 }
 
 func (h *graphqlWSHandler) HandleStart(id string, query string, variables map[string]any, operationName string) {
+	h.HandleStartWithExtensions(id, query, variables, operationName, nil)
+}
+
+func (h *graphqlWSHandler) HandleStartWithExtensions(id string, query string, variables map[string]any, operationName string, extensions map[string]any) {
 	ctx := context.WithValue(h.Context, apiContextKey, h.API)
 
 	apiRequest := &apiRequest{}
 	ctx = context.WithValue(ctx, apiRequestContextKey, apiRequest)
 
+	ctx, resp := h.API.handleExtensions(ctx, extensions)
+	if resp != nil {
+		h.sendResponse(id, resp)
+		return
+	}
+
 	req := &graphql.Request{
 		Context:        ctx,
 		Query:          query,
@@ -63,11 +81,23 @@ func (h *graphqlWSHandler) HandleStart(id string, query string, variables map[st
 		Features:       h.features,
 		OperationName:  operationName,
 		VariableValues: variables,
+		Extensions:     extensions,
+
+		IdleHandlerStallLimit: idleHandlerStallLimit,
+		CancelPromise:         apiRequest.CancelPromise,
+		ReportNulledFields:    h.API.config.ReportNulledFields,
+		ReportDeprecations:    h.API.config.ReportDeprecations,
+		ReportSchemaWarnings:  h.API.config.ReportSchemaWarnings,
+		MaxErrors:             h.API.config.MaxErrors,
+		MaxErrorMessageLength: h.API.config.MaxErrorMessageLength,
 	}
 
 	var info RequestInfo
-	var resp *graphql.Response
-	if doc, errs := graphql.ParseAndValidate(req.Query, req.Schema, req.Features, req.ValidateCost(-1, &info.Cost, h.API.config.DefaultFieldCost)); len(errs) > 0 {
+	doc, errs := h.API.parseAndValidate(req, &info)
+	if len(errs) == 0 && h.Request != nil {
+		errs = h.API.applyRequestPolicies(h.Request, req, doc)
+	}
+	if len(errs) > 0 {
 		resp = &graphql.Response{
 			Errors: errs,
 		}
@@ -75,39 +105,97 @@ func (h *graphqlWSHandler) HandleStart(id string, query string, variables map[st
 		req.Document = doc
 
 		if graphql.IsSubscription(doc, operationName) {
-			if _, ok := h.subscriptions[id]; ok {
+			h.subscriptionsMutex.Lock()
+			_, exists := h.subscriptions[id]
+			h.subscriptionsMutex.Unlock()
+			if exists {
 				// if the subscription already exists, ignore this message. should we do something
 				// else though?
 				return
 			}
+			subscriptionInfo := newSubscriptionInfo(id, h.ConnectionId, query, doc, operationName)
+			req.Context = context.WithValue(req.Context, subscriptionInfoContextKey, subscriptionInfo)
 			if sourceStream, errs := graphql.Subscribe(req); len(errs) > 0 {
 				resp = &graphql.Response{
 					Errors: errs,
 				}
 			} else {
-				if h.subscriptions == nil {
-					h.subscriptions = map[string]SubscriptionSourceStream{}
-				}
 				sourceStreamIn := sourceStream.(*SubscriptionSourceStream)
 				// Note we can't use the request context here, because the Go http package closes it
 				// after a hijacked connection's handler returns.
 				ctx, cancel := context.WithCancel(context.Background())
 				sourceStream := *sourceStreamIn
 				sourceStream.Stop = func() {
-					sourceStreamIn.Stop()
+					sourceStreamIn.stop(subscriptionInfo)
 					cancel()
 				}
+				h.subscriptionsMutex.Lock()
+				if h.subscriptions == nil {
+					h.subscriptions = map[string]SubscriptionSourceStream{}
+				}
 				h.subscriptions[id] = sourceStream
+				h.subscriptionsMutex.Unlock()
+				unregister := h.API.registerSubscription(req.Context, h.ConnectionId+":"+id, subscriptionInfo, func() {
+					h.HandleStop(id)
+				})
+				var deltaEncoder *subscriptionDeltaEncoder
+				if h.API.config.SubscriptionDeltas && wantsSubscriptionDeltas(extensions) {
+					deltaEncoder = &subscriptionDeltaEncoder{}
+				}
+				deliverer := newSubscriptionDeliverer(sourceStream.Delivery, func(resp *graphql.Response) error {
+					return h.Connection.SendData(ctx, id, resp)
+				}, func() {
+					h.Connection.Close()
+				})
+				if deliverer != nil {
+					if deltaEncoder != nil {
+						deliverer.prepare = deltaEncoder.encode
+					}
+					h.subscriptionsMutex.Lock()
+					if h.deliverers == nil {
+						h.deliverers = map[string]*subscriptionDeliverer{}
+					}
+					h.deliverers[id] = deliverer
+					h.subscriptionsMutex.Unlock()
+					go func() {
+						if err := deliverer.run(ctx); err != nil && err != context.Canceled {
+							h.Logger.Warn(errors.Wrap(err, "error sending graphql-ws data"))
+						}
+					}()
+				}
 				go func() {
+					defer unregister()
 					if err := sourceStream.Run(ctx, func(event any) {
 						req := *req
 						req.InitialValue = event
-						if err := h.Connection.SendData(context.Background(), id, h.API.execute(&req, &info)); err != nil {
+						resp := h.API.execute(&req, &info)
+						if deliverer != nil {
+							deliverer.deliver(ctx, resp)
+							return
+						}
+						if deltaEncoder != nil {
+							resp = deltaEncoder.encode(resp)
+						}
+						if err := h.Connection.SendData(context.Background(), id, resp); err != nil {
 							h.Logger.Warn(errors.Wrap(err, "error sending graphql-ws data"))
+						} else if flusher, ok := h.Connection.(transport.Flusher); ok && sourceStream.Request != nil {
+							// Don't request more events until the client has actually received this
+							// one.
+							if err := flusher.Flush(ctx); err != nil && err != context.Canceled {
+								h.Logger.Warn(errors.Wrap(err, "error flushing graphql-ws data"))
+							}
 						}
 					}); err != nil && err != context.Canceled {
 						h.Logger.Error(errors.Wrap(err, "error running source stream"))
 					}
+					if deliverer != nil {
+						deliverer.close()
+						h.subscriptionsMutex.Lock()
+						if h.deliverers[id] == deliverer {
+							delete(h.deliverers, id)
+						}
+						h.subscriptionsMutex.Unlock()
+					}
 					if err := h.Connection.SendComplete(context.Background(), id); err != nil {
 						h.Logger.Warn(errors.Wrap(err, "error sending graphql-ws complete"))
 					}
@@ -119,19 +207,38 @@ func (h *graphqlWSHandler) HandleStart(id string, query string, variables map[st
 	}
 
 	if resp != nil {
-		if err := h.Connection.SendData(context.Background(), id, resp); err != nil {
-			h.Logger.Warn(errors.Wrap(err, "error sending graphql-ws data"))
-		}
-		if err := h.Connection.SendComplete(context.Background(), id); err != nil {
-			h.Logger.Warn(errors.Wrap(err, "error sending graphql-ws complete"))
-		}
+		h.sendResponse(id, resp)
+	}
+}
+
+// Sends the response to a query or mutation, completing the operation.
+func (h *graphqlWSHandler) sendResponse(id string, resp *graphql.Response) {
+	if err := h.Connection.SendData(context.Background(), id, resp); err != nil {
+		h.Logger.Warn(errors.Wrap(err, "error sending graphql-ws data"))
+	}
+	if err := h.Connection.SendComplete(context.Background(), id); err != nil {
+		h.Logger.Warn(errors.Wrap(err, "error sending graphql-ws complete"))
 	}
 }
 
 func (h *graphqlWSHandler) HandleStop(id string) {
-	if stream, ok := h.subscriptions[id]; ok {
+	h.subscriptionsMutex.Lock()
+	stream, ok := h.subscriptions[id]
+	delete(h.subscriptions, id)
+	h.subscriptionsMutex.Unlock()
+
+	if ok {
 		stream.Stop()
-		delete(h.subscriptions, id)
+	}
+}
+
+func (h *graphqlWSHandler) HandleDeliveryAck(id string, sequence int64) {
+	h.subscriptionsMutex.Lock()
+	deliverer := h.deliverers[id]
+	h.subscriptionsMutex.Unlock()
+
+	if deliverer != nil {
+		deliverer.ack(sequence)
 	}
 }
 
@@ -141,19 +248,51 @@ func (h *graphqlWSHandler) LogError(err error) {
 
 func (h *graphqlWSHandler) Cancel() {
 	h.cancelContext()
+	h.stopSubscriptions()
 }
 
 func (h *graphqlWSHandler) HandleClose() {
-	for _, stream := range h.subscriptions {
-		stream.Stop()
-	}
-	h.subscriptions = nil
+	h.stopSubscriptions()
 
 	h.API.graphqlWSConnectionsMutex.Lock()
 	defer h.API.graphqlWSConnectionsMutex.Unlock()
 	delete(h.API.graphqlWSConnections, h.Connection)
 }
 
+func (h *graphqlWSHandler) stopSubscriptions() {
+	h.subscriptionsMutex.Lock()
+	subscriptions := h.subscriptions
+	h.subscriptions = nil
+	h.subscriptionsMutex.Unlock()
+
+	for _, stream := range subscriptions {
+		stream.Stop()
+	}
+}
+
+func (h *graphqlWSHandler) ConnectionAckPayload() json.RawMessage {
+	payload, ok := h.Context.Value(graphqlWSAckPayloadContextKey).(json.RawMessage)
+	if !ok {
+		return nil
+	}
+	return payload
+}
+
+type graphqlWSAckPayloadContextKeyType int
+
+var graphqlWSAckPayloadContextKey graphqlWSAckPayloadContextKeyType
+
+// WithGraphQLWSAckPayload returns a context that causes the given payload to be sent to the client
+// with the connection_ack message. It's intended to be used by Config.HandleGraphQLWSInit to give
+// clients session data such as a user id. The payload must be JSON-marshalable.
+func WithGraphQLWSAckPayload(ctx context.Context, payload interface{}) (context.Context, error) {
+	buf, err := jsoniter.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal ack payload")
+	}
+	return context.WithValue(ctx, graphqlWSAckPayloadContextKey, json.RawMessage(buf)), nil
+}
+
 // This type is a context which gets values from another context (e.g. a canceled http.Request
 // context after a hijacking).
 type hijackedContext struct {
@@ -177,8 +316,20 @@ func (ctx hijackedContext) Value(key any) any {
 	return ctx.valueContext.Value(key)
 }
 
+// HTTPError is an error with an HTTP status code. It can be returned by hooks such as
+// Config.HandleGraphQLWSUpgrade to reject requests with a specific status.
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (err *HTTPError) Error() string {
+	return err.Message
+}
+
 // ServeGraphQLWS serves a GraphQL WebSocket connection. It will serve connections for both the
-// deprecated graphql-ws subprotocol and the newer graphql-transport-ws subprotocol.
+// deprecated graphql-ws subprotocol and the newer graphql-transport-ws subprotocol, as well as any
+// subprotocols registered via RegisterWebSocketSubprotocol.
 //
 // This method hijacks connections. To gracefully close them, use CloseHijackedConnections.
 func (api *API) ServeGraphQLWS(w http.ResponseWriter, r *http.Request) {
@@ -187,10 +338,40 @@ func (api *API) ServeGraphQLWS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if f := api.config.HandleGraphQLWSUpgrade; f != nil {
+		ctx, err := f(r)
+		if err != nil {
+			status := http.StatusForbidden
+			if httpErr, ok := err.(*HTTPError); ok && httpErr.StatusCode != 0 {
+				status = httpErr.StatusCode
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if ctx != nil {
+			r = r.WithContext(ctx)
+		}
+	}
+
+	connectionId, err := newRandomId()
+	if err != nil {
+		http.Error(w, "unable to generate a connection id", http.StatusInternalServerError)
+		return
+	}
+
+	// Registered subprotocols are preferred over the built-in ones.
+	api.webSocketSubprotocolsMutex.RLock()
+	subprotocols := make([]string, 0, len(api.webSocketSubprotocols)+2)
+	for _, subprotocol := range api.webSocketSubprotocols {
+		subprotocols = append(subprotocols, subprotocol.name)
+	}
+	api.webSocketSubprotocolsMutex.RUnlock()
+	subprotocols = append(subprotocols, graphqlws.WebSocketSubprotocol, graphqltransportws.WebSocketSubprotocol)
+
 	var upgrader = websocket.Upgrader{
 		CheckOrigin:       api.config.WebSocketOriginCheck,
 		EnableCompression: true,
-		Subprotocols:      []string{graphqlws.WebSocketSubprotocol, graphqltransportws.WebSocketSubprotocol},
+		Subprotocols:      subprotocols,
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -210,17 +391,31 @@ func (api *API) ServeGraphQLWS(w http.ResponseWriter, r *http.Request) {
 			valueContext: r.Context(),
 		},
 		Logger:        api.logger,
+		ConnectionId:  connectionId,
+		Request:       r,
 		cancelContext: cancel,
 	}
 
-	var connection graphqlWSConnection
-	if conn.Subprotocol() == graphqltransportws.WebSocketSubprotocol {
+	marshalBinary, _ := api.binaryResponseEncoding(r.Header.Values("Accept"))
+
+	var connection transport.Connection
+	if newConnection := api.webSocketSubprotocol(conn.Subprotocol()); newConnection != nil {
+		connection = newConnection(handler)
+	} else if conn.Subprotocol() == graphqltransportws.WebSocketSubprotocol {
 		connection = &graphqltransportws.Connection{
-			Handler: handler,
+			Handler:           handler,
+			MarshalBinary:     marshalBinary,
+			KeepAliveInterval: api.config.GraphQLWSKeepAliveInterval,
+			MaxPayloadSize:    api.config.GraphQLWSMaxPayloadSize,
+			PayloadChunkSize:  api.config.GraphQLWSPayloadChunkSize,
 		}
 	} else {
 		connection = &graphqlws.Connection{
-			Handler: handler,
+			Handler:           handler,
+			MarshalBinary:     marshalBinary,
+			KeepAliveInterval: api.config.GraphQLWSKeepAliveInterval,
+			MaxPayloadSize:    api.config.GraphQLWSMaxPayloadSize,
+			PayloadChunkSize:  api.config.GraphQLWSPayloadChunkSize,
 		}
 	}
 
@@ -233,16 +428,58 @@ func (api *API) ServeGraphQLWS(w http.ResponseWriter, r *http.Request) {
 	connection.Serve(conn)
 }
 
+type webSocketSubprotocol struct {
+	name          string
+	newConnection func(handler transport.ConnectionHandler) transport.Connection
+}
+
+// RegisterWebSocketSubprotocol adds support for an additional WebSocket subprotocol to
+// ServeGraphQLWS. When a client negotiates the subprotocol, newConnection is invoked to create a
+// connection for it. The connection should translate its protocol's messages into calls to the
+// given handler, which executes operations and manages subscriptions the same way it does for the
+// built-in subprotocols.
+//
+// If a client supports more than one of the server's subprotocols, registered subprotocols are
+// preferred in the order they were registered, followed by the built-in ones. Registering a
+// subprotocol that was already registered or is built-in replaces it.
+func (api *API) RegisterWebSocketSubprotocol(name string, newConnection func(handler transport.ConnectionHandler) transport.Connection) {
+	api.webSocketSubprotocolsMutex.Lock()
+	defer api.webSocketSubprotocolsMutex.Unlock()
+	for _, subprotocol := range api.webSocketSubprotocols {
+		if subprotocol.name == name {
+			subprotocol.newConnection = newConnection
+			return
+		}
+	}
+	api.webSocketSubprotocols = append(api.webSocketSubprotocols, &webSocketSubprotocol{
+		name:          name,
+		newConnection: newConnection,
+	})
+}
+
+// Returns the connection constructor for the given registered subprotocol, or nil if it isn't
+// registered.
+func (api *API) webSocketSubprotocol(name string) func(handler transport.ConnectionHandler) transport.Connection {
+	api.webSocketSubprotocolsMutex.RLock()
+	defer api.webSocketSubprotocolsMutex.RUnlock()
+	for _, subprotocol := range api.webSocketSubprotocols {
+		if subprotocol.name == name {
+			return subprotocol.newConnection
+		}
+	}
+	return nil
+}
+
 // CloseHijackedConnections closes connections hijacked by ServeGraphQLWS.
 func (api *API) CloseHijackedConnections() error {
 	api.graphqlWSConnectionsMutex.Lock()
-	connections := make([]graphqlWSConnection, len(api.graphqlWSConnections))
+	connections := make([]transport.Connection, len(api.graphqlWSConnections))
 	i := 0
 	for connection := range api.graphqlWSConnections {
 		connections[i] = connection
 		i++
 	}
-	api.graphqlWSConnections = map[graphqlWSConnection]struct{}{}
+	api.graphqlWSConnections = map[transport.Connection]struct{}{}
 	api.graphqlWSConnectionsMutex.Unlock()
 
 	var ret error
@@ -2,11 +2,14 @@ package apifu
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -16,6 +19,7 @@ import (
 
 	"github.com/ccbrown/api-fu/graphql"
 	"github.com/ccbrown/api-fu/graphql/schema"
+	"github.com/ccbrown/api-fu/graphql/transport"
 	"github.com/ccbrown/api-fu/graphql/transport/graphqltransportws"
 	"github.com/ccbrown/api-fu/graphql/transport/graphqlws"
 )
@@ -56,6 +60,34 @@ var oneEventSubscription = &graphql.FieldDefinition{
 	},
 }
 
+// Produces three events, each only once it's been requested.
+var demandSubscription = &graphql.FieldDefinition{
+	Type: graphql.NewNonNullType(graphql.IntType),
+	Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+		if ctx.IsSubscribe {
+			ch := make(chan int, 3)
+			sent := 0
+			return &SubscriptionSourceStream{
+				EventChannel: ch,
+				Stop:         func() {},
+				Request: func(n int) {
+					for ; n > 0 && sent < 3; n-- {
+						sent++
+						ch <- sent
+						if sent == 3 {
+							close(ch)
+						}
+					}
+				},
+			}, nil
+		} else if ctx.Object != nil {
+			return ctx.Object, nil
+		} else {
+			return nil, fmt.Errorf("subscriptions are not supported using this protocol")
+		}
+	},
+}
+
 func TestGraphQLWS(t *testing.T) {
 	var testCfg Config
 	testCfg.Features = featuresFromContext
@@ -77,6 +109,7 @@ func TestGraphQLWS(t *testing.T) {
 
 	testCfg.AddSubscription("time", timeSubscription)
 	testCfg.AddSubscription("oneEvent", oneEventSubscription)
+	testCfg.AddSubscription("demand", demandSubscription)
 
 	api, err := NewAPI(&testCfg)
 	require.NoError(t, err)
@@ -195,6 +228,31 @@ func TestGraphQLWS(t *testing.T) {
 		assert.Equal(t, "sub", msg.Id)
 		assert.Equal(t, graphqlws.MessageTypeComplete, msg.Type)
 	})
+
+	t.Run("DemandSubscription", func(t *testing.T) {
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"id":   "demand",
+			"type": "start",
+			"payload": map[string]interface{}{
+				"query": `
+					subscription {
+						demand
+					}
+				`,
+			},
+		}))
+
+		for i := 1; i <= 3; i++ {
+			require.NoError(t, conn.ReadJSON(&msg))
+			assert.Equal(t, "demand", msg.Id)
+			assert.Equal(t, graphqlws.MessageTypeData, msg.Type)
+			assert.JSONEq(t, fmt.Sprintf(`{"data":{"demand":%v}}`, i), string(msg.Payload))
+		}
+
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, "demand", msg.Id)
+		assert.Equal(t, graphqlws.MessageTypeComplete, msg.Type)
+	})
 }
 
 func TestGraphQLWS_InitParameters(t *testing.T) {
@@ -541,3 +599,550 @@ func TestGraphQLTransportWS_InitParameters(t *testing.T) {
 		})
 	}
 }
+
+func TestGraphQLWS_Upgrade(t *testing.T) {
+	var testCfg Config
+
+	testCfg.AddQueryField("whoami", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return ctx.Context.Value("name"), nil
+		},
+	})
+
+	testCfg.HandleGraphQLWSUpgrade = func(r *http.Request) (context.Context, error) {
+		name := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		for _, protocol := range websocket.Subprotocols(r) {
+			if strings.HasPrefix(protocol, "token.") {
+				name = strings.TrimPrefix(protocol, "token.")
+			}
+		}
+		if name == "" {
+			return nil, &HTTPError{
+				StatusCode: http.StatusUnauthorized,
+				Message:    "no credentials",
+			}
+		} else if name == "mallory" {
+			return nil, fmt.Errorf("banned")
+		} else if name == "anonymous" {
+			return nil, nil
+		}
+		return context.WithValue(r.Context(), "name", name), nil
+	}
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+	defer api.CloseHijackedConnections()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.ServeGraphQLWS(w, r)
+	}))
+	defer ts.Close()
+
+	for name, tc := range map[string]struct {
+		Header         http.Header
+		Subprotocols   []string
+		ExpectedName   interface{}
+		ExpectedStatus int
+	}{
+		"Header": {
+			Header:       http.Header{"Authorization": []string{"Bearer alice"}},
+			ExpectedName: "alice",
+		},
+		"Subprotocol": {
+			Subprotocols: []string{"token.bob"},
+			ExpectedName: "bob",
+		},
+		"NilContext": {
+			Subprotocols: []string{"token.anonymous"},
+			ExpectedName: nil,
+		},
+		"Unauthorized": {
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+		"Forbidden": {
+			Header:         http.Header{"Authorization": []string{"Bearer mallory"}},
+			ExpectedStatus: http.StatusForbidden,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dialer := &websocket.Dialer{
+				HandshakeTimeout: time.Second,
+				Subprotocols:     append([]string{graphqltransportws.WebSocketSubprotocol}, tc.Subprotocols...),
+			}
+			conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), tc.Header)
+			if tc.ExpectedStatus != 0 {
+				require.Error(t, err)
+				require.NotNil(t, resp)
+				assert.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+				return
+			}
+			require.NoError(t, err)
+			defer conn.Close()
+			assert.Equal(t, graphqltransportws.WebSocketSubprotocol, conn.Subprotocol())
+
+			require.NoError(t, conn.WriteJSON(map[string]interface{}{
+				"type": "connection_init",
+			}))
+
+			var msg graphqltransportws.Message
+			require.NoError(t, conn.ReadJSON(&msg))
+			assert.Equal(t, graphqltransportws.MessageTypeConnectionAck, msg.Type)
+
+			require.NoError(t, conn.WriteJSON(map[string]interface{}{
+				"id":   "query",
+				"type": "subscribe",
+				"payload": map[string]interface{}{
+					"query": `{whoami}`,
+				},
+			}))
+
+			require.NoError(t, conn.ReadJSON(&msg))
+			assert.Equal(t, graphqltransportws.MessageTypeNext, msg.Type)
+			expectedName, err := json.Marshal(tc.ExpectedName)
+			require.NoError(t, err)
+			assert.JSONEq(t, fmt.Sprintf(`{"data": {"whoami": %s}}`, expectedName), string(msg.Payload))
+		})
+	}
+}
+
+func TestGraphQLWS_SubscriptionInfo(t *testing.T) {
+	var testCfg Config
+
+	stopped := make(chan *SubscriptionInfo, 1)
+	var subscribeInfo *SubscriptionInfo
+
+	testCfg.AddSubscription("info", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			info := CtxSubscriptionInfo(ctx.Context)
+			if ctx.IsSubscribe {
+				subscribeInfo = info
+				ch := make(chan int, 1)
+				ch <- 1
+				return &SubscriptionSourceStream{
+					EventChannel: ch,
+					Stop:         func() {},
+					OnStop: func(info *SubscriptionInfo) {
+						stopped <- info
+					},
+				}, nil
+			}
+			return info.OperationName + ":" + info.Id, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+	defer api.CloseHijackedConnections()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.ServeGraphQLWS(w, r)
+	}))
+	defer ts.Close()
+
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: time.Second,
+		Subprotocols:     []string{graphqltransportws.WebSocketSubprotocol},
+	}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(map[string]string{
+		"type": "connection_init",
+	}))
+
+	var msg graphqltransportws.Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, graphqltransportws.MessageTypeConnectionAck, msg.Type)
+
+	query := `subscription Info { info }`
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"id":   "sub",
+		"type": "subscribe",
+		"payload": map[string]interface{}{
+			"query": query,
+		},
+	}))
+
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, graphqltransportws.MessageTypeNext, msg.Type)
+	assert.JSONEq(t, `{"data":{"info":"Info:sub"}}`, string(msg.Payload))
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"id":   "sub",
+		"type": "complete",
+	}))
+
+	select {
+	case info := <-stopped:
+		assert.Same(t, subscribeInfo, info)
+		assert.Equal(t, "sub", info.Id)
+		assert.Equal(t, "Info", info.OperationName)
+		hash := sha256.Sum256([]byte(query))
+		assert.Equal(t, hex.EncodeToString(hash[:]), info.DocumentHash)
+		assert.NotEmpty(t, info.ConnectionId)
+		assert.False(t, info.StartTime.IsZero())
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscription was not stopped")
+	}
+}
+
+func TestGraphQLWS_BinaryResponseEncodings(t *testing.T) {
+	var testCfg Config
+	testCfg.BinaryResponseEncodings = true
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+	testCfg.HandleGraphQLWSInit = func(ctx context.Context, parameters json.RawMessage) (context.Context, error) {
+		return WithGraphQLWSAckPayload(ctx, map[string]interface{}{
+			"n": 1,
+		})
+	}
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+	defer api.CloseHijackedConnections()
+
+	ts := httptest.NewServer(http.HandlerFunc(api.ServeGraphQLWS))
+	defer ts.Close()
+
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: time.Second,
+		Subprotocols:     []string{graphqltransportws.WebSocketSubprotocol},
+	}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), http.Header{
+		"Accept": []string{"application/cbor"},
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(map[string]string{
+		"type": "connection_init",
+	}))
+
+	messageType, p, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, messageType)
+	expected := append([]byte{0xbf, 0x64, 't', 'y', 'p', 'e', 0x6e}, "connection_ack"...)
+	expected = append(expected, 0x67, 'p', 'a', 'y', 'l', 'o', 'a', 'd', 0xbf, 0x61, 'n', 0x01, 0xff, 0xff)
+	assert.Equal(t, expected, p)
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"id":   "1",
+		"type": "subscribe",
+		"payload": map[string]interface{}{
+			"query": "{foo}",
+		},
+	}))
+
+	messageType, p, err = conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.BinaryMessage, messageType)
+	expected = []byte{0xbf, 0x62, 'i', 'd', 0x61, '1', 0x64, 't', 'y', 'p', 'e', 0x64, 'n', 'e', 'x', 't', 0x67, 'p', 'a', 'y', 'l', 'o', 'a', 'd'}
+	expected = append(expected, 0xbf, 0x64, 'd', 'a', 't', 'a', 0xbf, 0x63, 'f', 'o', 'o', 0x01, 0xff, 0xff, 0xff)
+	assert.Equal(t, expected, p)
+}
+
+func TestGraphQLWS_AckPayloadAndKeepAlive(t *testing.T) {
+	stopped := make(chan struct{}, 1)
+
+	dial := func(t *testing.T, keepAliveInterval time.Duration) *websocket.Conn {
+		var testCfg Config
+		testCfg.GraphQLWSKeepAliveInterval = keepAliveInterval
+		testCfg.AddSubscription("never", &graphql.FieldDefinition{
+			Type: graphql.IntType,
+			Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+				return &SubscriptionSourceStream{
+					EventChannel: make(chan int),
+					Stop: func() {
+						stopped <- struct{}{}
+					},
+				}, nil
+			},
+		})
+		testCfg.HandleGraphQLWSInit = func(ctx context.Context, parameters json.RawMessage) (context.Context, error) {
+			return WithGraphQLWSAckPayload(ctx, map[string]string{
+				"sessionId": "abc",
+			})
+		}
+		api, err := NewAPI(&testCfg)
+		require.NoError(t, err)
+		t.Cleanup(func() { api.CloseHijackedConnections() })
+
+		ts := httptest.NewServer(http.HandlerFunc(api.ServeGraphQLWS))
+		t.Cleanup(ts.Close)
+
+		dialer := &websocket.Dialer{
+			HandshakeTimeout: time.Second,
+			Subprotocols:     []string{graphqlws.WebSocketSubprotocol},
+		}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		require.NoError(t, conn.WriteJSON(map[string]string{
+			"type": "connection_init",
+		}))
+
+		var msg graphqlws.Message
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, graphqlws.MessageTypeConnectionAck, msg.Type)
+		assert.JSONEq(t, `{"sessionId":"abc"}`, string(msg.Payload))
+		return conn
+	}
+
+	t.Run("KeepAlive", func(t *testing.T) {
+		conn := dial(t, 10*time.Millisecond)
+
+		// The initial keep-alive is followed by more at the configured interval.
+		for i := 0; i < 3; i++ {
+			var msg graphqlws.Message
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+			require.NoError(t, conn.ReadJSON(&msg))
+			assert.Equal(t, graphqlws.MessageTypeConnectionKeepAlive, msg.Type)
+		}
+	})
+
+	t.Run("KeepAliveDisabled", func(t *testing.T) {
+		conn := dial(t, -1)
+
+		var msg graphqlws.Message
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(100*time.Millisecond)))
+		err := conn.ReadJSON(&msg)
+		require.Error(t, err)
+		netErr, ok := err.(interface{ Timeout() bool })
+		assert.True(t, ok && netErr.Timeout())
+	})
+
+	t.Run("Terminate", func(t *testing.T) {
+		conn := dial(t, -1)
+
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"id":   "sub",
+			"type": "start",
+			"payload": map[string]interface{}{
+				"query": `subscription { never }`,
+			},
+		}))
+		require.NoError(t, conn.WriteJSON(map[string]string{
+			"type": "connection_terminate",
+		}))
+
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the subscription was not stopped")
+		}
+
+		// Anything queued by the subscription is flushed, then the connection is closed.
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure))
+				break
+			}
+		}
+	})
+}
+
+func TestGraphQLWS_PayloadLimits(t *testing.T) {
+	dial := func(t *testing.T, cfg func(*Config), initPayload interface{}) *websocket.Conn {
+		var testCfg Config
+		testCfg.GraphQLWSKeepAliveInterval = -1
+		testCfg.AddQueryField("long", &graphql.FieldDefinition{
+			Type: graphql.StringType,
+			Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+				return strings.Repeat("x", 1000), nil
+			},
+		})
+		cfg(&testCfg)
+		api, err := NewAPI(&testCfg)
+		require.NoError(t, err)
+		t.Cleanup(func() { api.CloseHijackedConnections() })
+
+		ts := httptest.NewServer(http.HandlerFunc(api.ServeGraphQLWS))
+		t.Cleanup(ts.Close)
+
+		dialer := &websocket.Dialer{
+			HandshakeTimeout: time.Second,
+			Subprotocols:     []string{graphqltransportws.WebSocketSubprotocol},
+		}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"type":    "connection_init",
+			"payload": initPayload,
+		}))
+		var msg graphqltransportws.Message
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, graphqltransportws.MessageTypeConnectionAck, msg.Type)
+
+		require.NoError(t, conn.WriteJSON(map[string]interface{}{
+			"id":   "q",
+			"type": "subscribe",
+			"payload": map[string]interface{}{
+				"query": `{ long }`,
+			},
+		}))
+		return conn
+	}
+
+	t.Run("Chunked", func(t *testing.T) {
+		conn := dial(t, func(cfg *Config) {
+			cfg.GraphQLWSPayloadChunkSize = 300
+		}, map[string]interface{}{
+			transport.PayloadChunkingInitParameter: true,
+		})
+
+		var payload []byte
+		for {
+			var msg graphqltransportws.Message
+			require.NoError(t, conn.ReadJSON(&msg))
+			require.Equal(t, graphqltransportws.MessageTypeChunk, msg.Type)
+			assert.Equal(t, "q", msg.Id)
+			var chunk transport.PayloadChunk
+			require.NoError(t, json.Unmarshal(msg.Payload, &chunk))
+			assert.LessOrEqual(t, len(chunk.Data), 300)
+			payload = append(payload, chunk.Data...)
+			if chunk.Index == chunk.Count-1 {
+				assert.Equal(t, 4, chunk.Count)
+				break
+			}
+		}
+		assert.JSONEq(t, `{"data":{"long":"`+strings.Repeat("x", 1000)+`"}}`, string(payload))
+
+		var msg graphqltransportws.Message
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, graphqltransportws.MessageTypeComplete, msg.Type)
+	})
+
+	t.Run("ChunkingNotAccepted", func(t *testing.T) {
+		conn := dial(t, func(cfg *Config) {
+			cfg.GraphQLWSPayloadChunkSize = 300
+		}, nil)
+
+		var msg graphqltransportws.Message
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, graphqltransportws.MessageTypeNext, msg.Type)
+		assert.JSONEq(t, `{"data":{"long":"`+strings.Repeat("x", 1000)+`"}}`, string(msg.Payload))
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		conn := dial(t, func(cfg *Config) {
+			cfg.GraphQLWSMaxPayloadSize = 500
+		}, nil)
+
+		var msg graphqltransportws.Message
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, graphqltransportws.MessageTypeNext, msg.Type)
+		var resp graphql.Response
+		require.NoError(t, json.Unmarshal(msg.Payload, &resp))
+		assert.Nil(t, resp.Data)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, string(transport.PayloadTooLargeErrorCode), resp.Errors[0].Extensions["code"])
+		assert.EqualValues(t, 500, resp.Errors[0].Extensions["limit"])
+	})
+}
+
+// A minimal protocol in which clients send operations and the server responds with their results.
+type testWebSocketConnection struct {
+	handler transport.ConnectionHandler
+
+	mutex sync.Mutex
+	conn  *websocket.Conn
+}
+
+type testWebSocketMessage struct {
+	Id       string      `json:"id"`
+	Query    string      `json:"query,omitempty"`
+	Response interface{} `json:"response,omitempty"`
+	Complete bool        `json:"complete,omitempty"`
+}
+
+func (c *testWebSocketConnection) SendData(ctx context.Context, id string, response *graphql.Response) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.conn.WriteJSON(&testWebSocketMessage{Id: id, Response: response})
+}
+
+func (c *testWebSocketConnection) SendComplete(ctx context.Context, id string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.conn.WriteJSON(&testWebSocketMessage{Id: id, Complete: true})
+}
+
+func (c *testWebSocketConnection) Serve(conn *websocket.Conn) {
+	c.conn = conn
+	go func() {
+		defer c.handler.HandleClose()
+		defer c.handler.Cancel()
+		if err := c.handler.HandleInit(nil); err != nil {
+			return
+		}
+		for {
+			var msg testWebSocketMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			c.handler.HandleStart(msg.Id, msg.Query, nil, "")
+		}
+	}()
+}
+
+func (c *testWebSocketConnection) Close() error {
+	return c.conn.Close()
+}
+
+func TestRegisterWebSocketSubprotocol(t *testing.T) {
+	var testCfg Config
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+	defer api.CloseHijackedConnections()
+
+	api.RegisterWebSocketSubprotocol("test", func(handler transport.ConnectionHandler) transport.Connection {
+		return &testWebSocketConnection{
+			handler: handler,
+		}
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(api.ServeGraphQLWS))
+	defer ts.Close()
+
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: time.Second,
+		Subprotocols:     []string{graphqlws.WebSocketSubprotocol, "test"},
+	}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "test", conn.Subprotocol())
+
+	require.NoError(t, conn.WriteJSON(&testWebSocketMessage{
+		Id:    "1",
+		Query: `{foo}`,
+	}))
+
+	var msg testWebSocketMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "1", msg.Id)
+	assert.Equal(t, map[string]interface{}{
+		"data": map[string]interface{}{"foo": float64(1)},
+	}, msg.Response)
+
+	var complete testWebSocketMessage
+	require.NoError(t, conn.ReadJSON(&complete))
+	assert.Equal(t, testWebSocketMessage{Id: "1", Complete: true}, complete)
+}
@@ -0,0 +1,175 @@
+package apifu
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// HandlerOptions configures the handlers returned by GraphQLHandler and GraphQLWSHandler. They make
+// it easy to mount the API in routers such as chi, echo, or gin with different configurations for
+// different routes.
+type HandlerOptions struct {
+	// If non-zero, requests with bodies larger than this many bytes are rejected with 413 Request
+	// Entity Too Large.
+	MaxBodySize int64
+
+	// The HTTP methods that are allowed. Requests with other methods are rejected with 405 Method
+	// Not Allowed. If empty, GET and POST are allowed.
+	//
+	// This only restricts methods, not operation types. Unless Config.StrictGraphQLOverHTTP is
+	// true, GET requests can execute mutations and subscriptions just like POST requests. Set it,
+	// or set ReadOnly and DisallowSubscriptions, if GET requests must not have side effects.
+	AllowedMethods []string
+
+	// If given, this is invoked for every request before anything else, including preflight
+	// requests. It typically sets Access-Control-* headers. If it returns false, the request is
+	// not handled further and the hook is responsible for writing the response.
+	//
+	// When CORS is given, OPTIONS requests are answered with 204 No Content after it's invoked.
+	CORS func(w http.ResponseWriter, r *http.Request) bool
+
+	// If true, mutations are rejected during validation with an error whose extensions have a
+	// "code" of "OPERATION_TYPE_NOT_ALLOWED". This is useful for endpoints that must never write,
+	// such as public endpoints backed by read replicas.
+	ReadOnly bool
+
+	// If true, subscriptions are rejected in the same way that mutations are by ReadOnly.
+	DisallowSubscriptions bool
+}
+
+func (opts *HandlerOptions) allowedMethods() []string {
+	if opts == nil || len(opts.AllowedMethods) == 0 {
+		return []string{http.MethodGet, http.MethodPost}
+	}
+	return opts.AllowedMethods
+}
+
+func (opts *HandlerOptions) disallowedOperationTypes() []string {
+	var ret []string
+	if opts != nil && opts.ReadOnly {
+		ret = append(ret, "mutation")
+	}
+	if opts != nil && opts.DisallowSubscriptions {
+		ret = append(ret, "subscription")
+	}
+	return ret
+}
+
+type disallowedOperationTypesContextKeyType int
+
+var disallowedOperationTypesContextKey disallowedOperationTypesContextKeyType
+
+// Returns the operation types that the handler serving the request doesn't allow.
+func disallowedOperationTypes(ctx context.Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	disallowed, _ := ctx.Value(disallowedOperationTypesContextKey).([]string)
+	return disallowed
+}
+
+type apiHandler struct {
+	options *HandlerOptions
+	serve   func(w http.ResponseWriter, r *http.Request)
+}
+
+// GraphQLHandler returns an http.Handler that serves GraphQL HTTP requests via ServeGraphQL. If
+// opts is nil, the default options are used: only GET and POST requests are allowed, and bodies
+// aren't limited.
+func (api *API) GraphQLHandler(opts *HandlerOptions) http.Handler {
+	return &apiHandler{
+		options: opts,
+		serve:   api.ServeGraphQL,
+	}
+}
+
+// GraphQLWSHandler returns an http.Handler that serves GraphQL WebSocket connections via
+// ServeGraphQLWS. Only GET requests can be upgraded, so AllowedMethods and MaxBodySize are ignored.
+func (api *API) GraphQLWSHandler(opts *HandlerOptions) http.Handler {
+	wsOpts := &HandlerOptions{
+		AllowedMethods: []string{http.MethodGet},
+	}
+	if opts != nil {
+		wsOpts.CORS = opts.CORS
+		wsOpts.ReadOnly = opts.ReadOnly
+		wsOpts.DisallowSubscriptions = opts.DisallowSubscriptions
+	}
+	return &apiHandler{
+		options: wsOpts,
+		serve:   api.ServeGraphQLWS,
+	}
+}
+
+func (h *apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var cors func(w http.ResponseWriter, r *http.Request) bool
+	var maxBodySize int64
+	if h.options != nil {
+		cors = h.options.CORS
+		maxBodySize = h.options.MaxBodySize
+	}
+
+	if cors != nil {
+		if !cors(w, r) {
+			return
+		} else if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	allowed := h.options.allowedMethods()
+	if !containsString(allowed, r.Method) {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if maxBodySize > 0 && r.Body != nil {
+		// Read the body up front so that oversized bodies are reported as such rather than as
+		// malformed or truncated requests.
+		body, ok := readLimitedRequestBody(w, r, maxBodySize)
+		if !ok {
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if disallowed := h.options.disallowedOperationTypes(); len(disallowed) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), disallowedOperationTypesContextKey, disallowed))
+	}
+
+	h.serve(w, r)
+}
+
+// Reads the request's body via http.MaxBytesReader. If the body is larger than maxBodySize bytes,
+// it responds with 413 Request Entity Too Large. If the body can't be read for any other reason, it
+// responds with 400 Bad Request. In either case, false is returned.
+func readLimitedRequestBody(w http.ResponseWriter, r *http.Request, maxBodySize int64) ([]byte, bool) {
+	if r.ContentLength > maxBodySize {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		// http.MaxBytesReader yields exactly maxBodySize bytes before failing on larger bodies.
+		if int64(len(body)) == maxBodySize {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "unable to read request body", http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	return body, true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
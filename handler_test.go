@@ -0,0 +1,149 @@
+package apifu
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestGraphQLHandler(t *testing.T) {
+	var testCfg Config
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "bar", nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	handler := api.GraphQLHandler(&HandlerOptions{
+		MaxBodySize:    100,
+		AllowedMethods: []string{http.MethodPost},
+		CORS: func(w http.ResponseWriter, r *http.Request) bool {
+			if r.Header.Get("Origin") == "https://evil.example.com" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return false
+			}
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			return true
+		},
+	})
+
+	for name, tc := range map[string]struct {
+		Method         string
+		Origin         string
+		Body           string
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		"OK": {
+			Method:         http.MethodPost,
+			Body:           `{foo}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   `{"data":{"foo":"bar"}}`,
+		},
+		"MethodNotAllowed": {
+			Method:         http.MethodGet,
+			ExpectedStatus: http.StatusMethodNotAllowed,
+		},
+		"TooLarge": {
+			Method:         http.MethodPost,
+			Body:           `{foo}` + strings.Repeat(" ", 100),
+			ExpectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		"Preflight": {
+			Method:         http.MethodOptions,
+			ExpectedStatus: http.StatusNoContent,
+		},
+		"Forbidden": {
+			Method:         http.MethodPost,
+			Origin:         "https://evil.example.com",
+			Body:           `{foo}`,
+			ExpectedStatus: http.StatusForbidden,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.Method, "/", strings.NewReader(tc.Body))
+			r.Header.Set("Content-Type", "application/graphql")
+			if tc.Origin != "" {
+				r.Header.Set("Origin", tc.Origin)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			resp := w.Result()
+			assert.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+			if tc.ExpectedStatus == http.StatusMethodNotAllowed {
+				assert.Equal(t, "POST", resp.Header.Get("Allow"))
+			}
+			if tc.ExpectedBody != "" {
+				body, err := ioutil.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, tc.ExpectedBody, string(body))
+			}
+		})
+	}
+
+	t.Run("NilOptions", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/?query={foo}", nil)
+		w := httptest.NewRecorder()
+		api.GraphQLHandler(nil).ServeHTTP(w, r)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data":{"foo":"bar"}}`, w.Body.String())
+	})
+}
+
+func TestGraphQLHandler_ReadOnly(t *testing.T) {
+	var testCfg Config
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "bar", nil
+		},
+	})
+	testCfg.AddMutation("write", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			panic("mutations shouldn't be executed")
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	handler := api.GraphQLHandler(&HandlerOptions{
+		ReadOnly: true,
+	})
+
+	for name, tc := range map[string]struct {
+		Body         string
+		ExpectedBody string
+	}{
+		"Query": {
+			Body:         `{foo}`,
+			ExpectedBody: `{"data":{"foo":"bar"}}`,
+		},
+		"Mutation": {
+			Body:         `mutation {write}`,
+			ExpectedBody: `{"errors":[{"message":"Validation error: mutation operations are not allowed by this endpoint","locations":[{"line":1,"column":1}],"extensions":{"code":"OPERATION_TYPE_NOT_ALLOWED","retryable":false}}]}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.Body))
+			r.Header.Set("Content-Type", "application/graphql")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			body, err := ioutil.ReadAll(w.Result().Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.ExpectedBody, string(body))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package apifu
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+type internalRequestContextKeyType int
+
+var internalRequestContextKey internalRequestContextKeyType
+
+// Returns true if the context belongs to a request served by one of the internal handlers.
+func isInternalRequest(ctx context.Context) bool {
+	internal, _ := ctx.Value(internalRequestContextKey).(bool)
+	return internal
+}
+
+// Returns the feature set for a request. If Config.InternalFeature is set, it's added to the
+// feature sets of internal requests and removed from the feature sets of all other requests.
+func (api *API) requestFeatures(ctx context.Context) graphql.FeatureSet {
+	var features graphql.FeatureSet
+	if api.config.Features != nil {
+		features = api.config.Features(ctx)
+	}
+
+	internalFeature := api.config.InternalFeature
+	if internalFeature == "" {
+		return features
+	}
+	internal := isInternalRequest(ctx)
+	if internal == features.Has(internalFeature) {
+		return features
+	}
+	ret := make(graphql.FeatureSet, len(features)+1)
+	for feature := range features {
+		ret[feature] = struct{}{}
+	}
+	if internal {
+		ret[internalFeature] = struct{}{}
+	} else {
+		delete(ret, internalFeature)
+	}
+	return ret
+}
+
+// Wraps the handler so that its requests are internal.
+func internalHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), internalRequestContextKey, true)))
+	})
+}
+
+// InternalGraphQLHandler is like GraphQLHandler, but serves the internal view of the schema. See
+// Config.InternalFeature.
+//
+// The handler doesn't perform any authentication or authorization of its own, so it should only be
+// reachable by trusted clients.
+func (api *API) InternalGraphQLHandler(opts *HandlerOptions) http.Handler {
+	return internalHandler(api.GraphQLHandler(opts))
+}
+
+// InternalGraphQLWSHandler is like GraphQLWSHandler, but serves the internal view of the schema.
+// See Config.InternalFeature.
+//
+// The handler doesn't perform any authentication or authorization of its own, so it should only be
+// reachable by trusted clients.
+func (api *API) InternalGraphQLWSHandler(opts *HandlerOptions) http.Handler {
+	return internalHandler(api.GraphQLWSHandler(opts))
+}
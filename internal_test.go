@@ -0,0 +1,67 @@
+package apifu
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestInternalFeature(t *testing.T) {
+	testCfg := Config{
+		InternalFeature: "internal",
+		Features: func(ctx context.Context) graphql.FeatureSet {
+			// Clients must not be able to opt in to the internal view.
+			return graphql.NewFeatureSet("internal", "other")
+		},
+	}
+	testCfg.AddQueryField("public", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "public", nil
+		},
+	})
+	testCfg.AddQueryField("secret", &graphql.FieldDefinition{
+		Type:             graphql.StringType,
+		RequiredFeatures: graphql.NewFeatureSet(testCfg.InternalFeature),
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			assert.True(t, ctx.Features.Has("other"))
+			return "secret", nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	execute := func(handler http.Handler, query string) string {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/", strings.NewReader(query))
+		r.Header.Set("Content-Type", "application/graphql")
+		handler.ServeHTTP(w, r)
+		body, err := ioutil.ReadAll(w.Result().Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	query := `{public secret}`
+	introspectionQuery := `{__type(name: "Query") {fields {name}}}`
+
+	t.Run("Public", func(t *testing.T) {
+		handler := api.GraphQLHandler(nil)
+		assert.JSONEq(t, `{"errors":[{"message":"Validation error: field secret does not exist on Query","locations":[{"line":1,"column":9}]}]}`, execute(handler, query))
+		assert.NotContains(t, execute(handler, introspectionQuery), `"secret"`)
+	})
+
+	t.Run("Internal", func(t *testing.T) {
+		handler := api.InternalGraphQLHandler(nil)
+		assert.JSONEq(t, `{"data":{"public":"public","secret":"secret"}}`, execute(handler, query))
+		assert.Contains(t, execute(handler, introspectionQuery), `{"name":"secret"}`)
+	})
+}
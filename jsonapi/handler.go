@@ -2,6 +2,7 @@ package jsonapi
 
 import (
 	"context"
+	"fmt"
 	"mime"
 	"net/http"
 	"strconv"
@@ -18,13 +19,33 @@ func (api API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Version: "1.1",
 	}
 
-	w.Header().Set("Content-Type", "application/vnd.api+json")
+	if api.Meta != nil {
+		if meta := api.Meta(r); len(meta) > 0 {
+			if resp.Document.Meta == nil {
+				resp.Document.Meta = make(map[string]any, len(meta))
+			}
+			for k, v := range meta {
+				resp.Document.Meta[k] = v
+			}
+		}
+	}
 
 	status := http.StatusOK
 	if resp.Status != 0 {
 		status = resp.Status
 	}
 
+	if status == http.StatusNotModified || status == http.StatusNoContent {
+		// These responses cannot contain a body.
+		for k, v := range resp.Headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+
 	if len(resp.Document.Errors) > 0 {
 		status = http.StatusInternalServerError
 		for _, err := range resp.Document.Errors {
@@ -62,7 +83,8 @@ func errorForHTTPStatus(status int) types.Error {
 
 func (api API) getResource(ctx context.Context, id types.ResourceId) (*types.Resource, *types.Error) {
 	if resourceType, ok := api.Schema.resourceTypes[id.Type]; ok {
-		return resourceType.get(ctx, id)
+		resource, _, err := resourceType.get(ctx, id)
+		return resource, err
 	}
 	return nil, nil
 }
@@ -71,7 +93,7 @@ func (api API) getResources(ctx context.Context, ids []types.ResourceId) ([]type
 	var ret []types.Resource
 	for _, id := range ids {
 		if resourceType, ok := api.Schema.resourceTypes[id.Type]; ok {
-			if resource, err := resourceType.get(ctx, id); err != nil {
+			if resource, _, err := resourceType.get(ctx, id); err != nil {
 				return nil, err
 			} else if resource != nil {
 				ret = append(ret, *resource)
@@ -81,11 +103,85 @@ func (api API) getResources(ctx context.Context, ids []types.ResourceId) ([]type
 	return ret, nil
 }
 
-func (api API) handlePatchResourceRequest(ctx context.Context, r *http.Request, resourceType AnyResourceType, resourceId types.ResourceId) *types.ResponseDocument {
+// Returns the resources to include in a response with the given primary data, as requested via
+// the include query parameter. Afterwards, any relationships that were only resolved in order to
+// find the included resources are removed from the primary and included resources.
+func (api API) includedResources(ctx context.Context, primary []*types.Resource) ([]types.Resource, *types.Error) {
+	rc := GetRequestContext(ctx)
+	if rc == nil || len(rc.Include) == 0 {
+		return nil, nil
+	}
+
+	resources := make(map[types.ResourceId]*types.Resource, len(primary))
+	for _, resource := range primary {
+		resources[types.ResourceId{Type: resource.Type, Id: resource.Id}] = resource
+	}
+
+	var included []*types.Resource
+	for _, path := range rc.Include {
+		current := primary
+		for _, name := range path {
+			var next []*types.Resource
+			for _, resource := range current {
+				rel, ok := resource.Relationships[name].(types.Relationship)
+				if !ok {
+					return nil, parameterError("include", fmt.Sprintf("%v is not a valid relationship path.", strings.Join(path, ".")))
+				}
+				var ids []types.ResourceId
+				if rel.Data != nil {
+					switch data := (*rel.Data).(type) {
+					case types.ResourceId:
+						ids = []types.ResourceId{data}
+					case []types.ResourceId:
+						ids = data
+					}
+				}
+				for _, id := range ids {
+					related, ok := resources[id]
+					if !ok {
+						var err *types.Error
+						if related, err = api.getResource(ctx, id); err != nil {
+							return nil, err
+						}
+						resources[id] = related
+						if related != nil {
+							included = append(included, related)
+						}
+					}
+					if related != nil {
+						next = append(next, related)
+					}
+				}
+			}
+			current = next
+		}
+	}
+
+	for _, resource := range resources {
+		if resource == nil {
+			continue
+		}
+		for name := range resource.Relationships {
+			if !rc.FieldRequested(resource.Type, name) {
+				delete(resource.Relationships, name)
+			}
+		}
+	}
+
+	ret := make([]types.Resource, len(included))
+	for i, resource := range included {
+		ret[i] = *resource
+	}
+	return ret, nil
+}
+
+func (api API) handlePatchResourceRequest(ctx context.Context, r *http.Request, resourceType AnyResourceType, resourceId types.ResourceId) *response {
 	var patch types.PatchResourceRequest
 	if err := jsoniter.NewDecoder(r.Body).Decode(&patch); err != nil {
-		return &types.ResponseDocument{
-			Errors: []types.Error{errorForHTTPStatus(http.StatusBadRequest)},
+		return &response{
+			Document: types.ResponseDocument{
+				Errors: []types.Error{errorForHTTPStatus(http.StatusBadRequest)},
+			},
 		}
 	}
 
@@ -93,33 +189,121 @@ func (api API) handlePatchResourceRequest(ctx context.Context, r *http.Request,
 		// A server MUST return 409 Conflict when processing a PATCH request in
 		// which the resource object’s type or id do not match the server’s
 		// endpoint.
-		return &types.ResponseDocument{
-			Errors: []types.Error{errorForHTTPStatus(http.StatusConflict)},
+		return &response{
+			Document: types.ResponseDocument{
+				Errors: []types.Error{errorForHTTPStatus(http.StatusConflict)},
+			},
 		}
 	}
 
+	if resp := checkIfMatch(ctx, r, resourceType, resourceId); resp != nil {
+		return resp
+	}
+
 	relationships := make(map[string]any, len(patch.Data.Relationships))
 	for k, v := range patch.Data.Relationships {
 		relationships[k] = v.Data
 	}
 
-	if resource, err := resourceType.patch(ctx, resourceId, patch.Data.Attributes, relationships); err != nil {
-		return &types.ResponseDocument{
-			Errors: []types.Error{*err},
+	if resource, etag, err := resourceType.patch(ctx, resourceId, patch.Data.Attributes, relationships); err != nil {
+		return &response{
+			Document: types.ResponseDocument{
+				Errors: []types.Error{*err},
+			},
 		}
 	} else if resource != nil {
 		var data any = resource
-		return &types.ResponseDocument{
-			Data: &data,
-			Links: types.Links{
-				"self": r.URL.Path,
+		return &response{
+			Document: types.ResponseDocument{
+				Data: &data,
+				Links: types.Links{
+					"self": r.URL.Path,
+				},
 			},
+			Headers: etagHeaders(etag),
 		}
 	}
 
 	return nil
 }
 
+func etagHeaders(etag string) map[string]string {
+	if etag == "" {
+		return nil
+	}
+	return map[string]string{
+		"ETag": `"` + etag + `"`,
+	}
+}
+
+// Parses an If-Match or If-None-Match header and invokes f for each entity tag. The tags are
+// unquoted and weakness indicators are stripped. If the header is "*", f is invoked with "*".
+func forEachETag(header string, f func(etag string, weak bool) bool) {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			if !f(tag, false) {
+				return
+			}
+			continue
+		}
+		weak := strings.HasPrefix(tag, "W/")
+		tag = strings.TrimPrefix(tag, "W/")
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		if !f(tag[1:len(tag)-1], weak) {
+			return
+		}
+	}
+}
+
+// Returns true if the If-None-Match header matches the given entity tag, using weak comparison.
+func ifNoneMatchMatches(header string, etag string) bool {
+	matches := false
+	forEachETag(header, func(tag string, weak bool) bool {
+		matches = tag == "*" || tag == etag
+		return !matches
+	})
+	return matches
+}
+
+// Returns true if the If-Match header matches the given entity tag, using strong comparison.
+func ifMatchMatches(header string, etag string) bool {
+	matches := false
+	forEachETag(header, func(tag string, weak bool) bool {
+		matches = tag == "*" || (!weak && etag != "" && tag == etag)
+		return !matches
+	})
+	return matches
+}
+
+// If the request has an If-Match header, this verifies that it matches the resource's current
+// entity tag. If it doesn't, a response is returned which should be sent instead of processing the
+// request.
+func checkIfMatch(ctx context.Context, r *http.Request, resourceType AnyResourceType, resourceId types.ResourceId) *response {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil
+	}
+
+	etag, exists, err := resourceType.currentETag(ctx, resourceId)
+	if err != nil {
+		return &response{
+			Document: types.ResponseDocument{
+				Errors: []types.Error{*err},
+			},
+		}
+	} else if !exists || !ifMatchMatches(header, etag) {
+		return &response{
+			Document: types.ResponseDocument{
+				Errors: []types.Error{errorForHTTPStatus(http.StatusPreconditionFailed)},
+			},
+		}
+	}
+	return nil
+}
+
 type response struct {
 	Document types.ResponseDocument
 	Headers  map[string]string
@@ -201,7 +385,7 @@ func (api API) executeRequest(r *http.Request) *response {
 			// This is not an implementation-specific parameter, and if it's not one we support, we
 			// must return a 400 error.
 			switch familyName {
-			case "page":
+			case "page", "include", "fields":
 			default:
 				return &response{
 					Document: types.ResponseDocument{
@@ -212,10 +396,59 @@ func (api API) executeRequest(r *http.Request) *response {
 		}
 	}
 
+	rc, err := parseRequestContext(q)
+	if err != nil {
+		return &response{
+			Document: types.ResponseDocument{
+				Errors: []types.Error{*err},
+			},
+		}
+	} else if len(rc.Include) > 0 && (r.Method != "GET" || (len(pathComponents) == 4 && pathComponents[2] == "relationships")) {
+		// If a server is unable to identify a relationship path or does not support inclusion of
+		// resources from a path, it MUST respond with 400 Bad Request.
+		return &response{
+			Document: types.ResponseDocument{
+				Errors: []types.Error{*parameterError("include", "Inclusion of related resources is not supported for this request.")},
+			},
+		}
+	}
+	ctx = withRequestContext(ctx, rc)
+
 	if len(pathComponents) >= 1 {
 		typeName := pathComponents[0]
 		if resourceType, ok := api.Schema.resourceTypes[typeName]; ok {
-			if len(pathComponents) == 1 && r.Method == "POST" {
+			if len(pathComponents) == 1 && r.Method == "GET" {
+				// collection request
+				if resources, links, err := resourceType.list(ctx, typeName, q); err != nil {
+					return &response{
+						Document: types.ResponseDocument{
+							Errors: []types.Error{*err},
+						},
+					}
+				} else {
+					primary := make([]*types.Resource, len(resources))
+					for i := range resources {
+						primary[i] = &resources[i]
+					}
+					included, err := api.includedResources(ctx, primary)
+					if err != nil {
+						return &response{
+							Document: types.ResponseDocument{
+								Errors: []types.Error{*err},
+							},
+						}
+					}
+					links["self"] = r.URL.RequestURI()
+					var data any = resources
+					return &response{
+						Document: types.ResponseDocument{
+							Data:     &data,
+							Links:    links,
+							Included: included,
+						},
+					}
+				}
+			} else if len(pathComponents) == 1 && r.Method == "POST" {
 				// new resource request
 				var patch types.PostResourceRequest
 				if err := jsoniter.NewDecoder(r.Body).Decode(&patch); err != nil {
@@ -235,13 +468,38 @@ func (api API) executeRequest(r *http.Request) *response {
 					for k, v := range patch.Data.Relationships {
 						relationships[k] = v.Data
 					}
-					if resource, err := resourceType.create(ctx, patch.Data.Attributes, relationships); err != nil {
+					if result, err := resourceType.create(ctx, patch.Data.Id, patch.Data.Attributes, relationships); err != nil {
 						return &response{
 							Document: types.ResponseDocument{
 								Errors: []types.Error{*err},
 							},
 						}
-					} else if resource != nil {
+					} else if result != nil && result.StatusURL != "" {
+						return &response{
+							Document: types.ResponseDocument{
+								Links: types.Links{
+									"status": result.StatusURL,
+								},
+							},
+							Headers: map[string]string{
+								"Content-Location": result.StatusURL,
+							},
+							Status: http.StatusAccepted,
+						}
+					} else if result != nil && result.Resource != nil {
+						resource := result.Resource
+						headers := map[string]string{
+							"Location": "/" + resource.Type + "/" + resource.Id,
+						}
+						for k, v := range etagHeaders(result.ETag) {
+							headers[k] = v
+						}
+						if result.NoContent {
+							return &response{
+								Headers: headers,
+								Status:  http.StatusNoContent,
+							}
+						}
 						var data any = resource
 						return &response{
 							Document: types.ResponseDocument{
@@ -250,10 +508,8 @@ func (api API) executeRequest(r *http.Request) *response {
 									"self": "/" + resource.Type + "/" + resource.Id,
 								},
 							},
-							Headers: map[string]string{
-								"Location": "/" + resource.Type + "/" + resource.Id,
-							},
-							Status: http.StatusCreated,
+							Headers: headers,
+							Status:  http.StatusCreated,
 						}
 					}
 				}
@@ -267,13 +523,27 @@ func (api API) executeRequest(r *http.Request) *response {
 					// resource request
 					switch r.Method {
 					case "GET":
-						if resource, err := resourceType.get(ctx, resourceId); err != nil {
+						if resource, etag, err := resourceType.get(ctx, resourceId); err != nil {
 							return &response{
 								Document: types.ResponseDocument{
 									Errors: []types.Error{*err},
 								},
 							}
 						} else if resource != nil {
+							if etag != "" && ifNoneMatchMatches(r.Header.Get("If-None-Match"), etag) {
+								return &response{
+									Headers: etagHeaders(etag),
+									Status:  http.StatusNotModified,
+								}
+							}
+							included, err := api.includedResources(ctx, []*types.Resource{resource})
+							if err != nil {
+								return &response{
+									Document: types.ResponseDocument{
+										Errors: []types.Error{*err},
+									},
+								}
+							}
 							var data any = resource
 							return &response{
 								Document: types.ResponseDocument{
@@ -281,15 +551,19 @@ func (api API) executeRequest(r *http.Request) *response {
 									Links: types.Links{
 										"self": r.URL.Path,
 									},
+									Included: included,
 								},
+								Headers: etagHeaders(etag),
 							}
 						}
 					case "PATCH":
-						if doc := api.handlePatchResourceRequest(ctx, r, resourceType, resourceId); doc != nil {
-							return &response{
-								Document: *doc}
+						if resp := api.handlePatchResourceRequest(ctx, r, resourceType, resourceId); resp != nil {
+							return resp
 						}
 					case "DELETE":
+						if resp := checkIfMatch(ctx, r, resourceType, resourceId); resp != nil {
+							return resp
+						}
 						if err := resourceType.delete(ctx, resourceId); err != nil {
 							return &response{
 								Document: types.ResponseDocument{
@@ -318,12 +592,26 @@ func (api API) executeRequest(r *http.Request) *response {
 								}}
 						} else if relationship != nil {
 							var data any = nil
+							var primary []*types.Resource
 							var err *types.Error
 							switch ids := (*relationship.Data).(type) {
 							case types.ResourceId:
-								data, err = api.getResource(ctx, ids)
+								var resource *types.Resource
+								if resource, err = api.getResource(ctx, ids); resource != nil {
+									data = resource
+									primary = []*types.Resource{resource}
+								}
 							case []types.ResourceId:
-								data, err = api.getResources(ctx, ids)
+								var resources []types.Resource
+								resources, err = api.getResources(ctx, ids)
+								data = resources
+								for i := range resources {
+									primary = append(primary, &resources[i])
+								}
+							}
+							var included []types.Resource
+							if err == nil {
+								included, err = api.includedResources(ctx, primary)
 							}
 							if err != nil {
 								return &response{
@@ -337,6 +625,7 @@ func (api API) executeRequest(r *http.Request) *response {
 									Links: types.Links{
 										"self": r.URL.Path,
 									},
+									Included: included,
 								}}
 						}
 					case "PATCH":
@@ -349,9 +638,8 @@ func (api API) executeRequest(r *http.Request) *response {
 						} else if relationship != nil {
 							if relatedId, ok := (*relationship.Data).(types.ResourceId); ok {
 								if relatedResourceType, ok := api.Schema.resourceTypes[relatedId.Type]; ok {
-									if doc := api.handlePatchResourceRequest(ctx, r, relatedResourceType, relatedId); doc != nil {
-										return &response{
-											Document: *doc}
+									if resp := api.handlePatchResourceRequest(ctx, r, relatedResourceType, relatedId); resp != nil {
+										return resp
 									}
 								}
 							}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -508,6 +509,11 @@ func TestCreateResource(t *testing.T) {
 			Body:           `{"data": {"type": "people"}}`,
 			ExpectedStatus: http.StatusMethodNotAllowed,
 		},
+		"ClientIdUnsupported": {
+			Path:           "/comments",
+			Body:           `{"data": {"type": "comments", "id": "client-id"}}`,
+			ExpectedStatus: http.StatusForbidden,
+		},
 	} {
 		t.Run(name, func(t *testing.T) {
 			w := httptest.NewRecorder()
@@ -525,6 +531,101 @@ func TestCreateResource(t *testing.T) {
 	}
 }
 
+func TestCreateResource_ClientIds(t *testing.T) {
+	s, err := NewSchema(&SchemaDefinition{
+		ResourceTypes: map[string]AnyResourceType{
+			"people": ResourceType[string]{
+				CreateWithId: func(ctx context.Context, id string, attributes map[string]json.RawMessage, relationships map[string]any) (string, types.ResourceId, *types.Error) {
+					if id == "" {
+						id = "generated-id"
+					}
+					return id, types.ResourceId{Type: "people", Id: id}, nil
+				},
+				AllowClientIds:  true,
+				CreateNoContent: true,
+			},
+			"photos": ResourceType[string]{
+				CreateAsync: func(ctx context.Context, id string, attributes map[string]json.RawMessage, relationships map[string]any) (string, *types.Error) {
+					return "/photo-jobs/1", nil
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Path             string
+		Body             string
+		ExpectedStatus   int
+		ExpectedHeaders  map[string]string
+		ExpectedResponse string
+	}{
+		"NoContent": {
+			Path:           "/people",
+			Body:           `{"data": {"type": "people", "id": "client-id"}}`,
+			ExpectedStatus: http.StatusNoContent,
+			ExpectedHeaders: map[string]string{
+				"Location": "/people/client-id",
+			},
+		},
+		"GeneratedId": {
+			Path:           "/people",
+			Body:           `{"data": {"type": "people"}}`,
+			ExpectedStatus: http.StatusCreated,
+			ExpectedHeaders: map[string]string{
+				"Location": "/people/generated-id",
+			},
+			ExpectedResponse: `{
+			  "links": {
+				"self": "/people/generated-id"
+			  },
+			  "data": {
+				"type": "people",
+				"id": "generated-id"
+			  },
+			  "jsonapi": {
+				"version": "1.1"
+			  }
+			}`,
+		},
+		"Async": {
+			Path:           "/photos",
+			Body:           `{"data": {"type": "photos"}}`,
+			ExpectedStatus: http.StatusAccepted,
+			ExpectedHeaders: map[string]string{
+				"Content-Location": "/photo-jobs/1",
+			},
+			ExpectedResponse: `{
+			  "links": {
+				"status": "/photo-jobs/1"
+			  },
+			  "jsonapi": {
+				"version": "1.1"
+			  }
+			}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest("POST", tc.Path, strings.NewReader(tc.Body))
+			require.NoError(t, err)
+			r.Header.Set("Accept", "application/vnd.api+json")
+			API{Schema: s}.ServeHTTP(w, r)
+			resp := w.Result()
+			assert.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+			for k, v := range tc.ExpectedHeaders {
+				assert.Equal(t, v, resp.Header.Get(k))
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if tc.ExpectedResponse == "" {
+				assert.Empty(t, body)
+			} else {
+				assert.JSONEq(t, tc.ExpectedResponse, string(body))
+			}
+		})
+	}
+}
+
 func TestDeleteResource(t *testing.T) {
 	for name, tc := range map[string]struct {
 		Path           string
@@ -876,3 +977,495 @@ func TestUnsupportedMethod(t *testing.T) {
 		})
 	}
 }
+
+func TestMetaAndLinks(t *testing.T) {
+	s, err := NewSchema(&SchemaDefinition{
+		ResourceTypes: map[string]AnyResourceType{
+			"people": ResourceType[string]{
+				Attributes: map[string]*AttributeDefinition[string]{
+					"name": {
+						Resolver: ConstantString[string]("Dan"),
+					},
+				},
+				Get: func(ctx context.Context, id string) (string, *types.Error) {
+					return id, nil
+				},
+				Meta: func(ctx context.Context, resource string) (map[string]any, *types.Error) {
+					return map[string]any{"canEdit": resource == "1"}, nil
+				},
+				Links: func(ctx context.Context, resource string) (types.Links, *types.Error) {
+					return types.Links{"self": "/people/" + resource}, nil
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	api := API{
+		Schema: s,
+		Meta: func(r *http.Request) map[string]any {
+			return map[string]any{"requestPath": r.URL.Path}
+		},
+	}
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/people/1", nil)
+	require.NoError(t, err)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	api.ServeHTTP(w, r)
+	resp := w.Result()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.JSONEq(t, `{
+	  "links": {
+		"self": "/people/1"
+	  },
+	  "data": {
+		"type": "people",
+		"id": "1",
+		"attributes": {
+		  "name": "Dan"
+		},
+		"links": {
+		  "self": "/people/1"
+		},
+		"meta": {
+		  "canEdit": true
+		}
+	  },
+	  "meta": {
+		"requestPath": "/people/1"
+	  },
+	  "jsonapi": {
+		"version": "1.1"
+	  }
+	}`, string(body))
+}
+
+func TestConditionalRequests(t *testing.T) {
+	version := 1
+	s, err := NewSchema(&SchemaDefinition{
+		ResourceTypes: map[string]AnyResourceType{
+			"people": ResourceType[int]{
+				Get: func(ctx context.Context, id string) (int, *types.Error) {
+					return version, nil
+				},
+				Patch: func(ctx context.Context, id string, attributes map[string]json.RawMessage, relationships map[string]any) (int, *types.Error) {
+					version++
+					return version, nil
+				},
+				Delete: func(ctx context.Context, id string) *types.Error {
+					return nil
+				},
+				ETag: func(ctx context.Context, resource int) (string, *types.Error) {
+					return "v" + strconv.Itoa(resource), nil
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	api := API{Schema: s}
+
+	do := func(method, header, value, body string) *http.Response {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest(method, "/people/1", strings.NewReader(body))
+		require.NoError(t, err)
+		r.Header.Set("Accept", "application/vnd.api+json")
+		if header != "" {
+			r.Header.Set(header, value)
+		}
+		api.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	const patchBody = `{"data":{"type":"people","id":"1"}}`
+
+	t.Run("Get", func(t *testing.T) {
+		resp := do("GET", "", "", "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"v1"`, resp.Header.Get("ETag"))
+
+		resp = do("GET", "If-None-Match", `"v0", W/"v1"`, "")
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+		assert.Equal(t, `"v1"`, resp.Header.Get("ETag"))
+		body, _ := io.ReadAll(resp.Body)
+		assert.Empty(t, body)
+
+		resp = do("GET", "If-None-Match", `"v0"`, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		resp := do("PATCH", "If-Match", `"v0"`, patchBody)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+		resp = do("PATCH", "If-Match", `W/"v1"`, patchBody)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+		resp = do("PATCH", "If-Match", `"v1"`, patchBody)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"v2"`, resp.Header.Get("ETag"))
+
+		resp = do("PATCH", "If-Match", `*`, patchBody)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `"v3"`, resp.Header.Get("ETag"))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		resp := do("DELETE", "If-Match", `"v1"`, "")
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+		resp = do("DELETE", "If-Match", `"v3"`, "")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestListResources(t *testing.T) {
+	s, err := NewSchema(&SchemaDefinition{
+		ResourceTypes: map[string]AnyResourceType{
+			"people": ResourceType[string]{
+				List: func(ctx context.Context, after, before *string, limit int) ([]ListItem[string], *types.Error) {
+					var ret []ListItem[string]
+					for _, id := range []string{"c", "a", "b", "d"} {
+						ret = append(ret, ListItem[string]{
+							Id:       id,
+							Resource: id,
+							Cursor:   id,
+						})
+					}
+					return ret, nil
+				},
+				DefaultPageSize: 2,
+				MaxPageSize:     3,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Query            string
+		ExpectedStatus   int
+		ExpectedResponse string
+	}{
+		"Default": {
+			ExpectedStatus: http.StatusOK,
+			ExpectedResponse: `{
+			  "links": {
+				"self": "/people",
+				"next": "/people?page%5Bafter%5D=b&page%5Bsize%5D=2"
+			  },
+			  "data": [
+				{"type": "people", "id": "a"},
+				{"type": "people", "id": "b"}
+			  ],
+			  "jsonapi": {
+				"version": "1.1"
+			  }
+			}`,
+		},
+		"After": {
+			Query:          "page[after]=b&page[size]=3",
+			ExpectedStatus: http.StatusOK,
+			ExpectedResponse: `{
+			  "links": {
+				"self": "/people?page[after]=b&page[size]=3",
+				"prev": "/people?page%5Bbefore%5D=c&page%5Bsize%5D=3"
+			  },
+			  "data": [
+				{"type": "people", "id": "c"},
+				{"type": "people", "id": "d"}
+			  ],
+			  "jsonapi": {
+				"version": "1.1"
+			  }
+			}`,
+		},
+		"Before": {
+			Query:          "page[before]=d&page[size]=1",
+			ExpectedStatus: http.StatusOK,
+			ExpectedResponse: `{
+			  "links": {
+				"self": "/people?page[before]=d&page[size]=1",
+				"prev": "/people?page%5Bbefore%5D=c&page%5Bsize%5D=1",
+				"next": "/people?page%5Bafter%5D=c&page%5Bsize%5D=1"
+			  },
+			  "data": [
+				{"type": "people", "id": "c"}
+			  ],
+			  "jsonapi": {
+				"version": "1.1"
+			  }
+			}`,
+		},
+		"OtherParameters": {
+			Query:          "Foo=bar&page[after]=a&Foo[bar]=baz",
+			ExpectedStatus: http.StatusOK,
+			ExpectedResponse: `{
+			  "links": {
+				"self": "/people?Foo=bar&page[after]=a&Foo[bar]=baz",
+				"prev": "/people?Foo=bar&Foo%5Bbar%5D=baz&page%5Bbefore%5D=b&page%5Bsize%5D=2",
+				"next": "/people?Foo=bar&Foo%5Bbar%5D=baz&page%5Bafter%5D=c&page%5Bsize%5D=2"
+			  },
+			  "data": [
+				{"type": "people", "id": "b"},
+				{"type": "people", "id": "c"}
+			  ],
+			  "jsonapi": {
+				"version": "1.1"
+			  }
+			}`,
+		},
+		"TooLarge": {
+			Query:          "page[size]=4",
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		"Negative": {
+			Query:          "page[size]=-1",
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		"NotANumber": {
+			Query:          "page[size]=foo",
+			ExpectedStatus: http.StatusBadRequest,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			path := "/people"
+			if tc.Query != "" {
+				path += "?" + tc.Query
+			}
+			r, err := http.NewRequest("GET", path, nil)
+			require.NoError(t, err)
+			r.Header.Set("Accept", "application/vnd.api+json")
+			API{Schema: s}.ServeHTTP(w, r)
+			resp := w.Result()
+			assert.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+			if tc.ExpectedResponse != "" {
+				body, _ := io.ReadAll(resp.Body)
+				assert.JSONEq(t, tc.ExpectedResponse, string(body))
+			}
+		})
+	}
+}
+
+func TestTypedAttributes(t *testing.T) {
+	type Person struct {
+		Name string
+		Age  int
+	}
+
+	var patched Person
+	s, err := NewSchema(&SchemaDefinition{
+		ResourceTypes: map[string]AnyResourceType{
+			"people": ResourceType[Person]{
+				Attributes: map[string]*AttributeDefinition[Person]{
+					"name": {
+						Resolver: AttributeFunc[Person, string](func(ctx context.Context, person Person) (string, *types.Error) {
+							return person.Name, nil
+						}),
+						Decoder: TypedAttributeDecoder[string]{
+							Validate: func(ctx context.Context, value string) *types.Error {
+								if value == "" {
+									err := errorForHTTPStatus(http.StatusUnprocessableEntity)
+									err.Detail = "Names must not be empty."
+									return &err
+								}
+								return nil
+							},
+						},
+					},
+					"age": {
+						Resolver: AttributeFunc[Person, int](func(ctx context.Context, person Person) (int, *types.Error) {
+							return person.Age, nil
+						}),
+						Decoder: TypedAttributeDecoder[int]{},
+					},
+				},
+				Patch: func(ctx context.Context, id string, attributes map[string]json.RawMessage, relationships map[string]any) (Person, *types.Error) {
+					if name, ok := AttributeValue[string](ctx, "name"); ok {
+						patched.Name = name
+					}
+					if age, ok := AttributeValue[int](ctx, "age"); ok {
+						patched.Age = age
+					}
+					return patched, nil
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	api := API{Schema: s}
+
+	for name, tc := range map[string]struct {
+		Body           string
+		ExpectedStatus int
+		ExpectedBody   string
+		ExpectedPerson Person
+	}{
+		"Valid": {
+			Body:           `{"data":{"type":"people","id":"1","attributes":{"name":"Alice","age":30}}}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   `{"data":{"type":"people","id":"1","attributes":{"age":30,"name":"Alice"}},"links":{"self":"/people/1"},"jsonapi":{"version":"1.1"}}`,
+			ExpectedPerson: Person{Name: "Alice", Age: 30},
+		},
+		"Partial": {
+			Body:           `{"data":{"type":"people","id":"1","attributes":{"age":40}}}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   `{"data":{"type":"people","id":"1","attributes":{"age":40,"name":""}},"links":{"self":"/people/1"},"jsonapi":{"version":"1.1"}}`,
+			ExpectedPerson: Person{Age: 40},
+		},
+		"WrongType": {
+			Body:           `{"data":{"type":"people","id":"1","attributes":{"age":"old"}}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+		},
+		"Invalid": {
+			Body:           `{"data":{"type":"people","id":"1","attributes":{"name":""}}}`,
+			ExpectedStatus: http.StatusUnprocessableEntity,
+			ExpectedBody:   `{"errors":[{"status":"422","title":"Unprocessable Entity","detail":"Names must not be empty.","source":{"pointer":"/data/attributes/name"}}],"jsonapi":{"version":"1.1"}}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			patched = Person{}
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest("PATCH", "/people/1", strings.NewReader(tc.Body))
+			require.NoError(t, err)
+			r.Header.Set("Accept", "application/vnd.api+json")
+			api.ServeHTTP(w, r)
+			resp := w.Result()
+			assert.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+			if tc.ExpectedBody != "" {
+				body, _ := io.ReadAll(resp.Body)
+				assert.JSONEq(t, tc.ExpectedBody, string(body))
+			}
+			assert.Equal(t, tc.ExpectedPerson, patched)
+		})
+	}
+}
+
+func TestIncludeAndFields(t *testing.T) {
+	for name, tc := range map[string]struct {
+		Path           string
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		"Include": {
+			Path:           "/articles/1?include=comments.author&fields[articles]=title&fields[people]=firstName",
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody: `{
+				"links": {"self": "/articles/1"},
+				"data": {
+					"type": "articles",
+					"id": "1",
+					"attributes": {"title": "JSON:API paints my bikeshed!"}
+				},
+				"included": [
+					{
+						"type": "comments",
+						"id": "5",
+						"relationships": {
+							"author": {
+								"links": {"self": "/comments/5/relationships/author", "related": "/comments/5/author"},
+								"data": {"type": "people", "id": "2"}
+							}
+						}
+					},
+					{
+						"type": "comments",
+						"id": "12",
+						"relationships": {
+							"author": {
+								"links": {"self": "/comments/12/relationships/author", "related": "/comments/12/author"},
+								"data": {"type": "people", "id": "2"}
+							}
+						}
+					},
+					{
+						"type": "people",
+						"id": "2",
+						"attributes": {"firstName": "Dan"}
+					}
+				],
+				"jsonapi": {"version": "1.1"}
+			}`,
+		},
+		"Related": {
+			Path:           "/articles/1/comments?include=author&fields[comments]=",
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody: `{
+				"links": {"self": "/articles/1/comments"},
+				"data": [
+					{"type": "comments", "id": "5"},
+					{"type": "comments", "id": "12"}
+				],
+				"included": [
+					{
+						"type": "people",
+						"id": "2",
+						"attributes": {"firstName": "Dan", "lastName": "Gebhardt", "twitter": "dgeb"}
+					}
+				],
+				"jsonapi": {"version": "1.1"}
+			}`,
+		},
+		"InvalidPath": {
+			Path:           "/articles/1?include=comments.foo",
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		"RelationshipEndpoint": {
+			Path:           "/articles/1/relationships/comments?include=author",
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		"InvalidFields": {
+			Path:           "/articles/1?fields=title",
+			ExpectedStatus: http.StatusBadRequest,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest("GET", tc.Path, nil)
+			require.NoError(t, err)
+			r.Header.Set("Accept", "application/vnd.api+json")
+			API{Schema: testSchema}.ServeHTTP(w, r)
+			resp := w.Result()
+			assert.Equal(t, tc.ExpectedStatus, resp.StatusCode)
+			if tc.ExpectedBody != "" {
+				body, _ := io.ReadAll(resp.Body)
+				assert.JSONEq(t, tc.ExpectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestGetRequestContext(t *testing.T) {
+	var rc *RequestContext
+	s, err := NewSchema(&SchemaDefinition{
+		ResourceTypes: map[string]AnyResourceType{
+			"people": ResourceType[struct{}]{
+				List: func(ctx context.Context, after, before *string, limit int) ([]ListItem[struct{}], *types.Error) {
+					rc = GetRequestContext(ctx)
+					return nil, nil
+				},
+				DefaultPageSize: 10,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest("GET", "/people?fields[people]=name,age&page[size]=5&include=friends,friends.employer", nil)
+	require.NoError(t, err)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	API{Schema: s}.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+	require.NotNil(t, rc)
+	assert.Equal(t, map[string][]string{"people": {"name", "age"}}, rc.Fields)
+	assert.Equal(t, map[string]string{"size": "5"}, rc.Page)
+	assert.Equal(t, [][]string{{"friends"}, {"friends", "employer"}}, rc.Include)
+	assert.True(t, rc.FieldRequested("people", "age"))
+	assert.False(t, rc.FieldRequested("people", "email"))
+	assert.True(t, rc.FieldRequested("companies", "name"))
+	assert.True(t, rc.Included("friends"))
+	assert.True(t, rc.Included("friends", "employer"))
+	assert.False(t, rc.Included("employer"))
+}
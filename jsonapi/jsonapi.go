@@ -4,11 +4,16 @@ package jsonapi
 
 import (
 	"fmt"
+	"net/http"
 	"strings"
 )
 
 type API struct {
 	Schema *Schema
+
+	// If given, this is invoked for each request and its result is merged into the top-level
+	// "meta" member of the response document.
+	Meta func(r *http.Request) map[string]any
 }
 
 func isGloballyAllowedCharacter(r rune) bool {
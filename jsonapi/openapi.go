@@ -0,0 +1,508 @@
+package jsonapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenAPIInfo provides the metadata for generated OpenAPI documents.
+type OpenAPIInfo struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+// Describes the capabilities of a resource type. This is used to generate OpenAPI documents.
+type resourceTypeDescription struct {
+	Get             bool
+	List            bool
+	Patch           bool
+	Create          bool
+	CreateAsync     bool
+	CreateNoContent bool
+	Delete          bool
+	ETag            bool
+
+	// The Go types of the attributes' values. Types are nil if they're unknown.
+	Attributes map[string]reflect.Type
+
+	Relationships map[string]relationshipDescription
+}
+
+type relationshipDescription struct {
+	// If neither of these are true, the cardinality of the relationship is unknown.
+	ToOne  bool
+	ToMany bool
+
+	AddMembers    bool
+	RemoveMembers bool
+}
+
+const mediaType = "application/vnd.api+json"
+
+func schemaRef(name string) map[string]any {
+	return map[string]any{
+		"$ref": "#/components/schemas/" + name,
+	}
+}
+
+func nullableSchema(schema map[string]any) map[string]any {
+	return map[string]any{
+		"oneOf": []any{schema, map[string]any{"type": "null"}},
+	}
+}
+
+func objectSchema(properties map[string]any, required ...string) map[string]any {
+	ret := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		ret["required"] = required
+	}
+	return ret
+}
+
+func documentSchema(data map[string]any) map[string]any {
+	properties := map[string]any{
+		"links": schemaRef("Links"),
+		"meta":  map[string]any{"type": "object"},
+	}
+	if data == nil {
+		return objectSchema(properties)
+	}
+	properties["data"] = data
+	return objectSchema(properties, "data")
+}
+
+func openAPIContent(schema map[string]any) map[string]any {
+	return map[string]any{
+		mediaType: map[string]any{
+			"schema": schema,
+		},
+	}
+}
+
+func openAPIResponse(description string, schema map[string]any) map[string]any {
+	ret := map[string]any{
+		"description": description,
+	}
+	if schema != nil {
+		ret["content"] = openAPIContent(schema)
+	}
+	return ret
+}
+
+func openAPIOperation(operationId, summary string, responses map[string]any) map[string]any {
+	responses["default"] = openAPIResponse("An error occurred.", schemaRef("ErrorDocument"))
+	return map[string]any{
+		"operationId": operationId,
+		"summary":     summary,
+		"responses":   responses,
+	}
+}
+
+// OpenAPI returns an OpenAPI 3.1 document describing the schema's endpoints. The result can be
+// marshaled as JSON or YAML.
+//
+// Attribute schemas are derived from the Go types of the attributes' values if their resolvers
+// implement TypedAttributeResolver. Otherwise attributes may have any value.
+func (s *Schema) OpenAPI(info OpenAPIInfo) map[string]any {
+	openAPIInfo := map[string]any{
+		"title":   info.Title,
+		"version": info.Version,
+	}
+	if info.Description != "" {
+		openAPIInfo["description"] = info.Description
+	}
+
+	schemas := map[string]any{
+		"ResourceIdentifier": objectSchema(map[string]any{
+			"type": map[string]any{"type": "string"},
+			"id":   map[string]any{"type": "string"},
+			"meta": map[string]any{"type": "object"},
+		}, "type", "id"),
+		"Resource": objectSchema(map[string]any{
+			"type":          map[string]any{"type": "string"},
+			"id":            map[string]any{"type": "string"},
+			"attributes":    map[string]any{"type": "object"},
+			"relationships": map[string]any{"type": "object"},
+			"links":         schemaRef("Links"),
+			"meta":          map[string]any{"type": "object"},
+		}, "type", "id"),
+		"Links": map[string]any{
+			"type":                 "object",
+			"additionalProperties": map[string]any{"type": "string"},
+		},
+		"Error": objectSchema(map[string]any{
+			"id":     map[string]any{"type": "string"},
+			"status": map[string]any{"type": "string"},
+			"code":   map[string]any{"type": "string"},
+			"title":  map[string]any{"type": "string"},
+			"detail": map[string]any{"type": "string"},
+			"source": map[string]any{"type": "object"},
+			"meta":   map[string]any{"type": "object"},
+		}),
+		"ErrorDocument": objectSchema(map[string]any{
+			"errors": map[string]any{
+				"type":  "array",
+				"items": schemaRef("Error"),
+			},
+			"meta": map[string]any{"type": "object"},
+		}, "errors"),
+	}
+	paths := map[string]any{}
+
+	typeNames := make([]string, 0, len(s.resourceTypes))
+	for name := range s.resourceTypes {
+		typeNames = append(typeNames, name)
+	}
+	sort.Strings(typeNames)
+
+	for _, typeName := range typeNames {
+		desc := s.resourceTypes[typeName].describe()
+		resourceSchemaName := typeName + "Resource"
+		schemas[resourceSchemaName] = resourceSchema(typeName, desc, true)
+
+		collectionPath := map[string]any{}
+		if desc.List {
+			op := openAPIOperation(typeName+".list", "Lists "+typeName+".", map[string]any{
+				"200": openAPIResponse("The requested page of resources.", documentSchema(map[string]any{
+					"type":  "array",
+					"items": schemaRef(resourceSchemaName),
+				})),
+			})
+			op["parameters"] = []any{
+				queryParameter("page[size]", map[string]any{"type": "integer", "minimum": 1}),
+				queryParameter("page[after]", map[string]any{"type": "string"}),
+				queryParameter("page[before]", map[string]any{"type": "string"}),
+			}
+			collectionPath["get"] = op
+		}
+		if desc.Create || desc.CreateAsync {
+			responses := map[string]any{}
+			if desc.CreateAsync {
+				responses["202"] = openAPIResponse("The creation was accepted. The status link can be used to monitor it.", documentSchema(nil))
+			} else {
+				responses["201"] = openAPIResponse("The resource was created.", documentSchema(schemaRef(resourceSchemaName)))
+				if desc.CreateNoContent {
+					responses["204"] = openAPIResponse("The resource was created exactly as given.", nil)
+				}
+			}
+			op := openAPIOperation(typeName+".create", "Creates a resource in "+typeName+".", responses)
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content": openAPIContent(objectSchema(map[string]any{
+					"data": resourceSchema(typeName, desc, false),
+				}, "data")),
+			}
+			collectionPath["post"] = op
+		}
+		if len(collectionPath) > 0 {
+			paths["/"+typeName] = collectionPath
+		}
+
+		resourcePath := map[string]any{}
+		if desc.Get {
+			responses := map[string]any{
+				"200": openAPIResponse("The requested resource.", documentSchema(schemaRef(resourceSchemaName))),
+				"404": openAPIResponse("The resource was not found.", schemaRef("ErrorDocument")),
+			}
+			if desc.ETag {
+				responses["304"] = openAPIResponse("The resource matches the If-None-Match header.", nil)
+			}
+			resourcePath["get"] = openAPIOperation(typeName+".get", "Gets a resource from "+typeName+".", responses)
+		}
+		if desc.Patch {
+			responses := map[string]any{
+				"200": openAPIResponse("The updated resource.", documentSchema(schemaRef(resourceSchemaName))),
+			}
+			if desc.ETag {
+				responses["412"] = openAPIResponse("The resource doesn't match the If-Match header.", schemaRef("ErrorDocument"))
+			}
+			op := openAPIOperation(typeName+".update", "Updates a resource in "+typeName+".", responses)
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content": openAPIContent(objectSchema(map[string]any{
+					"data": resourceSchema(typeName, desc, false),
+				}, "data")),
+			}
+			resourcePath["patch"] = op
+		}
+		if desc.Delete {
+			responses := map[string]any{
+				"200": openAPIResponse("The resource was deleted.", documentSchema(nil)),
+			}
+			if desc.ETag {
+				responses["412"] = openAPIResponse("The resource doesn't match the If-Match header.", schemaRef("ErrorDocument"))
+			}
+			resourcePath["delete"] = openAPIOperation(typeName+".delete", "Deletes a resource from "+typeName+".", responses)
+		}
+		if len(resourcePath) > 0 {
+			resourcePath["parameters"] = []any{idParameter()}
+			paths["/"+typeName+"/{id}"] = resourcePath
+		}
+
+		relationshipNames := make([]string, 0, len(desc.Relationships))
+		for name := range desc.Relationships {
+			relationshipNames = append(relationshipNames, name)
+		}
+		sort.Strings(relationshipNames)
+
+		for _, name := range relationshipNames {
+			rel := desc.Relationships[name]
+			linkageSchema := relationshipLinkageSchema(rel)
+			relationshipSchema := documentSchema(linkageSchema)
+			operationIdPrefix := typeName + ".relationships." + name
+
+			if desc.Get {
+				var relatedSchema map[string]any
+				switch {
+				case rel.ToOne:
+					relatedSchema = nullableSchema(schemaRef("Resource"))
+				case rel.ToMany:
+					relatedSchema = map[string]any{"type": "array", "items": schemaRef("Resource")}
+				default:
+					relatedSchema = map[string]any{}
+				}
+				paths["/"+typeName+"/{id}/"+name] = map[string]any{
+					"parameters": []any{idParameter()},
+					"get": openAPIOperation(typeName+".related."+name+".get", "Gets the resources related to a resource in "+typeName+" via "+name+".", map[string]any{
+						"200": openAPIResponse("The related resources.", documentSchema(relatedSchema)),
+					}),
+				}
+			}
+
+			relationshipPath := map[string]any{}
+			if desc.Get {
+				relationshipPath["get"] = openAPIOperation(operationIdPrefix+".get", "Gets the "+name+" relationship of a resource in "+typeName+".", map[string]any{
+					"200": openAPIResponse("The relationship.", relationshipSchema),
+				})
+			}
+			if desc.Patch {
+				op := openAPIOperation(operationIdPrefix+".update", "Replaces the "+name+" relationship of a resource in "+typeName+".", map[string]any{
+					"200": openAPIResponse("The updated relationship.", relationshipSchema),
+				})
+				op["requestBody"] = map[string]any{
+					"required": true,
+					"content":  openAPIContent(objectSchema(map[string]any{"data": linkageSchema}, "data")),
+				}
+				relationshipPath["patch"] = op
+			}
+			membersBody := map[string]any{
+				"required": true,
+				"content": openAPIContent(objectSchema(map[string]any{
+					"data": map[string]any{"type": "array", "items": schemaRef("ResourceIdentifier")},
+				}, "data")),
+			}
+			if desc.Get && !rel.ToOne && rel.AddMembers {
+				op := openAPIOperation(operationIdPrefix+".add", "Adds members to the "+name+" relationship of a resource in "+typeName+".", map[string]any{
+					"200": openAPIResponse("The updated relationship.", relationshipSchema),
+				})
+				op["requestBody"] = membersBody
+				relationshipPath["post"] = op
+			}
+			if desc.Get && !rel.ToOne && rel.RemoveMembers {
+				op := openAPIOperation(operationIdPrefix+".remove", "Removes members from the "+name+" relationship of a resource in "+typeName+".", map[string]any{
+					"200": openAPIResponse("The updated relationship.", relationshipSchema),
+				})
+				op["requestBody"] = membersBody
+				relationshipPath["delete"] = op
+			}
+			if len(relationshipPath) > 0 {
+				relationshipPath["parameters"] = []any{idParameter()}
+				paths["/"+typeName+"/{id}/relationships/"+name] = relationshipPath
+			}
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info":    openAPIInfo,
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas,
+		},
+	}
+}
+
+func idParameter() map[string]any {
+	return map[string]any{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]any{"type": "string"},
+	}
+}
+
+func queryParameter(name string, schema map[string]any) map[string]any {
+	return map[string]any{
+		"name":   name,
+		"in":     "query",
+		"schema": schema,
+	}
+}
+
+func relationshipLinkageSchema(rel relationshipDescription) map[string]any {
+	toOne := nullableSchema(schemaRef("ResourceIdentifier"))
+	toMany := map[string]any{
+		"type":  "array",
+		"items": schemaRef("ResourceIdentifier"),
+	}
+	switch {
+	case rel.ToOne:
+		return toOne
+	case rel.ToMany:
+		return toMany
+	}
+	return map[string]any{
+		"oneOf": []any{schemaRef("ResourceIdentifier"), toMany, map[string]any{"type": "null"}},
+	}
+}
+
+// Returns the schema for a resource object of the given type. If isResponse is false, the schema
+// is for resource objects in request documents, which don't require ids, links, or metadata.
+func resourceSchema(typeName string, desc *resourceTypeDescription, isResponse bool) map[string]any {
+	attributes := make(map[string]any, len(desc.Attributes))
+	for name, t := range desc.Attributes {
+		attributes[name] = jsonSchemaForType(t, nil)
+	}
+
+	relationships := make(map[string]any, len(desc.Relationships))
+	for name, rel := range desc.Relationships {
+		properties := map[string]any{
+			"data": relationshipLinkageSchema(rel),
+		}
+		if isResponse {
+			properties["links"] = schemaRef("Links")
+			properties["meta"] = map[string]any{"type": "object"}
+		}
+		relationships[name] = objectSchema(properties)
+	}
+
+	properties := map[string]any{
+		"type": map[string]any{
+			"type":  "string",
+			"const": typeName,
+		},
+		"id":            map[string]any{"type": "string"},
+		"attributes":    objectSchema(attributes),
+		"relationships": objectSchema(relationships),
+	}
+	if !isResponse {
+		return objectSchema(properties, "type")
+	}
+	properties["links"] = schemaRef("Links")
+	properties["meta"] = map[string]any{"type": "object"}
+	return objectSchema(properties, "type", "id")
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// Returns a JSON Schema describing the JSON encoding of values of the given type. If the encoding
+// can't be determined, e.g. because the type is nil or has a custom marshaler, an empty schema is
+// returned, which permits any value.
+func jsonSchemaForType(t reflect.Type, visiting map[reflect.Type]struct{}) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	} else if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	} else if t.Implements(jsonMarshalerType) {
+		return map[string]any{}
+	} else if t.Implements(textMarshalerType) {
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Ptr:
+		return nullableSchema(jsonSchemaForType(t.Elem(), visiting))
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{
+			"type":  "array",
+			"items": jsonSchemaForType(t.Elem(), visiting),
+		}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return map[string]any{"type": "object"}
+		}
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": jsonSchemaForType(t.Elem(), visiting),
+		}
+	case reflect.Struct:
+		if _, ok := visiting[t]; ok {
+			// Recursive types can't be described without named schemas, so we permit anything.
+			return map[string]any{}
+		}
+		if visiting == nil {
+			visiting = map[reflect.Type]struct{}{}
+		}
+		visiting[t] = struct{}{}
+		defer delete(visiting, t)
+
+		properties := map[string]any{}
+		var required []string
+		addStructFields(t, visiting, properties, &required)
+		sort.Strings(required)
+		return objectSchema(properties, required...)
+	}
+	return map[string]any{}
+}
+
+// Adds the JSON properties for the struct's fields, including the fields of embedded structs.
+func addStructFields(t reflect.Type, visiting map[reflect.Type]struct{}, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				addStructFields(fieldType, visiting, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		schema := jsonSchemaForType(field.Type, visiting)
+		if strings.Contains(","+options+",", ",string,") {
+			schema = map[string]any{"type": "string"}
+		}
+		properties[name] = schema
+		if !strings.Contains(","+options+",", ",omitempty,") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/jsonapi/types"
+)
+
+func TestSchema_OpenAPI(t *testing.T) {
+	type Article struct {
+		Title string
+	}
+
+	type Metadata struct {
+		Tags      []string  `json:"tags"`
+		Score     *float64  `json:"score,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+		Ignored   string    `json:"-"`
+	}
+
+	schema, err := NewSchema(&SchemaDefinition{
+		ResourceTypes: map[string]AnyResourceType{
+			"articles": ResourceType[Article]{
+				Attributes: map[string]*AttributeDefinition[Article]{
+					"title": {
+						Resolver: AttributeFunc[Article, string](func(ctx context.Context, article Article) (string, *types.Error) {
+							return article.Title, nil
+						}),
+					},
+					"metadata": {
+						Resolver: AttributeFunc[Article, Metadata](func(ctx context.Context, article Article) (Metadata, *types.Error) {
+							return Metadata{}, nil
+						}),
+					},
+					"untyped": {
+						Resolver: ConstantString[Article]("foo"),
+					},
+				},
+				Relationships: map[string]*RelationshipDefinition[Article]{
+					"author": {
+						Resolver: ToOneRelationshipResolver[Article]{},
+					},
+					"comments": {
+						Resolver: ToManyRelationshipResolver[Article]{
+							AddMembers: func(ctx context.Context, article Article, members []types.ResourceId) ([]types.ResourceId, *types.Error) {
+								return nil, nil
+							},
+						},
+					},
+				},
+				Get: func(ctx context.Context, id string) (Article, *types.Error) {
+					return Article{}, nil
+				},
+				Patch: func(ctx context.Context, id string, attributes map[string]json.RawMessage, relationships map[string]any) (Article, *types.Error) {
+					return Article{}, nil
+				},
+			},
+			"comments": ResourceType[struct{}]{
+				List: func(ctx context.Context, after, before *string, limit int) ([]ListItem[struct{}], *types.Error) {
+					return nil, nil
+				},
+				DefaultPageSize: 10,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	buf, err := json.Marshal(schema.OpenAPI(OpenAPIInfo{
+		Title:   "Test",
+		Version: "1.0.0",
+	}))
+	require.NoError(t, err)
+
+	var doc struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]struct {
+					Properties map[string]json.RawMessage `json:"properties"`
+				} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(buf, &doc))
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+
+	methods := map[string][]string{}
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method != "parameters" {
+				methods[path] = append(methods[path], method)
+			}
+		}
+	}
+	for path, expected := range map[string][]string{
+		"/articles/{id}":                        {"get", "patch"},
+		"/articles/{id}/author":                 {"get"},
+		"/articles/{id}/comments":               {"get"},
+		"/articles/{id}/relationships/author":   {"get", "patch"},
+		"/articles/{id}/relationships/comments": {"get", "patch", "post"},
+		"/comments":                             {"get"},
+	} {
+		assert.ElementsMatch(t, expected, methods[path], path)
+	}
+	assert.Len(t, methods, 6)
+
+	attributes := doc.Components.Schemas["articlesResource"].Properties["attributes"].Properties
+	assert.JSONEq(t, `{"type": "string"}`, string(attributes["title"]))
+	assert.JSONEq(t, `{}`, string(attributes["untyped"]))
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"score": {"oneOf": [{"type": "number"}, {"type": "null"}]},
+			"created_at": {"type": "string", "format": "date-time"}
+		},
+		"required": ["created_at", "tags"]
+	}`, string(attributes["metadata"]))
+
+	relationships := doc.Components.Schemas["articlesResource"].Properties["relationships"].Properties
+	assert.Contains(t, string(relationships["author"]), `"oneOf"`)
+	assert.Contains(t, string(relationships["comments"]), `"type":"array"`)
+}
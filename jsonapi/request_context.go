@@ -0,0 +1,161 @@
+package jsonapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ccbrown/api-fu/jsonapi/types"
+)
+
+// RequestContext describes what the client asked for via a request's query parameters. It can be
+// retrieved by resolvers and resource type functions via GetRequestContext in order to optimize
+// data loading.
+type RequestContext struct {
+	// The sparse fieldsets requested via fields[TYPE] query parameters, keyed by resource type. If
+	// a type isn't present, all of its fields are requested.
+	Fields map[string][]string
+
+	// The relationship paths requested via the include query parameter, relative to the primary
+	// data. For example, "include=comments.author" is represented as [["comments", "author"]].
+	Include [][]string
+
+	// The pagination parameters given via page[NAME] query parameters, keyed by name.
+	Page map[string]string
+}
+
+// FieldRequested returns true if the given attribute or relationship should be present on
+// resources of the given type in the response. It is safe to call on a nil RequestContext.
+func (c *RequestContext) FieldRequested(typeName, field string) bool {
+	if c == nil {
+		return true
+	}
+	fields, ok := c.Fields[typeName]
+	if !ok {
+		return true
+	}
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Included returns true if the resources at the given relationship path, relative to the primary
+// data, will be included in the response. It is safe to call on a nil RequestContext.
+func (c *RequestContext) Included(path ...string) bool {
+	if c == nil {
+		return false
+	}
+	for _, include := range c.Include {
+		if len(include) < len(path) {
+			continue
+		}
+		isPrefix := true
+		for i := range path {
+			if include[i] != path[i] {
+				isPrefix = false
+				break
+			}
+		}
+		if isPrefix {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if any include path traverses a relationship with the given name. Resource linkage
+// is always resolved for such relationships.
+func (c *RequestContext) traversesRelationship(name string) bool {
+	if c == nil {
+		return false
+	}
+	for _, include := range c.Include {
+		for _, n := range include {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type requestContextKeyType int
+
+var requestContextKey requestContextKeyType
+
+// GetRequestContext returns the RequestContext for the request being handled, or nil if the context
+// doesn't belong to a request.
+func GetRequestContext(ctx context.Context) *RequestContext {
+	rc, _ := ctx.Value(requestContextKey).(*RequestContext)
+	return rc
+}
+
+func withRequestContext(ctx context.Context, rc *RequestContext) context.Context {
+	return context.WithValue(ctx, requestContextKey, rc)
+}
+
+func parameterError(parameter, detail string) *types.Error {
+	err := errorForHTTPStatus(http.StatusBadRequest)
+	err.Detail = detail
+	err.Source = &types.ErrorSource{
+		Parameter: parameter,
+	}
+	return &err
+}
+
+// Parses the include, fields, and page query parameters. Other parameters are ignored.
+func parseRequestContext(q url.Values) (*RequestContext, *types.Error) {
+	ret := &RequestContext{}
+	for k, values := range q {
+		value := strings.Join(values, ",")
+		familyName, member, hasMember := strings.Cut(k, "[")
+		member = strings.TrimSuffix(member, "]")
+		isNested := strings.Contains(member, "[")
+
+		switch familyName {
+		case "include":
+			if hasMember {
+				return nil, parameterError(k, "include does not accept members.")
+			}
+			for _, path := range strings.Split(value, ",") {
+				if path == "" {
+					continue
+				}
+				names := strings.Split(path, ".")
+				for _, name := range names {
+					if validateMemberName(name) != nil {
+						return nil, parameterError(k, fmt.Sprintf("%v is not a valid relationship path.", path))
+					}
+				}
+				ret.Include = append(ret.Include, names)
+			}
+		case "fields":
+			if !hasMember || isNested {
+				return nil, parameterError(k, "fields must be given as fields[TYPE].")
+			}
+			if ret.Fields == nil {
+				ret.Fields = map[string][]string{}
+			}
+			fields := []string{}
+			for _, field := range strings.Split(value, ",") {
+				if field != "" {
+					fields = append(fields, field)
+				}
+			}
+			ret.Fields[member] = fields
+		case "page":
+			if hasMember && !isNested {
+				if ret.Page == nil {
+					ret.Page = map[string]string{}
+				}
+				ret.Page[member] = q.Get(k)
+			}
+		}
+	}
+	return ret, nil
+}
@@ -2,9 +2,11 @@ package jsonapi
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"reflect"
 
 	"github.com/ccbrown/api-fu/jsonapi/types"
 )
@@ -14,9 +16,80 @@ type AttributeResolver[T any] interface {
 	ResolveAttribute(ctx context.Context, resource T) (any, *types.Error)
 }
 
+// TypedAttributeResolver may be implemented by attribute resolvers to describe the Go type of the
+// values that they resolve. It's used to describe attributes in generated OpenAPI documents.
+// Attributes whose resolvers don't implement it may have any value.
+type TypedAttributeResolver interface {
+	AttributeType() reflect.Type
+}
+
+// AttributeFunc is an attribute resolver for attributes whose values have the Go type V.
+type AttributeFunc[T, V any] func(ctx context.Context, resource T) (V, *types.Error)
+
+func (f AttributeFunc[T, V]) ResolveAttribute(ctx context.Context, resource T) (any, *types.Error) {
+	return f(ctx, resource)
+}
+
+func (f AttributeFunc[T, V]) AttributeType() reflect.Type {
+	return reflect.TypeOf((*V)(nil)).Elem()
+}
+
+type AttributeDecoder interface {
+	// Implementations should decode and validate a value given for the attribute in a create or
+	// patch request. If the returned error doesn't have a source, it's pointed at the attribute.
+	//
+	// Generally you should use `TypedAttributeDecoder` instead of implementing this directly.
+	DecodeAttribute(ctx context.Context, value json.RawMessage) (any, *types.Error)
+}
+
+// TypedAttributeDecoder decodes attribute values into the Go type V.
+type TypedAttributeDecoder[V any] struct {
+	// If given, this is invoked to validate decoded values.
+	Validate func(ctx context.Context, value V) *types.Error
+}
+
+func (d TypedAttributeDecoder[V]) DecodeAttribute(ctx context.Context, value json.RawMessage) (any, *types.Error) {
+	var v V
+	if err := json.Unmarshal(value, &v); err != nil {
+		err2 := errorForHTTPStatus(http.StatusUnprocessableEntity)
+		err2.Detail = err.Error()
+		return nil, &err2
+	}
+	if d.Validate != nil {
+		if err := d.Validate(ctx, v); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (d TypedAttributeDecoder[V]) AttributeType() reflect.Type {
+	return reflect.TypeOf((*V)(nil)).Elem()
+}
+
 type AttributeDefinition[T any] struct {
 	// Defines the type and implementation of the attribute.
 	Resolver AttributeResolver[T]
+
+	// If given, values for the attribute in create and patch requests are decoded and validated
+	// before the resource type's Create, CreateWithId, CreateAsync, or Patch function is invoked.
+	// Requests with invalid values are rejected, and the decoded values can be retrieved via
+	// `AttributeValue`.
+	Decoder AttributeDecoder
+}
+
+type decodedAttributesContextKeyType int
+
+var decodedAttributesContextKey decodedAttributesContextKeyType
+
+// AttributeValue returns the decoded value of an attribute given in a create or patch request. It
+// can be used within a resource type's Create, CreateWithId, CreateAsync, or Patch function to get
+// the values of attributes that have decoders. If the attribute wasn't given, doesn't have a
+// decoder, or has a value of a different type, ok is false.
+func AttributeValue[V any](ctx context.Context, name string) (value V, ok bool) {
+	attributes, _ := ctx.Value(decodedAttributesContextKey).(map[string]any)
+	value, ok = attributes[name].(V)
+	return
 }
 
 func (def *AttributeDefinition[T]) validate() error {
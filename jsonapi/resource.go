@@ -7,8 +7,11 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
+	"sort"
+	"strconv"
 
 	"github.com/ccbrown/api-fu/jsonapi/types"
+	"github.com/ccbrown/api-fu/pagination"
 )
 
 type RelationshipDefinition[T any] struct {
@@ -25,14 +28,17 @@ func (def *RelationshipDefinition[T]) validate() error {
 
 // An interface which all ResourceType instantiations implement.
 type AnyResourceType interface {
-	get(ctx context.Context, id types.ResourceId) (*types.Resource, *types.Error)
-	patch(ctx context.Context, id types.ResourceId, attributes map[string]json.RawMessage, relationships map[string]any) (*types.Resource, *types.Error)
-	create(ctx context.Context, attributes map[string]json.RawMessage, relationships map[string]any) (*types.Resource, *types.Error)
+	get(ctx context.Context, id types.ResourceId) (*types.Resource, string, *types.Error)
+	list(ctx context.Context, typeName string, params url.Values) ([]types.Resource, types.Links, *types.Error)
+	currentETag(ctx context.Context, id types.ResourceId) (string, bool, *types.Error)
+	patch(ctx context.Context, id types.ResourceId, attributes map[string]json.RawMessage, relationships map[string]any) (*types.Resource, string, *types.Error)
+	create(ctx context.Context, id string, attributes map[string]json.RawMessage, relationships map[string]any) (*createResult, *types.Error)
 	delete(ctx context.Context, id types.ResourceId) *types.Error
 	getRelationship(ctx context.Context, id types.ResourceId, relationshipName string, params url.Values) (*types.Relationship, *types.Error)
 	patchRelationship(ctx context.Context, id types.ResourceId, relationshipName string, data any) (*types.Relationship, *types.Error)
 	addRelationshipMembers(ctx context.Context, id types.ResourceId, relationshipName string, members []types.ResourceId) (*types.Relationship, *types.Error)
 	removeRelationshipMembers(ctx context.Context, id types.ResourceId, relationshipName string, members []types.ResourceId) (*types.Relationship, *types.Error)
+	describe() *resourceTypeDescription
 	validate() error
 }
 
@@ -47,6 +53,22 @@ type ResourceType[T any] struct {
 	// endpoint.
 	Get func(ctx context.Context, id string) (T, *types.Error)
 
+	// If given, the resources can be listed via the GET method on the /{type_name} endpoint. Lists
+	// are paginated using the page[size], page[after], and page[before] query parameters, with the
+	// same semantics as GraphQL connections.
+	//
+	// List is only required to return items within the range defined by the given cursors and is
+	// only required to return up to `limit` items. If limit is negative, the last items within the
+	// range should be returned instead of the first. Returning extra items or out-of-order items is
+	// fine. They will be sorted and filtered automatically.
+	List func(ctx context.Context, after, before *string, limit int) ([]ListItem[T], *types.Error)
+
+	// The page size used for lists when page[size] isn't given. This is required if List is given.
+	DefaultPageSize int
+
+	// If non-zero, list requests with larger page sizes are rejected.
+	MaxPageSize int
+
 	// If given, the resource can be updated, e.g. via the PATCH method on the /{type_name}/{id}
 	// endpoint.
 	//
@@ -58,9 +80,69 @@ type ResourceType[T any] struct {
 	// Relationship values are either `nil`, `types.ResourceId`, or `[]types.ResourceId`.
 	Create func(ctx context.Context, attributes map[string]json.RawMessage, relationships map[string]any) (T, types.ResourceId, *types.Error)
 
+	// CreateWithId is like Create, but it's also given the id provided by the client for the new
+	// resource. If the client didn't provide one, id is empty. Client-provided ids are only
+	// permitted if AllowClientIds is true. This is used instead of Create.
+	CreateWithId func(ctx context.Context, id string, attributes map[string]json.RawMessage, relationships map[string]any) (T, types.ResourceId, *types.Error)
+
+	// If given, the resource can be created asynchronously. This is used instead of Create or
+	// CreateWithId, and successful requests are responded to with 202 Accepted. The returned URL should point to a
+	// resource which can be used to monitor the status of the creation. It's sent via the
+	// Content-Location header and as the "status" link of the response document.
+	CreateAsync func(ctx context.Context, id string, attributes map[string]json.RawMessage, relationships map[string]any) (string, *types.Error)
+
+	// If true, clients may provide ids for new resources. Otherwise, requests that contain ids are
+	// rejected with 403 Forbidden. This requires CreateWithId or CreateAsync.
+	AllowClientIds bool
+
+	// If true, creation requests with client-provided ids are responded to with 204 No Content
+	// instead of the new resource. This should only be used if the resource is created exactly as
+	// given in the request.
+	CreateNoContent bool
+
 	// If given, the resource can be deleted via the DELETE method on the /{type_name}/{id}
 	// endpoint.
 	Delete func(ctx context.Context, id string) *types.Error
+
+	// If given, this is invoked whenever a resource is included in a response and its result is
+	// used as the resource's "meta" member.
+	Meta func(ctx context.Context, resource T) (map[string]any, *types.Error)
+
+	// If given, this is invoked whenever a resource is included in a response and its result is
+	// used as the resource's "links" member.
+	Links func(ctx context.Context, resource T) (types.Links, *types.Error)
+
+	// If given, this is used to compute an entity tag for the resource's current version. The
+	// handler returns it via the ETag header, responds to GET requests with a matching
+	// If-None-Match header with 304 Not Modified, and rejects PATCH and DELETE requests with a
+	// non-matching If-Match header with 412 Precondition Failed.
+	//
+	// The returned value should not include quotes. It is always treated as a strong validator.
+	ETag func(ctx context.Context, resource T) (string, *types.Error)
+}
+
+// ListItem is a resource returned by ResourceType.List.
+type ListItem[T any] struct {
+	Id       string
+	Resource T
+
+	// The cursor is exposed to clients via page links. Cursors must sort lexicographically in the
+	// order that the resources should be listed.
+	Cursor string
+}
+
+type listCursor string
+
+func (c listCursor) LessThan(other listCursor) bool {
+	return c < other
+}
+
+type listEdge[T any] struct {
+	item ListItem[T]
+}
+
+func (e listEdge[T]) Cursor() listCursor {
+	return listCursor(e.item.Cursor)
 }
 
 func isNil(v interface{}) bool {
@@ -71,18 +153,164 @@ func isNil(v interface{}) bool {
 	return (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && rv.IsNil()
 }
 
-func (t ResourceType[T]) get(ctx context.Context, id types.ResourceId) (*types.Resource, *types.Error) {
+func (t ResourceType[T]) get(ctx context.Context, id types.ResourceId) (*types.Resource, string, *types.Error) {
 	if t.Get == nil {
 		err := errorForHTTPStatus(http.StatusMethodNotAllowed)
-		return nil, &err
+		return nil, "", &err
 	}
 
 	resource, err := t.Get(ctx, id.Id)
 	if err != nil || isNil(resource) {
-		return nil, err
+		return nil, "", err
+	}
+
+	return t.completeWithETag(ctx, id, resource)
+}
+
+func (t ResourceType[T]) list(ctx context.Context, typeName string, params url.Values) ([]types.Resource, types.Links, *types.Error) {
+	if t.List == nil {
+		err := errorForHTTPStatus(http.StatusMethodNotAllowed)
+		return nil, nil, &err
+	}
+
+	badRequest := func(detail string) *types.Error {
+		err := errorForHTTPStatus(http.StatusBadRequest)
+		err.Detail = detail
+		return &err
 	}
 
-	return t.complete(ctx, id, resource)
+	var after, before *listCursor
+	if v := params.Get("page[after]"); v != "" {
+		c := listCursor(v)
+		after = &c
+	}
+	if v := params.Get("page[before]"); v != "" {
+		c := listCursor(v)
+		before = &c
+	}
+
+	var first, last *int
+	if v := params.Get("page[size]"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, nil, badRequest("page[size] must be an integer.")
+		}
+		if before != nil && after == nil {
+			last = &size
+		} else {
+			first = &size
+		}
+	}
+
+	paginator := pagination.Paginator[listCursor]{
+		DefaultPageSize: t.DefaultPageSize,
+		MaxPageSize:     t.MaxPageSize,
+	}
+	window, err := paginator.Window(after, before, first, last)
+	if err != nil {
+		if windowErr, ok := err.(*pagination.WindowError); ok && windowErr.Reason == pagination.WindowErrorReasonPageSizeTooLarge {
+			return nil, nil, badRequest(fmt.Sprintf("page[size] cannot exceed %v.", windowErr.MaxPageSize))
+		}
+		return nil, nil, badRequest("page[size] cannot be negative.")
+	}
+
+	var edges []listEdge[T]
+	if !window.IsEmpty() {
+		var afterString, beforeString *string
+		if after != nil {
+			s := string(*after)
+			afterString = &s
+		}
+		if before != nil {
+			s := string(*before)
+			beforeString = &s
+		}
+		items, err := t.List(ctx, afterString, beforeString, window.Limit())
+		if err != nil {
+			return nil, nil, err
+		}
+		edges = make([]listEdge[T], len(items))
+		for i, item := range items {
+			edges[i] = listEdge[T]{item: item}
+		}
+	}
+	edges, pageInfo := pagination.Page(edges, window)
+
+	resources := make([]types.Resource, 0, len(edges))
+	for _, edge := range edges {
+		item := edge.item
+		resource, err := t.complete(ctx, types.ResourceId{Type: typeName, Id: item.Id}, item.Resource)
+		if err != nil {
+			return nil, nil, err
+		}
+		resources = append(resources, *resource)
+	}
+
+	pageSize := 0
+	if window.First != nil {
+		pageSize = *window.First
+	} else {
+		pageSize = *window.Last
+	}
+	links := types.Links{}
+	if pageInfo.HasNextPage && pageInfo.EndCursor != nil {
+		links["next"] = listPageLink(typeName, params, "page[after]", string(*pageInfo.EndCursor), pageSize)
+	}
+	if pageInfo.HasPreviousPage && pageInfo.StartCursor != nil {
+		links["prev"] = listPageLink(typeName, params, "page[before]", string(*pageInfo.StartCursor), pageSize)
+	}
+
+	return resources, links, nil
+}
+
+// Returns a link to another page of a list. The request's other query parameters, such as filters
+// and sparse fieldsets, are preserved.
+func listPageLink(typeName string, params url.Values, cursorParam, cursor string, pageSize int) string {
+	q := url.Values{}
+	for k, v := range params {
+		if k != "page[after]" && k != "page[before]" {
+			q[k] = v
+		}
+	}
+	q.Set(cursorParam, cursor)
+	q.Set("page[size]", strconv.Itoa(pageSize))
+	return "/" + typeName + "?" + q.Encode()
+}
+
+// Gets the entity tag for the resource's current version without completing it. If the resource
+// doesn't exist, false is returned. If the resource type doesn't define entity tags, an empty
+// string is returned.
+func (t ResourceType[T]) currentETag(ctx context.Context, id types.ResourceId) (string, bool, *types.Error) {
+	if t.Get == nil {
+		return "", true, nil
+	}
+
+	resource, err := t.Get(ctx, id.Id)
+	if err != nil {
+		return "", false, err
+	} else if isNil(resource) {
+		return "", false, nil
+	} else if t.ETag == nil {
+		return "", true, nil
+	}
+
+	etag, err := t.ETag(ctx, resource)
+	return etag, err == nil, err
+}
+
+func (t ResourceType[T]) completeWithETag(ctx context.Context, id types.ResourceId, resource T) (*types.Resource, string, *types.Error) {
+	ret, err := t.complete(ctx, id, resource)
+	if err != nil {
+		return nil, "", err
+	}
+
+	etag := ""
+	if t.ETag != nil {
+		if etag, err = t.ETag(ctx, resource); err != nil {
+			return nil, "", err
+		}
+	}
+	return ret, etag, nil
 }
 
 func addStandardRelationshipLinks(id types.ResourceId, name string, rel *types.Relationship) {
@@ -102,10 +330,15 @@ func (t ResourceType[T]) complete(ctx context.Context, id types.ResourceId, reso
 		Id:   id.Id,
 	}
 
+	rc := GetRequestContext(ctx)
+
 	if len(t.Attributes) > 0 {
 		ret.Attributes = make(map[string]any, len(t.Attributes))
 
 		for name, def := range t.Attributes {
+			if !rc.FieldRequested(id.Type, name) {
+				continue
+			}
 			if v, err := def.Resolver.ResolveAttribute(ctx, resource); err != nil {
 				return nil, err
 			} else {
@@ -118,7 +351,14 @@ func (t ResourceType[T]) complete(ctx context.Context, id types.ResourceId, reso
 		ret.Relationships = make(map[string]any, len(t.Relationships))
 
 		for name, def := range t.Relationships {
-			if rel, err := def.Resolver.ResolveRelationship(ctx, resource, false, nil); err != nil {
+			// Relationships that are traversed by include paths are always resolved with data so
+			// that the included resources can be found. They're removed afterwards if they weren't
+			// requested.
+			traversed := rc.traversesRelationship(name)
+			if !traversed && !rc.FieldRequested(id.Type, name) {
+				continue
+			}
+			if rel, err := def.Resolver.ResolveRelationship(ctx, resource, traversed, nil); err != nil {
 				return nil, err
 			} else {
 				addStandardRelationshipLinks(id, name, &rel)
@@ -127,35 +367,137 @@ func (t ResourceType[T]) complete(ctx context.Context, id types.ResourceId, reso
 		}
 	}
 
+	if t.Meta != nil {
+		if meta, err := t.Meta(ctx, resource); err != nil {
+			return nil, err
+		} else if len(meta) > 0 {
+			ret.Meta = meta
+		}
+	}
+
+	if t.Links != nil {
+		if links, err := t.Links(ctx, resource); err != nil {
+			return nil, err
+		} else if len(links) > 0 {
+			ret.Links = links
+		}
+	}
+
 	return &ret, nil
 }
 
-func (t ResourceType[T]) patch(ctx context.Context, id types.ResourceId, attributes map[string]json.RawMessage, relationships map[string]any) (*types.Resource, *types.Error) {
+func (t ResourceType[T]) patch(ctx context.Context, id types.ResourceId, attributes map[string]json.RawMessage, relationships map[string]any) (*types.Resource, string, *types.Error) {
 	if t.Patch == nil {
 		err := errorForHTTPStatus(http.StatusMethodNotAllowed)
-		return nil, &err
+		return nil, "", &err
+	}
+
+	ctx, err := t.decodeAttributes(ctx, attributes)
+	if err != nil {
+		return nil, "", err
 	}
 
 	resource, err := t.Patch(ctx, id.Id, attributes, relationships)
 	if err != nil || isNil(resource) {
-		return nil, err
+		return nil, "", err
 	}
 
-	return t.complete(ctx, id, resource)
+	return t.completeWithETag(ctx, id, resource)
+}
+
+// The result of a resource creation. Exactly one of Resource or StatusURL is given.
+type createResult struct {
+	Resource *types.Resource
+	ETag     string
+
+	// If true, the resource should be omitted from the response.
+	NoContent bool
+
+	// For asynchronous creations, the URL to monitor the creation's status.
+	StatusURL string
 }
 
-func (t ResourceType[T]) create(ctx context.Context, attributes map[string]json.RawMessage, relationships map[string]any) (*types.Resource, *types.Error) {
-	if t.Create == nil {
+func (t ResourceType[T]) create(ctx context.Context, id string, attributes map[string]json.RawMessage, relationships map[string]any) (*createResult, *types.Error) {
+	if t.Create == nil && t.CreateWithId == nil && t.CreateAsync == nil {
 		err := errorForHTTPStatus(http.StatusMethodNotAllowed)
 		return nil, &err
 	}
 
-	resource, id, err := t.Create(ctx, attributes, relationships)
+	if id != "" && !t.AllowClientIds {
+		// A server MUST return 403 Forbidden in response to an unsupported request to create a
+		// resource with a client-generated ID.
+		err := errorForHTTPStatus(http.StatusForbidden)
+		err.Detail = "Client-generated ids are not supported for this resource type."
+		return nil, &err
+	}
+
+	ctx, err := t.decodeAttributes(ctx, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.CreateAsync != nil {
+		statusURL, err := t.CreateAsync(ctx, id, attributes, relationships)
+		if err != nil {
+			return nil, err
+		}
+		return &createResult{
+			StatusURL: statusURL,
+		}, nil
+	}
+
+	var resource T
+	var resourceId types.ResourceId
+	if t.CreateWithId != nil {
+		resource, resourceId, err = t.CreateWithId(ctx, id, attributes, relationships)
+	} else {
+		resource, resourceId, err = t.Create(ctx, attributes, relationships)
+	}
 	if err != nil || isNil(resource) {
 		return nil, err
 	}
 
-	return t.complete(ctx, id, resource)
+	completed, etag, err := t.completeWithETag(ctx, resourceId, resource)
+	if err != nil {
+		return nil, err
+	}
+	return &createResult{
+		Resource:  completed,
+		ETag:      etag,
+		NoContent: t.CreateNoContent && id != "",
+	}, nil
+}
+
+// Decodes the given attributes that have decoders and returns a context from which their values can
+// be retrieved via AttributeValue.
+func (t ResourceType[T]) decodeAttributes(ctx context.Context, attributes map[string]json.RawMessage) (context.Context, *types.Error) {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		if def, ok := t.Attributes[name]; ok && def.Decoder != nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ctx, nil
+	}
+	sort.Strings(names)
+
+	decoded := make(map[string]any, len(names))
+	for _, name := range names {
+		value, err := t.Attributes[name].Decoder.DecodeAttribute(ctx, attributes[name])
+		if err != nil {
+			if err.Source == nil {
+				withSource := *err
+				withSource.Source = &types.ErrorSource{
+					Pointer: "/data/attributes/" + name,
+				}
+				err = &withSource
+			}
+			return ctx, err
+		}
+		decoded[name] = value
+	}
+	return context.WithValue(ctx, decodedAttributesContextKey, decoded), nil
 }
 
 func (t ResourceType[T]) delete(ctx context.Context, id types.ResourceId) *types.Error {
@@ -253,7 +595,63 @@ func (t ResourceType[T]) removeRelationshipMembers(ctx context.Context, id types
 	}
 }
 
+func (t ResourceType[T]) describe() *resourceTypeDescription {
+	ret := &resourceTypeDescription{
+		Get:             t.Get != nil,
+		List:            t.List != nil,
+		Patch:           t.Patch != nil,
+		Create:          t.Create != nil || t.CreateWithId != nil,
+		CreateAsync:     t.CreateAsync != nil,
+		CreateNoContent: t.CreateNoContent,
+		Delete:          t.Delete != nil,
+		ETag:            t.ETag != nil,
+		Attributes:      make(map[string]reflect.Type, len(t.Attributes)),
+		Relationships:   make(map[string]relationshipDescription, len(t.Relationships)),
+	}
+	for name, def := range t.Attributes {
+		var attributeType reflect.Type
+		if typed, ok := def.Resolver.(TypedAttributeResolver); ok {
+			attributeType = typed.AttributeType()
+		} else if typed, ok := def.Decoder.(TypedAttributeResolver); ok {
+			attributeType = typed.AttributeType()
+		}
+		ret.Attributes[name] = attributeType
+	}
+	for name, def := range t.Relationships {
+		desc := relationshipDescription{}
+		switch resolver := def.Resolver.(type) {
+		case ToOneRelationshipResolver[T], *ToOneRelationshipResolver[T]:
+			desc.ToOne = true
+		case ToManyRelationshipResolver[T]:
+			desc.ToMany = true
+			desc.AddMembers = resolver.AddMembers != nil
+			desc.RemoveMembers = resolver.RemoveMembers != nil
+		case *ToManyRelationshipResolver[T]:
+			desc.ToMany = true
+			desc.AddMembers = resolver.AddMembers != nil
+			desc.RemoveMembers = resolver.RemoveMembers != nil
+		default:
+			// We don't know the relationship's cardinality or whether members can be added or
+			// removed.
+			desc.AddMembers = true
+			desc.RemoveMembers = true
+		}
+		ret.Relationships[name] = desc
+	}
+	return ret
+}
+
 func (t ResourceType[T]) validate() error {
+	if t.List != nil && t.DefaultPageSize <= 0 {
+		return fmt.Errorf("resource types with List must have a positive DefaultPageSize")
+	} else if (t.Create != nil && t.CreateWithId != nil) || (t.Create != nil && t.CreateAsync != nil) || (t.CreateWithId != nil && t.CreateAsync != nil) {
+		return fmt.Errorf("resource types can only have one of Create, CreateWithId, and CreateAsync")
+	} else if t.AllowClientIds && t.CreateWithId == nil && t.CreateAsync == nil {
+		return fmt.Errorf("AllowClientIds requires CreateWithId or CreateAsync")
+	} else if t.CreateNoContent && !t.AllowClientIds {
+		return fmt.Errorf("CreateNoContent requires AllowClientIds")
+	}
+
 	for name, def := range t.Attributes {
 		if name == "id" || name == "type" {
 			return fmt.Errorf("illegal attribute name: %v", name)
@@ -1,9 +1,13 @@
 package jsonapi
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/ccbrown/api-fu/jsonapi/types"
 )
 
 func TestSchemaValidation(t *testing.T) {
@@ -31,6 +35,44 @@ func TestSchemaValidation(t *testing.T) {
 			},
 			Okay: false,
 		},
+		"CreateNoContentWithoutClientIds": {
+			In: &SchemaDefinition{
+				ResourceTypes: map[string]AnyResourceType{
+					"articles": ResourceType[struct{}]{
+						CreateNoContent: true,
+					},
+				},
+			},
+			Okay: false,
+		},
+		"ClientIdsWithoutCreateWithId": {
+			In: &SchemaDefinition{
+				ResourceTypes: map[string]AnyResourceType{
+					"articles": ResourceType[struct{}]{
+						Create: func(ctx context.Context, attributes map[string]json.RawMessage, relationships map[string]any) (struct{}, types.ResourceId, *types.Error) {
+							return struct{}{}, types.ResourceId{}, nil
+						},
+						AllowClientIds: true,
+					},
+				},
+			},
+			Okay: false,
+		},
+		"CreateAndCreateWithId": {
+			In: &SchemaDefinition{
+				ResourceTypes: map[string]AnyResourceType{
+					"articles": ResourceType[struct{}]{
+						Create: func(ctx context.Context, attributes map[string]json.RawMessage, relationships map[string]any) (struct{}, types.ResourceId, *types.Error) {
+							return struct{}{}, types.ResourceId{}, nil
+						},
+						CreateWithId: func(ctx context.Context, id string, attributes map[string]json.RawMessage, relationships map[string]any) (struct{}, types.ResourceId, *types.Error) {
+							return struct{}{}, types.ResourceId{}, nil
+						},
+					},
+				},
+			},
+			Okay: false,
+		},
 	} {
 		t.Run(name, func(t *testing.T) {
 			_, err := NewSchema(tc.In)
@@ -22,6 +22,10 @@ type ResponseDocument struct {
 
 	// A links object related to the primary data.
 	Links Links `json:"links,omitempty"`
+
+	// An array of resource objects that are related to the primary data and/or each other
+	// (“included resources”).
+	Included []Resource `json:"included,omitempty"`
 }
 
 type JSONAPI struct {
@@ -167,6 +171,9 @@ type PostResourceRequest struct {
 type PostResourceRequestData struct {
 	Type string `json:"type"`
 
+	// A client-generated id for the new resource, if any.
+	Id string `json:"id,omitempty"`
+
 	// An object containing the attributes to be updated.
 	Attributes map[string]json.RawMessage `json:"attributes,omitempty"`
 
@@ -0,0 +1,243 @@
+package apifu
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+// EncodeGlobalID encodes a type name and a type-specific id into an opaque global id.
+func EncodeGlobalID(typeName string, localID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(typeName + ":" + localID))
+}
+
+// DecodeGlobalID decodes a global id that was encoded with EncodeGlobalID. If the id is malformed,
+// ok will be false.
+func DecodeGlobalID(id string) (typeName string, localID string, ok bool) {
+	b, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return "", "", false
+	}
+	parts := strings.SplitN(string(b), ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// EncodeID encodes a type-specific id into a global id for the GraphQL type that represents the Go
+// type T. The type name is the name of T, with any pointers removed, unless T or *T has a
+// GraphQLTypeName method, in which case its result is used:
+//
+//	func (User) GraphQLTypeName() string { return "User" }
+//
+// The result is equivalent to EncodeGlobalID's.
+func EncodeID[T any](localID string) string {
+	return EncodeGlobalID(idTypeName[T](), localID)
+}
+
+// DecodeID decodes a global id that was encoded for the GraphQL type that represents the Go type T.
+// See EncodeID. If the id is malformed or belongs to a different type, a *NotFoundError is returned,
+// which resolvers can return as is. This prevents the id of one type from being used to look up an
+// object of another type.
+func DecodeID[T any](id string) (string, error) {
+	expectedTypeName := idTypeName[T]()
+	typeName, localID, ok := DecodeGlobalID(id)
+	if !ok || typeName != expectedTypeName {
+		return "", &NotFoundError{
+			TypeName: expectedTypeName,
+			Key:      id,
+		}
+	}
+	return localID, nil
+}
+
+func idTypeName[T any]() string {
+	type typeNamer interface {
+		GraphQLTypeName() string
+	}
+	var zero T
+	if namer, ok := any(zero).(typeNamer); ok {
+		return namer.GraphQLTypeName()
+	} else if namer, ok := any(&zero).(typeNamer); ok {
+		return namer.GraphQLTypeName()
+	}
+	return normalizeModelType(reflect.TypeOf(&zero).Elem()).Name()
+}
+
+// Used by the node fields if Config.StrictNodeIDs is true. Resolves the nodes for the ids that were
+// encoded via EncodeGlobalID for object types. The ids are resolved in groups by type so that each
+// resolved node can be checked against the type encoded in the ids it was resolved for.
+func (api *API) resolveStrictNodes(ctx context.Context, ids []string) ([]interface{}, error) {
+	var types []*graphql.ObjectType
+	idsByType := map[*graphql.ObjectType][]string{}
+	for _, id := range ids {
+		typeName, _, ok := DecodeGlobalID(id)
+		if !ok {
+			continue
+		}
+		t, ok := api.schema.NamedTypes()[typeName].(*graphql.ObjectType)
+		if !ok || (t.IsTypeOf == nil && api.config.NodeInterface().ResolveType == nil) {
+			continue
+		}
+		if _, ok := idsByType[t]; !ok {
+			types = append(types, t)
+		}
+		idsByType[t] = append(idsByType[t], id)
+	}
+
+	ret := []interface{}{}
+	for _, t := range types {
+		nodes, err := api.config.ResolveNodesByGlobalIds(ctx, idsByType[t])
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			if api.isNodeOfType(ctx, node, t) {
+				ret = append(ret, node)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// Returns true if the node is of the given type, as determined by the Node interface's ResolveType
+// function if given or the type's IsTypeOf function otherwise.
+func (api *API) isNodeOfType(ctx context.Context, node interface{}, t *graphql.ObjectType) bool {
+	if resolveType := api.config.NodeInterface().ResolveType; resolveType != nil {
+		resolved := resolveType(ctx, node)
+		return resolved != nil && resolved.Name == t.Name
+	}
+	return t.IsTypeOf != nil && t.IsTypeOf(node)
+}
+
+// GlobalID returns a non-null "id" field definition that resolves to a global id for the object
+// with the given type name. The object's type-specific id is taken from the field with the given
+// name and encoded via EncodeGlobalID.
+func GlobalID(typeName string, fieldName string) *graphql.FieldDefinition {
+	return &graphql.FieldDefinition{
+		Type:        graphql.NewNonNullType(graphql.IDType),
+		Description: "The global id of the node.",
+		Cost:        graphql.FieldResolverCost(0),
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return EncodeGlobalID(typeName, fmt.Sprint(fieldValue(ctx.Object, fieldName))), nil
+		},
+	}
+}
+
+// NotFoundError is returned by lookup fields when no object exists for the given key. Its
+// extensions contain a "code" of "NOT_FOUND", a "retryable" of false, and the type name and key.
+type NotFoundError struct {
+	TypeName string
+	Key      interface{}
+}
+
+func (err *NotFoundError) Error() string {
+	return fmt.Sprintf("%v not found.", err.TypeName)
+}
+
+func (err *NotFoundError) Extensions() map[string]interface{} {
+	ret := graphql.ErrorCodeNotFound.Extensions()
+	ret["typeName"] = err.TypeName
+	ret["key"] = err.Key
+	return ret
+}
+
+// LookupFieldConfig defines the configuration for a field that looks up an object by a natural key,
+// such as a user's email address.
+type LookupFieldConfig struct {
+	// The type of the object. This is typically an object type that implements the Node interface,
+	// or the Node interface itself. The field will be nullable regardless of whether this type is
+	// non-null.
+	Type graphql.Type
+
+	// An optional description for the field.
+	Description string
+
+	// An optional deprecation reason for the field.
+	DeprecationReason string
+
+	// The name of the key argument, e.g. "email".
+	KeyArgument string
+
+	// The type of the key argument. If nil, String! is used.
+	KeyType graphql.Type
+
+	// An optional description for the key argument.
+	KeyDescription string
+
+	// ResolveByKeys should return the objects for the given keys. Lookups made within the same
+	// request are batched, so keys may contain multiple values. The returned slice must have one
+	// item per key, with nil items indicating that no object exists for the key.
+	ResolveByKeys func(ctx context.Context, keys []interface{}) ([]interface{}, error)
+
+	// If true, the field resolves to null without an error for keys that don't exist. Otherwise a
+	// NotFoundError is returned.
+	NullIfNotFound bool
+
+	// This field is only available for introspection and use when the given features are enabled.
+	RequiredFeatures graphql.FeatureSet
+}
+
+// LookupField returns a field definition that looks up objects by natural key. Lookups are batched
+// as if by Batch, so multiple lookups within a query result in a single ResolveByKeys invocation.
+func LookupField(config *LookupFieldConfig) *graphql.FieldDefinition {
+	keyType := config.KeyType
+	if keyType == nil {
+		keyType = graphql.NewNonNullType(graphql.StringType)
+	}
+
+	t := config.Type
+	if nonNull, ok := t.(*graphql.NonNullType); ok {
+		t = nonNull.Type
+	}
+
+	return &graphql.FieldDefinition{
+		Type:              t,
+		Description:       config.Description,
+		DeprecationReason: config.DeprecationReason,
+		RequiredFeatures:  config.RequiredFeatures,
+		Arguments: map[string]*graphql.InputValueDefinition{
+			config.KeyArgument: {
+				Type:        keyType,
+				Description: config.KeyDescription,
+			},
+		},
+		Resolve: Batch(func(ctxs []graphql.FieldContext) []graphql.ResolveResult {
+			ret := make([]graphql.ResolveResult, len(ctxs))
+
+			keys := make([]interface{}, len(ctxs))
+			for i, ctx := range ctxs {
+				keys[i] = ctx.Arguments[config.KeyArgument]
+			}
+
+			objects, err := config.ResolveByKeys(ctxs[0].Context, keys)
+			if err == nil && len(objects) != len(keys) {
+				err = fmt.Errorf("lookup returned %v objects for %v keys", len(objects), len(keys))
+			}
+			if err != nil {
+				for i := range ret {
+					ret[i].Error = err
+				}
+				return ret
+			}
+
+			for i, object := range objects {
+				if !isNil(object) {
+					ret[i].Value = object
+				} else if !config.NullIfNotFound {
+					ret[i].Error = &NotFoundError{
+						TypeName: schema.UnwrappedType(t).TypeName(),
+						Key:      keys[i],
+					}
+				}
+			}
+			return ret
+		}),
+	}
+}
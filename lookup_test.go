@@ -0,0 +1,198 @@
+package apifu
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestGlobalID(t *testing.T) {
+	id := EncodeGlobalID("User", "a:b")
+	typeName, localID, ok := DecodeGlobalID(id)
+	assert.True(t, ok)
+	assert.Equal(t, "User", typeName)
+	assert.Equal(t, "a:b", localID)
+
+	_, _, ok = DecodeGlobalID("!")
+	assert.False(t, ok)
+
+	_, _, ok = DecodeGlobalID(EncodeGlobalID("", "a"))
+	assert.False(t, ok)
+}
+
+func TestLookupField(t *testing.T) {
+	type user struct {
+		ID    int
+		Email string
+	}
+
+	users := map[string]*user{
+		"alice@example.com": {ID: 1, Email: "alice@example.com"},
+		"bob@example.com":   {ID: 2, Email: "bob@example.com"},
+	}
+
+	var testCfg Config
+
+	userType := &graphql.ObjectType{
+		Name: "User",
+		Fields: map[string]*graphql.FieldDefinition{
+			"id":    GlobalID("User", "ID"),
+			"email": NonNull(graphql.StringType, "Email"),
+		},
+		ImplementedInterfaces: []*graphql.InterfaceType{testCfg.NodeInterface()},
+		IsTypeOf: func(v interface{}) bool {
+			_, ok := v.(*user)
+			return ok
+		},
+	}
+
+	var batches [][]interface{}
+	testCfg.AddQueryField("userByEmail", LookupField(&LookupFieldConfig{
+		Type:        userType,
+		KeyArgument: "email",
+		ResolveByKeys: func(ctx context.Context, keys []interface{}) ([]interface{}, error) {
+			batches = append(batches, keys)
+			ret := make([]interface{}, len(keys))
+			for i, key := range keys {
+				if u, ok := users[key.(string)]; ok {
+					ret[i] = u
+				}
+			}
+			return ret, nil
+		},
+	}))
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	resp := executeGraphQL(t, api, `{
+		alice: userByEmail(email: "alice@example.com") { id email }
+		bob: userByEmail(email: "bob@example.com") { email }
+		carol: userByEmail(email: "carol@example.com") { email }
+	}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"data": {
+			"alice": {"id": "`+EncodeGlobalID("User", "1")+`", "email": "alice@example.com"},
+			"bob": {"email": "bob@example.com"},
+			"carol": null
+		},
+		"errors": [
+			{
+				"message": "User not found.",
+				"locations": [{"line": 4, "column": 3}],
+				"path": ["carol"],
+				"extensions": {"code": "NOT_FOUND", "retryable": false, "typeName": "User", "key": "carol@example.com"}
+			}
+		]
+	}`, string(body))
+
+	require.Len(t, batches, 1)
+	assert.ElementsMatch(t, []interface{}{"alice@example.com", "bob@example.com", "carol@example.com"}, batches[0])
+}
+
+type testIDUser struct{}
+
+type testIDChannel struct{}
+
+func (*testIDChannel) GraphQLTypeName() string {
+	return "Channel"
+}
+
+func TestEncodeID(t *testing.T) {
+	assert.Equal(t, EncodeGlobalID("testIDUser", "1"), EncodeID[testIDUser]("1"))
+	assert.Equal(t, EncodeGlobalID("testIDUser", "1"), EncodeID[*testIDUser]("1"))
+	assert.Equal(t, EncodeGlobalID("Channel", "1"), EncodeID[testIDChannel]("1"))
+	assert.Equal(t, EncodeGlobalID("Channel", "1"), EncodeID[*testIDChannel]("1"))
+
+	localID, err := DecodeID[testIDUser](EncodeID[testIDUser]("a:b"))
+	require.NoError(t, err)
+	assert.Equal(t, "a:b", localID)
+
+	for name, id := range map[string]string{
+		"WrongType": EncodeID[testIDChannel]("1"),
+		"Malformed": "!",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeID[testIDUser](id)
+			require.IsType(t, &NotFoundError{}, err)
+			assert.Equal(t, "testIDUser", err.(*NotFoundError).TypeName)
+			assert.Equal(t, id, err.(*NotFoundError).Key)
+		})
+	}
+}
+
+func TestStrictNodeIDs(t *testing.T) {
+	type user struct {
+		ID string
+	}
+
+	var resolvedIDs []string
+	testCfg := Config{
+		StrictNodeIDs: true,
+		// This resolver ignores the ids' types, so any valid local id resolves to a user.
+		ResolveNodesByGlobalIds: func(ctx context.Context, ids []string) ([]interface{}, error) {
+			var ret []interface{}
+			for _, id := range ids {
+				resolvedIDs = append(resolvedIDs, id)
+				if _, localID, _ := DecodeGlobalID(id); localID != "missing" {
+					ret = append(ret, &user{ID: localID})
+				}
+			}
+			return ret, nil
+		},
+	}
+	testCfg.AddNamedType(&graphql.ObjectType{
+		Name: "User",
+		Fields: map[string]*graphql.FieldDefinition{
+			"id": GlobalID("User", "ID"),
+		},
+		ImplementedInterfaces: []*graphql.InterfaceType{testCfg.NodeInterface()},
+		IsTypeOf: func(value interface{}) bool {
+			_, ok := value.(*user)
+			return ok
+		},
+	})
+	testCfg.AddNamedType(&graphql.ObjectType{
+		Name: "Channel",
+		Fields: map[string]*graphql.FieldDefinition{
+			"id": GlobalID("Channel", "ID"),
+		},
+		ImplementedInterfaces: []*graphql.InterfaceType{testCfg.NodeInterface()},
+		IsTypeOf: func(value interface{}) bool {
+			return false
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	userID := EncodeGlobalID("User", "1")
+	channelID := EncodeGlobalID("Channel", "1")
+	// The user for this id doesn't exist, but the user resolved for the channel id must not take
+	// its place.
+	missingUserID := EncodeGlobalID("User", "missing")
+	resp := executeGraphQL(t, api, `{
+		user: node(id: "`+userID+`") { id }
+		channel: node(id: "`+channelID+`") { id }
+		malformed: node(id: "!") { id }
+		unknownType: node(id: "`+EncodeGlobalID("Foo", "1")+`") { id }
+		nodes(ids: ["`+userID+`", "`+channelID+`", "!"]) { id }
+		mismatched: nodes(ids: ["`+missingUserID+`", "`+channelID+`"]) { id }
+	}`)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"user":{"id":"`+userID+`"},"channel":null,"malformed":null,"unknownType":null,"nodes":[{"id":"`+userID+`"}],"mismatched":[]}}`, string(body))
+
+	// Malformed ids and ids for unknown types never reach the resolver.
+	assert.ElementsMatch(t, []string{userID, channelID, userID, channelID, missingUserID, channelID}, resolvedIDs)
+}
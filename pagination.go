@@ -2,14 +2,13 @@ package apifu
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
-	"github.com/vmihailenco/msgpack"
 
 	"github.com/ccbrown/api-fu/graphql"
 	"github.com/ccbrown/api-fu/graphql/schema"
@@ -24,6 +23,18 @@ const (
 	ConnectionDirectionBackwardOnly
 )
 
+func (d ConnectionDirection) String() string {
+	switch d {
+	case ConnectionDirectionBidirectional:
+		return "bidirectional"
+	case ConnectionDirectionForwardOnly:
+		return "forward-only"
+	case ConnectionDirectionBackwardOnly:
+		return "backward-only"
+	}
+	return fmt.Sprintf("ConnectionDirection(%d)", int(d))
+}
+
 // ConnectionConfig defines the configuration for a connection that adheres to the GraphQL Cursor
 // Connections Specification.
 type ConnectionConfig struct {
@@ -39,12 +50,24 @@ type ConnectionConfig struct {
 	DeprecationReason string
 
 	// The direction of the connection. This determines which of the first/last/before/after
-	// arguments are defined on the connection.
+	// arguments are defined on the connection. If the connection is bidirectional and implements a
+	// connection interface with a different direction, the interface's direction is inherited.
 	Direction ConnectionDirection
 
 	// An optional map of additional arguments to add to the connection.
 	Arguments map[string]*graphql.InputValueDefinition
 
+	// An optional cost function for the connection field. See ConnectionFieldDefinitionConfig.Cost.
+	Cost func(graphql.FieldCostContext) graphql.FieldCost
+
+	// If non-zero, this is the page size used when neither `first` nor `last` is given. See
+	// ConnectionFieldDefinitionConfig.DefaultPageSize.
+	DefaultPageSize int
+
+	// If non-zero, requests for more edges than this result in an error. This is enforced before
+	// ResolveEdges or ResolveAllEdges is invoked.
+	MaxPageSize int
+
 	// If getting all edges for the connection is cheap, you can just provide ResolveAllEdges.
 	// ResolveAllEdges should return a slice value, with one item for each edge, and a function that
 	// can be used to sort the cursors produced by EdgeCursor.
@@ -79,8 +102,18 @@ type ConnectionConfig struct {
 	// should return the type of cursor assigned to CursorType.
 	EdgeCursor func(edge any) any
 
+	// If non-zero, this is the maximum length that the connection's serialized cursors should have,
+	// e.g. to keep them within URL length limits. When the connection is defined, a warning is
+	// emitted if cursors of CursorType may exceed it. Compression isn't taken into account.
+	MaxCursorLength int
+
+	// If given, this is invoked with the warning emitted for MaxCursorLength. Otherwise the warning
+	// is discarded.
+	CursorLengthWarning func(warning string)
+
 	// EdgeFields should provide definitions for the fields of each node. You must provide the
-	// "node" field, but the "cursor" field will be provided for you.
+	// "node" field, but the "cursor" field will be provided for you. The costs of these fields are
+	// automatically multiplied by the requested page size.
 	EdgeFields map[string]*graphql.FieldDefinition
 
 	// The connection will implement these interfaces. If any of the interfaces define an edge
@@ -89,27 +122,60 @@ type ConnectionConfig struct {
 
 	// This connection is only available for introspection and use when the given features are enabled.
 	RequiredFeatures graphql.FeatureSet
+
+	// If given, this is invoked to get the message for errors caused by invalid arguments, such as
+	// malformed cursors. The default message is available via err.Message. This can be used to
+	// localize messages.
+	ArgumentErrorMessage func(ctx context.Context, err *ConnectionArgumentError) string
 }
 
-// SerializeCursor serializes a cursor to a string that can be used in a response.
-func SerializeCursor(cursor any) (string, error) {
-	b, err := msgpack.Marshal(cursor)
-	if err != nil {
-		return "", err
+const (
+	// A connection's "after" or "before" argument isn't a valid cursor.
+	ErrorCodeInvalidCursor graphql.ErrorCode = "INVALID_CURSOR"
+
+	// A connection's "first" or "last" argument is missing or invalid.
+	ErrorCodeInvalidPageSize graphql.ErrorCode = "INVALID_PAGE_SIZE"
+)
+
+// ConnectionArgumentError is returned by connection resolvers when their arguments are invalid. The
+// error's extensions include its "code" and the name of the offending "argument" so that clients
+// can handle it without matching the message.
+type ConnectionArgumentError struct {
+	// Either ErrorCodeInvalidCursor or ErrorCodeInvalidPageSize.
+	Code graphql.ErrorCode
+
+	// The name of the invalid argument, e.g. "after".
+	Argument string
+
+	// If the error is for the "first" or "last" argument, this describes the problem.
+	WindowError *pagination.WindowError
+
+	Message string
+}
+
+func (err *ConnectionArgumentError) Error() string {
+	return err.Message
+}
+
+func (err *ConnectionArgumentError) Extensions() map[string]any {
+	return map[string]any{
+		"code":      string(err.Code),
+		"retryable": err.Code.IsRetryable(),
+		"argument":  err.Argument,
 	}
-	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SerializeCursor serializes a cursor to a string that can be used in a response. Cursors aren't
+// compressed. See CursorCompressionConfig.SerializeCursor.
+func SerializeCursor(cursor any) (string, error) {
+	return (*CursorCompressionConfig)(nil).SerializeCursor(cursor)
 }
 
 // DeserializeCursor deserializes a cursor that was previously serialized with SerializeCursor or
-// returns nil if the cursor is invalid.
+// returns nil if the cursor is invalid. Compressed cursors are rejected. See
+// CursorCompressionConfig.DeserializeCursor.
 func DeserializeCursor(t reflect.Type, s string) any {
-	ret := reflect.New(t)
-	if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
-		if err := msgpack.Unmarshal(b, ret.Interface()); err == nil {
-			return ret.Elem().Interface()
-		}
-	}
-	return nil
+	return (*CursorCompressionConfig)(nil).DeserializeCursor(t, s)
 }
 
 // PageInfo represents the page info of a GraphQL Cursor Connection.
@@ -143,8 +209,8 @@ var PageInfoType = &graphql.ObjectType{
 		"startCursor": {
 			// XXX: In the latest Relay spec, this is nullable
 			// (https://github.com/facebook/relay/pull/2655). However, it would technically be a
-			// breaking change to make it nullable here now.
-			// TODO: Update them if there's an opportunity to safely do so.
+			// breaking change to make it nullable here now, so it's only nullable for APIs that
+			// opt in via Config.NullablePageInfoCursors.
 			Type:        graphql.NewNonNullType(graphql.StringType),
 			Cost:        graphql.FieldResolverCost(0),
 			Description: "This is the cursor of the first edge in the current page.",
@@ -164,6 +230,31 @@ var PageInfoType = &graphql.ObjectType{
 	},
 }
 
+// Makes the PageInfo type's cursors nullable, as required by the latest Relay spec. The cursors
+// are null for empty pages. The definition must be a clone, as the type is modified in place.
+func makePageInfoCursorsNullable(def *graphql.SchemaDefinition) {
+	inspectNamedTypesOnce(def, func(node any) bool {
+		if obj, ok := node.(*graphql.ObjectType); ok && obj.Name == PageInfoType.Name {
+			for _, name := range []string{"startCursor", "endCursor"} {
+				field, ok := obj.Fields[name]
+				if !ok {
+					continue
+				}
+				resolve := field.Resolve
+				field.Type = graphql.StringType
+				field.Resolve = func(ctx graphql.FieldContext) (any, error) {
+					v, err := resolve(ctx)
+					if s, ok := v.(string); ok && s == "" {
+						return nil, err
+					}
+					return v, err
+				}
+			}
+		}
+		return true
+	})
+}
+
 // Defines the configuration for a connection interface.
 type ConnectionInterfaceConfig struct {
 	// A prefix to use for the connection and edge type names. For example, if you provide
@@ -178,10 +269,113 @@ type ConnectionInterfaceConfig struct {
 	// If true, implementations must provide the "totalCount" field.
 	HasTotalCount bool
 
+	// The direction of the connection's pagination arguments. Bidirectional connection fields and
+	// connections that use this interface will inherit this direction.
+	Direction ConnectionDirection
+
+	// If true, mismatches between the interface and its implementations, such as fields with
+	// pagination arguments of a different direction or a missing totalCount field, are reported as
+	// errors when the API's schema is built.
+	ValidateImplementations bool
+
 	// This connection is only available for introspection and use when the given features are enabled.
 	RequiredFeatures graphql.FeatureSet
 }
 
+type connectionInterfaceConfigKeyType int
+
+// Interfaces created via ConnectionInterface have their configs in their metadata under this key.
+var connectionInterfaceConfigKey connectionInterfaceConfigKeyType
+
+func lookupConnectionInterfaceConfig(t graphql.Type) *ConnectionInterfaceConfig {
+	if iface, ok := schema.UnwrappedType(t).(*graphql.InterfaceType); ok {
+		config, _ := iface.Metadata[connectionInterfaceConfigKey].(*ConnectionInterfaceConfig)
+		return config
+	}
+	return nil
+}
+
+// Returns the direction of a connection field's pagination arguments. If the arguments don't
+// include any pagination arguments, false is returned.
+func connectionArgumentsDirection(arguments map[string]*graphql.InputValueDefinition) (ConnectionDirection, bool) {
+	_, hasFirst := arguments["first"]
+	_, hasLast := arguments["last"]
+	if hasFirst && hasLast {
+		return ConnectionDirectionBidirectional, true
+	} else if hasFirst {
+		return ConnectionDirectionForwardOnly, true
+	} else if hasLast {
+		return ConnectionDirectionBackwardOnly, true
+	}
+	return 0, false
+}
+
+// Makes sure that connection fields are compatible with the connection interfaces they're
+// associated with if the interfaces opted in via ValidateImplementations.
+func validateConnectionFields(def *graphql.SchemaDefinition) error {
+	namedTypes := map[string]graphql.NamedType{}
+	schema.Inspect(def, func(node any) bool {
+		if t, ok := node.(graphql.NamedType); ok {
+			if _, ok := namedTypes[t.TypeName()]; ok {
+				return false
+			}
+			namedTypes[t.TypeName()] = t
+		}
+		return true
+	})
+
+	names := make([]string, 0, len(namedTypes))
+	for name := range namedTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var fields map[string]*graphql.FieldDefinition
+		switch t := namedTypes[name].(type) {
+		case *graphql.ObjectType:
+			fields = t.Fields
+		case *graphql.InterfaceType:
+			fields = t.Fields
+		}
+
+		fieldNames := make([]string, 0, len(fields))
+		for name := range fields {
+			fieldNames = append(fieldNames, name)
+		}
+		sort.Strings(fieldNames)
+
+		for _, fieldName := range fieldNames {
+			field := fields[fieldName]
+			var ifaces []*graphql.InterfaceType
+			switch t := schema.UnwrappedType(field.Type).(type) {
+			case *graphql.ObjectType:
+				ifaces = t.ImplementedInterfaces
+			case *graphql.InterfaceType:
+				ifaces = []*graphql.InterfaceType{t}
+			}
+			for _, iface := range ifaces {
+				ifaceConfig := lookupConnectionInterfaceConfig(iface)
+				if ifaceConfig == nil || !ifaceConfig.ValidateImplementations {
+					continue
+				}
+				connectionName := schema.UnwrappedType(field.Type).TypeName()
+				if direction, ok := connectionArgumentsDirection(field.Arguments); !ok {
+					return fmt.Errorf("%v.%v returns %v, which implements %v, but it has no pagination arguments", name, fieldName, connectionName, iface.Name)
+				} else if direction != ifaceConfig.Direction {
+					return fmt.Errorf("%v.%v returns %v, which implements %v, but its arguments are %v while the interface is %v", name, fieldName, connectionName, iface.Name, direction, ifaceConfig.Direction)
+				}
+				if obj, ok := schema.UnwrappedType(field.Type).(*graphql.ObjectType); ok && ifaceConfig.HasTotalCount {
+					if _, ok := obj.Fields["totalCount"]; !ok {
+						return fmt.Errorf("%v implements %v, which requires totalCount, but it doesn't provide ResolveTotalCount or ResolveAllEdges", obj.Name, iface.Name)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
 var forwardConnectionArguments = map[string]*graphql.InputValueDefinition{
 	"first": {
 		Type:        graphql.NewNonNullType(graphql.IntType),
@@ -223,14 +417,40 @@ var bidirectionalConnectionArguments = map[string]*graphql.InputValueDefinition{
 	},
 }
 
-func defaultConnectionCost(ctx graphql.FieldCostContext) graphql.FieldCost {
-	maxCount, _ := ctx.Arguments["first"].(int)
-	if last, ok := ctx.Arguments["last"].(int); ok {
-		maxCount = last
+// Returns a cost function for a connection field. The returned function makes the number of
+// requested edges available to the connection's "edges" field, which uses it as a multiplier so that
+// the costs of edge fields and any connections nested within them are multiplied by the page size.
+//
+// If cost is nil, the connection has a resolver cost of 1.
+func connectionCost(cost func(graphql.FieldCostContext) graphql.FieldCost, defaultPageSize int) func(graphql.FieldCostContext) graphql.FieldCost {
+	return func(ctx graphql.FieldCostContext) graphql.FieldCost {
+		maxCount, ok := ctx.Arguments["first"].(int)
+		if last, hasLast := ctx.Arguments["last"].(int); hasLast {
+			maxCount = last
+		} else if !ok {
+			maxCount = defaultPageSize
+		}
+		ret := graphql.FieldCost{
+			Resolver: 1,
+		}
+		if cost != nil {
+			ret = cost(ctx)
+		}
+		parent := ret.Context
+		if parent == nil {
+			parent = ctx.Context
+		}
+		ret.Context = context.WithValue(parent, maxEdgeCountContextKey, maxCount)
+		return ret
 	}
+}
+
+// The cost function for a connection's "edges" field.
+func edgesCost(ctx graphql.FieldCostContext) graphql.FieldCost {
+	maxCount, _ := ctx.Context.Value(maxEdgeCountContextKey).(int)
 	return graphql.FieldCost{
-		Context:  context.WithValue(ctx.Context, maxEdgeCountContextKey, maxCount),
-		Resolver: 1,
+		Resolver:   0,
+		Multiplier: maxCount,
 	}
 }
 
@@ -265,12 +485,7 @@ func ConnectionInterface(config *ConnectionInterfaceConfig) *graphql.InterfaceTy
 			"edges": {
 				Type:        graphql.NewNonNullType(graphql.NewListType(graphql.NewNonNullType(edge))),
 				Description: edgesDesc,
-				Cost: func(ctx graphql.FieldCostContext) graphql.FieldCost {
-					return graphql.FieldCost{
-						Resolver:   0,
-						Multiplier: ctx.Context.Value(maxEdgeCountContextKey).(int),
-					}
-				},
+				Cost:        edgesCost,
 			},
 			"pageInfo": {
 				Type: graphql.NewNonNullType(PageInfoType),
@@ -290,6 +505,11 @@ func ConnectionInterface(config *ConnectionInterfaceConfig) *graphql.InterfaceTy
 		}
 	}
 
+	configCopy := *config
+	ret.Metadata = map[interface{}]interface{}{
+		connectionInterfaceConfigKey: &configCopy,
+	}
+
 	return ret
 }
 
@@ -298,7 +518,8 @@ type ConnectionFieldDefinitionConfig struct {
 	// The type of the connection.
 	Type graphql.Type
 
-	// The direction of the connection.
+	// The direction of the connection. If the connection is bidirectional and Type is an interface
+	// created via ConnectionInterface, the interface's direction is inherited.
 	Direction ConnectionDirection
 
 	// An optional description for the connection field.
@@ -310,21 +531,56 @@ type ConnectionFieldDefinitionConfig struct {
 	// An optional map of additional arguments to add to the field.
 	Arguments map[string]*graphql.InputValueDefinition
 
+	// An optional cost function for the connection field. If nil, the field has a resolver cost of
+	// 1. In either case, the costs of the connection's edge fields are multiplied by the requested
+	// page size, including any connections nested within them.
+	Cost func(graphql.FieldCostContext) graphql.FieldCost
+
+	// If non-zero, this is the page size used when neither `first` nor `last` is given, making
+	// those arguments optional. For forward-only and backward-only connections, this is the default
+	// value of the `first` or `last` argument. For bidirectional connections, the first N edges are
+	// returned.
+	DefaultPageSize int
+
+	// If non-zero, this is the maximum value for the `first` and `last` arguments. It's mentioned in
+	// the arguments' descriptions, and connections created via Connection enforce it.
+	MaxPageSize int
+
 	// This connection is only available for introspection and use when the given features are enabled.
 	RequiredFeatures graphql.FeatureSet
 }
 
+// Returns a copy of a page size argument that reflects the given default and maximum page sizes.
+func pageSizeArgument(def *graphql.InputValueDefinition, direction ConnectionDirection, defaultPageSize, maxPageSize int) *graphql.InputValueDefinition {
+	ret := *def
+	if defaultPageSize > 0 {
+		if direction == ConnectionDirectionBidirectional {
+			ret.Description = strings.Replace(ret.Description, " You must provide either `first` or `last`.", "", 1)
+		} else {
+			ret.DefaultValue = defaultPageSize
+		}
+	}
+	if maxPageSize > 0 {
+		ret.Description += fmt.Sprintf(" The maximum is %v.", maxPageSize)
+	}
+	return &ret
+}
+
 // Returns a minimal connection field definition, with default arguments and cost function defined.
 func ConnectionFieldDefinition(config *ConnectionFieldDefinitionConfig) *graphql.FieldDefinition {
 	ret := &graphql.FieldDefinition{
 		Type:              config.Type,
 		Arguments:         map[string]*graphql.InputValueDefinition{},
-		Cost:              defaultConnectionCost,
+		Cost:              connectionCost(config.Cost, config.DefaultPageSize),
 		Description:       config.Description,
 		DeprecationReason: config.DeprecationReason,
 		RequiredFeatures:  config.RequiredFeatures,
 	}
-	switch config.Direction {
+	direction := config.Direction
+	if ifaceConfig := lookupConnectionInterfaceConfig(config.Type); ifaceConfig != nil && direction == ConnectionDirectionBidirectional {
+		direction = ifaceConfig.Direction
+	}
+	switch direction {
 	case ConnectionDirectionForwardOnly:
 		for name, def := range forwardConnectionArguments {
 			ret.Arguments[name] = def
@@ -338,6 +594,16 @@ func ConnectionFieldDefinition(config *ConnectionFieldDefinitionConfig) *graphql
 			ret.Arguments[name] = def
 		}
 	}
+	if config.DefaultPageSize > 0 || config.MaxPageSize > 0 {
+		for _, name := range []string{"first", "last"} {
+			if def, ok := ret.Arguments[name]; ok {
+				ret.Arguments[name] = pageSizeArgument(def, direction, config.DefaultPageSize, config.MaxPageSize)
+			}
+		}
+		if config.DefaultPageSize > 0 && direction == ConnectionDirectionBidirectional {
+			ret.Description = strings.TrimSpace(ret.Description + fmt.Sprintf(" If neither `first` nor `last` is given, the first %v results are returned.", config.DefaultPageSize))
+		}
+	}
 	for name, def := range config.Arguments {
 		ret.Arguments[name] = def
 	}
@@ -385,13 +651,15 @@ func resolveEdgeSliceLen(edgeSlice any) (any, error) {
 // Connection is used to create a connection field that adheres to the GraphQL Cursor Connections
 // Specification.
 func Connection(config *ConnectionConfig) *graphql.FieldDefinition {
+	checkCursorLength(config)
+
 	edgeFields := map[string]*graphql.FieldDefinition{
 		"cursor": {
 			Type:        graphql.NewNonNullType(graphql.StringType),
 			Cost:        graphql.FieldResolverCost(0),
 			Description: cursorDesc,
 			Resolve: func(ctx graphql.FieldContext) (any, error) {
-				s, err := SerializeCursor(ctx.Object.(edge).cursor.value)
+				s, err := ctxCursorCompression(ctx.Context).SerializeCursor(ctx.Object.(edge).cursor.value)
 				if err != nil {
 					return nil, errors.Wrap(err, "error serializing cursor")
 				}
@@ -432,13 +700,8 @@ func Connection(config *ConnectionConfig) *graphql.FieldDefinition {
 		RequiredFeatures: config.RequiredFeatures,
 		Fields: map[string]*graphql.FieldDefinition{
 			"edges": {
-				Type: graphql.NewNonNullType(graphql.NewListType(graphql.NewNonNullType(edgeType))),
-				Cost: func(ctx graphql.FieldCostContext) graphql.FieldCost {
-					return graphql.FieldCost{
-						Resolver:   0,
-						Multiplier: ctx.Context.Value(maxEdgeCountContextKey).(int),
-					}
-				},
+				Type:        graphql.NewNonNullType(graphql.NewListType(graphql.NewNonNullType(edgeType))),
+				Cost:        edgesCost,
 				Description: edgesDesc,
 				Resolve: func(ctx graphql.FieldContext) (any, error) {
 					return ctx.Object.(*connection).Edges, nil
@@ -473,53 +736,75 @@ func Connection(config *ConnectionConfig) *graphql.FieldDefinition {
 		}
 	}
 
+	direction := config.Direction
+	for _, iface := range config.ImplementedInterfaces {
+		if ifaceConfig := lookupConnectionInterfaceConfig(iface); ifaceConfig != nil && direction == ConnectionDirectionBidirectional {
+			direction = ifaceConfig.Direction
+		}
+	}
+
 	ret := ConnectionFieldDefinition(&ConnectionFieldDefinitionConfig{
 		Type:              connectionType,
-		Direction:         config.Direction,
+		Direction:         direction,
 		Description:       config.Description,
 		DeprecationReason: config.DeprecationReason,
 		Arguments:         config.Arguments,
+		Cost:              config.Cost,
+		DefaultPageSize:   config.DefaultPageSize,
+		MaxPageSize:       config.MaxPageSize,
 		RequiredFeatures:  config.RequiredFeatures,
 	})
+	paginator := pagination.Paginator[userCursor]{
+		DefaultPageSize: config.DefaultPageSize,
+		MaxPageSize:     config.MaxPageSize,
+	}
 	ret.Resolve = func(ctx graphql.FieldContext) (any, error) {
-		if first, ok := ctx.Arguments["first"].(int); ok {
-			if first < 0 {
-				return nil, fmt.Errorf("The `first` argument cannot be negative.")
-			} else if _, ok := ctx.Arguments["last"].(int); ok {
-				return nil, fmt.Errorf("You cannot provide both `first` and `last` arguments.")
-			}
-		} else if last, ok := ctx.Arguments["last"].(int); ok {
-			if last < 0 {
-				return nil, fmt.Errorf("The `last` argument cannot be negative.")
+		var first, last *int
+		if f, ok := ctx.Arguments["first"].(int); ok {
+			first = &f
+		}
+		if l, ok := ctx.Arguments["last"].(int); ok {
+			last = &l
+		}
+		window, err := paginator.Window(nil, nil, first, last)
+		if err != nil {
+			return nil, config.argumentError(ctx, connectionWindowError(err))
+		} else if first == nil && last == nil {
+			arguments := make(map[string]any, len(ctx.Arguments)+1)
+			for k, v := range ctx.Arguments {
+				arguments[k] = v
 			}
-		} else {
-			return nil, fmt.Errorf("You must provide either the `first` or `last` argument.")
+			arguments["first"] = *window.First
+			ctx.Arguments = arguments
 		}
 
 		var afterCursor, beforeCursor any
 
 		if after, _ := ctx.Arguments["after"].(string); after != "" {
-			if value := DeserializeCursor(config.CursorType, after); value == nil {
-				return nil, fmt.Errorf("Invalid after cursor.")
+			if value := ctxCursorCompression(ctx.Context).DeserializeCursor(config.CursorType, after); value == nil {
+				return nil, config.argumentError(ctx, &ConnectionArgumentError{
+					Code:     ErrorCodeInvalidCursor,
+					Argument: "after",
+					Message:  "Invalid after cursor.",
+				})
 			} else {
 				afterCursor = value
 			}
 		}
 
 		if before, _ := ctx.Arguments["before"].(string); before != "" {
-			if value := DeserializeCursor(config.CursorType, before); value == nil {
-				return nil, fmt.Errorf("Invalid before cursor.")
+			if value := ctxCursorCompression(ctx.Context).DeserializeCursor(config.CursorType, before); value == nil {
+				return nil, config.argumentError(ctx, &ConnectionArgumentError{
+					Code:     ErrorCodeInvalidCursor,
+					Argument: "before",
+					Message:  "Invalid before cursor.",
+				})
 			} else {
 				beforeCursor = value
 			}
 		}
 
-		var limit int
-		if first, ok := ctx.Arguments["first"].(int); ok {
-			limit = first + 1
-		} else {
-			limit = -(ctx.Arguments["last"].(int) + 1)
-		}
+		limit := window.Limit()
 		resolve := func() (any, func(a, b any) bool, error) {
 			return config.ResolveAllEdges(ctx)
 		}
@@ -528,7 +813,7 @@ func Connection(config *ConnectionConfig) *graphql.FieldDefinition {
 				return config.ResolveEdges(ctx, afterCursor, beforeCursor, limit)
 			}
 		}
-		if limit == 1 || limit == -1 {
+		if window.IsEmpty() {
 			// no edges. don't do anything unless pageInfo is requested
 			return &connection{
 				ResolveTotalCount: func() (any, error) {
@@ -574,6 +859,42 @@ func Connection(config *ConnectionConfig) *graphql.FieldDefinition {
 	return ret
 }
 
+// Translates a pagination.WindowError into an error in terms of the connection's arguments.
+func connectionWindowError(err error) error {
+	windowErr, ok := err.(*pagination.WindowError)
+	if !ok {
+		return err
+	}
+	arg := "first"
+	if windowErr.Last {
+		arg = "last"
+	}
+	ret := &ConnectionArgumentError{
+		Code:        ErrorCodeInvalidPageSize,
+		Argument:    arg,
+		WindowError: windowErr,
+	}
+	switch windowErr.Reason {
+	case pagination.WindowErrorReasonNegativePageSize:
+		ret.Message = fmt.Sprintf("The `%v` argument cannot be negative.", arg)
+	case pagination.WindowErrorReasonConflictingPageSizes:
+		ret.Message = "You cannot provide both `first` and `last` arguments."
+	case pagination.WindowErrorReasonPageSizeTooLarge:
+		ret.Message = fmt.Sprintf("The `%v` argument cannot exceed %v.", arg, windowErr.MaxPageSize)
+	default:
+		ret.Message = "You must provide either the `first` or `last` argument."
+	}
+	return ret
+}
+
+// Applies the connection's ArgumentErrorMessage function to argument errors.
+func (config *ConnectionConfig) argumentError(ctx graphql.FieldContext, err error) error {
+	if argErr, ok := err.(*ConnectionArgumentError); ok && config.ArgumentErrorMessage != nil {
+		argErr.Message = config.ArgumentErrorMessage(ctx.Context, argErr)
+	}
+	return err
+}
+
 func completeConnection(config *ConnectionConfig, ctx graphql.FieldContext, beforeCursorValue, afterCursorValue any, cursorLess func(a, b any) bool, edgeSlice any) (any, error) {
 	if edgeSlice, ok := edgeSlice.(graphql.ResolvePromise); ok {
 		return chain(ctx.Context, edgeSlice, func(edgeSlice any) (any, error) {
@@ -622,27 +943,31 @@ func completeConnection(config *ConnectionConfig, ctx graphql.FieldContext, befo
 		}
 	}
 
-	var first, last *int
+	window := pagination.Window[userCursor]{
+		After:  afterCursor,
+		Before: beforeCursor,
+	}
 	if f, ok := ctx.Arguments["first"].(int); ok {
-		first = &f
+		window.First = &f
 	}
 	if l, ok := ctx.Arguments["last"].(int); ok {
-		last = &l
+		window.Last = &l
 	}
 
-	edges, pageInfo := pagination.EdgesToReturn(edgesWithCursors, afterCursor, beforeCursor, first, last)
+	edges, pageInfo := pagination.Page(edgesWithCursors, window)
 
 	serializedPageInfo := &PageInfo{
 		HasPreviousPage: pageInfo.HasPreviousPage,
 		HasNextPage:     pageInfo.HasNextPage,
 	}
 	if len(edges) > 0 {
+		compression := ctxCursorCompression(ctx.Context)
 		var err error
-		serializedPageInfo.StartCursor, err = SerializeCursor(pageInfo.StartCursor.value)
+		serializedPageInfo.StartCursor, err = compression.SerializeCursor(pageInfo.StartCursor.value)
 		if err != nil {
 			return nil, errors.Wrap(err, "error serializing start cursor")
 		}
-		serializedPageInfo.EndCursor, err = SerializeCursor(pageInfo.EndCursor.value)
+		serializedPageInfo.EndCursor, err = compression.SerializeCursor(pageInfo.EndCursor.value)
 		if err != nil {
 			return nil, errors.Wrap(err, "error serializing end cursor")
 		}
@@ -699,6 +1024,11 @@ type TimeBasedConnectionConfig struct {
 	// This function should return a TimeBasedCursor for the given edge.
 	EdgeCursor func(edge any) TimeBasedCursor
 
+	// The ranges given to EdgeGetter may overlap, so edges are deduplicated before pagination. By
+	// default, edges are considered duplicates if they have the same cursor. If given, this
+	// function returns a comparable key that's used to identify duplicates instead.
+	DedupeKey func(edge any) any
+
 	// Returns the fields for the edge. This should always at least include a "node" field.
 	EdgeFields map[string]*graphql.FieldDefinition
 
@@ -775,6 +1105,12 @@ func TimeBasedConnection(config *TimeBasedConnectionConfig) *graphql.FieldDefini
 			}
 
 			queries := pagination.TimeBasedRangeQueries(afterPtr, beforePtr, atOrAfterTime, beforeTime, limit)
+			if clock := ctxClock(ctx.Context); clock != nil {
+				for i := range queries {
+					queries[i].MinTime = normalizeTime(clock, queries[i].MinTime)
+					queries[i].MaxTime = normalizeTime(clock, queries[i].MaxTime)
+				}
+			}
 
 			var edges []any
 			var promises []graphql.ResolvePromise
@@ -801,11 +1137,32 @@ func TimeBasedConnection(config *TimeBasedConnectionConfig) *graphql.FieldDefini
 							edges = append(edges, v.Index(i).Interface())
 						}
 					}
-					return edges, nil
+					return config.dedupeEdges(edges), nil
 				}), timeBasedCursorLess, err
 			}
-			return edges, timeBasedCursorLess, err
+			return config.dedupeEdges(edges), timeBasedCursorLess, err
 		},
 		ImplementedInterfaces: config.ImplementedInterfaces,
 	})
 }
+
+// Removes duplicate edges, keeping the first occurrence of each.
+func (config *TimeBasedConnectionConfig) dedupeEdges(edges []any) []any {
+	key := func(edge any) any {
+		return config.EdgeCursor(edge)
+	}
+	if config.DedupeKey != nil {
+		key = config.DedupeKey
+	}
+	seen := make(map[any]struct{}, len(edges))
+	ret := edges[:0]
+	for _, edge := range edges {
+		k := key(edge)
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		ret = append(ret, edge)
+	}
+	return ret
+}
@@ -0,0 +1,127 @@
+package pagination
+
+import (
+	"fmt"
+)
+
+// Window describes a requested page of a list in terms of cursors and page sizes. It doesn't depend
+// on any particular transport: GraphQL connections derive it from their first, last, after, and
+// before arguments, and JSON:API collections derive it from their page parameters.
+type Window[C Cursor[C]] struct {
+	After  *C
+	Before *C
+	First  *int
+	Last   *int
+}
+
+// Limit returns the number of items that should be fetched in order to fill the window and
+// determine whether there are more items beyond it. If the window is anchored to the end of the
+// list, the limit is negative. If the window has no page size, the limit is zero.
+func (w Window[C]) Limit() int {
+	if w.First != nil {
+		return *w.First + 1
+	} else if w.Last != nil {
+		return -(*w.Last + 1)
+	}
+	return 0
+}
+
+// IsEmpty returns true if the window has a page size of zero.
+func (w Window[C]) IsEmpty() bool {
+	return (w.First != nil && *w.First == 0) || (w.Last != nil && *w.Last == 0)
+}
+
+// WindowErrorReason indicates why a window couldn't be created.
+type WindowErrorReason int
+
+const (
+	// The page size was negative.
+	WindowErrorReasonNegativePageSize WindowErrorReason = iota
+
+	// The page size exceeded the paginator's maximum.
+	WindowErrorReasonPageSizeTooLarge
+
+	// Both first and last page sizes were given.
+	WindowErrorReasonConflictingPageSizes
+
+	// Neither page size was given and the paginator doesn't have a default.
+	WindowErrorReasonMissingPageSize
+)
+
+// WindowError is returned by Paginator when a window's parameters are invalid. Transports will
+// typically translate these into their own error messages.
+type WindowError struct {
+	Reason WindowErrorReason
+
+	// True if the error pertains to the last page size rather than the first.
+	Last bool
+
+	// The paginator's maximum page size.
+	MaxPageSize int
+}
+
+func (err *WindowError) Error() string {
+	param := "first"
+	if err.Last {
+		param = "last"
+	}
+	switch err.Reason {
+	case WindowErrorReasonNegativePageSize:
+		return fmt.Sprintf("%v page size cannot be negative", param)
+	case WindowErrorReasonPageSizeTooLarge:
+		return fmt.Sprintf("%v page size cannot exceed %v", param, err.MaxPageSize)
+	case WindowErrorReasonConflictingPageSizes:
+		return "first and last page sizes cannot both be given"
+	default:
+		return "a first or last page size is required"
+	}
+}
+
+// Paginator implements the cursor semantics shared by all paginated lists.
+type Paginator[C Cursor[C]] struct {
+	// If non-zero, this is the page size used when neither first nor last is given. The first
+	// items are returned.
+	DefaultPageSize int
+
+	// If non-zero, windows with larger page sizes are rejected.
+	MaxPageSize int
+}
+
+// Window validates the given parameters and returns the corresponding window. If neither first nor
+// last is given, the paginator's default page size is applied. If the parameters are invalid, a
+// *WindowError is returned.
+func (p Paginator[C]) Window(after, before *C, first, last *int) (Window[C], error) {
+	if first != nil {
+		if *first < 0 {
+			return Window[C]{}, &WindowError{Reason: WindowErrorReasonNegativePageSize}
+		} else if last != nil {
+			return Window[C]{}, &WindowError{Reason: WindowErrorReasonConflictingPageSizes}
+		} else if p.MaxPageSize > 0 && *first > p.MaxPageSize {
+			return Window[C]{}, &WindowError{Reason: WindowErrorReasonPageSizeTooLarge, MaxPageSize: p.MaxPageSize}
+		}
+	} else if last != nil {
+		if *last < 0 {
+			return Window[C]{}, &WindowError{Reason: WindowErrorReasonNegativePageSize, Last: true}
+		} else if p.MaxPageSize > 0 && *last > p.MaxPageSize {
+			return Window[C]{}, &WindowError{Reason: WindowErrorReasonPageSizeTooLarge, Last: true, MaxPageSize: p.MaxPageSize}
+		}
+	} else if p.DefaultPageSize > 0 {
+		n := p.DefaultPageSize
+		first = &n
+	} else {
+		return Window[C]{}, &WindowError{Reason: WindowErrorReasonMissingPageSize}
+	}
+
+	return Window[C]{
+		After:  after,
+		Before: before,
+		First:  first,
+		Last:   last,
+	}, nil
+}
+
+// Page returns the edges within the window along with the page info. The edges may be given in
+// any order and may include edges outside of the window.
+func Page[E Edge[C], C Cursor[C]](edges []E, window Window[C]) ([]E, PageInfo[C]) {
+	return EdgesToReturn(edges, window.After, window.Before, window.First, window.Last)
+}
@@ -0,0 +1,76 @@
+package pagination
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type intCursor int
+
+func (c intCursor) LessThan(other intCursor) bool {
+	return c < other
+}
+
+type intEdge int
+
+func (e intEdge) Cursor() intCursor {
+	return intCursor(e)
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestPaginator(t *testing.T) {
+	paginator := Paginator[intCursor]{
+		DefaultPageSize: 2,
+		MaxPageSize:     3,
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		window, err := paginator.Window(nil, nil, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 3, window.Limit())
+
+		edges, pageInfo := Page([]intEdge{3, 1, 2}, window)
+		assert.Equal(t, []intEdge{1, 2}, edges)
+		assert.False(t, pageInfo.HasPreviousPage)
+		assert.True(t, pageInfo.HasNextPage)
+	})
+
+	t.Run("Last", func(t *testing.T) {
+		before := intCursor(3)
+		window, err := paginator.Window(nil, &before, nil, intPtr(1))
+		require.NoError(t, err)
+		assert.Equal(t, -2, window.Limit())
+
+		edges, pageInfo := Page([]intEdge{3, 1, 2}, window)
+		assert.Equal(t, []intEdge{2}, edges)
+		assert.True(t, pageInfo.HasPreviousPage)
+		assert.True(t, pageInfo.HasNextPage)
+	})
+
+	for name, tc := range map[string]struct {
+		First  *int
+		Last   *int
+		Reason WindowErrorReason
+	}{
+		"Negative":    {First: intPtr(-1), Reason: WindowErrorReasonNegativePageSize},
+		"TooLarge":    {Last: intPtr(4), Reason: WindowErrorReasonPageSizeTooLarge},
+		"Conflicting": {First: intPtr(1), Last: intPtr(1), Reason: WindowErrorReasonConflictingPageSizes},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := paginator.Window(nil, nil, tc.First, tc.Last)
+			require.IsType(t, &WindowError{}, err)
+			assert.Equal(t, tc.Reason, err.(*WindowError).Reason)
+		})
+	}
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := Paginator[intCursor]{}.Window(nil, nil, nil, nil)
+		require.IsType(t, &WindowError{}, err)
+		assert.Equal(t, WindowErrorReasonMissingPageSize, err.(*WindowError).Reason)
+	})
+}
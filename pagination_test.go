@@ -1,6 +1,7 @@
 package apifu
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http/httptest"
@@ -171,6 +172,73 @@ func TestConnection(t *testing.T) {
 	}`, string(body))
 }
 
+func TestConnection_ArgumentErrors(t *testing.T) {
+	newConnection := func(namePrefix string, argumentErrorMessage func(ctx context.Context, err *ConnectionArgumentError) string) *graphql.FieldDefinition {
+		return Connection(&ConnectionConfig{
+			NamePrefix: namePrefix,
+			ResolveEdges: func(ctx graphql.FieldContext, after, before any, limit int) (edgeSlice any, cursorLess func(a, b any) bool, err error) {
+				return []int{}, func(a, b any) bool {
+					return false
+				}, nil
+			},
+			CursorType: reflect.TypeOf(0),
+			EdgeCursor: func(edge any) any {
+				return edge
+			},
+			EdgeFields: map[string]*graphql.FieldDefinition{
+				"node": {
+					Type: graphql.IntType,
+					Resolve: func(ctx graphql.FieldContext) (any, error) {
+						return ctx.Object, nil
+					},
+				},
+			},
+			ArgumentErrorMessage: argumentErrorMessage,
+		})
+	}
+
+	config := &Config{}
+	config.AddQueryField("connection", newConnection("Test", nil))
+	config.AddQueryField("localizedConnection", newConnection("Localized", func(ctx context.Context, err *ConnectionArgumentError) string {
+		if err.Code == ErrorCodeInvalidCursor {
+			return fmt.Sprintf("Curseur %v invalide.", err.Argument)
+		}
+		return err.Message
+	}))
+
+	api, err := NewAPI(config)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Query        string
+		ExpectedBody string
+	}{
+		"InvalidAfter": {
+			Query:        `{connection(first: 1, after: "!") { edges { node } }}`,
+			ExpectedBody: `{"data":{"connection":null},"errors":[{"message":"Invalid after cursor.","locations":[{"line":1,"column":2}],"path":["connection"],"extensions":{"code":"INVALID_CURSOR","retryable":false,"argument":"after"}}]}`,
+		},
+		"InvalidBefore": {
+			Query:        `{connection(last: 1, before: "oTA") { edges { node } }}`,
+			ExpectedBody: `{"data":{"connection":null},"errors":[{"message":"Invalid before cursor.","locations":[{"line":1,"column":2}],"path":["connection"],"extensions":{"code":"INVALID_CURSOR","retryable":false,"argument":"before"}}]}`,
+		},
+		"ConflictingPageSizes": {
+			Query:        `{connection(first: 1, last: 1) { edges { node } }}`,
+			ExpectedBody: `{"data":{"connection":null},"errors":[{"message":"You cannot provide both ` + "`first`" + ` and ` + "`last`" + ` arguments.","locations":[{"line":1,"column":2}],"path":["connection"],"extensions":{"code":"INVALID_PAGE_SIZE","retryable":false,"argument":"first"}}]}`,
+		},
+		"Localized": {
+			Query:        `{localizedConnection(first: 1, after: "!") { edges { node } }}`,
+			ExpectedBody: `{"data":{"localizedConnection":null},"errors":[{"message":"Curseur after invalide.","locations":[{"line":1,"column":2}],"path":["localizedConnection"],"extensions":{"code":"INVALID_CURSOR","retryable":false,"argument":"after"}}]}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := executeGraphQL(t, api, tc.Query)
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.ExpectedBody, string(body))
+		})
+	}
+}
+
 func TestConnection_ZeroArg_WithoutPageInfo(t *testing.T) {
 	config := &Config{}
 	config.AddQueryField("connection", Connection(&ConnectionConfig{
@@ -563,3 +631,388 @@ func TestTimeBasedConnection(t *testing.T) {
 		})
 	}
 }
+
+func TestTimeBasedConnection_Dedupe(t *testing.T) {
+	type testEdge struct {
+		Id   string
+		Time time.Time
+	}
+
+	var edges []testEdge
+	for i := 0; i < 3; i++ {
+		edges = append(edges, testEdge{
+			Id:   strconv.Itoa(i),
+			Time: time.Date(2020, time.January, 01, 0, 0, i, 0, time.UTC),
+		})
+	}
+
+	newConnection := func(namePrefix string, copyId string, dedupeKey func(edge any) any) *graphql.FieldDefinition {
+		return TimeBasedConnection(&TimeBasedConnectionConfig{
+			NamePrefix: namePrefix,
+			EdgeGetter: func(ctx graphql.FieldContext, minTime time.Time, maxTime time.Time, limit int) (any, error) {
+				// Simulate overlapping ranges by returning every edge twice.
+				var ret []testEdge
+				for _, edge := range edges {
+					ret = append(ret, edge)
+					edge.Id += copyId
+					ret = append(ret, edge)
+				}
+				return ret, nil
+			},
+			EdgeCursor: func(edge any) TimeBasedCursor {
+				return NewTimeBasedCursor(edge.(testEdge).Time, edge.(testEdge).Id)
+			},
+			DedupeKey: dedupeKey,
+			EdgeFields: map[string]*graphql.FieldDefinition{
+				"node": {
+					Type: graphql.StringType,
+					Resolve: func(ctx graphql.FieldContext) (any, error) {
+						return ctx.Object.(testEdge).Id, nil
+					},
+				},
+			},
+		})
+	}
+
+	config := &Config{}
+	config.AddQueryField("connection", newConnection("Test", "", nil))
+	config.AddQueryField("keyedConnection", newConnection("Keyed", "-copy", func(edge any) any {
+		return edge.(testEdge).Time
+	}))
+
+	api, err := NewAPI(config)
+	require.NoError(t, err)
+
+	resp := executeGraphQL(t, api, `{
+		connection(first: 100) { edges { node } }
+		keyedConnection(first: 100) { edges { node } }
+	}`)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"data": {
+			"connection": {"edges": [{"node":"0"},{"node":"1"},{"node":"2"}]},
+			"keyedConnection": {"edges": [{"node":"0"},{"node":"1"},{"node":"2"}]}
+		}
+	}`, string(body))
+}
+
+func TestConnectionInterface_Inheritance(t *testing.T) {
+	newConnectionInterface := func(prefix string, direction ConnectionDirection) *graphql.InterfaceType {
+		return ConnectionInterface(&ConnectionInterfaceConfig{
+			NamePrefix: prefix,
+			EdgeFields: map[string]*graphql.FieldDefinition{
+				"node": {
+					Type: graphql.IntType,
+				},
+			},
+			HasTotalCount:           true,
+			Direction:               direction,
+			ValidateImplementations: true,
+		})
+	}
+
+	newConnectionConfig := func(prefix string, iface *graphql.InterfaceType) *ConnectionConfig {
+		return &ConnectionConfig{
+			NamePrefix: prefix,
+			ResolveEdges: func(ctx graphql.FieldContext, after, before any, limit int) (edgeSlice any, cursorLess func(a, b any) bool, err error) {
+				return []int{}, func(a, b any) bool {
+					return false
+				}, nil
+			},
+			ResolveTotalCount: func(ctx graphql.FieldContext) (any, error) {
+				return 0, nil
+			},
+			CursorType: reflect.TypeOf(""),
+			EdgeCursor: func(edge any) any {
+				return strconv.Itoa(edge.(int))
+			},
+			EdgeFields: map[string]*graphql.FieldDefinition{
+				"node": {
+					Type: graphql.IntType,
+					Resolve: func(ctx graphql.FieldContext) (any, error) {
+						return ctx.Object, nil
+					},
+				},
+			},
+			ImplementedInterfaces: []*graphql.InterfaceType{iface},
+		}
+	}
+
+	t.Run("InheritedDirection", func(t *testing.T) {
+		iface := newConnectionInterface("ForwardInterface", ConnectionDirectionForwardOnly)
+
+		ifaceField := ConnectionFieldDefinition(&ConnectionFieldDefinitionConfig{
+			Type: graphql.NewNonNullType(iface),
+		})
+		assert.Contains(t, ifaceField.Arguments, "first")
+		assert.Contains(t, ifaceField.Arguments, "after")
+		assert.NotContains(t, ifaceField.Arguments, "last")
+		assert.NotContains(t, ifaceField.Arguments, "before")
+
+		config := &Config{}
+		config.AddQueryField("connection", Connection(newConnectionConfig("Forward", iface)))
+		api, err := NewAPI(config)
+		require.NoError(t, err)
+
+		field := api.schema.QueryType().Fields["connection"]
+		assert.Contains(t, field.Arguments, "first")
+		assert.NotContains(t, field.Arguments, "last")
+	})
+
+	t.Run("DirectionMismatch", func(t *testing.T) {
+		iface := newConnectionInterface("BackwardInterface", ConnectionDirectionBackwardOnly)
+
+		connectionConfig := newConnectionConfig("Mismatched", iface)
+		connectionConfig.Direction = ConnectionDirectionForwardOnly
+
+		config := &Config{}
+		config.AddQueryField("connection", Connection(connectionConfig))
+		_, err := NewAPI(config)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "forward-only while the interface is backward-only")
+	})
+
+	t.Run("Unvalidated", func(t *testing.T) {
+		iface := ConnectionInterface(&ConnectionInterfaceConfig{
+			NamePrefix: "UnvalidatedInterface",
+			EdgeFields: map[string]*graphql.FieldDefinition{
+				"node": {
+					Type: graphql.IntType,
+				},
+			},
+		})
+
+		connectionConfig := newConnectionConfig("Unvalidated", iface)
+		connectionConfig.Direction = ConnectionDirectionForwardOnly
+
+		config := &Config{}
+		config.AddQueryField("connection", Connection(connectionConfig))
+		_, err := NewAPI(config)
+		require.NoError(t, err)
+	})
+
+	t.Run("MissingTotalCount", func(t *testing.T) {
+		iface := newConnectionInterface("TotalCountInterface", ConnectionDirectionBidirectional)
+
+		connectionConfig := newConnectionConfig("NoTotalCount", iface)
+		connectionConfig.ResolveTotalCount = nil
+
+		config := &Config{}
+		config.AddQueryField("connection", Connection(connectionConfig))
+		_, err := NewAPI(config)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "NoTotalCountConnection implements TotalCountInterfaceConnection, which requires totalCount")
+	})
+}
+
+func TestConnection_NestedCost(t *testing.T) {
+	newConnectionConfig := func(prefix string, edgeFields map[string]*graphql.FieldDefinition) *ConnectionConfig {
+		edgeFields["node"] = &graphql.FieldDefinition{
+			Type: graphql.IntType,
+			Resolve: func(ctx graphql.FieldContext) (any, error) {
+				return ctx.Object, nil
+			},
+		}
+		return &ConnectionConfig{
+			NamePrefix: prefix,
+			ResolveEdges: func(ctx graphql.FieldContext, after, before any, limit int) (edgeSlice any, cursorLess func(a, b any) bool, err error) {
+				return []int{}, func(a, b any) bool {
+					return false
+				}, nil
+			},
+			CursorType: reflect.TypeOf(""),
+			EdgeCursor: func(edge any) any {
+				return strconv.Itoa(edge.(int))
+			},
+			EdgeFields: edgeFields,
+		}
+	}
+
+	innerConfig := newConnectionConfig("Inner", map[string]*graphql.FieldDefinition{})
+	innerConfig.Cost = graphql.FieldResolverCost(2)
+
+	config := &Config{}
+	config.AddQueryField("connection", Connection(newConnectionConfig("Outer", map[string]*graphql.FieldDefinition{
+		"expensive": {
+			Type: graphql.IntType,
+			Cost: graphql.FieldResolverCost(3),
+			Resolve: func(ctx graphql.FieldContext) (any, error) {
+				return ctx.Object, nil
+			},
+		},
+		"inner": Connection(innerConfig),
+	})))
+
+	api, err := NewAPI(config)
+	require.NoError(t, err)
+
+	var cost int
+	_, errs := graphql.ParseAndValidate(`{
+		connection(first: 10) {
+			edges {
+				node
+				expensive
+				inner(last: 5) {
+					edges {
+						node
+					}
+				}
+			}
+		}
+	}`, api.schema, nil, graphql.ValidateCost("", nil, -1, &cost, graphql.FieldCost{Resolver: 1}))
+	require.Empty(t, errs)
+	assert.Equal(t, (1 /* connection */)+(10 /* edges */)*((1 /* node */)+(3 /* expensive */)+(2 /* inner */)+(5 /* inner edges */)*(1 /* node */)), cost)
+}
+
+func TestConnection_PageSize(t *testing.T) {
+	var limits []int
+	newConnection := func(prefix string, direction ConnectionDirection) *graphql.FieldDefinition {
+		return Connection(&ConnectionConfig{
+			NamePrefix:      prefix,
+			Direction:       direction,
+			DefaultPageSize: 2,
+			MaxPageSize:     3,
+			ResolveEdges: func(ctx graphql.FieldContext, after, before any, limit int) (edgeSlice any, cursorLess func(a, b any) bool, err error) {
+				limits = append(limits, limit)
+				return []int{1, 2, 3, 4, 5}, func(a, b any) bool {
+					return a.(int) < b.(int)
+				}, nil
+			},
+			CursorType: reflect.TypeOf(0),
+			EdgeCursor: func(edge any) any {
+				return edge
+			},
+			EdgeFields: map[string]*graphql.FieldDefinition{
+				"node": {
+					Type: graphql.IntType,
+					Resolve: func(ctx graphql.FieldContext) (any, error) {
+						return ctx.Object, nil
+					},
+				},
+			},
+		})
+	}
+
+	config := &Config{}
+	config.AddQueryField("bidirectional", newConnection("Bidirectional", ConnectionDirectionBidirectional))
+	config.AddQueryField("forward", newConnection("Forward", ConnectionDirectionForwardOnly))
+
+	api, err := NewAPI(config)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Query          string
+		ExpectedLimits []int
+		ExpectedCost   int
+		ExpectedBody   string
+	}{
+		"BidirectionalDefault": {
+			Query:          `{bidirectional { edges { node } }}`,
+			ExpectedLimits: []int{3},
+			ExpectedCost:   1 + 2,
+			ExpectedBody:   `{"data":{"bidirectional":{"edges":[{"node":1},{"node":2}]}}}`,
+		},
+		"ForwardDefault": {
+			Query:          `{forward { edges { node } }}`,
+			ExpectedLimits: []int{3},
+			ExpectedCost:   1 + 2,
+			ExpectedBody:   `{"data":{"forward":{"edges":[{"node":1},{"node":2}]}}}`,
+		},
+		"Max": {
+			Query:          `{bidirectional(last: 3) { edges { node } }}`,
+			ExpectedCost:   1 + 3,
+			ExpectedLimits: []int{-4},
+			ExpectedBody:   `{"data":{"bidirectional":{"edges":[{"node":3},{"node":4},{"node":5}]}}}`,
+		},
+		"MaxExceeded": {
+			Query:        `{forward(first: 4) { edges { node } }}`,
+			ExpectedCost: 1 + 4,
+			ExpectedBody: `{"data":{"forward":null},"errors":[{"message":"The ` + "`first`" + ` argument cannot exceed 3.","locations":[{"line":1,"column":2}],"path":["forward"],"extensions":{"code":"INVALID_PAGE_SIZE","retryable":false,"argument":"first"}}]}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var cost int
+			_, errs := graphql.ParseAndValidate(tc.Query, api.schema, nil, graphql.ValidateCost("", nil, -1, &cost, graphql.FieldCost{Resolver: 1}))
+			require.Empty(t, errs)
+			assert.Equal(t, tc.ExpectedCost, cost)
+
+			limits = nil
+			resp := executeGraphQL(t, api, tc.Query)
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.ExpectedBody, string(body))
+			assert.Equal(t, tc.ExpectedLimits, limits)
+		})
+	}
+
+	assert.Equal(t, 2, api.schema.QueryType().Fields["forward"].Arguments["first"].DefaultValue)
+	assert.Contains(t, api.schema.QueryType().Fields["forward"].Arguments["first"].Description, "The maximum is 3.")
+	assert.Contains(t, api.schema.QueryType().Fields["bidirectional"].Description, "the first 2 results are returned")
+}
+
+func TestConnection_NullablePageInfoCursors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		Nullable     bool
+		ExpectedType string
+		Expected     string
+	}{
+		"Legacy": {
+			ExpectedType: "String!",
+			Expected:     `{"data":{"connection":{"pageInfo":{"startCursor":"","endCursor":""}}}}`,
+		},
+		"Nullable": {
+			Nullable:     true,
+			ExpectedType: "String",
+			Expected:     `{"data":{"connection":{"pageInfo":{"startCursor":null,"endCursor":null}}}}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := &Config{
+				NullablePageInfoCursors: tc.Nullable,
+			}
+			config.AddQueryField("connection", Connection(&ConnectionConfig{
+				NamePrefix: "Test",
+				ResolveAllEdges: func(ctx graphql.FieldContext) (any, func(a, b any) bool, error) {
+					return []int{}, func(a, b any) bool {
+						return a.(int) < b.(int)
+					}, nil
+				},
+				CursorType: reflect.TypeOf(0),
+				EdgeCursor: func(edge any) any {
+					return edge
+				},
+				EdgeFields: map[string]*graphql.FieldDefinition{
+					"node": {
+						Type: graphql.IntType,
+						Resolve: func(ctx graphql.FieldContext) (any, error) {
+							return ctx.Object, nil
+						},
+					},
+				},
+			}))
+
+			api, err := NewAPI(config)
+			require.NoError(t, err)
+
+			pageInfo := api.Schema().NamedTypes()["PageInfo"].(*graphql.ObjectType)
+			assert.Equal(t, tc.ExpectedType, pageInfo.Fields["startCursor"].Type.String())
+			assert.Equal(t, tc.ExpectedType, pageInfo.Fields["endCursor"].Type.String())
+
+			resp := executeGraphQL(t, api, `{
+				connection(first: 10) {
+					pageInfo {
+						startCursor
+						endCursor
+					}
+				}
+			}`)
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.Expected, string(body))
+		})
+	}
+
+	// The shared type must not be modified.
+	assert.IsType(t, &graphql.NonNullType{}, PageInfoType.Fields["startCursor"].Type)
+}
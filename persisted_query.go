@@ -5,6 +5,10 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync/atomic"
 
 	"github.com/ccbrown/api-fu/graphql"
 )
@@ -25,6 +29,15 @@ var emptyStringHash = sha256.Sum256([]byte(""))
 // PersistedQueryExtension implements Apollo persisted queries:
 // https://www.apollographql.com/docs/react/api/link/persisted-queries/
 //
+// Requests may be GET requests, with the extension given via the "extensions" query string
+// parameter, or POST requests. If a request only contains a hash and the query isn't found, an error
+// with a "code" of "PERSISTED_QUERY_NOT_FOUND" is returned and the client is expected to retry with
+// the full query, which is then persisted. If the request's hash doesn't match its query, an error
+// with a "code" of "PERSISTED_QUERY_HASH_MISMATCH" is returned and nothing is persisted.
+//
+// If storage is nil, requests that only contain a hash receive an error with a "code" of
+// "PERSISTED_QUERY_NOT_SUPPORTED" so that clients can stop sending them.
+//
 // Typically this shouldn't be invoked directly. Instead, set the PersistedQueryStorage Config
 // field.
 func PersistedQueryExtension(storage PersistedQueryStorage, execute func(*graphql.Request) *graphql.Response) func(*graphql.Request) *graphql.Response {
@@ -33,11 +46,15 @@ func PersistedQueryExtension(storage PersistedQueryStorage, execute func(*graphq
 		ext, _ := r.Extensions["persistedQuery"].(map[string]interface{})
 		switch ext["version"] {
 		case 1, 1.0:
+			// errors parsing the hash can be ignored: hash will end up empty and we'll error out due
+			// to not being able to find the query
+			hashHex, _ := ext["sha256Hash"].(string)
+			hash, _ := hex.DecodeString(hashHex)
+
 			if r.Query == "" && r.Document == nil {
-				// errors parsing the hash can be ignored: hash will end up empty and we'll error
-				// out due to not being able to find the query
-				hashHex, _ := ext["sha256Hash"].(string)
-				hash, _ := hex.DecodeString(hashHex)
+				if storage == nil {
+					return persistedQueryErrorResponse("PersistedQueryNotSupported", graphql.ErrorCodePersistedQueryNotSupported)
+				}
 
 				found := false
 				if bytes.Equal(hash, emptyStringHash[:]) {
@@ -51,19 +68,169 @@ func PersistedQueryExtension(storage PersistedQueryStorage, execute func(*graphq
 					}
 				}
 				if !found {
-					return &graphql.Response{
-						Errors: []*graphql.Error{
-							{
-								Message: "PersistedQueryNotFound",
-							},
-						},
-					}
+					return persistedQueryErrorResponse("PersistedQueryNotFound", graphql.ErrorCodePersistedQueryNotFound)
 				}
 			} else if r.Query != "" {
-				hash := sha256.Sum256([]byte(r.Query))
-				storage.PersistQuery(r.Context, r.Query, hash[:])
+				queryHash := sha256.Sum256([]byte(r.Query))
+				if hashHex != "" && !bytes.Equal(hash, queryHash[:]) {
+					return persistedQueryErrorResponse("The provided sha256Hash does not match the query.", graphql.ErrorCodePersistedQueryHashMismatch)
+				} else if storage != nil {
+					storage.PersistQuery(r.Context, r.Query, queryHash[:])
+				}
 			}
 		}
 		return execute(&r)
 	}
 }
+
+func persistedQueryErrorResponse(message string, code graphql.ErrorCode) *graphql.Response {
+	return &graphql.Response{
+		Errors: []*graphql.Error{
+			{
+				Message:    message,
+				Extensions: code.Extensions(),
+			},
+		},
+	}
+}
+
+// LoadPersistedQueryManifest reads a persisted operations manifest such as the ones generated by
+// gql-client-gen and persists its queries to the given storage. This is typically done at startup
+// so that clients can execute the manifest's queries by hash alone.
+//
+// The manifest must be a JSON object mapping hex-encoded SHA-256 hashes to queries. An error is
+// returned if any hash doesn't match its query.
+func LoadPersistedQueryManifest(ctx context.Context, storage PersistedQueryStorage, r io.Reader) error {
+	var manifest map[string]string
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return fmt.Errorf("error decoding manifest: %w", err)
+	}
+
+	for hashHex, query := range manifest {
+		hash := sha256.Sum256([]byte(query))
+		if expected, err := hex.DecodeString(hashHex); err != nil || !bytes.Equal(expected, hash[:]) {
+			return fmt.Errorf("manifest hash %v does not match its query", hashHex)
+		}
+	}
+
+	for _, query := range manifest {
+		hash := sha256.Sum256([]byte(query))
+		storage.PersistQuery(ctx, query, hash[:])
+	}
+	return nil
+}
+
+// PersistedQueryMetrics receives persisted query events, e.g. for export to a monitoring system.
+// Implementations must be safe for concurrent use and should return quickly since they're invoked
+// while serving requests.
+type PersistedQueryMetrics interface {
+	// PersistedQueryHit is invoked when a request's hash is found in storage.
+	PersistedQueryHit(ctx context.Context, hash []byte)
+
+	// PersistedQueryMiss is invoked when a request's hash isn't found in storage. Clients are
+	// expected to retry with the full query, so occasional misses are normal, but frequent misses
+	// for the same hash may indicate stale clients or manifests that were never loaded.
+	PersistedQueryMiss(ctx context.Context, hash []byte)
+
+	// PersistedQueryEvicted is invoked when a persisted query's validated document is evicted from
+	// the document cache. See Config.DocumentCacheSize.
+	PersistedQueryEvicted(hash []byte)
+}
+
+// PersistedQueryStats contains counts of persisted query events since the API was created.
+type PersistedQueryStats struct {
+	// The number of requests whose hashes were found in storage.
+	Hits int64 `json:"hits"`
+
+	// The number of requests whose hashes weren't found in storage.
+	Misses int64 `json:"misses"`
+
+	// The number of persisted query documents evicted from the document cache.
+	Evictions int64 `json:"evictions"`
+}
+
+// PersistedQueryStats returns counts of the persisted query events that have occurred since the API
+// was created. To export the events individually, set Config.PersistedQueryMetrics.
+func (api *API) PersistedQueryStats() PersistedQueryStats {
+	return PersistedQueryStats{
+		Hits:      atomic.LoadInt64(&api.persistedQueryStats.Hits),
+		Misses:    atomic.LoadInt64(&api.persistedQueryStats.Misses),
+		Evictions: atomic.LoadInt64(&api.persistedQueryStats.Evictions),
+	}
+}
+
+// observedPersistedQueryStorage wraps the API's storage to record hits and misses.
+type observedPersistedQueryStorage struct {
+	PersistedQueryStorage
+	api *API
+}
+
+func (s *observedPersistedQueryStorage) GetPersistedQuery(ctx context.Context, hash []byte) string {
+	query := s.PersistedQueryStorage.GetPersistedQuery(ctx, hash)
+	metrics := s.api.config.PersistedQueryMetrics
+	if query != "" {
+		atomic.AddInt64(&s.api.persistedQueryStats.Hits, 1)
+		if metrics != nil {
+			metrics.PersistedQueryHit(ctx, hash)
+		}
+	} else {
+		atomic.AddInt64(&s.api.persistedQueryStats.Misses, 1)
+		if metrics != nil {
+			metrics.PersistedQueryMiss(ctx, hash)
+		}
+	}
+	return query
+}
+
+// Returns the storage to be given to PersistedQueryExtension.
+func (api *API) persistedQueryStorage() PersistedQueryStorage {
+	if api.config.PersistedQueryStorage == nil {
+		return nil
+	}
+	return &observedPersistedQueryStorage{
+		PersistedQueryStorage: api.config.PersistedQueryStorage,
+		api:                   api,
+	}
+}
+
+// Invoked when a document is evicted from one of the API's document caches.
+func (api *API) documentEvicted(doc *cachedDocument) {
+	if !doc.persisted {
+		return
+	}
+	atomic.AddInt64(&api.persistedQueryStats.Evictions, 1)
+	if metrics := api.config.PersistedQueryMetrics; metrics != nil {
+		hash := sha256.Sum256([]byte(doc.key.query))
+		metrics.PersistedQueryEvicted(hash[:])
+	}
+}
+
+// PreloadPersistedQueries persists the given queries to Config.PersistedQueryStorage and warms the
+// document cache with them so that the first requests for them don't need to parse or validate
+// them. This is typically done at startup with the queries of known clients, e.g. from a manifest
+// generated by gql-client-gen.
+//
+// The queries are validated using the features returned by Config.Features for the given context.
+// If any query is invalid, an error is returned and nothing is persisted.
+func (api *API) PreloadPersistedQueries(ctx context.Context, queries ...string) error {
+	if api.config.PersistedQueryStorage == nil {
+		return fmt.Errorf("persisted query storage is not configured")
+	}
+
+	cache := api.documentCache
+	if cache == nil {
+		cache = api.persistedQueryDocumentCache
+	}
+	features := api.requestFeatures(ctx)
+	for _, query := range queries {
+		if _, errs := api.cachedParseAndValidate(cache, api.schema, features, query, true); len(errs) > 0 {
+			return fmt.Errorf("invalid query %q: %w", query, errs[0])
+		}
+	}
+
+	for _, query := range queries {
+		hash := sha256.Sum256([]byte(query))
+		api.config.PersistedQueryStorage.PersistQuery(ctx, query, hash[:])
+	}
+	return nil
+}
@@ -4,11 +4,19 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/ccbrown/api-fu/graphql"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type persistedQueryMap map[string]string
@@ -36,6 +44,10 @@ func TestPersistedQueryExtension(t *testing.T) {
 		Errors: []*graphql.Error{
 			{
 				Message: "PersistedQueryNotFound",
+				Extensions: map[string]interface{}{
+					"code":      "PERSISTED_QUERY_NOT_FOUND",
+					"retryable": false,
+				},
 			},
 		},
 	}, execute(&graphql.Request{
@@ -66,3 +78,225 @@ func TestPersistedQueryExtension(t *testing.T) {
 		},
 	}))
 }
+
+func TestPersistedQueryExtension_HashMismatch(t *testing.T) {
+	storage := persistedQueryMap{}
+	execute := PersistedQueryExtension(storage, func(r *graphql.Request) *graphql.Response {
+		return &graphql.Response{}
+	})
+
+	otherHash := sha256.Sum256([]byte(`{ foo }`))
+	resp := execute(&graphql.Request{
+		Query: `{ __typename }`,
+		Extensions: map[string]interface{}{
+			"persistedQuery": map[string]interface{}{
+				"version":    1,
+				"sha256Hash": hex.EncodeToString(otherHash[:]),
+			},
+		},
+	})
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "PERSISTED_QUERY_HASH_MISMATCH", resp.Errors[0].Extensions["code"])
+	assert.Empty(t, storage)
+}
+
+func TestPersistedQueryExtension_NotSupported(t *testing.T) {
+	query := `{ __typename }`
+	queryHash := sha256.Sum256([]byte(query))
+	extensions := map[string]interface{}{
+		"persistedQuery": map[string]interface{}{
+			"version":    1,
+			"sha256Hash": hex.EncodeToString(queryHash[:]),
+		},
+	}
+	success := &graphql.Response{}
+	execute := PersistedQueryExtension(nil, func(r *graphql.Request) *graphql.Response {
+		return success
+	})
+
+	resp := execute(&graphql.Request{
+		Extensions: extensions,
+	})
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "PersistedQueryNotSupported", resp.Errors[0].Message)
+	assert.Equal(t, "PERSISTED_QUERY_NOT_SUPPORTED", resp.Errors[0].Extensions["code"])
+
+	assert.Equal(t, success, execute(&graphql.Request{
+		Query:      query,
+		Extensions: extensions,
+	}))
+}
+
+func TestPersistedQueries_HTTP(t *testing.T) {
+	var cfg Config
+	cfg.PersistedQueryStorage = persistedQueryMap{}
+	cfg.AddQueryField("echo", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Arguments: map[string]*graphql.InputValueDefinition{
+			"value": {
+				Type: graphql.NewNonNullType(graphql.StringType),
+			},
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return ctx.Arguments["value"], nil
+		},
+	})
+	api, err := NewAPI(&cfg)
+	require.NoError(t, err)
+
+	query := `query($value: String!) { echo(value: $value) }`
+	queryHash := sha256.Sum256([]byte(query))
+	extensions := `{"persistedQuery":{"version":1,"sha256Hash":"` + hex.EncodeToString(queryHash[:]) + `"}}`
+
+	get := func(params url.Values) string {
+		w := httptest.NewRecorder()
+		api.ServeGraphQL(w, httptest.NewRequest("GET", "/?"+params.Encode(), nil))
+		body, err := io.ReadAll(w.Result().Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	// The client first tries the hash alone.
+	assert.JSONEq(t, `{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND","retryable":false}}]}`, get(url.Values{
+		"variables":  []string{`{"value":"a"}`},
+		"extensions": []string{extensions},
+	}))
+
+	// On a miss, it retries with the query, which registers it.
+	assert.JSONEq(t, `{"data":{"echo":"b"}}`, get(url.Values{
+		"query":      []string{query},
+		"variables":  []string{`{"value":"b"}`},
+		"extensions": []string{extensions},
+	}))
+
+	// Subsequent requests only need the hash.
+	assert.JSONEq(t, `{"data":{"echo":"c"}}`, get(url.Values{
+		"variables":  []string{`{"value":"c"}`},
+		"extensions": []string{extensions},
+	}))
+
+	// Queries can also be registered via POST.
+	otherQuery := `{ echo(value: "d") }`
+	otherHash := sha256.Sum256([]byte(otherQuery))
+	otherExtensions := `{"persistedQuery":{"version":1,"sha256Hash":"` + hex.EncodeToString(otherHash[:]) + `"}}`
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"query":`+strconv.Quote(otherQuery)+`,"extensions":`+otherExtensions+`}`))
+	r.Header.Set("Content-Type", "application/json")
+	api.ServeGraphQL(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data":{"echo":"d"}}`, get(url.Values{
+		"extensions": []string{otherExtensions},
+	}))
+}
+
+func TestLoadPersistedQueryManifest(t *testing.T) {
+	query := `{__typename}`
+	queryHash := sha256.Sum256([]byte(query))
+	queryHashHex := hex.EncodeToString(queryHash[:])
+
+	t.Run("Valid", func(t *testing.T) {
+		storage := persistedQueryMap{}
+		require.NoError(t, LoadPersistedQueryManifest(context.Background(), storage, strings.NewReader(`{"`+queryHashHex+`": "{__typename}"}`)))
+		assert.Equal(t, query, storage.GetPersistedQuery(context.Background(), queryHash[:]))
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		storage := persistedQueryMap{}
+		assert.Error(t, LoadPersistedQueryManifest(context.Background(), storage, strings.NewReader(`{"`+queryHashHex+`": "{foo}"}`)))
+		assert.Empty(t, storage)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		assert.Error(t, LoadPersistedQueryManifest(context.Background(), persistedQueryMap{}, strings.NewReader(`[]`)))
+	})
+}
+
+type testPersistedQueryMetrics struct {
+	mutex   sync.Mutex
+	hits    []string
+	misses  []string
+	evicted []string
+}
+
+func (m *testPersistedQueryMetrics) PersistedQueryHit(ctx context.Context, hash []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.hits = append(m.hits, hex.EncodeToString(hash))
+}
+
+func (m *testPersistedQueryMetrics) PersistedQueryMiss(ctx context.Context, hash []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.misses = append(m.misses, hex.EncodeToString(hash))
+}
+
+func (m *testPersistedQueryMetrics) PersistedQueryEvicted(hash []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.evicted = append(m.evicted, hex.EncodeToString(hash))
+}
+
+func TestPersistedQueryStats(t *testing.T) {
+	metrics := &testPersistedQueryMetrics{}
+	storage := persistedQueryMap{}
+
+	var cfg Config
+	cfg.PersistedQueryStorage = storage
+	cfg.PersistedQueryMetrics = metrics
+	cfg.DocumentCacheSize = 1
+	cfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return 1, nil
+		},
+	})
+	api, err := NewAPI(&cfg)
+	require.NoError(t, err)
+
+	hashHex := func(query string) string {
+		hash := sha256.Sum256([]byte(query))
+		return hex.EncodeToString(hash[:])
+	}
+	get := func(hashHex string) string {
+		w := httptest.NewRecorder()
+		api.ServeGraphQL(w, httptest.NewRequest("GET", "/?"+url.Values{
+			"extensions": []string{`{"persistedQuery":{"version":1,"sha256Hash":"` + hashHex + `"}}`},
+		}.Encode(), nil))
+		body, err := io.ReadAll(w.Result().Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	t.Run("Preload", func(t *testing.T) {
+		assert.Error(t, api.PreloadPersistedQueries(context.Background(), `{foo}`, `{bar}`))
+		assert.Empty(t, storage)
+
+		// The cache only fits one document, so preloading the second evicts the first.
+		require.NoError(t, api.PreloadPersistedQueries(context.Background(), `{foo}`, `{a: foo}`))
+		assert.Len(t, storage, 2)
+		assert.Equal(t, []string{hashHex(`{foo}`)}, metrics.evicted)
+	})
+
+	t.Run("HitsAndMisses", func(t *testing.T) {
+		assert.JSONEq(t, `{"data":{"a":1}}`, get(hashHex(`{a: foo}`)))
+		assert.JSONEq(t, `{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND","retryable":false}}]}`, get(hashHex(`{b: foo}`)))
+		assert.Equal(t, []string{hashHex(`{a: foo}`)}, metrics.hits)
+		assert.Equal(t, []string{hashHex(`{b: foo}`)}, metrics.misses)
+	})
+
+	assert.Equal(t, PersistedQueryStats{
+		Hits:      1,
+		Misses:    1,
+		Evictions: 1,
+	}, api.PersistedQueryStats())
+
+	t.Run("NotConfigured", func(t *testing.T) {
+		var cfg Config
+		cfg.AddQueryField("foo", &graphql.FieldDefinition{
+			Type: graphql.IntType,
+		})
+		api, err := NewAPI(&cfg)
+		require.NoError(t, err)
+		assert.Error(t, api.PreloadPersistedQueries(context.Background(), `{foo}`))
+	})
+}
@@ -0,0 +1,126 @@
+package apifu
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/schema"
+)
+
+// Policy defines an authorization policy. Fields reference policies by name via their Policy
+// property, and the policies themselves are registered via Config.Policies.
+//
+// Policies on interface fields apply to the corresponding fields of every implementation that
+// doesn't specify its own policy.
+type Policy struct {
+	// Authorize is invoked before the field is resolved. The context is the same one that will be
+	// passed to the resolver, so the object and arguments are available. If false is returned, the
+	// field is not resolved.
+	Authorize func(ctx graphql.FieldContext) (bool, error)
+
+	// If true, fields that aren't authorized resolve to null instead of producing a
+	// PermissionDeniedError. This can only be used for nullable fields.
+	OmitUnauthorized bool
+}
+
+// PermissionDeniedError is returned when a policy doesn't permit access to a field. Its extensions
+// contain a "code" of "FORBIDDEN".
+type PermissionDeniedError struct{}
+
+func (err *PermissionDeniedError) Error() string {
+	return "Permission denied."
+}
+
+func (err *PermissionDeniedError) Extensions() map[string]interface{} {
+	return graphql.ErrorCodeForbidden.Extensions()
+}
+
+func authorizeResolver(policy *Policy, resolve func(graphql.FieldContext) (interface{}, error)) func(graphql.FieldContext) (interface{}, error) {
+	return func(ctx graphql.FieldContext) (interface{}, error) {
+		if ok, err := policy.Authorize(ctx); err != nil {
+			return nil, err
+		} else if !ok {
+			if policy.OmitUnauthorized {
+				return nil, nil
+			}
+			return nil, &PermissionDeniedError{}
+		}
+		return resolve(ctx)
+	}
+}
+
+// inspectNamedTypesOnce is like schema.Inspect, but doesn't descend into named types more than once.
+func inspectNamedTypesOnce(def *graphql.SchemaDefinition, f func(interface{}) bool) {
+	visited := map[string]struct{}{}
+	schema.Inspect(def, func(node interface{}) bool {
+		if t, ok := node.(graphql.NamedType); ok {
+			if _, ok := visited[t.TypeName()]; ok {
+				return false
+			}
+			visited[t.TypeName()] = struct{}{}
+		}
+		return f(node)
+	})
+}
+
+func hasPolicies(def *graphql.SchemaDefinition) bool {
+	found := false
+	inspectNamedTypesOnce(def, func(node interface{}) bool {
+		if field, ok := node.(*graphql.FieldDefinition); ok && field.Policy != "" {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// applyPolicies wraps the resolvers of all fields that reference policies. The definition is
+// modified in place, so it should be a clone.
+func applyPolicies(def *graphql.SchemaDefinition, policies map[string]*Policy) error {
+	var objects []*graphql.ObjectType
+	inspectNamedTypesOnce(def, func(node interface{}) bool {
+		if obj, ok := node.(*graphql.ObjectType); ok {
+			objects = append(objects, obj)
+		}
+		return true
+	})
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Name < objects[j].Name
+	})
+
+	for _, obj := range objects {
+		fieldNames := make([]string, 0, len(obj.Fields))
+		for name := range obj.Fields {
+			fieldNames = append(fieldNames, name)
+		}
+		sort.Strings(fieldNames)
+
+		for _, name := range fieldNames {
+			field := obj.Fields[name]
+			policyName := field.Policy
+			if policyName == "" {
+				for _, iface := range obj.ImplementedInterfaces {
+					if ifaceField, ok := iface.Fields[name]; ok && ifaceField.Policy != "" {
+						policyName = ifaceField.Policy
+						break
+					}
+				}
+			}
+			if policyName == "" {
+				continue
+			}
+
+			policy, ok := policies[policyName]
+			if !ok {
+				return fmt.Errorf("%v.%v references undefined policy %v", obj.Name, name, policyName)
+			} else if policy.OmitUnauthorized {
+				if _, ok := field.Type.(*graphql.NonNullType); ok {
+					return fmt.Errorf("%v.%v is non-null and cannot use policy %v, which omits unauthorized fields", obj.Name, name, policyName)
+				}
+			}
+			field.Resolve = authorizeResolver(policy, obj.FieldResolver(name))
+		}
+	}
+	return nil
+}
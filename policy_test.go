@@ -0,0 +1,136 @@
+package apifu
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestPolicies(t *testing.T) {
+	var testCfg Config
+	testCfg.Features = featuresFromContext
+	testCfg.Policies = map[string]*Policy{
+		"admin": {
+			Authorize: func(ctx graphql.FieldContext) (bool, error) {
+				return ctx.Features.Has("admin"), nil
+			},
+		},
+		"adminOrOmit": {
+			Authorize: func(ctx graphql.FieldContext) (bool, error) {
+				return ctx.Features.Has("admin"), nil
+			},
+			OmitUnauthorized: true,
+		},
+		"evenArgument": {
+			Authorize: func(ctx graphql.FieldContext) (bool, error) {
+				return ctx.Arguments["n"].(int)%2 == 0, nil
+			},
+		},
+	}
+
+	secret := &graphql.FieldDefinition{
+		Type:   graphql.StringType,
+		Policy: "admin",
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "secret", nil
+		},
+	}
+	testCfg.AddQueryField("secret", secret)
+	testCfg.AddQueryField("omittable", &graphql.FieldDefinition{
+		Type:   graphql.StringType,
+		Policy: "adminOrOmit",
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "secret", nil
+		},
+	})
+	testCfg.AddQueryField("even", &graphql.FieldDefinition{
+		Type: graphql.IntType,
+		Arguments: map[string]*graphql.InputValueDefinition{
+			"n": {
+				Type: graphql.NewNonNullType(graphql.IntType),
+			},
+		},
+		Policy: "evenArgument",
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return ctx.Arguments["n"], nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	// The original definition should not be modified.
+	_, err = secret.Resolve(graphql.FieldContext{})
+	assert.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Query    string
+		Features []string
+		Expected string
+	}{
+		"Authorized": {
+			Query:    `{secret omittable}`,
+			Features: []string{"admin"},
+			Expected: `{"data":{"secret":"secret","omittable":"secret"}}`,
+		},
+		"Unauthorized": {
+			Query:    `{secret}`,
+			Expected: `{"data":{"secret":null},"errors":[{"message":"Permission denied.","locations":[{"line":1,"column":2}],"path":["secret"],"extensions":{"code":"FORBIDDEN","retryable":false}}]}`,
+		},
+		"Omitted": {
+			Query:    `{omittable}`,
+			Expected: `{"data":{"omittable":null}}`,
+		},
+		"Arguments": {
+			Query:    `{a: even(n: 2) b: even(n: 3)}`,
+			Expected: `{"data":{"a":2,"b":null},"errors":[{"message":"Permission denied.","locations":[{"line":1,"column":16}],"path":["b"],"extensions":{"code":"FORBIDDEN","retryable":false}}]}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := executeGraphQLWithFeatures(t, api, tc.Query, tc.Features)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.Expected, string(body))
+		})
+	}
+}
+
+func TestPolicies_Validation(t *testing.T) {
+	t.Run("Undefined", func(t *testing.T) {
+		var testCfg Config
+		testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+			Type:   graphql.StringType,
+			Policy: "undefined",
+			Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+				return "foo", nil
+			},
+		})
+		_, err := NewAPI(&testCfg)
+		assert.Error(t, err)
+	})
+
+	t.Run("OmitNonNull", func(t *testing.T) {
+		var testCfg Config
+		testCfg.Policies = map[string]*Policy{
+			"omit": {
+				Authorize: func(ctx graphql.FieldContext) (bool, error) {
+					return false, nil
+				},
+				OmitUnauthorized: true,
+			},
+		}
+		testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+			Type:   graphql.NewNonNullType(graphql.StringType),
+			Policy: "omit",
+			Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+				return "foo", nil
+			},
+		})
+		_, err := NewAPI(&testCfg)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,166 @@
+package apifu
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/executor"
+	"github.com/ccbrown/api-fu/graphql/parser"
+)
+
+// RegisteredOperation is an operation defined by the server that clients invoke by name, providing
+// only variables. Unlike persisted queries, clients can't execute arbitrary documents this way, and
+// each operation can have its own authorization. See Config.RegisteredOperations.
+type RegisteredOperation struct {
+	// The GraphQL document containing the operation.
+	Query string
+
+	// If the document contains multiple operations, this selects the one to execute.
+	OperationName string
+
+	// Variables used when the client doesn't provide them. Variables given by the client take
+	// precedence.
+	DefaultVariables map[string]interface{}
+
+	// If given, this is applied in addition to Config.RequestPolicies whenever the operation is
+	// invoked.
+	Policy RequestPolicy
+}
+
+type registeredOperation struct {
+	definition *RegisteredOperation
+}
+
+// Applies the operation's policy to a request that invokes it. The document must already be
+// validated.
+func (op *registeredOperation) authorize(r *http.Request, doc *ast.Document) []*graphql.Error {
+	if op.definition.Policy == nil {
+		return nil
+	}
+	return applyRequestPolicies(r, doc, op.definition.OperationName, op.definition.Policy)
+}
+
+// The number of validated registered operation documents cached per operation. Documents are
+// validated for each feature set that they're invoked with.
+const registeredOperationDocumentCacheSizePerOperation = 8
+
+// Parses the operations in Config.RegisteredOperations so that syntax errors are reported by NewAPI.
+// Validation depends on the requested features, so it's done when the operations are invoked.
+func (api *API) registerOperations() error {
+	if len(api.config.RegisteredOperations) == 0 {
+		return nil
+	}
+	api.registeredOperations = make(map[string]*registeredOperation, len(api.config.RegisteredOperations))
+	for name, def := range api.config.RegisteredOperations {
+		doc, errs := parser.ParseDocument([]byte(def.Query))
+		if len(errs) > 0 {
+			return fmt.Errorf("invalid registered operation %q: %w", name, errs[0])
+		}
+		if _, err := executor.GetOperation(doc, def.OperationName); err != nil {
+			return fmt.Errorf("invalid registered operation %q: %w", name, err)
+		}
+		api.registeredOperations[name] = &registeredOperation{
+			definition: def,
+		}
+	}
+	api.registeredOperationDocumentCache = newDocumentCache(len(api.registeredOperations) * registeredOperationDocumentCacheSizePerOperation)
+	return nil
+}
+
+// Validates the registered operation's document, using the cache so that it's only validated once
+// for each feature set.
+func (api *API) parseAndValidateRegisteredOperation(req *graphql.Request, info *RequestInfo) (*ast.Document, []*graphql.Error) {
+	return api.parseAndValidateWithCache(api.registeredOperationDocumentCache, req, info, false)
+}
+
+// ServeRegisteredOperation serves requests that invoke operations registered via
+// Config.RegisteredOperations. Requests are POST requests with JSON bodies like the following:
+//
+//	{"operationName": "GetUser", "variables": {"id": "..."}}
+//
+// Requests may also include "extensions". Responses are the same as those written by
+// ServeGraphQL. If no operation is registered with the given name, the response contains an error
+// with a "code" of "OPERATION_NOT_FOUND".
+//
+// Operations are validated and subject to the same rules as they would be if the document were
+// sent to ServeGraphQL, and validated documents are cached.
+//
+// Request bodies aren't limited. Use RegisteredOperationHandler to reject large bodies.
+func (api *API) ServeRegisteredOperation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), apiContextKey, api)
+	apiRequest := &apiRequest{}
+	ctx = context.WithValue(ctx, apiRequestContextKey, apiRequest)
+	r = r.WithContext(ctx)
+
+	codec := api.jsonCodec()
+	var body struct {
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+		Extensions    map[string]interface{} `json:"extensions"`
+	}
+	if b, err := ioutil.ReadAll(r.Body); err != nil {
+		http.Error(w, "unable to read request body", http.StatusBadRequest)
+		return
+	} else if err := codec.Unmarshal(b, &body); err != nil {
+		http.Error(w, "malformed request body", http.StatusBadRequest)
+		return
+	}
+
+	registered := api.registeredOperations[body.OperationName]
+	if registered == nil {
+		api.writeGraphQLResponse(w, r, codec, &graphql.Response{
+			Errors: []*graphql.Error{
+				{
+					Message:    "Operation not found.",
+					Extensions: graphql.ErrorCodeOperationNotFound.Extensions(),
+				},
+			},
+		})
+		return
+	}
+
+	variables := make(map[string]interface{}, len(registered.definition.DefaultVariables)+len(body.Variables))
+	for name, value := range registered.definition.DefaultVariables {
+		variables[name] = value
+	}
+	for name, value := range body.Variables {
+		variables[name] = value
+	}
+
+	api.serveGraphQLRequest(w, r, codec, &graphql.Request{
+		Context:        ctx,
+		Query:          registered.definition.Query,
+		OperationName:  registered.definition.OperationName,
+		VariableValues: variables,
+		Extensions:     body.Extensions,
+	}, apiRequest, registered)
+}
+
+// RegisteredOperationHandler returns an http.Handler that serves registered operations via
+// ServeRegisteredOperation. Only POST requests are supported, so AllowedMethods is ignored. Bodies
+// are limited to MaxBodySize bytes via http.MaxBytesReader.
+func (api *API) RegisteredOperationHandler(opts *HandlerOptions) http.Handler {
+	operationOpts := &HandlerOptions{
+		AllowedMethods: []string{http.MethodPost},
+	}
+	if opts != nil {
+		operationOpts.CORS = opts.CORS
+		operationOpts.MaxBodySize = opts.MaxBodySize
+		operationOpts.ReadOnly = opts.ReadOnly
+		operationOpts.DisallowSubscriptions = opts.DisallowSubscriptions
+	}
+	return &apiHandler{
+		options: operationOpts,
+		serve:   api.ServeRegisteredOperation,
+	}
+}
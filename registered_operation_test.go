@@ -0,0 +1,160 @@
+package apifu
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/ast"
+)
+
+func TestServeRegisteredOperation(t *testing.T) {
+	var testCfg Config
+	testCfg.AddQueryField("greeting", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Arguments: map[string]*graphql.InputValueDefinition{
+			"name": {
+				Type: graphql.NewNonNullType(graphql.StringType),
+			},
+			"punctuation": {
+				Type: graphql.NewNonNullType(graphql.StringType),
+			},
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "Hello, " + ctx.Arguments["name"].(string) + ctx.Arguments["punctuation"].(string), nil
+		},
+	})
+	testCfg.RegisteredOperations = map[string]*RegisteredOperation{
+		"Greet": {
+			Query:         `query Other { __typename } query Greet($name: String!, $punctuation: String!) { greeting(name: $name, punctuation: $punctuation) }`,
+			OperationName: "Greet",
+			DefaultVariables: map[string]interface{}{
+				"punctuation": "!",
+			},
+		},
+		"Restricted": {
+			Query: `{ __typename }`,
+			Policy: func(r *http.Request, doc *ast.Document, op *ast.OperationDefinition) error {
+				if r.Header.Get("X-Admin") == "" {
+					return &PermissionDeniedError{}
+				}
+				return nil
+			},
+		},
+	}
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	handler := api.RegisteredOperationHandler(&HandlerOptions{
+		MaxBodySize: 100,
+	})
+
+	for name, tc := range map[string]struct {
+		Method         string
+		Header         http.Header
+		Body           string
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		"OK": {
+			Body:         `{"operationName":"Greet","variables":{"name":"Alice"}}`,
+			ExpectedBody: `{"data":{"greeting":"Hello, Alice!"}}`,
+		},
+		"OverriddenDefault": {
+			Body:         `{"operationName":"Greet","variables":{"name":"Alice","punctuation":"?"}}`,
+			ExpectedBody: `{"data":{"greeting":"Hello, Alice?"}}`,
+		},
+		"MissingVariable": {
+			Body:         `{"operationName":"Greet"}`,
+			ExpectedBody: `{"errors":[{"message":"Validation error: The name variable is required.","locations":[{"line":1,"column":28}]}]}`,
+		},
+		"NotFound": {
+			Body:         `{"operationName":"Nope"}`,
+			ExpectedBody: `{"errors":[{"message":"Operation not found.","extensions":{"code":"OPERATION_NOT_FOUND","retryable":false}}]}`,
+		},
+		"Unauthorized": {
+			Body:         `{"operationName":"Restricted"}`,
+			ExpectedBody: `{"errors":[{"message":"Permission denied.","locations":[{"line":1,"column":1}],"extensions":{"code":"FORBIDDEN","retryable":false}}]}`,
+		},
+		"Authorized": {
+			Header: http.Header{
+				"X-Admin": []string{"1"},
+			},
+			Body:         `{"operationName":"Restricted"}`,
+			ExpectedBody: `{"data":{"__typename":"Query"}}`,
+		},
+		"MalformedBody": {
+			Body:           `{`,
+			ExpectedStatus: http.StatusBadRequest,
+		},
+		"MethodNotAllowed": {
+			Method:         http.MethodGet,
+			ExpectedStatus: http.StatusMethodNotAllowed,
+		},
+		"TooLarge": {
+			Body:           `{"operationName":"Greet","variables":{"name":"` + strings.Repeat("a", 100) + `"}}`,
+			ExpectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			method := tc.Method
+			if method == "" {
+				method = http.MethodPost
+			}
+			r := httptest.NewRequest(method, "/", strings.NewReader(tc.Body))
+			for k, v := range tc.Header {
+				r.Header[k] = v
+			}
+			r.Header.Set("Content-Type", "application/json")
+			// Make sure the body's length is enforced as it's read.
+			r.ContentLength = -1
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			resp := w.Result()
+			expectedStatus := tc.ExpectedStatus
+			if expectedStatus == 0 {
+				expectedStatus = http.StatusOK
+			}
+			require.Equal(t, expectedStatus, resp.StatusCode)
+			if tc.ExpectedBody != "" {
+				body, err := ioutil.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, tc.ExpectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestRegisteredOperations_Invalid(t *testing.T) {
+	for name, op := range map[string]*RegisteredOperation{
+		"Syntax": {
+			Query: `{`,
+		},
+		"MissingOperation": {
+			Query:         `query A { __typename }`,
+			OperationName: "B",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var testCfg Config
+			testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+				Type: graphql.StringType,
+				Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+					return "bar", nil
+				},
+			})
+			testCfg.RegisteredOperations = map[string]*RegisteredOperation{
+				"Op": op,
+			}
+			_, err := NewAPI(&testCfg)
+			assert.Error(t, err)
+		})
+	}
+}
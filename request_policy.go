@@ -0,0 +1,201 @@
+package apifu
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/executor"
+)
+
+// RequestPolicy decides whether an HTTP request may execute an operation. It's given the request,
+// the validated document, and the operation selected from it. If it returns an error, the operation
+// isn't executed and the response contains a single error with the error's message. If the error
+// implements graphql.ExtendedError, its extensions are included. Otherwise the extensions have a
+// "code" of "FORBIDDEN".
+//
+// Policies are registered via Config.RequestPolicies and are applied after validation and before
+// execution by every transport: ServeGraphQL, ServeConnect, ServeWebhookSubscriptions, and
+// ServeGraphQLWS. For WebSocket connections, the request is the one that was upgraded, and the
+// policies are applied to each operation started on the connection.
+type RequestPolicy func(r *http.Request, doc *ast.Document, op *ast.OperationDefinition) error
+
+// RequestPolicyError is returned by the built-in request policies. Its extensions contain the given
+// "code".
+type RequestPolicyError struct {
+	Code    graphql.ErrorCode
+	Message string
+}
+
+func (err *RequestPolicyError) Error() string {
+	return err.Message
+}
+
+func (err *RequestPolicyError) Extensions() map[string]interface{} {
+	return err.Code.Extensions()
+}
+
+// AllRequestPolicies returns a policy that requires every one of the given policies to pass. They're
+// applied in order, and the first error is returned.
+func AllRequestPolicies(policies ...RequestPolicy) RequestPolicy {
+	return func(r *http.Request, doc *ast.Document, op *ast.OperationDefinition) error {
+		for _, policy := range policies {
+			if err := policy(r, doc, op); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// AnyRequestPolicy returns a policy that passes if at least one of the given policies passes. If
+// none of them do, the last error is returned.
+func AnyRequestPolicy(policies ...RequestPolicy) RequestPolicy {
+	return func(r *http.Request, doc *ast.Document, op *ast.OperationDefinition) error {
+		var err error
+		for _, policy := range policies {
+			if err = policy(r, doc, op); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// IntrospectionOnlyFrom returns a policy that rejects operations that select the __schema or
+// __type fields unless the request's remote address is within one of the given networks. Rejected
+// operations produce an error with a "code" of "FORBIDDEN".
+//
+// The address is taken from http.Request.RemoteAddr. If the API is behind a proxy, it should be
+// rewritten with the client's address before the request is served.
+func IntrospectionOnlyFrom(networks ...netip.Prefix) RequestPolicy {
+	return func(r *http.Request, doc *ast.Document, op *ast.OperationDefinition) error {
+		if !selectsIntrospection(doc, op) {
+			return nil
+		}
+		if addr, ok := remoteAddr(r); ok {
+			for _, network := range networks {
+				if network.Contains(addr) {
+					return nil
+				}
+			}
+		}
+		return &RequestPolicyError{
+			Code:    graphql.ErrorCodeForbidden,
+			Message: "Introspection is not allowed from this network.",
+		}
+	}
+}
+
+// MutationsRequireHeader returns a policy that rejects mutations unless the request has a non-empty
+// header with the given name. Rejected operations produce an error with a "code" of "FORBIDDEN".
+// This can be used to defend against cross-site request forgery, as browsers won't send custom
+// headers with cross-origin requests without a preflight.
+func MutationsRequireHeader(name string) RequestPolicy {
+	return func(r *http.Request, doc *ast.Document, op *ast.OperationDefinition) error {
+		if op.OperationType == nil || op.OperationType.Value != "mutation" || r.Header.Get(name) != "" {
+			return nil
+		}
+		return &RequestPolicyError{
+			Code:    graphql.ErrorCodeForbidden,
+			Message: "Mutations require the " + http.CanonicalHeaderKey(name) + " header.",
+		}
+	}
+}
+
+// Returns the address of the client that made the request.
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// Returns true if the operation selects the __schema or __type fields, directly or via fragments.
+func selectsIntrospection(doc *ast.Document, op *ast.OperationDefinition) bool {
+	fragments := map[string]*ast.FragmentDefinition{}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[def.Name.Name] = def
+		}
+	}
+	visited := map[string]struct{}{}
+	var selects func(selectionSet *ast.SelectionSet) bool
+	selects = func(selectionSet *ast.SelectionSet) bool {
+		if selectionSet == nil {
+			return false
+		}
+		for _, selection := range selectionSet.Selections {
+			switch selection := selection.(type) {
+			case *ast.Field:
+				if name := selection.Name.Name; name == "__schema" || name == "__type" || selects(selection.SelectionSet) {
+					return true
+				}
+			case *ast.InlineFragment:
+				if selects(selection.SelectionSet) {
+					return true
+				}
+			case *ast.FragmentSpread:
+				name := selection.FragmentName.Name
+				if _, ok := visited[name]; ok {
+					continue
+				}
+				visited[name] = struct{}{}
+				if fragment := fragments[name]; fragment != nil && selects(fragment.SelectionSet) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return selects(op.SelectionSet)
+}
+
+// Applies Config.RequestPolicies to the operation selected by the request. The document must
+// already be validated.
+func (api *API) applyRequestPolicies(r *http.Request, req *graphql.Request, doc *ast.Document) []*graphql.Error {
+	return applyRequestPolicies(r, doc, req.OperationName, api.config.RequestPolicies...)
+}
+
+// Applies the policies to the operation with the given name, returning an error for the first one
+// that fails.
+func applyRequestPolicies(r *http.Request, doc *ast.Document, operationName string, policies ...RequestPolicy) []*graphql.Error {
+	if len(policies) == 0 {
+		return nil
+	}
+	op, opErr := executor.GetOperation(doc, operationName)
+	if opErr != nil {
+		// Execution will report the error.
+		return nil
+	}
+	for _, policy := range policies {
+		if err := policy(r, doc, op); err != nil {
+			pos := op.Position()
+			ret := &graphql.Error{
+				Message: err.Error(),
+				Locations: []graphql.Location{
+					{
+						Line:   pos.Line,
+						Column: pos.Column,
+					},
+				},
+			}
+			var ext graphql.ExtendedError
+			if errors.As(err, &ext) {
+				ret.Extensions = ext.Extensions()
+			} else {
+				ret.Extensions = graphql.ErrorCodeForbidden.Extensions()
+			}
+			return []*graphql.Error{ret}
+		}
+	}
+	return nil
+}
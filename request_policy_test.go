@@ -0,0 +1,226 @@
+package apifu
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/ast"
+	"github.com/ccbrown/api-fu/graphql/transport/connect"
+	"github.com/ccbrown/api-fu/graphql/transport/graphqltransportws"
+)
+
+func TestRequestPolicies(t *testing.T) {
+	var testCfg Config
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "bar", nil
+		},
+	})
+	testCfg.AddMutation("foo", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "bar", nil
+		},
+	})
+	testCfg.RequestPolicies = []RequestPolicy{
+		IntrospectionOnlyFrom(netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")),
+		MutationsRequireHeader("X-Requested-With"),
+		func(r *http.Request, doc *ast.Document, op *ast.OperationDefinition) error {
+			if op.Name != nil && op.Name.Name == "Blocked" {
+				return assert.AnError
+			}
+			return nil
+		},
+	}
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		RemoteAddr   string
+		Header       http.Header
+		Body         string
+		ExpectedBody string
+	}{
+		"Query": {
+			RemoteAddr:   "203.0.113.1:1234",
+			Body:         `{foo __typename}`,
+			ExpectedBody: `{"data":{"foo":"bar","__typename":"Query"}}`,
+		},
+		"IntrospectionAllowed": {
+			RemoteAddr:   "10.1.2.3:1234",
+			Body:         `{__type(name: "Query") { name }}`,
+			ExpectedBody: `{"data":{"__type":{"name":"Query"}}}`,
+		},
+		"IntrospectionAllowedIPv6": {
+			RemoteAddr:   "[::1]:1234",
+			Body:         `{__type(name: "Query") { name }}`,
+			ExpectedBody: `{"data":{"__type":{"name":"Query"}}}`,
+		},
+		"IntrospectionDenied": {
+			RemoteAddr:   "203.0.113.1:1234",
+			Body:         `{...F} fragment F on Query { __schema { queryType { name } } }`,
+			ExpectedBody: `{"errors":[{"message":"Introspection is not allowed from this network.","locations":[{"line":1,"column":1}],"extensions":{"code":"FORBIDDEN","retryable":false}}]}`,
+		},
+		"MutationAllowed": {
+			RemoteAddr: "203.0.113.1:1234",
+			Header: http.Header{
+				"X-Requested-With": []string{"test"},
+			},
+			Body:         `mutation {foo}`,
+			ExpectedBody: `{"data":{"foo":"bar"}}`,
+		},
+		"MutationDenied": {
+			RemoteAddr:   "203.0.113.1:1234",
+			Body:         `mutation {foo}`,
+			ExpectedBody: `{"errors":[{"message":"Mutations require the X-Requested-With header.","locations":[{"line":1,"column":1}],"extensions":{"code":"FORBIDDEN","retryable":false}}]}`,
+		},
+		"Custom": {
+			RemoteAddr:   "203.0.113.1:1234",
+			Body:         `query Blocked {foo}`,
+			ExpectedBody: `{"errors":[{"message":"` + assert.AnError.Error() + `","locations":[{"line":1,"column":1}],"extensions":{"code":"FORBIDDEN","retryable":false}}]}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.Body))
+			r.RemoteAddr = tc.RemoteAddr
+			for k, v := range tc.Header {
+				r.Header[k] = v
+			}
+			r.Header.Set("Content-Type", "application/graphql")
+			w := httptest.NewRecorder()
+			api.ServeGraphQL(w, r)
+			resp := w.Result()
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.ExpectedBody, string(body))
+		})
+	}
+}
+
+func TestAnyRequestPolicy(t *testing.T) {
+	doc := &ast.Document{}
+	op := &ast.OperationDefinition{
+		OperationType: &ast.OperationType{Value: "mutation"},
+	}
+	policy := AnyRequestPolicy(
+		MutationsRequireHeader("X-A"),
+		MutationsRequireHeader("X-B"),
+	)
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	assert.Error(t, policy(r, doc, op))
+
+	r.Header.Set("X-B", "1")
+	assert.NoError(t, policy(r, doc, op))
+	assert.Error(t, AllRequestPolicies(MutationsRequireHeader("X-A"), MutationsRequireHeader("X-B"))(r, doc, op))
+}
+
+func TestRequestPolicies_Transports(t *testing.T) {
+	var testCfg Config
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return "bar", nil
+		},
+	})
+	testCfg.AddSubscription("oneEvent", oneEventSubscription)
+	testCfg.Webhooks = &WebhookConfig{
+		CheckCallbackURL: func(ctx context.Context, u *url.URL) error {
+			return nil
+		},
+	}
+	testCfg.RequestPolicies = []RequestPolicy{
+		func(r *http.Request, doc *ast.Document, op *ast.OperationDefinition) error {
+			if r.Header.Get("X-Allow") == "" {
+				return &RequestPolicyError{
+					Code:    graphql.ErrorCodeForbidden,
+					Message: "Not allowed.",
+				}
+			}
+			return nil
+		},
+	}
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+	defer api.CloseHijackedConnections()
+	defer api.CloseWebhookSubscriptions()
+
+	const expectedError = `{"message":"Not allowed.","locations":[{"line":1,"column":1}],"extensions":{"code":"FORBIDDEN","retryable":false}}`
+
+	t.Run("Connect", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/rpc"+connect.ExecuteProcedure, strings.NewReader(`{"query":"{foo}"}`))
+		r.Header.Set("Content-Type", connect.UnaryContentType)
+		w := httptest.NewRecorder()
+		api.ServeConnect(w, r)
+		body, err := ioutil.ReadAll(w.Result().Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"errors":[`+expectedError+`]}`, string(body))
+	})
+
+	t.Run("Webhook", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"query":"subscription {oneEvent}","callbackURL":"http://example.com"}`))
+		w := httptest.NewRecorder()
+		api.ServeWebhookSubscriptions(w, r)
+		resp := w.Result()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"errors":[`+expectedError+`]}`, string(body))
+	})
+
+	t.Run("GraphQLWS", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(api.ServeGraphQLWS))
+		defer ts.Close()
+
+		dial := func(header http.Header) *websocket.Conn {
+			dialer := &websocket.Dialer{
+				HandshakeTimeout: time.Second,
+				Subprotocols:     []string{graphqltransportws.WebSocketSubprotocol},
+			}
+			conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), header)
+			require.NoError(t, err)
+			require.NoError(t, conn.WriteJSON(map[string]string{
+				"type": "connection_init",
+			}))
+			var msg graphqltransportws.Message
+			require.NoError(t, conn.ReadJSON(&msg))
+			require.Equal(t, graphqltransportws.MessageTypeConnectionAck, msg.Type)
+			require.NoError(t, conn.WriteJSON(map[string]interface{}{
+				"id":   "sub",
+				"type": "subscribe",
+				"payload": map[string]interface{}{
+					"query": `subscription {oneEvent}`,
+				},
+			}))
+			return conn
+		}
+
+		conn := dial(nil)
+		defer conn.Close()
+		var msg graphqltransportws.Message
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, graphqltransportws.MessageTypeNext, msg.Type)
+		assert.JSONEq(t, `{"errors":[`+expectedError+`]}`, string(msg.Payload))
+
+		conn = dial(http.Header{"X-Allow": []string{"1"}})
+		defer conn.Close()
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, graphqltransportws.MessageTypeNext, msg.Type)
+		assert.JSONEq(t, `{"data":{"oneEvent":1}}`, string(msg.Payload))
+	})
+}
@@ -9,41 +9,189 @@ import (
 	"github.com/ccbrown/api-fu/graphql/ast"
 )
 
-func parseDateTime(v interface{}) interface{} {
+// DefaultDateTimeInputLayouts are the layouts accepted by DateTimeType. In addition to RFC-3339, they
+// accept numeric offsets without a colon (e.g. "+0000") or without minutes (e.g. "+00").
+var DefaultDateTimeInputLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999Z07",
+}
+
+// DateTimeConfig defines the configuration for a DateTime scalar created with NewDateTimeType.
+type DateTimeConfig struct {
+	// The name of the scalar. If empty, "DateTime" is used.
+	Name string
+
+	// The description of the scalar. If empty, a description for RFC-3339 datetimes is used.
+	Description string
+
+	// The layouts that inputs may be formatted with, in the format used by time.Parse. They are
+	// tried in order. If empty, DefaultDateTimeInputLayouts is used.
+	InputLayouts []string
+
+	// The layout that results are formatted with, in the format used by time.Format. If empty,
+	// time.RFC3339Nano is used.
+	OutputLayout string
+
+	// If non-zero, results are truncated to a multiple of this duration before they're formatted.
+	// For example, time.Millisecond can be used to limit output to millisecond precision.
+	Precision time.Duration
+}
+
+func parseTime(layouts []string, v interface{}) interface{} {
 	switch v := v.(type) {
 	case []byte:
-		t := time.Time{}
-		if err := t.UnmarshalText(v); err == nil {
-			return t
-		}
-		return nil
+		return parseTime(layouts, string(v))
 	case string:
-		return parseDateTime([]byte(v))
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
 	}
 	return nil
 }
 
+// NewDateTimeType creates a new DateTime scalar with the given configuration.
+func NewDateTimeType(config *DateTimeConfig) *graphql.ScalarType {
+	name := config.Name
+	if name == "" {
+		name = "DateTime"
+	}
+	description := config.Description
+	if description == "" {
+		description = "DateTime represents an RFC-3339 datetime."
+	}
+	inputLayouts := config.InputLayouts
+	if len(inputLayouts) == 0 {
+		inputLayouts = DefaultDateTimeInputLayouts
+	}
+	outputLayout := config.OutputLayout
+	if outputLayout == "" {
+		outputLayout = time.RFC3339Nano
+	}
+	precision := config.Precision
+
+	return &graphql.ScalarType{
+		Name:        name,
+		Description: description,
+		LiteralCoercion: func(v ast.Value) interface{} {
+			switch v := v.(type) {
+			case *ast.StringValue:
+				return parseTime(inputLayouts, v.Value)
+			}
+			return nil
+		},
+		VariableValueCoercion: func(v interface{}) interface{} {
+			return parseTime(inputLayouts, v)
+		},
+		ResultCoercion: func(v interface{}) interface{} {
+			switch v := v.(type) {
+			case time.Time:
+				if precision > 0 {
+					v = v.Truncate(precision)
+				}
+				return v.Format(outputLayout)
+			}
+			return nil
+		},
+		Metadata: map[interface{}]interface{}{
+			dateTimeTypeKey: struct{}{},
+		},
+	}
+}
+
 // DateTimeType provides a DateTime implementation that serializing to and from RFC-3339 datetimes.
-var DateTimeType = &graphql.ScalarType{
-	Name:        "DateTime",
-	Description: "DateTime represents an RFC-3339 datetime.",
+// Use NewDateTimeType if you need to customize the accepted formats or precision.
+var DateTimeType = NewDateTimeType(&DateTimeConfig{})
+
+const dateLayout = "2006-01-02"
+
+// DateType provides a Date implementation that serializes to and from RFC-3339 full-dates such as
+// "2019-12-01". Inputs are coerced to time.Time values at midnight UTC, and results may be any
+// time.Time value.
+var DateType = &graphql.ScalarType{
+	Name:        "Date",
+	Description: "Date represents an RFC-3339 full-date such as \"2019-12-01\".",
+	LiteralCoercion: func(v ast.Value) interface{} {
+		switch v := v.(type) {
+		case *ast.StringValue:
+			return parseTime([]string{dateLayout}, v.Value)
+		}
+		return nil
+	},
+	VariableValueCoercion: func(v interface{}) interface{} {
+		return parseTime([]string{dateLayout}, v)
+	},
+	ResultCoercion: func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case time.Time:
+			return v.Format(dateLayout)
+		}
+		return nil
+	},
+}
+
+const timeLayout = "15:04:05.999999999"
+
+// TimeType provides a Time implementation that serializes to and from RFC-3339 partial-times such as
+// "01:23:45" or "01:23:45.6". Inputs are coerced to time.Time values on January 1 of year 0, as
+// produced by time.Parse, and results may be any time.Time value.
+var TimeType = &graphql.ScalarType{
+	Name:        "Time",
+	Description: "Time represents an RFC-3339 partial-time such as \"01:23:45\" or \"01:23:45.6\".",
 	LiteralCoercion: func(v ast.Value) interface{} {
 		switch v := v.(type) {
 		case *ast.StringValue:
-			return parseDateTime(v.Value)
+			return parseTime([]string{timeLayout}, v.Value)
 		}
 		return nil
 	},
-	VariableValueCoercion: parseDateTime,
+	VariableValueCoercion: func(v interface{}) interface{} {
+		return parseTime([]string{timeLayout}, v)
+	},
 	ResultCoercion: func(v interface{}) interface{} {
 		switch v := v.(type) {
 		case time.Time:
-			if b, err := v.MarshalText(); err == nil {
-				return string(b)
+			return v.Format(timeLayout)
+		}
+		return nil
+	},
+}
+
+func coerceUnixTimestamp(v interface{}) interface{} {
+	if _, ok := v.(bool); ok {
+		return nil
+	}
+	if n, ok := coerceLongInt(v).(int64); ok {
+		return time.Unix(n, 0).UTC()
+	}
+	return nil
+}
+
+// UnixTimestampType provides a UnixTimestamp implementation that serializes to and from integers
+// representing the number of seconds since the Unix epoch. Inputs are coerced to time.Time values
+// in UTC, and results may be any time.Time value. Sub-second precision is discarded.
+var UnixTimestampType = &graphql.ScalarType{
+	Name:        "UnixTimestamp",
+	Description: "UnixTimestamp represents a point in time as the number of seconds since the Unix epoch.",
+	LiteralCoercion: func(v ast.Value) interface{} {
+		switch v := v.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.ParseInt(v.Value, 10, 64); err == nil && n >= minSafeInteger && n <= maxSafeInteger {
+				return time.Unix(n, 0).UTC()
 			}
 		}
 		return nil
 	},
+	VariableValueCoercion: coerceUnixTimestamp,
+	ResultCoercion: func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case time.Time:
+			return v.Unix()
+		}
+		return nil
+	},
 }
 
 // NonZeroDateTime returns a field definition that resolves to the value of the field with the given
@@ -13,6 +13,76 @@ func TestDateTimeType(t *testing.T) {
 	assert.Equal(t, time.Date(2019, time.December, 1, 1, 23, 45, 600000000, time.UTC), DateTimeType.LiteralCoercion(&ast.StringValue{
 		Value: "2019-12-01T01:23:45.6Z",
 	}))
+
+	for _, input := range []string{
+		"2019-12-01T02:23:45.6+01:00",
+		"2019-12-01T02:23:45.6+0100",
+		"2019-12-01T02:23:45.6+01",
+	} {
+		v := DateTimeType.VariableValueCoercion(input)
+		if assert.IsType(t, time.Time{}, v, input) {
+			assert.True(t, time.Date(2019, time.December, 1, 1, 23, 45, 600000000, time.UTC).Equal(v.(time.Time)), input)
+		}
+	}
+
+	assert.Nil(t, DateTimeType.VariableValueCoercion("2019-12-01"))
+	assert.Nil(t, DateTimeType.LiteralCoercion(&ast.IntValue{Value: "1"}))
+
+	assert.Equal(t, "2019-12-01T01:23:45.123456789Z", DateTimeType.ResultCoercion(time.Date(2019, time.December, 1, 1, 23, 45, 123456789, time.UTC)))
+}
+
+func TestNewDateTimeType(t *testing.T) {
+	dateTimeType := NewDateTimeType(&DateTimeConfig{
+		Name:         "Timestamp",
+		InputLayouts: []string{"2006-01-02 15:04:05"},
+		OutputLayout: "2006-01-02T15:04:05.000Z07:00",
+		Precision:    time.Millisecond,
+	})
+	assert.Equal(t, "Timestamp", dateTimeType.Name)
+
+	assert.Equal(t, time.Date(2019, time.December, 1, 1, 23, 45, 0, time.UTC), dateTimeType.VariableValueCoercion("2019-12-01 01:23:45"))
+	assert.Nil(t, dateTimeType.VariableValueCoercion("2019-12-01T01:23:45Z"))
+
+	assert.Equal(t, "2019-12-01T01:23:45.123Z", dateTimeType.ResultCoercion(time.Date(2019, time.December, 1, 1, 23, 45, 123456789, time.UTC)))
+	assert.Equal(t, "2019-12-01T01:23:45.000Z", dateTimeType.ResultCoercion(time.Date(2019, time.December, 1, 1, 23, 45, 0, time.UTC)))
+}
+
+func TestDateType(t *testing.T) {
+	assert.Equal(t, time.Date(2019, time.December, 1, 0, 0, 0, 0, time.UTC), DateType.LiteralCoercion(&ast.StringValue{
+		Value: "2019-12-01",
+	}))
+	assert.Equal(t, time.Date(2019, time.December, 1, 0, 0, 0, 0, time.UTC), DateType.VariableValueCoercion("2019-12-01"))
+	assert.Nil(t, DateType.VariableValueCoercion("2019-12-01T01:23:45Z"))
+	assert.Nil(t, DateType.VariableValueCoercion(1))
+
+	assert.Equal(t, "2019-12-01", DateType.ResultCoercion(time.Date(2019, time.December, 1, 1, 23, 45, 0, time.UTC)))
+	assert.Nil(t, DateType.ResultCoercion("2019-12-01"))
+}
+
+func TestTimeType(t *testing.T) {
+	assert.Equal(t, time.Date(0, time.January, 1, 1, 23, 45, 600000000, time.UTC), TimeType.LiteralCoercion(&ast.StringValue{
+		Value: "01:23:45.6",
+	}))
+	assert.Equal(t, time.Date(0, time.January, 1, 1, 23, 45, 0, time.UTC), TimeType.VariableValueCoercion("01:23:45"))
+	assert.Nil(t, TimeType.VariableValueCoercion("25:00:00"))
+
+	assert.Equal(t, "01:23:45", TimeType.ResultCoercion(time.Date(2019, time.December, 1, 1, 23, 45, 0, time.UTC)))
+	assert.Equal(t, "01:23:45.6", TimeType.ResultCoercion(time.Date(2019, time.December, 1, 1, 23, 45, 600000000, time.UTC)))
+}
+
+func TestUnixTimestampType(t *testing.T) {
+	assert.Equal(t, time.Date(2019, time.December, 1, 1, 23, 45, 0, time.UTC), UnixTimestampType.LiteralCoercion(&ast.IntValue{
+		Value: "1575163425",
+	}))
+	assert.Nil(t, UnixTimestampType.LiteralCoercion(&ast.StringValue{
+		Value: "1575163425",
+	}))
+
+	assert.Equal(t, time.Date(2019, time.December, 1, 1, 23, 45, 0, time.UTC), UnixTimestampType.VariableValueCoercion(float64(1575163425)))
+	assert.Nil(t, UnixTimestampType.VariableValueCoercion(1575163425.5))
+	assert.Nil(t, UnixTimestampType.VariableValueCoercion(true))
+
+	assert.Equal(t, int64(1575163425), UnixTimestampType.ResultCoercion(time.Date(2019, time.December, 1, 1, 23, 45, 600000000, time.UTC)))
 }
 
 func TestLongIntType(t *testing.T) {
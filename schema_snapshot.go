@@ -0,0 +1,78 @@
+package apifu
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ccbrown/api-fu/graphql/sdl"
+)
+
+// SchemaSDL builds the schema defined by the given config and serializes it as an SDL document.
+// The output is deterministic, so it can be committed and compared against in tests.
+func SchemaSDL(cfg *Config) (string, error) {
+	schema, err := cfg.graphqlSchema()
+	if err != nil {
+		return "", fmt.Errorf("error building graphql schema: %w", err)
+	}
+	return sdl.Print(schema)
+}
+
+// SchemaFingerprint returns a hash of the schema defined by the given config. The fingerprint only
+// changes if the schema's SDL representation changes.
+func SchemaFingerprint(cfg *Config) (string, error) {
+	s, err := SchemaSDL(cfg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// CheckSchemaGolden compares the SDL representation of the schema defined by the given config to
+// the contents of the file at the given path. If they differ, an error describing the first
+// difference is returned. If update is true, the file is written instead.
+//
+// This is intended to be used in tests to catch unexpected schema changes:
+//
+//	require.NoError(t, apifu.CheckSchemaGolden(&cfg, "testdata/schema.graphql", os.Getenv("UPDATE_GOLDEN") != ""))
+func CheckSchemaGolden(cfg *Config, path string, update bool) error {
+	s, err := SchemaSDL(cfg)
+	if err != nil {
+		return err
+	}
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, []byte(s), 0644)
+	}
+
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading golden file: %w", err)
+	} else if bytes.Equal(golden, []byte(s)) {
+		return nil
+	}
+
+	expected := strings.Split(string(golden), "\n")
+	actual := strings.Split(s, "\n")
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		var e, a string
+		if i < len(expected) {
+			e = expected[i]
+		}
+		if i < len(actual) {
+			a = actual[i]
+		}
+		if e != a {
+			return fmt.Errorf("schema differs from %v at line %v:\n- %v\n+ %v", path, i+1, e, a)
+		}
+	}
+	return fmt.Errorf("schema differs from %v", path)
+}
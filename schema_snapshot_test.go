@@ -0,0 +1,52 @@
+package apifu
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestSchemaSnapshot(t *testing.T) {
+	newConfig := func(fieldType graphql.Type) *Config {
+		var cfg Config
+		cfg.AddQueryField("b", &graphql.FieldDefinition{
+			Type: graphql.IntType,
+		})
+		cfg.AddQueryField("a", &graphql.FieldDefinition{
+			Type: fieldType,
+		})
+		return &cfg
+	}
+
+	s, err := SchemaSDL(newConfig(graphql.StringType))
+	require.NoError(t, err)
+	assert.Contains(t, s, "type Query {\n  a: String\n  b: Int\n")
+
+	fingerprint, err := SchemaFingerprint(newConfig(graphql.StringType))
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		other, err := SchemaFingerprint(newConfig(graphql.StringType))
+		require.NoError(t, err)
+		assert.Equal(t, fingerprint, other)
+	}
+	other, err := SchemaFingerprint(newConfig(graphql.IntType))
+	require.NoError(t, err)
+	assert.NotEqual(t, fingerprint, other)
+
+	path := filepath.Join(t.TempDir(), "testdata", "schema.graphql")
+	assert.Error(t, CheckSchemaGolden(newConfig(graphql.StringType), path, false))
+	require.NoError(t, CheckSchemaGolden(newConfig(graphql.StringType), path, true))
+	golden, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, s, string(golden))
+	assert.NoError(t, CheckSchemaGolden(newConfig(graphql.StringType), path, false))
+
+	err = CheckSchemaGolden(newConfig(graphql.IntType), path, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "-   a: String\n+   a: Int")
+}
@@ -0,0 +1,83 @@
+package apifu
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Provide registers a service, such as a database handle or cache client, that resolvers can
+// retrieve via Service. Services are keyed by their dynamic type, so providing a second service of
+// the same type replaces the first. To register a service under an interface type, use ProvideAs.
+//
+// Services are typically provided once, right after the API is constructed, but it's safe to
+// provide them while requests are being served.
+func (api *API) Provide(service interface{}) {
+	api.provide(reflect.TypeOf(service), service)
+}
+
+// ProvideAs is like API.Provide, but registers the service under the type T, which is typically
+// an interface:
+//
+//	apifu.ProvideAs[model.UserStore](api, postgresUserStore)
+func ProvideAs[T any](api *API, service T) {
+	api.provide(reflect.TypeOf((*T)(nil)).Elem(), service)
+}
+
+func (api *API) provide(t reflect.Type, service interface{}) {
+	if t == nil {
+		panic("apifu: cannot provide a nil service")
+	}
+	api.servicesMutex.Lock()
+	defer api.servicesMutex.Unlock()
+	if api.services == nil {
+		api.services = map[reflect.Type]interface{}{}
+	}
+	api.services[t] = service
+}
+
+// Service returns the service of type T provided to the API that is serving the given context. If
+// no service was provided with exactly that type, a provided service that implements T is returned
+// if T is an interface. If more than one provided service implements T, which one is returned is
+// unspecified. If there is no such service or the context doesn't belong to a request, false is
+// returned.
+//
+// This is typically used by resolvers:
+//
+//	Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+//	    db := apifu.MustService[*sql.DB](ctx.Context)
+//	    ...
+//	}
+func Service[T any](ctx context.Context) (T, bool) {
+	var ret T
+	api, _ := ctx.Value(apiContextKey).(*API)
+	if api == nil {
+		return ret, false
+	}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	api.servicesMutex.RLock()
+	defer api.servicesMutex.RUnlock()
+	if service, ok := api.services[t]; ok {
+		ret, ok = service.(T)
+		return ret, ok
+	}
+	if t.Kind() == reflect.Interface {
+		for _, service := range api.services {
+			if service, ok := service.(T); ok {
+				return service, true
+			}
+		}
+	}
+	return ret, false
+}
+
+// MustService is like Service, but panics if the service isn't available. This is convenient for
+// services that are always provided at startup.
+func MustService[T any](ctx context.Context) T {
+	service, ok := Service[T](ctx)
+	if !ok {
+		panic(fmt.Sprintf("apifu: no service of type %v has been provided", reflect.TypeOf((*T)(nil)).Elem()))
+	}
+	return service
+}
@@ -0,0 +1,66 @@
+package apifu
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+type testGreeter interface {
+	Greet() string
+}
+
+type testEnglishGreeter struct{}
+
+func (testEnglishGreeter) Greet() string {
+	return "hello"
+}
+
+type testCounter struct {
+	n int
+}
+
+func TestService(t *testing.T) {
+	var testCfg Config
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			counter := MustService[*testCounter](ctx.Context)
+			greeter, ok := Service[testGreeter](ctx.Context)
+			if !ok {
+				return nil, fmt.Errorf("no greeter")
+			}
+			_, ok = Service[fmt.Stringer](ctx.Context)
+			return fmt.Sprintf("%v %v %v", greeter.Greet(), counter.n, ok), nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+	api.Provide(&testCounter{n: 1})
+	api.Provide(&testCounter{n: 2})
+	api.Provide(testEnglishGreeter{})
+
+	resp := executeGraphQL(t, api, `{foo}`)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"foo":"hello 2 false"}}`, string(body))
+
+	ProvideAs[testGreeter](api, testEnglishGreeter{})
+	ctx := context.WithValue(context.Background(), apiContextKey, api)
+	greeter, ok := Service[testGreeter](ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "hello", greeter.Greet())
+
+	_, ok = Service[*testCounter](context.Background())
+	assert.False(t, ok)
+	assert.Panics(t, func() {
+		MustService[*testCounter](context.Background())
+	})
+}
@@ -0,0 +1,163 @@
+package apifu
+
+import (
+	"context"
+	"sync"
+)
+
+// RequestStore holds values that are shared by all resolvers of a single request. A new store is
+// created each time a request is executed (including each event of a subscription), and its
+// finalizers are invoked once execution is complete.
+//
+// It's safe to use a store concurrently, e.g. from within functions passed to Go.
+type RequestStore struct {
+	mutex      sync.Mutex
+	values     map[interface{}]*requestStoreEntry
+	finalizers []func()
+}
+
+type requestStoreEntry struct {
+	// Closed once the value is available.
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+type requestStoreContextKeyType int
+
+var requestStoreContextKey requestStoreContextKeyType
+
+// CtxRequestStore returns the request store for the given context. It returns nil if the context
+// does not belong to a request.
+func CtxRequestStore(ctx context.Context) *RequestStore {
+	store, _ := ctx.Value(requestStoreContextKey).(*RequestStore)
+	return store
+}
+
+// Get returns the value for the given key, if one has been set or successfully computed.
+func (s *RequestStore) Get(key interface{}) (interface{}, bool) {
+	s.mutex.Lock()
+	e, ok := s.values[key]
+	s.mutex.Unlock()
+	if !ok {
+		return nil, false
+	}
+	select {
+	case <-e.done:
+		return e.value, e.err == nil
+	default:
+		return nil, false
+	}
+}
+
+// Set sets the value for the given key, replacing any value that was previously set or computed.
+func (s *RequestStore) Set(key interface{}, value interface{}) {
+	e := &requestStoreEntry{
+		done:  make(chan struct{}),
+		value: value,
+	}
+	close(e.done)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.values == nil {
+		s.values = map[interface{}]*requestStoreEntry{}
+	}
+	s.values[key] = e
+}
+
+// GetOrCompute returns the value for the given key. If there isn't one, f is invoked to compute it.
+// Concurrent calls for the same key wait for a single invocation of f. If f returns an error, the
+// error is memoized as well.
+func (s *RequestStore) GetOrCompute(key interface{}, f func() (interface{}, error)) (interface{}, error) {
+	s.mutex.Lock()
+	if e, ok := s.values[key]; ok {
+		s.mutex.Unlock()
+		<-e.done
+		return e.value, e.err
+	}
+	e := &requestStoreEntry{
+		done: make(chan struct{}),
+	}
+	if s.values == nil {
+		s.values = map[interface{}]*requestStoreEntry{}
+	}
+	s.values[key] = e
+	s.mutex.Unlock()
+
+	defer close(e.done)
+	e.value, e.err = f()
+	return e.value, e.err
+}
+
+// AddFinalizer registers a function to be invoked once the request's execution is complete.
+// Finalizers are invoked in the reverse order that they're added, like deferred functions.
+func (s *RequestStore) AddFinalizer(f func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.finalizers = append(s.finalizers, f)
+}
+
+func (s *RequestStore) finalize() {
+	s.mutex.Lock()
+	finalizers := s.finalizers
+	s.finalizers = nil
+	s.mutex.Unlock()
+
+	for i := len(finalizers) - 1; i >= 0; i-- {
+		finalizers[i]()
+	}
+}
+
+// RequestStoreKey is a typed key for values in a RequestStore. Keys are compared by identity, so
+// they're typically declared as package-level variables:
+//
+//	var viewerKey = apifu.NewRequestStoreKey[*model.User]()
+//
+//	viewer, err := viewerKey.GetOrCompute(ctx.Context, func() (*model.User, error) {
+//	    return loadViewer(ctx.Context)
+//	})
+type RequestStoreKey[T any] struct {
+	// Zero-sized types may share addresses, so the key needs at least one byte.
+	_ byte
+}
+
+// NewRequestStoreKey creates a new, unique key.
+func NewRequestStoreKey[T any]() *RequestStoreKey[T] {
+	return &RequestStoreKey[T]{}
+}
+
+// Get returns the value for the key in the context's request store.
+func (k *RequestStoreKey[T]) Get(ctx context.Context) (T, bool) {
+	var ret T
+	store := CtxRequestStore(ctx)
+	if store == nil {
+		return ret, false
+	}
+	v, ok := store.Get(k)
+	if !ok {
+		return ret, false
+	}
+	ret, ok = v.(T)
+	return ret, ok
+}
+
+// Set sets the value for the key in the context's request store. It panics if the context does not
+// belong to a request.
+func (k *RequestStoreKey[T]) Set(ctx context.Context, v T) {
+	CtxRequestStore(ctx).Set(k, v)
+}
+
+// GetOrCompute returns the value for the key in the context's request store, invoking f to compute
+// it if necessary. If the context does not belong to a request, f is invoked without memoization.
+func (k *RequestStoreKey[T]) GetOrCompute(ctx context.Context, f func() (T, error)) (T, error) {
+	store := CtxRequestStore(ctx)
+	if store == nil {
+		return f()
+	}
+	v, err := store.GetOrCompute(k, func() (interface{}, error) {
+		return f()
+	})
+	ret, _ := v.(T)
+	return ret, err
+}
@@ -0,0 +1,95 @@
+package apifu
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func fakeStoreContext(store *RequestStore) context.Context {
+	return context.WithValue(context.Background(), requestStoreContextKey, store)
+}
+
+func TestRequestStore(t *testing.T) {
+	var computations int64
+	var finalizations int64
+	key := NewRequestStoreKey[int]()
+
+	var testCfg Config
+	for _, name := range []string{"a", "b", "c"} {
+		testCfg.AddQueryField(name, &graphql.FieldDefinition{
+			Type: graphql.IntType,
+			Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+				return Go(ctx.Context, func() (interface{}, error) {
+					return key.GetOrCompute(ctx.Context, func() (int, error) {
+						CtxRequestStore(ctx.Context).AddFinalizer(func() {
+							atomic.AddInt64(&finalizations, 1)
+						})
+						return int(atomic.AddInt64(&computations, 1)), nil
+					})
+				}), nil
+			},
+		})
+	}
+
+	var storeFromInfo *RequestStore
+	testCfg.Execute = func(r *graphql.Request, info *RequestInfo) *graphql.Response {
+		storeFromInfo = info.Store
+		assert.Same(t, info.Store, CtxRequestStore(r.Context))
+		return graphql.Execute(r)
+	}
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	for i := 1; i <= 2; i++ {
+		resp := executeGraphQL(t, api, `{a b c}`)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, fmt.Sprintf(`{"data":{"a":%v,"b":%v,"c":%v}}`, i, i, i), string(body))
+		assert.Equal(t, int64(i), atomic.LoadInt64(&finalizations))
+
+		v, ok := key.Get(fakeStoreContext(storeFromInfo))
+		assert.True(t, ok)
+		assert.Equal(t, i, v)
+	}
+}
+
+func TestRequestStoreKey(t *testing.T) {
+	key := NewRequestStoreKey[string]()
+	otherKey := NewRequestStoreKey[string]()
+
+	store := &RequestStore{}
+	ctx := fakeStoreContext(store)
+
+	_, ok := key.Get(ctx)
+	assert.False(t, ok)
+
+	key.Set(ctx, "foo")
+	v, ok := key.Get(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "foo", v)
+
+	_, ok = otherKey.Get(ctx)
+	assert.False(t, ok)
+
+	v, err := key.GetOrCompute(ctx, func() (string, error) {
+		t.Fatal("value should already be set")
+		return "", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "foo", v)
+
+	var order []int
+	store.AddFinalizer(func() { order = append(order, 1) })
+	store.AddFinalizer(func() { order = append(order, 2) })
+	store.finalize()
+	assert.Equal(t, []int{2, 1}, order)
+}
@@ -2,7 +2,13 @@ package apifu
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"reflect"
+	"sort"
+	"time"
+
+	"github.com/ccbrown/api-fu/graphql/ast"
 )
 
 // SubscriptionSourceStream defines the source stream for a subscription.
@@ -13,10 +19,138 @@ type SubscriptionSourceStream struct {
 	// Stop is invoked when the subscription should be stopped and the event channel should be
 	// closed.
 	Stop func()
+
+	// If given, this is invoked when the stream starts running and its result is delivered as the
+	// first event, before any events from EventChannel. This allows clients to get the current state
+	// immediately instead of waiting for the next event. If it returns an error, the stream ends
+	// with that error.
+	InitialEvent func(ctx context.Context) (any, error)
+
+	// If given, this is invoked after Stop with information about the subscription that was
+	// stopped. This is useful for per-subscription billing or auditing.
+	OnStop func(info *SubscriptionInfo)
+
+	// If positive, events are delivered at most once per interval. Events that arrive sooner are
+	// held until the interval has elapsed, and bursts of events are combined via Coalesce. This
+	// prevents high-frequency sources such as tickers or presence updates from re-executing the
+	// subscription's operation at the full event rate.
+	MinInterval time.Duration
+
+	// If given, this combines a held event with a newer one when MinInterval is used. If nil, only
+	// the newest event is delivered.
+	Coalesce func(pending, next interface{}) interface{}
+
+	// If given, the stream uses demand-based flow control: Request is invoked with the number of
+	// additional events that the subscriber is ready to receive, and the producer should send no
+	// more events to EventChannel than have been requested. When the stream starts, InitialDemand
+	// events are requested. After that, another event is requested each time an event's result has
+	// been delivered to the client, so producers slow down when clients aren't keeping up instead
+	// of results piling up in the connection's send buffer. If Delivery configures a buffer, results
+	// are considered delivered once they're buffered.
+	Request func(n int)
+
+	// The number of events requested when the stream starts if Request is given. If zero, 1 is
+	// used.
+	InitialDemand int
+
+	// Configures how results are delivered to WebSocket clients, e.g. to buffer them or to require
+	// that clients acknowledge them. This is ignored for webhook subscriptions, which are retried
+	// according to WebhookConfig.
+	Delivery SubscriptionDelivery
+}
+
+func (s *SubscriptionSourceStream) stop(info *SubscriptionInfo) {
+	s.Stop()
+	if s.OnStop != nil {
+		s.OnStop(info)
+	}
+}
+
+// Grants the producer permission to send n more events. This does nothing if the stream doesn't
+// use demand-based flow control.
+func (s *SubscriptionSourceStream) request(n int) {
+	if s.Request != nil && n > 0 {
+		s.Request(n)
+	}
+}
+
+// SubscriptionInfo describes a subscription operation. It's available to the subscription's
+// resolvers via CtxSubscriptionInfo.
+type SubscriptionInfo struct {
+	// For WebSocket subscriptions, this is the id chosen by the client, which is only unique within
+	// the connection. For webhook subscriptions, this is the webhook subscription's id.
+	Id string
+
+	// The name of the operation, if it has one.
+	OperationName string
+
+	// The hex-encoded SHA-256 hash of the operation's document.
+	DocumentHash string
+
+	// The time at which the subscription was started.
+	StartTime time.Time
+
+	// For WebSocket subscriptions, this uniquely identifies the connection. For webhook
+	// subscriptions, this is empty.
+	ConnectionId string
+}
+
+func newSubscriptionInfo(id, connectionId, query string, doc *ast.Document, operationName string) *SubscriptionInfo {
+	if operationName == "" {
+		// If the client didn't specify the operation, it's the document's only operation.
+		for _, def := range doc.Definitions {
+			if def, ok := def.(*ast.OperationDefinition); ok && def.Name != nil {
+				operationName = def.Name.Name
+			}
+		}
+	}
+	hash := sha256.Sum256([]byte(query))
+	return &SubscriptionInfo{
+		Id:            id,
+		OperationName: operationName,
+		DocumentHash:  hex.EncodeToString(hash[:]),
+		StartTime:     time.Now(),
+		ConnectionId:  connectionId,
+	}
+}
+
+type subscriptionInfoContextKeyType int
+
+var subscriptionInfoContextKey subscriptionInfoContextKeyType
+
+// CtxSubscriptionInfo returns information about the subscription being executed with the given
+// context. This is available both when the subscription is started and when each of its events is
+// resolved. If the context doesn't belong to a subscription, nil is returned.
+func CtxSubscriptionInfo(ctx context.Context) *SubscriptionInfo {
+	info, _ := ctx.Value(subscriptionInfoContextKey).(*SubscriptionInfo)
+	return info
 }
 
 // Run drives the stream until it's closed or until the given context is cancelled.
+//
+// If the stream uses demand-based flow control, onEvent should return once the event's result has
+// been delivered, as each return grants the producer permission to send another event.
 func (s *SubscriptionSourceStream) Run(ctx context.Context, onEvent func(interface{})) error {
+	var lastDelivery time.Time
+	deliver := func(event interface{}) {
+		lastDelivery = time.Now()
+		onEvent(event)
+	}
+
+	if s.InitialEvent != nil {
+		event, err := s.InitialEvent(ctx)
+		if err != nil {
+			return err
+		}
+		deliver(event)
+	}
+
+	if s.InitialDemand > 0 {
+		s.request(s.InitialDemand)
+	} else {
+		s.request(1)
+	}
+
 	eventChannel := reflect.ValueOf(s.EventChannel)
 	ctxChannel := reflect.ValueOf(ctx.Done())
 	selectCases := []reflect.SelectCase{
@@ -28,18 +162,146 @@ func (s *SubscriptionSourceStream) Run(ctx context.Context, onEvent func(interfa
 			Dir:  reflect.SelectRecv,
 			Chan: eventChannel,
 		},
+		{
+			// This is the timer for the held event. While no event is held, the channel is the
+			// zero value, which causes the case to be ignored.
+			Dir: reflect.SelectRecv,
+		},
 	}
+
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	var pending interface{}
+	hasPending := false
+
 	for {
 		chosen, recv, recvOK := reflect.Select(selectCases)
-		if chosen == 0 {
+		switch chosen {
+		case 0:
 			// ctx.Done()
 			return ctx.Err()
+		case 1:
+			// s.EventChannel
+			if !recvOK {
+				if hasPending {
+					deliver(pending)
+				}
+				return nil
+			}
+			event := recv.Interface()
+			if s.MinInterval <= 0 {
+				deliver(event)
+				s.request(1)
+			} else if hasPending {
+				if s.Coalesce != nil {
+					pending = s.Coalesce(pending, event)
+				} else {
+					pending = event
+				}
+				// The event was absorbed by the held one, so it won't be delivered on its own.
+				s.request(1)
+			} else if wait := s.MinInterval - time.Since(lastDelivery); wait <= 0 {
+				deliver(event)
+				s.request(1)
+			} else {
+				pending = event
+				hasPending = true
+				timer = time.NewTimer(wait)
+				selectCases[2].Chan = reflect.ValueOf(timer.C)
+			}
+		case 2:
+			// The held event's interval has elapsed.
+			deliver(pending)
+			pending = nil
+			hasPending = false
+			timer = nil
+			selectCases[2].Chan = reflect.Value{}
+			s.request(1)
+		}
+	}
+}
+
+// ActiveSubscription is a snapshot of a running subscription. See API.Subscriptions.
+type ActiveSubscription struct {
+	// Uniquely identifies the subscription among all of the API's subscriptions. This can be passed
+	// to API.TerminateSubscription.
+	Id string
+
+	// Information about the subscription's operation.
+	Info SubscriptionInfo
+
+	// The attributes returned by Config.SubscriptionAttributes.
+	Attributes map[string]string
+}
+
+type activeSubscription struct {
+	snapshot ActiveSubscription
+	stop     func()
+}
+
+// Registers a running subscription so that it can be listed and terminated. The returned function
+// must be invoked once the subscription is no longer running.
+func (api *API) registerSubscription(ctx context.Context, id string, info *SubscriptionInfo, stop func()) func() {
+	sub := &activeSubscription{
+		snapshot: ActiveSubscription{
+			Id:   id,
+			Info: *info,
+		},
+		stop: stop,
+	}
+	if api.config.SubscriptionAttributes != nil {
+		sub.snapshot.Attributes = api.config.SubscriptionAttributes(ctx)
+	}
+
+	api.activeSubscriptionsMutex.Lock()
+	defer api.activeSubscriptionsMutex.Unlock()
+	if api.activeSubscriptions == nil {
+		api.activeSubscriptions = map[string]*activeSubscription{}
+	}
+	api.activeSubscriptions[id] = sub
+
+	return func() {
+		api.activeSubscriptionsMutex.Lock()
+		defer api.activeSubscriptionsMutex.Unlock()
+		if api.activeSubscriptions[id] == sub {
+			delete(api.activeSubscriptions, id)
 		}
-		// s.EventChannel
-		if recvOK {
-			onEvent(recv.Interface())
-		} else {
-			return nil
+	}
+}
+
+// Subscriptions returns a snapshot of the running subscriptions, including both WebSocket and
+// webhook subscriptions, ordered by start time. This is intended for use by operators, e.g. to
+// find runaway subscriptions.
+func (api *API) Subscriptions() []ActiveSubscription {
+	api.activeSubscriptionsMutex.Lock()
+	ret := make([]ActiveSubscription, 0, len(api.activeSubscriptions))
+	for _, sub := range api.activeSubscriptions {
+		ret = append(ret, sub.snapshot)
+	}
+	api.activeSubscriptionsMutex.Unlock()
+
+	sort.Slice(ret, func(i, j int) bool {
+		if !ret[i].Info.StartTime.Equal(ret[j].Info.StartTime) {
+			return ret[i].Info.StartTime.Before(ret[j].Info.StartTime)
 		}
+		return ret[i].Id < ret[j].Id
+	})
+	return ret
+}
+
+// TerminateSubscription stops a running subscription. The subscription's client is notified that
+// the subscription is complete. It returns false if no such subscription exists.
+func (api *API) TerminateSubscription(id string) bool {
+	api.activeSubscriptionsMutex.Lock()
+	sub, ok := api.activeSubscriptions[id]
+	api.activeSubscriptionsMutex.Unlock()
+
+	if ok {
+		sub.stop()
 	}
+	return ok
 }
@@ -0,0 +1,248 @@
+package apifu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql/transport/graphqltransportws"
+)
+
+func TestSubscriptionSourceStream_InitialEvent(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		ch := make(chan int, 2)
+		ch <- 1
+		ch <- 2
+		close(ch)
+
+		stream := &SubscriptionSourceStream{
+			EventChannel: ch,
+			Stop:         func() {},
+			InitialEvent: func(ctx context.Context) (any, error) {
+				return 0, nil
+			},
+		}
+
+		var events []interface{}
+		assert.NoError(t, stream.Run(context.Background(), func(event interface{}) {
+			events = append(events, event)
+		}))
+		assert.Equal(t, []interface{}{0, 1, 2}, events)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		stream := &SubscriptionSourceStream{
+			EventChannel: make(chan int),
+			Stop:         func() {},
+			InitialEvent: func(ctx context.Context) (any, error) {
+				return nil, fmt.Errorf("error")
+			},
+		}
+
+		assert.Error(t, stream.Run(context.Background(), func(event interface{}) {
+			t.Fatal("no events should be delivered")
+		}))
+	})
+}
+
+func TestSubscriptionSourceStream_MinInterval(t *testing.T) {
+	t.Run("Newest", func(t *testing.T) {
+		ch := make(chan int, 3)
+		ch <- 1
+		ch <- 2
+		ch <- 3
+		close(ch)
+
+		stream := &SubscriptionSourceStream{
+			EventChannel: ch,
+			Stop:         func() {},
+			MinInterval:  time.Hour,
+		}
+
+		var events []interface{}
+		assert.NoError(t, stream.Run(context.Background(), func(event interface{}) {
+			events = append(events, event)
+		}))
+		assert.Equal(t, []interface{}{1, 3}, events)
+	})
+
+	t.Run("Coalesce", func(t *testing.T) {
+		ch := make(chan int)
+		stream := &SubscriptionSourceStream{
+			EventChannel: ch,
+			Stop:         func() {},
+			MinInterval:  50 * time.Millisecond,
+			Coalesce: func(pending, next interface{}) interface{} {
+				return pending.(int) + next.(int)
+			},
+		}
+
+		events := make(chan interface{}, 10)
+		done := make(chan error)
+		go func() {
+			done <- stream.Run(context.Background(), func(event interface{}) {
+				events <- event
+			})
+		}()
+
+		start := time.Now()
+		ch <- 1
+		assert.Equal(t, 1, <-events)
+		ch <- 2
+		ch <- 3
+		ch <- 4
+		assert.Equal(t, 9, <-events)
+		assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+		close(ch)
+		assert.NoError(t, <-done)
+		assert.Empty(t, events)
+	})
+}
+
+func TestSubscriptionSourceStream_Request(t *testing.T) {
+	t.Run("Demand", func(t *testing.T) {
+		ch := make(chan int, 10)
+		demand := 0
+		stream := &SubscriptionSourceStream{
+			EventChannel: ch,
+			Stop:         func() {},
+			Request: func(n int) {
+				// A well-behaved producer sends no more than it's asked for.
+				for i := 0; i < n; i++ {
+					demand++
+					if demand <= 5 {
+						ch <- demand
+					}
+					if demand == 5 {
+						close(ch)
+					}
+				}
+			},
+			InitialDemand: 2,
+		}
+
+		var events []interface{}
+		assert.NoError(t, stream.Run(context.Background(), func(event interface{}) {
+			assert.Equal(t, 2, demand-len(events), "there should always be 2 outstanding requests")
+			events = append(events, event)
+		}))
+		assert.Equal(t, []interface{}{1, 2, 3, 4, 5}, events)
+	})
+
+	t.Run("Coalesce", func(t *testing.T) {
+		ch := make(chan int, 10)
+		requests := make(chan int, 10)
+		stream := &SubscriptionSourceStream{
+			EventChannel: ch,
+			Stop:         func() {},
+			MinInterval:  50 * time.Millisecond,
+			Request: func(n int) {
+				requests <- n
+			},
+			InitialDemand: 3,
+		}
+
+		events := make(chan interface{}, 10)
+		done := make(chan error)
+		go func() {
+			done <- stream.Run(context.Background(), func(event interface{}) {
+				events <- event
+			})
+		}()
+
+		// Each event, whether it's delivered or absorbed by a held one, is followed by a request.
+		assert.Equal(t, 3, <-requests)
+		ch <- 1
+		ch <- 2
+		ch <- 3
+		assert.Equal(t, 1, <-events)
+		assert.Equal(t, 3, <-events)
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, 1, <-requests)
+		}
+		close(ch)
+		assert.NoError(t, <-done)
+	})
+}
+
+func TestAPI_Subscriptions(t *testing.T) {
+	var testCfg Config
+	testCfg.AddSubscription("time", timeSubscription)
+	testCfg.HandleGraphQLWSInit = func(ctx context.Context, parameters json.RawMessage) (context.Context, error) {
+		return context.WithValue(ctx, "user", "alice"), nil
+	}
+	testCfg.SubscriptionAttributes = func(ctx context.Context) map[string]string {
+		return map[string]string{
+			"user": ctx.Value("user").(string),
+		}
+	}
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+	defer api.CloseHijackedConnections()
+
+	ts := httptest.NewServer(http.HandlerFunc(api.ServeGraphQLWS))
+	defer ts.Close()
+
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: time.Second,
+		Subprotocols:     []string{graphqltransportws.WebSocketSubprotocol},
+	}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(map[string]string{
+		"type": "connection_init",
+	}))
+
+	var msg graphqltransportws.Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, graphqltransportws.MessageTypeConnectionAck, msg.Type)
+
+	assert.Empty(t, api.Subscriptions())
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"id":   "sub",
+		"type": "subscribe",
+		"payload": map[string]interface{}{
+			"query": `subscription Time { time }`,
+		},
+	}))
+
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, graphqltransportws.MessageTypeNext, msg.Type)
+
+	subs := api.Subscriptions()
+	require.Len(t, subs, 1)
+	assert.Equal(t, "Time", subs[0].Info.OperationName)
+	assert.Equal(t, "sub", subs[0].Info.Id)
+	assert.Equal(t, map[string]string{"user": "alice"}, subs[0].Attributes)
+
+	assert.False(t, api.TerminateSubscription("foo"))
+	assert.True(t, api.TerminateSubscription(subs[0].Id))
+
+	// The client should be notified, but the stream may have sent another event first.
+	for {
+		require.NoError(t, conn.ReadJSON(&msg))
+		if msg.Type != graphqltransportws.MessageTypeNext {
+			break
+		}
+	}
+	assert.Equal(t, "sub", msg.Id)
+	assert.Equal(t, graphqltransportws.MessageTypeComplete, msg.Type)
+
+	assert.Eventually(t, func() bool {
+		return len(api.Subscriptions()) == 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,96 @@
+package apifu
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// ClientTimeoutHeader is the HTTP header that clients can use to request a timeout for their
+// operation, in milliseconds. See Config.MaxClientTimeout.
+const ClientTimeoutHeader = "X-GraphQL-Timeout-Ms"
+
+// Returns the timeout requested by the client, if any, capped to max. Timeouts are given in
+// milliseconds via the "timeoutMs" extension. Timeouts that aren't finite positive numbers are
+// ignored.
+func clientTimeout(r *graphql.Request, max time.Duration) (time.Duration, bool) {
+	var ms float64
+	switch v := r.Extensions["timeoutMs"].(type) {
+	case float64:
+		ms = v
+	case int:
+		ms = float64(v)
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false
+		}
+		ms = n
+	default:
+		return 0, false
+	}
+	if math.IsNaN(ms) || math.IsInf(ms, 0) || ms <= 0 {
+		return 0, false
+	}
+	// Cap the timeout before converting it so that large values can't overflow.
+	if ms >= float64(max)/float64(time.Millisecond) {
+		return max, true
+	}
+	return time.Duration(ms * float64(time.Millisecond)), true
+}
+
+// Copies the timeout header into the request's extensions so that it's handled the same way as the
+// "timeoutMs" extension. The extension takes precedence.
+func applyClientTimeoutHeader(req *graphql.Request, r *http.Request) {
+	header := r.Header.Get(ClientTimeoutHeader)
+	if header == "" {
+		return
+	} else if _, ok := req.Extensions["timeoutMs"]; ok {
+		return
+	}
+	extensions := make(map[string]interface{}, len(req.Extensions)+1)
+	for k, v := range req.Extensions {
+		extensions[k] = v
+	}
+	extensions["timeoutMs"] = header
+	req.Extensions = extensions
+}
+
+// withClientTimeout wraps execute so that clients can request a deadline for their operations. See
+// Config.MaxClientTimeout.
+func withClientTimeout(cfg *Config, execute func(*graphql.Request, *RequestInfo) *graphql.Response) func(*graphql.Request, *RequestInfo) *graphql.Response {
+	if cfg.MaxClientTimeout <= 0 {
+		return execute
+	}
+	return func(r *graphql.Request, info *RequestInfo) *graphql.Response {
+		timeout, ok := clientTimeout(r, cfg.MaxClientTimeout)
+		if !ok {
+			return execute(r, info)
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context, timeout)
+		defer cancel()
+		req := *r
+		req.Context = ctx
+		resp := execute(&req, info)
+
+		// Only report the timeout if it was ours rather than one imposed by the parent context.
+		if ctx.Err() == context.DeadlineExceeded && r.Context.Err() == nil {
+			resp.Errors = append(resp.Errors, &graphql.Error{
+				Message:    "The operation exceeded its timeout of " + timeout.String() + ".",
+				Extensions: timeoutErrorExtensions(timeout),
+			})
+		}
+		return resp
+	}
+}
+
+func timeoutErrorExtensions(timeout time.Duration) map[string]interface{} {
+	ret := graphql.ErrorCodeTimeout.Extensions()
+	ret["timeoutMs"] = timeout.Milliseconds()
+	return ret
+}
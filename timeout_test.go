@@ -0,0 +1,105 @@
+package apifu
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestClientTimeout(t *testing.T) {
+	var cfg Config
+	cfg.MaxClientTimeout = 50 * time.Millisecond
+	cfg.AddQueryField("slow", &graphql.FieldDefinition{
+		Type: graphql.BooleanType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return Go(ctx.Context, func() (interface{}, error) {
+				select {
+				case <-ctx.Context.Done():
+					return nil, ctx.Context.Err()
+				case <-time.After(time.Second):
+					return true, nil
+				}
+			}), nil
+		},
+	})
+	cfg.AddQueryField("fast", &graphql.FieldDefinition{
+		Type: graphql.BooleanType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			_, hasDeadline := ctx.Context.Deadline()
+			return hasDeadline, nil
+		},
+	})
+	api, err := NewAPI(&cfg)
+	require.NoError(t, err)
+
+	type response struct {
+		Data   map[string]interface{}
+		Errors []*graphql.Error
+	}
+
+	execute := func(body string, header string) response {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		if header != "" {
+			r.Header.Set(ClientTimeoutHeader, header)
+		}
+		api.ServeGraphQL(w, r)
+		var resp response
+		require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&resp))
+		return resp
+	}
+
+	t.Run("NoTimeout", func(t *testing.T) {
+		resp := execute(`{"query":"{fast}"}`, "")
+		assert.Empty(t, resp.Errors)
+		assert.Equal(t, false, resp.Data["fast"])
+	})
+
+	t.Run("NotExceeded", func(t *testing.T) {
+		resp := execute(`{"query":"{fast}","extensions":{"timeoutMs":1000}}`, "")
+		assert.Empty(t, resp.Errors)
+		assert.Equal(t, true, resp.Data["fast"])
+	})
+
+	t.Run("Extension", func(t *testing.T) {
+		resp := execute(`{"query":"{slow}","extensions":{"timeoutMs":10}}`, "")
+		require.Len(t, resp.Errors, 2)
+		assert.Equal(t, "TIMEOUT", resp.Errors[1].Extensions["code"])
+		assert.Equal(t, float64(10), resp.Errors[1].Extensions["timeoutMs"])
+	})
+
+	t.Run("HeaderCapped", func(t *testing.T) {
+		resp := execute(`{"query":"{slow}"}`, "10000")
+		require.Len(t, resp.Errors, 2)
+		assert.Equal(t, "TIMEOUT", resp.Errors[1].Extensions["code"])
+		assert.Equal(t, float64(50), resp.Errors[1].Extensions["timeoutMs"])
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		resp := execute(`{"query":"{slow}","extensions":{"timeoutMs":1e300}}`, "")
+		require.Len(t, resp.Errors, 2)
+		assert.Equal(t, "TIMEOUT", resp.Errors[1].Extensions["code"])
+		assert.Equal(t, float64(50), resp.Errors[1].Extensions["timeoutMs"])
+	})
+
+	for name, header := range map[string]string{
+		"NaN":      "NaN",
+		"Infinity": "+Inf",
+		"Negative": "-10",
+		"Zero":     "0",
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := execute(`{"query":"{fast}"}`, header)
+			assert.Empty(t, resp.Errors)
+			assert.Equal(t, false, resp.Data["fast"])
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package apifu
+
+import (
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// RegisterTypeOf sets the object type's IsTypeOf function so that it matches values of type T. If
+// T isn't a pointer type, non-nil pointers to T are also matched. The object type is returned so
+// that it can be used inline when defining unions or interface implementations:
+//
+//	petType := apifu.NewUnionType("Pet",
+//		apifu.RegisterTypeOf[Dog](dogType),
+//		apifu.RegisterTypeOf[Cat](catType),
+//	)
+//
+// This replaces hand-written IsTypeOf functions, which are easy to get wrong, e.g. by forgetting to
+// handle pointers.
+func RegisterTypeOf[T any](t *graphql.ObjectType) *graphql.ObjectType {
+	t.IsTypeOf = func(obj interface{}) bool {
+		switch v := obj.(type) {
+		case T:
+			return true
+		case *T:
+			return v != nil
+		}
+		return false
+	}
+	return t
+}
+
+// NewUnionType creates a union type with the given members. Each member must define IsTypeOf,
+// typically via RegisterTypeOf.
+func NewUnionType(name string, members ...*graphql.ObjectType) *graphql.UnionType {
+	return &graphql.UnionType{
+		Name:        name,
+		MemberTypes: members,
+	}
+}
@@ -0,0 +1,55 @@
+package apifu
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestRegisterTypeOf(t *testing.T) {
+	type Dog struct {
+		Name string
+	}
+	type Cat struct {
+		Name string
+	}
+
+	dogType := RegisterTypeOf[Dog](&graphql.ObjectType{
+		Name: "Dog",
+		Fields: map[string]*graphql.FieldDefinition{
+			"name": NonNull(graphql.StringType, "Name"),
+		},
+	})
+	catType := RegisterTypeOf[*Cat](&graphql.ObjectType{
+		Name: "Cat",
+		Fields: map[string]*graphql.FieldDefinition{
+			"name": NonNull(graphql.StringType, "Name"),
+		},
+	})
+
+	assert.True(t, dogType.IsTypeOf(Dog{}))
+	assert.True(t, dogType.IsTypeOf(&Dog{}))
+	assert.False(t, dogType.IsTypeOf((*Dog)(nil)))
+	assert.False(t, dogType.IsTypeOf(Cat{}))
+	assert.True(t, catType.IsTypeOf(&Cat{}))
+	assert.False(t, catType.IsTypeOf(Cat{}))
+
+	var cfg Config
+	cfg.AddQueryField("pets", &graphql.FieldDefinition{
+		Type: graphql.NewListType(NewUnionType("Pet", dogType, catType)),
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return []interface{}{Dog{Name: "Fido"}, &Dog{Name: "Rex"}, &Cat{Name: "Tom"}}, nil
+		},
+	})
+	api, err := NewAPI(&cfg)
+	require.NoError(t, err)
+
+	resp := executeGraphQL(t, api, `{pets{__typename ... on Dog{name} ... on Cat{name}}}`)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"pets":[{"__typename":"Dog","name":"Fido"},{"__typename":"Dog","name":"Rex"},{"__typename":"Cat","name":"Tom"}]}}`, string(body))
+}
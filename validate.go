@@ -0,0 +1,84 @@
+package apifu
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/ccbrown/api-fu/graphql"
+	"github.com/ccbrown/api-fu/graphql/validator"
+)
+
+// ValidationResult is the response body written by ServeValidate.
+type ValidationResult struct {
+	// True if the operation passed validation and would be executed if it were sent to
+	// ServeGraphQL.
+	Valid bool `json:"valid"`
+
+	// The errors that would be returned if the operation were sent to ServeGraphQL.
+	Errors []*graphql.Error `json:"errors,omitempty"`
+
+	// The operation's estimated cost. This is omitted if the document fails any of the standard
+	// validation rules.
+	Cost *ValidationCost `json:"cost,omitempty"`
+}
+
+// ValidationCost describes an operation's estimated cost. It has the same shape as the "cost"
+// extension added by Config.ReportCost.
+type ValidationCost struct {
+	Estimated  int            `json:"estimated"`
+	Dimensions map[string]int `json:"dimensions,omitempty"`
+}
+
+// ServeValidate validates GraphQL operations without executing them. Requests are POST requests
+// with the same bodies as those accepted by ServeGraphQL, and responses are JSON-encoded
+// ValidationResults.
+//
+// This allows client teams to verify that their operations are compatible with the running schema
+// from their own CI pipelines. The operation is validated using the same rules and features as it
+// would be by ServeGraphQL, including cost limits. If the operation's cost depends on variables,
+// the given variables are used.
+func (api *API) ServeValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), apiContextKey, api)
+	r = r.WithContext(ctx)
+
+	codec := api.jsonCodec()
+	req, code, err := graphql.NewRequestFromHTTPWithJSONCodec(r, codec)
+	if err != nil {
+		http.Error(w, err.Error(), code)
+		return
+	}
+	req.Schema = api.schema
+	req.Features = api.requestFeatures(ctx)
+
+	result := &ValidationResult{}
+	if doc, errs := graphql.ParseAndValidate(req.Query, req.Schema, req.Features); len(errs) > 0 {
+		result.Errors = errs
+	} else {
+		// As with cached documents, the request-specific rules are applied separately so that
+		// the cost is only reported for documents that are otherwise valid.
+		var info RequestInfo
+		typeInfo := validator.NewTypeInfo(doc, req.Schema, req.Features)
+		result.Errors = graphql.ApplyValidatorRules(doc, req.Schema, req.Features, typeInfo, api.validatorRules(req, &info)...)
+		result.Cost = &ValidationCost{
+			Estimated:  info.Cost,
+			Dimensions: info.CostDimensions,
+		}
+	}
+	result.Valid = len(result.Errors) == 0
+
+	body, err := codec.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
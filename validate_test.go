@@ -0,0 +1,93 @@
+package apifu
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+func TestServeValidate(t *testing.T) {
+	testCfg := Config{
+		DefaultFieldCost: graphql.FieldCost{
+			Resolver: 1,
+		},
+		MaxCostDimensions: map[string]int{
+			"external": 1,
+		},
+	}
+	testCfg.AddQueryField("search", &graphql.FieldDefinition{
+		Type: graphql.StringType,
+		Cost: func(graphql.FieldCostContext) graphql.FieldCost {
+			return graphql.FieldCost{
+				Resolver: 1,
+				Dimensions: map[string]int{
+					"external": 1,
+				},
+			}
+		},
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			panic("operations shouldn't be executed")
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		Method       string
+		Body         string
+		ExpectedCode int
+		Expected     string
+	}{
+		"Valid": {
+			Method:       "POST",
+			Body:         `{"query":"{search}"}`,
+			ExpectedCode: http.StatusOK,
+			Expected:     `{"valid":true,"cost":{"estimated":1,"dimensions":{"external":1}}}`,
+		},
+		"Invalid": {
+			Method:       "POST",
+			Body:         `{"query":"{foo}"}`,
+			ExpectedCode: http.StatusOK,
+			Expected:     `{"valid":false,"errors":[{"message":"Validation error: field foo does not exist on Query","locations":[{"line":1,"column":2}]}]}`,
+		},
+		"SyntaxError": {
+			Method:       "POST",
+			Body:         `{"query":"{"}`,
+			ExpectedCode: http.StatusOK,
+			Expected:     `{"valid":false,"errors":[{"message":"Syntax error: expected name","locations":[{"line":1,"column":2}]}]}`,
+		},
+		"CostExceeded": {
+			Method:       "POST",
+			Body:         `{"query":"{a: search b: search}"}`,
+			ExpectedCode: http.StatusOK,
+			Expected:     `{"valid":false,"errors":[{"message":"Validation error: operation external cost of 2 exceeds allowed cost of 1","locations":[{"line":1,"column":1}]}],"cost":{"estimated":2,"dimensions":{"external":2}}}`,
+		},
+		"GET": {
+			Method:       "GET",
+			ExpectedCode: http.StatusMethodNotAllowed,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, err := http.NewRequest(tc.Method, "", strings.NewReader(tc.Body))
+			require.NoError(t, err)
+			r.Header.Set("Content-Type", "application/json")
+			api.ServeValidate(w, r)
+			resp := w.Result()
+			assert.Equal(t, tc.ExpectedCode, resp.StatusCode)
+			if tc.Expected != "" {
+				body, err := ioutil.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, tc.Expected, string(body))
+			}
+		})
+	}
+}
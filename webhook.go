@@ -0,0 +1,493 @@
+package apifu
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+// WebhookConfig configures subscriptions over HTTP callbacks. These allow clients that can't hold
+// sockets open to register a subscription along with a callback URL. The subscription is executed
+// server-side and each event's result is POSTed to the callback URL.
+//
+// Each delivery is a JSON-encoded GraphQL response with the following headers:
+//
+//   - X-Webhook-Id: The id of the webhook subscription.
+//   - X-Webhook-Timestamp: The Unix time at which the delivery was signed.
+//   - X-Webhook-Signature: "sha256=" followed by the hex-encoded HMAC-SHA256 of the timestamp, a
+//     period, and the request body, keyed by the subscription's secret.
+//
+// Each subscription has its own randomly generated secret, which is only given to the client that
+// created it. Receivers should verify signatures and reject deliveries with old timestamps.
+//
+// If the callback URL responds with 410 Gone, the subscription is stopped.
+type WebhookConfig struct {
+	// The client used to make deliveries. If nil, http.DefaultClient is used, unless
+	// CheckCallbackURL is also nil, in which case a client that refuses to connect to the addresses
+	// described by CheckCallbackURL is used.
+	Client *http.Client
+
+	// The maximum number of attempts made for each delivery. If zero, 3 attempts are made.
+	MaxAttempts int
+
+	// The delay before the first retry of a failed delivery. The delay doubles after each retry. If
+	// zero, one second is used.
+	RetryBackoff time.Duration
+
+	// The TTL used when a client doesn't request one. If zero, 24 hours is used.
+	DefaultTTL time.Duration
+
+	// If non-zero, requested TTLs are capped to this duration.
+	MaxTTL time.Duration
+
+	// If non-zero, new subscriptions are rejected while this many are active.
+	MaxSubscriptions int
+
+	// If given, this is invoked to validate callback URLs before subscriptions are created. It
+	// should typically be used to prevent requests to internal networks. If nil, callback URLs
+	// whose hosts are or resolve to loopback, link-local, private, or unspecified addresses are
+	// rejected.
+	CheckCallbackURL func(ctx context.Context, u *url.URL) error
+}
+
+// WebhookSubscriptionRequest is a request to create a webhook subscription.
+type WebhookSubscriptionRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	CallbackURL   string                 `json:"callbackUrl"`
+
+	// The requested lifetime of the subscription. If zero, WebhookConfig.DefaultTTL is used.
+	TTLSeconds int `json:"ttlSeconds"`
+}
+
+// WebhookSubscription describes an active webhook subscription.
+type WebhookSubscription struct {
+	Id             string    `json:"id"`
+	CallbackURL    string    `json:"callbackUrl"`
+	ExpirationTime time.Time `json:"expirationTime"`
+
+	// The key used to sign the subscription's deliveries. This is only returned when the
+	// subscription is created, so the client must store it.
+	Secret string `json:"secret"`
+}
+
+type webhookSubscription struct {
+	id          string
+	callbackURL string
+	secret      []byte
+	cancel      func()
+}
+
+func (cfg *WebhookConfig) client() *http.Client {
+	if cfg.Client != nil {
+		return cfg.Client
+	} else if cfg.CheckCallbackURL == nil {
+		return publicWebhookClient
+	}
+	return http.DefaultClient
+}
+
+func (cfg *WebhookConfig) checkCallbackURL(ctx context.Context, u *url.URL) error {
+	if cfg.CheckCallbackURL != nil {
+		return cfg.CheckCallbackURL(ctx, u)
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return errors.New("Unable to resolve the callback URL's host.")
+	}
+	for _, addr := range addrs {
+		if !isPublicWebhookAddr(addr) {
+			return errors.New("Callback URLs must not refer to internal networks.")
+		}
+	}
+	return nil
+}
+
+// Returns true if webhooks may be delivered to the address when CheckCallbackURL is nil.
+func isPublicWebhookAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return !addr.IsLoopback() && !addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast() && !addr.IsPrivate() && !addr.IsUnspecified()
+}
+
+// Hosts may resolve to different addresses by the time deliveries are made, so the addresses are
+// checked again when connecting.
+var publicWebhookClient = &http.Client{
+	Transport: &http.Transport{
+		// Proxies would make the connections instead, bypassing the address checks.
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if addr, err := netip.ParseAddr(host); err != nil || !isPublicWebhookAddr(addr) {
+					return fmt.Errorf("refusing to deliver webhook to %v", address)
+				}
+				return nil
+			},
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	},
+}
+
+func (cfg *WebhookConfig) maxAttempts() int {
+	if cfg.MaxAttempts > 0 {
+		return cfg.MaxAttempts
+	}
+	return 3
+}
+
+func (cfg *WebhookConfig) retryBackoff() time.Duration {
+	if cfg.RetryBackoff > 0 {
+		return cfg.RetryBackoff
+	}
+	return time.Second
+}
+
+func (cfg *WebhookConfig) ttl(requestedSeconds int) time.Duration {
+	ttl := cfg.DefaultTTL
+	if requestedSeconds > 0 {
+		// The requested value is clamped before conversion so that it can't overflow.
+		maxSeconds := int64(math.MaxInt64 / time.Second)
+		if seconds := int64(requestedSeconds); seconds > maxSeconds {
+			ttl = time.Duration(maxSeconds) * time.Second
+		} else {
+			ttl = time.Duration(seconds) * time.Second
+		}
+	} else if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if cfg.MaxTTL > 0 && ttl > cfg.MaxTTL {
+		ttl = cfg.MaxTTL
+	}
+	return ttl
+}
+
+// WebhookSignature returns the value of the X-Webhook-Signature header for a delivery with the
+// given timestamp and body, where secret is the subscription's secret. Receivers can use this to
+// verify deliveries.
+func WebhookSignature(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Generates a random, hex-encoded id.
+func newRandomId() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// Generates a random, hex-encoded webhook secret.
+func newWebhookSecret() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// SubscribeWebhook creates a webhook subscription. The operation must be a subscription. The
+// context's values are used to execute the subscription, but its cancellation is not, so it's safe
+// to pass a request context.
+//
+// Config.Webhooks must be set to use this method. Config.RequestPolicies aren't applied since
+// there's no HTTP request to apply them to. ServeWebhookSubscriptions applies them.
+func (api *API) SubscribeWebhook(ctx context.Context, r *WebhookSubscriptionRequest) (*WebhookSubscription, []*graphql.Error) {
+	return api.subscribeWebhook(ctx, nil, r)
+}
+
+// Creates a webhook subscription. If httpRequest is non-nil, Config.RequestPolicies are applied to
+// it.
+func (api *API) subscribeWebhook(ctx context.Context, httpRequest *http.Request, r *WebhookSubscriptionRequest) (*WebhookSubscription, []*graphql.Error) {
+	cfg := api.config.Webhooks
+	if cfg == nil {
+		return nil, []*graphql.Error{{Message: "Webhook subscriptions are not supported."}}
+	}
+
+	callbackURL, err := url.Parse(r.CallbackURL)
+	if err != nil || (callbackURL.Scheme != "http" && callbackURL.Scheme != "https") || callbackURL.Host == "" {
+		return nil, []*graphql.Error{{Message: "A valid http or https callback URL is required."}}
+	}
+	if err := cfg.checkCallbackURL(ctx, callbackURL); err != nil {
+		return nil, []*graphql.Error{{Message: err.Error()}}
+	}
+
+	ctx = context.WithValue(ctx, apiContextKey, api)
+	apiRequest := &apiRequest{}
+	ctx = context.WithValue(ctx, apiRequestContextKey, apiRequest)
+
+	req := &graphql.Request{
+		Context:        ctx,
+		Query:          r.Query,
+		Schema:         api.schema,
+		IdleHandler:    apiRequest.IdleHandler,
+		OperationName:  r.OperationName,
+		VariableValues: r.Variables,
+
+		IdleHandlerStallLimit: idleHandlerStallLimit,
+		CancelPromise:         apiRequest.CancelPromise,
+		ReportNulledFields:    api.config.ReportNulledFields,
+		ReportDeprecations:    api.config.ReportDeprecations,
+		ReportSchemaWarnings:  api.config.ReportSchemaWarnings,
+		MaxErrors:             api.config.MaxErrors,
+		MaxErrorMessageLength: api.config.MaxErrorMessageLength,
+	}
+	req.Features = api.requestFeatures(ctx)
+
+	var info RequestInfo
+	doc, errs := api.parseAndValidate(req, &info)
+	if len(errs) == 0 && httpRequest != nil {
+		errs = api.applyRequestPolicies(httpRequest, req, doc)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	req.Document = doc
+
+	if !graphql.IsSubscription(doc, r.OperationName) {
+		return nil, []*graphql.Error{{Message: "Only subscription operations can be used with webhooks."}}
+	}
+
+	id, err := newRandomId()
+	if err != nil {
+		return nil, []*graphql.Error{{Message: "Unable to generate a subscription id."}}
+	}
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, []*graphql.Error{{Message: "Unable to generate a subscription secret."}}
+	}
+
+	ttl := cfg.ttl(r.TTLSeconds)
+
+	// Like hijacked connections, the subscription outlives the context it was created with.
+	expirationTime := time.Now().Add(ttl)
+	streamCtx, cancel := context.WithDeadline(context.Background(), expirationTime)
+	subscriptionInfo := newSubscriptionInfo(id, "", r.Query, doc, r.OperationName)
+	req.Context = hijackedContext{
+		newContext:   streamCtx,
+		valueContext: context.WithValue(ctx, subscriptionInfoContextKey, subscriptionInfo),
+	}
+
+	sourceStream, errs := graphql.Subscribe(req)
+	if len(errs) > 0 {
+		cancel()
+		return nil, errs
+	}
+	stream := sourceStream.(*SubscriptionSourceStream)
+
+	sub := &webhookSubscription{
+		id:          id,
+		callbackURL: callbackURL.String(),
+		secret:      []byte(secret),
+		cancel:      cancel,
+	}
+
+	api.webhookSubscriptionsMutex.Lock()
+	if cfg.MaxSubscriptions > 0 && len(api.webhookSubscriptions) >= cfg.MaxSubscriptions {
+		api.webhookSubscriptionsMutex.Unlock()
+		stream.stop(subscriptionInfo)
+		cancel()
+		return nil, []*graphql.Error{{Message: "Too many webhook subscriptions."}}
+	}
+	if api.webhookSubscriptions == nil {
+		api.webhookSubscriptions = map[string]*webhookSubscription{}
+	}
+	api.webhookSubscriptions[id] = sub
+	api.webhookSubscriptionsMutex.Unlock()
+
+	unregister := api.registerSubscription(req.Context, id, subscriptionInfo, func() {
+		api.UnsubscribeWebhook(id)
+	})
+
+	go func() {
+		defer unregister()
+		defer api.UnsubscribeWebhook(id)
+		defer stream.stop(subscriptionInfo)
+		if err := stream.Run(streamCtx, func(event any) {
+			req := *req
+			req.InitialValue = event
+			if err := api.deliverWebhook(streamCtx, sub, api.execute(&req, &info)); err != nil {
+				if err == errWebhookGone {
+					cancel()
+				} else if streamCtx.Err() == nil {
+					api.logger.Warn(errors.Wrap(err, "error delivering webhook"))
+				}
+			}
+		}); err != nil && err != context.Canceled && err != context.DeadlineExceeded {
+			api.logger.Error(errors.Wrap(err, "error running source stream"))
+		}
+	}()
+
+	return &WebhookSubscription{
+		Id:             id,
+		CallbackURL:    sub.callbackURL,
+		ExpirationTime: expirationTime,
+		Secret:         secret,
+	}, nil
+}
+
+var errWebhookGone = errors.New("webhook callback is gone")
+
+func (api *API) deliverWebhook(ctx context.Context, sub *webhookSubscription, resp *graphql.Response) error {
+	cfg := api.config.Webhooks
+
+	body, err := api.jsonCodec().Marshal(resp)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling response")
+	}
+
+	backoff := cfg.retryBackoff()
+	for attempt := 1; ; attempt++ {
+		err = api.attemptWebhookDelivery(ctx, sub, body)
+		if err == nil || err == errWebhookGone || attempt >= cfg.maxAttempts() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (api *API) attemptWebhookDelivery(ctx context.Context, sub *webhookSubscription, body []byte) error {
+	cfg := api.config.Webhooks
+
+	req, err := http.NewRequest(http.MethodPost, sub.callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", sub.id)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", WebhookSignature(sub.secret, timestamp, body))
+
+	resp, err := cfg.client().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGone {
+		return errWebhookGone
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("callback responded with status %v", resp.StatusCode)
+	}
+	return nil
+}
+
+// UnsubscribeWebhook stops a webhook subscription. It returns false if no such subscription exists.
+func (api *API) UnsubscribeWebhook(id string) bool {
+	api.webhookSubscriptionsMutex.Lock()
+	sub, ok := api.webhookSubscriptions[id]
+	delete(api.webhookSubscriptions, id)
+	api.webhookSubscriptionsMutex.Unlock()
+
+	if ok {
+		sub.cancel()
+	}
+	return ok
+}
+
+// CloseWebhookSubscriptions stops all webhook subscriptions.
+func (api *API) CloseWebhookSubscriptions() {
+	api.webhookSubscriptionsMutex.Lock()
+	subs := api.webhookSubscriptions
+	api.webhookSubscriptions = nil
+	api.webhookSubscriptionsMutex.Unlock()
+
+	for _, sub := range subs {
+		sub.cancel()
+	}
+}
+
+// ServeWebhookSubscriptions serves requests to manage webhook subscriptions. POST requests with a
+// JSON-encoded WebhookSubscriptionRequest create subscriptions and respond with a JSON-encoded
+// WebhookSubscription. DELETE requests with an "id" query string parameter stop subscriptions.
+//
+// Errors for POST requests are returned as GraphQL responses with a 400 status code.
+func (api *API) ServeWebhookSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if api.config.Webhooks == nil {
+		http.Error(w, "webhook subscriptions are not supported", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var req WebhookSubscriptionRequest
+		if b, err := ioutil.ReadAll(r.Body); err != nil {
+			http.Error(w, "unable to read request body", http.StatusBadRequest)
+			return
+		} else if err := api.jsonCodec().Unmarshal(b, &req); err != nil {
+			http.Error(w, "malformed request body", http.StatusBadRequest)
+			return
+		}
+
+		var status int
+		var v interface{}
+		if sub, errs := api.subscribeWebhook(r.Context(), r, &req); len(errs) > 0 {
+			status = http.StatusBadRequest
+			v = &graphql.Response{
+				Errors: errs,
+			}
+		} else {
+			status = http.StatusCreated
+			v = sub
+		}
+
+		body, err := api.jsonCodec().Marshal(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		w.Write(body)
+	case http.MethodDelete:
+		if api.UnsubscribeWebhook(r.URL.Query().Get("id")) {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			http.Error(w, "subscription not found", http.StatusNotFound)
+		}
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,323 @@
+package apifu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ccbrown/api-fu/graphql"
+)
+
+type webhookReceiver struct {
+	mutex      sync.Mutex
+	secret     string
+	deliveries []string
+	failures   int
+	status     int
+	received   chan struct{}
+}
+
+// Sets the secret used to verify deliveries once the subscription is created.
+func (r *webhookReceiver) setSecret(secret string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.secret = secret
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if req.Header.Get("X-Webhook-Signature") != WebhookSignature([]byte(r.secret), req.Header.Get("X-Webhook-Timestamp"), body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	r.deliveries = append(r.deliveries, string(body))
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+	}
+	r.received <- struct{}{}
+}
+
+func TestWebhookSubscriptions(t *testing.T) {
+	var testCfg Config
+	testCfg.Webhooks = &WebhookConfig{
+		RetryBackoff: time.Millisecond,
+		// The callbacks are served via loopback.
+		CheckCallbackURL: func(ctx context.Context, u *url.URL) error {
+			return nil
+		},
+	}
+
+	events := make(chan int)
+	testCfg.AddQueryField("foo", &graphql.FieldDefinition{
+		Type: graphql.BooleanType,
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			return true, nil
+		},
+	})
+	testCfg.AddSubscription("counter", &graphql.FieldDefinition{
+		Type: graphql.NewNonNullType(graphql.IntType),
+		Resolve: func(ctx graphql.FieldContext) (interface{}, error) {
+			if ctx.IsSubscribe {
+				return &SubscriptionSourceStream{
+					EventChannel: events,
+					Stop:         func() {},
+				}, nil
+			}
+			return ctx.Object, nil
+		},
+	})
+
+	api, err := NewAPI(&testCfg)
+	require.NoError(t, err)
+	defer api.CloseWebhookSubscriptions()
+
+	server := httptest.NewServer(http.HandlerFunc(api.ServeWebhookSubscriptions))
+	defer server.Close()
+
+	subscribe := func(t *testing.T, req WebhookSubscriptionRequest) (*http.Response, []byte) {
+		body, err := json.Marshal(req)
+		require.NoError(t, err)
+		resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, respBody
+	}
+
+	t.Run("Delivery", func(t *testing.T) {
+		receiver := &webhookReceiver{
+			failures: 1,
+			received: make(chan struct{}, 1),
+		}
+		callback := httptest.NewServer(receiver)
+		defer callback.Close()
+
+		resp, body := subscribe(t, WebhookSubscriptionRequest{
+			Query:       `subscription { counter }`,
+			CallbackURL: callback.URL,
+		})
+		require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+
+		var sub WebhookSubscription
+		require.NoError(t, json.Unmarshal(body, &sub))
+		assert.NotEmpty(t, sub.Id)
+		assert.Len(t, sub.Secret, 64)
+		assert.WithinDuration(t, time.Now().Add(24*time.Hour), sub.ExpirationTime, time.Minute)
+		receiver.setSecret(sub.Secret)
+
+		events <- 1
+		<-receiver.received
+		events <- 2
+		<-receiver.received
+
+		receiver.mutex.Lock()
+		require.Len(t, receiver.deliveries, 2)
+		assert.JSONEq(t, `{"data":{"counter":1}}`, receiver.deliveries[0])
+		assert.JSONEq(t, `{"data":{"counter":2}}`, receiver.deliveries[1])
+		receiver.mutex.Unlock()
+
+		req, err := http.NewRequest(http.MethodDelete, server.URL+"?id="+sub.Id, nil)
+		require.NoError(t, err)
+		resp, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+		assert.False(t, api.UnsubscribeWebhook(sub.Id))
+	})
+
+	t.Run("Gone", func(t *testing.T) {
+		receiver := &webhookReceiver{
+			status:   http.StatusGone,
+			received: make(chan struct{}, 1),
+		}
+		callback := httptest.NewServer(receiver)
+		defer callback.Close()
+
+		resp, body := subscribe(t, WebhookSubscriptionRequest{
+			Query:       `subscription { counter }`,
+			CallbackURL: callback.URL,
+		})
+		require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+
+		var sub WebhookSubscription
+		require.NoError(t, json.Unmarshal(body, &sub))
+		receiver.setSecret(sub.Secret)
+
+		events <- 1
+		<-receiver.received
+
+		assert.Eventually(t, func() bool {
+			return !api.UnsubscribeWebhook(sub.Id)
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		testCfg.Webhooks.MaxTTL = 10 * time.Millisecond
+		defer func() {
+			testCfg.Webhooks.MaxTTL = 0
+		}()
+
+		resp, body := subscribe(t, WebhookSubscriptionRequest{
+			Query:       `subscription { counter }`,
+			CallbackURL: "http://example.com",
+			TTLSeconds:  60,
+		})
+		require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+
+		var sub WebhookSubscription
+		require.NoError(t, json.Unmarshal(body, &sub))
+
+		assert.Eventually(t, func() bool {
+			return !api.UnsubscribeWebhook(sub.Id)
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Secrets", func(t *testing.T) {
+		var secrets []string
+		for i := 0; i < 2; i++ {
+			resp, body := subscribe(t, WebhookSubscriptionRequest{
+				Query:       `subscription { counter }`,
+				CallbackURL: "http://example.com",
+			})
+			require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+
+			var sub WebhookSubscription
+			require.NoError(t, json.Unmarshal(body, &sub))
+			require.True(t, api.UnsubscribeWebhook(sub.Id))
+			secrets = append(secrets, sub.Secret)
+		}
+		assert.NotEqual(t, secrets[0], secrets[1])
+	})
+
+	t.Run("MaxSubscriptions", func(t *testing.T) {
+		testCfg.Webhooks.MaxSubscriptions = 1
+		defer func() {
+			testCfg.Webhooks.MaxSubscriptions = 0
+		}()
+
+		req := WebhookSubscriptionRequest{
+			Query:       `subscription { counter }`,
+			CallbackURL: "http://example.com",
+		}
+		resp, body := subscribe(t, req)
+		require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+		var sub WebhookSubscription
+		require.NoError(t, json.Unmarshal(body, &sub))
+
+		resp, body = subscribe(t, req)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		var gqlResp graphql.Response
+		require.NoError(t, json.Unmarshal(body, &gqlResp))
+		require.Len(t, gqlResp.Errors, 1)
+		assert.Equal(t, "Too many webhook subscriptions.", gqlResp.Errors[0].Message)
+
+		// Once a subscription stops, another can take its place.
+		require.True(t, api.UnsubscribeWebhook(sub.Id))
+		resp, body = subscribe(t, req)
+		require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+		require.NoError(t, json.Unmarshal(body, &sub))
+		require.True(t, api.UnsubscribeWebhook(sub.Id))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			Request WebhookSubscriptionRequest
+			Message string
+		}{
+			"Query": {
+				Request: WebhookSubscriptionRequest{
+					Query:       `{ foo }`,
+					CallbackURL: "http://example.com",
+				},
+				Message: "Only subscription operations can be used with webhooks.",
+			},
+			"CallbackURL": {
+				Request: WebhookSubscriptionRequest{
+					Query:       `subscription { counter }`,
+					CallbackURL: "ftp://example.com",
+				},
+				Message: "A valid http or https callback URL is required.",
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				resp, body := subscribe(t, tc.Request)
+				assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+				var gqlResp graphql.Response
+				require.NoError(t, json.Unmarshal(body, &gqlResp))
+				require.Len(t, gqlResp.Errors, 1)
+				assert.Equal(t, tc.Message, gqlResp.Errors[0].Message)
+			})
+		}
+	})
+}
+
+func TestWebhookConfig_CheckCallbackURL(t *testing.T) {
+	cfg := &WebhookConfig{}
+	for rawURL, allowed := range map[string]bool{
+		"http://203.0.113.1/hook":      true,
+		"https://[2001:db8::1]/hook":   true,
+		"http://127.0.0.1/hook":        false,
+		"http://[::1]:8080/hook":       false,
+		"http://10.1.2.3/hook":         false,
+		"http://192.168.0.1/hook":      false,
+		"http://169.254.169.254/hook":  false,
+		"http://[fe80::1]/hook":        false,
+		"http://[::ffff:10.0.0.1]/x":   false,
+		"http://0.0.0.0/hook":          false,
+		"https://[fd00::1]:8443/hook":  false,
+		"http://172.16.0.1:8080/hook":  false,
+		"http://100.64.0.1/hook":       true,
+		"http://198.51.100.254/hook":   true,
+		"http://[2001:db8::ffff]/hook": true,
+	} {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		if allowed {
+			assert.NoError(t, cfg.checkCallbackURL(context.Background(), u), rawURL)
+		} else {
+			assert.Error(t, cfg.checkCallbackURL(context.Background(), u), rawURL)
+		}
+	}
+
+	// The default client enforces the same restrictions when connecting.
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer callback.Close()
+	_, err := cfg.client().Get(callback.URL)
+	assert.Error(t, err)
+
+	// Proxies would connect on the client's behalf, so they must not be used.
+	assert.Nil(t, cfg.client().Transport.(*http.Transport).Proxy)
+}
+
+func TestWebhookConfig_TTL(t *testing.T) {
+	cfg := &WebhookConfig{}
+	assert.Equal(t, 24*time.Hour, cfg.ttl(0))
+	assert.Equal(t, time.Minute, cfg.ttl(60))
+	assert.Equal(t, time.Duration(math.MaxInt64/time.Second)*time.Second, cfg.ttl(math.MaxInt))
+
+	cfg.MaxTTL = time.Hour
+	assert.Equal(t, time.Hour, cfg.ttl(math.MaxInt))
+}